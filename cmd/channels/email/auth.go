@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/emersion/go-sasl"
+)
+
+const (
+	AuthPlain   = "plain"
+	AuthLogin   = "login"
+	AuthXOAuth2 = "xoauth2"
+)
+
+// saslClient returns the SASL client to authenticate against an SMTP relay, or nil if no authentication is configured.
+func (ch *Email) saslClient(ctx context.Context) (sasl.Client, error) {
+	switch ch.Auth {
+	case AuthXOAuth2:
+		token, err := ch.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &xoauth2Client{username: ch.User, token: token}, nil
+	case AuthLogin:
+		if ch.Password == "" {
+			return nil, nil
+		}
+
+		return sasl.NewLoginClient(ch.User, ch.Password), nil
+	default:
+		if ch.Password == "" {
+			return nil, nil
+		}
+
+		return sasl.NewPlainClient("", ch.User, ch.Password), nil
+	}
+}
+
+// xoauth2Client implements the XOAUTH2 SASL mechanism used by Microsoft 365 and Gmail, see
+// https://developers.google.com/gmail/imap/xoauth2-protocol.
+type xoauth2Client struct {
+	username string
+	token    string
+}
+
+// Start implements the sasl.Client interface.
+func (c *xoauth2Client) Start() (string, []byte, error) {
+	return "XOAUTH2", []byte("user=" + c.username + "\x01auth=Bearer " + c.token + "\x01\x01"), nil
+}
+
+// Next implements the sasl.Client interface.
+//
+// The server only sends a challenge if the authentication failed, containing a JSON object describing the error.
+func (c *xoauth2Client) Next(challenge []byte) ([]byte, error) {
+	return nil, fmt.Errorf("XOAUTH2 authentication failed: %s", challenge)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEmail_SetConfig_XOAuth2(t *testing.T) {
+	email := &Email{}
+	require.NoError(t, email.SetConfig(json.RawMessage(`{"host":"localhost","port":"25","auth":"xoauth2",`+
+		`"user":"icinga@example.com","oauth2_token_url":"https://login.example.com/token",`+
+		`"oauth2_client_id":"id","oauth2_client_secret":"secret"}`)))
+	require.NotNil(t, email.tokens)
+	assert.Equal(t, "https://login.example.com/token", email.tokens.TokenURL)
+
+	require.NoError(t, email.SetConfig(json.RawMessage(
+		`{"host":"localhost","port":"25","auth":"login","user":"icinga","password":"secret"}`)))
+	assert.Nil(t, email.tokens, "tokens should be reset when changing the auth mechanism")
+}
+
+func TestXOAuth2Client(t *testing.T) {
+	c := &xoauth2Client{username: "icinga@example.com", token: "ya29.token"}
+
+	mech, ir, err := c.Start()
+	require.NoError(t, err)
+	assert.Equal(t, "XOAUTH2", mech)
+	assert.Equal(t, "user=icinga@example.com\x01auth=Bearer ya29.token\x01\x01", string(ir))
+
+	_, err = c.Next([]byte(`{"status":"401"}`))
+	assert.ErrorContains(t, err, `{"status":"401"}`)
+}
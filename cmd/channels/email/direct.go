@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/emersion/go-smtp"
+	"net"
+	"strings"
+)
+
+// lookupMX is net.LookupMX, replaceable for testing.
+var lookupMX = net.LookupMX
+
+// sendDirect delivers the message to each recipient domain's mail exchangers, bypassing any relay.
+//
+// For each domain, its MX records are tried in order of preference. Without any MX records, the domain itself is used
+// as an implicit MX, as specified in RFC 5321, section 5.1. STARTTLS is used opportunistically, i.e., if supported by
+// the mail exchanger, but without verifying its certificate, as there is no authenticated name to verify against.
+func (ch *Email) sendDirect(reversePath string, recipients []string, msg []byte) error {
+	var errs []error
+	for domain, domainRecipients := range groupRecipientsByDomain(recipients) {
+		if err := ch.sendDirectToDomain(domain, reversePath, domainRecipients, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", domain, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// sendDirectToDomain delivers the message to the first mail exchanger of the domain accepting it.
+func (ch *Email) sendDirectToDomain(domain, reversePath string, recipients []string, msg []byte) error {
+	hosts, err := mailExchangers(domain)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, host := range hosts {
+		err := sendOpportunisticTLS(host, reversePath, recipients, msg)
+		if err == nil {
+			return nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", host, err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// mailExchangers returns the mail exchanger hosts of a domain in order of preference.
+func mailExchangers(domain string) ([]string, error) {
+	mxs, err := lookupMX(domain)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return []string{domain}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot lookup MX records: %w", err)
+	}
+
+	if len(mxs) == 0 {
+		return []string{domain}, nil
+	}
+
+	var hosts []string
+	for _, mx := range mxs {
+		host := strings.TrimSuffix(mx.Host, ".")
+		if host == "" {
+			// A "null MX" record, RFC 7505, explicitly states that this domain does not accept any emails.
+			return nil, fmt.Errorf("domain does not accept emails, having a null MX record")
+		}
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
+
+// sendOpportunisticTLS delivers the message to the host's SMTP port, trying STARTTLS first.
+func sendOpportunisticTLS(host, reversePath string, recipients []string, msg []byte) error {
+	addr := net.JoinHostPort(host, "25")
+
+	client, err := smtp.DialStartTLS(addr, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		client, err = smtp.Dial(addr)
+		if err != nil {
+			return err
+		}
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.SendMail(reversePath, recipients, bytes.NewReader(msg)); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// groupRecipientsByDomain maps each recipient's lowercased domain to all recipients of this domain.
+func groupRecipientsByDomain(recipients []string) map[string][]string {
+	byDomain := make(map[string][]string)
+	for _, recipient := range recipients {
+		at := strings.LastIndex(recipient, "@")
+		domain := strings.ToLower(recipient[at+1:])
+		byDomain[domain] = append(byDomain[domain], recipient)
+	}
+	return byDomain
+}
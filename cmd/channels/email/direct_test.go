@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+)
+
+func TestMailExchangers(t *testing.T) {
+	origLookupMX := lookupMX
+	t.Cleanup(func() { lookupMX = origLookupMX })
+
+	records := map[string][]*net.MX{
+		"example.com":  {{Host: "mx1.example.com.", Pref: 10}, {Host: "mx2.example.com.", Pref: 20}},
+		"example.net":  {},
+		"example.org":  {{Host: ".", Pref: 0}},
+		"broken.local": nil,
+	}
+	lookupMX = func(name string) ([]*net.MX, error) {
+		if name == "broken.local" {
+			return nil, errors.New("server misbehaving")
+		}
+		if mxs, ok := records[name]; ok {
+			return mxs, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	hosts, err := mailExchangers("example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mx1.example.com", "mx2.example.com"}, hosts)
+
+	hosts, err = mailExchangers("example.net")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.net"}, hosts, "domain without MX records should be its implicit MX")
+
+	hosts, err = mailExchangers("example.invalid")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.invalid"}, hosts, "domain without MX records should be its implicit MX")
+
+	_, err = mailExchangers("example.org")
+	assert.Error(t, err, "null MX should reject delivery")
+
+	_, err = mailExchangers("broken.local")
+	assert.Error(t, err)
+}
+
+func TestGroupRecipientsByDomain(t *testing.T) {
+	assert.Equal(t,
+		map[string][]string{
+			"example.com": {"alice@example.com", "bob@Example.COM"},
+			"example.org": {"carol@example.org"},
+		},
+		groupRecipientsByDomain([]string{"alice@example.com", "carol@example.org", "bob@Example.COM"}))
+}
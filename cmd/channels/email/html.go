@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"html/template"
+	"log"
+)
+
+// severityColors maps incident severities to the colors used by Icinga Web, defaulting to defaultSeverityColor.
+var severityColors = map[string]string{
+	"ok":      "#44bb77",
+	"debug":   "#7e7e7e",
+	"info":    "#4b7fbf",
+	"notice":  "#4b7fbf",
+	"warning": "#ffaa44",
+	"err":     "#ff5566",
+	"crit":    "#ff5566",
+	"alert":   "#cc2244",
+	"emerg":   "#aa0033",
+}
+
+const defaultSeverityColor = "#7e7e7e"
+
+// defaultHTMLTemplate renders the HTML part of emails unless a custom html_template is configured.
+//
+// Most email clients only support inline styles and table layouts, thus no stylesheet is used.
+const defaultHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{ .Subject }}</title></head>
+<body style="margin: 0; padding: 16px; font-family: sans-serif; font-size: 14px; color: #333333;">
+<table role="presentation" width="100%" cellspacing="0" cellpadding="0" style="max-width: 640px; border-collapse: collapse;">
+<tr><td style="padding: 12px 16px; background-color: {{ .Color }}; color: #ffffff; font-size: 16px; font-weight: bold;">
+{{ .Subject }}
+</td></tr>
+<tr><td style="padding: 16px; border: 1px solid #dddddd; border-top: none;">
+{{- if .Event.Message }}
+<p style="margin: 0 0 4px 0; font-weight: bold;">{{ if eq .Event.Type "state" }}Output{{ else }}Comment{{ end }}</p>
+<pre style="margin: 0 0 16px 0; padding: 8px; background-color: #f5f5f5; white-space: pre-wrap;">{{ .Event.Message }}</pre>
+{{- end }}
+<p style="margin: 0 0 16px 0;">
+<b>When:</b> {{ .Event.Time.Format "2006-01-02 15:04:05 MST" }}<br>
+{{- if .Event.Username }}
+<b>Author:</b> {{ .Event.Username }}<br>
+{{- end }}
+<b>Object:</b> <a href="{{ .Object.Url }}">{{ .Object.Name }}</a>
+</p>
+{{- if or .Object.Tags .Object.ExtraTags }}
+<table cellspacing="0" cellpadding="4" style="margin: 0 0 16px 0; border-collapse: collapse; font-size: 13px;">
+{{- range $k, $v := .Object.Tags }}
+<tr><td style="border: 1px solid #dddddd; font-weight: bold;">{{ $k }}</td><td style="border: 1px solid #dddddd;">{{ $v }}</td></tr>
+{{- end }}
+{{- range $k, $v := .Object.ExtraTags }}
+<tr><td style="border: 1px solid #dddddd; color: #7e7e7e;">{{ $k }}</td><td style="border: 1px solid #dddddd;">{{ $v }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+{{- range .Rules }}{{ if .HasAnnotations }}
+<p style="margin: 0 0 16px 0;">
+<b>Rule {{ .Name }}</b>
+{{- with .Description }}<br>
+{{ . }}
+{{- end }}
+{{- with .RunbookUrl }}<br>
+<b>Runbook:</b> <a href="{{ . }}">{{ . }}</a>
+{{- end }}
+{{- with .ChatChannel }}<br>
+<b>Chat:</b> {{ . }}
+{{- end }}
+</p>
+{{- end }}{{ end }}
+<p style="margin: 0 0 16px 0;">
+<a href="{{ .Incident.Url }}" style="display: inline-block; padding: 8px 16px; background-color: #0095bf; color: #ffffff; text-decoration: none; border-radius: 4px;">View Incident #{{ .Incident.Id }}</a>
+{{- if .AcknowledgeUrl }}
+<a href="{{ .AcknowledgeUrl }}" style="display: inline-block; padding: 8px 16px; background-color: #44bb77; color: #ffffff; text-decoration: none; border-radius: 4px;">Acknowledge</a>
+{{- end }}
+</p>
+{{- if .Reasons }}
+<p style="margin: 0; font-size: 12px; color: #7e7e7e;">You are receiving this notification as:</p>
+<ul style="margin: 4px 0 0 0; font-size: 12px; color: #7e7e7e;">
+{{- range .Reasons }}
+<li>{{ . }}</li>
+{{- end }}
+</ul>
+{{- end }}
+</td></tr>
+</table>
+</body>
+</html>
+`
+
+var defaultHTML = template.Must(template.New("html").Parse(defaultHTMLTemplate))
+
+// htmlData is passed to the HTML template, providing all fields of the plugin.NotificationRequest.
+type htmlData struct {
+	*plugin.NotificationRequest
+
+	// Subject and Text are the subject and the plain-text part of the email.
+	Subject string
+	Text    string
+
+	// Color represents the incident's severity, e.g., for a header bar.
+	Color string
+}
+
+// parseHTMLTemplate parses a custom HTML template, which is executed with html/template to escape all values.
+func parseHTMLTemplate(text string) (*template.Template, error) {
+	t, err := template.New("html").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse html_template: %w", err)
+	}
+
+	return t, nil
+}
+
+// renderHTML renders the HTML part of an email, using the configured html_template or defaultHTMLTemplate.
+//
+// If the custom template fails, e.g., by referring a non-existent field, the default is used instead, as a broken
+// template must not prevent notifications from being sent. The error is logged to stderr, ending up in the daemon's log.
+func (ch *Email) renderHTML(req *plugin.NotificationRequest, subject, text string) ([]byte, error) {
+	color, ok := severityColors[req.Incident.Severity]
+	if !ok {
+		color = defaultSeverityColor
+	}
+	data := &htmlData{NotificationRequest: req, Subject: subject, Text: text, Color: color}
+
+	var buf bytes.Buffer
+	if ch.htmlTemplate != nil {
+		err := ch.htmlTemplate.Execute(&buf, data)
+		if err == nil {
+			return buf.Bytes(), nil
+		}
+
+		log.Printf("Cannot render html_template, using the default instead: %v", err)
+		buf.Reset()
+	}
+
+	if err := defaultHTML.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("cannot render HTML part: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/icinga/icinga-notifications/internal/testutils/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// htmlDetails are the options of all requests rendered, filling all parts of the HTML template, see
+// plugintest.NewNotificationRequest.
+var htmlDetails = []plugintest.NotificationRequestOption{
+	plugintest.WithTag("service", "<http>"),
+	plugintest.WithRule("Web", "https://wiki.example.com/web?section=http"),
+	plugintest.WithAcknowledgeUrl("https://notifications.example.com/acknowledge?token=x"),
+}
+
+func TestEmail_RenderHTML(t *testing.T) {
+	email := &Email{}
+	require.NoError(t, email.SetConfig(json.RawMessage(`{"host":"localhost","port":"25"}`)))
+
+	req := plugintest.NewNotificationRequest(htmlDetails...)
+	html, err := email.renderHTML(req, "[#42] state web01!http is crit", "plain text")
+	require.NoError(t, err)
+
+	assert.Contains(t, string(html), "background-color: #ff5566", "header should use the severity color")
+	assert.Contains(t, string(html), "&lt;http&gt;", "tags should be escaped")
+	assert.Contains(t, string(html), `<a href="https://icinga.example.com/notifications/incident?id=42"`)
+	assert.Contains(t, string(html), "Acknowledge</a>")
+	assert.Contains(t, string(html), `<a href="https://wiki.example.com/web?section=http">`)
+	assert.Contains(t, string(html), "connection refused")
+}
+
+func TestEmail_RenderHTML_CustomTemplate(t *testing.T) {
+	email := &Email{}
+	require.NoError(t, email.SetConfig(json.RawMessage(
+		`{"host":"localhost","port":"25",`+
+			`"html_template": "<p style=\"color: {{ .Color }}\">{{ .Object.Tags.service }}: {{ .Text }}</p>"}`)))
+
+	html, err := email.renderHTML(plugintest.NewNotificationRequest(htmlDetails...), "subject", "plain & text")
+	require.NoError(t, err)
+	assert.Equal(t, `<p style="color: #ff5566">&lt;http&gt;: plain &amp; text</p>`, string(html))
+
+	require.NoError(t, email.SetConfig(json.RawMessage(
+		`{"host":"localhost","port":"25","html_template":"{{ .Unknown }}"}`)))
+	html, err = email.renderHTML(plugintest.NewNotificationRequest(htmlDetails...), "subject", "text")
+	require.NoError(t, err)
+	assert.Contains(t, string(html), "<!DOCTYPE html>", "failing templates should fall back to the default")
+
+	assert.Error(t, email.SetConfig(json.RawMessage(`{"host":"localhost","port":"25","html_template":"{{ .Subject "}`)))
+}
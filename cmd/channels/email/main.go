@@ -2,18 +2,27 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/emersion/go-sasl"
 	"github.com/emersion/go-smtp"
 	"github.com/google/uuid"
 	"github.com/icinga/icinga-go-library/types"
 	"github.com/icinga/icinga-notifications/internal"
+	"github.com/icinga/icinga-notifications/internal/oauth2"
 	"github.com/icinga/icinga-notifications/pkg/plugin"
 	"github.com/jhillyerd/enmime"
+	"html/template"
 	"net"
 	"net/mail"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 func main() {
@@ -26,6 +35,15 @@ const (
 	EncryptionTLS      = "tls"
 )
 
+const (
+	DeliveryRelay  = "relay"
+	DeliveryDirect = "direct"
+)
+
+// relayRetryInterval is the duration a relay is considered unhealthy after a failed delivery attempt. Unhealthy relays
+// are only used after all healthy relays have failed.
+const relayRetryInterval = 5 * time.Minute
+
 type Email struct {
 	Host       string `json:"host"`
 	Port       string `json:"port"`
@@ -34,6 +52,26 @@ type Email struct {
 	User       string `json:"user"`
 	Password   string `json:"password"`
 	Encryption string `json:"encryption"`
+
+	// Auth is the SASL mechanism, one of AuthPlain, AuthLogin or AuthXOAuth2. For the latter, the access token is
+	// fetched from OAuth2TokenURL by tokens instead of using the Password.
+	Auth               string `json:"auth"`
+	OAuth2TokenURL     string `json:"oauth2_token_url"`
+	OAuth2ClientID     string `json:"oauth2_client_id"`
+	OAuth2ClientSecret string `json:"oauth2_client_secret"`
+	OAuth2Scope        string `json:"oauth2_scope"`
+	tokens             *oauth2.ClientCredentials
+
+	FailoverHosts string `json:"failover_hosts"`
+	Delivery      string `json:"delivery"`
+
+	// HTMLTemplate optionally replaces defaultHTMLTemplate, being parsed into htmlTemplate by SetConfig.
+	HTMLTemplate string `json:"html_template"`
+	htmlTemplate *template.Template
+
+	// relayFailures maps relay addresses to the time of their last failed delivery attempt.
+	relayFailures   map[string]time.Time
+	relayFailuresMu sync.Mutex
 }
 
 func (ch *Email) GetInfo() *plugin.Info {
@@ -45,7 +83,10 @@ func (ch *Email) GetInfo() *plugin.Info {
 				"en_US": "SMTP Host",
 				"de_DE": "SMTP Host",
 			},
-			Required: true,
+			Help: map[string]string{
+				"en_US": "Required unless the delivery method is direct delivery.",
+				"de_DE": "Erforderlich, außer bei der Zustellungsmethode direkte Zustellung.",
+			},
 		},
 		{
 			Name: "port",
@@ -54,9 +95,12 @@ func (ch *Email) GetInfo() *plugin.Info {
 				"en_US": "SMTP Port",
 				"de_DE": "SMTP Port",
 			},
-			Required: true,
-			Min:      types.Int{NullInt64: sql.NullInt64{Int64: 1, Valid: true}},
-			Max:      types.Int{NullInt64: sql.NullInt64{Int64: 65535, Valid: true}},
+			Help: map[string]string{
+				"en_US": "Required unless the delivery method is direct delivery, which always uses port 25.",
+				"de_DE": "Erforderlich, außer bei der Zustellungsmethode direkte Zustellung, die immer Port 25 verwendet.",
+			},
+			Min: types.Int{NullInt64: sql.NullInt64{Int64: 1, Valid: true}},
+			Max: types.Int{NullInt64: sql.NullInt64{Int64: 65535, Valid: true}},
 		},
 		{
 			Name: "sender_name",
@@ -85,8 +129,8 @@ func (ch *Email) GetInfo() *plugin.Info {
 				"de_DE": "SMTP Benutzer",
 			},
 			Help: map[string]string{
-				"en_US": "When configuring an SMTP user, an SMTP password must also be set.",
-				"de_DE": "Das Setzen eines SMTP Benutzers erfordert ebenfalls ein SMTP Passwort.",
+				"en_US": "When configuring an SMTP user, an SMTP password must also be set, unless using OAuth 2.0.",
+				"de_DE": "Das Setzen eines SMTP Benutzers erfordert ebenfalls ein SMTP Passwort, außer bei OAuth 2.0.",
 			},
 		},
 		{
@@ -97,6 +141,105 @@ func (ch *Email) GetInfo() *plugin.Info {
 				"de_DE": "SMTP Passwort",
 			},
 		},
+		{
+			Name: "auth",
+			Type: "option",
+			Label: map[string]string{
+				"en_US": "SMTP Authentication",
+				"de_DE": "SMTP Authentifizierung",
+			},
+			Help: map[string]string{
+				"en_US": "SASL mechanism used if an SMTP user is set. OAuth 2.0 (XOAUTH2) fetches an access token for the SMTP user using the client credentials flow, e.g., for Microsoft 365.",
+				"de_DE": "SASL-Mechanismus, der verwendet wird, wenn ein SMTP Benutzer gesetzt ist. OAuth 2.0 (XOAUTH2) ruft ein Zugriffstoken für den SMTP Benutzer über den Client-Credentials-Flow ab, z.B. für Microsoft 365.",
+			},
+			Options: map[string]string{
+				AuthPlain:   "PLAIN",
+				AuthLogin:   "LOGIN",
+				AuthXOAuth2: "OAuth 2.0 (XOAUTH2)",
+			},
+			Default: AuthPlain,
+		},
+		{
+			Name: "oauth2_token_url",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "OAuth 2.0 Token URL",
+				"de_DE": "OAuth 2.0 Token-URL",
+			},
+			Help: map[string]string{
+				"en_US": "Required for OAuth 2.0, e.g., https://login.microsoftonline.com/<tenant>/oauth2/v2.0/token.",
+				"de_DE": "Erforderlich für OAuth 2.0, z.B. https://login.microsoftonline.com/<tenant>/oauth2/v2.0/token.",
+			},
+		},
+		{
+			Name: "oauth2_client_id",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "OAuth 2.0 Client ID",
+				"de_DE": "OAuth 2.0 Client-ID",
+			},
+		},
+		{
+			Name: "oauth2_client_secret",
+			Type: "secret",
+			Label: map[string]string{
+				"en_US": "OAuth 2.0 Client Secret",
+				"de_DE": "OAuth 2.0 Client-Secret",
+			},
+		},
+		{
+			Name: "oauth2_scope",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "OAuth 2.0 Scope",
+				"de_DE": "OAuth 2.0 Scope",
+			},
+			Help: map[string]string{
+				"en_US": "Space-separated scopes to request, e.g., https://outlook.office365.com/.default for Microsoft 365.",
+				"de_DE": "Leerzeichengetrennte anzufordernde Scopes, z.B. https://outlook.office365.com/.default für Microsoft 365.",
+			},
+		},
+		{
+			Name: "failover_hosts",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "SMTP Failover Hosts",
+				"de_DE": "SMTP Ausweich-Hosts",
+			},
+			Help: map[string]string{
+				"en_US": "Comma-separated list of SMTP hosts, optionally with a port, to be tried in order if the primary host fails.",
+				"de_DE": "Kommagetrennte Liste von SMTP Hosts, optional mit Port, die der Reihe nach versucht werden, falls der primäre Host fehlschlägt.",
+			},
+		},
+		{
+			Name: "delivery",
+			Type: "option",
+			Label: map[string]string{
+				"en_US": "Delivery Method",
+				"de_DE": "Zustellungsmethode",
+			},
+			Help: map[string]string{
+				"en_US": "Either send all emails through the configured SMTP hosts or deliver them directly to the recipient domains' mail exchangers (MX), using STARTTLS if available.",
+				"de_DE": "Entweder alle E-Mails über die konfigurierten SMTP Hosts versenden oder direkt an die Mail-Exchanger (MX) der Empfängerdomains zustellen, mit STARTTLS falls verfügbar.",
+			},
+			Options: map[string]string{
+				DeliveryRelay:  "SMTP Host",
+				DeliveryDirect: "Direct Delivery (MX)",
+			},
+			Default: DeliveryRelay,
+		},
+		{
+			Name: "html_template",
+			Type: "text",
+			Label: map[string]string{
+				"en_US": "HTML Template",
+				"de_DE": "HTML-Vorlage",
+			},
+			Help: map[string]string{
+				"en_US": "Go html/template for the HTML part of emails, replacing the built-in one. Besides all fields of the notification request, .Subject, .Text and the severity's .Color are available.",
+				"de_DE": "Go html/template für den HTML-Teil von E-Mails, ersetzt die eingebaute Vorlage. Neben allen Feldern der Benachrichtigungsanfrage sind .Subject, .Text und die .Color des Schweregrads verfügbar.",
+			},
+		},
 		{
 			Name:     "encryption",
 			Type:     "option",
@@ -132,13 +275,120 @@ func (ch *Email) SetConfig(jsonStr json.RawMessage) error {
 		return fmt.Errorf("failed to load config: %s %w", jsonStr, err)
 	}
 
-	if (ch.User == "") != (ch.Password == "") {
-		return fmt.Errorf("user and password fields must both be set or empty")
+	ch.tokens = nil
+	switch ch.Auth {
+	case AuthPlain, AuthLogin:
+		if (ch.User == "") != (ch.Password == "") {
+			return fmt.Errorf("user and password fields must both be set or empty")
+		}
+	case AuthXOAuth2:
+		if ch.User == "" || ch.OAuth2TokenURL == "" || ch.OAuth2ClientID == "" || ch.OAuth2ClientSecret == "" {
+			return fmt.Errorf("user and all oauth2 fields except for the scope must be set for XOAUTH2")
+		}
+		if u, err := url.Parse(ch.OAuth2TokenURL); err != nil || u.Scheme != "https" {
+			return fmt.Errorf("oauth2_token_url must be an https URL, got %q", ch.OAuth2TokenURL)
+		}
+
+		ch.tokens = &oauth2.ClientCredentials{
+			TokenURL:     ch.OAuth2TokenURL,
+			ClientID:     ch.OAuth2ClientID,
+			ClientSecret: ch.OAuth2ClientSecret,
+			Scope:        ch.OAuth2Scope,
+		}
+	default:
+		return fmt.Errorf("unsupported auth mechanism %q", ch.Auth)
+	}
+
+	switch ch.Delivery {
+	case DeliveryRelay:
+		if ch.Host == "" || ch.Port == "" {
+			return fmt.Errorf("host and port must be set unless the delivery method is %q", DeliveryDirect)
+		}
+	case DeliveryDirect:
+	default:
+		return fmt.Errorf("unsupported delivery method %q", ch.Delivery)
 	}
 
+	for _, host := range ch.failoverHosts() {
+		if host == "" {
+			return fmt.Errorf("failover_hosts must not contain empty entries")
+		}
+	}
+
+	ch.htmlTemplate = nil
+	if strings.TrimSpace(ch.HTMLTemplate) != "" {
+		if ch.htmlTemplate, err = parseHTMLTemplate(ch.HTMLTemplate); err != nil {
+			return err
+		}
+	}
+
+	ch.relayFailuresMu.Lock()
+	ch.relayFailures = nil
+	ch.relayFailuresMu.Unlock()
+
 	return nil
 }
 
+// failoverHosts splits the comma-separated FailoverHosts into its entries.
+func (ch *Email) failoverHosts() []string {
+	if strings.TrimSpace(ch.FailoverHosts) == "" {
+		return nil
+	}
+
+	var hosts []string
+	for _, host := range strings.Split(ch.FailoverHosts, ",") {
+		hosts = append(hosts, strings.TrimSpace(host))
+	}
+	return hosts
+}
+
+// relays returns the addresses of all configured SMTP relays in the order they should be tried.
+//
+// The primary host is followed by all failover hosts, using the primary port if no explicit port is given. Relays with
+// a recently failed delivery attempt are moved to the end, ordered by the time of their last failure.
+func (ch *Email) relays() []string {
+	addrs := []string{net.JoinHostPort(ch.Host, ch.Port)}
+	for _, host := range ch.failoverHosts() {
+		if _, _, err := net.SplitHostPort(host); err == nil {
+			addrs = append(addrs, host)
+		} else {
+			addrs = append(addrs, net.JoinHostPort(strings.Trim(host, "[]"), ch.Port))
+		}
+	}
+
+	ch.relayFailuresMu.Lock()
+	defer ch.relayFailuresMu.Unlock()
+
+	unhealthySince := func(addr string) time.Time {
+		if failure, ok := ch.relayFailures[addr]; ok && time.Since(failure) < relayRetryInterval {
+			return failure
+		}
+		return time.Time{}
+	}
+
+	sort.SliceStable(addrs, func(i, j int) bool {
+		return unhealthySince(addrs[i]).Before(unhealthySince(addrs[j]))
+	})
+
+	return addrs
+}
+
+// setRelayHealth records the outcome of a delivery attempt for the relay.
+func (ch *Email) setRelayHealth(addr string, err error) {
+	ch.relayFailuresMu.Lock()
+	defer ch.relayFailuresMu.Unlock()
+
+	if err == nil {
+		delete(ch.relayFailures, addr)
+		return
+	}
+
+	if ch.relayFailures == nil {
+		ch.relayFailures = make(map[string]time.Time)
+	}
+	ch.relayFailures[addr] = time.Now()
+}
+
 func (ch *Email) SendNotification(req *plugin.NotificationRequest) error {
 	var to []mail.Address
 	for _, address := range req.Contact.Addresses {
@@ -153,25 +403,142 @@ func (ch *Email) SendNotification(req *plugin.NotificationRequest) error {
 
 	var msg bytes.Buffer
 	plugin.FormatMessage(&msg, req)
+	subject := plugin.FormatSubject(req)
 
-	return enmime.Builder().
+	html, err := ch.renderHTML(req, subject, msg.String())
+	if err != nil {
+		return err
+	}
+
+	builder := enmime.Builder().
 		ToAddrs(to).
 		From(ch.SenderName, ch.SenderMail).
-		Subject(plugin.FormatSubject(req)).
+		Subject(subject).
 		Header("Message-Id", fmt.Sprintf("<%s-%s>", uuid.New().String(), ch.SenderMail)).
 		Text(msg.Bytes()).
-		Send(ch)
+		HTML(html)
+	if req.Event.TraceID != "" {
+		builder = builder.Header(plugin.TraceIDHeader, req.Event.TraceID)
+	}
+
+	return builder.Send(ch)
 }
 
+// errRejected marks a message permanently rejected by a relay, see sendVia.
+var errRejected = errors.New("message rejected")
+
 // Send implements the enmime.Sender interface.
+//
+// Unless direct delivery is configured, the message is delivered through the first relay accepting it, preferring
+// healthy relays over recently failed ones. Only relays being unreachable, failing the TLS handshake or the
+// authentication, or temporarily refusing the message are marked unhealthy and failed over. A message permanently
+// rejected by a relay is not tried again, as any other relay would reject it as well.
 func (ch *Email) Send(reversePath string, recipients []string, msg []byte) error {
+	if ch.Delivery == DeliveryDirect {
+		return ch.sendDirect(reversePath, recipients, msg)
+	}
+
+	var errs []error
+	for _, serverAddr := range ch.relays() {
+		err := ch.sendVia(serverAddr, reversePath, recipients, msg)
+		if errors.Is(err, errRejected) {
+			ch.setRelayHealth(serverAddr, nil)
+			return errors.Join(append(errs, fmt.Errorf("%s: %w", serverAddr, err))...)
+		}
+
+		ch.setRelayHealth(serverAddr, err)
+		if err == nil {
+			return nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", serverAddr, err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// sendVia delivers the message through a single SMTP relay.
+//
+// Once connected and authenticated, a reply code of 500 or above to the message is returned wrapped in errRejected.
+func (ch *Email) sendVia(serverAddr, reversePath string, recipients []string, msg []byte) error {
+	client, err := ch.dial(serverAddr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.SendMail(reversePath, recipients, bytes.NewReader(msg)); err != nil {
+		if replyCode(err) >= 500 {
+			return fmt.Errorf("%w: %w", errRejected, err)
+		}
+		return err
+	}
+
+	// The message was already accepted, so failing over to another relay would deliver it twice.
+	_ = client.Quit()
+
+	return nil
+}
+
+// replyCode returns the SMTP reply code of the error, or 0 if it isn't a reply of the server, e.g., a network error.
+func replyCode(err error) int {
+	var smtpErr *smtp.SMTPError
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code
+	}
+
+	return 0
+}
+
+// HealthCheck implements the plugin.HealthChecker interface.
+//
+// The channel is healthy if any relay accepts a connection, the authentication and a NOOP command. Direct delivery
+// is always considered healthy, as the servers depend on the recipients.
+func (ch *Email) HealthCheck() error {
+	if ch.Delivery == DeliveryDirect {
+		return nil
+	}
+
+	var errs []error
+	for _, serverAddr := range ch.relays() {
+		err := ch.noop(serverAddr)
+		if err == nil {
+			return nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", serverAddr, err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// noop connects to a single SMTP relay and sends a NOOP command.
+func (ch *Email) noop(serverAddr string) error {
+	client, err := ch.dial(serverAddr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Noop(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// dial connects to a single SMTP relay using the configured encryption and authenticates if configured.
+func (ch *Email) dial(serverAddr string) (*smtp.Client, error) {
 	var (
 		client *smtp.Client
 		err    error
 	)
 
-	serverAddr := net.JoinHostPort(ch.Host, ch.Port)
-
 	switch ch.Encryption {
 	case EncryptionStartTLS:
 		client, err = smtp.DialStartTLS(serverAddr, nil)
@@ -180,22 +547,27 @@ func (ch *Email) Send(reversePath string, recipients []string, msg []byte) error
 	case EncryptionNone:
 		client, err = smtp.Dial(serverAddr)
 	default:
-		return fmt.Errorf("unsupported mail encryption type %q", ch.Encryption)
+		return nil, fmt.Errorf("unsupported mail encryption type %q", ch.Encryption)
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer func() { _ = client.Close() }()
 
-	if ch.Password != "" {
-		if err = client.Auth(sasl.NewPlainClient("", ch.User, ch.Password)); err != nil {
-			return err
-		}
+	auth, err := ch.saslClient(context.Background())
+	if err != nil {
+		_ = client.Close()
+		return nil, err
 	}
-
-	if err := client.SendMail(reversePath, recipients, bytes.NewReader(msg)); err != nil {
-		return err
+	if auth != nil {
+		if err = client.Auth(auth); err != nil {
+			if ch.tokens != nil {
+				// The token might have been revoked, so fetch a new one for the next attempt.
+				ch.tokens.Invalidate()
+			}
+			_ = client.Close()
+			return nil, err
+		}
 	}
 
-	return client.Quit()
+	return client, nil
 }
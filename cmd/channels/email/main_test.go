@@ -2,7 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/emersion/go-smtp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"sync/atomic"
 	"testing"
 )
 
@@ -19,24 +26,46 @@ func TestEmail_SetConfig(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name:    "empty-json-obj-use-defaults",
+			name:    "empty-json-obj",
 			jsonMsg: `{}`,
-			want:    &Email{SenderName: "Icinga"},
+			wantErr: true,
+		},
+		{
+			name:    "relay-missing-port",
+			jsonMsg: `{"host":"smtp.example.com"}`,
+			wantErr: true,
+		},
+		{
+			name:    "relay-use-defaults",
+			jsonMsg: `{"host":"smtp.example.com","port":"25"}`,
+			want: &Email{
+				Host:       "smtp.example.com",
+				Port:       "25",
+				SenderName: "Icinga",
+				Delivery:   DeliveryRelay,
+				Auth:       AuthPlain,
+			},
 		},
 		{
 			name:    "sender-mail-null-equals-defaults",
-			jsonMsg: `{"sender_mail": null}`,
-			want:    &Email{SenderName: "Icinga"},
+			jsonMsg: `{"host":"smtp.example.com","port":"25","sender_mail": null}`,
+			want: &Email{
+				Host:       "smtp.example.com",
+				Port:       "25",
+				SenderName: "Icinga",
+				Delivery:   DeliveryRelay,
+				Auth:       AuthPlain,
+			},
 		},
 		{
 			name:    "sender-mail-overwrite",
-			jsonMsg: `{"sender_mail": "foo@bar"}`,
-			want:    &Email{SenderName: "Icinga", SenderMail: "foo@bar"},
+			jsonMsg: `{"sender_mail": "foo@bar","delivery":"direct"}`,
+			want:    &Email{SenderName: "Icinga", SenderMail: "foo@bar", Delivery: DeliveryDirect, Auth: AuthPlain},
 		},
 		{
 			name:    "sender-mail-overwrite-empty",
-			jsonMsg: `{"sender_mail": ""}`,
-			want:    &Email{SenderName: "Icinga", SenderMail: ""},
+			jsonMsg: `{"sender_mail": "","delivery":"direct"}`,
+			want:    &Email{SenderName: "Icinga", SenderMail: "", Delivery: DeliveryDirect, Auth: AuthPlain},
 		},
 		{
 			name:    "full-example-config",
@@ -49,11 +78,39 @@ func TestEmail_SetConfig(t *testing.T) {
 				User:       "",
 				Password:   "",
 				Encryption: "none",
+				Delivery:   DeliveryRelay,
+				Auth:       AuthPlain,
 			},
 		},
+		{
+			name:    "direct-delivery",
+			jsonMsg: `{"sender_mail":"icinga@example.com","delivery":"direct"}`,
+			want:    &Email{SenderName: "Icinga", SenderMail: "icinga@example.com", Delivery: DeliveryDirect, Auth: AuthPlain},
+		},
+		{
+			name:    "unknown-delivery",
+			jsonMsg: `{"delivery":"pigeon"}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown-auth",
+			jsonMsg: `{"auth":"cram-md5"}`,
+			wantErr: true,
+		},
+		{
+			name:    "xoauth2-missing-client",
+			jsonMsg: `{"auth":"xoauth2","user":"icinga@example.com","oauth2_token_url":"https://login.example.com/token"}`,
+			wantErr: true,
+		},
+		{
+			name: "xoauth2-insecure-token-url",
+			jsonMsg: `{"auth":"xoauth2","user":"icinga@example.com","oauth2_token_url":"http://login.example.com/token",` +
+				`"oauth2_client_id":"id","oauth2_client_secret":"secret"}`,
+			wantErr: true,
+		},
 		{
 			name:    "user-but-missing-pass",
-			jsonMsg: `{"user": "foo"}`,
+			jsonMsg: `{"host":"smtp.example.com","port":"25","user": "foo"}`,
 			wantErr: true,
 		},
 	}
@@ -70,3 +127,123 @@ func TestEmail_SetConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestEmail_Relays(t *testing.T) {
+	email := &Email{}
+	err := email.SetConfig(json.RawMessage(
+		`{"host":"smtp1.example.com","port":"25","failover_hosts":"smtp2.example.com, smtp3.example.com:587,[2001:db8::1]"}`))
+	assert.NoError(t, err)
+
+	assert.Equal(t,
+		[]string{"smtp1.example.com:25", "smtp2.example.com:25", "smtp3.example.com:587", "[2001:db8::1]:25"},
+		email.relays(),
+		"all relays should be healthy and in configuration order")
+
+	email.setRelayHealth("smtp1.example.com:25", errors.New("connection refused"))
+	email.setRelayHealth("[2001:db8::1]:25", errors.New("connection refused"))
+	email.setRelayHealth("smtp3.example.com:587", errors.New("connection refused"))
+	email.setRelayHealth("[2001:db8::1]:25", nil)
+
+	assert.Equal(t,
+		[]string{"smtp2.example.com:25", "[2001:db8::1]:25", "smtp1.example.com:25", "smtp3.example.com:587"},
+		email.relays(),
+		"unhealthy relays should be tried last, ordered by their last failure")
+
+	assert.Error(t, email.SetConfig(json.RawMessage(
+		`{"host":"smtp1.example.com","port":"25","failover_hosts":"smtp2.example.com,,"}`)))
+}
+
+// relayBackend is a smtp.Backend answering all messages by err, counting the received messages.
+type relayBackend struct {
+	err      error
+	received atomic.Int32
+}
+
+func (b *relayBackend) NewSession(*smtp.Conn) (smtp.Session, error) {
+	return &relaySession{b}, nil
+}
+
+type relaySession struct {
+	backend *relayBackend
+}
+
+func (s *relaySession) Reset()                               {}
+func (s *relaySession) Logout() error                        { return nil }
+func (s *relaySession) Mail(string, *smtp.MailOptions) error { return nil }
+func (s *relaySession) Rcpt(string, *smtp.RcptOptions) error { return nil }
+func (s *relaySession) Data(r io.Reader) error {
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return err
+	}
+
+	s.backend.received.Add(1)
+	return s.backend.err
+}
+
+// startRelay starts an SMTP relay answering all messages by err, returning its backend and address.
+func startRelay(t *testing.T, err error) (*relayBackend, string) {
+	l, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, listenErr)
+
+	backend := &relayBackend{err: err}
+	server := smtp.NewServer(backend)
+	server.Domain = "localhost"
+	go func() { _ = server.Serve(l) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	return backend, l.Addr().String()
+}
+
+func TestEmail_Send(t *testing.T) {
+	newEmail := func(t *testing.T, primary, failover string) *Email {
+		host, port, err := net.SplitHostPort(primary)
+		require.NoError(t, err)
+
+		email := &Email{}
+		require.NoError(t, email.SetConfig(json.RawMessage(fmt.Sprintf(
+			`{"host":%q,"port":%q,"encryption":"none","failover_hosts":%q}`, host, port, failover))))
+		return email
+	}
+
+	send := func(email *Email) error {
+		return email.Send("icinga@example.com", []string{"jane@example.com"}, []byte("Subject: test\r\n\r\ntest\r\n"))
+	}
+
+	t.Run("Unreachable", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		unreachable := l.Addr().String()
+		require.NoError(t, l.Close())
+
+		failover, failoverAddr := startRelay(t, nil)
+		email := newEmail(t, unreachable, failoverAddr)
+
+		require.NoError(t, send(email))
+		assert.Equal(t, int32(1), failover.received.Load())
+		assert.Equal(t, []string{failoverAddr, unreachable}, email.relays(), "unreachable relay should be unhealthy")
+	})
+
+	t.Run("TemporaryFailure", func(t *testing.T) {
+		primary, primaryAddr := startRelay(t, &smtp.SMTPError{Code: 451, Message: "try again later"})
+		failover, failoverAddr := startRelay(t, nil)
+		email := newEmail(t, primaryAddr, failoverAddr)
+
+		require.NoError(t, send(email))
+		assert.Equal(t, int32(1), primary.received.Load())
+		assert.Equal(t, int32(1), failover.received.Load())
+		assert.Equal(t, []string{failoverAddr, primaryAddr}, email.relays(), "failing relay should be unhealthy")
+	})
+
+	t.Run("PermanentFailure", func(t *testing.T) {
+		primary, primaryAddr := startRelay(t, &smtp.SMTPError{Code: 550, Message: "mailbox unavailable"})
+		failover, failoverAddr := startRelay(t, nil)
+		email := newEmail(t, primaryAddr, failoverAddr)
+
+		err := send(email)
+		assert.ErrorIs(t, err, errRejected)
+		assert.Equal(t, 550, replyCode(err))
+		assert.Equal(t, int32(1), primary.received.Load())
+		assert.Equal(t, int32(0), failover.received.Load(), "rejected message must not be failed over")
+		assert.Equal(t, []string{primaryAddr, failoverAddr}, email.relays(), "rejecting relay should stay healthy")
+	})
+}
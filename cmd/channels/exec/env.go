@@ -0,0 +1,163 @@
+package main
+
+import (
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envPrefix is the prefix of all environment variables describing a notification, except for the ones compatible with
+// Icinga 1 and Icinga 2 notification scripts.
+const envPrefix = "ICINGA_NOTIFICATIONS_"
+
+// inheritedEnv lists the environment variables passed on from the channel's own environment, which might contain
+// secrets otherwise, e.g., from the daemon's configuration.
+var inheritedEnv = []string{"PATH", "LANG", "LC_ALL", "TZ"}
+
+// longDateTime formats the event time like Icinga's $icinga.long_date_time$ macro.
+const longDateTime = "2006-01-02 15:04:05 -0700"
+
+// invalidEnvChars matches all characters not allowed in the names of environment variables created from tags and
+// address types.
+var invalidEnvChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// environment returns the environment of a command, i.e., the inheritedEnv and the request's fields.
+//
+// Besides the ICINGA_NOTIFICATIONS_ variables, those of the notification commands shipped with Icinga 2, e.g.,
+// HOSTNAME and SERVICESTATE, and their Icinga 1 counterparts prefixed by ICINGA_ are set, allowing existing scripts to
+// be reused. Their states and notification types are derived from the incident's severity and the event type.
+func environment(req *plugin.NotificationRequest) []string {
+	vars := make(map[string]string)
+	set := func(name, value string) {
+		if value != "" {
+			vars[name] = value
+		}
+	}
+
+	var message strings.Builder
+	plugin.FormatMessage(&message, req)
+	set(envPrefix+"SUBJECT", plugin.FormatSubject(req))
+	set(envPrefix+"MESSAGE", message.String())
+	set(envPrefix+"ACKNOWLEDGE_URL", req.AcknowledgeUrl)
+
+	var email string
+	set(envPrefix+"CONTACT_NAME", req.Contact.FullName)
+	for _, addr := range req.Contact.Addresses {
+		set(envPrefix+"ADDRESS_"+envName(addr.Type), addr.Address)
+		if addr.Type == "email" {
+			email = addr.Address
+		}
+	}
+
+	set(envPrefix+"OBJECT_NAME", req.Object.Name)
+	set(envPrefix+"OBJECT_URL", req.Object.Url)
+	for k, v := range req.Object.ExtraTags {
+		set(envPrefix+"EXTRA_TAG_"+envName(k), v)
+	}
+	for k, v := range req.Object.Tags {
+		set(envPrefix+"TAG_"+envName(k), v)
+	}
+
+	set(envPrefix+"INCIDENT_ID", strconv.FormatInt(req.Incident.Id, 10))
+	set(envPrefix+"INCIDENT_URL", req.Incident.Url)
+	set(envPrefix+"SEVERITY", req.Incident.Severity)
+
+	ev := req.Event
+	set(envPrefix+"EVENT_TYPE", ev.Type)
+	set(envPrefix+"EVENT_TIME", ev.Time.Format(time.RFC3339))
+	set(envPrefix+"EVENT_USERNAME", ev.Username)
+	set(envPrefix+"EVENT_MESSAGE", ev.Message)
+	set(envPrefix+"TRACE_ID", ev.TraceID)
+
+	if host, service := req.Object.Tags["host"], req.Object.Tags["service"]; host != "" {
+		compat := map[string]string{
+			"NOTIFICATIONTYPE":       notificationType(ev.Type, req.Incident.Severity),
+			"HOSTNAME":               host,
+			"HOSTDISPLAYNAME":        host,
+			"LONGDATETIME":           ev.Time.Local().Format(longDateTime),
+			"NOTIFICATIONAUTHORNAME": ev.Username,
+			"NOTIFICATIONAUTHOR":     ev.Username,
+			"NOTIFICATIONCOMMENT":    ev.Message,
+			"USEREMAIL":              email,
+			"CONTACTEMAIL":           email,
+		}
+		if service == "" {
+			compat["HOSTSTATE"] = hostState(req.Incident.Severity)
+			compat["HOSTOUTPUT"] = ev.Message
+		} else {
+			compat["SERVICENAME"] = service
+			compat["SERVICEDESC"] = service
+			compat["SERVICEDISPLAYNAME"] = service
+			compat["SERVICESTATE"] = serviceState(req.Incident.Severity)
+			compat["SERVICEOUTPUT"] = ev.Message
+		}
+
+		for name, value := range compat {
+			set(name, value)
+			set("ICINGA_"+name, value)
+		}
+	}
+
+	env := make([]string, 0, len(inheritedEnv)+len(vars))
+	for _, name := range inheritedEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	for name, value := range vars {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env[len(env)-len(vars):])
+
+	return env
+}
+
+// envName converts a tag name or an address type to a part of an environment variable name, e.g., "host-group" to
+// "HOST_GROUP".
+func envName(s string) string {
+	return invalidEnvChars.ReplaceAllString(strings.ToUpper(s), "_")
+}
+
+// notificationType maps the event type to the notification types of Icinga, e.g., "PROBLEM" or "ACKNOWLEDGEMENT".
+func notificationType(eventType, severity string) string {
+	switch eventType {
+	case event.TypeState:
+		if severity == "ok" {
+			return "RECOVERY"
+		}
+		return "PROBLEM"
+	case event.TypeAcknowledgementSet:
+		return "ACKNOWLEDGEMENT"
+	default:
+		// E.g., "downtime-start" becomes "DOWNTIMESTART", as do "custom" and the flapping types.
+		return strings.ToUpper(strings.ReplaceAll(eventType, "-", ""))
+	}
+}
+
+// hostState maps the severity to an Icinga host state, every severity but ok being a problem.
+func hostState(severity string) string {
+	if severity == "ok" || severity == "" {
+		return "UP"
+	}
+
+	return "DOWN"
+}
+
+// serviceState maps the severity to an Icinga service state, with err being the severity of the UNKNOWN state.
+func serviceState(severity string) string {
+	switch severity {
+	case "ok", "debug", "info", "notice", "":
+		return "OK"
+	case "warning":
+		return "WARNING"
+	case "err":
+		return "UNKNOWN"
+	default:
+		return "CRITICAL"
+	}
+}
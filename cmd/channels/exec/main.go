@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvAllowedPaths lists the directories, separated like PATH, commands must be located in. It is read from the
+// environment of the daemon, as the channel's configuration might be changed by less privileged users in Icinga Web.
+const EnvAllowedPaths = "ICINGA_NOTIFICATIONS_EXEC_ALLOWED_PATHS"
+
+// defaultAllowedPath is used if EnvAllowedPaths is not set.
+var defaultAllowedPath = filepath.Join(internal.SysConfDir, "icinga-notifications", "scripts")
+
+// maxOutput limits the output of a failed command included in the error.
+const maxOutput = 4 << 10
+
+func main() {
+	plugin.RunPlugin(&Exec{})
+}
+
+// Exec runs a local command for each notification, passing the plugin.NotificationRequest as JSON on stdin and its
+// fields as environment variables, see environment.
+type Exec struct {
+	Command    string `json:"command"`
+	Arguments  string `json:"arguments"`
+	WorkingDir string `json:"working_dir"`
+	Timeout    string `json:"timeout"`
+
+	// path is the Command with all symlinks resolved, being located within one of the allowed paths.
+	path    string
+	timeout time.Duration
+}
+
+func (ch *Exec) GetInfo() *plugin.Info {
+	configAttrs := plugin.ConfigOptions{
+		{
+			Name: "command",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Command",
+				"de_DE": "Befehl",
+			},
+			Help: map[string]string{
+				"en_US": "Absolute path of the script or binary to execute. It must be located in one of the directories allowed by the daemon's " + EnvAllowedPaths + " environment variable, defaulting to " + defaultAllowedPath + ".",
+				"de_DE": "Absoluter Pfad des auszuführenden Skripts oder Programms. Es muss in einem der durch die Umgebungsvariable " + EnvAllowedPaths + " des Daemons erlaubten Verzeichnisse liegen, standardmäßig " + defaultAllowedPath + ".",
+			},
+			Required: true,
+		},
+		{
+			Name: "arguments",
+			Type: "text",
+			Label: map[string]string{
+				"en_US": "Arguments",
+				"de_DE": "Argumente",
+			},
+			Help: map[string]string{
+				"en_US": "Command line arguments, one per line. References to environment variables passed to the command, e.g., $HOSTNAME or ${SERVICENAME}, are replaced by their values.",
+				"de_DE": "Kommandozeilenargumente, eines pro Zeile. Verweise auf an den Befehl übergebene Umgebungsvariablen, z.B. $HOSTNAME oder ${SERVICENAME}, werden durch deren Werte ersetzt.",
+			},
+		},
+		{
+			Name: "working_dir",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Working Directory",
+				"de_DE": "Arbeitsverzeichnis",
+			},
+			Help: map[string]string{
+				"en_US": "Absolute path of the directory to run the command in, defaults to the directory of the command.",
+				"de_DE": "Absoluter Pfad des Verzeichnisses, in dem der Befehl ausgeführt wird, standardmäßig das Verzeichnis des Befehls.",
+			},
+		},
+		{
+			Name: "timeout",
+			Type: "number",
+			Label: map[string]string{
+				"en_US": "Timeout",
+				"de_DE": "Zeitüberschreitung",
+			},
+			Help: map[string]string{
+				"en_US": "Timeout in seconds after which the command is killed and the notification considered failed.",
+				"de_DE": "Zeitüberschreitung in Sekunden, nach der der Befehl beendet und die Benachrichtigung als fehlgeschlagen betrachtet wird.",
+			},
+			Default: "60",
+			Min:     types.Int{NullInt64: sql.NullInt64{Int64: 1, Valid: true}},
+		},
+	}
+
+	return &plugin.Info{
+		Name:             "Exec",
+		Version:          internal.Version.Version,
+		Author:           "Icinga GmbH",
+		ConfigAttributes: configAttrs,
+	}
+}
+
+func (ch *Exec) SetConfig(jsonStr json.RawMessage) error {
+	err := plugin.PopulateDefaults(ch)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(jsonStr, ch)
+	if err != nil {
+		return err
+	}
+
+	ch.path, err = allowedCommand(ch.Command, allowedPaths())
+	if err != nil {
+		return err
+	}
+
+	if ch.WorkingDir != "" && !filepath.IsAbs(ch.WorkingDir) {
+		return fmt.Errorf("working_dir must be an absolute path, got %q", ch.WorkingDir)
+	}
+
+	timeout, err := strconv.Atoi(ch.Timeout)
+	if err != nil || timeout < 1 {
+		return fmt.Errorf("timeout must be a positive integer, got %q", ch.Timeout)
+	}
+	ch.timeout = time.Duration(timeout) * time.Second
+
+	return nil
+}
+
+func (ch *Exec) SendNotification(req *plugin.NotificationRequest) error {
+	stdin, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	env := environment(req)
+	lookup := make(map[string]string, len(env))
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		lookup[k] = v
+	}
+
+	var args []string
+	for _, line := range strings.Split(ch.Arguments, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			args = append(args, os.Expand(line, func(k string) string { return lookup[k] }))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ch.timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, ch.path, args...)
+	cmd.Dir = ch.WorkingDir
+	if cmd.Dir == "" {
+		cmd.Dir = filepath.Dir(ch.path)
+	}
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Background processes started by the command might keep its output open, which must not block the channel.
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("command %q did not finish within %v", ch.Command, ch.timeout)
+	} else if err != nil {
+		out := bytes.TrimSpace(output.Bytes())
+		if len(out) > maxOutput {
+			out = append(out[:maxOutput:maxOutput], "..."...)
+		}
+
+		return fmt.Errorf("command %q failed: %w: %s", ch.Command, err, out)
+	}
+
+	return nil
+}
+
+// HealthCheck implements the plugin.HealthChecker interface by verifying that the command is still executable.
+func (ch *Exec) HealthCheck() error {
+	stat, err := os.Stat(ch.path)
+	if err != nil {
+		return err
+	}
+
+	if stat.IsDir() || stat.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("command %q is not executable", ch.Command)
+	}
+
+	return nil
+}
+
+// allowedPaths returns the directories of EnvAllowedPaths or the defaultAllowedPath.
+func allowedPaths() []string {
+	env, ok := os.LookupEnv(EnvAllowedPaths)
+	if !ok {
+		return []string{defaultAllowedPath}
+	}
+
+	var paths []string
+	for _, path := range filepath.SplitList(env) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// allowedCommand resolves the symlinks of the absolute command path and returns it if it is located within one of the
+// allowed directories, whose symlinks are resolved as well.
+func allowedCommand(command string, allowed []string) (string, error) {
+	if !filepath.IsAbs(command) {
+		return "", fmt.Errorf("command must be an absolute path, got %q", command)
+	}
+
+	path, err := filepath.EvalSymlinks(command)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve command: %w", err)
+	}
+
+	for _, dir := range allowed {
+		dir, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+
+		if rel, err := filepath.Rel(dir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("command %q is not located within the allowed paths %q, see %s", command, allowed, EnvAllowedPaths)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/icinga/icinga-notifications/internal/testutils/plugintest"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeScript writes an executable shell script to the directory and returns its path.
+func writeScript(t *testing.T, dir, name, script string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755))
+
+	return path
+}
+
+// withEmail adds the email address of the contact to the requests, see plugintest.NewNotificationRequest.
+var withEmail = plugintest.WithAddress("email", "jdoe@example.com")
+
+func TestExec_SetConfig(t *testing.T) {
+	allowed := t.TempDir()
+	t.Setenv(EnvAllowedPaths, allowed)
+
+	script := writeScript(t, allowed, "notify.sh", "exit 0\n")
+	outside := writeScript(t, t.TempDir(), "notify.sh", "exit 0\n")
+	link := filepath.Join(allowed, "link.sh")
+	require.NoError(t, os.Symlink(outside, link))
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"Allowed", `{"command": "` + script + `"}`, ""},
+		{"Relative", `{"command": "notify.sh"}`, "absolute path"},
+		{"Missing", `{"command": "` + filepath.Join(allowed, "missing.sh") + `"}`, "cannot resolve command"},
+		{"Outside", `{"command": "` + outside + `"}`, "not located within the allowed paths"},
+		{"Traversal", `{"command": "` + allowed + `/../` + filepath.Base(filepath.Dir(outside)) + `/notify.sh"}`, "not located within"},
+		{"SymlinkOutside", `{"command": "` + link + `"}`, "not located within the allowed paths"},
+		{"RelativeWorkingDir", `{"command": "` + script + `", "working_dir": "tmp"}`, "working_dir"},
+		{"InvalidTimeout", `{"command": "` + script + `", "timeout": "0"}`, "timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Exec{}).SetConfig(json.RawMessage(tt.config))
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("DefaultAllowedPath", func(t *testing.T) {
+		require.NoError(t, os.Unsetenv(EnvAllowedPaths))
+		assert.Equal(t, []string{defaultAllowedPath}, allowedPaths())
+	})
+}
+
+func TestExec_SendNotification(t *testing.T) {
+	allowed := t.TempDir()
+	t.Setenv(EnvAllowedPaths, allowed)
+
+	out := filepath.Join(t.TempDir(), "out")
+	script := writeScript(t, allowed, "notify.sh", `cat > "$1"
+{
+  echo
+  echo "args=$2 $3"
+  echo "pwd=$(pwd)"
+  echo "type=$NOTIFICATIONTYPE state=$SERVICESTATE email=$USEREMAIL"
+  echo "legacy=$ICINGA_SERVICEDESC"
+  echo "incident=$ICINGA_NOTIFICATIONS_INCIDENT_ID tag=$ICINGA_NOTIFICATIONS_TAG_SERVICE"
+} >> "$1"
+`)
+
+	ch := &Exec{}
+	require.NoError(t, ch.SetConfig(json.RawMessage(`{"command": "`+script+`", "arguments": "`+out+`\n-l\n$HOSTNAME\n"}`)))
+	require.NoError(t, ch.SendNotification(plugintest.NewNotificationRequest(withEmail)))
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	stdin, rest, ok := strings.Cut(string(data), "\n")
+	require.True(t, ok)
+
+	var req plugin.NotificationRequest
+	require.NoError(t, json.Unmarshal([]byte(stdin), &req))
+	assert.Equal(t, int64(42), req.Incident.Id)
+
+	assert.Equal(t, "args=-l web01\n"+
+		"pwd="+allowed+"\n"+
+		"type=PROBLEM state=CRITICAL email=jdoe@example.com\n"+
+		"legacy=http\n"+
+		"incident=42 tag=http\n", rest)
+}
+
+func TestExec_SendNotification_Failure(t *testing.T) {
+	allowed := t.TempDir()
+	t.Setenv(EnvAllowedPaths, allowed)
+
+	failing := writeScript(t, allowed, "fail.sh", "echo 'mail: no such user' >&2\nexit 3\n")
+	ch := &Exec{}
+	require.NoError(t, ch.SetConfig(json.RawMessage(`{"command": "`+failing+`"}`)))
+	err := ch.SendNotification(plugintest.NewNotificationRequest(withEmail))
+	assert.ErrorContains(t, err, "exit status 3")
+	assert.ErrorContains(t, err, "mail: no such user")
+
+	hanging := writeScript(t, allowed, "hang.sh", "sleep 10\n")
+	ch = &Exec{}
+	require.NoError(t, ch.SetConfig(json.RawMessage(`{"command": "`+hanging+`", "timeout": "1"}`)))
+	start := time.Now()
+	err = ch.SendNotification(plugintest.NewNotificationRequest(withEmail))
+	assert.ErrorContains(t, err, "did not finish within 1s")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestExec_HealthCheck(t *testing.T) {
+	allowed := t.TempDir()
+	t.Setenv(EnvAllowedPaths, allowed)
+
+	script := writeScript(t, allowed, "notify.sh", "exit 0\n")
+	ch := &Exec{}
+	require.NoError(t, ch.SetConfig(json.RawMessage(`{"command": "`+script+`"}`)))
+	assert.NoError(t, ch.HealthCheck())
+
+	require.NoError(t, os.Chmod(script, 0o644))
+	assert.ErrorContains(t, ch.HealthCheck(), "not executable")
+
+	require.NoError(t, os.Remove(script))
+	assert.Error(t, ch.HealthCheck())
+}
+
+func TestNotificationType(t *testing.T) {
+	assert.Equal(t, "PROBLEM", notificationType("state", "crit"))
+	assert.Equal(t, "RECOVERY", notificationType("state", "ok"))
+	assert.Equal(t, "ACKNOWLEDGEMENT", notificationType("acknowledgement-set", "crit"))
+	assert.Equal(t, "DOWNTIMESTART", notificationType("downtime-start", "crit"))
+	assert.Equal(t, "CUSTOM", notificationType("custom", "ok"))
+}
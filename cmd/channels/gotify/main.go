@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AddressType is the contact address type holding the token of the Gotify application the contact receives
+// notifications by.
+const AddressType = "gotify"
+
+// Gotify priorities as interpreted by its Android app, see https://github.com/gotify/android#message-priorities.
+const (
+	// priorityLow shows a notification without a sound.
+	priorityLow = 2
+	// priorityDefault shows a notification with a sound.
+	priorityDefault = 5
+	// priorityHigh additionally pops up the notification on the screen.
+	priorityHigh = 8
+)
+
+func main() {
+	plugin.RunPlugin(&Gotify{})
+}
+
+// Gotify sends push notifications to a self-hosted Gotify server.
+//
+// As Gotify users receive the messages of their own applications, each contact specifies the token of an application of
+// its Gotify user by an AddressType address.
+type Gotify struct {
+	URL string `json:"url"`
+
+	client *http.Client
+}
+
+func (ch *Gotify) GetInfo() *plugin.Info {
+	configAttrs := plugin.ConfigOptions{
+		{
+			Name: "url",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Gotify URL",
+				"de_DE": "Gotify-URL",
+			},
+			Help: map[string]string{
+				"en_US": "Base URL of the Gotify server, e.g., https://gotify.example.com.",
+				"de_DE": "Basis-URL des Gotify-Servers, z.B. https://gotify.example.com.",
+			},
+			Required: true,
+		},
+	}
+
+	return &plugin.Info{
+		Name:             "Gotify",
+		Version:          internal.Version.Version,
+		Author:           "Icinga GmbH",
+		ConfigAttributes: configAttrs,
+	}
+}
+
+func (ch *Gotify) SetConfig(jsonStr json.RawMessage) error {
+	err := plugin.PopulateDefaults(ch)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(jsonStr, ch)
+	if err != nil {
+		return err
+	}
+
+	if u, err := url.Parse(ch.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("url must be an absolute URL, got %q", ch.URL)
+	}
+	ch.URL = strings.TrimSuffix(ch.URL, "/")
+	ch.client = &http.Client{Timeout: 10 * time.Second}
+
+	return nil
+}
+
+// HealthCheck implements the plugin.HealthChecker interface by querying the health of the Gotify server.
+func (ch *Gotify) HealthCheck() error {
+	request, err := http.NewRequest(http.MethodGet, ch.URL+"/health", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := ch.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error while sending http request to gotify server: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	var health struct {
+		Health   string `json:"health"`
+		Database string `json:"database"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&health); err != nil {
+		return fmt.Errorf("%s: cannot parse health response: %w", resp.Status, err)
+	}
+	if health.Health != "green" || health.Database != "green" {
+		return fmt.Errorf("gotify server is unhealthy, health is %q and database is %q", health.Health, health.Database)
+	}
+
+	return nil
+}
+
+func (ch *Gotify) SendNotification(req *plugin.NotificationRequest) error {
+	var token string
+	for _, address := range req.Contact.Addresses {
+		if address.Type == AddressType {
+			token = address.Address
+			break
+		}
+	}
+
+	if token == "" {
+		return fmt.Errorf("contact user %s does not specify a gotify application token", req.Contact.FullName)
+	}
+
+	var message strings.Builder
+	plugin.FormatMessage(&message, req)
+
+	body, err := json.Marshal(map[string]any{
+		"title":    plugin.FormatSubject(req),
+		"message":  message.String(),
+		"priority": priority(req),
+		"extras": map[string]any{
+			"client::display":      map[string]any{"contentType": "text/plain"},
+			"client::notification": map[string]any{"click": map[string]any{"url": req.Incident.Url}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, ch.URL+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("X-Gotify-Key", token)
+	request.Header.Set("Content-Type", "application/json")
+
+	resp, err := ch.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error while sending http request to gotify server: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		var result struct {
+			ErrorDescription string `json:"errorDescription"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err == nil && result.ErrorDescription != "" {
+			return fmt.Errorf("%s: %s", resp.Status, result.ErrorDescription)
+		}
+		return errors.New(resp.Status)
+	}
+
+	return nil
+}
+
+// priority maps the incident's severity to a Gotify priority.
+//
+// Only state changes and renotifications of incidents of at least critical severity are popped up on the screen, while
+// other events, e.g., acknowledgements, are informational and shown without a sound.
+func priority(req *plugin.NotificationRequest) int {
+	if req.Event.Type != event.TypeState && req.Event.Type != event.TypeIncidentAge {
+		return priorityLow
+	}
+
+	severity, err := event.GetSeverityByName(req.Incident.Severity)
+	switch {
+	case err != nil:
+		return priorityDefault
+	case severity >= event.SeverityCrit:
+		return priorityHigh
+	case severity >= event.SeverityWarning:
+		return priorityDefault
+	default:
+		return priorityLow
+	}
+}
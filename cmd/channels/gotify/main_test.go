@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/icinga/icinga-notifications/internal/testutils/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// withToken adds the application token of the contact to the requests, see plugintest.NewNotificationRequest.
+var withToken = plugintest.WithAddress("gotify", "AzpVb1mCVNRzNq.")
+
+func TestGotify_SendNotification(t *testing.T) {
+	var messages []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/gotify/message" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-Gotify-Key") != "AzpVb1mCVNRzNq." {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error": "Unauthorized", "errorCode": 401, "errorDescription": "you need to provide a valid access token or user credentials to access this api"}`))
+			return
+		}
+
+		var message map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&message)
+		messages = append(messages, message)
+		_, _ = w.Write([]byte(`{"id": 25, "appid": 5}`))
+	}))
+	defer server.Close()
+
+	ch := &Gotify{}
+	require.NoError(t, ch.SetConfig(json.RawMessage(`{"url": "`+server.URL+`/gotify/"}`)))
+
+	require.NoError(t, ch.SendNotification(plugintest.NewNotificationRequest(withToken)))
+	require.Len(t, messages, 1)
+	assert.Equal(t, "[#42] state web01!http is crit", messages[0]["title"])
+	assert.Contains(t, messages[0]["message"], "connection refused")
+	assert.Equal(t, float64(priorityHigh), messages[0]["priority"])
+	assert.Equal(t, map[string]any{"click": map[string]any{"url": "https://icinga.example.com/notifications/incident?id=42"}},
+		messages[0]["extras"].(map[string]any)["client::notification"])
+
+	for _, test := range []struct {
+		severity, eventType string
+		want                int
+	}{
+		{"alert", "incident-age", priorityHigh},
+		{"warning", "state", priorityDefault},
+		{"ok", "state", priorityLow},
+		{"crit", "acknowledgement-set", priorityLow},
+	} {
+		req := plugintest.NewNotificationRequest(withToken,
+			plugintest.WithSeverity(test.severity), plugintest.WithEventType(test.eventType))
+		assert.Equal(t, test.want, priority(req), "%s %s", test.severity, test.eventType)
+	}
+
+	req := plugintest.NewNotificationRequest(withToken)
+	req.Contact.Addresses[0].Address = "wrong"
+	assert.ErrorContains(t, ch.SendNotification(req), "401 Unauthorized: you need to provide a valid access token")
+
+	req.Contact.Addresses = nil
+	assert.ErrorContains(t, ch.SendNotification(req), "does not specify a gotify application token")
+}
+
+func TestGotify_HealthCheck(t *testing.T) {
+	health := `{"health": "green", "database": "green"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(health))
+	}))
+	defer server.Close()
+
+	ch := &Gotify{}
+	require.NoError(t, ch.SetConfig(json.RawMessage(`{"url": "`+server.URL+`"}`)))
+	assert.NoError(t, ch.HealthCheck())
+
+	health = `{"health": "orange", "database": "red"}`
+	assert.ErrorContains(t, ch.HealthCheck(), `health is "orange" and database is "red"`)
+
+	assert.ErrorContains(t, (&Gotify{}).SetConfig(json.RawMessage(`{"url": "gotify.example.com"}`)), "absolute URL")
+}
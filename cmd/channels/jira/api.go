@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// apiError is the error body of the Jira REST API.
+type apiError struct {
+	ErrorMessages []string          `json:"errorMessages"`
+	Errors        map[string]string `json:"errors"`
+}
+
+// do sends a request to the Jira REST API, encoding in as JSON and decoding the response into out, if not nil.
+func (ch *Jira) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, ch.URL+path, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if ch.User != "" {
+		req.SetBasicAuth(ch.User, ch.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+ch.Token)
+	}
+
+	resp, err := ch.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error while sending http request to jira: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr apiError
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&apiErr)
+
+		messages := apiErr.ErrorMessages
+		for field, message := range apiErr.Errors {
+			messages = append(messages, field+": "+message)
+		}
+		slices.Sort(messages)
+		if len(messages) == 0 {
+			return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.Join(messages, ", "))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("cannot parse response of %s %s: %w", method, path, err)
+		}
+	}
+
+	return nil
+}
+
+// createIssue creates an issue in the configured project and returns its key.
+func (ch *Jira) createIssue(summary, description string) (string, error) {
+	type named struct {
+		Key  string `json:"key,omitempty"`
+		Name string `json:"name,omitempty"`
+	}
+
+	var in struct {
+		Fields struct {
+			Project     named  `json:"project"`
+			IssueType   named  `json:"issuetype"`
+			Summary     string `json:"summary"`
+			Description string `json:"description"`
+		} `json:"fields"`
+	}
+	in.Fields.Project.Key = ch.Project
+	in.Fields.IssueType.Name = ch.IssueType
+	// The summary is limited to a single line of 255 characters.
+	in.Fields.Summary, _, _ = strings.Cut(summary, "\n")
+	if runes := []rune(in.Fields.Summary); len(runes) > 255 {
+		in.Fields.Summary = string(runes[:254]) + "…"
+	}
+	in.Fields.Description = description
+
+	var out struct {
+		Key string `json:"key"`
+	}
+	if err := ch.do(http.MethodPost, "/rest/api/2/issue", &in, &out); err != nil {
+		return "", err
+	}
+	if out.Key == "" {
+		return "", errors.New("jira did not return the key of the created issue")
+	}
+
+	return out.Key, nil
+}
+
+// addComment adds a comment to the issue.
+func (ch *Jira) addComment(key, comment string) error {
+	in := struct {
+		Body string `json:"body"`
+	}{Body: comment}
+
+	return ch.do(http.MethodPost, "/rest/api/2/issue/"+url.PathEscape(key)+"/comment", &in, nil)
+}
+
+// transition performs the transition of the given name on the issue, matched case-insensitively.
+func (ch *Jira) transition(key, name string) error {
+	path := "/rest/api/2/issue/" + url.PathEscape(key) + "/transitions"
+
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	if err := ch.do(http.MethodGet, path, nil, &available); err != nil {
+		return err
+	}
+
+	var names []string
+	for _, t := range available.Transitions {
+		if strings.EqualFold(t.Name, name) {
+			in := struct {
+				Transition struct {
+					ID string `json:"id"`
+				} `json:"transition"`
+			}{}
+			in.Transition.ID = t.ID
+
+			return ch.do(http.MethodPost, path, &in, nil)
+		}
+
+		names = append(names, t.Name)
+	}
+
+	return fmt.Errorf("issue %s has no transition %q, available are %q", key, name, names)
+}
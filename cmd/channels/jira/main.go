@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func main() {
+	plugin.RunPlugin(&Jira{})
+}
+
+// Jira tracks each incident by a Jira issue. The issue is created with the first notification of the incident, commented
+// on with its further events, and transitioned by CloseTransition once the incident recovers.
+//
+// The key of the issue is kept in the incident's state, passed back by the daemon with each notification, see
+// plugin.StatefulNotifier. Thus, Jira does not keep any state on its own.
+type Jira struct {
+	URL             string `json:"url"`
+	User            string `json:"user"`
+	Token           string `json:"token"`
+	Project         string `json:"project"`
+	IssueType       string `json:"issue_type"`
+	CloseTransition string `json:"close_transition"`
+
+	client *http.Client
+}
+
+// issueState is the state of an incident, returned to and passed back by the daemon as JSON.
+type issueState struct {
+	// Key of the issue, e.g., "OPS-42".
+	Key string `json:"key"`
+
+	// Severity of the incident as of the last handled event.
+	Severity string `json:"severity"`
+
+	// Closed is set once the issue was transitioned by CloseTransition.
+	Closed bool `json:"closed,omitempty"`
+
+	// LastEvent identifies the last handled event, see eventID.
+	LastEvent string `json:"last_event"`
+}
+
+func (ch *Jira) GetInfo() *plugin.Info {
+	configAttrs := plugin.ConfigOptions{
+		{
+			Name: "url",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Jira URL",
+				"de_DE": "Jira-URL",
+			},
+			Help: map[string]string{
+				"en_US": "Base URL of the Jira instance, e.g., https://example.atlassian.net.",
+				"de_DE": "Basis-URL der Jira-Instanz, z.B. https://example.atlassian.net.",
+			},
+			Required: true,
+		},
+		{
+			Name: "user",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "User",
+				"de_DE": "Benutzer",
+			},
+			Help: map[string]string{
+				"en_US": "Email address of the Jira Cloud user the API token belongs to. Leave empty to use the token as a personal access token of Jira Data Center.",
+				"de_DE": "E-Mail-Adresse des Jira-Cloud-Benutzers, zu dem das API-Token gehört. Leer lassen, um das Token als persönliches Zugriffstoken von Jira Data Center zu verwenden.",
+			},
+		},
+		{
+			Name: "token",
+			Type: "secret",
+			Label: map[string]string{
+				"en_US": "API Token",
+				"de_DE": "API-Token",
+			},
+			Required: true,
+		},
+		{
+			Name: "project",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Project Key",
+				"de_DE": "Projektschlüssel",
+			},
+			Help: map[string]string{
+				"en_US": "Key of the project to create the issues in, e.g., OPS.",
+				"de_DE": "Schlüssel des Projekts, in dem die Vorgänge erstellt werden, z.B. OPS.",
+			},
+			Required: true,
+		},
+		{
+			Name: "issue_type",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Issue Type",
+				"de_DE": "Vorgangstyp",
+			},
+			Default: "Task",
+		},
+		{
+			Name: "close_transition",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Close Transition",
+				"de_DE": "Abschließender Übergang",
+			},
+			Help: map[string]string{
+				"en_US": "Name of the workflow transition performed on the issue once the incident recovers.",
+				"de_DE": "Name des Workflow-Übergangs, der für den Vorgang ausgeführt wird, sobald sich der Vorfall erholt.",
+			},
+			Default: "Done",
+		},
+	}
+
+	return &plugin.Info{
+		Name:             "Jira",
+		Version:          internal.Version.Version,
+		Author:           "Icinga GmbH",
+		ConfigAttributes: configAttrs,
+	}
+}
+
+func (ch *Jira) SetConfig(jsonStr json.RawMessage) error {
+	err := plugin.PopulateDefaults(ch)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(jsonStr, ch)
+	if err != nil {
+		return err
+	}
+
+	if u, err := url.Parse(ch.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("url must be an absolute URL, got %q", ch.URL)
+	}
+	ch.URL = strings.TrimSuffix(ch.URL, "/")
+	ch.client = &http.Client{Timeout: 10 * time.Second}
+
+	return nil
+}
+
+// SendNotification is only called for plugins not supporting plugin.StatefulNotifier, which would create an issue for
+// each notification. Thus, it is rejected.
+func (ch *Jira) SendNotification(*plugin.NotificationRequest) error {
+	return errors.New("the daemon does not support stateful channels, please upgrade it")
+}
+
+// SendTestNotification implements the plugin.TestNotifier interface by verifying that the project is accessible,
+// without creating an issue.
+func (ch *Jira) SendTestNotification(*plugin.NotificationRequest) error {
+	return ch.do(http.MethodGet, "/rest/api/2/project/"+url.PathEscape(ch.Project), nil, nil)
+}
+
+// HealthCheck implements the plugin.HealthChecker interface by verifying the credentials against the Jira API.
+func (ch *Jira) HealthCheck() error {
+	return ch.do(http.MethodGet, "/rest/api/2/myself", nil, nil)
+}
+
+// SendStatefulNotification implements the plugin.StatefulNotifier interface.
+//
+// As the issue is shared by all contacts notified about the incident via this channel, each event is only handled for
+// the first one. Renotifications and escalations without a change of the severity are not commented on either.
+func (ch *Jira) SendStatefulNotification(req *plugin.NotificationRequest) (json.RawMessage, error) {
+	var state issueState
+	if req.State != nil {
+		if err := json.Unmarshal(req.State, &state); err != nil {
+			return nil, fmt.Errorf("cannot parse incident state: %w", err)
+		}
+	}
+
+	id := eventID(req.Event)
+	if state.LastEvent == id {
+		return nil, nil
+	}
+
+	// Neither the acknowledge link nor the reasons of a single contact belong into an issue visible to everyone.
+	shared := *req
+	shared.AcknowledgeUrl = ""
+	shared.Reasons = nil
+	var message strings.Builder
+	plugin.FormatMessage(&message, &shared)
+
+	severity := req.Incident.Severity
+	switch {
+	case state.Key == "" && severity == "ok":
+		// The incident recovered before any issue was created, e.g., as the channel was added in the meantime.
+	case state.Key == "":
+		key, err := ch.createIssue(plugin.FormatSubject(&shared), message.String())
+		if err != nil {
+			return nil, err
+		}
+		state.Key = key
+	case severity != state.Severity || (req.Event.Type != event.TypeState && req.Event.Type != event.TypeIncidentAge):
+		if err := ch.addComment(state.Key, plugin.FormatSubject(&shared)+"\n\n"+message.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	if state.Key != "" && severity == "ok" && !state.Closed {
+		if err := ch.transition(state.Key, ch.CloseTransition); err != nil {
+			return nil, err
+		}
+		state.Closed = true
+	}
+
+	state.Severity = severity
+	state.LastEvent = id
+
+	return json.Marshal(state)
+}
+
+// eventID identifies the event of a notification, being equal for the notifications of all its contacts.
+func eventID(ev *plugin.Event) string {
+	return fmt.Sprintf("%s/%d", ev.Type, ev.Time.UnixMilli())
+}
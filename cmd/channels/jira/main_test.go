@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/icinga/icinga-notifications/internal/testutils/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeJira records the requests sent to it and responds like the Jira REST API for the issue OPS-1.
+type fakeJira struct {
+	requests []string
+	comments []string
+}
+
+func (f *fakeJira) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, token, _ := r.BasicAuth(); user != "jdoe@example.com" || token != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"errorMessages": ["You are not authenticated."]}`))
+		return
+	}
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	switch r.Method + " " + r.URL.Path {
+	case "POST /rest/api/2/issue":
+		fields := body["fields"].(map[string]any)
+		if fields["project"].(map[string]any)["key"] != "OPS" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors": {"project": "valid project is required"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": "10000", "key": "OPS-1"}`))
+	case "POST /rest/api/2/issue/OPS-1/comment":
+		f.comments = append(f.comments, body["body"].(string))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{}`))
+	case "GET /rest/api/2/issue/OPS-1/transitions":
+		_, _ = w.Write([]byte(`{"transitions": [{"id": "11", "name": "In Progress"}, {"id": "31", "name": "Done"}]}`))
+	case "POST /rest/api/2/issue/OPS-1/transitions":
+		if body["transition"].(map[string]any)["id"] != "31" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newJira(t *testing.T, config string) (*Jira, *fakeJira) {
+	fake := &fakeJira{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	ch := &Jira{}
+	require.NoError(t, ch.SetConfig(json.RawMessage(
+		`{"url": "`+server.URL+`/", "user": "jdoe@example.com", "token": "secret"`+config+`}`)))
+
+	return ch, fake
+}
+
+func TestJira_SendStatefulNotification(t *testing.T) {
+	ch, fake := newJira(t, `, "project": "OPS"`)
+	start := time.Unix(1700000000, 0)
+
+	state, err := ch.SendStatefulNotification(plugintest.NewNotificationRequest(plugintest.WithEventTime(start)))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"key": "OPS-1", "severity": "crit", "last_event": "state/1700000000000"}`, string(state))
+	assert.Equal(t, []string{"POST /rest/api/2/issue"}, fake.requests)
+
+	t.Run("SameEventForAnotherContact", func(t *testing.T) {
+		newState, err := ch.SendStatefulNotification(plugintest.NewNotificationRequest(
+			plugintest.WithEventTime(start), plugintest.WithState(state)))
+		require.NoError(t, err)
+		assert.Nil(t, newState, "state should be kept")
+		assert.Len(t, fake.requests, 1)
+	})
+
+	t.Run("Renotification", func(t *testing.T) {
+		newState, err := ch.SendStatefulNotification(plugintest.NewNotificationRequest(
+			plugintest.WithEventType("incident-age"), plugintest.WithEventTime(start.Add(time.Hour)),
+			plugintest.WithState(state)))
+		require.NoError(t, err)
+		assert.NotNil(t, newState)
+		assert.Len(t, fake.requests, 1, "unchanged severity should not be commented on")
+	})
+
+	state, err = ch.SendStatefulNotification(plugintest.NewNotificationRequest(plugintest.WithSeverity("warning"),
+		plugintest.WithEventTime(start.Add(2*time.Hour)), plugintest.WithState(state),
+		plugintest.WithAcknowledgeUrl("https://icinga.example.com/notifications/acknowledge?token=personal")))
+	require.NoError(t, err)
+	require.Len(t, fake.comments, 1)
+	assert.True(t, strings.HasPrefix(fake.comments[0], "[#42] state web01!http is warning\n\n"))
+	assert.NotContains(t, fake.comments[0], "personal", "contact specific acknowledge link should be omitted")
+
+	state, err = ch.SendStatefulNotification(plugintest.NewNotificationRequest(plugintest.WithSeverity("ok"),
+		plugintest.WithEventTime(start.Add(3*time.Hour)), plugintest.WithState(state)))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"key": "OPS-1", "severity": "ok", "closed": true, "last_event": "state/1700010800000"}`, string(state))
+	assert.Equal(t, []string{
+		"POST /rest/api/2/issue",
+		"POST /rest/api/2/issue/OPS-1/comment",
+		"POST /rest/api/2/issue/OPS-1/comment",
+		"GET /rest/api/2/issue/OPS-1/transitions",
+		"POST /rest/api/2/issue/OPS-1/transitions",
+	}, fake.requests)
+}
+
+func TestJira_SendStatefulNotification_Failure(t *testing.T) {
+	ch, _ := newJira(t, `, "project": "DEV"`)
+	_, err := ch.SendStatefulNotification(plugintest.NewNotificationRequest(plugintest.WithEventTime(time.Now())))
+	assert.ErrorContains(t, err, "400 Bad Request: project: valid project is required")
+
+	ch, _ = newJira(t, `, "project": "OPS", "close_transition": "Resolve"`)
+	_, err = ch.SendStatefulNotification(plugintest.NewNotificationRequest(plugintest.WithSeverity("ok"),
+		plugintest.WithEventTime(time.Now()), plugintest.WithState(json.RawMessage(`{"key": "OPS-1"}`))))
+	assert.ErrorContains(t, err, `issue OPS-1 has no transition "Resolve", available are ["In Progress" "Done"]`)
+
+	ch, _ = newJira(t, `, "project": "OPS", "token": "wrong"`)
+	assert.ErrorContains(t, ch.HealthCheck(), "401 Unauthorized: You are not authenticated.")
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AddressType is the contact address type holding the Pushover user or group key of a contact.
+const AddressType = "pushover"
+
+// apiURL is the base URL of the Pushover API.
+const apiURL = "https://api.pushover.net"
+
+// Limits of the Pushover API for the message, its title and the emergency priority's retry and expire parameters.
+const (
+	maxMessageLength = 1024
+	maxTitleLength   = 250
+	minRetry         = 30
+	maxExpire        = 10800
+)
+
+// Pushover priorities, see https://pushover.net/api#priority.
+const (
+	priorityNormal    = 0
+	priorityHigh      = 1
+	priorityEmergency = 2
+)
+
+func main() {
+	plugin.RunPlugin(&Pushover{})
+}
+
+// Pushover sends push notifications to the Pushover user or group key of the contact's AddressType address.
+//
+// Notifications of critical incidents are sent with the emergency priority, repeated every Retry seconds until they are
+// acknowledged in the Pushover app or Expire seconds passed. Once the incident recovers or is acknowledged in Icinga
+// Notifications, the remaining repetitions are cancelled.
+type Pushover struct {
+	Token  string `json:"token"`
+	Retry  string `json:"retry"`
+	Expire string `json:"expire"`
+	Sound  string `json:"sound"`
+
+	retry  int
+	expire int
+	apiURL string
+	client *http.Client
+}
+
+func (ch *Pushover) GetInfo() *plugin.Info {
+	configAttrs := plugin.ConfigOptions{
+		{
+			Name: "token",
+			Type: "secret",
+			Label: map[string]string{
+				"en_US": "API Token",
+				"de_DE": "API-Token",
+			},
+			Help: map[string]string{
+				"en_US": "API token of the Pushover application the notifications are sent by.",
+				"de_DE": "API-Token der Pushover-Anwendung, über die die Benachrichtigungen gesendet werden.",
+			},
+			Required: true,
+		},
+		{
+			Name: "retry",
+			Type: "number",
+			Label: map[string]string{
+				"en_US": "Emergency Retry",
+				"de_DE": "Notfall-Wiederholung",
+			},
+			Help: map[string]string{
+				"en_US": "Seconds after which notifications of critical incidents are repeated until they are acknowledged.",
+				"de_DE": "Sekunden, nach denen Benachrichtigungen kritischer Vorfälle wiederholt werden, bis sie bestätigt sind.",
+			},
+			Default: "60",
+			Min:     types.Int{NullInt64: sql.NullInt64{Int64: minRetry, Valid: true}},
+		},
+		{
+			Name: "expire",
+			Type: "number",
+			Label: map[string]string{
+				"en_US": "Emergency Expire",
+				"de_DE": "Notfall-Ablauf",
+			},
+			Help: map[string]string{
+				"en_US": "Seconds after which notifications of critical incidents are no longer repeated.",
+				"de_DE": "Sekunden, nach denen Benachrichtigungen kritischer Vorfälle nicht mehr wiederholt werden.",
+			},
+			Default: "3600",
+			Min:     types.Int{NullInt64: sql.NullInt64{Int64: minRetry, Valid: true}},
+			Max:     types.Int{NullInt64: sql.NullInt64{Int64: maxExpire, Valid: true}},
+		},
+		{
+			Name: "sound",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Sound",
+				"de_DE": "Ton",
+			},
+			Help: map[string]string{
+				"en_US": "Name of the sound played on the devices, e.g., siren. Leave empty to use the user's default sound.",
+				"de_DE": "Name des auf den Geräten abgespielten Tons, z.B. siren. Leer lassen, um den Standardton des Benutzers zu verwenden.",
+			},
+		},
+	}
+
+	return &plugin.Info{
+		Name:             "Pushover",
+		Version:          internal.Version.Version,
+		Author:           "Icinga GmbH",
+		ConfigAttributes: configAttrs,
+	}
+}
+
+func (ch *Pushover) SetConfig(jsonStr json.RawMessage) error {
+	err := plugin.PopulateDefaults(ch)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(jsonStr, ch)
+	if err != nil {
+		return err
+	}
+
+	if ch.retry, err = strconv.Atoi(ch.Retry); err != nil || ch.retry < minRetry {
+		return fmt.Errorf("retry must be an integer of at least %d, got %q", minRetry, ch.Retry)
+	}
+	if ch.expire, err = strconv.Atoi(ch.Expire); err != nil || ch.expire < ch.retry || ch.expire > maxExpire {
+		return fmt.Errorf("expire must be an integer between retry and %d, got %q", maxExpire, ch.Expire)
+	}
+
+	if ch.apiURL == "" {
+		ch.apiURL = apiURL
+	}
+	ch.client = &http.Client{Timeout: 10 * time.Second}
+
+	return nil
+}
+
+// HealthCheck implements the plugin.HealthChecker interface by verifying the API token against the Pushover API.
+func (ch *Pushover) HealthCheck() error {
+	return ch.do(http.MethodGet, "/1/apps/limits.json?"+url.Values{"token": {ch.Token}}.Encode(), nil)
+}
+
+func (ch *Pushover) SendNotification(req *plugin.NotificationRequest) error {
+	var user string
+	for _, address := range req.Contact.Addresses {
+		if address.Type == AddressType {
+			user = address.Address
+			break
+		}
+	}
+
+	if user == "" {
+		return fmt.Errorf("contact user %s does not specify a pushover user key", req.Contact.FullName)
+	}
+
+	var message strings.Builder
+	plugin.FormatMessage(&message, req)
+
+	form := url.Values{
+		"token":     {ch.Token},
+		"user":      {user},
+		"title":     {truncate(plugin.FormatSubject(req), maxTitleLength)},
+		"message":   {truncate(message.String(), maxMessageLength)},
+		"url":       {req.Incident.Url},
+		"url_title": {fmt.Sprintf("Incident #%d", req.Incident.Id)},
+		"timestamp": {strconv.FormatInt(req.Event.Time.Unix(), 10)},
+	}
+	if ch.Sound != "" {
+		form.Set("sound", ch.Sound)
+	}
+
+	prio := priority(req)
+	form.Set("priority", strconv.Itoa(prio))
+	if prio == priorityEmergency {
+		form.Set("retry", strconv.Itoa(ch.retry))
+		form.Set("expire", strconv.Itoa(ch.expire))
+		form.Set("tags", incidentTag(req.Incident.Id))
+	}
+
+	if err := ch.do(http.MethodPost, "/1/messages.json", form); err != nil {
+		return err
+	}
+
+	if req.Incident.Severity == "ok" || req.Event.Type == event.TypeAcknowledgementSet {
+		// Stop repeating previous emergency notifications, as nobody needs to act anymore.
+		path := "/1/receipts/cancel_by_tag/" + url.PathEscape(incidentTag(req.Incident.Id)) + ".json"
+		return ch.do(http.MethodPost, path, url.Values{"token": {ch.Token}})
+	}
+
+	return nil
+}
+
+// priority maps the incident's severity to a Pushover priority.
+//
+// Only state changes and renotifications of incidents of at least critical severity are sent with the emergency
+// priority, while other events, e.g., acknowledgements, are informational and sent with the normal priority.
+func priority(req *plugin.NotificationRequest) int {
+	if req.Event.Type != event.TypeState && req.Event.Type != event.TypeIncidentAge {
+		return priorityNormal
+	}
+
+	severity, err := event.GetSeverityByName(req.Incident.Severity)
+	switch {
+	case err != nil:
+		return priorityNormal
+	case severity >= event.SeverityCrit:
+		return priorityEmergency
+	case severity >= event.SeverityWarning:
+		return priorityHigh
+	default:
+		return priorityNormal
+	}
+}
+
+// incidentTag is attached to the emergency notifications of an incident, allowing to cancel them all at once.
+func incidentTag(id int64) string {
+	return fmt.Sprintf("icinga-notifications-incident-%d", id)
+}
+
+// truncate s to at most n characters, as longer messages are rejected by the Pushover API.
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+
+	return s
+}
+
+// do sends a request with the form to the Pushover API, returning the errors reported by it.
+func (ch *Pushover) do(method, path string, form url.Values) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	request, err := http.NewRequest(method, ch.apiURL+path, body)
+	if err != nil {
+		return err
+	}
+	if form != nil {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := ch.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error while sending http request to pushover: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	var result struct {
+		Status int      `json:"status"`
+		Errors []string `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil || result.Status != 1 {
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("%s: %s", resp.Status, strings.Join(result.Errors, ", "))
+		}
+		return errors.New(resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/icinga/icinga-notifications/internal/testutils/plugintest"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakePushover records the requests sent to it and responds like the Pushover API for the API token "secret".
+type fakePushover struct {
+	requests []string
+	forms    []url.Values
+}
+
+func (f *fakePushover) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	f.forms = append(f.forms, r.Form)
+
+	if r.Form.Get("token") != "secret" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"token": "invalid", "errors": ["application token is invalid"], "status": 0}`))
+		return
+	}
+
+	_, _ = w.Write([]byte(`{"status": 1, "request": "5042853c-402d-4a18-abcb-168734a801de"}`))
+}
+
+func newPushover(t *testing.T, config string) (*Pushover, *fakePushover) {
+	fake := &fakePushover{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	ch := &Pushover{apiURL: server.URL}
+	require.NoError(t, ch.SetConfig(json.RawMessage(`{"token": "secret"`+config+`}`)))
+
+	return ch, fake
+}
+
+// withUserKey adds the user key of the contact to the requests, see plugintest.NewNotificationRequest.
+var withUserKey = plugintest.WithAddress("pushover", "uQiRzpo4DXghDmr9QzzfQu27cmVRsG")
+
+func TestPushover_SetConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"Defaults", ``, ""},
+		{"RetryTooShort", `, "retry": "10"`, "retry must be an integer of at least 30"},
+		{"ExpireTooLong", `, "expire": "86400"`, "expire must be an integer between retry and 10800"},
+		{"ExpireBeforeRetry", `, "retry": "120", "expire": "60"`, "expire must be an integer between retry"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := (&Pushover{}).SetConfig(json.RawMessage(`{"token": "secret"` + test.config + `}`))
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestPushover_SendNotification(t *testing.T) {
+	t.Run("Emergency", func(t *testing.T) {
+		ch, fake := newPushover(t, `, "retry": "120", "sound": "siren"`)
+		req := plugintest.NewNotificationRequest(withUserKey, plugintest.WithMessage(strings.Repeat("x", 2000)))
+		require.NoError(t, ch.SendNotification(req))
+
+		require.Equal(t, []string{"POST /1/messages.json"}, fake.requests)
+		form := fake.forms[0]
+		assert.Equal(t, "uQiRzpo4DXghDmr9QzzfQu27cmVRsG", form.Get("user"))
+		assert.Equal(t, "[#42] state web01!http is crit", form.Get("title"))
+		assert.Len(t, []rune(form.Get("message")), maxMessageLength, "message should be truncated")
+		assert.Equal(t, "https://icinga.example.com/notifications/incident?id=42", form.Get("url"))
+		assert.Equal(t, "1700000000", form.Get("timestamp"))
+		assert.Equal(t, "siren", form.Get("sound"))
+		assert.Equal(t, "2", form.Get("priority"))
+		assert.Equal(t, "120", form.Get("retry"))
+		assert.Equal(t, "3600", form.Get("expire"))
+		assert.Equal(t, "icinga-notifications-incident-42", form.Get("tags"))
+	})
+
+	t.Run("Priorities", func(t *testing.T) {
+		for _, test := range []struct {
+			severity, eventType string
+			want                string
+		}{
+			{"emerg", "incident-age", "2"},
+			{"err", "state", "1"},
+			{"warning", "state", "1"},
+			{"info", "state", "0"},
+			{"crit", "mute", "0"},
+		} {
+			ch, fake := newPushover(t, ``)
+			req := plugintest.NewNotificationRequest(withUserKey,
+				plugintest.WithSeverity(test.severity), plugintest.WithEventType(test.eventType))
+			require.NoError(t, ch.SendNotification(req))
+			assert.Equal(t, test.want, fake.forms[0].Get("priority"), "%s %s", test.severity, test.eventType)
+			assert.Equal(t, test.want == "2", fake.forms[0].Has("retry"), "%s %s", test.severity, test.eventType)
+		}
+	})
+
+	t.Run("CancelEmergency", func(t *testing.T) {
+		for _, req := range []*plugin.NotificationRequest{
+			plugintest.NewNotificationRequest(withUserKey, plugintest.WithSeverity("ok")),
+			plugintest.NewNotificationRequest(withUserKey, plugintest.WithEventType("acknowledgement-set")),
+		} {
+			ch, fake := newPushover(t, ``)
+			require.NoError(t, ch.SendNotification(req))
+			assert.Equal(t, []string{
+				"POST /1/messages.json",
+				"POST /1/receipts/cancel_by_tag/icinga-notifications-incident-42.json",
+			}, fake.requests)
+			assert.Equal(t, "0", fake.forms[0].Get("priority"))
+		}
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		ch, _ := newPushover(t, ``)
+		ch.Token = "wrong"
+		assert.ErrorContains(t, ch.SendNotification(plugintest.NewNotificationRequest(withUserKey)),
+			"400 Bad Request: application token is invalid")
+		assert.ErrorContains(t, ch.HealthCheck(), "application token is invalid")
+
+		req := plugintest.NewNotificationRequest(withUserKey)
+		req.Contact.Addresses = nil
+		assert.ErrorContains(t, ch.SendNotification(req), "does not specify a pushover user key")
+	})
+}
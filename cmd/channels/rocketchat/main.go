@@ -69,6 +69,31 @@ func (ch *RocketChat) SetConfig(jsonStr json.RawMessage) error {
 	return json.Unmarshal(jsonStr, ch)
 }
 
+// HealthCheck implements the plugin.HealthChecker interface by verifying the credentials against the Rocket.Chat API.
+func (ch *RocketChat) HealthCheck() error {
+	request, err := http.NewRequest(http.MethodGet, ch.URL+"/api/v1/me", nil)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("X-Auth-Token", ch.Token)
+	request.Header.Set("X-User-Id", ch.UserID)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error while sending http request to rocketchat server: %w", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+
+	return nil
+}
+
 func (ch *RocketChat) SendNotification(req *plugin.NotificationRequest) error {
 	var output bytes.Buffer
 	_, _ = fmt.Fprint(&output, plugin.FormatSubject(req)+"\n\n")
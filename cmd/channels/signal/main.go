@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AddressType is the contact address type holding the phone number of the contact's Signal account, e.g., "+4915112345678".
+const AddressType = "signal"
+
+func main() {
+	plugin.RunPlugin(&Signal{})
+}
+
+// Signal sends notifications from the Signal account Number, registered with a signal-cli-rest-api instance, see
+// https://github.com/bbernhard/signal-cli-rest-api.
+//
+// Each message is prefixed by an emoji reflecting the incident's severity. Messages longer than MaxLength are split at
+// line breaks into multiple ones, as Signal only shows the beginning of long messages.
+type Signal struct {
+	URL       string `json:"url"`
+	Number    string `json:"number"`
+	MaxLength string `json:"max_length"`
+
+	maxLength int
+	client    *http.Client
+}
+
+func (ch *Signal) GetInfo() *plugin.Info {
+	configAttrs := plugin.ConfigOptions{
+		{
+			Name: "url",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "signal-cli REST API URL",
+				"de_DE": "signal-cli-REST-API-URL",
+			},
+			Help: map[string]string{
+				"en_US": "Base URL of the signal-cli-rest-api instance, e.g., http://localhost:8080.",
+				"de_DE": "Basis-URL der signal-cli-rest-api-Instanz, z.B. http://localhost:8080.",
+			},
+			Required: true,
+		},
+		{
+			Name: "number",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Sender Number",
+				"de_DE": "Absendernummer",
+			},
+			Help: map[string]string{
+				"en_US": "Phone number of the Signal account registered with signal-cli, e.g., +4915112345678.",
+				"de_DE": "Telefonnummer des bei signal-cli registrierten Signal-Kontos, z.B. +4915112345678.",
+			},
+			Required: true,
+		},
+		{
+			Name: "max_length",
+			Type: "number",
+			Label: map[string]string{
+				"en_US": "Maximum Message Length",
+				"de_DE": "Maximale Nachrichtenlänge",
+			},
+			Help: map[string]string{
+				"en_US": "Number of characters after which a notification is split into multiple messages.",
+				"de_DE": "Anzahl der Zeichen, nach denen eine Benachrichtigung auf mehrere Nachrichten aufgeteilt wird.",
+			},
+			Default: "2000",
+			Min:     types.Int{NullInt64: sql.NullInt64{Int64: 100, Valid: true}},
+		},
+	}
+
+	return &plugin.Info{
+		Name:             "Signal",
+		Version:          internal.Version.Version,
+		Author:           "Icinga GmbH",
+		ConfigAttributes: configAttrs,
+	}
+}
+
+func (ch *Signal) SetConfig(jsonStr json.RawMessage) error {
+	err := plugin.PopulateDefaults(ch)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(jsonStr, ch)
+	if err != nil {
+		return err
+	}
+
+	if u, err := url.Parse(ch.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("url must be an absolute URL, got %q", ch.URL)
+	}
+	ch.URL = strings.TrimSuffix(ch.URL, "/")
+
+	if ch.Number == "" {
+		return errors.New("number must not be empty")
+	}
+	if ch.maxLength, err = strconv.Atoi(ch.MaxLength); err != nil || ch.maxLength < 100 {
+		return fmt.Errorf("max_length must be an integer of at least 100, got %q", ch.MaxLength)
+	}
+
+	ch.client = &http.Client{Timeout: 30 * time.Second}
+
+	return nil
+}
+
+// HealthCheck implements the plugin.HealthChecker interface by verifying that the sender's account is registered with
+// the signal-cli-rest-api instance.
+func (ch *Signal) HealthCheck() error {
+	var accounts []string
+	if err := ch.do(http.MethodGet, "/v1/accounts", nil, &accounts); err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		if account == ch.Number {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("number %s is not registered with signal-cli", ch.Number)
+}
+
+func (ch *Signal) SendNotification(req *plugin.NotificationRequest) error {
+	var recipient string
+	for _, address := range req.Contact.Addresses {
+		if address.Type == AddressType {
+			recipient = address.Address
+			break
+		}
+	}
+
+	if recipient == "" {
+		return fmt.Errorf("contact user %s does not specify a signal phone number", req.Contact.FullName)
+	}
+
+	var output strings.Builder
+	_, _ = fmt.Fprint(&output, severityEmoji(req)+" "+plugin.FormatSubject(req)+"\n\n")
+	plugin.FormatMessage(&output, req)
+
+	parts := split(strings.TrimSpace(output.String()), ch.maxLength)
+	for i, part := range parts {
+		if len(parts) > 1 {
+			part = fmt.Sprintf("(%d/%d) %s", i+1, len(parts), part)
+		}
+
+		message := map[string]any{"message": part, "number": ch.Number, "recipients": []string{recipient}}
+		if err := ch.do(http.MethodPost, "/v2/send", message, nil); err != nil {
+			return fmt.Errorf("cannot send message %d of %d: %w", i+1, len(parts), err)
+		}
+	}
+
+	return nil
+}
+
+// severityEmoji returns an emoji reflecting the incident's severity, or the event type for events not caused by a
+// change of the severity, e.g., acknowledgements.
+func severityEmoji(req *plugin.NotificationRequest) string {
+	switch req.Event.Type {
+	case event.TypeState, event.TypeIncidentAge:
+	case event.TypeAcknowledgementSet, event.TypeAcknowledgementCleared:
+		return "👤"
+	case event.TypeDowntimeStart, event.TypeDowntimeEnd, event.TypeDowntimeRemoved, event.TypeMute, event.TypeUnmute:
+		return "🔕"
+	default:
+		return "💬"
+	}
+
+	severity, err := event.GetSeverityByName(req.Incident.Severity)
+	switch {
+	case err != nil:
+		return "❔"
+	case severity >= event.SeverityCrit:
+		return "🚨"
+	case severity == event.SeverityErr:
+		return "❗"
+	case severity == event.SeverityWarning:
+		return "⚠️"
+	case severity == event.SeverityOK:
+		return "✅"
+	default:
+		return "ℹ️"
+	}
+}
+
+// split the message into parts of at most n characters, each reserving some space for its "(i/n) " prefix.
+//
+// Parts end at the last line break within the limit, if any, or at the last space otherwise.
+func split(message string, n int) []string {
+	const reserved = len("(99/99) ")
+
+	var parts []string
+	for rest := []rune(message); len(rest) > 0; {
+		if len(rest) <= n && parts == nil {
+			return []string{string(rest)}
+		}
+		if len(rest) <= n-reserved {
+			parts = append(parts, string(rest))
+			break
+		}
+
+		part := string(rest[:n-reserved])
+		end := strings.LastIndex(part, "\n")
+		if end <= 0 {
+			end = strings.LastIndex(part, " ")
+		}
+		if end <= 0 {
+			end = len(part)
+		}
+
+		parts = append(parts, strings.TrimSpace(part[:end]))
+		rest = []rune(strings.TrimLeft(string(rest)[end:], " \n"))
+	}
+
+	return parts
+}
+
+// do sends a request with the JSON body to the signal-cli-rest-api and parses the JSON response into result, if not nil.
+func (ch *Signal) do(method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequest(method, ch.URL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := ch.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error while sending http request to signal-cli: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&apiErr); err == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s: %s", resp.Status, apiErr.Error)
+		}
+		return errors.New(resp.Status)
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(result); err != nil {
+			return fmt.Errorf("cannot parse signal-cli response: %w", err)
+		}
+	}
+
+	return nil
+}
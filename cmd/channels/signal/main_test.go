@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/icinga/icinga-notifications/internal/testutils/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeSignal responds like the signal-cli-rest-api with the account +4915100000000 registered.
+type fakeSignal struct {
+	messages []map[string]any
+}
+
+func (f *fakeSignal) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method + " " + r.URL.Path {
+	case "GET /v1/accounts":
+		_, _ = w.Write([]byte(`["+4915100000000"]`))
+	case "POST /v2/send":
+		var message map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&message)
+		if message["number"] != "+4915100000000" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": "User +4915199999999 is not registered"}`))
+			return
+		}
+		f.messages = append(f.messages, message)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"timestamp": "1700000000000"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newSignal(t *testing.T, config string) (*Signal, *fakeSignal) {
+	fake := &fakeSignal{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	ch := &Signal{}
+	require.NoError(t, ch.SetConfig(json.RawMessage(`{"url": "`+server.URL+`/", "number": "+4915100000000"`+config+`}`)))
+
+	return ch, fake
+}
+
+// withNumber adds the phone number of the contact to the requests, see plugintest.NewNotificationRequest.
+var withNumber = plugintest.WithAddress("signal", "+4915112345678")
+
+func TestSignal_SendNotification(t *testing.T) {
+	ch, fake := newSignal(t, ``)
+	require.NoError(t, ch.SendNotification(plugintest.NewNotificationRequest(withNumber)))
+
+	require.Len(t, fake.messages, 1)
+	assert.Equal(t, []any{"+4915112345678"}, fake.messages[0]["recipients"])
+	assert.True(t, strings.HasPrefix(fake.messages[0]["message"].(string), "🚨 [#42] state web01!http is crit\n\n"))
+	assert.Contains(t, fake.messages[0]["message"], "connection refused")
+
+	t.Run("Split", func(t *testing.T) {
+		ch, fake := newSignal(t, `, "max_length": "200"`)
+		output := strings.Repeat("CRITICAL - disk /var has 1% free space left\n", 10)
+		req := plugintest.NewNotificationRequest(withNumber, plugintest.WithMessage(output))
+		require.NoError(t, ch.SendNotification(req))
+
+		require.Greater(t, len(fake.messages), 2)
+		var joined []string
+		for i, m := range fake.messages {
+			message := m["message"].(string)
+			assert.LessOrEqual(t, len([]rune(message)), 200, "message %d", i)
+			assert.True(t, strings.HasPrefix(message, "("), "message %d should be numbered", i)
+			joined = append(joined, message[strings.Index(message, ") ")+2:])
+		}
+		assert.Equal(t, strings.Count(output, "\n"), strings.Count(strings.Join(joined, "\n"), "CRITICAL - disk"),
+			"no line should be lost or split")
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		ch, _ := newSignal(t, ``)
+		ch.Number = "+4915199999999"
+		assert.ErrorContains(t, ch.SendNotification(plugintest.NewNotificationRequest(withNumber)),
+			"400 Bad Request: User +4915199999999 is not registered")
+		assert.ErrorContains(t, ch.HealthCheck(), "number +4915199999999 is not registered")
+
+		req := plugintest.NewNotificationRequest(withNumber)
+		req.Contact.Addresses = nil
+		assert.ErrorContains(t, ch.SendNotification(req), "does not specify a signal phone number")
+	})
+}
+
+func TestSignal_HealthCheck(t *testing.T) {
+	ch, _ := newSignal(t, ``)
+	assert.NoError(t, ch.HealthCheck())
+}
+
+func TestSeverityEmoji(t *testing.T) {
+	for _, test := range []struct {
+		severity, eventType string
+		want                string
+	}{
+		{"emerg", "state", "🚨"},
+		{"crit", "incident-age", "🚨"},
+		{"err", "state", "❗"},
+		{"warning", "state", "⚠️"},
+		{"notice", "state", "ℹ️"},
+		{"ok", "state", "✅"},
+		{"crit", "acknowledgement-set", "👤"},
+		{"crit", "downtime-start", "🔕"},
+		{"crit", "custom", "💬"},
+	} {
+		req := plugintest.NewNotificationRequest(withNumber,
+			plugintest.WithSeverity(test.severity), plugintest.WithEventType(test.eventType))
+		assert.Equal(t, test.want, severityEmoji(req), "%s %s", test.severity, test.eventType)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	assert.Equal(t, []string{"short message"}, split("short message", 100))
+
+	long := strings.Repeat("a", 150)
+	parts := split(long, 100)
+	assert.Equal(t, long, strings.Join(parts, ""), "words longer than a part should be split anywhere")
+	for _, part := range parts {
+		assert.LessOrEqual(t, len(part), 92)
+	}
+
+	assert.Equal(t, []string{strings.Repeat("ä ", 45) + "ä", strings.Repeat("ö", 10)},
+		split(strings.Repeat("ä ", 46)+strings.Repeat("ö", 10), 100), "parts should end at the last space")
+}
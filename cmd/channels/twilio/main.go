@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AddressType is the contact address type holding the phone number to be called in E.164 format, e.g., "+4915112345678".
+const AddressType = "phone"
+
+// apiURL is the base URL of the Twilio API.
+const apiURL = "https://api.twilio.com"
+
+// maxSpokenLength limits the characters of the event's message read out, as long plugin outputs are hard to follow.
+const maxSpokenLength = 300
+
+func main() {
+	plugin.RunPlugin(&Twilio{})
+}
+
+// Twilio notifies contacts by a voice call placed via Twilio, reading out the incident by text-to-speech.
+//
+// If the notification contains an acknowledgement link, the callee is asked to press 1 to acknowledge the incident.
+// The pressed digit is posted by Twilio to the listener's acknowledgement endpoint for voice calls, which acknowledges
+// the incident on behalf of the contact. Thus, the listener must be reachable by Twilio at the links' base URL.
+//
+// As calling for every event would be rather annoying, only state changes and renotifications of incidents of at least
+// MinSeverity are called for, while all other notifications are skipped.
+type Twilio struct {
+	AccountSID  string `json:"account_sid"`
+	AuthToken   string `json:"auth_token"`
+	From        string `json:"from"`
+	Language    string `json:"language"`
+	MinSeverity string `json:"min_severity"`
+
+	minSeverity event.Severity
+	apiURL      string
+	client      *http.Client
+}
+
+func (ch *Twilio) GetInfo() *plugin.Info {
+	configAttrs := plugin.ConfigOptions{
+		{
+			Name: "account_sid",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Account SID",
+				"de_DE": "Konto-SID",
+			},
+			Help: map[string]string{
+				"en_US": "SID of the Twilio account placing the calls, starting with AC.",
+				"de_DE": "SID des Twilio-Kontos, über das die Anrufe getätigt werden, beginnend mit AC.",
+			},
+			Required: true,
+		},
+		{
+			Name: "auth_token",
+			Type: "secret",
+			Label: map[string]string{
+				"en_US": "Auth Token",
+				"de_DE": "Auth-Token",
+			},
+			Help: map[string]string{
+				"en_US": "Auth token of the Twilio account.",
+				"de_DE": "Auth-Token des Twilio-Kontos.",
+			},
+			Required: true,
+		},
+		{
+			Name: "from",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Caller Number",
+				"de_DE": "Anrufernummer",
+			},
+			Help: map[string]string{
+				"en_US": "Twilio phone number the calls are placed from, e.g., +4915112345678.",
+				"de_DE": "Twilio-Telefonnummer, von der aus angerufen wird, z.B. +4915112345678.",
+			},
+			Required: true,
+		},
+		{
+			Name: "language",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Language",
+				"de_DE": "Sprache",
+			},
+			Help: map[string]string{
+				"en_US": "Language of the text-to-speech voice, e.g., en-US or de-DE.",
+				"de_DE": "Sprache der Text-to-Speech-Stimme, z.B. en-US oder de-DE.",
+			},
+			Default: "en-US",
+		},
+		{
+			Name: "min_severity",
+			Type: "option",
+			Label: map[string]string{
+				"en_US": "Minimum Severity",
+				"de_DE": "Mindestschweregrad",
+			},
+			Help: map[string]string{
+				"en_US": "Only incidents of at least this severity are called for, all other notifications are skipped.",
+				"de_DE": "Nur bei Vorfällen mit mindestens diesem Schweregrad wird angerufen, alle anderen Benachrichtigungen werden übersprungen.",
+			},
+			Options: map[string]string{
+				"warning": "Warning",
+				"err":     "Error",
+				"crit":    "Critical",
+				"alert":   "Alert",
+				"emerg":   "Emergency",
+			},
+			Default: "crit",
+		},
+	}
+
+	return &plugin.Info{
+		Name:             "Twilio Voice",
+		Version:          internal.Version.Version,
+		Author:           "Icinga GmbH",
+		ConfigAttributes: configAttrs,
+	}
+}
+
+func (ch *Twilio) SetConfig(jsonStr json.RawMessage) error {
+	err := plugin.PopulateDefaults(ch)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(jsonStr, ch)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(ch.AccountSID, "AC") {
+		return fmt.Errorf("account_sid must start with AC, got %q", ch.AccountSID)
+	}
+	if ch.AuthToken == "" {
+		return errors.New("auth_token must not be empty")
+	}
+	if ch.From == "" {
+		return errors.New("from must not be empty")
+	}
+	if ch.minSeverity, err = event.GetSeverityByName(ch.MinSeverity); err != nil {
+		return fmt.Errorf("min_severity: %w", err)
+	}
+
+	if ch.apiURL == "" {
+		ch.apiURL = apiURL
+	}
+	ch.client = &http.Client{Timeout: 30 * time.Second}
+
+	return nil
+}
+
+// HealthCheck implements the plugin.HealthChecker interface by verifying that the Twilio account is active.
+func (ch *Twilio) HealthCheck() error {
+	var account struct {
+		Status string `json:"status"`
+	}
+	if err := ch.do(http.MethodGet, ".json", nil, &account); err != nil {
+		return err
+	}
+	if account.Status != "active" {
+		return fmt.Errorf("twilio account is %s", account.Status)
+	}
+
+	return nil
+}
+
+func (ch *Twilio) SendNotification(req *plugin.NotificationRequest) error {
+	if req.Event.Type != event.TypeState && req.Event.Type != event.TypeIncidentAge {
+		return nil
+	}
+	if severity, err := event.GetSeverityByName(req.Incident.Severity); err != nil || severity < ch.minSeverity {
+		return nil
+	}
+
+	var to string
+	for _, address := range req.Contact.Addresses {
+		if address.Type == AddressType {
+			to = address.Address
+			break
+		}
+	}
+
+	if to == "" {
+		return fmt.Errorf("contact user %s does not specify a phone number", req.Contact.FullName)
+	}
+
+	twiml, err := ch.twiml(req)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{"To": {to}, "From": {ch.From}, "Twiml": {twiml}}
+
+	return ch.do(http.MethodPost, "/Calls.json", form, nil)
+}
+
+// twiml returns the TwiML instructions of the call, reading out the incident twice.
+//
+// With an acknowledgement link, the callee may press 1 at any time to acknowledge the incident, being posted to the
+// acknowledgement endpoint for voice calls, see acklink.VoicePath.
+func (ch *Twilio) twiml(req *plugin.NotificationRequest) (string, error) {
+	text := spoken(req)
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><Response>`)
+
+	say := func(text string, loop int) {
+		_, _ = fmt.Fprintf(&b, `<Say language="%s" loop="%d">`, escape(ch.Language), loop)
+		b.WriteString(escape(text))
+		b.WriteString(`</Say>`)
+	}
+
+	if req.AcknowledgeUrl == "" {
+		say(text, 2)
+	} else {
+		action, err := url.Parse(req.AcknowledgeUrl)
+		if err != nil {
+			return "", fmt.Errorf("cannot parse acknowledgement link: %w", err)
+		}
+		action = action.JoinPath("voice")
+
+		_, _ = fmt.Fprintf(&b, `<Gather numDigits="1" action="%s" method="POST" timeout="10">`, escape(action.String()))
+		say(text+" Press 1 to acknowledge the incident.", 2)
+		b.WriteString(`</Gather>`)
+		say("No input received. Goodbye.", 1)
+	}
+
+	b.WriteString(`</Response>`)
+
+	return b.String(), nil
+}
+
+// spoken returns the text read out for the notification, i.e., its subject and the beginning of the event's message.
+func spoken(req *plugin.NotificationRequest) string {
+	text := "This is Icinga Notifications. " + plugin.FormatSubject(req) + "."
+	if message := strings.Join(strings.Fields(req.Event.Message), " "); message != "" {
+		if r := []rune(message); len(r) > maxSpokenLength {
+			message = string(r[:maxSpokenLength]) + "…"
+		}
+		text += " " + message
+	}
+
+	return text
+}
+
+// escape the string for use in XML text and attribute values.
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+
+	return b.String()
+}
+
+// do sends a request with the form to the Twilio API of the account and parses the JSON response into result, if not
+// nil, returning the errors reported by the API.
+func (ch *Twilio) do(method, path string, form url.Values, result any) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	request, err := http.NewRequest(method, ch.apiURL+"/2010-04-01/Accounts/"+url.PathEscape(ch.AccountSID)+path, body)
+	if err != nil {
+		return err
+	}
+
+	request.SetBasicAuth(ch.AccountSID, ch.AuthToken)
+	if form != nil {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := ch.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error while sending http request to twilio: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&apiErr); err == nil && apiErr.Message != "" {
+			return fmt.Errorf("%s: %s (code %d)", resp.Status, apiErr.Message, apiErr.Code)
+		}
+		return errors.New(resp.Status)
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(result); err != nil {
+			return fmt.Errorf("cannot parse twilio response: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/icinga/icinga-notifications/internal/testutils/plugintest"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// fakeTwilio records the requests sent to it and responds like the Twilio API for the auth token "secret".
+type fakeTwilio struct {
+	requests []string
+	forms    []url.Values
+}
+
+func (f *fakeTwilio) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	f.forms = append(f.forms, r.PostForm)
+
+	if _, token, _ := r.BasicAuth(); token != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"code": 20003, "message": "Authenticate", "status": 401}`))
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		_, _ = w.Write([]byte(`{"sid": "AC123", "status": "active"}`))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte(`{"sid": "CA123", "status": "queued"}`))
+}
+
+func newTwilio(t *testing.T, config string) (*Twilio, *fakeTwilio) {
+	fake := &fakeTwilio{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	ch := &Twilio{apiURL: server.URL}
+	require.NoError(t, ch.SetConfig(json.RawMessage(`{"account_sid": "AC123", "auth_token": "secret", "from": "+15005550006"`+config+`}`)))
+
+	return ch, fake
+}
+
+// withPhone adds the phone number called to the requests, see plugintest.NewNotificationRequest.
+var withPhone = plugintest.WithAddress("phone", "+4915112345678")
+
+func TestTwilio_SetConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"Defaults", `{"account_sid": "AC123", "auth_token": "secret", "from": "+15005550006"}`, ""},
+		{"InvalidAccountSID", `{"account_sid": "123", "auth_token": "secret", "from": "+15005550006"}`, "account_sid must start with AC"},
+		{"MissingFrom", `{"account_sid": "AC123", "auth_token": "secret"}`, "from must not be empty"},
+		{"InvalidSeverity", `{"account_sid": "AC123", "auth_token": "secret", "from": "+15005550006", "min_severity": "bad"}`, "min_severity"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := (&Twilio{}).SetConfig(json.RawMessage(test.config))
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestTwilio_SendNotification(t *testing.T) {
+	// twiml is the structure of the calls' instructions relevant to the tests.
+	type twiml struct {
+		Gather *struct {
+			Action string `xml:"action,attr"`
+			Say    string `xml:"Say"`
+		} `xml:"Gather"`
+		Say []string `xml:"Say"`
+	}
+
+	t.Run("Acknowledgeable", func(t *testing.T) {
+		ch, fake := newTwilio(t, `, "language": "de-DE"`)
+		require.NoError(t, ch.SendNotification(plugintest.NewNotificationRequest(withPhone,
+			plugintest.WithMessage("CRITICAL - Socket timeout\n<html> & more"),
+			plugintest.WithAcknowledgeUrl("https://icinga.example.com/notifications/acknowledge?token=abc.def"))))
+
+		require.Equal(t, []string{"POST /2010-04-01/Accounts/AC123/Calls.json"}, fake.requests)
+		form := fake.forms[0]
+		assert.Equal(t, "+4915112345678", form.Get("To"))
+		assert.Equal(t, "+15005550006", form.Get("From"))
+		assert.Contains(t, form.Get("Twiml"), `language="de-DE"`)
+
+		var response twiml
+		require.NoError(t, xml.Unmarshal([]byte(form.Get("Twiml")), &response), "TwiML should be valid XML")
+		require.NotNil(t, response.Gather)
+		assert.Equal(t, "https://icinga.example.com/notifications/acknowledge/voice?token=abc.def", response.Gather.Action)
+		assert.Equal(t, "This is Icinga Notifications. [#42] state web01!http is crit. "+
+			"CRITICAL - Socket timeout <html> & more Press 1 to acknowledge the incident.", response.Gather.Say)
+		assert.Equal(t, []string{"No input received. Goodbye."}, response.Say)
+	})
+
+	t.Run("WithoutAcknowledgeUrl", func(t *testing.T) {
+		ch, fake := newTwilio(t, ``)
+		req := plugintest.NewNotificationRequest(withPhone,
+			plugintest.WithSeverity("emerg"), plugintest.WithEventType("incident-age"))
+		require.NoError(t, ch.SendNotification(req))
+
+		var response twiml
+		require.NoError(t, xml.Unmarshal([]byte(fake.forms[0].Get("Twiml")), &response))
+		assert.Nil(t, response.Gather, "without a link, there is nothing to acknowledge")
+		require.Len(t, response.Say, 1)
+		assert.Contains(t, response.Say[0], "[#42] incident-age on web01!http")
+	})
+
+	t.Run("Skipped", func(t *testing.T) {
+		for _, req := range []*plugin.NotificationRequest{
+			plugintest.NewNotificationRequest(withPhone, plugintest.WithSeverity("warning")),
+			plugintest.NewNotificationRequest(withPhone, plugintest.WithSeverity("ok")),
+			plugintest.NewNotificationRequest(withPhone, plugintest.WithEventType("acknowledgement-set")),
+		} {
+			ch, fake := newTwilio(t, ``)
+			require.NoError(t, ch.SendNotification(req))
+			assert.Empty(t, fake.requests, "%s %s should not be called for", req.Incident.Severity, req.Event.Type)
+		}
+
+		ch, fake := newTwilio(t, `, "min_severity": "warning"`)
+		req := plugintest.NewNotificationRequest(withPhone, plugintest.WithSeverity("warning"))
+		require.NoError(t, ch.SendNotification(req))
+		assert.Len(t, fake.requests, 1, "warning should be called for with min_severity warning")
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		ch, _ := newTwilio(t, ``)
+		require.NoError(t, ch.HealthCheck())
+
+		ch.AuthToken = "wrong"
+		assert.ErrorContains(t, ch.SendNotification(plugintest.NewNotificationRequest(withPhone)),
+			"401 Unauthorized: Authenticate (code 20003)")
+		assert.ErrorContains(t, ch.HealthCheck(), "Authenticate")
+
+		req := plugintest.NewNotificationRequest(withPhone)
+		req.Contact.Addresses = nil
+		assert.ErrorContains(t, ch.SendNotification(req), "does not specify a phone number")
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/icinga/icinga-notifications/pkg/webhookverify"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	AuthNone   = "none"
+	AuthOAuth2 = "oauth2"
+	AuthHMAC   = "hmac"
+)
+
+// authenticate adds the configured authentication to the request about to send the body.
+func (ch *Webhook) authenticate(httpReq *http.Request, body []byte) error {
+	switch ch.Auth {
+	case AuthOAuth2:
+		token, err := ch.tokens.Token(httpReq.Context())
+		if err != nil {
+			return err
+		}
+
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	case AuthHMAC:
+		now := time.Now()
+		// The signature is verifiable by receivers using the webhookverify package, allowing them to reject replayed
+		// requests based on the timestamp.
+		httpReq.Header.Set(webhookverify.TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+		httpReq.Header.Set(ch.HMACHeader, webhookverify.Sign(ch.HMACSecret, now, body))
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"github.com/icinga/icinga-notifications/pkg/webhookverify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func makeRequest() *plugin.NotificationRequest {
+	return &plugin.NotificationRequest{
+		Contact:  &plugin.Contact{FullName: "Jane Doe"},
+		Object:   &plugin.Object{Name: "db-01", Tags: map[string]string{"host": "db-01"}},
+		Incident: &plugin.Incident{Id: 42, Severity: "crit"},
+		Event:    &plugin.Event{Time: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), Type: "state", TraceID: "trace"},
+	}
+}
+
+func TestWebhook_SetConfig_Auth(t *testing.T) {
+	for name, config := range map[string]string{
+		"UnknownMode":        `{"url_template":"http://localhost","auth":"basic"}`,
+		"OAuth2MissingField": `{"url_template":"http://localhost","auth":"oauth2","oauth2_token_url":"https://localhost"}`,
+		"OAuth2Insecure": `{"url_template":"http://localhost","auth":"oauth2","oauth2_token_url":"http://localhost",` +
+			`"oauth2_client_id":"id","oauth2_client_secret":"secret"}`,
+		"HMACMissingSecret": `{"url_template":"http://localhost","auth":"hmac"}`,
+	} {
+		assert.Error(t, (&Webhook{}).SetConfig(json.RawMessage(config)), name)
+	}
+
+	webhook := &Webhook{}
+	require.NoError(t, webhook.SetConfig(json.RawMessage(`{"url_template":"http://localhost"}`)))
+	assert.Equal(t, AuthNone, webhook.Auth)
+	assert.Nil(t, webhook.tokens)
+}
+
+func TestWebhook_SendNotification_HMAC(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		timestamp, err := strconv.ParseInt(r.Header.Get(webhookverify.TimestampHeader), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, webhookverify.Sign("secret", time.Unix(timestamp, 0), body), r.Header.Get("X-Signature"))
+		assert.Equal(t, "trace", r.Header.Get(plugin.TraceIDHeader))
+
+		// Contract with receivers verifying the requests by the webhookverify package.
+		verifier := &webhookverify.Verifier{Secret: "secret", SignatureHeader: "X-Signature"}
+		assert.NoError(t, verifier.Verify(r.Header, body))
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{}
+	require.NoError(t, webhook.SetConfig(json.RawMessage(`{"url_template":"`+server.URL+`",`+
+		`"auth":"hmac","hmac_secret":"secret","hmac_header":"X-Signature"}`)))
+	require.NoError(t, webhook.SendNotification(makeRequest()))
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{"id":42}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=80c06ec4776489890b4062e2492ef6eb936a6bf5c2be7e1d8652aeda8cb49aa1",
+		webhookverify.Sign("secret", time.Unix(1700000000, 0), []byte(`{"id":42}`)))
+}
+
+func TestWebhook_SendNotification_OAuth2(t *testing.T) {
+	var tokens atomic.Int64
+	tokenServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": 3600}`, tokens.Add(1))
+	}))
+	defer tokenServer.Close()
+
+	var revoked atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if revoked.Load() && r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		assert.Contains(t, r.Header.Get("Authorization"), "Bearer token-")
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{}
+	require.NoError(t, webhook.SetConfig(json.RawMessage(`{"url_template":"`+server.URL+`","auth":"oauth2",`+
+		`"oauth2_token_url":"`+tokenServer.URL+`","oauth2_client_id":"id","oauth2_client_secret":"secret"}`)))
+	webhook.tokens.Client = tokenServer.Client()
+
+	require.NoError(t, webhook.SendNotification(makeRequest()))
+	require.NoError(t, webhook.SendNotification(makeRequest()))
+	assert.Equal(t, int64(1), tokens.Load(), "token should be reused")
+
+	revoked.Store(true)
+	require.NoError(t, webhook.SendNotification(makeRequest()), "revoked token should be replaced")
+	assert.Equal(t, int64(2), tokens.Load())
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+)
+
+// maxResponseSize limits the part of a response body being read, e.g., to be provided to the follow-up templates.
+const maxResponseSize = 1 << 20
+
+// followUpData is passed to the follow-up templates, extending the current plugin.NotificationRequest by the
+// JSON-decoded body of the first response, e.g., the ID of a created ticket or the token of a login call.
+type followUpData struct {
+	*plugin.NotificationRequest
+	Response any `json:"response"`
+}
+
+// followUp sends the follow-up request using the body of the first response.
+func (ch *Webhook) followUp(req *plugin.NotificationRequest, respBody []byte) error {
+	data := followUpData{NotificationRequest: req}
+	if len(bytes.TrimSpace(respBody)) > 0 {
+		if err := json.Unmarshal(respBody, &data.Response); err != nil {
+			return fmt.Errorf("cannot parse response of the first request as JSON: %w", err)
+		}
+	}
+
+	var urlBuff, reqBodyBuff bytes.Buffer
+	if err := ch.tmplFollowUpUrl.Execute(&urlBuff, data); err != nil {
+		return fmt.Errorf("cannot execute Follow-up URL template: %w", err)
+	}
+	if err := ch.tmplFollowUpRequestBody.Execute(&reqBodyBuff, data); err != nil {
+		return fmt.Errorf("cannot execute Follow-up Request Body template: %w", err)
+	}
+
+	_, err := ch.request(ch.FollowUpMethod, urlBuff.String(), reqBodyBuff.Bytes(), ch.followUpRespStatusCodes,
+		req.Event.TraceID)
+	if err != nil {
+		return fmt.Errorf("follow-up request failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhook_SendNotification_FollowUp(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+
+		switch r.URL.Path {
+		case "/tickets":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = io.WriteString(w, `{"ticket": {"id": 7}}`)
+		case "/invalid":
+			_, _ = io.WriteString(w, `<html></html>`)
+		}
+	}))
+	defer server.Close()
+
+	config := func(url, followUpURL string) json.RawMessage {
+		return json.RawMessage(`{"url_template":"` + server.URL + url + `","response_status_codes":"200,201",` +
+			`"request_body_template":"{{.Incident.Id}}","follow_up_method":"PUT",` +
+			`"follow_up_url_template":"` + server.URL + followUpURL + `",` +
+			`"follow_up_request_body_template":"{{.Object.Name}} is {{.Incident.Severity}}"}`)
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		requests = nil
+		webhook := &Webhook{}
+		require.NoError(t, webhook.SetConfig(config("/tickets", "/tickets/{{.Response.ticket.id}}")))
+		require.NoError(t, webhook.SendNotification(makeRequest()))
+		assert.Equal(t, []string{"POST /tickets 42", "PUT /tickets/7 db-01 is crit"}, requests)
+	})
+
+	t.Run("MissingValue", func(t *testing.T) {
+		requests = nil
+		webhook := &Webhook{}
+		require.NoError(t, webhook.SetConfig(config("/tickets", "/tickets/{{.Response.ticket.key}}")))
+		assert.Error(t, webhook.SendNotification(makeRequest()))
+		assert.Len(t, requests, 1, "no follow-up request should be sent")
+	})
+
+	t.Run("InvalidResponse", func(t *testing.T) {
+		requests = nil
+		webhook := &Webhook{}
+		require.NoError(t, webhook.SetConfig(config("/invalid", "/tickets/{{.Response.ticket.id}}")))
+		assert.Error(t, webhook.SendNotification(makeRequest()))
+		assert.Len(t, requests, 1, "no follow-up request should be sent")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		requests = nil
+		webhook := &Webhook{}
+		require.NoError(t, webhook.SetConfig(json.RawMessage(`{"url_template":"`+server.URL+`/invalid"}`)))
+		require.NoError(t, webhook.SendNotification(makeRequest()))
+		assert.Len(t, requests, 1)
+	})
+}
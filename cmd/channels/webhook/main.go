@@ -2,16 +2,24 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"github.com/icinga/icinga-go-library/types"
 	"github.com/icinga/icinga-notifications/internal"
+	"github.com/icinga/icinga-notifications/internal/oauth2"
 	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"github.com/icinga/icinga-notifications/pkg/webhookverify"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"slices"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 )
 
 func main() {
@@ -24,10 +32,44 @@ type Webhook struct {
 	RequestBodyTemplate string `json:"request_body_template"`
 	ResponseStatusCodes string `json:"response_status_codes"`
 
+	// MaxAttempts limits how often a request is sent if it fails with a network error or a retryable status code,
+	// waiting according to Backoff, starting with BackoffDelay seconds, in between. Timeout limits each attempt.
+	MaxAttempts  string `json:"max_attempts"`
+	Backoff      string `json:"backoff"`
+	BackoffDelay string `json:"backoff_delay"`
+	Timeout      string `json:"timeout"`
+	maxAttempts  int
+	backoffDelay time.Duration
+	client       *http.Client
+	// sleep waits between two attempts, being replaced in tests.
+	sleep func(time.Duration)
+
+	// Auth is the authentication mode, one of AuthNone, AuthOAuth2 or AuthHMAC. For AuthOAuth2, an access token is
+	// fetched from OAuth2TokenURL by tokens and sent as a bearer token. For AuthHMAC, the request is signed by HMACSecret.
+	Auth               string `json:"auth"`
+	OAuth2TokenURL     string `json:"oauth2_token_url"`
+	OAuth2ClientID     string `json:"oauth2_client_id"`
+	OAuth2ClientSecret string `json:"oauth2_client_secret"`
+	OAuth2Scope        string `json:"oauth2_scope"`
+	HMACSecret         string `json:"hmac_secret"`
+	HMACHeader         string `json:"hmac_header"`
+	tokens             *oauth2.ClientCredentials
+
+	// FollowUpURLTemplate enables a second request, sent after the first one succeeded. Its templates are applied to
+	// followUpData, providing the JSON-decoded body of the first response.
+	FollowUpMethod              string `json:"follow_up_method"`
+	FollowUpURLTemplate         string `json:"follow_up_url_template"`
+	FollowUpRequestBodyTemplate string `json:"follow_up_request_body_template"`
+	FollowUpResponseStatusCodes string `json:"follow_up_response_status_codes"`
+
 	tmplUrl         *template.Template
 	tmplRequestBody *template.Template
 
-	respStatusCodes []int
+	tmplFollowUpUrl         *template.Template
+	tmplFollowUpRequestBody *template.Template
+
+	respStatusCodes         []int
+	followUpRespStatusCodes []int
 }
 
 func (ch *Webhook) GetInfo() *plugin.Info {
@@ -86,6 +128,203 @@ func (ch *Webhook) GetInfo() *plugin.Info {
 			Default:  "200",
 			Required: true,
 		},
+		{
+			Name: "max_attempts",
+			Type: "number",
+			Label: map[string]string{
+				"en_US": "Maximum Attempts",
+				"de_DE": "Maximale Versuche",
+			},
+			Help: map[string]string{
+				"en_US": "How often a request is sent at most if it fails due to a network error, a timeout or the HTTP status code 408, 429 or 5xx.",
+				"de_DE": "Wie oft eine Anfrage höchstens gesendet wird, falls sie aufgrund eines Netzwerkfehlers, einer Zeitüberschreitung oder des HTTP-Status-Codes 408, 429 oder 5xx fehlschlägt.",
+			},
+			Default: "3",
+			Min:     types.Int{NullInt64: sql.NullInt64{Int64: 1, Valid: true}},
+			Max:     types.Int{NullInt64: sql.NullInt64{Int64: 10, Valid: true}},
+		},
+		{
+			Name: "backoff",
+			Type: "option",
+			Label: map[string]string{
+				"en_US": "Backoff",
+				"de_DE": "Wartestrategie",
+			},
+			Help: map[string]string{
+				"en_US": "How the delay between two attempts evolves. A Retry-After header of a 429 or 503 response takes precedence. Delays are capped at one minute.",
+				"de_DE": "Wie sich die Wartezeit zwischen zwei Versuchen entwickelt. Ein Retry-After-Header einer 429- oder 503-Antwort hat Vorrang. Wartezeiten sind auf eine Minute begrenzt.",
+			},
+			Options: map[string]string{
+				BackoffExponential: "Exponential",
+				BackoffConstant:    "Constant",
+			},
+			Default: BackoffExponential,
+		},
+		{
+			Name: "backoff_delay",
+			Type: "number",
+			Label: map[string]string{
+				"en_US": "Backoff Delay",
+				"de_DE": "Wartezeit",
+			},
+			Help: map[string]string{
+				"en_US": "Delay in seconds before the first retry, doubled for each further retry with an exponential backoff.",
+				"de_DE": "Wartezeit in Sekunden vor dem ersten erneuten Versuch, bei exponentieller Wartestrategie für jeden weiteren Versuch verdoppelt.",
+			},
+			Default: "1",
+			Min:     types.Int{NullInt64: sql.NullInt64{Int64: 0, Valid: true}},
+			Max:     types.Int{NullInt64: sql.NullInt64{Int64: 60, Valid: true}},
+		},
+		{
+			Name: "timeout",
+			Type: "number",
+			Label: map[string]string{
+				"en_US": "Timeout",
+				"de_DE": "Zeitüberschreitung",
+			},
+			Help: map[string]string{
+				"en_US": "Timeout in seconds for each attempt, including reading the response.",
+				"de_DE": "Zeitüberschreitung in Sekunden für jeden Versuch, einschließlich des Lesens der Antwort.",
+			},
+			Default: "30",
+			Min:     types.Int{NullInt64: sql.NullInt64{Int64: 1, Valid: true}},
+		},
+		{
+			Name: "auth",
+			Type: "option",
+			Label: map[string]string{
+				"en_US": "Authentication",
+				"de_DE": "Authentifizierung",
+			},
+			Help: map[string]string{
+				"en_US": "OAuth 2.0 fetches an access token using the client credentials flow and sends it as a bearer token. HMAC signs the request body with a shared secret.",
+				"de_DE": "OAuth 2.0 ruft ein Zugriffstoken über den Client-Credentials-Flow ab und sendet es als Bearer-Token. HMAC signiert die Anfragedaten mit einem gemeinsamen Geheimnis.",
+			},
+			Options: map[string]string{
+				AuthNone:   "None",
+				AuthOAuth2: "OAuth 2.0 Client Credentials",
+				AuthHMAC:   "HMAC-SHA256 Signature",
+			},
+			Default: AuthNone,
+		},
+		{
+			Name: "oauth2_token_url",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "OAuth 2.0 Token URL",
+				"de_DE": "OAuth 2.0 Token-URL",
+			},
+			Help: map[string]string{
+				"en_US": "Required for OAuth 2.0, the token endpoint of the authorization server.",
+				"de_DE": "Erforderlich für OAuth 2.0, der Token-Endpunkt des Autorisierungsservers.",
+			},
+		},
+		{
+			Name: "oauth2_client_id",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "OAuth 2.0 Client ID",
+				"de_DE": "OAuth 2.0 Client-ID",
+			},
+		},
+		{
+			Name: "oauth2_client_secret",
+			Type: "secret",
+			Label: map[string]string{
+				"en_US": "OAuth 2.0 Client Secret",
+				"de_DE": "OAuth 2.0 Client-Secret",
+			},
+		},
+		{
+			Name: "oauth2_scope",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "OAuth 2.0 Scope",
+				"de_DE": "OAuth 2.0 Scope",
+			},
+			Help: map[string]string{
+				"en_US": "Space-separated scopes to request, if required by the authorization server.",
+				"de_DE": "Leerzeichengetrennte anzufordernde Scopes, falls vom Autorisierungsserver verlangt.",
+			},
+		},
+		{
+			Name: "hmac_secret",
+			Type: "secret",
+			Label: map[string]string{
+				"en_US": "HMAC Secret",
+				"de_DE": "HMAC-Geheimnis",
+			},
+			Help: map[string]string{
+				"en_US": "Required for HMAC, the secret shared with the receiver to sign requests with.",
+				"de_DE": "Erforderlich für HMAC, das mit dem Empfänger geteilte Geheimnis zum Signieren der Anfragen.",
+			},
+		},
+		{
+			Name: "hmac_header",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "HMAC Header",
+				"de_DE": "HMAC-Header",
+			},
+			Help: map[string]string{
+				"en_US": "HTTP header carrying the signature as sha256=<hex> over the Unix timestamp of the X-Icinga-Notifications-Timestamp header, a dot and the request body.",
+				"de_DE": "HTTP-Header mit der Signatur als sha256=<hex> über den Unix-Zeitstempel des X-Icinga-Notifications-Timestamp-Headers, einen Punkt und die Anfragedaten.",
+			},
+			Default: webhookverify.DefaultSignatureHeader,
+		},
+		{
+			Name: "follow_up_method",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Follow-up HTTP Method",
+				"de_DE": "Folge-HTTP-Methode",
+			},
+			Help: map[string]string{
+				"en_US": "HTTP request method used for the follow-up request.",
+				"de_DE": "HTTP-Methode für die Folgeanfrage.",
+			},
+			Default: "POST",
+		},
+		{
+			Name: "follow_up_url_template",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Follow-up URL Template",
+				"de_DE": "Folge-URL-Template",
+			},
+			Help: map[string]string{
+				"en_US": "If set, a follow-up request is sent to this URL after the first request succeeded, e.g., to update a created ticket. " +
+					"All follow-up templates may access the JSON-decoded body of the first response as .Response, e.g., {{.Response.id}}.",
+				"de_DE": "Falls gesetzt, wird nach erfolgreicher erster Anfrage eine Folgeanfrage an diese URL gesendet, z.B. um ein erstelltes Ticket zu aktualisieren. " +
+					"Alle Folge-Templates können auf die JSON-dekodierten Antwortdaten der ersten Anfrage als .Response zugreifen, z.B. {{.Response.id}}.",
+			},
+		},
+		{
+			Name: "follow_up_request_body_template",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Follow-up Request Body Template",
+				"de_DE": "Folge-Anfragedaten-Template",
+			},
+			Help: map[string]string{
+				"en_US": "Go template applied to the current plugin.NotificationRequest and the first response to create the follow-up request body.",
+				"de_DE": "Go-Template über das zu verarbeitende plugin.NotificationRequest und die erste Antwort zum Erzeugen der Folge-Anfragedaten.",
+			},
+			Default: "{{json .}}",
+		},
+		{
+			Name: "follow_up_response_status_codes",
+			Type: "string",
+			Label: map[string]string{
+				"en_US": "Follow-up Response Status Codes",
+				"de_DE": "Folge-Antwort-Status-Codes",
+			},
+			Help: map[string]string{
+				"en_US": "Comma separated list of expected HTTP response status code of the follow-up request.",
+				"de_DE": "Kommaseparierte Liste erwarteter Status-Code der HTTP-Antwort der Folgeanfrage.",
+			},
+			Default: "200",
+		},
 	}
 
 	return &plugin.Info{
@@ -128,14 +367,75 @@ func (ch *Webhook) SetConfig(jsonStr json.RawMessage) error {
 		return fmt.Errorf("cannot parse Request Body template: %w", err)
 	}
 
-	respStatusCodes := strings.Split(ch.ResponseStatusCodes, ",")
-	ch.respStatusCodes = make([]int, len(respStatusCodes))
-	for i, respStatusCodeStr := range respStatusCodes {
-		respStatusCode, err := strconv.Atoi(respStatusCodeStr)
+	ch.respStatusCodes, err = parseStatusCodes(ch.ResponseStatusCodes)
+	if err != nil {
+		return err
+	}
+
+	ch.tmplFollowUpUrl, ch.tmplFollowUpRequestBody = nil, nil
+	if ch.FollowUpURLTemplate != "" {
+		// A value missing in the response would otherwise be rendered as "<no value>".
+		ch.tmplFollowUpUrl, err = template.New("follow_up_url").Funcs(tmplFuncs).Option("missingkey=error").
+			Parse(ch.FollowUpURLTemplate)
+		if err != nil {
+			return fmt.Errorf("cannot parse Follow-up URL template: %w", err)
+		}
+
+		ch.tmplFollowUpRequestBody, err = template.New("follow_up_request_body").Funcs(tmplFuncs).
+			Option("missingkey=error").Parse(ch.FollowUpRequestBodyTemplate)
 		if err != nil {
-			return fmt.Errorf("cannot convert status code %q to int: %w", respStatusCodeStr, err)
+			return fmt.Errorf("cannot parse Follow-up Request Body template: %w", err)
+		}
+
+		ch.followUpRespStatusCodes, err = parseStatusCodes(ch.FollowUpResponseStatusCodes)
+		if err != nil {
+			return err
 		}
-		ch.respStatusCodes[i] = respStatusCode
+	}
+
+	if ch.maxAttempts, err = strconv.Atoi(ch.MaxAttempts); err != nil || ch.maxAttempts < 1 {
+		return fmt.Errorf("max_attempts must be a positive integer, got %q", ch.MaxAttempts)
+	}
+	if ch.Backoff != BackoffExponential && ch.Backoff != BackoffConstant {
+		return fmt.Errorf("unsupported backoff %q", ch.Backoff)
+	}
+	backoffDelay, err := strconv.Atoi(ch.BackoffDelay)
+	if err != nil || backoffDelay < 0 {
+		return fmt.Errorf("backoff_delay must be a non-negative integer, got %q", ch.BackoffDelay)
+	}
+	ch.backoffDelay = time.Duration(backoffDelay) * time.Second
+	timeout, err := strconv.Atoi(ch.Timeout)
+	if err != nil || timeout < 1 {
+		return fmt.Errorf("timeout must be a positive integer, got %q", ch.Timeout)
+	}
+	ch.client = &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	if ch.sleep == nil {
+		ch.sleep = time.Sleep
+	}
+
+	ch.tokens = nil
+	switch ch.Auth {
+	case AuthNone:
+	case AuthOAuth2:
+		if ch.OAuth2TokenURL == "" || ch.OAuth2ClientID == "" || ch.OAuth2ClientSecret == "" {
+			return fmt.Errorf("all oauth2 fields except for the scope must be set for OAuth 2.0")
+		}
+		if u, err := url.Parse(ch.OAuth2TokenURL); err != nil || u.Scheme != "https" {
+			return fmt.Errorf("oauth2_token_url must be an https URL, got %q", ch.OAuth2TokenURL)
+		}
+
+		ch.tokens = &oauth2.ClientCredentials{
+			TokenURL:     ch.OAuth2TokenURL,
+			ClientID:     ch.OAuth2ClientID,
+			ClientSecret: ch.OAuth2ClientSecret,
+			Scope:        ch.OAuth2Scope,
+		}
+	case AuthHMAC:
+		if ch.HMACSecret == "" || ch.HMACHeader == "" {
+			return fmt.Errorf("hmac_secret and hmac_header must be set for HMAC")
+		}
+	default:
+		return fmt.Errorf("unsupported auth mode %q", ch.Auth)
 	}
 
 	return nil
@@ -150,21 +450,127 @@ func (ch *Webhook) SendNotification(req *plugin.NotificationRequest) error {
 		return fmt.Errorf("cannot execute Request Body template: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(ch.Method, urlBuff.String(), &reqBodyBuff)
+	respBody, err := ch.request(ch.Method, urlBuff.String(), reqBodyBuff.Bytes(), ch.respStatusCodes, req.Event.TraceID)
 	if err != nil {
 		return err
 	}
-	httpResp, err := http.DefaultClient.Do(httpReq)
+
+	if ch.tmplFollowUpUrl != nil {
+		return ch.followUp(req, respBody)
+	}
+
+	return nil
+}
+
+// HealthCheck implements the plugin.HealthChecker interface.
+//
+// If OAuth 2.0 is used, an access token is fetched to verify the credentials. If the URL does not depend on the
+// notification, i.e., is no template, a HEAD request checks its reachability. Any HTTP response is accepted, as the
+// receiver might not support HEAD requests.
+func (ch *Webhook) HealthCheck() error {
+	if ch.tokens != nil {
+		if _, err := ch.tokens.Token(context.Background()); err != nil {
+			return fmt.Errorf("cannot fetch OAuth 2.0 access token: %w", err)
+		}
+	}
+
+	if strings.Contains(ch.URLTemplate, "{{") {
+		return nil
+	}
+
+	httpResp, err := ch.client.Head(ch.URLTemplate)
 	if err != nil {
 		return err
 	}
-	_, _ = io.Copy(io.Discard, httpResp.Body)
 	_ = httpResp.Body.Close()
 
-	if !slices.Contains(ch.respStatusCodes, httpResp.StatusCode) {
-		return fmt.Errorf("unaccepted HTTP response status code %d not in %v",
-			httpResp.StatusCode, ch.respStatusCodes)
+	return nil
+}
+
+// request sends the request, retrying it as configured, and returns the response body on an expected status code.
+func (ch *Webhook) request(method, target string, body []byte, statusCodes []int, traceID string) ([]byte, error) {
+	var httpResp *http.Response
+	var respBody []byte
+	var err error
+	for attempt := 1; ; attempt++ {
+		httpResp, respBody, err = ch.send(method, target, body, traceID)
+		if err == nil && (slices.Contains(statusCodes, httpResp.StatusCode) || !retryable(httpResp.StatusCode)) {
+			break
+		}
+		if attempt >= ch.maxAttempts {
+			break
+		}
+
+		reason := err
+		if reason == nil {
+			reason = fmt.Errorf("HTTP response status code %d", httpResp.StatusCode)
+		}
+		delay := ch.retryDelay(attempt, httpResp)
+		log.Printf("Webhook request failed in attempt %d of %d, retrying in %v: %v", attempt, ch.maxAttempts, delay, reason)
+		ch.sleep(delay)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	if !slices.Contains(statusCodes, httpResp.StatusCode) {
+		return nil, fmt.Errorf("unaccepted HTTP response status code %d not in %v", httpResp.StatusCode, statusCodes)
+	}
+
+	return respBody, nil
+}
+
+// send sends the request once, or twice if the access token was rejected, as it might have been revoked before it
+// expired and is thus retried with a fresh one.
+func (ch *Webhook) send(method, target string, body []byte, traceID string) (*http.Response, []byte, error) {
+	httpResp, respBody, err := ch.do(method, target, body, traceID)
+	if err == nil && httpResp.StatusCode == http.StatusUnauthorized && ch.tokens != nil {
+		ch.tokens.Invalidate()
+		return ch.do(method, target, body, traceID)
+	}
+
+	return httpResp, respBody, err
+}
+
+// do sends a single request with the body to the target URL and returns the response with its body, being read up
+// to maxResponseSize.
+func (ch *Webhook) do(method, target string, body []byte, traceID string) (*http.Response, []byte, error) {
+	httpReq, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	if traceID != "" {
+		httpReq.Header.Set(plugin.TraceIDHeader, traceID)
+	}
+	if err := ch.authenticate(httpReq, body); err != nil {
+		return nil, nil, err
+	}
+
+	httpResp, err := ch.client.Do(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	respBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	_, _ = io.Copy(io.Discard, httpResp.Body)
+
+	return httpResp, respBody, nil
+}
+
+// parseStatusCodes parses a comma separated list of HTTP status codes.
+func parseStatusCodes(list string) ([]int, error) {
+	var statusCodes []int
+	for _, statusCodeStr := range strings.Split(list, ",") {
+		statusCode, err := strconv.Atoi(statusCodeStr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert status code %q to int: %w", statusCodeStr, err)
+		}
+		statusCodes = append(statusCodes, statusCode)
+	}
+
+	return statusCodes, nil
 }
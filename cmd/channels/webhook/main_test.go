@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhook_HealthCheck(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+
+	webhook := &Webhook{}
+	require.NoError(t, webhook.SetConfig(json.RawMessage(`{"url_template":"`+server.URL+`"}`)))
+	assert.NoError(t, webhook.HealthCheck(), "any HTTP response should be accepted")
+	assert.Equal(t, []string{http.MethodHead}, methods)
+
+	templated := &Webhook{}
+	require.NoError(t, templated.SetConfig(json.RawMessage(`{"url_template":"`+server.URL+`/{{.Incident.Id}}"}`)))
+	assert.NoError(t, templated.HealthCheck())
+	assert.Len(t, methods, 1, "templated URLs should not be requested")
+
+	server.Close()
+	assert.Error(t, webhook.HealthCheck())
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	BackoffExponential = "exponential"
+	BackoffConstant    = "constant"
+)
+
+// maxRetryDelay caps the delay between two attempts, including delays requested by a Retry-After header, as the
+// daemon waits for the notification to be sent.
+const maxRetryDelay = time.Minute
+
+// retryable reports whether a request answered with the HTTP status code might succeed when sent again.
+func retryable(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// parseRetryAfter parses the value of a Retry-After header, being either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+// retryDelay returns the delay after the given failed attempt, starting with 1, before sending the next one.
+//
+// Responses with the status code 429 or 503 and a Retry-After header take precedence over the configured backoff.
+func (ch *Webhook) retryDelay(attempt int, httpResp *http.Response) time.Duration {
+	if httpResp != nil &&
+		(httpResp.StatusCode == http.StatusTooManyRequests || httpResp.StatusCode == http.StatusServiceUnavailable) {
+		if delay, ok := parseRetryAfter(httpResp.Header.Get("Retry-After"), time.Now()); ok {
+			return min(delay, maxRetryDelay)
+		}
+	}
+
+	delay := ch.backoffDelay
+	if ch.Backoff == BackoffExponential {
+		for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+			delay *= 2
+		}
+	}
+
+	return min(delay, maxRetryDelay)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		delay time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"Fri, 01 Mar 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Fri, 01 Mar 2024 11:00:00 GMT", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		delay, ok := parseRetryAfter(tt.value, now)
+		assert.Equal(t, tt.ok, ok, tt.value)
+		assert.Equal(t, tt.delay, delay, tt.value)
+	}
+}
+
+func TestWebhook_RetryDelay(t *testing.T) {
+	webhook := &Webhook{Backoff: BackoffExponential, backoffDelay: time.Second}
+	assert.Equal(t, time.Second, webhook.retryDelay(1, nil))
+	assert.Equal(t, 4*time.Second, webhook.retryDelay(3, nil))
+	assert.Equal(t, maxRetryDelay, webhook.retryDelay(10, nil))
+
+	webhook.Backoff = BackoffConstant
+	assert.Equal(t, time.Second, webhook.retryDelay(3, nil))
+
+	retryAfter := func(statusCode int, value string) *http.Response {
+		return &http.Response{StatusCode: statusCode, Header: http.Header{"Retry-After": {value}}}
+	}
+	assert.Equal(t, 5*time.Second, webhook.retryDelay(1, retryAfter(http.StatusTooManyRequests, "5")))
+	assert.Equal(t, maxRetryDelay, webhook.retryDelay(1, retryAfter(http.StatusServiceUnavailable, "3600")))
+	assert.Equal(t, time.Second, webhook.retryDelay(1, retryAfter(http.StatusBadGateway, "5")),
+		"Retry-After should only be honored for 429 and 503")
+}
+
+func TestWebhook_SetConfig_Retry(t *testing.T) {
+	for name, config := range map[string]string{
+		"NoAttempts":    `{"url_template":"http://localhost","max_attempts":"0"}`,
+		"Backoff":       `{"url_template":"http://localhost","backoff":"linear"}`,
+		"NegativeDelay": `{"url_template":"http://localhost","backoff_delay":"-1"}`,
+		"Timeout":       `{"url_template":"http://localhost","timeout":"forever"}`,
+	} {
+		assert.Error(t, (&Webhook{}).SetConfig(json.RawMessage(config)), name)
+	}
+}
+
+func TestWebhook_SendNotification_Retry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int64
+		wantErr  bool
+	}{
+		{"Success", []int{http.StatusOK}, 1, false},
+		{"TransientFailure", []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK}, 3, false},
+		{"PersistentFailure", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, 3, true},
+		{"PermanentFailure", []int{http.StatusBadRequest, http.StatusOK}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				w.Header().Set("Retry-After", strconv.Itoa(int(n)*5))
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer server.Close()
+
+			var delays []time.Duration
+			webhook := &Webhook{sleep: func(d time.Duration) { delays = append(delays, d) }}
+			require.NoError(t, webhook.SetConfig(json.RawMessage(`{"url_template":"`+server.URL+`",`+
+				`"max_attempts":"3","backoff":"exponential","backoff_delay":"1"}`)))
+
+			err := webhook.SendNotification(makeRequest())
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.attempts, attempts.Load())
+			assert.Len(t, delays, int(tt.attempts-1))
+			if tt.name == "TransientFailure" {
+				assert.Equal(t, []time.Duration{time.Second, 10 * time.Second}, delays,
+					"the second delay should be taken from the Retry-After header")
+			}
+		})
+	}
+}
+
+func TestWebhook_SendNotification_Timeout(t *testing.T) {
+	var attempts atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			time.Sleep(1500 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{sleep: func(time.Duration) {}}
+	require.NoError(t, webhook.SetConfig(json.RawMessage(`{"url_template":"`+server.URL+`","timeout":"1"}`)))
+	require.NoError(t, webhook.SendNotification(makeRequest()))
+	assert.Equal(t, int64(2), attempts.Load(), "the timed out request should be retried")
+}
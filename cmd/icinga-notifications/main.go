@@ -6,20 +6,63 @@ import (
 	"github.com/icinga/icinga-go-library/logging"
 	"github.com/icinga/icinga-go-library/utils"
 	"github.com/icinga/icinga-notifications/internal"
+	"github.com/icinga/icinga-notifications/internal/archive"
+	"github.com/icinga/icinga-notifications/internal/audit"
 	"github.com/icinga/icinga-notifications/internal/channel"
+	"github.com/icinga/icinga-notifications/internal/cli"
 	"github.com/icinga/icinga-notifications/internal/config"
 	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/enrichment"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/eventbuffer"
+	"github.com/icinga/icinga-notifications/internal/eventqueue"
+	"github.com/icinga/icinga-notifications/internal/ha"
 	"github.com/icinga/icinga-notifications/internal/icinga2"
+	"github.com/icinga/icinga-notifications/internal/ics"
 	"github.com/icinga/icinga-notifications/internal/incident"
+	"github.com/icinga/icinga-notifications/internal/integrity"
 	"github.com/icinga/icinga-notifications/internal/listener"
+	"github.com/icinga/icinga-notifications/internal/mailgateway"
 	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/icinga/icinga-notifications/internal/pgnotify"
+	"github.com/icinga/icinga-notifications/internal/retention"
+	"github.com/icinga/icinga-notifications/internal/ruleimport"
+	"github.com/icinga/icinga-notifications/internal/schema"
+	"github.com/icinga/icinga-notifications/internal/scrub"
+	"github.com/icinga/icinga-notifications/internal/selftest"
+	"github.com/icinga/icinga-notifications/internal/shard"
+	"github.com/icinga/icinga-notifications/internal/workerpool"
 	"github.com/okzk/sdnotify"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
 	"os/signal"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
 )
 
+// eventBacklog is the number of events queued per worker of the event processing pool, before the Icinga 2 Event Stream
+// is no longer read, see workerpool.New.
+const eventBacklog = 100
+
 func main() {
+	if len(os.Args) > 1 && (os.Args[1] == "incidents" || os.Args[1] == "simulate") {
+		run := cli.RunIncidents
+		if os.Args[1] == "simulate" {
+			run = cli.RunSimulate
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		err := run(ctx, os.Args[2:], os.Stdout)
+		cancel()
+		if err != nil {
+			utils.PrintErrorThenExit(err, daemon.ExitFailure)
+		}
+		return
+	}
+
 	daemon.ParseFlagsAndConfig()
 	conf := daemon.Config()
 
@@ -46,6 +89,45 @@ func main() {
 		logger.Fatalf("Cannot connect to the database: %+v", err)
 	}
 
+	schemaInfo, err := schema.Load(ctx, db)
+	if err != nil {
+		logger.Fatalf("Cannot load database schema version: %+v", err)
+	}
+	if err := schemaInfo.Check(); err != nil {
+		if schemaInfo.Version < schemaInfo.MinSupported {
+			// Processing events would fail at the latest when writing to tables or columns missing in the schema.
+			logger.Fatalf("Cannot start with an outdated database schema: %v", err)
+		}
+
+		logger.Warnf("Destructive operations like importing rules or soft-deleting objects are refused: %v", err)
+	}
+
+	if path := daemon.CLIFlags().ImportRules; path != "" {
+		result, err := importRules(ctx, db, path)
+		if err != nil {
+			logger.Fatalf("Cannot import rules from %q: %+v", path, err)
+		}
+
+		logger.Infow("Successfully imported rules, exiting",
+			zap.Strings("created", result.Created), zap.Strings("replaced", result.Replaced))
+		return
+	}
+
+	if scrub.Default, err = scrub.New(conf.Scrub); err != nil {
+		logger.Fatalf("Cannot create scrubber: %+v", err)
+	}
+
+	enrichmentLogger := logs.GetChildLogger("enrichment").SugaredLogger
+	if enrichment.Default, err = enrichment.New(conf.Enrichment, enrichmentLogger); err != nil {
+		logger.Fatalf("Cannot create enricher: %+v", err)
+	}
+
+	shard.Configure(conf.Sharding)
+	if conf.Sharding.Count > 1 {
+		logger.Infow("Serving only objects of some shards", zap.Int("count", shard.Count()),
+			zap.Ints("shards", conf.Sharding.Shards))
+	}
+
 	channel.UpsertPlugins(ctx, conf.ChannelsDir, logs.GetChildLogger("channel"), db)
 
 	icinga2Launcher := &icinga2.Launcher{
@@ -63,6 +145,51 @@ func main() {
 	icinga2Launcher.RuntimeConfig = runtimeConfig
 
 	go runtimeConfig.PeriodicUpdates(ctx, 1*time.Second)
+	go rescanChannels(ctx, db, logs, runtimeConfig)
+
+	if conf.DatabaseNotifications {
+		go listenDatabaseNotifications(ctx, logs, runtimeConfig)
+	}
+
+	haInstance, err := ha.New(ctx, db, logs.GetChildLogger("ha"), conf.HA)
+	if err != nil {
+		logger.Fatalf("Cannot register this instance for high availability: %+v", err)
+	}
+
+	// The high availability and the state export outlive ctx until the events and notifications in progress are
+	// drained, as another instance must not take over before and the exported states should reflect the final state.
+	stopCtx, stop := context.WithCancel(context.Background())
+	var stopped sync.WaitGroup
+	// Wait for the instance to be unregistered before closing the database, allowing another one to take over.
+	defer func() {
+		stop()
+		stopped.Wait()
+	}()
+
+	stopped.Add(1)
+	go func() {
+		defer stopped.Done()
+		if err := haInstance.Run(stopCtx); err != nil {
+			logger.Fatalf("Stopping, as this instance is no longer responsible: %+v", err)
+		}
+	}()
+
+	if !haInstance.Active() {
+		// Passive instances are considered started by systemd, while waiting to take over.
+		_ = sdnotify.Ready()
+		logger.Info("Another instance is responsible, waiting to take over")
+
+		select {
+		case <-haInstance.Takeover():
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	if conf.ReadOnly {
+		incident.SetReadOnly(true)
+		logger.Warn("Starting in read-only mode, no escalations will be triggered and no notifications will be sent")
+	}
 
 	err = incident.LoadOpenIncidents(ctx, db, logs.GetChildLogger("incident"), runtimeConfig)
 	if err != nil {
@@ -75,15 +202,267 @@ func main() {
 		logger.Fatalf("Failed to restore muted objects: %+v", err)
 	}
 
+	// Events of distinct objects are processed concurrently, each worker queueing up to eventBacklog events.
+	pool := workerpool.New(conf.EventWorkers, eventBacklog)
+	icinga2Launcher.Pool = pool
+
+	var queue *eventqueue.Queue
+	// queueStopped is done once the queue no longer submits events to the pool.
+	var queueStopped sync.WaitGroup
+	if conf.EventQueue.Enabled {
+		process := func(ctx context.Context, ev *event.Event) error {
+			return incident.ProcessEvent(ctx, db, logs, runtimeConfig, ev)
+		}
+
+		queue = eventqueue.New(db, conf.EventQueue, pool, process, logs.GetChildLogger("event-queue"))
+		icinga2Launcher.Queue = queue
+
+		queueStopped.Add(1)
+		go func() {
+			defer queueStopped.Done()
+			queue.Run(ctx)
+		}()
+	}
+
 	// Wait to load open incidents from the database before either starting Event Stream Clients or starting the Listener.
 	icinga2Launcher.Ready()
 
 	// When Icinga Notifications is started by systemd, we've to notify systemd that we're ready.
 	_ = sdnotify.Ready()
 
-	if err := listener.NewListener(db, runtimeConfig, logs).Run(ctx); err != nil {
+	go incident.AutoCloseInactive(ctx, logs.GetChildLogger("incident"), runtimeConfig, conf.IncidentAutoClose)
+
+	if conf.StateExport > 0 {
+		stateExporter := &incident.StateExporter{
+			DB:       db,
+			Logger:   logs.GetChildLogger("incident"),
+			Interval: conf.StateExport,
+		}
+
+		stopped.Add(1)
+		go func() {
+			defer stopped.Done()
+			stateExporter.Run(stopCtx)
+		}()
+	}
+
+	// Archives are written by the retention, but read by the listener of any instance.
+	var archiver *archive.Archiver
+	if conf.Retention.Archive.Enabled() {
+		archiver = archive.New(conf.Retention.Archive)
+	}
+
+	// Jobs unrelated to objects only run on the instance serving the first shard.
+	if shard.Primary() {
+		icsImporter := &ics.Importer{
+			RuntimeConfig: runtimeConfig,
+			Logger:        logs.GetChildLogger("ics"),
+			Interval:      conf.IcsImportInterval,
+			Client:        &http.Client{},
+		}
+		go icsImporter.Run(ctx)
+
+		if conf.CalDAV.Interval > 0 {
+			caldavPublisher := &ics.Publisher{
+				RuntimeConfig: runtimeConfig,
+				Logger:        logs.GetChildLogger("ics"),
+				Interval:      conf.CalDAV.Interval,
+				Horizon:       conf.CalDAV.Horizon,
+				Username:      conf.CalDAV.Username,
+				Password:      conf.CalDAV.Password,
+				Client:        &http.Client{},
+			}
+			go caldavPublisher.Run(ctx)
+		}
+
+		if conf.IntegrityCheck.Interval > 0 {
+			integrityChecker := &integrity.Checker{
+				DB:       db,
+				Logger:   logs.GetChildLogger("integrity"),
+				Interval: conf.IntegrityCheck.Interval,
+				Repair:   conf.IntegrityCheck.Repair,
+			}
+			go integrityChecker.Run(ctx)
+		}
+
+		if conf.Retention.Enabled() {
+			cleaner := &retention.Cleaner{
+				DB:       db,
+				Logger:   logs.GetChildLogger("retention"),
+				Config:   conf.Retention,
+				Archiver: archiver,
+			}
+			go cleaner.Run(ctx)
+		}
+
+		if conf.SelfTest.At != "" {
+			tester := &selftest.Tester{
+				DB:            db,
+				RuntimeConfig: runtimeConfig,
+				Logger:        logs.GetChildLogger("self-test"),
+				Config:        conf.SelfTest,
+			}
+			go tester.Run(ctx)
+		}
+	}
+
+	if conf.MailGateway.Listen != "" {
+		gateway, err := mailgateway.NewGateway(conf.MailGateway, db, runtimeConfig, queue, logs)
+		if err != nil {
+			logger.Fatalf("Cannot create mail gateway: %+v", err)
+		}
+
+		go func() {
+			if err := gateway.Run(ctx); err != nil {
+				logger.Errorf("Mail gateway has finished with an error: %+v", err)
+			}
+		}()
+	}
+
+	var buffer *eventbuffer.Buffer
+	if conf.EventBuffer.Size > 0 {
+		process := func(ctx context.Context, ev *event.Event) error {
+			if queue != nil {
+				return queue.Enqueue(ctx, ev)
+			}
+			return incident.ProcessEvent(ctx, db, logs, runtimeConfig, ev)
+		}
+
+		buffer, err = eventbuffer.New(conf.EventBuffer.Size, conf.EventBuffer.File, process, db.PingContext,
+			logs.GetChildLogger("event-buffer"))
+		if err != nil {
+			logger.Fatalf("Cannot create event buffer: %+v", err)
+		}
+
+		go buffer.Run(ctx, conf.EventBuffer.RetryInterval)
+	}
+
+	if err := listener.NewListener(db, runtimeConfig, buffer, queue, archiver, logs).Run(ctx); err != nil {
 		logger.Errorf("Listener has finished with an error: %+v", err)
 	} else {
 		logger.Info("Listener has finished")
 	}
+
+	// No further events are accepted from now on, but those in progress and their notifications are completed.
+	_ = sdnotify.Stopping()
+	logger.Infow("Waiting for events and notifications in progress", zap.Duration("timeout", conf.ShutdownTimeout))
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancelDrain()
+
+	// The events already accepted from the Event Stream are still processed before incident.Drain refuses any further
+	// ones. Thus, the pool is closed once nothing submits to it anymore, waiting for its queued events to complete.
+	icinga2Launcher.Wait()
+	queueStopped.Wait()
+
+	poolClosed := make(chan struct{})
+	go func() {
+		defer close(poolClosed)
+		pool.Close()
+	}()
+	select {
+	case <-poolClosed:
+	case <-drainCtx.Done():
+		logger.Warn("Aborted waiting for queued events to be processed")
+	}
+
+	if aborted := incident.Drain(drainCtx); aborted > 0 {
+		logger.Warnw("Aborted events and notifications still in progress, undelivered notifications stay pending",
+			zap.Int("aborted", aborted))
+	} else {
+		logger.Info("Completed all events and notifications in progress")
+	}
+}
+
+// importRules imports the rules document at the given path, see ruleimport.Import.
+func importRules(ctx context.Context, db *database.DB, path string) (*ruleimport.Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	doc, err := ruleimport.ParseDocument(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return ruleimport.Import(ctx, db, doc)
+}
+
+// listenDatabaseNotifications synchronizes the configuration and invalidates incidents as soon as the database notifies
+// about changes, see pgnotify.Listener.
+func listenDatabaseNotifications(ctx context.Context, logs *logging.Logging, rc *config.RuntimeConfig) {
+	logger := logs.GetChildLogger("database-notifications")
+
+	listener := &pgnotify.Listener{
+		Database: daemon.Config().Database,
+		Logger:   logger,
+		Handlers: map[string]pgnotify.Handler{
+			pgnotify.ConfigChannel: func(context.Context, string) {
+				rc.RequestUpdate()
+			},
+			pgnotify.IncidentChannel: func(ctx context.Context, payload string) {
+				// An empty payload invalidates all incidents, as changes might have been missed.
+				var id int64
+				if payload != "" {
+					var err error
+					if id, err = strconv.ParseInt(payload, 10, 64); err != nil {
+						logger.Errorw("Cannot parse incident ID of notification", zap.String("payload", payload))
+						return
+					}
+				}
+
+				if err := incident.Invalidate(ctx, id); err != nil {
+					logger.Errorw("Cannot invalidate incident", zap.Int64("incident", id), zap.Error(err))
+				}
+			},
+		},
+	}
+
+	if err := listener.Run(ctx); err != nil {
+		logger.Errorw("Stopped listening for database notifications", zap.Error(err))
+	}
+}
+
+// rescanChannels rescans the channels directory on each SIGHUP and whenever its plugins change, registering newly
+// installed plugins and letting the channels restart updated ones, until the context is done.
+func rescanChannels(ctx context.Context, db *database.DB, logs *logging.Logging, rc *config.RuntimeConfig) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	logger := logs.GetChildLogger("channel")
+	rescan := func() {
+		channel.UpsertPlugins(ctx, daemon.Config().ChannelsDir, logger, db)
+		rc.RescanChannelPlugins()
+	}
+
+	changed := make(chan struct{}, 1)
+	go channel.WatchPlugins(ctx, daemon.Config().ChannelsDir, logger, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	for {
+		select {
+		case <-sighup:
+			logger.Info("Received SIGHUP, rescanning the channels directory")
+			rescan()
+
+			e, err := audit.NewEntry(audit.ActionReload, audit.ActorSignal, "", nil, nil)
+			if err == nil {
+				err = audit.Record(ctx, db, e)
+			}
+			if err != nil {
+				logger.Errorw("Cannot record reload in the audit log", zap.Error(err))
+			}
+		case <-changed:
+			rescan()
+		case <-ctx.Done():
+			return
+		}
+	}
 }
@@ -0,0 +1,188 @@
+// Package acklink implements signed, single-use links to acknowledge an incident, embedded in notifications.
+//
+// A link references an incident and the notified contact together with its expiry time and a random nonce, signed by
+// an HMAC. Thus, the listener can verify a link without storing it and acknowledges the incident on behalf of the
+// contact it was sent to. Only the nonces of redeemed links are stored to prevent them from being used again.
+package acklink
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/errs"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Path of the listener endpoint handling the links.
+const Path = "/acknowledge"
+
+// VoicePath of the listener endpoint handling the keypad input of voice calls.
+//
+// Plugins placing voice calls derive it from a link by appending "/voice" to its path while keeping its token. Thus,
+// they don't have to know the base URL of the links.
+const VoicePath = Path + "/voice"
+
+var (
+	// ErrInvalidLink is returned by Signer.Verify for malformed links or links with an invalid signature.
+	ErrInvalidLink = errors.New("invalid acknowledgement link")
+
+	// ErrExpiredLink is returned by Signer.Verify for links past their expiry time.
+	ErrExpiredLink = errors.New("acknowledgement link has expired")
+
+	// ErrLinkUsed is returned by Redeem for links which have already been used.
+	ErrLinkUsed = errors.New("acknowledgement link has already been used")
+)
+
+// Claims of a link, i.e., which contact may acknowledge which incident until when.
+type Claims struct {
+	IncidentID int64
+	ContactID  int64
+	ExpiresAt  time.Time
+	Nonce      string
+}
+
+// Signer creates and verifies links, see NewSigner.
+type Signer struct {
+	baseURL  *url.URL
+	key      []byte
+	validity time.Duration
+}
+
+// NewSigner creates a Signer from the given configuration.
+//
+// If acknowledgement links are disabled, i.e., no base URL is configured, nil is returned without an error.
+func NewSigner(conf *daemon.AckLinkConfig) (*Signer, error) {
+	if conf.BaseURL == "" {
+		return nil, nil
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	baseURL, err := url.Parse(conf.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse ack-links.base-url: %w", err)
+	}
+
+	return &Signer{baseURL: baseURL, key: []byte(conf.Secret), validity: conf.Validity}, nil
+}
+
+// URL returns a new link allowing the contact to acknowledge the incident once until the link expires.
+func (s *Signer) URL(incidentID, contactID int64, now time.Time) string {
+	var nonce [16]byte
+	_, _ = rand.Read(nonce[:])
+
+	payload := fmt.Sprintf("%d.%d.%d.%s",
+		incidentID, contactID, now.Add(s.validity).Unix(), hex.EncodeToString(nonce[:]))
+
+	u := s.baseURL.JoinPath(Path)
+	u.RawQuery = url.Values{"token": {s.sign(payload)}}.Encode()
+
+	return u.String()
+}
+
+// sign returns the token of the given payload, consisting of the payload and its HMAC, both base64url encoded.
+func (s *Signer) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the token of a link created by URL and returns its claims.
+//
+// An error wrapping either ErrInvalidLink or ErrExpiredLink is returned if the token cannot be trusted or is expired.
+// Whether the link has already been used is checked by Redeem.
+func (s *Signer) Verify(token string, now time.Time) (*Claims, error) {
+	encodedPayload, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidLink)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidLink)
+	}
+
+	if !hmac.Equal([]byte(s.sign(string(payload))), []byte(token)) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidLink)
+	}
+
+	fields := strings.Split(string(payload), ".")
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("%w: malformed payload", ErrInvalidLink)
+	}
+
+	var ids [3]int64
+	for i := range ids {
+		if ids[i], err = strconv.ParseInt(fields[i], 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: malformed payload", ErrInvalidLink)
+		}
+	}
+
+	claims := &Claims{IncidentID: ids[0], ContactID: ids[1], ExpiresAt: time.Unix(ids[2], 0), Nonce: fields[3]}
+	if now.After(claims.ExpiresAt) {
+		return nil, fmt.Errorf("%w at %s", ErrExpiredLink, claims.ExpiresAt)
+	}
+
+	return claims, nil
+}
+
+// redemptionRow records a used link by its nonce.
+type redemptionRow struct {
+	Nonce      string          `db:"nonce"`
+	IncidentID int64           `db:"incident_id"`
+	ContactID  int64           `db:"contact_id"`
+	RedeemedAt types.UnixMilli `db:"redeemed_at"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (r *redemptionRow) TableName() string {
+	return "incident_ack_link"
+}
+
+// Redeem marks the verified link of the given claims as used, returning ErrLinkUsed if it was already.
+//
+// The nonce is only inserted, relying on the primary key to reject it if it was already redeemed. Thus, out of
+// concurrent redemptions of the same link, exactly one succeeds. If acting on the link fails afterwards, it should be
+// released again, see Release.
+func Redeem(ctx context.Context, db *database.DB, claims *Claims) error {
+	row := &redemptionRow{
+		Nonce:      claims.Nonce,
+		IncidentID: claims.IncidentID,
+		ContactID:  claims.ContactID,
+		RedeemedAt: types.UnixMilli(time.Now()),
+	}
+	stmt, _ := db.BuildInsertStmt(row)
+	if _, err := db.NamedExecContext(ctx, stmt, row); errs.IsDuplicateKey(err) {
+		return ErrLinkUsed
+	} else if err != nil {
+		return fmt.Errorf("cannot redeem acknowledgement link: %w", err)
+	}
+
+	return nil
+}
+
+// Release reverts the redemption of the link of the given claims by Redeem, allowing it to be used again, e.g., as
+// acknowledging the incident failed.
+func Release(ctx context.Context, db *database.DB, claims *Claims) error {
+	stmt := db.Rebind(`DELETE FROM "incident_ack_link" WHERE "nonce" = ?`)
+	if _, err := db.ExecContext(ctx, stmt, claims.Nonce); err != nil {
+		return fmt.Errorf("cannot release acknowledgement link: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package acklink
+
+import (
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	t.Parallel()
+
+	conf := &daemon.AckLinkConfig{
+		BaseURL:  "https://notifications.example.com/prefix",
+		Secret:   strings.Repeat("s", 32),
+		Validity: time.Hour,
+	}
+	signer, err := NewSigner(conf)
+	require.NoError(t, err)
+
+	now := time.Now()
+	link, err := url.Parse(signer.URL(23, 42, now))
+	require.NoError(t, err)
+	assert.Equal(t, "/prefix/acknowledge", link.Path)
+
+	token := link.Query().Get("token")
+	claims, err := signer.Verify(token, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(23), claims.IncidentID)
+	assert.Equal(t, int64(42), claims.ContactID)
+	assert.Equal(t, now.Add(time.Hour).Unix(), claims.ExpiresAt.Unix())
+	assert.Len(t, claims.Nonce, 32)
+
+	other, err := url.Parse(signer.URL(23, 42, now))
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other.Query().Get("token"), "each link must have its own nonce")
+
+	t.Run("Expired", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := signer.Verify(token, now.Add(2*time.Hour))
+		assert.ErrorIs(t, err, ErrExpiredLink)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		t.Parallel()
+
+		payload, mac, _ := strings.Cut(token, ".")
+		for _, tampered := range []string{"", "garbage", payload, payload + "." + mac[1:], "MjMuNDMuMS54." + mac} {
+			_, err := signer.Verify(tampered, now)
+			assert.ErrorIs(t, err, ErrInvalidLink, tampered)
+		}
+	})
+
+	t.Run("OtherSecret", func(t *testing.T) {
+		t.Parallel()
+
+		other := *conf
+		other.Secret = strings.Repeat("o", 32)
+		otherSigner, err := NewSigner(&other)
+		require.NoError(t, err)
+
+		_, err = otherSigner.Verify(token, now)
+		assert.ErrorIs(t, err, ErrInvalidLink)
+	})
+}
+
+func TestNewSigner(t *testing.T) {
+	t.Parallel()
+
+	signer, err := NewSigner(&daemon.AckLinkConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, signer, "acknowledgement links must be disabled without a base URL")
+
+	_, err = NewSigner(&daemon.AckLinkConfig{BaseURL: "https://example.com", Secret: "short", Validity: time.Hour})
+	assert.Error(t, err)
+}
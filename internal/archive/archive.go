@@ -0,0 +1,254 @@
+// Package archive exports rows before they are deleted by the retention, see package retention, allowing to keep them
+// for compliance without bloating the database.
+//
+// Each batch of deleted rows is written as a single gzip-compressed NDJSON file to either a local directory or an
+// S3-compatible bucket, one Record per line for closed incidents, or a single row per line for notifications and
+// events. If deleting the rows fails after they were archived, they are archived again by the next attempt, i.e., each
+// row is archived at least once.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/jmoiron/sqlx"
+	"net/http"
+	"time"
+)
+
+// Record is a single line of an archive, i.e., a closed incident with everything that happened to it.
+//
+// All rows are exported with their database columns as they are, to be independent of the daemon's data types.
+type Record struct {
+	Incident map[string]any   `json:"incident"`
+	Object   map[string]any   `json:"object"`
+	Events   []map[string]any `json:"events"`
+	History  []map[string]any `json:"history"`
+}
+
+// store writes and reads archives under the given name.
+type store interface {
+	put(ctx context.Context, name string, data []byte) error
+	// list returns the names of all archives starting with the given prefix.
+	list(ctx context.Context, prefix string) ([]string, error)
+	get(ctx context.Context, name string) ([]byte, error)
+}
+
+// Archiver archives incidents to the configured destination.
+type Archiver struct {
+	store  store
+	prefix string
+}
+
+// New creates an Archiver for the enabled destination of the configuration.
+func New(c daemon.ArchiveConfig) *Archiver {
+	if c.S3.Endpoint != "" {
+		return &Archiver{store: &s3Store{config: c.S3, client: &http.Client{Timeout: time.Minute}}, prefix: c.S3.Prefix}
+	}
+
+	return &Archiver{store: &dirStore{dir: c.Path}}
+}
+
+// ArchiveIncidents archives the closed incidents of the given IDs as a Record each, before they are deleted.
+//
+// The archive is uploaded without any transaction, so that the rows must not be deleted before this returns.
+func (a *Archiver) ArchiveIncidents(ctx context.Context, db sqlx.ExtContext, incidentIDs []int64) error {
+	if len(incidentIDs) == 0 {
+		return nil
+	}
+
+	records, err := fetchRecords(ctx, db, incidentIDs)
+	if err != nil {
+		return err
+	}
+
+	lines := make([]any, 0, len(records))
+	for _, r := range records {
+		lines = append(lines, r)
+	}
+
+	return a.write(ctx, "incidents", incidentIDs, lines)
+}
+
+// ArchiveNotifications archives the notifications of the given incident_history IDs, one history row per line, before
+// they are deleted. Like ArchiveIncidents, the rows must not be deleted before this returns.
+func (a *Archiver) ArchiveNotifications(ctx context.Context, db sqlx.ExtContext, historyIDs []int64) error {
+	return a.archiveRows(ctx, db, "notifications",
+		`SELECT * FROM "incident_history" WHERE "id" IN (?) ORDER BY "id"`, historyIDs)
+}
+
+// ArchiveEvents archives the events of the given IDs, one event row per line, before they are deleted. Like
+// ArchiveIncidents, the rows must not be deleted before this returns.
+func (a *Archiver) ArchiveEvents(ctx context.Context, db sqlx.ExtContext, eventIDs []int64) error {
+	return a.archiveRows(ctx, db, "events", `SELECT * FROM "event" WHERE "id" IN (?) ORDER BY "id"`, eventIDs)
+}
+
+// archiveRows archives the rows selected by the query with its single placeholder expanded to the IDs.
+func (a *Archiver) archiveRows(ctx context.Context, db sqlx.ExtContext, kind, query string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := selectIn(ctx, db, query, ids)
+	if err != nil {
+		return err
+	}
+
+	lines := make([]any, 0, len(rows))
+	for _, row := range rows {
+		lines = append(lines, row)
+	}
+
+	return a.write(ctx, kind, ids, lines)
+}
+
+// write stores the lines as a single archive of the given kind, named after the first and last of the sorted IDs.
+func (a *Archiver) write(ctx context.Context, kind string, ids []int64, lines []any) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, line := range lines {
+		if err := enc.Encode(line); err != nil {
+			return fmt.Errorf("cannot encode %s: %w", kind, err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s%s-%s-%d-%d.ndjson.gz", a.prefix, kind, time.Now().UTC().Format("20060102T150405Z"),
+		ids[0], ids[len(ids)-1])
+	if err := a.store.put(ctx, name, buf.Bytes()); err != nil {
+		return fmt.Errorf("cannot store archive %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// fetchRecords fetches the Record of each incident in the order of the IDs.
+func fetchRecords(ctx context.Context, db sqlx.ExtContext, incidentIDs []int64) ([]*Record, error) {
+	// Depending on the driver and protocol, IDs are scanned as int64 or string, thus records are keyed by their string
+	// representation.
+	records := make(map[string]*Record, len(incidentIDs))
+	record := func(row map[string]any, column string) *Record {
+		r := records[fmt.Sprint(row[column])]
+		delete(row, "_incident_id")
+		return r
+	}
+
+	incidents, err := selectIn(ctx, db, `SELECT * FROM "incident" WHERE "id" IN (?)`, incidentIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, incident := range incidents {
+		records[fmt.Sprint(incident["id"])] = &Record{
+			Incident: incident,
+			Events:   []map[string]any{},
+			History:  []map[string]any{},
+		}
+	}
+
+	objects, err := selectIn(ctx, db, `SELECT "incident"."id" AS "_incident_id", "object".* FROM "object"`+
+		` INNER JOIN "incident" ON "incident"."object_id" = "object"."id" WHERE "incident"."id" IN (?)`, incidentIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		if r := record(object, "_incident_id"); r != nil {
+			r.Object = object
+		}
+	}
+
+	events, err := selectIn(ctx, db, `SELECT "incident_event"."incident_id" AS "_incident_id", "event".* FROM "event"`+
+		` INNER JOIN "incident_event" ON "incident_event"."event_id" = "event"."id"`+
+		` WHERE "incident_event"."incident_id" IN (?) ORDER BY "event"."time", "event"."id"`, incidentIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if r := record(event, "_incident_id"); r != nil {
+			r.Events = append(r.Events, event)
+		}
+	}
+
+	history, err := selectIn(ctx, db,
+		`SELECT * FROM "incident_history" WHERE "incident_id" IN (?) ORDER BY "time", "id"`, incidentIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range history {
+		if r := record(entry, "incident_id"); r != nil {
+			r.History = append(r.History, entry)
+		}
+	}
+
+	ordered := make([]*Record, 0, len(incidentIDs))
+	for _, id := range incidentIDs {
+		if r := records[fmt.Sprint(id)]; r != nil {
+			ordered = append(ordered, r)
+		}
+	}
+
+	return ordered, nil
+}
+
+// selectIn runs the query with its single placeholder expanded to the values, returning all rows as column maps.
+func selectIn[T any](ctx context.Context, db sqlx.ExtContext, query string, values []T) ([]map[string]any, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	stmt, args, err := sqlx.In(query, values)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryxContext(ctx, db.Rebind(stmt), args...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query rows to archive: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	binary := make(map[string]bool, len(columnTypes))
+	for _, ct := range columnTypes {
+		switch ct.DatabaseTypeName() {
+		case "BYTEA", "BINARY", "VARBINARY":
+			binary[ct.Name()] = true
+		}
+	}
+
+	var result []map[string]any
+	for rows.Next() {
+		row := make(map[string]any)
+		if err := rows.MapScan(row); err != nil {
+			return nil, err
+		}
+		for column, value := range row {
+			row[column] = normalize(value, binary[column])
+		}
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
+
+// normalize a scanned value for JSON, which would encode byte slices base64, e.g., text columns of MySQL. Values of
+// binary columns, e.g., object IDs, are hex-encoded instead, as anywhere else in the daemon.
+func normalize(value any, binary bool) any {
+	if b, ok := value.([]byte); ok {
+		if binary {
+			return hex.EncodeToString(b)
+		}
+		return string(b)
+	}
+
+	return value
+}
@@ -0,0 +1,38 @@
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArchiver_write(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a := &Archiver{store: &dirStore{dir: dir}}
+	lines := []any{map[string]any{"id": 3}, map[string]any{"id": 7}}
+	require.NoError(t, a.write(context.Background(), "notifications", []int64{3, 7}, lines))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, strings.HasPrefix(entries[0].Name(), "notifications-"), "archive must be named by kind")
+	assert.True(t, strings.HasSuffix(entries[0].Name(), "-3-7.ndjson.gz"), "archive must be named by IDs")
+
+	f, err := os.Open(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\":3}\n{\"id\":7}\n", string(data))
+}
@@ -0,0 +1,162 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotArchived is returned by Archiver.Incident for incidents not found in any archive.
+var ErrNotArchived = errors.New("incident not archived")
+
+// Incident reads the Record of the incident of the given ID from the archives written by ArchiveIncidents.
+//
+// Only archives whose ID range covers the incident are downloaded. If the incident was archived multiple times, e.g.,
+// as deleting it failed afterwards, the latest Record is returned.
+func (a *Archiver) Incident(ctx context.Context, id int64) (*Record, error) {
+	names, err := a.find(ctx, "incidents", func(_ time.Time, first, last int64) bool {
+		return first <= id && id <= last
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Record
+	for _, name := range names {
+		err := readArchive(ctx, a, name, func(r *Record) {
+			if isID(r.Incident, "id", id) {
+				found = r
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("%w: %d", ErrNotArchived, id)
+	}
+
+	return found, nil
+}
+
+// Notifications reads the notifications of the incident of the given ID from the archives written by
+// ArchiveNotifications since the given time, e.g., the start of the incident, as no earlier archive can contain them.
+func (a *Archiver) Notifications(ctx context.Context, incidentID int64, since time.Time) ([]map[string]any, error) {
+	names, err := a.find(ctx, "notifications", func(written time.Time, _, _ int64) bool {
+		return !written.Before(since.Truncate(time.Second))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var notifications []map[string]any
+	for _, name := range names {
+		err := readArchive(ctx, a, name, func(row *map[string]any) {
+			if isID(*row, "incident_id", incidentID) {
+				notifications = append(notifications, *row)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return notifications, nil
+}
+
+// find returns the sorted names of all archives of the given kind matching the filter, see Archiver.write.
+func (a *Archiver) find(
+	ctx context.Context, kind string, filter func(written time.Time, first, last int64) bool,
+) ([]string, error) {
+	prefix := a.prefix + kind + "-"
+	names, err := a.store.list(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list archives: %w", err)
+	}
+
+	var matching []string
+	for _, name := range names {
+		written, first, last, ok := parseName(strings.TrimPrefix(name, prefix))
+		if ok && filter(written, first, last) {
+			matching = append(matching, name)
+		}
+	}
+	slices.Sort(matching)
+
+	return matching, nil
+}
+
+// parseName parses the name of an archive without its prefix and kind, i.e., "<time>-<first ID>-<last ID>.ndjson.gz".
+func parseName(name string) (written time.Time, first, last int64, ok bool) {
+	parts := strings.Split(strings.TrimSuffix(name, ".ndjson.gz"), "-")
+	if len(parts) != 3 || !strings.HasSuffix(name, ".ndjson.gz") {
+		return time.Time{}, 0, 0, false
+	}
+
+	written, err := time.Parse("20060102T150405Z", parts[0])
+	if err != nil {
+		return time.Time{}, 0, 0, false
+	}
+	first, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, 0, false
+	}
+	last, err = strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, 0, false
+	}
+
+	return written, first, last, true
+}
+
+// readArchive downloads the archive of the given name and decodes each of its lines into a T passed to fn.
+//
+// Numbers are decoded as json.Number, so that IDs and timestamps are kept exactly, see Decode.
+func readArchive[T any](ctx context.Context, a *Archiver, name string, fn func(*T)) error {
+	data, err := a.store.get(ctx, name)
+	if err != nil {
+		return fmt.Errorf("cannot read archive %q: %w", name, err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("cannot decompress archive %q: %w", name, err)
+	}
+
+	dec := json.NewDecoder(gz)
+	dec.UseNumber()
+	for {
+		line := new(T)
+		if err := dec.Decode(line); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("cannot decode archive %q: %w", name, err)
+		}
+
+		fn(line)
+	}
+}
+
+// isID reports whether the column of the archived row holds the given ID.
+func isID(row map[string]any, column string, id int64) bool {
+	return fmt.Sprint(row[column]) == strconv.FormatInt(id, 10)
+}
+
+// Decode an archived row into v, e.g., a struct with JSON tags named like the database columns.
+func Decode(row map[string]any, v any) error {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
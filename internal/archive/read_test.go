@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseName(t *testing.T) {
+	t.Parallel()
+
+	written, first, last, ok := parseName("20240102T030405Z-17-42.ndjson.gz")
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), written)
+	assert.Equal(t, int64(17), first)
+	assert.Equal(t, int64(42), last)
+
+	for _, name := range []string{"20240102T030405Z-17-42.ndjson", "20240102T030405Z-17.ndjson.gz", "x-1-2.ndjson.gz"} {
+		_, _, _, ok := parseName(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestArchiver_Read(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	a := &Archiver{store: &dirStore{dir: t.TempDir()}}
+
+	record := func(id int64, message string) any {
+		return &Record{
+			Incident: map[string]any{"id": id},
+			Object:   map[string]any{"name": "example.com"},
+			History:  []map[string]any{{"id": id * 10, "message": message}},
+		}
+	}
+	require.NoError(t, a.write(ctx, "incidents", []int64{1, 2}, []any{record(1, "first"), record(2, "first")}))
+	require.NoError(t, a.write(ctx, "incidents", []int64{2, 3}, []any{record(2, "again"), record(3, "first")}))
+
+	notification := func(id, incidentID int64) any {
+		return map[string]any{"id": id, "incident_id": incidentID, "type": "notified"}
+	}
+	require.NoError(t, a.write(ctx, "notifications", []int64{10, 12},
+		[]any{notification(10, 1), notification(11, 2), notification(12, 1)}))
+
+	t.Run("Incident", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := a.Incident(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, json.Number("2"), r.Incident["id"], "numbers must be kept exactly")
+		assert.Equal(t, "again", r.History[0]["message"], "latest archived record must be returned")
+
+		_, err = a.Incident(ctx, 4)
+		assert.ErrorIs(t, err, ErrNotArchived)
+	})
+
+	t.Run("Notifications", func(t *testing.T) {
+		t.Parallel()
+
+		notifications, err := a.Notifications(ctx, 1, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		require.Len(t, notifications, 2)
+
+		var entry struct {
+			ID   int64  `json:"id"`
+			Type string `json:"type"`
+		}
+		require.NoError(t, Decode(notifications[1], &entry))
+		assert.Equal(t, int64(12), entry.ID)
+		assert.Equal(t, "notified", entry.Type)
+
+		notifications, err = a.Notifications(ctx, 1, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, notifications, "archives written before since must be skipped")
+	})
+}
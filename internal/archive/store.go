@@ -0,0 +1,242 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dirStore writes archives as files into a local directory.
+type dirStore struct {
+	dir string
+}
+
+// list returns the names of all archives starting with the given prefix.
+func (s *dirStore) list(_ context.Context, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasPrefix(entry.Name(), prefix) {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// get reads the archive of the given name.
+func (s *dirStore) get(_ context.Context, name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.dir, name))
+}
+
+// put writes the archive to a temporary file first, so that there are never any incomplete archives.
+func (s *dirStore) put(_ context.Context, name string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(s.dir, ".incidents-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(s.dir, name))
+}
+
+// s3Store uploads archives to an S3-compatible bucket, authenticated by AWS Signature Version 4.
+type s3Store struct {
+	config daemon.S3Config
+	client *http.Client
+}
+
+// put uploads the archive by a single PUT request.
+func (s *s3Store) put(ctx context.Context, name string, data []byte) error {
+	_, err := s.do(ctx, http.MethodPut, name, nil, data)
+	return err
+}
+
+// list returns the keys of all archives starting with the given prefix by ListObjectsV2 requests, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectsV2.html.
+func (s *s3Store) list(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		body, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("cannot decode object list: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// get downloads the archive of the given key by a single GET request.
+func (s *s3Store) get(ctx context.Context, name string) ([]byte, error) {
+	return s.do(ctx, http.MethodGet, name, nil, nil)
+}
+
+// do sends a signed request for the object of the given key, or the bucket itself if empty, returning the response
+// body of a successful request.
+func (s *s3Store) do(ctx context.Context, method, key string, query url.Values, data []byte) ([]byte, error) {
+	u := strings.TrimSuffix(s.config.Endpoint, "/") + "/" + s.config.Bucket + "/" + key
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/gzip")
+	}
+
+	payloadHash := sha256.Sum256(data)
+	signV4(req, hex.EncodeToString(payloadHash[:]), s.config, time.Now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		if res.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %q", os.ErrNotExist, key)
+		}
+		return nil, fmt.Errorf("unexpected status %q: %s", res.Status, bytes.TrimSpace(body))
+	}
+
+	return io.ReadAll(res.Body)
+}
+
+// signV4 signs the request including all its headers by AWS Signature Version 4 for the S3 service, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func signV4(req *http.Request, payloadHash string, c daemon.S3Config, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.EscapedPath()),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), date)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKey, scope, signedHeaders, signature))
+}
+
+// uriEncode encodes the already escaped path as required by the canonical request, i.e., everything except unreserved
+// characters and slashes is percent-encoded exactly once.
+func uriEncode(escapedPath string) string {
+	var b strings.Builder
+	for i := 0; i < len(escapedPath); i++ {
+		c := escapedPath[i]
+		switch {
+		case c == '%' && i+2 < len(escapedPath):
+			// Keep already percent-encoded characters.
+			b.WriteString(strings.ToUpper(escapedPath[i : i+3]))
+			i += 2
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', strings.IndexByte("-_.~/", c) >= 0:
+			b.WriteByte(c)
+		default:
+			_, _ = fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,251 @@
+// Package audit records the manual actions flowing through the daemon, e.g., acknowledgements or subscriptions, with
+// who did what, from where and when, see Entry.
+//
+// In contrast to the incident history, the audit log covers actions not related to any incident as well, e.g., channel
+// tests or configuration reloads, and is never deleted by the daemon.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Actions recorded by the daemon.
+const (
+	ActionAcknowledge = "incident.acknowledge"
+	ActionClose       = "incident.close"
+	ActionSubscribe   = "incident.subscribe"
+	ActionUnsubscribe = "incident.unsubscribe"
+	ActionSnooze      = "incident.snooze"
+	ActionEscalate    = "incident.escalate"
+	ActionMute        = "object.mute"
+	ActionUnmute      = "object.unmute"
+	ActionOptOut      = "contact.opt-out"
+	ActionOptOutEnd   = "contact.opt-out-end"
+	ActionChannelTest = "channel.test"
+	ActionReadOnly    = "daemon.read-only"
+	ActionReload      = "config.reload"
+	ActionImportRules = "config.import-rules"
+	ActionSoftDelete  = "config.soft-delete"
+	ActionRestore     = "config.restore"
+)
+
+// Entry is a single action of the audit log.
+//
+// The actor is prefixed by the kind of it, e.g., "api-token:chatbot" or "contact:jdoe", see the Actor functions. The
+// states before and after the action are arbitrary JSON values, if applicable.
+type Entry struct {
+	ID         int64           `db:"id" json:"id"`
+	Time       types.UnixMilli `db:"time" json:"time"`
+	Action     string          `db:"action" json:"action"`
+	Actor      string          `db:"actor" json:"actor"`
+	RemoteAddr types.String    `db:"remote_addr" json:"remote_addr"`
+	Target     types.String    `db:"target" json:"target"`
+	Before     types.String    `db:"old_state" json:"-"`
+	After      types.String    `db:"new_state" json:"-"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (e *Entry) TableName() string {
+	return "audit_log"
+}
+
+// MarshalJSON implements the json.Marshaler interface, embedding the states as JSON values instead of strings.
+func (e *Entry) MarshalJSON() ([]byte, error) {
+	type entry Entry // without the MarshalJSON method
+	return json.Marshal(struct {
+		*entry
+		Before json.RawMessage `json:"before"`
+		After  json.RawMessage `json:"after"`
+	}{(*entry)(e), rawJSON(e.Before), rawJSON(e.After)})
+}
+
+// rawJSON returns the JSON value stored as string, or null.
+func rawJSON(s types.String) json.RawMessage {
+	if !s.Valid {
+		return json.RawMessage("null")
+	}
+
+	return json.RawMessage(s.String)
+}
+
+// NewEntry creates an Entry of the action at the current time, encoding the states before and after it as JSON. A nil
+// state is stored as NULL, as well as an empty target.
+func NewEntry(action, actor, target string, before, after any) (*Entry, error) {
+	e := &Entry{
+		Time:   types.UnixMilli(time.Now()),
+		Action: action,
+		Actor:  actor,
+		Target: utils.ToDBString(target),
+	}
+
+	for _, state := range []struct {
+		value any
+		field *types.String
+	}{{before, &e.Before}, {after, &e.After}} {
+		if state.value == nil {
+			continue
+		}
+
+		b, err := json.Marshal(state.value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode state of audit log entry: %w", err)
+		}
+		*state.field = utils.ToDBString(string(b))
+	}
+
+	return e, nil
+}
+
+// RemoteIP returns the IP address of a request's remote address, e.g., http.Request.RemoteAddr, or the address itself
+// if it has no port.
+func RemoteIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+
+	return remoteAddr
+}
+
+// ApiTokenActor returns the actor of an action authorized by an API token.
+func ApiTokenActor(name string) string {
+	return "api-token:" + name
+}
+
+// SourceActor returns the actor of an action submitted by a source, e.g., via its listener password.
+func SourceActor(name string) string {
+	return "source:" + name
+}
+
+// ContactActor returns the actor of an action taken by a contact, e.g., via an acknowledgement link.
+func ContactActor(username string) string {
+	return "contact:" + username
+}
+
+// Actors not being a contact, a source or an API token.
+const (
+	// ActorDebugPassword took an action via an endpoint protected by the debug-password.
+	ActorDebugPassword = "debug-password"
+	// ActorSignal took an action by sending SIGHUP to the daemon.
+	ActorSignal = "signal:SIGHUP"
+)
+
+// Target returns the target of an action on a configuration or runtime object, e.g., "incident:42" or "rule:7".
+func Target(kind string, id any) string {
+	return fmt.Sprintf("%s:%v", kind, id)
+}
+
+// Record inserts the entry into the audit log.
+func Record(ctx context.Context, db *database.DB, e *Entry) error {
+	if _, err := db.NamedExecContext(ctx, utils.BuildInsertStmtWithout(db, e, "id"), e); err != nil {
+		return fmt.Errorf("cannot insert audit log entry: %w", err)
+	}
+
+	return nil
+}
+
+// MaxLimit is the maximum number of entries returned by List at once.
+const MaxLimit = 1000
+
+// Filter selects the entries returned by List, newest first.
+type Filter struct {
+	Action string
+	Actor  string
+	Target string
+	Since  time.Time
+	Until  time.Time
+	// BeforeID selects only entries older than the one with this ID, allowing to page through the log.
+	BeforeID int64
+	Limit    int
+}
+
+// ParseFilter parses the query parameters "action", "actor", "target", "since" and "until", the latter two as RFC 3339
+// timestamps, as well as "before_id" and "limit" into a Filter. The limit defaults to 100.
+func ParseFilter(query url.Values) (*Filter, error) {
+	f := &Filter{Action: query.Get("action"), Actor: query.Get("actor"), Target: query.Get("target"), Limit: 100}
+
+	for _, t := range []struct {
+		param string
+		field *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := query.Get(t.param); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an RFC 3339 timestamp, got %q", t.param, v)
+			}
+			*t.field = parsed
+		}
+	}
+
+	if v := query.Get("before_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("before_id must be a positive integer, got %q", v)
+		}
+		f.BeforeID = id
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > MaxLimit {
+			return nil, fmt.Errorf("limit must be an integer between 1 and %d, got %q", MaxLimit, v)
+		}
+		f.Limit = limit
+	}
+
+	return f, nil
+}
+
+// List returns the entries selected by the filter, newest first.
+func List(ctx context.Context, db *database.DB, f *Filter) ([]*Entry, error) {
+	if f.Limit <= 0 || f.Limit > MaxLimit {
+		return nil, errors.New("invalid audit log limit")
+	}
+
+	var conditions []string
+	var args []any
+	for _, c := range []struct{ column, value string }{
+		{"action", f.Action}, {"actor", f.Actor}, {"target", f.Target},
+	} {
+		if c.value != "" {
+			conditions = append(conditions, fmt.Sprintf(`"%s" = ?`, c.column))
+			args = append(args, c.value)
+		}
+	}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, `"time" >= ?`)
+		args = append(args, types.UnixMilli(f.Since))
+	}
+	if !f.Until.IsZero() {
+		conditions = append(conditions, `"time" < ?`)
+		args = append(args, types.UnixMilli(f.Until))
+	}
+	if f.BeforeID > 0 {
+		conditions = append(conditions, `"id" < ?`)
+		args = append(args, f.BeforeID)
+	}
+
+	stmt := db.BuildSelectStmt(new(Entry), new(Entry))
+	if len(conditions) > 0 {
+		stmt += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	stmt += fmt.Sprintf(` ORDER BY "id" DESC LIMIT %d`, f.Limit)
+
+	entries := []*Entry{}
+	if err := sqlx.SelectContext(ctx, db, &entries, db.Rebind(stmt), args...); err != nil {
+		return nil, fmt.Errorf("cannot select audit log entries: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNewEntry(t *testing.T) {
+	t.Parallel()
+
+	t.Run("States", func(t *testing.T) {
+		t.Parallel()
+
+		e, err := NewEntry(ActionReadOnly, ActorDebugPassword, "", map[string]bool{"read_only": false},
+			map[string]bool{"read_only": true})
+		require.NoError(t, err)
+
+		assert.False(t, e.Target.Valid, "empty target must be NULL")
+		assert.Equal(t, `{"read_only":false}`, e.Before.String)
+		assert.Equal(t, `{"read_only":true}`, e.After.String)
+
+		b, err := json.Marshal(e)
+		require.NoError(t, err)
+
+		var decoded map[string]any
+		require.NoError(t, json.Unmarshal(b, &decoded))
+		assert.Equal(t, "daemon.read-only", decoded["action"])
+		assert.Equal(t, "debug-password", decoded["actor"])
+		assert.Equal(t, map[string]any{"read_only": false}, decoded["before"], "states must be embedded as JSON")
+		assert.Equal(t, map[string]any{"read_only": true}, decoded["after"], "states must be embedded as JSON")
+	})
+
+	t.Run("WithoutStates", func(t *testing.T) {
+		t.Parallel()
+
+		e, err := NewEntry(ActionClose, ApiTokenActor("chatbot"), Target("incident", 42), nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "api-token:chatbot", e.Actor)
+		assert.Equal(t, "incident:42", e.Target.String)
+		assert.False(t, e.Before.Valid)
+		assert.False(t, e.After.Valid)
+
+		b, err := json.Marshal(e)
+		require.NoError(t, err)
+		assert.Contains(t, string(b), `"before":null`)
+		assert.Contains(t, string(b), `"after":null`)
+	})
+
+	t.Run("InvalidState", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewEntry(ActionReload, ActorSignal, "", nil, make(chan int))
+		assert.Error(t, err)
+	})
+}
+
+func TestRemoteIP(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "192.0.2.1", RemoteIP("192.0.2.1:5680"))
+	assert.Equal(t, "2001:db8::1", RemoteIP("[2001:db8::1]:5680"))
+	assert.Equal(t, "@", RemoteIP("@"), "addresses without port must be kept, e.g., of Unix sockets")
+}
+
+func TestParseFilter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Defaults", func(t *testing.T) {
+		t.Parallel()
+
+		f, err := ParseFilter(url.Values{})
+		require.NoError(t, err)
+		assert.Equal(t, &Filter{Limit: 100}, f)
+	})
+
+	t.Run("All", func(t *testing.T) {
+		t.Parallel()
+
+		f, err := ParseFilter(url.Values{
+			"action":    {ActionAcknowledge},
+			"actor":     {"contact:jdoe"},
+			"target":    {"incident:42"},
+			"since":     {"2024-01-01T00:00:00Z"},
+			"until":     {"2024-02-01T00:00:00+01:00"},
+			"before_id": {"23"},
+			"limit":     {"10"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, ActionAcknowledge, f.Action)
+		assert.Equal(t, "contact:jdoe", f.Actor)
+		assert.Equal(t, "incident:42", f.Target)
+		assert.True(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Equal(f.Since))
+		assert.True(t, time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC).Equal(f.Until))
+		assert.Equal(t, int64(23), f.BeforeID)
+		assert.Equal(t, 10, f.Limit)
+	})
+
+	for name, query := range map[string]url.Values{
+		"InvalidSince":    {"since": {"yesterday"}},
+		"InvalidUntil":    {"until": {"1700000000"}},
+		"InvalidBeforeID": {"before_id": {"-1"}},
+		"ZeroLimit":       {"limit": {"0"}},
+		"ExceedingLimit":  {"limit": {"1001"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseFilter(query)
+			assert.Error(t, err)
+		})
+	}
+}
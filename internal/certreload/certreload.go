@@ -0,0 +1,131 @@
+// Package certreload serves a TLS certificate loaded from files, reloading it once the files change, e.g., after a
+// renewal, without restarting the server.
+package certreload
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/icinga/icinga-go-library/logging"
+	"go.uber.org/zap"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Reloader holds a certificate and its private key loaded from PEM-encoded files.
+//
+// Its GetCertificate method is meant to be used as the tls.Config's GetCertificate callback. Thus, each TLS handshake
+// uses the certificate loaded most recently.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	logger   *logging.Logger
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	// modTimes of the certificate and key file when they were loaded.
+	modTimes [2]time.Time
+}
+
+// New creates a Reloader and loads the certificate initially, returning an error if it cannot be loaded.
+func New(certFile, keyFile string, logger *logging.Logger) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, implementing the tls.Config's GetCertificate callback.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// Reload loads the certificate and its key from the files. If this fails, e.g., as the files are not both updated yet
+// or do not match, the error is returned and the current certificate is kept.
+func (r *Reloader) Reload() error {
+	modTimes := r.statFiles()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	// Depending on the Go version, the leaf is not parsed already.
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.modTimes = modTimes
+	r.logger.Infow("Loaded TLS certificate", zap.String("subject", cert.Leaf.Subject.String()),
+		zap.Time("not_after", cert.Leaf.NotAfter))
+
+	return nil
+}
+
+// statFiles returns the current modification times of the certificate and key file, being zero for missing files.
+func (r *Reloader) statFiles() (modTimes [2]time.Time) {
+	for i, file := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[i] = info.ModTime()
+		}
+	}
+
+	return
+}
+
+// changed returns whether either file was modified since the certificate was loaded.
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.modTimes != r.statFiles()
+}
+
+// Run reloads the certificate on each SIGHUP and, with a positive interval, whenever its files were modified, checked
+// once per interval, until the context is done. Failing reloads are logged, keeping the current certificate.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	reload := func() {
+		if err := r.Reload(); err != nil {
+			r.logger.Errorw("Cannot reload TLS certificate, keeping the current one", zap.String("cert", r.certFile),
+				zap.String("key", r.keyFile), zap.Error(err))
+		}
+	}
+
+	for {
+		select {
+		case <-sighup:
+			r.logger.Info("Received SIGHUP, reloading the TLS certificate")
+			reload()
+		case <-tick:
+			if r.changed() {
+				reload()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
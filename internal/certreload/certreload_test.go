@@ -0,0 +1,76 @@
+package certreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloader(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "listener.crt")
+	keyFile := filepath.Join(dir, "listener.key")
+
+	// writeCert creates a self-signed certificate for the common name and writes it and its key to the files.
+	writeCert := func(commonName string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(time.Now().UnixNano()),
+			Subject:      pkix.Name{CommonName: commonName},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		require.NoError(t, err)
+		keyDer, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+		require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	}
+
+	commonName := func(r *Reloader) string {
+		cert, err := r.GetCertificate(nil)
+		require.NoError(t, err)
+		return cert.Leaf.Subject.CommonName
+	}
+
+	logger := logging.NewLogger(zaptest.NewLogger(t).Sugar(), time.Hour)
+
+	_, err := New(certFile, keyFile, logger)
+	assert.Error(t, err, "missing files should fail")
+
+	writeCert("first")
+	r, err := New(certFile, keyFile, logger)
+	require.NoError(t, err)
+	assert.Equal(t, "first", commonName(r))
+	assert.False(t, r.changed())
+
+	writeCert("second")
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	assert.True(t, r.changed())
+	require.NoError(t, r.Reload())
+	assert.Equal(t, "second", commonName(r))
+	assert.False(t, r.changed())
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600))
+	assert.Error(t, r.Reload())
+	assert.Equal(t, "second", commonName(r), "failed reload should keep the current certificate")
+}
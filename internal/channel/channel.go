@@ -2,20 +2,27 @@ package channel
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/icinga/icinga-go-library/backoff"
 	"github.com/icinga/icinga-notifications/internal/config/baseconf"
 	"github.com/icinga/icinga-notifications/internal/contracts"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/errs"
 	"github.com/icinga/icinga-notifications/internal/event"
 	"github.com/icinga/icinga-notifications/internal/recipient"
 	"github.com/icinga/icinga-notifications/pkg/plugin"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"net/url"
+	"sync"
+	"time"
 )
 
 type Channel struct {
 	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+	baseconf.TenantEntry                 `db:",inline"`
 
 	Name   string `db:"name"`
 	Type   string `db:"type"`
@@ -24,10 +31,17 @@ type Channel struct {
 	Logger *zap.SugaredLogger `db:"-"`
 
 	restartCh chan newConfig
+	rescanCh  chan struct{}
 	pluginCh  chan *Plugin
 
 	pluginCtx       context.Context
 	pluginCtxCancel func()
+
+	// scheduler limits the concurrent sends to the plugin, see daemon.ChannelConcurrencyConfig.
+	scheduler *scheduler
+
+	statusMu sync.Mutex
+	status   *Status
 }
 
 // MarshalLogObject implements the zapcore.ObjectMarshaler interface.
@@ -53,8 +67,10 @@ type newConfig struct {
 func (c *Channel) Start(ctx context.Context, logger *zap.SugaredLogger) {
 	c.Logger = logger
 	c.restartCh = make(chan newConfig)
+	c.rescanCh = make(chan struct{})
 	c.pluginCh = make(chan *Plugin)
 	c.pluginCtx, c.pluginCtxCancel = context.WithCancel(ctx)
+	c.scheduler = newScheduler(daemon.Config().ChannelConcurrency.MaxConcurrentSends)
 
 	go c.runPlugin(c.Type, c.Config)
 }
@@ -80,10 +96,34 @@ func (c *Channel) initPlugin(cType string, config string) *Plugin {
 	return p
 }
 
+// restartBackoff delays starting a plugin again after it could not be started or crashed before pluginStableAfter.
+var restartBackoff = backoff.NewExponentialWithJitter(time.Second, time.Minute)
+
+// pluginStableAfter is how long a plugin must have been running for a crash not to delay its restart.
+const pluginStableAfter = time.Minute
+
 // runPlugin is called as go routine to initialize and maintain the plugin by receiving signals on given chan(s)
+//
+// A crashed plugin is restarted, including its config. Plugins failing repeatedly are restarted with an increasing
+// delay, see restartBackoff, during which getPlugin returns nil.
 func (c *Channel) runPlugin(initType string, initConfig string) {
 	var currentlyRunningPlugin *Plugin
+	var startedAt time.Time
 	cType, config := initType, initConfig
+
+	// failures counts the consecutive failed starts and early crashes, while delayed is set during the delay.
+	var failures uint64
+	var delayed <-chan time.Time
+	delayRestart := func() {
+		delay := restartBackoff(failures)
+		failures++
+		delayed = time.After(delay)
+		c.Logger.Infow("Delaying restart of channel plugin", zap.Duration("delay", delay), zap.Uint64("failures", failures))
+	}
+	resetBackoff := func() {
+		failures, delayed = 0, nil
+	}
+
 	// Helper function for the following loop to stop a running plugin. Does nothing if no plugin is running.
 	stopIfRunning := func() (int, bool) {
 		if currentlyRunningPlugin != nil {
@@ -96,6 +136,13 @@ func (c *Channel) runPlugin(initType string, initConfig string) {
 		return 0, false
 	}
 
+	var healthCheck <-chan time.Time
+	if conf := daemon.Config().ChannelHealthCheck; conf.Interval > 0 {
+		ticker := time.NewTicker(conf.Interval)
+		defer ticker.Stop()
+		healthCheck = ticker.C
+	}
+
 	// Helper function for the following loop to receive from rpc.Done
 	rpcDone := func() <-chan struct{} {
 		if currentlyRunningPlugin != nil {
@@ -106,20 +153,49 @@ func (c *Channel) runPlugin(initType string, initConfig string) {
 	}
 
 	for {
-		if currentlyRunningPlugin == nil {
+		// Checked before each select rather than only on rpcDone, so that a crashed plugin is never handed out.
+		if currentlyRunningPlugin != nil && currentlyRunningPlugin.rpc.Err() != nil {
+			pid, _ := stopIfRunning()
+			c.Logger.Warnw("Channel plugin crashed", zap.Int("pid", pid))
+
+			if time.Since(startedAt) < pluginStableAfter {
+				delayRestart()
+			} else {
+				resetBackoff()
+			}
+		}
+
+		if currentlyRunningPlugin == nil && delayed == nil {
 			currentlyRunningPlugin = c.initPlugin(cType, config)
+			if currentlyRunningPlugin == nil {
+				delayRestart()
+			} else {
+				startedAt = time.Now()
+			}
 		}
 
 		select {
 		case <-rpcDone():
-			if pid, stopped := stopIfRunning(); stopped {
-				c.Logger.Warnw("Channel plugin crashed", zap.Int("pid", pid))
-			}
+			continue
+		case <-delayed:
+			delayed = nil
 
 			continue
 		case reload := <-c.restartCh:
 			cType, config = reload.ctype, reload.config
 			stopIfRunning()
+			resetBackoff()
+
+			continue
+		case <-c.rescanCh:
+			if currentlyRunningPlugin == nil {
+				// The plugin might have been installed in the meantime, thus try again immediately.
+				resetBackoff()
+			} else if currentlyRunningPlugin.updated() {
+				pid, _ := stopIfRunning()
+				c.Logger.Infow("Channel plugin executable was updated, restarting the plugin", zap.Int("pid", pid))
+				resetBackoff()
+			}
 
 			continue
 		case <-c.pluginCtx.Done():
@@ -128,6 +204,8 @@ func (c *Channel) runPlugin(initType string, initConfig string) {
 			}
 
 			return
+		case <-healthCheck:
+			go c.checkHealth(currentlyRunningPlugin, daemon.Config().ChannelHealthCheck.Timeout)
 		case c.pluginCh <- currentlyRunningPlugin:
 		}
 	}
@@ -158,14 +236,28 @@ func (c *Channel) Restart() {
 	c.restartCh <- newConfig{c.Type, c.Config}
 }
 
-// Notify prepares and sends the notification request, returns a non-error on fails, nil on success
-func (c *Channel) Notify(contact *recipient.Contact, i contracts.Incident, ev *event.Event, icingaweb2Url string) error {
-	p := c.getPlugin()
-	if p == nil {
-		return errors.New("plugin could not be started")
+// Rescan signals to restart the channel plugin if its executable was updated since it was started, or to start it
+// right away if it could not be started so far, e.g., as it was not installed yet.
+func (c *Channel) Rescan() {
+	select {
+	case c.rescanCh <- struct{}{}:
+	case <-c.pluginCtx.Done():
 	}
+}
 
-	contactStruct := &plugin.Contact{FullName: contact.FullName}
+// NewNotificationRequest prepares the notification request for the given contact about an event of the incident.
+//
+// The optional ackUrl allows the contact to acknowledge the incident directly from the notification, see acklink.
+// Subject and Message are left empty to be rendered by the caller, see msgtemplate.
+func NewNotificationRequest(
+	contact *recipient.Contact,
+	reasons []*plugin.Reason,
+	i contracts.Incident,
+	ev *event.Event,
+	icingaweb2Url string,
+	ackUrl string,
+) *plugin.NotificationRequest {
+	contactStruct := &plugin.Contact{Id: contact.ID, FullName: contact.FullName}
 	for _, addr := range contact.Addresses {
 		contactStruct.Addresses = append(contactStruct.Addresses, &plugin.Address{Type: addr.Type, Address: addr.Address})
 	}
@@ -175,7 +267,7 @@ func (c *Channel) Notify(contact *recipient.Contact, i contracts.Incident, ev *e
 	incidentUrl.RawQuery = fmt.Sprintf("id=%d", i.ID())
 	object := i.IncidentObject()
 
-	req := &plugin.NotificationRequest{
+	return &plugin.NotificationRequest{
 		Contact: contactStruct,
 		Object: &plugin.Object{
 			Name:      object.DisplayName(),
@@ -189,12 +281,123 @@ func (c *Channel) Notify(contact *recipient.Contact, i contracts.Incident, ev *e
 			Severity: i.SeverityString(),
 		},
 		Event: &plugin.Event{
-			Time:     ev.Time,
-			Type:     ev.Type,
-			Username: ev.Username,
-			Message:  ev.Message,
+			Time:           ev.Time,
+			Type:           ev.Type,
+			Username:       ev.Username,
+			Message:        ev.Message,
+			TraceID:        ev.TraceID,
+			SeverityChange: ev.SeverityChange,
+		},
+		Reasons:        reasons,
+		AcknowledgeUrl: ackUrl,
+	}
+}
+
+// TestObjectName is the name of the object in test notifications, see NewTestNotificationRequest.
+const TestObjectName = "icinga-notifications-test"
+
+// NewTestNotificationRequest prepares a synthetic notification request for the given contact, allowing to verify a
+// channel's config end-to-end without a real incident. As there is no incident, its ID is zero.
+//
+// Subject and Message are left empty to be rendered by the caller, see msgtemplate.
+func NewTestNotificationRequest(contact *recipient.Contact, icingaweb2Url string, now time.Time) *plugin.NotificationRequest {
+	return newSyntheticNotificationRequest(contact, icingaweb2Url, now, TestObjectName,
+		"This is a test notification to verify the channel configuration. No action is required.")
+}
+
+// DigestObjectName is the name of the object in digests of throttled notifications, see NewDigestNotificationRequest.
+const DigestObjectName = "icinga-notifications-digest"
+
+// NewDigestNotificationRequest prepares a notification request for the given contact summarizing notifications of
+// several incidents, e.g., those throttled during a mass outage. As there is no single incident, its ID is zero.
+//
+// Subject and Message are left empty to be rendered by the caller, see msgtemplate.
+func NewDigestNotificationRequest(
+	contact *recipient.Contact, icingaweb2Url string, now time.Time, message string,
+) *plugin.NotificationRequest {
+	return newSyntheticNotificationRequest(contact, icingaweb2Url, now, DigestObjectName, message)
+}
+
+// newSyntheticNotificationRequest prepares a custom notification request for the given contact not belonging to any
+// incident, using an object of the given name.
+func newSyntheticNotificationRequest(
+	contact *recipient.Contact, icingaweb2Url string, now time.Time, objectName, message string,
+) *plugin.NotificationRequest {
+	contactStruct := &plugin.Contact{Id: contact.ID, FullName: contact.FullName}
+	for _, addr := range contact.Addresses {
+		contactStruct.Addresses = append(contactStruct.Addresses, &plugin.Address{Type: addr.Type, Address: addr.Address})
+	}
+
+	baseUrl, _ := url.Parse(icingaweb2Url)
+
+	return &plugin.NotificationRequest{
+		Contact: contactStruct,
+		Object: &plugin.Object{
+			Name: objectName,
+			Url:  baseUrl.String(),
+			Tags: map[string]string{"host": objectName},
+		},
+		Incident: &plugin.Incident{
+			Url:      baseUrl.JoinPath("/notifications/incidents").String(),
+			Severity: "ok",
+		},
+		Event: &plugin.Event{
+			Time:    now,
+			Type:    event.TypeCustom,
+			Message: message,
 		},
 	}
+}
+
+// Notify sends the notification request, returns a non-error on fails, nil on success
+//
+// On success, the incident's new state reported by the plugin is returned, if any, see plugin.StatefulNotifier.
+func (c *Channel) Notify(req *plugin.NotificationRequest) (json.RawMessage, error) {
+	return c.send(req, (*Plugin).SendNotification)
+}
+
+// NotifyTest sends the test notification request, see NewTestNotificationRequest.
+func (c *Channel) NotifyTest(req *plugin.NotificationRequest) error {
+	_, err := c.send(req, func(p *Plugin, req *plugin.NotificationRequest) (json.RawMessage, error) {
+		return nil, p.SendTestNotification(req)
+	})
+
+	return err
+}
+
+// send passes the request to the given method of the current plugin.
+//
+// If the plugin terminates before responding, e.g., as it crashed, the request is replayed once to the restarted
+// plugin. Thus, a notification may be sent twice if the plugin crashed after delivering it.
+//
+// Once the channel's limit of concurrent sends is reached, the request waits for its contact's turn, see scheduler.
+func (c *Channel) send(
+	req *plugin.NotificationRequest, method func(*Plugin, *plugin.NotificationRequest) (json.RawMessage, error),
+) (json.RawMessage, error) {
+	if c.scheduler != nil {
+		if err := c.scheduler.acquire(c.pluginCtx, req.Contact.Id); err != nil {
+			return nil, fmt.Errorf("channel was stopped while waiting to send the notification: %w", err)
+		}
+		defer c.scheduler.release()
+	}
+
+	p := c.getPlugin()
+	if p == nil {
+		return nil, errors.New("plugin could not be started")
+	}
+
+	state, err := method(p, req)
+	if err == nil || errors.Is(err, errs.ErrChannelPermanent) || p.rpc.Err() == nil {
+		return state, err
+	}
+
+	c.Logger.Warnw("Channel plugin terminated while sending a notification, replaying it to the restarted plugin",
+		zap.Error(err))
+
+	restarted := c.getPlugin()
+	if restarted == nil || restarted == p {
+		return nil, fmt.Errorf("plugin could not be restarted: %w", err)
+	}
 
-	return p.SendNotification(req)
+	return method(restarted, req)
 }
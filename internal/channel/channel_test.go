@@ -0,0 +1,160 @@
+package channel
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"github.com/icinga/icinga-notifications/pkg/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewTestNotificationRequest(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	contact := &recipient.Contact{
+		FullName:  "Jane Doe",
+		Addresses: []*recipient.Address{{Type: "email", Address: "jdoe@example.com"}},
+	}
+	contact.ID = 42
+
+	req := NewTestNotificationRequest(contact, "https://example.com/icingaweb2/", now)
+	assert.Equal(t, &plugin.Contact{
+		Id:        42,
+		FullName:  "Jane Doe",
+		Addresses: []*plugin.Address{{Type: "email", Address: "jdoe@example.com"}},
+	}, req.Contact)
+	assert.Equal(t, TestObjectName, req.Object.Name)
+	assert.Equal(t, "https://example.com/icingaweb2/notifications/incidents", req.Incident.Url)
+	assert.Equal(t, event.TypeCustom, req.Event.Type)
+	assert.Equal(t, now, req.Event.Time)
+}
+
+func TestPlugin_SendTestNotification(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Supported", func(t *testing.T) {
+		t.Parallel()
+
+		var methods []string
+		p := fakePlugin(t, func(req *rpc.Request) (rpc.Response, bool) {
+			methods = append(methods, req.Method)
+			return rpc.Response{}, true
+		})
+
+		require.NoError(t, p.SendTestNotification(&plugin.NotificationRequest{}))
+		assert.Equal(t, []string{plugin.MethodSendTestNotification}, methods)
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		t.Parallel()
+
+		var methods []string
+		p := fakePlugin(t, func(req *rpc.Request) (rpc.Response, bool) {
+			methods = append(methods, req.Method)
+			if req.Method != plugin.MethodSendNotification {
+				return rpc.Response{Error: "unknown method: " + `"` + req.Method + `"`}, true
+			}
+
+			var nr plugin.NotificationRequest
+			assert.NoError(t, json.Unmarshal(req.Params, &nr))
+			assert.Equal(t, "test", nr.Subject)
+			return rpc.Response{}, true
+		})
+
+		require.NoError(t, p.SendTestNotification(&plugin.NotificationRequest{Subject: "test"}))
+		assert.Equal(t, []string{plugin.MethodSendTestNotification, plugin.MethodSendNotification}, methods)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		t.Parallel()
+
+		p := fakePlugin(t, func(*rpc.Request) (rpc.Response, bool) {
+			return rpc.Response{Error: "authentication failed"}, true
+		})
+
+		assert.ErrorContains(t, p.SendTestNotification(&plugin.NotificationRequest{}), "authentication failed")
+	})
+}
+
+// crashingPlugin returns a Plugin whose remote end terminates upon receiving the first request.
+func crashingPlugin(t *testing.T) *Plugin {
+	reqReader, reqWriter := io.Pipe()
+	resReader, resWriter := io.Pipe()
+
+	go func() {
+		_ = json.NewDecoder(reqReader).Decode(&rpc.Request{})
+		_ = resWriter.CloseWithError(errors.New("plugin crashed"))
+		_ = reqReader.Close()
+	}()
+
+	logger := zaptest.NewLogger(t).Sugar()
+	return &Plugin{rpc: rpc.NewRPC(reqWriter, resReader, logger), logger: logger}
+}
+
+func TestChannel_Notify(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ReplayAfterCrash", func(t *testing.T) {
+		t.Parallel()
+
+		var subjects []string
+		restarted := fakePlugin(t, func(req *rpc.Request) (rpc.Response, bool) {
+			var nr plugin.NotificationRequest
+			assert.NoError(t, json.Unmarshal(req.Params, &nr))
+			subjects = append(subjects, nr.Subject)
+			return rpc.Response{Result: json.RawMessage(`{"state": {"key": "OPS-1"}}`)}, true
+		})
+
+		c := &Channel{Logger: zaptest.NewLogger(t).Sugar(), pluginCh: make(chan *Plugin)}
+		go func() {
+			c.pluginCh <- crashingPlugin(t)
+			c.pluginCh <- restarted
+		}()
+
+		state, err := c.Notify(&plugin.NotificationRequest{Subject: "test"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"test"}, subjects)
+		assert.JSONEq(t, `{"key": "OPS-1"}`, string(state))
+	})
+
+	t.Run("NoReplayOnFailure", func(t *testing.T) {
+		t.Parallel()
+
+		c := &Channel{Logger: zaptest.NewLogger(t).Sugar(), pluginCh: make(chan *Plugin, 1)}
+		c.pluginCh <- fakePlugin(t, func(*rpc.Request) (rpc.Response, bool) {
+			return rpc.Response{Error: "authentication failed"}, true
+		})
+
+		// A replay would block on receiving another plugin.
+		_, err := c.Notify(&plugin.NotificationRequest{})
+		assert.ErrorContains(t, err, "authentication failed")
+	})
+}
+
+func TestPlugin_Updated(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "webhook")
+	require.NoError(t, os.WriteFile(file, []byte("#!/bin/sh"), 0o700))
+	stat, err := os.Stat(file)
+	require.NoError(t, err)
+
+	p := &Plugin{path: file, modTime: stat.ModTime()}
+	assert.False(t, p.updated())
+
+	require.NoError(t, os.Chtimes(file, time.Now(), stat.ModTime().Add(time.Minute)))
+	assert.True(t, p.updated())
+
+	require.NoError(t, os.Remove(file))
+	assert.True(t, p.updated())
+}
@@ -0,0 +1,75 @@
+package channel
+
+import (
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"go.uber.org/zap"
+	"os"
+	"sync"
+	"time"
+)
+
+// discovered holds the result of the latest Discover call.
+var discovered struct {
+	sync.Mutex
+	plugins []*plugin.Info
+	at      time.Time
+}
+
+// Discover scans the channels directory for working plugins, starting each once to query its Info.
+//
+// The result is kept in memory, allowing to serve it without database access, see Discovered.
+func Discover(channelPluginDir string, logger *logging.Logger) []*plugin.Info {
+	files, err := os.ReadDir(channelPluginDir)
+	if err != nil {
+		logger.Errorw("Failed to read the channel plugin directory", zap.Error(err))
+	}
+
+	var pluginInfos []*plugin.Info
+	for _, file := range files {
+		pluginType := file.Name()
+		pluginLogger := logger.With(zap.String("type", pluginType))
+		if err := ValidateType(pluginType); err != nil {
+			pluginLogger.Warnw("Ignoring plugin", zap.Error(err))
+			continue
+		}
+
+		p, err := NewPlugin(pluginType, pluginLogger)
+		if err != nil {
+			pluginLogger.Errorw("Failed to start plugin", zap.Error(err))
+			continue
+		}
+
+		info, err := p.GetInfo()
+		if err != nil {
+			p.logger.Error(err)
+			p.Stop()
+			continue
+		}
+		p.Stop()
+		info.Type = pluginType
+
+		pluginInfos = append(pluginInfos, info)
+	}
+
+	setDiscovered(pluginInfos, time.Now())
+
+	return pluginInfos
+}
+
+// Discovered returns the plugins found by the latest Discover call, ordered by their type, together with the time of
+// that call. The time is zero if Discover was not called yet.
+func Discovered() ([]*plugin.Info, time.Time) {
+	discovered.Lock()
+	defer discovered.Unlock()
+
+	return append([]*plugin.Info(nil), discovered.plugins...), discovered.at
+}
+
+// setDiscovered replaces the result of the latest Discover call.
+func setDiscovered(plugins []*plugin.Info, at time.Time) {
+	discovered.Lock()
+	defer discovered.Unlock()
+
+	discovered.plugins, discovered.at = plugins, at
+}
@@ -0,0 +1,36 @@
+package channel
+
+import (
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiscover(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid-type"), nil, 0o700))
+
+	before := time.Now()
+	assert.Empty(t, Discover(dir, logging.NewLogger(zaptest.NewLogger(t).Sugar(), time.Hour)))
+
+	plugins, discoveredAt := Discovered()
+	assert.Empty(t, plugins)
+	assert.False(t, discoveredAt.Before(before))
+
+	setDiscovered([]*plugin.Info{{Type: "email"}}, before)
+	plugins, discoveredAt = Discovered()
+	require.Len(t, plugins, 1)
+	assert.Equal(t, before, discoveredAt)
+
+	plugins[0] = nil
+	plugins, _ = Discovered()
+	assert.Equal(t, "email", plugins[0].Type, "Discovered must return a copy")
+}
@@ -0,0 +1,249 @@
+package channel
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	pluginv1 "github.com/icinga/icinga-notifications/pkg/plugin/proto/v1"
+	"github.com/icinga/icinga-notifications/pkg/rpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"io"
+	"time"
+)
+
+// handshakeTimeout limits how long NewPlugin waits for a started plugin to reveal its protocol, see negotiate.
+const handshakeTimeout = 10 * time.Second
+
+// transport of the requests to a plugin process, being either *rpc.RPC for the JSON-RPC protocol on stdin and stdout
+// or *grpcTransport.
+type transport interface {
+	Call(method string, params json.RawMessage) (json.RawMessage, error)
+	Done() <-chan struct{}
+	Err() error
+	Close() error
+}
+
+// negotiate the protocol spoken by a just started plugin by sending a JSON-RPC GetInfo request.
+//
+// A JSON-RPC plugin responds to it, while a plugin serving gRPC writes its plugin.Handshake first, ignoring stdin.
+func negotiate(stdin io.WriteCloser, stdout io.Reader, socket string, logger *zap.SugaredLogger) (transport, error) {
+	probe, err := json.Marshal(rpc.Request{Method: plugin.MethodGetInfo})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stdin.Write(append(probe, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to write request: %w", err)
+	}
+
+	dec := json.NewDecoder(stdout)
+	var first plugin.Handshake
+	errCh := make(chan error, 1)
+	go func() { errCh <- dec.Decode(&first) }()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return nil, fmt.Errorf("failed to read first response: %w", err)
+		}
+	case <-time.After(handshakeTimeout):
+		return nil, fmt.Errorf("plugin did not respond within %v", handshakeTimeout)
+	}
+
+	// Continue reading after the first value, which may already be buffered together with further output.
+	rest := io.MultiReader(dec.Buffered(), stdout)
+
+	switch first {
+	case plugin.Handshake{}:
+		// The response to the probe, which carries an ID not used by rpc.RPC and is thus not expected by it.
+		return rpc.NewRPC(stdin, rest, logger), nil
+	case plugin.GRPCHandshake:
+		return newGRPCTransport(stdin, rest, socket, logger)
+	default:
+		return nil, fmt.Errorf("unsupported plugin protocol %q of version %q", first.Protocol, first.Version)
+	}
+}
+
+// errPluginTerminated is returned by grpcTransport once the plugin closed its stdout, usually by terminating.
+var errPluginTerminated = errors.New("plugin terminated")
+
+// grpcTransport calls the methods of a plugin serving pluginv1.ChannelPluginServer, see plugin.ServeGRPC.
+//
+// Like for JSON-RPC, failures reported by the plugin are returned as *rpc.ResponseError.
+type grpcTransport struct {
+	stdin  io.Closer
+	conn   *grpc.ClientConn
+	client pluginv1.ChannelPluginClient
+	logger *zap.SugaredLogger
+
+	done chan struct{} // closed by watch once the plugin closed its stdout
+}
+
+func newGRPCTransport(stdin io.WriteCloser, stdout io.Reader, socket string, logger *zap.SugaredLogger) (*grpcTransport, error) {
+	conn, err := grpc.NewClient("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC client: %w", err)
+	}
+
+	t := &grpcTransport{
+		stdin:  stdin,
+		conn:   conn,
+		client: pluginv1.NewChannelPluginClient(conn),
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+	go t.watch(stdout)
+
+	return t, nil
+}
+
+// watch logs anything the plugin writes to stdout after its handshake and closes the transport when stdout is closed.
+func (t *grpcTransport) watch(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		t.logger.Warnw("Ignoring unexpected output of gRPC channel plugin", zap.String("line", scanner.Text()))
+	}
+
+	close(t.done)
+	_ = t.conn.Close()
+}
+
+func (t *grpcTransport) Call(method string, params json.RawMessage) (json.RawMessage, error) {
+	if err := t.Err(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-t.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var result any
+	var err error
+	switch method {
+	case plugin.MethodGetInfo:
+		var res *pluginv1.GetInfoResponse
+		if res, err = t.client.GetInfo(ctx, &pluginv1.GetInfoRequest{}); err == nil {
+			if result, err = plugin.InfoFromProto(res); err != nil {
+				return nil, &rpc.ResponseError{Message: err.Error()}
+			}
+		}
+	case plugin.MethodSetConfig:
+		_, err = t.client.SetConfig(ctx, &pluginv1.SetConfigRequest{ConfigJson: string(params)})
+	case plugin.MethodSendNotification, plugin.MethodSendTestNotification:
+		var state json.RawMessage
+		if state, err = t.sendNotification(ctx, params, method == plugin.MethodSendTestNotification); state != nil {
+			result = plugin.NotificationResult{State: state}
+		}
+	case plugin.MethodHealthCheck:
+		_, err = t.client.HealthCheck(ctx, &pluginv1.HealthCheckRequest{})
+	default:
+		err = status.Error(codes.Unimplemented, "")
+	}
+	if err != nil {
+		return nil, t.responseError(method, err)
+	}
+
+	if result == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(result)
+}
+
+// sendNotification sends the notification and waits for it to be delivered, logging the progress the plugin reports.
+//
+// Returns the new state of the incident reported together with the delivery, if any, see plugin.StatefulNotifier.
+func (t *grpcTransport) sendNotification(ctx context.Context, params json.RawMessage, test bool) (json.RawMessage, error) {
+	var req plugin.NotificationRequest
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, err
+	}
+
+	logger := t.logger
+	if req.Event != nil && req.Event.TraceID != "" {
+		logger = logger.With(zap.String("trace_id", req.Event.TraceID))
+	}
+
+	stream, err := t.client.SendNotification(ctx, &pluginv1.SendNotificationRequest{
+		Request: plugin.NotificationRequestToProto(&req),
+		Test:    test,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		st, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil, &rpc.ResponseError{Message: "plugin ended the delivery without reporting its result"}
+		} else if err != nil {
+			return nil, err
+		}
+
+		if st.GetState() == pluginv1.DeliveryStatus_STATE_DELIVERED {
+			if state := st.GetStateJson(); state != "" {
+				return json.RawMessage(state), nil
+			}
+
+			return nil, nil
+		}
+
+		logger.Infow("Channel plugin reported delivery progress", zap.String("message", st.GetMessage()))
+	}
+}
+
+// responseError converts a gRPC error into the errors returned by rpc.RPC.Call.
+//
+// Statuses reported by the plugin become an *rpc.ResponseError, with unimplemented methods being reported as unknown
+// like by plugin.RunPlugin. Failures to reach the plugin are returned as they are and may thus be retried.
+func (t *grpcTransport) responseError(method string, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	switch st.Code() {
+	case codes.Unimplemented:
+		return &rpc.ResponseError{Message: fmt.Sprintf("unknown method: %q", method)}
+	case codes.Unavailable, codes.Canceled:
+		if terminated := t.Err(); terminated != nil {
+			return fmt.Errorf("%w: %w", terminated, err)
+		}
+
+		return err
+	default:
+		return &rpc.ResponseError{Message: st.Message()}
+	}
+}
+
+// Done is closed once the plugin closed its stdout.
+func (t *grpcTransport) Done() <-chan struct{} {
+	return t.done
+}
+
+// Err returns errPluginTerminated once Done is closed, nil otherwise.
+func (t *grpcTransport) Err() error {
+	select {
+	case <-t.done:
+		return errPluginTerminated
+	default:
+		return nil
+	}
+}
+
+// Close closes the plugin's stdin, requesting it to stop after finishing all pending requests.
+func (t *grpcTransport) Close() error {
+	return t.stdin.Close()
+}
@@ -0,0 +1,148 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	pluginv1 "github.com/icinga/icinga-notifications/pkg/plugin/proto/v1"
+	"github.com/icinga/icinga-notifications/pkg/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeGRPCPlugin delivers notifications to contacts named "ok" after reporting progress, failing for everyone else.
+// The state of the request is returned as the incident's new state.
+type fakeGRPCPlugin struct {
+	pluginv1.UnimplementedChannelPluginServer
+}
+
+func (fakeGRPCPlugin) GetInfo(context.Context, *pluginv1.GetInfoRequest) (*pluginv1.GetInfoResponse, error) {
+	return &pluginv1.GetInfoResponse{Name: "Fake", Version: "1.0", ConfigAttrsJson: `[{"name": "url"}]`}, nil
+}
+
+func (fakeGRPCPlugin) SendNotification(
+	req *pluginv1.SendNotificationRequest,
+	stream pluginv1.ChannelPlugin_SendNotificationServer,
+) error {
+	if req.GetRequest().GetContact().GetFullName() != "ok" {
+		return status.Error(codes.Unknown, "rejected")
+	}
+
+	_ = stream.Send(&pluginv1.DeliveryStatus{State: pluginv1.DeliveryStatus_STATE_IN_PROGRESS, Message: "queued"})
+	return stream.Send(&pluginv1.DeliveryStatus{
+		State:     pluginv1.DeliveryStatus_STATE_DELIVERED,
+		StateJson: req.GetRequest().GetStateJson(),
+	})
+}
+
+// pluginPipes returns the plugin's ends of its stdin and stdout and the daemon's ones, closed when the test ends.
+func pluginPipes(t *testing.T) (stdin io.Reader, stdout io.WriteCloser, daemonIn io.WriteCloser, daemonOut io.Reader) {
+	reqReader, reqWriter := io.Pipe()
+	resReader, resWriter := io.Pipe()
+	t.Cleanup(func() {
+		_ = reqReader.Close()
+		_ = resWriter.Close()
+	})
+
+	return reqReader, resWriter, reqWriter, resReader
+}
+
+func TestNegotiate_JSONRPC(t *testing.T) {
+	t.Parallel()
+
+	stdin, stdout, daemonIn, daemonOut := pluginPipes(t)
+	go func() {
+		dec := json.NewDecoder(stdin)
+		enc := json.NewEncoder(stdout)
+		for {
+			var req rpc.Request
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			_ = enc.Encode(&rpc.Response{Result: json.RawMessage(`{"name": "Fake"}`), Id: req.Id})
+		}
+	}()
+
+	tr, err := negotiate(daemonIn, daemonOut, "", zaptest.NewLogger(t).Sugar())
+	require.NoError(t, err)
+	require.IsType(t, &rpc.RPC{}, tr)
+
+	info, err := (&Plugin{rpc: tr}).GetInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "Fake", info.Name)
+}
+
+func TestNegotiate_GRPC(t *testing.T) {
+	t.Parallel()
+
+	socket := filepath.Join(t.TempDir(), "plugin.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	pluginv1.RegisterChannelPluginServer(server, fakeGRPCPlugin{})
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	stdin, stdout, daemonIn, daemonOut := pluginPipes(t)
+	go func() { _, _ = io.Copy(io.Discard, stdin) }()
+	go func() { _ = json.NewEncoder(stdout).Encode(plugin.GRPCHandshake) }()
+
+	tr, err := negotiate(daemonIn, daemonOut, socket, zaptest.NewLogger(t).Sugar())
+	require.NoError(t, err)
+	require.IsType(t, &grpcTransport{}, tr)
+	p := &Plugin{rpc: tr}
+
+	info, err := p.GetInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "Fake", info.Name)
+	assert.Equal(t, plugin.ConfigOptions{{Name: "url"}}, info.ConfigAttributes)
+
+	state, err := p.SendNotification(&plugin.NotificationRequest{
+		Contact: &plugin.Contact{FullName: "ok"},
+		State:   json.RawMessage(`{"key":"OPS-1"}`),
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"key": "OPS-1"}`, string(state))
+
+	state, err = p.SendNotification(&plugin.NotificationRequest{Contact: &plugin.Contact{FullName: "ok"}})
+	require.NoError(t, err)
+	assert.Nil(t, state)
+	assert.NoError(t, p.SendTestNotification(&plugin.NotificationRequest{Contact: &plugin.Contact{FullName: "ok"}}))
+
+	_, err = p.SendNotification(&plugin.NotificationRequest{Contact: &plugin.Contact{FullName: "jdoe"}})
+	var respErr *rpc.ResponseError
+	require.ErrorAs(t, err, &respErr)
+	assert.Equal(t, "rejected", respErr.Message)
+
+	assert.NoError(t, p.HealthCheck(time.Second), "unimplemented health check should be considered healthy")
+
+	require.NoError(t, stdout.Close())
+	select {
+	case <-tr.Done():
+	case <-time.After(time.Second):
+		require.Fail(t, "transport should be done once the plugin closed stdout")
+	}
+	assert.ErrorIs(t, tr.Err(), errPluginTerminated)
+	assert.ErrorIs(t, p.SetConfig("{}"), errPluginTerminated)
+}
+
+func TestNegotiate_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	stdin, stdout, daemonIn, daemonOut := pluginPipes(t)
+	go func() { _, _ = io.Copy(io.Discard, stdin) }()
+	go func() { _ = json.NewEncoder(stdout).Encode(plugin.Handshake{Protocol: "grpc", Version: "v2"}) }()
+
+	_, err := negotiate(daemonIn, daemonOut, "", zaptest.NewLogger(t).Sugar())
+	assert.ErrorContains(t, err, `unsupported plugin protocol "grpc" of version "v2"`)
+}
@@ -0,0 +1,77 @@
+package channel
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Status of a Channel as determined by its latest health check, see plugin.HealthChecker.
+type Status struct {
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+	// CheckedAt is the time of the latest health check.
+	CheckedAt time.Time `json:"checked_at"`
+	// Since is the time of the health check which first reported the current Healthy value.
+	Since time.Time `json:"since"`
+}
+
+// Status returns the result of the latest health check or nil if the channel was not checked yet.
+func (c *Channel) Status() *Status {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	if c.status == nil {
+		return nil
+	}
+
+	status := *c.status
+	return &status
+}
+
+// checkHealth probes the plugin, being nil if it could not be started, and records the result.
+//
+// An unresponsive plugin is stopped, letting runPlugin restart it just as if it had crashed.
+func (c *Channel) checkHealth(p *Plugin, timeout time.Duration) {
+	err := errors.New("plugin could not be started")
+	if p != nil {
+		err = p.HealthCheck(timeout)
+	}
+
+	if errors.Is(err, errPluginUnresponsive) {
+		c.Logger.Warnw("Channel plugin is unresponsive, restarting it", zap.Int("pid", p.Pid()), zap.Error(err))
+		p.Stop()
+	}
+
+	c.setStatus(err, time.Now())
+}
+
+// setStatus records the outcome of a health check, logging changes of the channel's health.
+func (c *Channel) setStatus(err error, now time.Time) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	healthy := err == nil
+	changed := c.status == nil || c.status.Healthy != healthy
+
+	status := &Status{Healthy: healthy, CheckedAt: now, Since: now}
+	if !changed {
+		status.Since = c.status.Since
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	switch {
+	case !healthy && changed:
+		c.Logger.Warnw("Channel health check failed", zap.Error(err))
+	case !healthy:
+		c.Logger.Debugw("Channel is still unhealthy", zap.Duration("since", now.Sub(c.status.Since)), zap.Error(err))
+	case c.status != nil && changed:
+		c.Logger.Infow("Channel is healthy again", zap.Duration("unhealthy_for", now.Sub(c.status.Since)))
+	default:
+		c.Logger.Debug("Channel health check succeeded")
+	}
+
+	c.status = status
+}
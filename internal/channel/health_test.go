@@ -0,0 +1,106 @@
+package channel
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"github.com/icinga/icinga-notifications/pkg/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"io"
+	"testing"
+	"time"
+)
+
+// fakePlugin returns a Plugin whose remote end answers each request by respond, not answering at all if it returns
+// false.
+func fakePlugin(t *testing.T, respond func(*rpc.Request) (rpc.Response, bool)) *Plugin {
+	reqReader, reqWriter := io.Pipe()
+	resReader, resWriter := io.Pipe()
+	t.Cleanup(func() {
+		_ = reqReader.Close()
+		_ = resWriter.Close()
+	})
+
+	go func() {
+		dec := json.NewDecoder(reqReader)
+		enc := json.NewEncoder(resWriter)
+		for {
+			var req rpc.Request
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+
+			if res, ok := respond(&req); ok {
+				res.Id = req.Id
+				_ = enc.Encode(&res)
+			}
+		}
+	}()
+
+	logger := zaptest.NewLogger(t).Sugar()
+	return &Plugin{rpc: rpc.NewRPC(reqWriter, resReader, logger), logger: logger}
+}
+
+func TestPlugin_HealthCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		response *rpc.Response
+		wantErr  bool
+	}{
+		{"Healthy", &rpc.Response{}, false},
+		{"Unhealthy", &rpc.Response{Error: "connection refused"}, true},
+		{"Unsupported", &rpc.Response{Error: `unknown method: "HealthCheck"`}, false},
+		{"Timeout", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := fakePlugin(t, func(req *rpc.Request) (rpc.Response, bool) {
+				assert.Equal(t, plugin.MethodHealthCheck, req.Method)
+				if tt.response == nil {
+					return rpc.Response{}, false
+				}
+				return *tt.response, true
+			})
+
+			err := p.HealthCheck(100 * time.Millisecond)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestChannel_SetStatus(t *testing.T) {
+	t.Parallel()
+
+	c := &Channel{Logger: zaptest.NewLogger(t).Sugar()}
+	assert.Nil(t, c.Status())
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.setStatus(nil, start)
+	c.setStatus(nil, start.Add(time.Minute))
+	assert.Equal(t, &Status{Healthy: true, CheckedAt: start.Add(time.Minute), Since: start}, c.Status())
+
+	c.setStatus(errors.New("connection refused"), start.Add(2*time.Minute))
+	c.setStatus(errors.New("connection timed out"), start.Add(3*time.Minute))
+	assert.Equal(t, &Status{
+		Error:     "connection timed out",
+		CheckedAt: start.Add(3 * time.Minute),
+		Since:     start.Add(2 * time.Minute),
+	}, c.Status())
+
+	c.setStatus(nil, start.Add(4*time.Minute))
+	status := c.Status()
+	require.NotNil(t, status)
+	assert.True(t, status.Healthy)
+	assert.Equal(t, start.Add(4*time.Minute), status.Since)
+}
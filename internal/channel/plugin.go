@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/icinga/icinga-go-library/database"
 	"github.com/icinga/icinga-go-library/logging"
 	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/errs"
 	"github.com/icinga/icinga-notifications/pkg/plugin"
 	"github.com/icinga/icinga-notifications/pkg/rpc"
 	"go.uber.org/zap"
@@ -23,9 +25,16 @@ import (
 
 type Plugin struct {
 	cmd    *exec.Cmd
-	rpc    *rpc.RPC
+	rpc    transport
 	logger *zap.SugaredLogger
 
+	// path and modTime of the executable when the plugin was started, allowing to detect updates, see updated.
+	path    string
+	modTime time.Time
+
+	// socketDir holds the Unix socket the plugin may serve gRPC on, see plugin.EnvPluginSocket.
+	socketDir string
+
 	stopOnce sync.Once
 }
 
@@ -35,7 +44,19 @@ func NewPlugin(pluginType string, logger *zap.SugaredLogger) (*Plugin, error) {
 
 	logger.Debugw("Starting new channel plugin process", zap.String("path", file))
 
+	stat, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	socketDir, err := os.MkdirTemp("", "icinga-notifications-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	socket := filepath.Join(socketDir, "plugin.sock")
+
 	cmd := exec.Command(file)
+	cmd.Env = append(os.Environ(), plugin.EnvPluginSocket+"="+socket)
 
 	started := false
 	var childIOPipes []io.Closer
@@ -49,6 +70,7 @@ func NewPlugin(pluginType string, logger *zap.SugaredLogger) (*Plugin, error) {
 			for _, pipe := range parentIOPipes {
 				_ = pipe.Close()
 			}
+			_ = os.RemoveAll(socketDir)
 		}
 	}()
 
@@ -79,13 +101,23 @@ func NewPlugin(pluginType string, logger *zap.SugaredLogger) (*Plugin, error) {
 	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("failed to start cmd: %w", err)
 	}
-	started = true
 
 	l := logger.With(zap.Int("pid", cmd.Process.Pid))
+	t, err := negotiate(reqWrite, resRead, socket, l)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("failed to negotiate protocol: %w", err)
+	}
+	started = true
+
 	p := &Plugin{
-		cmd:    cmd,
-		rpc:    rpc.NewRPC(reqWrite, resRead, l),
-		logger: l,
+		cmd:       cmd,
+		rpc:       t,
+		logger:    l,
+		path:      file,
+		modTime:   stat.ModTime(),
+		socketDir: socketDir,
 	}
 
 	go forwardLogs(logRead, l)
@@ -110,6 +142,7 @@ func (p *Plugin) Stop() {
 				p.logger.Errorw("Channel plugin stopped with an error", zap.Error(err))
 			}
 			timer.Stop()
+			_ = os.RemoveAll(p.socketDir)
 
 			p.logger.Debug("Channel plugin terminated")
 		}()
@@ -120,6 +153,14 @@ func (p *Plugin) Pid() int {
 	return p.cmd.Process.Pid
 }
 
+// updated reports whether the plugin's executable was replaced or removed since the plugin was started, e.g., by a
+// package upgrade.
+func (p *Plugin) updated() bool {
+	stat, err := os.Stat(p.path)
+
+	return err != nil || !stat.ModTime().Equal(p.modTime)
+}
+
 // GetInfo sends the PluginInfo request and returns the response or an error if an error occurred
 func (p *Plugin) GetInfo() (*plugin.Info, error) {
 	result, err := p.rpc.Call(plugin.MethodGetInfo, nil)
@@ -143,74 +184,121 @@ func (p *Plugin) SetConfig(config string) error {
 	return err
 }
 
-// SendNotification sends the notification, returns an error if fails
-func (p *Plugin) SendNotification(req *plugin.NotificationRequest) error {
+// SendNotification sends the notification, returns the incident's new state reported by the plugin, if any, or an
+// error if fails, see plugin.StatefulNotifier.
+//
+// Failures reported by the plugin itself are wrapped with errs.ErrChannelPermanent, while communication failures are
+// returned as they are and may be retried.
+func (p *Plugin) SendNotification(req *plugin.NotificationRequest) (json.RawMessage, error) {
 	params, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("failed to prepare request params: %w", err)
+		return nil, fmt.Errorf("%w: failed to prepare request params: %w", errs.ErrChannelPermanent, err)
 	}
 
-	_, err = p.rpc.Call(plugin.MethodSendNotification, params)
-
-	return err
-}
+	result, err := p.rpc.Call(plugin.MethodSendNotification, params)
 
-func forwardLogs(errPipe io.Reader, logger *zap.SugaredLogger) {
-	scanner := bufio.NewScanner(errPipe)
-	for scanner.Scan() {
-		logger.Info(scanner.Text())
+	var respErr *rpc.ResponseError
+	if errors.As(err, &respErr) {
+		// The plugin has processed the request and reported a failure, e.g., rejected credentials.
+		return nil, fmt.Errorf("%w: %w", errs.ErrChannelPermanent, err)
+	} else if err != nil {
+		return nil, err
 	}
 
-	if err := scanner.Err(); err != nil {
-		logger.Errorw("Failed to scan stderr line", zap.Error(err))
+	var res plugin.NotificationResult
+	if len(result) > 0 && string(result) != "null" {
+		if err := json.Unmarshal(result, &res); err != nil {
+			// The notification was sent nonetheless, thus it must not be retried.
+			p.logger.Errorw("Failed to parse the notification result of the channel plugin", zap.Error(err))
+		}
 	}
+
+	return res.State, nil
 }
 
-// UpsertPlugins upsert the available_channel_type table with working plugins
-func UpsertPlugins(ctx context.Context, channelPluginDir string, logger *logging.Logger, db *database.DB) {
-	logger.Debug("Updating available channel types")
-	files, err := os.ReadDir(channelPluginDir)
+// SendTestNotification sends the synthetic test notification, see NewTestNotificationRequest.
+//
+// Plugins built before test notifications were introduced receive it as a regular notification. As for
+// SendNotification, failures reported by the plugin itself are wrapped with errs.ErrChannelPermanent.
+func (p *Plugin) SendTestNotification(req *plugin.NotificationRequest) error {
+	params, err := json.Marshal(req)
 	if err != nil {
-		logger.Errorw("Failed to read the channel plugin directory", zap.Error(err))
+		return fmt.Errorf("%w: failed to prepare request params: %w", errs.ErrChannelPermanent, err)
 	}
 
-	var pluginInfos []*plugin.Info
-	var pluginTypes []string
+	_, err = p.rpc.Call(plugin.MethodSendTestNotification, params)
 
-	for _, file := range files {
-		pluginType := file.Name()
-		pluginLogger := logger.With(zap.String("type", pluginType))
-		if err := ValidateType(pluginType); err != nil {
-			pluginLogger.Warnw("Ignoring plugin", zap.Error(err))
-			continue
+	var respErr *rpc.ResponseError
+	if errors.As(err, &respErr) {
+		if strings.HasPrefix(respErr.Message, "unknown method:") {
+			_, err := p.SendNotification(req)
+			return err
 		}
 
-		p, err := NewPlugin(pluginType, pluginLogger)
-		if err != nil {
-			pluginLogger.Errorw("Failed to start plugin", zap.Error(err))
-			continue
-		}
+		return fmt.Errorf("%w: %w", errs.ErrChannelPermanent, err)
+	}
+
+	return err
+}
 
-		info, err := p.GetInfo()
-		if err != nil {
-			p.logger.Error(err)
-			p.Stop()
-			continue
+// HealthCheck asks the plugin whether it is able to send notifications, see plugin.HealthChecker.
+//
+// An error is returned if the plugin reports a failure or does not respond within the timeout, the latter wrapping
+// errPluginUnresponsive. Plugins built before the health check was introduced are considered healthy as long as they
+// respond.
+func (p *Plugin) HealthCheck(timeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := p.rpc.Call(plugin.MethodHealthCheck, nil)
+		errCh <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-errCh:
+		var respErr *rpc.ResponseError
+		if errors.As(err, &respErr) && strings.HasPrefix(respErr.Message, "unknown method:") {
+			return nil
 		}
-		p.Stop()
-		info.Type = pluginType
 
-		pluginTypes = append(pluginTypes, pluginType)
-		pluginInfos = append(pluginInfos, info)
+		return err
+	case <-timer.C:
+		return fmt.Errorf("%w within %v", errPluginUnresponsive, timeout)
 	}
+}
 
+// errPluginUnresponsive is returned by Plugin.HealthCheck if the plugin did not respond in time, e.g., as it is wedged.
+var errPluginUnresponsive = errors.New("plugin did not respond")
+
+func forwardLogs(errPipe io.Reader, logger *zap.SugaredLogger) {
+	scanner := bufio.NewScanner(errPipe)
+	for scanner.Scan() {
+		logger.Info(scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		logger.Errorw("Failed to scan stderr line", zap.Error(err))
+	}
+}
+
+// UpsertPlugins upsert the available_channel_type table with working plugins, see Discover.
+func UpsertPlugins(ctx context.Context, channelPluginDir string, logger *logging.Logger, db *database.DB) {
+	logger.Debug("Updating available channel types")
+	pluginInfos := Discover(channelPluginDir, logger)
 	if len(pluginInfos) == 0 {
 		logger.Info("No working plugin found")
 		return
 	}
 
+	var pluginTypes []string
+	for _, info := range pluginInfos {
+		pluginTypes = append(pluginTypes, info.Type)
+	}
+
 	stmt, _ := db.BuildUpsertStmt(&plugin.Info{})
-	_, err = db.NamedExecContext(ctx, stmt, pluginInfos)
+	_, err := db.NamedExecContext(ctx, stmt, pluginInfos)
 	if err != nil {
 		logger.Errorw("Failed to update available channel types", zap.Error(err))
 	} else {
@@ -0,0 +1,121 @@
+package channel
+
+import (
+	"context"
+	"sync"
+)
+
+// scheduler limits the number of concurrent sends of a channel.
+//
+// Once the limit is reached, further sends wait in a queue per contact, identified by its ID. Each slot becoming free is granted to the
+// contacts' queues in turn, so that a contact with hundreds of pending notifications, e.g., from replaying the events
+// missed during a restart, delays other contacts by at most one notification each.
+type scheduler struct {
+	// limit of concurrent sends, a zero value disables the limit.
+	limit int
+
+	mu      sync.Mutex
+	running int
+	queues  map[int64][]chan struct{}
+	// order lists the contacts with a non-empty queue, the one to be granted the next free slot first.
+	order []int64
+}
+
+// newScheduler creates a scheduler allowing limit concurrent sends, or any number if limit is not positive.
+func newScheduler(limit int) *scheduler {
+	return &scheduler{limit: max(limit, 0), queues: make(map[int64][]chan struct{})}
+}
+
+// acquire waits for a free slot for sending a notification to the contact.
+//
+// An error is only returned if the context is done before, otherwise the slot must be released after sending.
+func (s *scheduler) acquire(ctx context.Context, contact int64) error {
+	s.mu.Lock()
+	if s.limit == 0 || (s.running < s.limit && len(s.order) == 0) {
+		s.running++
+		s.mu.Unlock()
+
+		return nil
+	}
+
+	granted := make(chan struct{})
+	if len(s.queues[contact]) == 0 {
+		s.order = append(s.order, contact)
+	}
+	s.queues[contact] = append(s.queues[contact], granted)
+	s.mu.Unlock()
+
+	select {
+	case <-granted:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		select {
+		case <-granted:
+			// The slot was granted concurrently, thus pass it on to the next one waiting.
+			s.grantNext()
+		default:
+			s.dequeue(contact, granted)
+		}
+
+		return ctx.Err()
+	}
+}
+
+// release frees the slot of a send, see acquire.
+func (s *scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.grantNext()
+}
+
+// grantNext passes a released slot to the first send waiting of the next contact in turn, or frees it if nobody waits.
+//
+// Must be called with mu held.
+func (s *scheduler) grantNext() {
+	if len(s.order) == 0 {
+		s.running--
+		return
+	}
+
+	contact := s.order[0]
+	queue := s.queues[contact]
+	close(queue[0])
+
+	s.order = s.order[1:]
+	if len(queue) > 1 {
+		s.queues[contact] = queue[1:]
+		s.order = append(s.order, contact)
+	} else {
+		delete(s.queues, contact)
+	}
+}
+
+// dequeue removes the send waiting for the granted channel from the contact's queue.
+//
+// Must be called with mu held.
+func (s *scheduler) dequeue(contact int64, granted chan struct{}) {
+	queue := s.queues[contact]
+	for i, ch := range queue {
+		if ch == granted {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+
+	if len(queue) > 0 {
+		s.queues[contact] = queue
+		return
+	}
+
+	delete(s.queues, contact)
+	for i, c := range s.order {
+		if c == contact {
+			s.order = append(s.order[:i:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
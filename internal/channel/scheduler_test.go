@@ -0,0 +1,88 @@
+package channel
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	t.Parallel()
+
+	// IDs of the contacts, distinguishing contacts sharing the same full name.
+	const alice, bob, carol int64 = 1, 2, 3
+
+	t.Run("Unlimited", func(t *testing.T) {
+		t.Parallel()
+
+		s := newScheduler(0)
+		for i := 0; i < 100; i++ {
+			require.NoError(t, s.acquire(context.Background(), alice))
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		t.Parallel()
+
+		s := newScheduler(2)
+		require.NoError(t, s.acquire(context.Background(), alice))
+		require.NoError(t, s.acquire(context.Background(), bob))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, s.acquire(ctx, carol), context.DeadlineExceeded, "third send should wait for a slot")
+
+		s.release()
+		require.NoError(t, s.acquire(context.Background(), carol), "released slot should be free again")
+		assert.Empty(t, s.queues, "cancelled sends should be removed from the queues")
+		assert.Empty(t, s.order, "cancelled sends should be removed from the order")
+	})
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		t.Parallel()
+
+		s := newScheduler(1)
+		require.NoError(t, s.acquire(context.Background(), alice))
+
+		var mu sync.Mutex
+		var sent []int64
+		var wg sync.WaitGroup
+		queued := func(contact int64) int {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return len(s.queues[contact])
+		}
+		enqueue := func(contact int64) {
+			before := queued(contact)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if assert.NoError(t, s.acquire(context.Background(), contact)) {
+					mu.Lock()
+					sent = append(sent, contact)
+					mu.Unlock()
+					s.release()
+				}
+			}()
+
+			// Wait for the send to be queued to make the order deterministic.
+			assert.Eventually(t, func() bool { return queued(contact) == before+1 }, time.Second, time.Millisecond)
+		}
+
+		// Alice replays a backlog of notifications before Bob and Carol are to be notified.
+		for i := 0; i < 4; i++ {
+			enqueue(alice)
+		}
+		enqueue(bob)
+		enqueue(carol)
+
+		s.release()
+		wg.Wait()
+
+		assert.Equal(t, []int64{alice, bob, carol, alice, alice, alice}, sent)
+		assert.Zero(t, s.running, "all slots should be free again")
+	})
+}
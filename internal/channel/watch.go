@@ -0,0 +1,73 @@
+package channel
+
+import (
+	"context"
+	"github.com/icinga/icinga-go-library/logging"
+	"go.uber.org/zap"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// watchDebounce delays the handling of changes in the channels directory, as installing a plugin usually involves
+// several file operations, e.g., writing a temporary file and renaming it.
+const watchDebounce = time.Second
+
+// WatchPlugins calls onChange whenever plugins are added to, replaced in or removed from the channels directory, until
+// the context is done.
+//
+// The directory is watched by inotify on Linux and polled on other systems. If it cannot be watched, an error is
+// logged and changes are only detected by an explicit rescan, e.g., on SIGHUP.
+func WatchPlugins(ctx context.Context, channelPluginDir string, logger *logging.Logger, onChange func()) {
+	changes, err := watchDir(ctx, channelPluginDir)
+	if err != nil {
+		logger.Errorw("Cannot watch the channel plugin directory, send SIGHUP after changing plugins",
+			zap.String("dir", channelPluginDir), zap.Error(err))
+		return
+	}
+
+	last := pluginFiles(channelPluginDir)
+	var debounce <-chan time.Time
+	for {
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				logger.Errorw("Stopped watching the channel plugin directory, send SIGHUP after changing plugins",
+					zap.String("dir", channelPluginDir))
+				return
+			}
+			debounce = time.After(watchDebounce)
+		case <-debounce:
+			debounce = nil
+			if current := pluginFiles(channelPluginDir); !maps.Equal(last, current) {
+				logger.Info("Channel plugin directory has changed, rescanning it")
+				last = current
+				onChange()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pluginFile identifies a version of a plugin's executable.
+type pluginFile struct {
+	modTime time.Time
+	size    int64
+}
+
+// pluginFiles returns the files in the channels directory by their name. Errors are ignored, resulting in fewer files.
+func pluginFiles(channelPluginDir string) map[string]pluginFile {
+	entries, _ := os.ReadDir(channelPluginDir)
+
+	files := make(map[string]pluginFile, len(entries))
+	for _, entry := range entries {
+		// Follows symlinks, as plugins might be linked from elsewhere.
+		if stat, err := os.Stat(filepath.Join(channelPluginDir, entry.Name())); err == nil {
+			files[entry.Name()] = pluginFile{modTime: stat.ModTime(), size: stat.Size()}
+		}
+	}
+
+	return files
+}
@@ -0,0 +1,50 @@
+package channel
+
+import (
+	"context"
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+// watchDir returns a channel receiving a value whenever an entry of the directory might have changed, being closed if
+// the directory cannot be watched any longer, e.g., as it was removed.
+func watchDir(ctx context.Context, dir string) (<-chan struct{}, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	mask := uint32(unix.IN_CREATE | unix.IN_DELETE | unix.IN_CLOSE_WRITE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+		unix.IN_ATTRIB | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF)
+	if _, err := unix.InotifyAddWatch(fd, dir, mask); err != nil {
+		_ = unix.Close(fd)
+		return nil, &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+
+	// As the descriptor is non-blocking, reads use the runtime's poller and are interrupted by closing the file.
+	inotify := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		<-ctx.Done()
+		_ = inotify.Close()
+	}()
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+
+		// The events are not parsed, as the directory is compared to its previous state anyway.
+		buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+		for {
+			if _, err := inotify.Read(buf); err != nil {
+				return
+			}
+
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return changes, nil
+}
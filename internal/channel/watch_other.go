@@ -0,0 +1,35 @@
+//go:build !linux
+
+package channel
+
+import (
+	"context"
+	"time"
+)
+
+// watchPollInterval is the interval in which the directory is checked for changes on systems without inotify.
+const watchPollInterval = 10 * time.Second
+
+// watchDir returns a channel receiving a value every watchPollInterval, as changes cannot be watched for efficiently.
+func watchDir(ctx context.Context, _ string) (<-chan struct{}, error) {
+	changes := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(watchPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case changes <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
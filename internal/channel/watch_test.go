@@ -0,0 +1,61 @@
+package channel
+
+import (
+	"context"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWatchPlugins(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("changes are only polled on this system")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "email"), []byte("v1"), 0o700))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 10)
+	go WatchPlugins(ctx, dir, logging.NewLogger(zaptest.NewLogger(t).Sugar(), time.Hour), func() {
+		changed <- struct{}{}
+	})
+
+	// Give the watcher time to set up inotify before changing the directory.
+	time.Sleep(100 * time.Millisecond)
+
+	expectChange := func(msg string) {
+		select {
+		case <-changed:
+		case <-time.After(5 * watchDebounce):
+			require.Fail(t, msg)
+		}
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "webhook"), []byte("v1"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "webhook"), []byte("v2"), 0o700))
+	expectChange("adding a plugin should be detected")
+
+	require.NoError(t, os.Rename(filepath.Join(dir, "webhook"), filepath.Join(dir, "rocketchat")))
+	expectChange("renaming a plugin should be detected")
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "email")))
+	expectChange("removing a plugin should be detected")
+
+	require.NoError(t, os.Chmod(filepath.Join(dir, "rocketchat"), 0o755))
+	select {
+	case <-changed:
+		assert.Fail(t, "changing permissions only should not be reported")
+	case <-time.After(2 * watchDebounce):
+	}
+}
@@ -0,0 +1,261 @@
+// Package chatops parses and verifies the callbacks of interactive chat messages, allowing contacts to act on incidents
+// directly from a Slack or Microsoft Teams message.
+//
+// Both platforms sign their requests with a shared secret. Their users are mapped to contacts by contact addresses of
+// the types AddressTypeSlack and AddressTypeTeams, holding the Slack member ID or the Microsoft Entra object ID.
+package chatops
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Action to be performed on an incident.
+type Action string
+
+const (
+	ActionAcknowledge Action = "acknowledge"
+	ActionSnooze      Action = "snooze"
+	ActionEscalate    Action = "escalate"
+)
+
+// actionAliases maps the accepted action names, including short forms for typed Teams commands, to their Action.
+var actionAliases = map[string]Action{
+	"acknowledge": ActionAcknowledge,
+	"ack":         ActionAcknowledge,
+	"snooze":      ActionSnooze,
+	"escalate":    ActionEscalate,
+}
+
+// SnoozeDuration is how long an incident is snoozed by ActionSnooze.
+const SnoozeDuration = time.Hour
+
+const (
+	// AddressTypeSlack is the contact address type holding a Slack member ID, e.g., "U012AB3CD".
+	AddressTypeSlack = "slack"
+	// AddressTypeTeams is the contact address type holding a Microsoft Entra object ID of a Teams user.
+	AddressTypeTeams = "teams"
+)
+
+// maxSlackRequestAge limits the age of a Slack request to prevent replay attacks, as recommended by Slack.
+const maxSlackRequestAge = 5 * time.Minute
+
+// ErrInvalidSignature is returned if a request is not signed by the configured secret.
+var ErrInvalidSignature = errors.New("invalid request signature")
+
+// Callback is an action performed by a chat user on an incident.
+type Callback struct {
+	Action     Action
+	IncidentID int64
+
+	// AddressType and Address identify the chat user, see GetContactByAddress of config.RuntimeConfig.
+	AddressType string
+	Address     string
+
+	// ResponseURL is only set for Slack, allowing to post the result of the action, see RespondSlack.
+	ResponseURL string
+}
+
+// parseAction returns the Action of the given name together with the incident ID.
+func parseAction(name, incidentID string) (Action, int64, error) {
+	action, ok := actionAliases[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return "", 0, fmt.Errorf("unknown action %q", name)
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(incidentID), "#"), 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("incident ID must be an integer, got %q", incidentID)
+	}
+
+	return action, id, nil
+}
+
+// VerifySlack checks the signature of a Slack request, see https://api.slack.com/authentication/verifying-requests-from-slack.
+func VerifySlack(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing timestamp", ErrInvalidSignature)
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > maxSlackRequestAge || age < -maxSlackRequestAge {
+		return fmt.Errorf("%w: timestamp is off by %v", ErrInvalidSignature, age.Round(time.Second))
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature"))) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+
+	return nil
+}
+
+// slackPayload is the relevant part of a Slack block_actions interaction payload.
+type slackPayload struct {
+	Type string `json:"type"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	ResponseURL string `json:"response_url"`
+}
+
+// ParseSlack parses the form-encoded body of a Slack interaction request.
+//
+// The button clicked must have one of the Action names as its action_id and the incident ID as its value.
+func ParseSlack(body []byte) (*Callback, error) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse form: %w", err)
+	}
+
+	var payload slackPayload
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil {
+		return nil, fmt.Errorf("cannot parse payload: %w", err)
+	}
+
+	if payload.Type != "block_actions" {
+		return nil, fmt.Errorf("unsupported interaction type %q", payload.Type)
+	}
+	if len(payload.Actions) != 1 {
+		return nil, fmt.Errorf("expected exactly one action, got %d", len(payload.Actions))
+	}
+	if payload.User.ID == "" {
+		return nil, errors.New("missing user ID")
+	}
+
+	action, id, err := parseAction(payload.Actions[0].ActionID, payload.Actions[0].Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Callback{
+		Action:      action,
+		IncidentID:  id,
+		AddressType: AddressTypeSlack,
+		Address:     payload.User.ID,
+		ResponseURL: payload.ResponseURL,
+	}, nil
+}
+
+// RespondSlack posts the text as an ephemeral message, only visible to the user who performed the action, to the
+// response URL of a Callback.
+//
+// Only URLs of hooks.slack.com are accepted, as the response URL is part of the request body.
+func RespondSlack(ctx context.Context, responseURL, text string) error {
+	u, err := url.Parse(responseURL)
+	if err != nil || u.Scheme != "https" || u.Host != "hooks.slack.com" {
+		return fmt.Errorf("refusing to respond to %q", responseURL)
+	}
+
+	body, err := json.Marshal(map[string]any{"text": text, "response_type": "ephemeral", "replace_original": false})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack responded with %s", res.Status)
+	}
+
+	return nil
+}
+
+// VerifyTeams checks the HMAC of a Teams outgoing webhook request against the base64-encoded secret.
+func VerifyTeams(secret string, header http.Header, body []byte) error {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return fmt.Errorf("cannot decode secret: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+
+	expected := "HMAC " + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("Authorization"))) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+
+	return nil
+}
+
+// teamsMention matches the mentions of the outgoing webhook within a message text, e.g., "<at>Icinga</at>".
+var teamsMention = regexp.MustCompile(`<at>[^<]*</at>`)
+
+// teamsActivity is the relevant part of a Teams message activity.
+type teamsActivity struct {
+	Text string `json:"text"`
+	From struct {
+		AadObjectID string `json:"aadObjectId"`
+	} `json:"from"`
+	Value *struct {
+		Action     string `json:"action"`
+		IncidentID int64  `json:"incident_id"`
+	} `json:"value"`
+}
+
+// ParseTeams parses a Teams message activity.
+//
+// Either an Adaptive Card Action.Submit with the data {"action": "...", "incident_id": 42} or a message mentioning the
+// outgoing webhook followed by the action and the incident ID, e.g., "@Icinga ack 42", is accepted.
+func ParseTeams(body []byte) (*Callback, error) {
+	var activity teamsActivity
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return nil, fmt.Errorf("cannot parse activity: %w", err)
+	}
+
+	if activity.From.AadObjectID == "" {
+		return nil, errors.New("missing user object ID")
+	}
+
+	var action Action
+	var id int64
+	var err error
+	if activity.Value != nil {
+		action, id, err = parseAction(activity.Value.Action, strconv.FormatInt(activity.Value.IncidentID, 10))
+	} else {
+		text := teamsMention.ReplaceAllString(activity.Text, "")
+		fields := strings.Fields(strings.ReplaceAll(text, "&nbsp;", " "))
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("expected an action and an incident ID, got %q", strings.TrimSpace(text))
+		}
+
+		action, id, err = parseAction(fields[0], fields[1])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Callback{Action: action, IncidentID: id, AddressType: AddressTypeTeams, Address: activity.From.AadObjectID}, nil
+}
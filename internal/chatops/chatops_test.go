@@ -0,0 +1,151 @@
+package chatops
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVerifySlack(t *testing.T) {
+	t.Parallel()
+
+	secret := "8f742231b10e8888abcd99yyyzzz85a5"
+	body := []byte("payload=%7B%7D")
+	now := time.Unix(1700000000, 0)
+
+	sign := func(timestamp int64, body []byte) http.Header {
+		mac := hmac.New(sha256.New, []byte(secret))
+		_, _ = fmt.Fprintf(mac, "v0:%d:%s", timestamp, body)
+
+		header := http.Header{}
+		header.Set("X-Slack-Request-Timestamp", fmt.Sprint(timestamp))
+		header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+		return header
+	}
+
+	assert.NoError(t, VerifySlack(secret, sign(now.Unix(), body), body, now))
+	assert.NoError(t, VerifySlack(secret, sign(now.Unix()-60, body), body, now))
+	assert.ErrorIs(t, VerifySlack(secret, sign(now.Unix(), body), []byte("payload=tampered"), now), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySlack("other", sign(now.Unix(), body), body, now), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySlack(secret, sign(now.Unix()-600, body), body, now), ErrInvalidSignature,
+		"replayed requests must be rejected")
+	assert.ErrorIs(t, VerifySlack(secret, http.Header{}, body, now), ErrInvalidSignature)
+}
+
+func TestParseSlack(t *testing.T) {
+	t.Parallel()
+
+	form := func(payload string) []byte {
+		return []byte(url.Values{"payload": {payload}}.Encode())
+	}
+
+	c, err := ParseSlack(form(`{"type": "block_actions", "user": {"id": "U012AB3CD"},
+		"actions": [{"action_id": "snooze", "value": "42"}], "response_url": "https://hooks.slack.com/actions/T0/1/x"}`))
+	require.NoError(t, err)
+	assert.Equal(t, &Callback{
+		Action:      ActionSnooze,
+		IncidentID:  42,
+		AddressType: AddressTypeSlack,
+		Address:     "U012AB3CD",
+		ResponseURL: "https://hooks.slack.com/actions/T0/1/x",
+	}, c)
+
+	for name, payload := range map[string]string{
+		"Type":       `{"type": "view_submission", "user": {"id": "U1"}, "actions": [{"action_id": "ack", "value": "1"}]}`,
+		"NoActions":  `{"type": "block_actions", "user": {"id": "U1"}, "actions": []}`,
+		"NoUser":     `{"type": "block_actions", "actions": [{"action_id": "ack", "value": "1"}]}`,
+		"Action":     `{"type": "block_actions", "user": {"id": "U1"}, "actions": [{"action_id": "delete", "value": "1"}]}`,
+		"IncidentID": `{"type": "block_actions", "user": {"id": "U1"}, "actions": [{"action_id": "ack", "value": "x"}]}`,
+		"Malformed":  `{`,
+	} {
+		_, err := ParseSlack(form(payload))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestVerifyTeams(t *testing.T) {
+	t.Parallel()
+
+	secret := base64.StdEncoding.EncodeToString([]byte("outgoing webhook security token"))
+	body := []byte(`{"type": "message"}`)
+
+	mac := hmac.New(sha256.New, []byte("outgoing webhook security token"))
+	mac.Write(body)
+	header := http.Header{}
+	header.Set("Authorization", "HMAC "+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	assert.NoError(t, VerifyTeams(secret, header, body))
+	assert.ErrorIs(t, VerifyTeams(secret, header, []byte(`{"type": "tampered"}`)), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifyTeams(secret, http.Header{}, body), ErrInvalidSignature)
+	assert.Error(t, VerifyTeams("not base64!", header, body))
+}
+
+func TestParseTeams(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		body       string
+		action     Action
+		incidentID int64
+	}{
+		{
+			name:       "Text",
+			body:       `{"text": "<at>Icinga</at> ack 42", "from": {"aadObjectId": "a1b2"}}`,
+			action:     ActionAcknowledge,
+			incidentID: 42,
+		},
+		{
+			name:       "TextWithHash",
+			body:       `{"text": "<at>Icinga</at>&nbsp;Escalate #7\n", "from": {"aadObjectId": "a1b2"}}`,
+			action:     ActionEscalate,
+			incidentID: 7,
+		},
+		{
+			name:       "Submit",
+			body:       `{"value": {"action": "snooze", "incident_id": 42}, "from": {"aadObjectId": "a1b2"}}`,
+			action:     ActionSnooze,
+			incidentID: 42,
+		},
+		{name: "NoUser", body: `{"text": "<at>Icinga</at> ack 42", "from": {}}`},
+		{name: "NoIncident", body: `{"text": "<at>Icinga</at> ack", "from": {"aadObjectId": "a1b2"}}`},
+		{name: "UnknownAction", body: `{"text": "<at>Icinga</at> close 42", "from": {"aadObjectId": "a1b2"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := ParseTeams([]byte(tt.body))
+			if tt.action == "" {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, &Callback{
+				Action:      tt.action,
+				IncidentID:  tt.incidentID,
+				AddressType: AddressTypeTeams,
+				Address:     "a1b2",
+			}, c)
+		})
+	}
+}
+
+func TestRespondSlack_RejectsForeignURLs(t *testing.T) {
+	t.Parallel()
+
+	for _, u := range []string{"http://hooks.slack.com/x", "https://example.com/x", "https://hooks.slack.com.example.com/"} {
+		assert.Error(t, RespondSlack(context.Background(), u, "test"), u)
+	}
+}
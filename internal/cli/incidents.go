@@ -0,0 +1,327 @@
+// Package cli implements the subcommands of the icinga-notifications binary besides running the daemon, allowing
+// operators to triage incidents from a shell, e.g., on a jump host without access to Icinga Web.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/icinga/icinga-go-library/config"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-notifications/internal"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/incident"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// timeFormat is used for all timestamps of the table output, in the local time zone.
+const timeFormat = "2006-01-02 15:04:05"
+
+const incidentsUsage = `Usage: icinga-notifications incidents <command> [flags] [id]
+
+Commands:
+  ls          list incidents, latest first
+  show <id>   show an incident together with its history
+  ack <id>    acknowledge an open incident, requires the API
+
+Incidents are read from the API if a token is given, otherwise read-only from the database of the config file.
+Run "icinga-notifications incidents <command> -h" for the flags of a command.
+`
+
+// options shared by all incidents commands.
+type options struct {
+	apiURL string
+	token  string
+	config string
+	output string
+}
+
+// RunIncidents runs the "incidents" subcommand with the arguments following it, writing its output to stdout.
+func RunIncidents(ctx context.Context, args []string, stdout io.Writer) error {
+	if err := runIncidents(ctx, args, stdout); !errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+
+	return nil
+}
+
+func runIncidents(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		_, _ = fmt.Fprint(stdout, incidentsUsage)
+		return nil
+	}
+
+	opts := &options{}
+	fs := flag.NewFlagSet("incidents "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.StringVar(&opts.apiURL, "api-url", envOr("ICINGA_NOTIFICATIONS_API_URL", "http://localhost:5680"),
+		"base URL of the daemon's HTTP listener, also read from ICINGA_NOTIFICATIONS_API_URL")
+	fs.StringVar(&opts.token, "token", os.Getenv("ICINGA_NOTIFICATIONS_API_TOKEN"),
+		"API token, also read from ICINGA_NOTIFICATIONS_API_TOKEN; if empty, the database is queried read-only")
+	fs.StringVar(&opts.config, "c", internal.SysConfDir+"/icinga-notifications/config.yml",
+		"path to the daemon's config file, used for database access without a token")
+	fs.StringVar(&opts.config, "config", opts.config, "alias of -c")
+	fs.StringVar(&opts.output, "output", "table", "output format, either table or json")
+
+	switch args[0] {
+	case "ls":
+		query := url.Values{}
+		for _, f := range [][2]string{
+			{"state", "either open or closed, both by default"},
+			{"severity", "comma-separated list of severities, e.g., crit,warning"},
+			{"source-id", "ID of the source the incidents' objects belong to"},
+			{"object-id", "hex-encoded ID of the incidents' object"},
+			{"limit", "maximum number of incidents"},
+			{"offset", "number of incidents to skip"},
+		} {
+			param := strings.ReplaceAll(f[0], "-", "_")
+			fs.Func(f[0], f[1], func(value string) error {
+				query.Set(param, value)
+				return nil
+			})
+		}
+
+		if _, err := parseArgs(fs, args[1:], 0); err != nil {
+			return err
+		}
+
+		return run(ctx, opts, func(s source) error { return listIncidents(ctx, s, query, opts.output, stdout) })
+
+	case "show":
+		positional, err := parseArgs(fs, args[1:], 1)
+		if err != nil {
+			return err
+		}
+		id, err := parseID(positional[0])
+		if err != nil {
+			return err
+		}
+
+		return run(ctx, opts, func(s source) error { return showIncident(ctx, s, id, opts.output, stdout) })
+
+	case "ack":
+		username := fs.String("username", "", "username of the contact acknowledging the incident, required")
+		comment := fs.String("comment", "", "comment to be recorded, defaults to a note about the API token")
+
+		positional, err := parseArgs(fs, args[1:], 1)
+		if err != nil {
+			return err
+		}
+		id, err := parseID(positional[0])
+		if err != nil {
+			return err
+		}
+		if *username == "" {
+			return errors.New("--username must be set")
+		}
+
+		return run(ctx, opts, func(s source) error {
+			message, err := s.acknowledge(ctx, id, *username, *comment)
+			if err != nil {
+				return err
+			}
+
+			return write(stdout, opts.output, map[string]string{"message": message}, func(w io.Writer) {
+				_, _ = fmt.Fprintln(w, message)
+			})
+		})
+
+	default:
+		_, _ = fmt.Fprint(stdout, incidentsUsage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// run validates the shared options and calls f with the source they select.
+func run(ctx context.Context, opts *options, f func(source) error) error {
+	if opts.output != "table" && opts.output != "json" {
+		return fmt.Errorf("--output must be either table or json, got %q", opts.output)
+	}
+
+	if opts.token != "" {
+		return f(&apiSource{baseURL: opts.apiURL, token: opts.token, client: &http.Client{Timeout: time.Minute}})
+	}
+
+	conf := &daemon.ConfigFile{}
+	if err := config.FromYAMLFile(opts.config, conf); err != nil {
+		return fmt.Errorf("no API token given and cannot read config file for database access: %w", err)
+	}
+
+	db, err := database.NewDbFromConfig(&conf.Database, logging.NewLogger(zap.NewNop().Sugar(), time.Hour),
+		database.RetryConnectorCallbacks{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("cannot connect to the database: %w", err)
+	}
+
+	return f(&dbSource{db: db})
+}
+
+// parseArgs parses the flags, which may be interleaved with exactly n positional arguments, e.g., "show 42 --output
+// json", and returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(positional) != n {
+		return nil, fmt.Errorf("%s expects %d argument(s), got %d", fs.Name(), n, len(positional))
+	}
+
+	return positional, nil
+}
+
+// parseID parses an incident ID, allowing a "#" prefix as used in notifications.
+func parseID(arg string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("incident ID must be an integer, got %q", arg)
+	}
+
+	return id, nil
+}
+
+// listIncidents writes the incidents matching the query either as a table or as JSON.
+func listIncidents(ctx context.Context, s source, query url.Values, output string, stdout io.Writer) error {
+	incidents, err := s.list(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return write(stdout, output, incidents, func(w io.Writer) {
+		_, _ = fmt.Fprintln(w, "ID\tSEVERITY\tSTATE\tSTARTED\tOBJECT")
+		for _, i := range incidents {
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+				i.ID, i.Severity.String(), state(i), i.StartedAt.Time().Local().Format(timeFormat), i.ObjectName)
+		}
+	})
+}
+
+// showIncident writes an incident together with its history either as a table or as JSON.
+func showIncident(ctx context.Context, s source, id int64, output string, stdout io.Writer) error {
+	i, err := s.get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	page, err := s.history(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	v := struct {
+		Incident *incident.Summary     `json:"incident"`
+		History  *incident.HistoryPage `json:"history"`
+	}{i, page}
+
+	return write(stdout, output, v, func(w io.Writer) {
+		recovered := "-"
+		if !i.RecoveredAt.Time().IsZero() {
+			recovered = i.RecoveredAt.Time().Local().Format(timeFormat)
+		}
+
+		_, _ = fmt.Fprintf(w, "ID:\t%d\n", i.ID)
+		_, _ = fmt.Fprintf(w, "Object:\t%s\n", i.ObjectName)
+		_, _ = fmt.Fprintf(w, "Object ID:\t%s\n", i.ObjectID)
+		_, _ = fmt.Fprintf(w, "Source ID:\t%d\n", i.SourceID)
+		_, _ = fmt.Fprintf(w, "Severity:\t%s\n", i.Severity.String())
+		_, _ = fmt.Fprintf(w, "State:\t%s\n", state(i))
+		_, _ = fmt.Fprintf(w, "Started:\t%s\n", i.StartedAt.Time().Local().Format(timeFormat))
+		_, _ = fmt.Fprintf(w, "Recovered:\t%s\n", recovered)
+
+		_, _ = fmt.Fprintf(w, "\nHistory (%d of %d entries):\n", len(page.History), page.Total)
+		_, _ = fmt.Fprintln(w, "TIME\tTYPE\tDETAILS")
+		for _, h := range page.History {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", h.Time.Time().Local().Format(timeFormat), h.Type, details(h))
+		}
+	})
+}
+
+// write writes v as indented JSON or, for the table output, calls table with an aligning writer.
+func write(stdout io.Writer, output string, v any, table func(io.Writer)) error {
+	if output == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+// state returns whether the incident is open or closed.
+func state(i *incident.Summary) string {
+	if i.RecoveredAt.Time().IsZero() {
+		return "open"
+	}
+
+	return "closed"
+}
+
+// details summarizes the fields set for a history entry, e.g., "severity=warning->crit contact=3".
+func details(h *incident.HistoryEntry) string {
+	var parts []string
+	if h.NewSeverity.Valid {
+		parts = append(parts, fmt.Sprintf("severity=%s->%s", h.OldSeverity.String, h.NewSeverity.String))
+	}
+	for _, ref := range []struct {
+		name  string
+		value int64
+		valid bool
+	}{
+		{"rule", h.RuleID.Int64, h.RuleID.Valid},
+		{"contact", h.ContactID.Int64, h.ContactID.Valid},
+		{"contactgroup", h.ContactGroupID.Int64, h.ContactGroupID.Valid},
+		{"schedule", h.ScheduleID.Int64, h.ScheduleID.Valid},
+		{"channel", h.ChannelID.Int64, h.ChannelID.Valid},
+	} {
+		if ref.valid {
+			parts = append(parts, fmt.Sprintf("%s=%d", ref.name, ref.value))
+		}
+	}
+	if h.NewRecipientRole.Valid {
+		parts = append(parts, "role="+h.NewRecipientRole.String)
+	}
+	if h.NotificationState.Valid {
+		parts = append(parts, "state="+h.NotificationState.String)
+	}
+	if h.Message.Valid && h.Message.String != "" {
+		parts = append(parts, strconv.Quote(h.Message.String))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// envOr returns the value of the environment variable or the fallback if it is unset or empty.
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return fallback
+}
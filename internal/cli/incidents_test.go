@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeAPI serves a single open incident and records the last request.
+func fakeAPI(t *testing.T) (*httptest.Server, *http.Request, *map[string]string) {
+	var last http.Request
+	ackBody := map[string]string{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/incidents", func(w http.ResponseWriter, req *http.Request) {
+		last = *req
+		_, _ = w.Write([]byte(`[{"id": 42, "object_id": "0a0b", "object_name": "web01!http", "source_id": 1,
+			"started_at": 1700000000000, "recovered_at": null, "severity": "crit"}]`))
+	})
+	mux.HandleFunc("GET /v1/incidents/{id}", func(w http.ResponseWriter, req *http.Request) {
+		if req.PathValue("id") != "42" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": "incident not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": 42, "object_id": "0a0b", "object_name": "web01!http", "source_id": 1,
+			"started_at": 1700000000000, "recovered_at": 1700000600000, "severity": "ok"}`))
+	})
+	mux.HandleFunc("GET /v1/incidents/{id}/history", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(`{"total": 1, "history": [{"id": 1, "incident_id": 42, "time": 1700000000000,
+			"type": "incident_severity_changed", "new_severity": "crit", "old_severity": "ok"}]}`))
+	})
+	mux.HandleFunc("POST /v1/incidents/{id}/acknowledge", func(w http.ResponseWriter, req *http.Request) {
+		last = *req
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&ackBody))
+		_, _ = w.Write([]byte(`{"message": "incident 42 acknowledged by \"jdoe\""}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, &last, &ackBody
+}
+
+func runWithAPI(t *testing.T, server *httptest.Server, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	args = append(args, "--api-url", server.URL, "--token", "secret")
+	err := RunIncidents(context.Background(), args, &stdout)
+
+	return stdout.String(), err
+}
+
+func TestRunIncidents_List(t *testing.T) {
+	t.Parallel()
+
+	server, last, _ := fakeAPI(t)
+
+	out, err := runWithAPI(t, server, "ls", "--state", "open", "--severity", "crit,warning", "--source-id", "1")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer secret", last.Header.Get("Authorization"))
+	assert.Equal(t, url.Values{"state": {"open"}, "severity": {"crit,warning"}, "source_id": {"1"}}, last.URL.Query())
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, []string{"ID", "SEVERITY", "STATE", "STARTED", "OBJECT"}, strings.Fields(lines[0]))
+	assert.Regexp(t, `^42\s+crit\s+open\s+2023-11-1\d \d\d:\d\d:\d\d\s+web01!http$`, lines[1])
+
+	out, err = runWithAPI(t, server, "ls", "--output", "json")
+	require.NoError(t, err)
+
+	var incidents []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(out), &incidents))
+	require.Len(t, incidents, 1)
+	assert.Equal(t, "crit", incidents[0]["severity"])
+}
+
+func TestRunIncidents_Show(t *testing.T) {
+	t.Parallel()
+
+	server, _, _ := fakeAPI(t)
+
+	out, err := runWithAPI(t, server, "show", "#42")
+	require.NoError(t, err)
+	assert.Regexp(t, `(?m)^State:\s+closed$`, out)
+	assert.Regexp(t, `(?m)^Object ID:\s+0a0b$`, out)
+	assert.Contains(t, out, "History (1 of 1 entries):")
+	assert.Regexp(t, `(?m)incident_severity_changed\s+severity=ok->crit$`, out)
+
+	_, err = runWithAPI(t, server, "show", "7")
+	assert.ErrorContains(t, err, "incident not found")
+}
+
+func TestRunIncidents_Acknowledge(t *testing.T) {
+	t.Parallel()
+
+	server, last, body := fakeAPI(t)
+
+	out, err := runWithAPI(t, server, "ack", "42", "--username", "jdoe", "--comment", "On it")
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/incidents/42/acknowledge", last.URL.Path)
+	assert.Equal(t, map[string]string{"username": "jdoe", "comment": "On it"}, *body)
+	assert.Equal(t, "incident 42 acknowledged by \"jdoe\"\n", out)
+
+	_, err = runWithAPI(t, server, "ack", "42")
+	assert.ErrorContains(t, err, "--username must be set")
+}
+
+func TestRunIncidents_Args(t *testing.T) {
+	t.Parallel()
+
+	server, _, _ := fakeAPI(t)
+
+	for name, args := range map[string][]string{
+		"UnknownCommand": {"rm", "42"},
+		"MissingID":      {"show"},
+		"ExtraArgs":      {"show", "42", "43"},
+		"InvalidID":      {"show", "abc"},
+		"Output":         {"ls", "--output", "yaml"},
+	} {
+		_, err := runWithAPI(t, server, args...)
+		assert.Error(t, err, name)
+	}
+
+	var stdout bytes.Buffer
+	assert.NoError(t, RunIncidents(context.Background(), []string{"ls", "-h"}, &stdout))
+	assert.Contains(t, stdout.String(), "-severity")
+}
+
+func TestDBSource_ReadOnly(t *testing.T) {
+	t.Parallel()
+
+	_, err := (&dbSource{}).acknowledge(context.Background(), 42, "jdoe", "")
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
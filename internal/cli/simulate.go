@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// zoneTimeFormat is used for the table output of simulations, including the UTC offset to tell apart the times
+// occurring twice when daylight saving time ends.
+const zoneTimeFormat = "2006-01-02 15:04:05 -07:00"
+
+const simulateUsage = `Usage: icinga-notifications simulate <command> [flags] <id>
+
+Commands:
+  schedule <id>     list who is on call in a schedule, including the times nobody is
+  timeperiod <id>   list when a time period is active
+
+Both commands require the API and list all changes within the range of --from and --to, by default the next week.
+Changes of the time zone's UTC offset, e.g., when daylight saving time starts, are listed as well.
+Run "icinga-notifications simulate <command> -h" for the flags of a command.
+`
+
+// RunSimulate runs the "simulate" subcommand with the arguments following it, writing its output to stdout.
+func RunSimulate(ctx context.Context, args []string, stdout io.Writer) error {
+	if err := runSimulate(ctx, args, stdout); !errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+
+	return nil
+}
+
+// scheduleSimulation is the response of the API's schedule simulation.
+type scheduleSimulation struct {
+	ID              int64                        `json:"id"`
+	Name            string                       `json:"name"`
+	Timezone        string                       `json:"timezone"`
+	Shifts          []*recipient.Shift           `json:"shifts"`
+	ZoneTransitions []*timeperiod.ZoneTransition `json:"zone_transitions"`
+}
+
+// timePeriodSimulation is the response of the API's time period simulation.
+type timePeriodSimulation struct {
+	ID              int64                        `json:"id"`
+	Name            string                       `json:"name"`
+	Timezone        string                       `json:"timezone"`
+	Intervals       []*timeperiod.Interval       `json:"intervals"`
+	ZoneTransitions []*timeperiod.ZoneTransition `json:"zone_transitions"`
+}
+
+func runSimulate(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		_, _ = fmt.Fprint(stdout, simulateUsage)
+		return nil
+	}
+	if args[0] != "schedule" && args[0] != "timeperiod" {
+		_, _ = fmt.Fprint(stdout, simulateUsage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	opts := &options{}
+	query := url.Values{}
+	fs := flag.NewFlagSet("simulate "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.StringVar(&opts.apiURL, "api-url", envOr("ICINGA_NOTIFICATIONS_API_URL", "http://localhost:5680"),
+		"base URL of the daemon's HTTP listener, also read from ICINGA_NOTIFICATIONS_API_URL")
+	fs.StringVar(&opts.token, "token", os.Getenv("ICINGA_NOTIFICATIONS_API_TOKEN"),
+		"API token, also read from ICINGA_NOTIFICATIONS_API_TOKEN")
+	fs.StringVar(&opts.output, "output", "table", "output format, either table or json")
+	for _, f := range [][2]string{
+		{"from", "start of the simulation, either a date like 2024-03-31 or an RFC 3339 time, defaults to now"},
+		{"to", "end of the simulation, either a date or an RFC 3339 time, defaults to a week after --from"},
+		{"timezone", "time zone of dates and the output, e.g., Europe/Berlin, defaults to the daemon's one"},
+	} {
+		fs.Func(f[0], f[1], func(value string) error {
+			query.Set(f[0], value)
+			return nil
+		})
+	}
+
+	positional, err := parseArgs(fs, args[1:], 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(positional[0])
+	if err != nil {
+		return err
+	}
+
+	if opts.output != "table" && opts.output != "json" {
+		return fmt.Errorf("--output must be either table or json, got %q", opts.output)
+	}
+	if opts.token == "" {
+		return errors.New("simulations require the API, use --token or ICINGA_NOTIFICATIONS_API_TOKEN")
+	}
+
+	api := &apiSource{baseURL: opts.apiURL, token: opts.token, client: &http.Client{Timeout: time.Minute}}
+	path := fmt.Sprintf("/v1/%ss/%d/simulation?%s", args[0], id, query.Encode())
+
+	if args[0] == "schedule" {
+		sim := &scheduleSimulation{}
+		if err := api.do(ctx, http.MethodGet, path, nil, sim); err != nil {
+			return err
+		}
+
+		return write(stdout, opts.output, sim, func(w io.Writer) {
+			_, _ = fmt.Fprintf(w, "Schedule %q (#%d), times in %s:\n\n", sim.Name, sim.ID, sim.Timezone)
+			_, _ = fmt.Fprintln(w, "START\tEND\tON CALL")
+
+			rows := make([]row, 0, len(sim.Shifts))
+			for _, shift := range sim.Shifts {
+				onCall := "NOBODY"
+				if len(shift.Contacts) > 0 {
+					names := make([]string, 0, len(shift.Contacts))
+					for _, c := range shift.Contacts {
+						names = append(names, contactName(c))
+					}
+					onCall = strings.Join(names, ", ")
+				}
+				rows = append(rows, row{shift.Start, fmt.Sprintf("%s\t%s\t%s\n",
+					shift.Start.Format(zoneTimeFormat), shift.End.Format(zoneTimeFormat), onCall)})
+			}
+			writeRows(w, rows, sim.ZoneTransitions)
+		})
+	}
+
+	sim := &timePeriodSimulation{}
+	if err := api.do(ctx, http.MethodGet, path, nil, sim); err != nil {
+		return err
+	}
+
+	return write(stdout, opts.output, sim, func(w io.Writer) {
+		_, _ = fmt.Fprintf(w, "Time period %q (#%d), times in %s:\n\n", sim.Name, sim.ID, sim.Timezone)
+		_, _ = fmt.Fprintln(w, "START\tEND\tSTATE")
+
+		rows := make([]row, 0, len(sim.Intervals))
+		for _, interval := range sim.Intervals {
+			state := "inactive"
+			if interval.Active {
+				state = "active"
+			}
+			rows = append(rows, row{interval.Start, fmt.Sprintf("%s\t%s\t%s\n",
+				interval.Start.Format(zoneTimeFormat), interval.End.Format(zoneTimeFormat), state)})
+		}
+		writeRows(w, rows, sim.ZoneTransitions)
+	})
+}
+
+// row of a simulation's table output, starting at the given time.
+type row struct {
+	start time.Time
+	line  string
+}
+
+// writeRows writes the rows interleaved with the zone transitions, each being listed before the first row starting at
+// or after it.
+func writeRows(w io.Writer, rows []row, transitions []*timeperiod.ZoneTransition) {
+	all := make([]row, 0, len(transitions)+len(rows))
+	for _, t := range transitions {
+		all = append(all, row{t.Time, fmt.Sprintf("%s\t\t-- time zone changes from %s to %s --\n",
+			t.Time.Format(zoneTimeFormat), t.From, t.To)})
+	}
+	// The stable sort keeps the zone transitions before the rows starting at them.
+	all = append(all, rows...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].start.Before(all[j].start) })
+
+	for _, r := range all {
+		_, _ = io.WriteString(w, r.line)
+	}
+}
+
+// contactName formats the contact's full name together with its username, if any.
+func contactName(c *recipient.ShiftContact) string {
+	if c.Username == "" {
+		return c.FullName
+	}
+
+	return fmt.Sprintf("%s (%s)", c.FullName, c.Username)
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRunSimulate(t *testing.T) {
+	t.Parallel()
+
+	var query url.Values
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/schedules/3/simulation", func(w http.ResponseWriter, req *http.Request) {
+		query = req.URL.Query()
+		_, _ = w.Write([]byte(`{"id": 3, "name": "DB On-Call", "timezone": "Europe/Berlin",
+			"shifts": [
+				{"start": "2024-03-31T00:00:00+01:00", "end": "2024-03-31T03:00:00+02:00",
+					"contacts": [{"id": 1, "full_name": "Jane Doe", "username": "jdoe"}]},
+				{"start": "2024-03-31T03:00:00+02:00", "end": "2024-04-01T00:00:00+02:00", "contacts": []}
+			],
+			"zone_transitions": [{"time": "2024-03-31T03:00:00+02:00", "from": "CET (+01:00)", "to": "CEST (+02:00)"}]}`))
+	})
+	mux.HandleFunc("GET /v1/timeperiods/{id}/simulation", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error": "time period not found"}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	run := func(args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := RunSimulate(context.Background(), append(args, "--api-url", server.URL, "--token", "secret"), &stdout)
+
+		return stdout.String(), err
+	}
+
+	out, err := run("schedule", "3", "--from", "2024-03-31", "--to", "2024-04-01", "--timezone", "Europe/Berlin")
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{"from": {"2024-03-31"}, "to": {"2024-04-01"}, "timezone": {"Europe/Berlin"}}, query)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 6)
+	assert.Equal(t, `Schedule "DB On-Call" (#3), times in Europe/Berlin:`, lines[0])
+	assert.Regexp(t, `^2024-03-31 00:00:00 \+01:00\s+2024-03-31 03:00:00 \+02:00\s+Jane Doe \(jdoe\)$`, lines[3])
+	assert.Regexp(t, `^2024-03-31 03:00:00 \+02:00\s+-- time zone changes from CET \(\+01:00\) to CEST \(\+02:00\) --$`, lines[4])
+	assert.Regexp(t, `^2024-03-31 03:00:00 \+02:00\s+2024-04-01 00:00:00 \+02:00\s+NOBODY$`, lines[5])
+
+	_, err = run("timeperiod", "7")
+	assert.ErrorContains(t, err, "time period not found")
+
+	_, err = run("rotation", "7")
+	assert.ErrorContains(t, err, `unknown command "rotation"`)
+
+	var stdout bytes.Buffer
+	err = RunSimulate(context.Background(), []string{"schedule", "3", "--token", ""}, &stdout)
+	assert.ErrorContains(t, err, "simulations require the API")
+}
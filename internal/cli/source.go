@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-notifications/internal/incident"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrReadOnly is returned by the database source for actions requiring the API, e.g., acknowledging an incident.
+var ErrReadOnly = errors.New("acting on incidents requires the API, use --token or ICINGA_NOTIFICATIONS_API_TOKEN")
+
+// source of incidents, either the API of a running daemon or, read-only, its database.
+type source interface {
+	list(ctx context.Context, query url.Values) ([]*incident.Summary, error)
+	get(ctx context.Context, id int64) (*incident.Summary, error)
+	history(ctx context.Context, id int64) (*incident.HistoryPage, error)
+	acknowledge(ctx context.Context, id int64, username, comment string) (string, error)
+}
+
+// apiSource talks to the versioned incident API, authenticated by a bearer token.
+type apiSource struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func (s *apiSource) list(ctx context.Context, query url.Values) ([]*incident.Summary, error) {
+	var incidents []*incident.Summary
+	err := s.do(ctx, http.MethodGet, "/v1/incidents?"+query.Encode(), nil, &incidents)
+
+	return incidents, err
+}
+
+func (s *apiSource) get(ctx context.Context, id int64) (*incident.Summary, error) {
+	summary := &incident.Summary{}
+	if err := s.do(ctx, http.MethodGet, "/v1/incidents/"+strconv.FormatInt(id, 10), nil, summary); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+func (s *apiSource) history(ctx context.Context, id int64) (*incident.HistoryPage, error) {
+	page := &incident.HistoryPage{}
+	query := url.Values{"limit": {strconv.Itoa(incident.MaxListLimit)}}
+	if err := s.do(ctx, http.MethodGet, fmt.Sprintf("/v1/incidents/%d/history?%s", id, query.Encode()), nil, page); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (s *apiSource) acknowledge(ctx context.Context, id int64, username, comment string) (string, error) {
+	body := map[string]string{"username": username, "comment": comment}
+
+	var res struct {
+		Message string `json:"message"`
+	}
+	err := s.do(ctx, http.MethodPost, fmt.Sprintf("/v1/incidents/%d/acknowledge", id), body, &res)
+
+	return res.Message, err
+}
+
+// do sends a request with the given JSON body, if any, to the API and decodes the response into v.
+//
+// Error responses are returned as an error carrying the API's error message.
+func (s *apiSource) do(ctx context.Context, method, path string, body, v any) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(s.baseURL, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return fmt.Errorf("API responded with %s", res.Status)
+		}
+
+		return fmt.Errorf("API responded with %s: %s", res.Status, apiErr.Error)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("cannot parse API response: %w", err)
+	}
+
+	return nil
+}
+
+// dbSource reads the incidents from the database directly, e.g., if the daemon or its API is unavailable.
+type dbSource struct {
+	db *database.DB
+}
+
+func (s *dbSource) list(ctx context.Context, query url.Values) ([]*incident.Summary, error) {
+	f, err := incident.ParseListFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return incident.List(ctx, s.db, f)
+}
+
+func (s *dbSource) get(ctx context.Context, id int64) (*incident.Summary, error) {
+	return incident.GetSummary(ctx, s.db, id)
+}
+
+func (s *dbSource) history(ctx context.Context, id int64) (*incident.HistoryPage, error) {
+	return incident.ListHistory(ctx, s.db, &incident.HistoryFilter{IncidentID: id, Limit: incident.MaxListLimit})
+}
+
+func (s *dbSource) acknowledge(context.Context, int64, string, string) (string, error) {
+	return "", ErrReadOnly
+}
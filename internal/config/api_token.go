@@ -0,0 +1,157 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"go.uber.org/zap/zapcore"
+)
+
+// ApiCapability is a scope an ApiToken can be granted, limiting which listener endpoints it may use.
+type ApiCapability string
+
+const (
+	// ApiCapabilityIngestEvents allows submitting events and object dependencies on behalf of the token's source.
+	ApiCapabilityIngestEvents ApiCapability = "ingest_events"
+
+	// ApiCapabilityReadIncidents allows reading incidents, their history and the configuration exposed by the API.
+	ApiCapabilityReadIncidents ApiCapability = "read_incidents"
+
+	// ApiCapabilityManageIncidents allows acting on incidents, e.g., acknowledging, closing or watching them.
+	ApiCapabilityManageIncidents ApiCapability = "manage_incidents"
+)
+
+// ApiToken authenticates requests to the HTTP API as a bearer token, limited to the capabilities granted to it.
+//
+// Only the SHA-256 hash of the token is stored. As tokens are expected to be long random strings, a slow password hash
+// like bcrypt is not necessary, allowing to authenticate each request cheaply.
+//
+// A token may be bound to a source, on whose behalf it submits events. This replaces the source's listener password,
+// allowing multiple tokens per source, each with its own capabilities, to be created and revoked independently.
+//
+// A token of a tenant only accesses the incidents of the sources and the contacts visible to this tenant, see
+// RuntimeConfig.GetApiTokenTenant. Tokens without a tenant access all of them.
+type ApiToken struct {
+	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+	baseconf.TenantEntry                 `db:",inline"`
+
+	Name      string       `db:"name"`
+	TokenHash types.Binary `db:"token_hash" json:"-"`
+	SourceID  types.Int    `db:"source_id"`
+
+	IngestEvents    types.Bool `db:"ingest_events"`
+	ReadIncidents   types.Bool `db:"read_incidents"`
+	ManageIncidents types.Bool `db:"manage_incidents"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (t *ApiToken) TableName() string {
+	return "api_token"
+}
+
+// IncrementalInitAndValidate implements the IncrementalConfigurableInitAndValidatable interface.
+func (t *ApiToken) IncrementalInitAndValidate() error {
+	if len(t.TokenHash) != sha256.Size {
+		return errors.New("token_hash must be a SHA-256 hash")
+	}
+	if t.IngestEvents.Bool && !t.SourceID.Valid {
+		return errors.New("ingest_events requires the token to be bound to a source_id")
+	}
+
+	return nil
+}
+
+// Allows returns whether the token was granted the capability.
+func (t *ApiToken) Allows(capability ApiCapability) bool {
+	switch capability {
+	case ApiCapabilityIngestEvents:
+		return t.IngestEvents.Bool
+	case ApiCapabilityReadIncidents:
+		return t.ReadIncidents.Bool
+	case ApiCapabilityManageIncidents:
+		return t.ManageIncidents.Bool
+	default:
+		return false
+	}
+}
+
+// MarshalLogObject implements the zapcore.ObjectMarshaler interface.
+func (t *ApiToken) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddInt64("id", t.ID)
+	encoder.AddString("name", t.Name)
+	if t.TenantID.Valid {
+		encoder.AddInt64("tenant_id", t.TenantID.Int64)
+	}
+	if t.SourceID.Valid {
+		encoder.AddInt64("source_id", t.SourceID.Int64)
+	}
+	return nil
+}
+
+// applyPendingApiTokens synchronizes changed API tokens.
+func (r *RuntimeConfig) applyPendingApiTokens() {
+	incrementalApplyPending(
+		r,
+		&r.ApiTokens, &r.configChange.ApiTokens,
+		nil,
+		func(curElement, update *ApiToken) error {
+			curElement.ChangedAt = update.ChangedAt
+			curElement.TenantEntry = update.TenantEntry
+			curElement.Name = update.Name
+			curElement.TokenHash = update.TokenHash
+			curElement.SourceID = update.SourceID
+			curElement.IngestEvents = update.IngestEvents
+			curElement.ReadIncidents = update.ReadIncidents
+			curElement.ManageIncidents = update.ManageIncidents
+
+			return nil
+		},
+		nil)
+}
+
+// GetApiToken returns the API token matching the given bearer token, or nil if there is none.
+func (r *RuntimeConfig) GetApiToken(token string) *ApiToken {
+	r.RLock()
+	defer r.RUnlock()
+
+	if token == "" {
+		return nil
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	for _, apiToken := range r.ApiTokens {
+		if subtle.ConstantTimeCompare(apiToken.TokenHash, hash[:]) == 1 {
+			return apiToken
+		}
+	}
+
+	return nil
+}
+
+// GetApiTokenSource returns the source the API token is bound to, or nil if it is not bound to a known source.
+func (r *RuntimeConfig) GetApiTokenSource(apiToken *ApiToken) *Source {
+	r.RLock()
+	defer r.RUnlock()
+
+	if !apiToken.SourceID.Valid {
+		return nil
+	}
+
+	return r.Sources[apiToken.SourceID.Int64]
+}
+
+// GetApiTokenTenant returns the tenant the API token is scoped to, which is invalid for tokens accessing all tenants.
+//
+// A token without a tenant of its own, but bound to a source, is scoped to the tenant of this source.
+func (r *RuntimeConfig) GetApiTokenTenant(apiToken *ApiToken) types.Int {
+	r.RLock()
+	defer r.RUnlock()
+
+	if !apiToken.TenantID.Valid && apiToken.SourceID.Valid {
+		return r.GetSourceTenant(apiToken.SourceID.Int64)
+	}
+
+	return apiToken.TenantID
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRuntimeConfig_GetApiToken(t *testing.T) {
+	t.Parallel()
+
+	hash := sha256.Sum256([]byte("secret-token"))
+	token := &ApiToken{Name: "chatbot", TokenHash: hash[:]}
+	token.ID = 1
+	assert.NoError(t, token.IncrementalInitAndValidate())
+	assert.Error(t, (&ApiToken{TokenHash: []byte("plain")}).IncrementalInitAndValidate())
+
+	r := &RuntimeConfig{}
+	r.ApiTokens = map[int64]*ApiToken{token.ID: token}
+
+	assert.Same(t, token, r.GetApiToken("secret-token"))
+	assert.Nil(t, r.GetApiToken("other-token"))
+	assert.Nil(t, r.GetApiToken(""))
+}
+
+func TestApiToken_Allows(t *testing.T) {
+	t.Parallel()
+
+	yes := types.Bool{Bool: true, Valid: true}
+	hash := sha256.Sum256([]byte("secret-token"))
+
+	token := &ApiToken{TokenHash: hash[:], ReadIncidents: yes}
+	assert.True(t, token.Allows(ApiCapabilityReadIncidents))
+	assert.False(t, token.Allows(ApiCapabilityManageIncidents))
+	assert.False(t, token.Allows(ApiCapabilityIngestEvents))
+	assert.False(t, token.Allows("unknown"))
+
+	token.IngestEvents = yes
+	assert.Error(t, token.IncrementalInitAndValidate(), "ingesting events requires a source")
+
+	token.SourceID = types.Int{NullInt64: sql.NullInt64{Int64: 2, Valid: true}}
+	assert.NoError(t, token.IncrementalInitAndValidate())
+	assert.True(t, token.Allows(ApiCapabilityIngestEvents))
+
+	r := &RuntimeConfig{}
+	r.Sources = map[int64]*Source{2: {Name: "Webhook"}}
+	assert.Same(t, r.Sources[2], r.GetApiTokenSource(token))
+	assert.Nil(t, r.GetApiTokenSource(&ApiToken{}), "token is not bound to a source")
+}
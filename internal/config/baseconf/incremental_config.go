@@ -43,3 +43,17 @@ type IncrementalPkDbEntry[PK comparable] struct {
 func (i IncrementalPkDbEntry[PK]) GetPrimaryKey() PK {
 	return i.ID
 }
+
+// TenantEntry contains the tenant_id column of the configuration objects which can be scoped to a tenant.
+//
+// Objects without a tenant are shared by all tenants. This type needs to be embedded with the _`db:",inline"`_ struct
+// tag as well.
+type TenantEntry struct {
+	TenantID types.Int `db:"tenant_id"`
+}
+
+// VisibleTo reports whether this entry may be used within the given tenant, i.e., if either of them has no tenant or
+// both have the same one.
+func (t TenantEntry) VisibleTo(tenantID types.Int) bool {
+	return !t.TenantID.Valid || !tenantID.Valid || t.TenantID.Int64 == tenantID.Int64
+}
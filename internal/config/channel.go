@@ -19,6 +19,7 @@ func (r *RuntimeConfig) applyPendingChannels() {
 		},
 		func(curElement, update *channel.Channel) error {
 			curElement.ChangedAt = update.ChangedAt
+			curElement.TenantEntry = update.TenantEntry
 			curElement.Name = update.Name
 			curElement.Type = update.Type
 			curElement.Config = update.Config
@@ -30,3 +31,14 @@ func (r *RuntimeConfig) applyPendingChannels() {
 			return nil
 		})
 }
+
+// RescanChannelPlugins lets all channels restart their plugins if the executable was updated or start them right away
+// if they could not be started so far, see channel.Channel.Rescan.
+func (r *RuntimeConfig) RescanChannelPlugins() {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, ch := range r.Channels {
+		ch.Rescan()
+	}
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/logging"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+// initListenerClientCert validates the source's client certificate columns and parses its CA, if any.
+//
+// A source authenticating via mutual TLS requires its listener_client_name, being the Common Name or a DNS Subject
+// Alternative Name of its client certificate, and either its listener_client_ca_pem or its
+// listener_client_fingerprint, or both, to verify the certificate, see VerifyClientCertificate.
+func (source *Source) initListenerClientCert() error {
+	source.listenerClientCAs = nil
+	source.listenerClientFingerprint = nil
+
+	hasCA := source.ListenerClientCAPem.Valid && source.ListenerClientCAPem.String != ""
+	hasFingerprint := source.ListenerClientFingerprint.Valid && source.ListenerClientFingerprint.String != ""
+	if !source.ListenerClientName.Valid || source.ListenerClientName.String == "" {
+		if hasCA || hasFingerprint {
+			return errors.New("listener_client_ca_pem and listener_client_fingerprint require listener_client_name")
+		}
+		return nil
+	}
+	if !hasCA && !hasFingerprint {
+		return errors.New("listener_client_name requires either listener_client_ca_pem or listener_client_fingerprint")
+	}
+
+	if hasCA {
+		source.listenerClientCAs = x509.NewCertPool()
+		if !source.listenerClientCAs.AppendCertsFromPEM([]byte(source.ListenerClientCAPem.String)) {
+			return errors.New("listener_client_ca_pem does not contain any PEM-encoded certificate")
+		}
+	}
+
+	if hasFingerprint {
+		// Fingerprints are commonly written as colon-separated hex bytes, e.g., by "openssl x509 -fingerprint".
+		fingerprint, err := hex.DecodeString(strings.ReplaceAll(source.ListenerClientFingerprint.String, ":", ""))
+		if err != nil || len(fingerprint) != sha256.Size {
+			return errors.New("listener_client_fingerprint must be a hex-encoded SHA-256 fingerprint")
+		}
+		source.listenerClientFingerprint = fingerprint
+	}
+
+	return nil
+}
+
+// MatchesClientCertificate returns whether the certificate carries the source's listener_client_name, either as its
+// Common Name or as one of its DNS Subject Alternative Names. The certificate is not verified, see
+// VerifyClientCertificate.
+func (source *Source) MatchesClientCertificate(cert *x509.Certificate) bool {
+	name := source.ListenerClientName.String
+	if !source.ListenerClientName.Valid || name == "" {
+		return false
+	}
+
+	if strings.EqualFold(cert.Subject.CommonName, name) {
+		return true
+	}
+	for _, dnsName := range cert.DNSNames {
+		if strings.EqualFold(dnsName, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VerifyClientCertificate verifies the client certificate chain, leaf first, as presented via TLS at the given time.
+//
+// With a listener_client_ca_pem, the leaf must be valid for client authentication and chain up to one of its CAs,
+// possibly via the presented intermediates. With a listener_client_fingerprint, the leaf's SHA-256 fingerprint must
+// match, allowing self-signed certificates. If both are configured, both checks must pass.
+func (source *Source) VerifyClientCertificate(chain []*x509.Certificate, t time.Time) error {
+	if len(chain) == 0 {
+		return errors.New("no client certificate presented")
+	}
+	leaf := chain[0]
+
+	if source.listenerClientFingerprint != nil {
+		fingerprint := sha256.Sum256(leaf.Raw)
+		if subtle.ConstantTimeCompare(fingerprint[:], source.listenerClientFingerprint) != 1 {
+			return errors.New("client certificate fingerprint does not match")
+		}
+	}
+
+	if source.listenerClientCAs != nil {
+		intermediates := x509.NewCertPool()
+		for _, cert := range chain[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		_, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         source.listenerClientCAs,
+			Intermediates: intermediates,
+			CurrentTime:   t,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		if err != nil {
+			return fmt.Errorf("cannot verify client certificate: %w", err)
+		}
+	}
+
+	if source.listenerClientFingerprint == nil && source.listenerClientCAs == nil {
+		return errors.New("source does not accept client certificates")
+	}
+
+	return nil
+}
+
+// GetSourceFromClientCertificate returns the source whose listener_client_name matches the presented client
+// certificate chain and which successfully verifies it, see Source.VerifyClientCertificate. Otherwise, nil is returned.
+func (r *RuntimeConfig) GetSourceFromClientCertificate(chain []*x509.Certificate, logger *logging.Logger) *Source {
+	r.RLock()
+	defer r.RUnlock()
+
+	if len(chain) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for _, source := range r.Sources {
+		if !source.MatchesClientCertificate(chain[0]) {
+			continue
+		}
+
+		if err := source.VerifyClientCertificate(chain, now); err != nil {
+			logger.Debugw("Cannot verify client certificate for this source", zap.Int64("id", source.ID),
+				zap.String("subject", chain[0].Subject.String()), zap.Error(err))
+			continue
+		}
+
+		return source
+	}
+
+	logger.Debugw("No source accepts the client certificate", zap.String("subject", chain[0].Subject.String()))
+	return nil
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestSource_VerifyClientCertificate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	newCert := func(
+		template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey,
+	) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		template.SerialNumber = big.NewInt(now.UnixNano())
+		template.NotBefore = now.Add(-time.Hour)
+		template.NotAfter = now.Add(time.Hour)
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return cert, key
+	}
+
+	ca, caKey := newCert(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Sources CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	client, _ := newCert(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "prometheus"},
+		DNSNames:    []string{"alertmanager.example.com"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	server, _ := newCert(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "prometheus"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	selfSigned, _ := newCert(&x509.Certificate{Subject: pkix.Name{CommonName: "prometheus"}}, nil, nil)
+
+	caPem := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+	fingerprint := sha256.Sum256(selfSigned.Raw)
+
+	t.Run("Validation", func(t *testing.T) {
+		t.Parallel()
+
+		assert.NoError(t, (&Source{}).IncrementalInitAndValidate())
+		assert.Error(t, (&Source{ListenerClientName: types.MakeString("prometheus")}).IncrementalInitAndValidate())
+		assert.Error(t, (&Source{ListenerClientCAPem: types.MakeString(caPem)}).IncrementalInitAndValidate())
+		assert.Error(t, (&Source{
+			ListenerClientName:  types.MakeString("prometheus"),
+			ListenerClientCAPem: types.MakeString("not a certificate"),
+		}).IncrementalInitAndValidate())
+		assert.Error(t, (&Source{
+			ListenerClientName:        types.MakeString("prometheus"),
+			ListenerClientFingerprint: types.MakeString("abcdef"),
+		}).IncrementalInitAndValidate())
+	})
+
+	t.Run("CA", func(t *testing.T) {
+		t.Parallel()
+
+		source := &Source{
+			ListenerClientName:  types.MakeString("Alertmanager.example.com"),
+			ListenerClientCAPem: types.MakeString(caPem),
+		}
+		require.NoError(t, source.IncrementalInitAndValidate())
+
+		assert.True(t, source.MatchesClientCertificate(client), "names should match case-insensitively")
+		assert.NoError(t, source.VerifyClientCertificate([]*x509.Certificate{client}, now))
+		assert.Error(t, source.VerifyClientCertificate([]*x509.Certificate{client}, now.Add(2*time.Hour)), "expired")
+		assert.Error(t, source.VerifyClientCertificate([]*x509.Certificate{server}, now), "not for client auth")
+		assert.Error(t, source.VerifyClientCertificate([]*x509.Certificate{selfSigned}, now), "foreign CA")
+		assert.Error(t, source.VerifyClientCertificate(nil, now))
+	})
+
+	t.Run("Fingerprint", func(t *testing.T) {
+		t.Parallel()
+
+		hexFingerprint := hex.EncodeToString(fingerprint[:])
+		source := &Source{
+			ListenerClientName:        types.MakeString("prometheus"),
+			ListenerClientFingerprint: types.MakeString(hexFingerprint),
+		}
+		require.NoError(t, source.IncrementalInitAndValidate())
+
+		assert.NoError(t, source.VerifyClientCertificate([]*x509.Certificate{selfSigned}, now))
+		assert.Error(t, source.VerifyClientCertificate([]*x509.Certificate{client}, now))
+	})
+
+	t.Run("GetSourceFromClientCertificate", func(t *testing.T) {
+		t.Parallel()
+
+		r := &RuntimeConfig{}
+		r.Sources = map[int64]*Source{
+			1: {ListenerClientName: types.MakeString("prometheus"), ListenerClientCAPem: types.MakeString(caPem)},
+			2: {ListenerClientName: types.MakeString("grafana"), ListenerClientCAPem: types.MakeString(caPem)},
+			3: {},
+		}
+		for id, source := range r.Sources {
+			source.ID = id
+			require.NoError(t, source.IncrementalInitAndValidate())
+		}
+
+		logger := logging.NewLogger(zaptest.NewLogger(t).Sugar(), time.Hour)
+		assert.Same(t, r.Sources[1], r.GetSourceFromClientCertificate([]*x509.Certificate{client}, logger))
+		assert.Nil(t, r.GetSourceFromClientCertificate([]*x509.Certificate{selfSigned}, logger), "not verified")
+		assert.Nil(t, r.GetSourceFromClientCertificate(nil, logger))
+	})
+}
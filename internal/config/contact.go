@@ -14,9 +14,13 @@ func (r *RuntimeConfig) applyPendingContacts() {
 		nil,
 		func(curElement, update *recipient.Contact) error {
 			curElement.ChangedAt = update.ChangedAt
+			curElement.TenantEntry = update.TenantEntry
 			curElement.FullName = update.FullName
 			curElement.Username = update.Username
 			curElement.DefaultChannelID = update.DefaultChannelID
+			curElement.OutOfOffice = update.OutOfOffice
+			curElement.SubstituteContactID = update.SubstituteContactID
+			curElement.QuietHoursTimePeriodID = update.QuietHoursTimePeriodID
 			return nil
 		},
 		nil)
@@ -54,4 +58,78 @@ func (r *RuntimeConfig) applyPendingContacts() {
 			})
 			return nil
 		})
+
+	incrementalApplyPending(
+		r,
+		&r.ContactOptOuts, &r.configChange.ContactOptOuts,
+		func(newElement *recipient.OptOut) error {
+			contact, ok := r.Contacts[newElement.ContactID]
+			if !ok {
+				return fmt.Errorf("contact opt-out refers unknown contact %d", newElement.ContactID)
+			}
+
+			contact.OptOuts = append(contact.OptOuts, newElement)
+			return nil
+		},
+		func(curElement, update *recipient.OptOut) error {
+			if curElement.ContactID != update.ContactID {
+				return errRemoveAndAddInstead
+			}
+
+			curElement.ChangedAt = update.ChangedAt
+			curElement.ChannelID = update.ChannelID
+			curElement.Reason = update.Reason
+			curElement.CreatedBy = update.CreatedBy
+			curElement.CreatedAt = update.CreatedAt
+			curElement.ExpiresAt = update.ExpiresAt
+			curElement.EndedAt = update.EndedAt
+			curElement.EndedBy = update.EndedBy
+			return nil
+		},
+		func(delElement *recipient.OptOut) error {
+			contact, ok := r.Contacts[delElement.ContactID]
+			if !ok {
+				return nil
+			}
+
+			contact.OptOuts = slices.DeleteFunc(contact.OptOuts, func(optOut *recipient.OptOut) bool {
+				return optOut.ID == delElement.ID
+			})
+			return nil
+		})
+
+	incrementalApplyPending(
+		r,
+		&r.ContactChannelPreferences, &r.configChange.ContactChannelPreferences,
+		func(newElement *recipient.ChannelPreference) error {
+			contact, ok := r.Contacts[newElement.ContactID]
+			if !ok {
+				return fmt.Errorf("contact channel preference refers unknown contact %d", newElement.ContactID)
+			}
+
+			contact.ChannelPreferences = append(contact.ChannelPreferences, newElement)
+			return nil
+		},
+		func(curElement, update *recipient.ChannelPreference) error {
+			if curElement.ContactID != update.ContactID {
+				return errRemoveAndAddInstead
+			}
+
+			curElement.ChangedAt = update.ChangedAt
+			curElement.ChannelID = update.ChannelID
+			curElement.MinSeverity = update.MinSeverity
+			return nil
+		},
+		func(delElement *recipient.ChannelPreference) error {
+			contact, ok := r.Contacts[delElement.ContactID]
+			if !ok {
+				return nil
+			}
+
+			contact.ChannelPreferences = slices.DeleteFunc(contact.ChannelPreferences,
+				func(preference *recipient.ChannelPreference) bool {
+					return preference.ID == delElement.ID
+				})
+			return nil
+		})
 }
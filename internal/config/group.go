@@ -54,3 +54,41 @@ func (r *RuntimeConfig) applyPendingGroups() {
 			return nil
 		})
 }
+
+// applyPendingGroupRegions synchronizes changed follow-the-sun group regions.
+func (r *RuntimeConfig) applyPendingGroupRegions() {
+	incrementalApplyPending(
+		r,
+		&r.groupRegions, &r.configChange.groupRegions,
+		func(newElement *recipient.GroupRegion) error {
+			group, ok := r.Groups[newElement.GroupID]
+			if !ok {
+				return fmt.Errorf("group region refers unknown group %d", newElement.GroupID)
+			}
+
+			newElement.RegionGroup, ok = r.Groups[newElement.RegionGroupID]
+			if !ok {
+				return fmt.Errorf("group region refers unknown region group %d", newElement.RegionGroupID)
+			}
+
+			newElement.TimePeriod, ok = r.TimePeriods[newElement.TimePeriodID]
+			if !ok {
+				return fmt.Errorf("group region refers unknown time period %d", newElement.TimePeriodID)
+			}
+
+			group.Regions = append(group.Regions, newElement)
+			return nil
+		},
+		nil,
+		func(delElement *recipient.GroupRegion) error {
+			group, ok := r.Groups[delElement.GroupID]
+			if !ok {
+				return nil
+			}
+
+			group.Regions = slices.DeleteFunc(group.Regions, func(region *recipient.GroupRegion) bool {
+				return region.ID == delElement.ID
+			})
+			return nil
+		})
+}
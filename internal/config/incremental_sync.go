@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/icinga/icinga-go-library/database"
@@ -9,6 +10,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"strings"
 	"time"
 )
 
@@ -35,6 +37,73 @@ type IncrementalConfigurableInitAndValidatable interface {
 	IncrementalInitAndValidate() error
 }
 
+// incrementalFetcher fetches the changes of a single table into the RuntimeConfig.configChange, see incrementalFetch.
+type incrementalFetcher struct {
+	table string
+	// fetch the changes of the table, unless changed is false, in which case no changes are stored without a query.
+	fetch func(ctx context.Context, tx *sqlx.Tx, r *RuntimeConfig, changed bool) error
+}
+
+// newIncrementalFetcher creates an incrementalFetcher for the table of BaseT, storing its changes in
+// changeConfigSetField.
+func newIncrementalFetcher[
+	BaseT any,
+	PK comparable,
+	T interface {
+		*BaseT
+		IncrementalConfigurable[PK]
+	},
+](changeConfigSetField *map[PK]T) incrementalFetcher {
+	return incrementalFetcher{
+		table: database.TableName(T(nil)),
+		fetch: func(ctx context.Context, tx *sqlx.Tx, r *RuntimeConfig, changed bool) error {
+			if !changed {
+				*changeConfigSetField = make(map[PK]T)
+				return nil
+			}
+
+			return incrementalFetch(ctx, tx, r, changeConfigSetField)
+		},
+	}
+}
+
+// changedTables returns which tables of the fetchers have elements changed after the last known timestamp of the
+// table, see incrementalFetch.
+//
+// The latest changed_at timestamps of all tables are queried by a single statement, sparing the database from querying
+// each table on every synchronization, although most of the time, nothing has changed at all.
+func (r *RuntimeConfig) changedTables(
+	ctx context.Context, tx *sqlx.Tx, fetchers []incrementalFetcher,
+) (map[string]bool, error) {
+	queries := make([]string, 0, len(fetchers))
+	for _, f := range fetchers {
+		queries = append(queries, fmt.Sprintf(
+			`SELECT '%s' AS "table_name", MAX("changed_at") AS "changed_at" FROM "%s"`, f.table, f.table))
+	}
+
+	var rows []struct {
+		TableName string        `db:"table_name"`
+		ChangedAt sql.NullInt64 `db:"changed_at"`
+	}
+	if err := tx.SelectContext(ctx, &rows, strings.Join(queries, " UNION ALL ")); err != nil {
+		r.logger.Errorw("Cannot query latest configuration changes", zap.Error(err))
+		return nil, err
+	}
+
+	changed := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		if !row.ChangedAt.Valid {
+			// The table is empty.
+			continue
+		}
+
+		changedAt, ok := r.configChangeTimestamps[row.TableName]
+		changed[row.TableName] = !ok || row.ChangedAt.Int64 > time.Time(changedAt).UnixMilli()
+	}
+
+	return changed, nil
+}
+
 // incrementalFetch queries all recently changed elements of BaseT and stores them in changeConfigSetField.
 //
 // The RuntimeConfig.configChangeTimestamps map contains the last known timestamp for each BaseT table. Only those
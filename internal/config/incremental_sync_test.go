@@ -0,0 +1,120 @@
+package config
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/channel"
+	"github.com/icinga/icinga-notifications/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuntimeConfig_changedTables(t *testing.T) {
+	t.Parallel()
+
+	synced := time.UnixMilli(1_700_000_000_000)
+	latest := map[string]driver.Value{
+		"channel":    synced.UnixMilli(),
+		"contact":    synced.UnixMilli() + 1,
+		"rule":       synced.UnixMilli() - 1,
+		"source":     synced.UnixMilli(),
+		"timeperiod": nil,
+	}
+
+	fake := testutils.NewFakeDB(database.PostgreSQL, func(string, []any) (testutils.FakeResult, error) {
+		result := testutils.FakeResult{Columns: []string{"table_name", "changed_at"}}
+		for table, changedAt := range latest {
+			result.Rows = append(result.Rows, []driver.Value{table, changedAt})
+		}
+		return result, nil
+	})
+
+	r := &RuntimeConfig{
+		logger: logging.NewLogger(zaptest.NewLogger(t).Sugar(), time.Hour),
+		configChangeTimestamps: map[string]types.UnixMilli{
+			"channel":    types.UnixMilli(synced),
+			"contact":    types.UnixMilli(synced),
+			"rule":       types.UnixMilli(synced),
+			"timeperiod": types.UnixMilli(synced),
+		},
+	}
+
+	tx, err := fake.DB.Beginx()
+	require.NoError(t, err)
+	defer func() { _ = tx.Rollback() }()
+
+	fetchers := make([]incrementalFetcher, 0, len(latest))
+	for table := range latest {
+		fetchers = append(fetchers, incrementalFetcher{table: table})
+	}
+
+	changed, err := r.changedTables(context.Background(), tx, fetchers)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"channel": false, "contact": true, "rule": false, "source": true}, changed,
+		"only tables changed after their stored timestamp or without one must be fetched, except for empty ones")
+}
+
+func TestRuntimeConfig_incrementalFetchers(t *testing.T) {
+	t.Parallel()
+
+	fake := testutils.NewFakeDB(database.PostgreSQL, func(query string, _ []any) (testutils.FakeResult, error) {
+		result := testutils.FakeResult{Columns: []string{"table_name", "changed_at"}}
+		for _, stmt := range strings.Split(query, " UNION ALL ") {
+			var table string
+			if _, err := fmt.Sscanf(stmt, "SELECT '%s", &table); err != nil {
+				return testutils.FakeResult{}, err
+			}
+			// All tables are empty.
+			result.Rows = append(result.Rows, []driver.Value{strings.TrimSuffix(table, "'"), nil})
+		}
+		return result, nil
+	})
+
+	r := &RuntimeConfig{
+		logger:                 logging.NewLogger(zaptest.NewLogger(t).Sugar(), time.Hour),
+		configChangeTimestamps: make(map[string]types.UnixMilli),
+		configChange:           &ConfigSet{Channels: map[int64]*channel.Channel{1: {Name: "pending"}}},
+	}
+
+	tx, err := fake.DB.Beginx()
+	require.NoError(t, err)
+	defer func() { _ = tx.Rollback() }()
+
+	fetchers := r.incrementalFetchers()
+	changed, err := r.changedTables(context.Background(), tx, fetchers)
+	require.NoError(t, err)
+	assert.Empty(t, changed, "empty tables must not be fetched")
+
+	statements := fake.Statements()
+	require.Len(t, statements, 1, "latest changes of all tables must be queried by a single statement")
+	tables := make(map[string]bool)
+	for _, f := range fetchers {
+		assert.Falsef(t, tables[f.table], "table %q must be fetched once", f.table)
+		tables[f.table] = true
+
+		assert.Containsf(t, statements[0].Query,
+			fmt.Sprintf(`SELECT '%s' AS "table_name", MAX("changed_at") AS "changed_at" FROM "%s"`, f.table, f.table),
+			"latest change of table %q must be queried", f.table)
+	}
+
+	for _, f := range fetchers {
+		require.NoError(t, f.fetch(context.Background(), tx, r, changed[f.table]))
+	}
+	assert.Len(t, fake.Statements(), 1, "unchanged tables must not be queried")
+
+	pending := reflect.ValueOf(r.configChange).Elem()
+	for i := 0; i < pending.NumField(); i++ {
+		field := pending.Field(i)
+		assert.Falsef(t, field.IsNil(), "ConfigSet.%s lacks a fetcher", pending.Type().Field(i).Name)
+		assert.Zerof(t, field.Len(), "pending ConfigSet.%s must be reset", pending.Type().Field(i).Name)
+	}
+}
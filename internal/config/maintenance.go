@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/filter"
+	"github.com/icinga/icinga-notifications/internal/maintenance"
+	"go.uber.org/zap"
+	"time"
+)
+
+// applyPendingMaintenanceWindows synchronizes changed maintenance windows.
+func (r *RuntimeConfig) applyPendingMaintenanceWindows() {
+	incrementalApplyPending(
+		r,
+		&r.MaintenanceWindows, &r.configChange.MaintenanceWindows,
+		func(newElement *maintenance.Window) error {
+			if newElement.TimePeriodID.Valid {
+				tp, ok := r.TimePeriods[newElement.TimePeriodID.Int64]
+				if !ok {
+					return fmt.Errorf("maintenance window refers unknown time period %d", newElement.TimePeriodID.Int64)
+				}
+				newElement.TimePeriod = tp
+			}
+
+			return nil
+		},
+		func(curElement, update *maintenance.Window) error {
+			curElement.ChangedAt = update.ChangedAt
+			curElement.Name = update.Name
+			curElement.StartTime = update.StartTime
+			curElement.EndTime = update.EndTime
+
+			curElement.TimePeriodID = update.TimePeriodID
+			if curElement.TimePeriodID.Valid {
+				tp, ok := r.TimePeriods[curElement.TimePeriodID.Int64]
+				if !ok {
+					return fmt.Errorf("maintenance window refers unknown time period %d", curElement.TimePeriodID.Int64)
+				}
+				curElement.TimePeriod = tp
+			} else {
+				curElement.TimePeriod = nil
+			}
+
+			// ObjectFilter{,Expr} are being initialized by config.IncrementalConfigurableInitAndValidatable.
+			curElement.ObjectFilter = update.ObjectFilter
+			curElement.ObjectFilterExpr = update.ObjectFilterExpr
+
+			return nil
+		},
+		nil)
+}
+
+// GetMaintenanceWindow returns an active maintenance window covering the given object at the given time, or nil if
+// the object is not in maintenance. Windows failing to evaluate their object filter are skipped.
+func (r *RuntimeConfig) GetMaintenanceWindow(filterable filter.Filterable, t time.Time) *maintenance.Window {
+	for _, window := range r.MaintenanceWindows {
+		covers, err := window.Covers(filterable, t)
+		if err != nil {
+			r.logger.Warnw("Failed to evaluate object filter of maintenance window", zap.Object("maintenance_window", window),
+				zap.Error(err))
+			continue
+		}
+
+		if covers {
+			return window
+		}
+	}
+
+	return nil
+}
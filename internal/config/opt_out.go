@@ -0,0 +1,74 @@
+package config
+
+import (
+	"cmp"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"slices"
+	"time"
+)
+
+// OptOutGap is a rule escalation whose recipients have all opted out of the channels they would be notified by, i.e.,
+// notifications of this escalation are silently suppressed.
+type OptOutGap struct {
+	RuleID         int64    `json:"rule_id"`
+	RuleName       string   `json:"rule_name"`
+	EscalationID   int64    `json:"escalation_id"`
+	EscalationName string   `json:"escalation_name"`
+	Contacts       []string `json:"contacts"`
+
+	// Until is the earliest expiry of the involved opt-outs, i.e., when at least one recipient is notified again.
+	Until time.Time `json:"until"`
+}
+
+// OptOutGaps returns all rule escalations whose recipients at the given time have all opted out, see OptOutGap.
+//
+// Escalations without any recipient at that time, e.g., referencing an empty schedule, are not considered. The caller
+// must hold the lock obtained by RLock.
+func (r *RuntimeConfig) OptOutGaps(t time.Time) []*OptOutGap {
+	var gaps []*OptOutGap
+	for _, ru := range r.Rules {
+		for _, escalation := range ru.Escalations {
+			channels := make(rule.ContactChannels)
+			channels.LoadFromEscalationRecipients(escalation, t, rule.AlwaysNotifiable)
+			if len(channels) == 0 {
+				continue
+			}
+
+			gap := &OptOutGap{
+				RuleID:         ru.ID,
+				RuleName:       ru.Name,
+				EscalationID:   escalation.ID,
+				EscalationName: escalation.DisplayName(),
+			}
+			for contact, channelIDs := range channels {
+				for channelID := range channelIDs {
+					optOut := contact.OptedOut(channelID, t)
+					if optOut == nil {
+						gap = nil
+						break
+					}
+
+					if gap.Until.IsZero() || optOut.End().Before(gap.Until) {
+						gap.Until = optOut.End()
+					}
+				}
+				if gap == nil {
+					break
+				}
+
+				gap.Contacts = append(gap.Contacts, contact.FullName)
+			}
+
+			if gap != nil {
+				slices.Sort(gap.Contacts)
+				gaps = append(gaps, gap)
+			}
+		}
+	}
+
+	slices.SortFunc(gaps, func(a, b *OptOutGap) int {
+		return cmp.Or(cmp.Compare(a.RuleID, b.RuleID), cmp.Compare(a.EscalationID, b.EscalationID))
+	})
+
+	return gaps
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRuntimeConfig_OptOutGaps(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	newContact := func(id int64, name string, optOutsUntil ...time.Time) *recipient.Contact {
+		c := &recipient.Contact{FullName: name, DefaultChannelID: 1}
+		c.ID = id
+		for _, until := range optOutsUntil {
+			c.OptOuts = append(c.OptOuts, &recipient.OptOut{
+				ContactID: id,
+				CreatedAt: types.UnixMilli(now.Add(-time.Hour)),
+				ExpiresAt: types.UnixMilli(until),
+			})
+		}
+		return c
+	}
+
+	jane := newContact(1, "Jane", now.Add(2*time.Hour))
+	john := newContact(2, "John", now.Add(time.Hour))
+	alice := newContact(3, "Alice")
+
+	newEscalation := func(id int64, contacts ...*recipient.Contact) *rule.Escalation {
+		e := &rule.Escalation{RuleID: utils.ToDBInt(1)}
+		e.ID = id
+		for _, c := range contacts {
+			e.Recipients = append(e.Recipients, &rule.EscalationRecipient{
+				EscalationID: id,
+				Key:          recipient.ToKey(c),
+				Recipient:    c,
+			})
+		}
+		return e
+	}
+
+	silent := newEscalation(1, jane, john)
+	partial := newEscalation(2, jane, alice)
+	empty := newEscalation(3)
+
+	r := &RuntimeConfig{}
+	r.Rules = map[int64]*rule.Rule{1: {
+		Name:        "Linux Servers",
+		Escalations: map[int64]*rule.Escalation{silent.ID: silent, partial.ID: partial, empty.ID: empty},
+	}}
+	r.Rules[1].ID = 1
+
+	gaps := r.OptOutGaps(now)
+	require.Len(t, gaps, 1)
+	assert.Equal(t, &OptOutGap{
+		RuleID:         1,
+		RuleName:       "Linux Servers",
+		EscalationID:   silent.ID,
+		EscalationName: "[C] Jane, [C] John",
+		Contacts:       []string{"Jane", "John"},
+		Until:          now.Add(time.Hour),
+	}, gaps[0])
+
+	assert.Empty(t, r.OptOutGaps(now.Add(time.Hour)), "gaps should close once an opt-out expires")
+}
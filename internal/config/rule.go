@@ -2,12 +2,39 @@ package config
 
 import (
 	"fmt"
+	"github.com/icinga/icinga-go-library/types"
 	"github.com/icinga/icinga-notifications/internal/rule"
+	"maps"
 	"slices"
 )
 
-// applyPendingRules synchronizes changed rules.
+// applyPendingRules synchronizes changed rules, including their escalation policies.
 func (r *RuntimeConfig) applyPendingRules() {
+	incrementalApplyPending(
+		r,
+		&r.EscalationPolicies, &r.configChange.EscalationPolicies,
+		func(newElement *rule.EscalationPolicy) error {
+			newElement.Escalations = make(map[int64]*rule.Escalation)
+			return nil
+		},
+		func(curElement, update *rule.EscalationPolicy) error {
+			curElement.ChangedAt = update.ChangedAt
+			curElement.Name = update.Name
+			return nil
+		},
+		func(delElement *rule.EscalationPolicy) error {
+			// Rules still referencing the deleted policy keep its ID, but lose its escalations.
+			for _, ru := range r.Rules {
+				if ru.EscalationPolicy == delElement {
+					maps.DeleteFunc(ru.Escalations, func(id int64, _ *rule.Escalation) bool {
+						return delElement.Escalations[id] != nil
+					})
+					ru.EscalationPolicy = nil
+				}
+			}
+			return nil
+		})
+
 	incrementalApplyPending(
 		r,
 		&r.Rules, &r.configChange.Rules,
@@ -21,11 +48,15 @@ func (r *RuntimeConfig) applyPendingRules() {
 			}
 
 			newElement.Escalations = make(map[int64]*rule.Escalation)
-			return nil
+			return r.setRuleEscalationPolicy(newElement, newElement.EscalationPolicyID)
 		},
 		func(curElement, update *rule.Rule) error {
 			curElement.ChangedAt = update.ChangedAt
+			curElement.TenantEntry = update.TenantEntry
 			curElement.Name = update.Name
+			curElement.Description = update.Description
+			curElement.RunbookURL = update.RunbookURL
+			curElement.ChatChannel = update.ChatChannel
 
 			curElement.TimePeriodID = update.TimePeriodID
 			if curElement.TimePeriodID.Valid {
@@ -42,6 +73,10 @@ func (r *RuntimeConfig) applyPendingRules() {
 			curElement.ObjectFilter = update.ObjectFilter
 			curElement.ObjectFilterExpr = update.ObjectFilterExpr
 
+			if curElement.EscalationPolicyID != update.EscalationPolicyID {
+				return r.setRuleEscalationPolicy(curElement, update.EscalationPolicyID)
+			}
+
 			return nil
 		},
 		nil)
@@ -50,16 +85,31 @@ func (r *RuntimeConfig) applyPendingRules() {
 		r,
 		&r.ruleEscalations, &r.configChange.ruleEscalations,
 		func(newElement *rule.Escalation) error {
-			elementRule, ok := r.Rules[newElement.RuleID]
+			if newElement.PolicyID.Valid {
+				policy, ok := r.EscalationPolicies[newElement.PolicyID.Int64]
+				if !ok {
+					return fmt.Errorf("rule escalation refers unknown escalation policy %d", newElement.PolicyID.Int64)
+				}
+
+				policy.Escalations[newElement.ID] = newElement
+				for _, ru := range r.Rules {
+					if ru.EscalationPolicy == policy {
+						ru.Escalations[newElement.ID] = newElement
+					}
+				}
+				return nil
+			}
+
+			elementRule, ok := r.Rules[newElement.RuleID.Int64]
 			if !ok {
-				return fmt.Errorf("rule escalation refers unknown rule %d", newElement.RuleID)
+				return fmt.Errorf("rule escalation refers unknown rule %d", newElement.RuleID.Int64)
 			}
 
 			elementRule.Escalations[newElement.ID] = newElement
 			return nil
 		},
 		func(curElement, update *rule.Escalation) error {
-			if curElement.RuleID != update.RuleID {
+			if curElement.RuleID != update.RuleID || curElement.PolicyID != update.PolicyID {
 				return errRemoveAndAddInstead
 			}
 
@@ -68,12 +118,31 @@ func (r *RuntimeConfig) applyPendingRules() {
 			// Condition{,Expr} are being initialized by config.IncrementalConfigurableInitAndValidatable.
 			curElement.Condition = update.Condition
 			curElement.ConditionExpr = update.ConditionExpr
+			curElement.RenotifyInterval = update.RenotifyInterval
+			curElement.RenotifyIntervalRaw = update.RenotifyIntervalRaw
+			curElement.RequiredAcknowledgements = update.RequiredAcknowledgements
+			curElement.RequiredAcknowledgementsRaw = update.RequiredAcknowledgementsRaw
 			// TODO: synchronize Fallback{ForID,s} when implemented
 
 			return nil
 		},
 		func(delElement *rule.Escalation) error {
-			elementRule, ok := r.Rules[delElement.RuleID]
+			if delElement.PolicyID.Valid {
+				policy, ok := r.EscalationPolicies[delElement.PolicyID.Int64]
+				if !ok {
+					return nil
+				}
+
+				delete(policy.Escalations, delElement.ID)
+				for _, ru := range r.Rules {
+					if ru.EscalationPolicy == policy {
+						delete(ru.Escalations, delElement.ID)
+					}
+				}
+				return nil
+			}
+
+			elementRule, ok := r.Rules[delElement.RuleID.Int64]
 			if !ok {
 				return nil
 			}
@@ -112,3 +181,26 @@ func (r *RuntimeConfig) applyPendingRules() {
 			return nil
 		})
 }
+
+// setRuleEscalationPolicy lets the rule reference the escalation policy by its ID, replacing the escalations of the
+// previous policy within the rule's Escalations by those of the new one. An invalid ID removes the policy.
+func (r *RuntimeConfig) setRuleEscalationPolicy(ru *rule.Rule, policyID types.Int) error {
+	maps.DeleteFunc(ru.Escalations, func(_ int64, escalation *rule.Escalation) bool {
+		return escalation.PolicyID.Valid
+	})
+	ru.EscalationPolicyID = policyID
+	ru.EscalationPolicy = nil
+
+	if !policyID.Valid {
+		return nil
+	}
+
+	policy, ok := r.EscalationPolicies[policyID.Int64]
+	if !ok {
+		return fmt.Errorf("rule refers unknown escalation policy %d", policyID.Int64)
+	}
+
+	ru.EscalationPolicy = policy
+	maps.Copy(ru.Escalations, policy.Escalations)
+	return nil
+}
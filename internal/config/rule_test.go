@@ -0,0 +1,88 @@
+package config
+
+import (
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRuntimeConfig_applyPendingRules_EscalationPolicies(t *testing.T) {
+	t.Parallel()
+
+	newPolicy := func(id int64) *rule.EscalationPolicy {
+		p := &rule.EscalationPolicy{Name: "On-Call"}
+		p.ID = id
+		return p
+	}
+
+	newRule := func(id, policyID int64) *rule.Rule {
+		r := &rule.Rule{Name: "Rule", EscalationPolicyID: utils.ToDBInt(policyID)}
+		r.ID = id
+		return r
+	}
+
+	newEscalation := func(id, ruleID, policyID int64) *rule.Escalation {
+		e := &rule.Escalation{RuleID: utils.ToDBInt(ruleID), PolicyID: utils.ToDBInt(policyID)}
+		e.ID = id
+		require.NoError(t, e.IncrementalInitAndValidate())
+		return e
+	}
+
+	r := &RuntimeConfig{logger: logging.NewLogger(zaptest.NewLogger(t).Sugar(), time.Hour)}
+	apply := func(change *ConfigSet) {
+		r.configChange = change
+		r.applyPendingRules()
+		for id, ru := range r.Rules {
+			require.NoError(t, r.debugVerifyRule(id, ru))
+		}
+	}
+
+	apply(&ConfigSet{
+		EscalationPolicies: map[int64]*rule.EscalationPolicy{1: newPolicy(1), 2: newPolicy(2)},
+		Rules:              map[int64]*rule.Rule{1: newRule(1, 1), 2: newRule(2, 1), 3: newRule(3, 0)},
+		ruleEscalations: map[int64]*rule.Escalation{
+			11: newEscalation(11, 1, 0),
+			21: newEscalation(21, 0, 1),
+			22: newEscalation(22, 0, 1),
+			31: newEscalation(31, 0, 2),
+		},
+	})
+
+	escalationIDs := func(ruleID int64) []int64 {
+		var ids []int64
+		for id := range r.Rules[ruleID].Escalations {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	assert.Equal(t, []int64{11, 21, 22}, escalationIDs(1), "own and policy escalations")
+	assert.Equal(t, []int64{21, 22}, escalationIDs(2), "only policy escalations")
+	assert.Empty(t, escalationIDs(3), "neither own nor policy escalations")
+	assert.Same(t, r.EscalationPolicies[1].Escalations[21], r.Rules[2].Escalations[21], "escalations should be shared")
+	assert.Same(t, r.EscalationPolicies[2].Escalations[31], r.GetRuleEscalation(31), "unreferenced policy escalation")
+
+	// Switching the policy replaces its escalations, while deleting a policy escalation removes it from all rules.
+	apply(&ConfigSet{
+		Rules:           map[int64]*rule.Rule{1: newRule(1, 2)},
+		ruleEscalations: map[int64]*rule.Escalation{22: nil},
+	})
+	assert.Equal(t, []int64{11, 31}, escalationIDs(1))
+	assert.Equal(t, []int64{21}, escalationIDs(2))
+
+	// Deleting the policy removes its escalations from the rules still referencing it.
+	apply(&ConfigSet{EscalationPolicies: map[int64]*rule.EscalationPolicy{2: nil}})
+	assert.Equal(t, []int64{11}, escalationIDs(1))
+	assert.Nil(t, r.Rules[1].EscalationPolicy)
+
+	assert.Error(t, (&rule.Escalation{}).IncrementalInitAndValidate(), "escalation without rule or policy")
+	assert.Error(t, (&rule.Escalation{RuleID: utils.ToDBInt(1), PolicyID: utils.ToDBInt(1)}).IncrementalInitAndValidate(),
+		"escalation with both rule and policy")
+}
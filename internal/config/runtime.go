@@ -8,6 +8,8 @@ import (
 	"github.com/icinga/icinga-go-library/logging"
 	"github.com/icinga/icinga-go-library/types"
 	"github.com/icinga/icinga-notifications/internal/channel"
+	"github.com/icinga/icinga-notifications/internal/maintenance"
+	"github.com/icinga/icinga-notifications/internal/msgtemplate"
 	"github.com/icinga/icinga-notifications/internal/recipient"
 	"github.com/icinga/icinga-notifications/internal/rule"
 	"github.com/icinga/icinga-notifications/internal/timeperiod"
@@ -37,6 +39,9 @@ type RuntimeConfig struct {
 	configChangeAvailable  bool
 	configChangeTimestamps map[string]types.UnixMilli
 
+	// updateRequested wakes up PeriodicUpdates before its next tick, see RequestUpdate.
+	updateRequested chan struct{}
+
 	logs   *logging.Logging
 	logger *logging.Logger
 	db     *database.DB
@@ -54,6 +59,7 @@ func NewRuntimeConfig(
 		EventStreamLaunchFunc: esLaunch,
 
 		configChangeTimestamps: make(map[string]types.UnixMilli),
+		updateRequested:        make(chan struct{}, 1),
 
 		logs:   logs,
 		logger: logs.GetChildLogger("runtime-updates"),
@@ -65,18 +71,27 @@ type ConfigSet struct {
 	Channels         map[int64]*channel.Channel
 	Contacts         map[int64]*recipient.Contact
 	ContactAddresses map[int64]*recipient.Address
+	ContactOptOuts   map[int64]*recipient.OptOut
 	Groups           map[int64]*recipient.Group
 	TimePeriods      map[int64]*timeperiod.TimePeriod
 	Schedules        map[int64]*recipient.Schedule
 	Rules            map[int64]*rule.Rule
 	Sources          map[int64]*Source
 
+	ContactChannelPreferences map[int64]*recipient.ChannelPreference
+	EscalationPolicies        map[int64]*rule.EscalationPolicy
+	MaintenanceWindows        map[int64]*maintenance.Window
+	ApiTokens                 map[int64]*ApiToken
+	NotificationTemplates     map[int64]*msgtemplate.Template
+
 	// The following fields contain intermediate values, necessary for the incremental config synchronization.
 	// Furthermore, they allow accessing intermediate tables as everything is referred by pointers.
 	groupMembers             map[recipient.GroupMemberKey]*recipient.GroupMember
+	groupRegions             map[int64]*recipient.GroupRegion
 	timePeriodEntries        map[int64]*timeperiod.Entry
 	scheduleRotations        map[int64]*recipient.Rotation
 	scheduleRotationMembers  map[int64]*recipient.RotationMember
+	scheduleOverrides        map[int64]*recipient.ScheduleOverride
 	ruleEscalations          map[int64]*rule.Escalation
 	ruleEscalationRecipients map[int64]*rule.EscalationRecipient
 }
@@ -118,12 +133,25 @@ func (r *RuntimeConfig) PeriodicUpdates(ctx context.Context, interval time.Durat
 			if err := r.UpdateFromDatabase(ctx); err != nil {
 				r.logger.Errorw("Periodic configuration synchronization failed", zap.Error(err))
 			}
+		case <-r.updateRequested:
+			if err := r.UpdateFromDatabase(ctx); err != nil {
+				r.logger.Errorw("Requested configuration synchronization failed", zap.Error(err))
+			}
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// RequestUpdate lets PeriodicUpdates synchronize the configuration immediately instead of at its next tick, e.g., when
+// the database notified about a change. Requests made while one is already pending are coalesced.
+func (r *RuntimeConfig) RequestUpdate() {
+	select {
+	case r.updateRequested <- struct{}{}:
+	default:
+	}
+}
+
 // RLock locks the config for reading.
 func (r *RuntimeConfig) RLock() {
 	r.mu.RLock()
@@ -166,6 +194,14 @@ func (r *RuntimeConfig) GetRuleEscalation(escalationID int64) *rule.Escalation {
 		}
 	}
 
+	// Escalations of policies not referenced by any rule are still known, e.g., for the incidents' escalation states.
+	for _, p := range r.EscalationPolicies {
+		escalation, ok := p.Escalations[escalationID]
+		if ok {
+			return escalation
+		}
+	}
+
 	return nil
 }
 
@@ -181,6 +217,34 @@ func (r *RuntimeConfig) GetContact(username string) *recipient.Contact {
 	return nil
 }
 
+// GetTimePeriod returns the *timeperiod.TimePeriod having the given name, see timeperiod.TimePeriod.NameRaw.
+// Returns nil when there is no such time period. If multiple time periods share the name, the one with the lowest ID
+// is returned.
+func (r *RuntimeConfig) GetTimePeriod(name string) *timeperiod.TimePeriod {
+	var match *timeperiod.TimePeriod
+	for _, tp := range r.TimePeriods {
+		if tp.NameRaw.Valid && tp.NameRaw.String == name && (match == nil || tp.ID < match.ID) {
+			match = tp
+		}
+	}
+
+	return match
+}
+
+// GetContactByAddress returns the *recipient.Contact having an address of the given type, e.g., a Slack member ID.
+// Returns nil when no contact has such an address.
+func (r *RuntimeConfig) GetContactByAddress(addressType, address string) *recipient.Contact {
+	for _, contact := range r.Contacts {
+		for _, a := range contact.Addresses {
+			if a.Type == addressType && a.Address == address {
+				return contact
+			}
+		}
+	}
+
+	return nil
+}
+
 // GetSourceFromCredentials verifies a credential pair against known Sources.
 //
 // This method returns either a *Source or a nil pointer and logs the cause to the given logger. This is in almost all
@@ -236,24 +300,15 @@ func (r *RuntimeConfig) fetchFromDatabase(ctx context.Context) error {
 	// The transaction is only used for reading, never has to be committed.
 	defer func() { _ = tx.Rollback() }()
 
-	fetchFns := []func() error{
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.Channels) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.Contacts) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.ContactAddresses) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.Groups) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.groupMembers) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.Schedules) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.scheduleRotations) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.scheduleRotationMembers) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.TimePeriods) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.timePeriodEntries) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.Rules) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.ruleEscalations) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.ruleEscalationRecipients) },
-		func() error { return incrementalFetch(ctx, tx, r, &r.configChange.Sources) },
+	fetchers := r.incrementalFetchers()
+
+	changed, err := r.changedTables(ctx, tx, fetchers)
+	if err != nil {
+		return err
 	}
-	for _, f := range fetchFns {
-		if err := f(); err != nil {
+
+	for _, f := range fetchers {
+		if err := f.fetch(ctx, tx, r, changed[f.table]); err != nil {
 			return err
 		}
 	}
@@ -261,6 +316,35 @@ func (r *RuntimeConfig) fetchFromDatabase(ctx context.Context) error {
 	return nil
 }
 
+// incrementalFetchers returns an incrementalFetcher for each table of the ConfigSet, storing its changes in the
+// RuntimeConfig.configChange, in the order they are fetched.
+func (r *RuntimeConfig) incrementalFetchers() []incrementalFetcher {
+	return []incrementalFetcher{
+		newIncrementalFetcher(&r.configChange.Channels),
+		newIncrementalFetcher(&r.configChange.Contacts),
+		newIncrementalFetcher(&r.configChange.ContactAddresses),
+		newIncrementalFetcher(&r.configChange.ContactOptOuts),
+		newIncrementalFetcher(&r.configChange.ContactChannelPreferences),
+		newIncrementalFetcher(&r.configChange.Groups),
+		newIncrementalFetcher(&r.configChange.groupMembers),
+		newIncrementalFetcher(&r.configChange.groupRegions),
+		newIncrementalFetcher(&r.configChange.Schedules),
+		newIncrementalFetcher(&r.configChange.scheduleRotations),
+		newIncrementalFetcher(&r.configChange.scheduleRotationMembers),
+		newIncrementalFetcher(&r.configChange.scheduleOverrides),
+		newIncrementalFetcher(&r.configChange.TimePeriods),
+		newIncrementalFetcher(&r.configChange.timePeriodEntries),
+		newIncrementalFetcher(&r.configChange.EscalationPolicies),
+		newIncrementalFetcher(&r.configChange.Rules),
+		newIncrementalFetcher(&r.configChange.ruleEscalations),
+		newIncrementalFetcher(&r.configChange.ruleEscalationRecipients),
+		newIncrementalFetcher(&r.configChange.Sources),
+		newIncrementalFetcher(&r.configChange.MaintenanceWindows),
+		newIncrementalFetcher(&r.configChange.ApiTokens),
+		newIncrementalFetcher(&r.configChange.NotificationTemplates),
+	}
+}
+
 // applyPending synchronizes all changes.
 func (r *RuntimeConfig) applyPending() {
 	r.mu.Lock()
@@ -272,8 +356,12 @@ func (r *RuntimeConfig) applyPending() {
 		r.applyPendingGroups,
 		r.applyPendingSchedules,
 		r.applyPendingTimePeriods,
+		r.applyPendingGroupRegions, // Requires both groups and time periods.
 		r.applyPendingRules,
 		r.applyPendingSources,
+		r.applyPendingMaintenanceWindows, // Requires time periods.
+		r.applyPendingApiTokens,
+		r.applyPendingNotificationTemplates, // Requires both channels and rules.
 	}
 	for _, f := range applyFns {
 		f()
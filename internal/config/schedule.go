@@ -1,6 +1,7 @@
 package config
 
 import (
+	"cmp"
 	"fmt"
 	"github.com/icinga/icinga-notifications/internal/recipient"
 	"github.com/icinga/icinga-notifications/internal/timeperiod"
@@ -19,7 +20,9 @@ func (r *RuntimeConfig) applyPendingSchedules() {
 		nil,
 		func(curElement, update *recipient.Schedule) error {
 			curElement.ChangedAt = update.ChangedAt
+			curElement.TenantEntry = update.TenantEntry
 			curElement.Name = update.Name
+			curElement.IcsFeedURL = update.IcsFeedURL
 			return nil
 		},
 		nil)
@@ -106,6 +109,39 @@ func (r *RuntimeConfig) applyPendingSchedules() {
 			return nil
 		})
 
+	incrementalApplyPending(
+		r,
+		&r.scheduleOverrides, &r.configChange.scheduleOverrides,
+		func(newElement *recipient.ScheduleOverride) error {
+			schedule, ok := r.Schedules[newElement.ScheduleID]
+			if !ok {
+				return fmt.Errorf("schedule override refers unknown schedule %d", newElement.ScheduleID)
+			}
+
+			newElement.Contact, ok = r.Contacts[newElement.ContactID]
+			if !ok {
+				return fmt.Errorf("schedule override refers unknown contact %d", newElement.ContactID)
+			}
+
+			schedule.Overrides = append(schedule.Overrides, newElement)
+			slices.SortStableFunc(schedule.Overrides, func(a, b *recipient.ScheduleOverride) int {
+				return cmp.Compare(a.ID, b.ID)
+			})
+			return nil
+		},
+		nil,
+		func(delElement *recipient.ScheduleOverride) error {
+			schedule, ok := r.Schedules[delElement.ScheduleID]
+			if !ok {
+				return nil
+			}
+
+			schedule.Overrides = slices.DeleteFunc(schedule.Overrides, func(override *recipient.ScheduleOverride) bool {
+				return override.ID == delElement.ID
+			})
+			return nil
+		})
+
 	for id := range updatedScheduleIds {
 		schedule := r.Schedules[id]
 		r.logger.Debugw("Refreshing schedule rotations", zap.Inline(schedule))
@@ -1,10 +1,21 @@
 package config
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
 	"github.com/icinga/icinga-go-library/types"
 	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"github.com/icinga/icinga-notifications/internal/event"
 	"go.uber.org/zap/zapcore"
+	"io"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
 )
 
 // SourceTypeIcinga2 represents the "icinga2" Source Type for Event Stream API sources.
@@ -13,12 +24,22 @@ const SourceTypeIcinga2 = "icinga2"
 // Source entry within the ConfigSet to describe a source.
 type Source struct {
 	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+	baseconf.TenantEntry                 `db:",inline"`
 
 	Type string `db:"type"`
 	Name string `db:"name"`
 
 	ListenerPasswordHash types.String `db:"listener_password_hash"`
 
+	// The following columns authenticate the source via mutual TLS by its client certificate, see
+	// Source.VerifyClientCertificate.
+	ListenerClientName        types.String   `db:"listener_client_name"`
+	ListenerClientCAPem       types.String   `db:"listener_client_ca_pem"`
+	ListenerClientFingerprint types.String   `db:"listener_client_fingerprint"`
+	listenerClientCAs         *x509.CertPool `db:"-" json:"-"`
+	listenerClientFingerprint []byte         `db:"-" json:"-"`
+
+	// Icinga2BaseURL may list the API endpoints of an HA zone separated by commas, see Icinga2BaseURLs.
 	Icinga2BaseURL     types.String `db:"icinga2_base_url"`
 	Icinga2AuthUser    types.String `db:"icinga2_auth_user"`
 	Icinga2AuthPass    types.String `db:"icinga2_auth_pass"`
@@ -26,8 +47,43 @@ type Source struct {
 	Icinga2CommonName  types.String `db:"icinga2_common_name"`
 	Icinga2InsecureTLS types.Bool   `db:"icinga2_insecure_tls"`
 
+	TransformTemplate types.String       `db:"transform_template"`
+	transformTemplate *template.Template `db:"-" json:"-"`
+
+	// AutoCloseAfter optionally overrides the daemon's incident-auto-close-after in milliseconds.
+	AutoCloseAfter types.Int `db:"auto_close_after"`
+
+	// SeverityMapping optionally maps the severities of this source's events to other ones, encoded as a JSON object of
+	// severity names, e.g., {"warning": "crit"}, see MapSeverity.
+	SeverityMapping types.String                      `db:"severity_mapping"`
+	severityMapping map[event.Severity]event.Severity `db:"-" json:"-"`
+
+	// URLTemplate optionally replaces the URL of this source's events, e.g., to deep-link into the source's own UI, see
+	// RenderURL.
+	URLTemplate types.String       `db:"url_template"`
+	urlTemplate *template.Template `db:"-" json:"-"`
+
+	// Icinga2WriteBack enables writing acknowledgements and snoozes of incidents back to Icinga 2, see Icinga2Actions.
+	Icinga2WriteBack types.Bool `db:"icinga2_write_back"`
+
+	// Icinga2CustomVars optionally lists custom variables of hosts and services separated by commas, attached to the
+	// events of this source as extra tags, see Icinga2CustomVarNames.
+	Icinga2CustomVars types.String `db:"icinga2_custom_vars"`
+
 	// Icinga2SourceConf for Event Stream API sources, only if Source.Type == SourceTypeIcinga2.
 	Icinga2SourceCancel context.CancelFunc `db:"-" json:"-"`
+	// Icinga2Actions is set by the Event Stream API Client of this source if Icinga2WriteBack is enabled.
+	Icinga2Actions Icinga2Actions `db:"-" json:"-"`
+}
+
+// Icinga2Actions performs actions on Icinga 2 objects via the Icinga 2 API, identifying them by their event tags, i.e.,
+// "host" and optionally "service". This is implemented by the icinga2 package, which cannot be imported here.
+type Icinga2Actions interface {
+	// AcknowledgeProblem acknowledges the current problem of the object on behalf of the author.
+	AcknowledgeProblem(ctx context.Context, tags map[string]string, author, comment string) error
+
+	// ScheduleDowntime schedules a fixed downtime of the object between start and end on behalf of the author.
+	ScheduleDowntime(ctx context.Context, tags map[string]string, author, comment string, start, end time.Time) error
 }
 
 // MarshalLogObject implements the zapcore.ObjectMarshaler interface.
@@ -38,6 +94,189 @@ func (source *Source) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 	return nil
 }
 
+// IncrementalInitAndValidate implements the IncrementalConfigurableInitAndValidatable interface.
+func (source *Source) IncrementalInitAndValidate() error {
+	if err := source.initListenerClientCert(); err != nil {
+		return err
+	}
+
+	if source.TransformTemplate.Valid && source.TransformTemplate.String != "" {
+		if source.Type == SourceTypeIcinga2 {
+			return fmt.Errorf("transform_template is not supported for %q sources", SourceTypeIcinga2)
+		}
+
+		tmpl, err := template.New("transform_template").
+			Option("missingkey=zero").
+			Funcs(template.FuncMap{"json": transformTemplateJson}).
+			Parse(source.TransformTemplate.String)
+		if err != nil {
+			return fmt.Errorf("cannot parse transform_template: %w", err)
+		}
+
+		source.transformTemplate = tmpl
+	}
+
+	source.urlTemplate = nil
+	if source.URLTemplate.Valid && source.URLTemplate.String != "" {
+		tmpl, err := template.New("url_template").
+			Option("missingkey=zero").
+			Funcs(template.FuncMap{"pathescape": url.PathEscape, "queryescape": url.QueryEscape}).
+			Parse(source.URLTemplate.String)
+		if err != nil {
+			return fmt.Errorf("cannot parse url_template: %w", err)
+		}
+
+		source.urlTemplate = tmpl
+	}
+
+	source.severityMapping = nil
+	if source.SeverityMapping.Valid && source.SeverityMapping.String != "" {
+		var mapping map[string]string
+		if err := json.Unmarshal([]byte(source.SeverityMapping.String), &mapping); err != nil {
+			return fmt.Errorf("cannot parse severity_mapping: %w", err)
+		}
+
+		source.severityMapping = make(map[event.Severity]event.Severity, len(mapping))
+		for from, to := range mapping {
+			fromSeverity, err := event.GetSeverityByName(from)
+			if err != nil || fromSeverity == event.SeverityNone {
+				return fmt.Errorf("severity_mapping: cannot map unknown severity %q", from)
+			}
+			toSeverity, err := event.GetSeverityByName(to)
+			if err != nil || toSeverity == event.SeverityNone {
+				return fmt.Errorf("severity_mapping: cannot map %q to unknown severity %q", from, to)
+			}
+
+			source.severityMapping[fromSeverity] = toSeverity
+		}
+	}
+
+	return nil
+}
+
+// Icinga2BaseURLs returns the Icinga 2 API endpoints of this source, listed in Icinga2BaseURL separated by commas or
+// whitespace, e.g., "https://master1:5665, https://master2:5665".
+func (source *Source) Icinga2BaseURLs() []string {
+	return splitList(source.Icinga2BaseURL.String)
+}
+
+// Icinga2CustomVarNames returns the names of the custom variables listed in Icinga2CustomVars separated by commas or
+// whitespace, e.g., "os, owner".
+func (source *Source) Icinga2CustomVarNames() []string {
+	return splitList(source.Icinga2CustomVars.String)
+}
+
+// splitList splits a list separated by commas or whitespace, omitting empty elements.
+func splitList(list string) []string {
+	return strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// IncidentAutoCloseAfter returns after which inactivity this source's incidents are closed, either configured for this
+// source or defaulting to the given daemon-wide duration. A zero value disables closing incidents automatically.
+func (source *Source) IncidentAutoCloseAfter(defaultAfter time.Duration) time.Duration {
+	if source.AutoCloseAfter.Valid {
+		return time.Duration(source.AutoCloseAfter.Int64) * time.Millisecond
+	}
+
+	return defaultAfter
+}
+
+// MapSeverity replaces the severity of the given event of this source according to its severity_mapping, if any.
+//
+// Each severity is mapped at most once, i.e., {"warning": "err", "err": "crit"} maps warning to err and not to crit.
+// Thus, the event must not be mapped again, e.g., when retrying to process it.
+func (source *Source) MapSeverity(ev *event.Event) {
+	if mapped, ok := source.severityMapping[ev.Severity]; ok {
+		ev.Severity = mapped
+	}
+}
+
+// MapEventSeverity applies the severity_mapping of the event's source to it, see Source.MapSeverity.
+//
+// The event is left unchanged if its source is unknown.
+func (r *RuntimeConfig) MapEventSeverity(ev *event.Event) {
+	r.RLock()
+	defer r.RUnlock()
+
+	if source := r.Sources[ev.SourceId]; source != nil {
+		source.MapSeverity(ev)
+	}
+}
+
+// RenderURL replaces the URL of the given event of this source by its url_template, if any.
+//
+// The template is executed with the event as its data, e.g., {{.Tags.host}} or {{.URL}} for the URL sent by the source.
+// If the template fails or results in an invalid URL, an error is returned and the event is left unchanged.
+func (source *Source) RenderURL(ev *event.Event) error {
+	if source.urlTemplate == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := source.urlTemplate.Execute(&buf, ev); err != nil {
+		return fmt.Errorf("cannot execute url_template: %w", err)
+	}
+
+	rendered := strings.TrimSpace(buf.String())
+	if _, err := url.Parse(rendered); err != nil {
+		return fmt.Errorf("url_template resulted in an invalid URL: %w", err)
+	}
+
+	ev.URL = rendered
+	return nil
+}
+
+// RenderEventURL applies the url_template of the event's source to it, see Source.RenderURL.
+//
+// The event is left unchanged if its source is unknown.
+func (r *RuntimeConfig) RenderEventURL(ev *event.Event) error {
+	r.RLock()
+	defer r.RUnlock()
+
+	if source := r.Sources[ev.SourceId]; source != nil {
+		return source.RenderURL(ev)
+	}
+
+	return nil
+}
+
+// TransformEventBody converts a submitted event body into the JSON representation of an event.Event.
+//
+// Without a configured transform_template, the body is returned as it is. Otherwise, the body is decoded as arbitrary
+// JSON, passed as the data to the template and the template's output is returned.
+func (source *Source) TransformEventBody(body io.Reader) (io.Reader, error) {
+	if source.transformTemplate == nil {
+		return body, nil
+	}
+
+	var data any
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("cannot parse JSON body: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := source.transformTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("cannot execute transform_template: %w", err)
+	}
+
+	return &buf, nil
+}
+
+// transformTemplateJson is available as the "json" function within a Source's transform_template.
+//
+// It encodes its argument as JSON, allowing to safely embed strings or whole objects into the event's JSON output.
+func transformTemplateJson(v any) (string, error) {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
 // applyPendingSources synchronizes changed sources.
 func (r *RuntimeConfig) applyPendingSources() {
 	incrementalApplyPending(
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSource_TransformEventBody(t *testing.T) {
+	t.Parallel()
+
+	t.Run("WithoutTemplate", func(t *testing.T) {
+		t.Parallel()
+
+		source := &Source{Type: "other"}
+		require.NoError(t, source.IncrementalInitAndValidate())
+
+		body := strings.NewReader(`{"name": "foo"}`)
+		out, err := source.TransformEventBody(body)
+		require.NoError(t, err)
+		assert.Same(t, body, out, "body should be passed through unchanged")
+	})
+
+	t.Run("WithTemplate", func(t *testing.T) {
+		t.Parallel()
+
+		source := &Source{
+			Type: "other",
+			TransformTemplate: types.MakeString(`{
+				"name": {{ json .alert.name }},
+				"tags": {"host": {{ json .alert.host }}},
+				"severity": {{ if eq .alert.status "firing" }}"crit"{{ else }}"ok"{{ end }},
+				"message": {{ json .alert.annotations.summary }}
+			}`),
+		}
+		require.NoError(t, source.IncrementalInitAndValidate())
+
+		out, err := source.TransformEventBody(strings.NewReader(`{"alert": {
+			"name": "disk \"full\"",
+			"host": "dummy-42",
+			"status": "firing",
+			"annotations": {"summary": "no space left"}
+		}}`))
+		require.NoError(t, err)
+
+		raw, err := io.ReadAll(out)
+		require.NoError(t, err)
+
+		var ev map[string]any
+		require.NoError(t, json.Unmarshal(raw, &ev), "output must be valid JSON: %s", raw)
+		assert.Equal(t, map[string]any{
+			"name":     `disk "full"`,
+			"tags":     map[string]any{"host": "dummy-42"},
+			"severity": "crit",
+			"message":  "no space left",
+		}, ev)
+	})
+
+	t.Run("InvalidBody", func(t *testing.T) {
+		t.Parallel()
+
+		source := &Source{Type: "other", TransformTemplate: types.MakeString(`{}`)}
+		require.NoError(t, source.IncrementalInitAndValidate())
+
+		_, err := source.TransformEventBody(strings.NewReader(`{`))
+		assert.Error(t, err)
+	})
+
+	t.Run("InvalidTemplate", func(t *testing.T) {
+		t.Parallel()
+
+		source := &Source{Type: "other", TransformTemplate: types.MakeString(`{{ .foo `)}
+		assert.Error(t, source.IncrementalInitAndValidate())
+	})
+
+	t.Run("Icinga2Source", func(t *testing.T) {
+		t.Parallel()
+
+		source := &Source{Type: SourceTypeIcinga2, TransformTemplate: types.MakeString(`{}`)}
+		assert.Error(t, source.IncrementalInitAndValidate())
+	})
+}
+
+func TestSource_Icinga2BaseURLs(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, (&Source{}).Icinga2BaseURLs())
+	assert.Equal(t, []string{"https://master1:5665"},
+		(&Source{Icinga2BaseURL: types.MakeString("https://master1:5665")}).Icinga2BaseURLs())
+	multiple := &Source{Icinga2BaseURL: types.MakeString(" https://master1,https://master2:5665,\n https://master3 ")}
+	assert.Equal(t, []string{"https://master1", "https://master2:5665", "https://master3"}, multiple.Icinga2BaseURLs())
+}
+
+func TestSource_Icinga2CustomVarNames(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, (&Source{}).Icinga2CustomVarNames())
+	source := &Source{Icinga2CustomVars: types.MakeString("os, owner,")}
+	assert.Equal(t, []string{"os", "owner"}, source.Icinga2CustomVarNames())
+}
+
+func TestSource_IncidentAutoCloseAfter(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 24*time.Hour, (&Source{}).IncidentAutoCloseAfter(24*time.Hour))
+
+	twoHours := &Source{AutoCloseAfter: types.Int{NullInt64: sql.NullInt64{Int64: 7_200_000, Valid: true}}}
+	assert.Equal(t, 2*time.Hour, twoHours.IncidentAutoCloseAfter(24*time.Hour))
+
+	disabled := &Source{AutoCloseAfter: types.Int{NullInt64: sql.NullInt64{Int64: 0, Valid: true}}}
+	assert.Equal(t, time.Duration(0), disabled.IncidentAutoCloseAfter(24*time.Hour))
+}
+
+func TestSource_MapSeverity(t *testing.T) {
+	t.Parallel()
+
+	t.Run("WithoutMapping", func(t *testing.T) {
+		t.Parallel()
+
+		source := &Source{Type: SourceTypeIcinga2}
+		require.NoError(t, source.IncrementalInitAndValidate())
+
+		ev := &event.Event{Severity: event.SeverityWarning}
+		source.MapSeverity(ev)
+		assert.Equal(t, event.SeverityWarning, ev.Severity)
+	})
+
+	t.Run("WithMapping", func(t *testing.T) {
+		t.Parallel()
+
+		source := &Source{Type: "other", SeverityMapping: types.MakeString(`{"warning": "err", "err": "crit"}`)}
+		require.NoError(t, source.IncrementalInitAndValidate())
+
+		for from, to := range map[event.Severity]event.Severity{
+			event.SeverityWarning: event.SeverityErr,
+			event.SeverityErr:     event.SeverityCrit,
+			event.SeverityCrit:    event.SeverityCrit,
+			event.SeverityNone:    event.SeverityNone,
+		} {
+			ev := &event.Event{Severity: from}
+			source.MapSeverity(ev)
+			assert.Equal(t, to, ev.Severity, "%s should be mapped once to %s", from.String(), to.String())
+		}
+	})
+
+	t.Run("InvalidMapping", func(t *testing.T) {
+		t.Parallel()
+
+		for _, mapping := range []string{`{"warning": "critical"}`, `{"none": "crit"}`, `{"warning": "none"}`, `["crit"]`} {
+			source := &Source{Type: "other", SeverityMapping: types.MakeString(mapping)}
+			assert.ErrorContains(t, source.IncrementalInitAndValidate(), "severity_mapping", mapping)
+		}
+	})
+}
+
+func TestSource_RenderURL(t *testing.T) {
+	t.Parallel()
+
+	newEvent := func() *event.Event {
+		return &event.Event{
+			URL:      "http://localhost/icingaweb2/icingadb/host?name=db-01",
+			Tags:     map[string]string{"host": "db-01", "alertname": "Disk Full"},
+			Severity: event.SeverityCrit,
+		}
+	}
+
+	t.Run("WithoutTemplate", func(t *testing.T) {
+		t.Parallel()
+
+		source := &Source{Type: "other"}
+		require.NoError(t, source.IncrementalInitAndValidate())
+
+		ev := newEvent()
+		require.NoError(t, source.RenderURL(ev))
+		assert.Equal(t, "http://localhost/icingaweb2/icingadb/host?name=db-01", ev.URL)
+	})
+
+	t.Run("WithTemplate", func(t *testing.T) {
+		t.Parallel()
+
+		source := &Source{Type: "other", URLTemplate: types.MakeString(
+			"https://grafana.example.com/alerting/list" +
+				"?search={{ queryescape .Tags.alertname }}&severity={{ .Severity }}\n",
+		)}
+		require.NoError(t, source.IncrementalInitAndValidate())
+
+		ev := newEvent()
+		require.NoError(t, source.RenderURL(ev))
+		assert.Equal(t, "https://grafana.example.com/alerting/list?search=Disk+Full&severity=crit", ev.URL)
+	})
+
+	t.Run("InvalidTemplate", func(t *testing.T) {
+		t.Parallel()
+
+		source := &Source{Type: "other", URLTemplate: types.MakeString("{{ .Tags.host ")}
+		assert.ErrorContains(t, source.IncrementalInitAndValidate(), "url_template")
+	})
+
+	t.Run("FailingTemplate", func(t *testing.T) {
+		t.Parallel()
+
+		source := &Source{Type: "other", URLTemplate: types.MakeString("{{ .Unknown }}")}
+		require.NoError(t, source.IncrementalInitAndValidate())
+
+		ev := newEvent()
+		assert.Error(t, source.RenderURL(ev))
+		assert.Equal(t, "http://localhost/icingaweb2/icingadb/host?name=db-01", ev.URL, "URL should be kept")
+	})
+}
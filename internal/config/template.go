@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/msgtemplate"
+)
+
+// applyPendingNotificationTemplates synchronizes changed notification templates.
+func (r *RuntimeConfig) applyPendingNotificationTemplates() {
+	verify := func(t *msgtemplate.Template) error {
+		if _, ok := r.Channels[t.ChannelID]; !ok {
+			return fmt.Errorf("notification template refers unknown channel %d", t.ChannelID)
+		}
+		if _, ok := r.Rules[t.RuleID.Int64]; t.RuleID.Valid && !ok {
+			return fmt.Errorf("notification template refers unknown rule %d", t.RuleID.Int64)
+		}
+
+		return nil
+	}
+
+	incrementalApplyPending(
+		r,
+		&r.NotificationTemplates, &r.configChange.NotificationTemplates,
+		verify,
+		func(curElement, update *msgtemplate.Template) error {
+			if err := verify(update); err != nil {
+				return err
+			}
+
+			curElement.ChangedAt = update.ChangedAt
+			curElement.ChannelID = update.ChannelID
+			curElement.RuleID = update.RuleID
+
+			// {Subject,Message}Template are being initialized by config.IncrementalConfigurableInitAndValidatable.
+			curElement.Subject = update.Subject
+			curElement.SubjectTemplate = update.SubjectTemplate
+			curElement.Message = update.Message
+			curElement.MessageTemplate = update.MessageTemplate
+
+			return nil
+		},
+		nil)
+}
+
+// GetNotificationTemplate returns the template for notifications via the given channel, caused by the given rules.
+//
+// A template of one of the rules takes precedence over a template of the channel without a rule, and templates of
+// lower rule IDs are preferred. If there is no template, nil is returned, which renders the defaults.
+func (r *RuntimeConfig) GetNotificationTemplate(channelID int64, ruleIDs []int64) *msgtemplate.Template {
+	var match *msgtemplate.Template
+	for _, t := range r.NotificationTemplates {
+		if t.ChannelID != channelID {
+			continue
+		}
+
+		if !t.RuleID.Valid {
+			if match == nil {
+				match = t
+			}
+			continue
+		}
+
+		for _, ruleID := range ruleIDs {
+			if t.RuleID.Int64 == ruleID && (match == nil || !match.RuleID.Valid || ruleID < match.RuleID.Int64) {
+				match = t
+			}
+		}
+	}
+
+	return match
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"database/sql"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/msgtemplate"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRuntimeConfig_GetNotificationTemplate(t *testing.T) {
+	t.Parallel()
+
+	newTemplate := func(id, channelID, ruleID int64) *msgtemplate.Template {
+		tmpl := &msgtemplate.Template{ChannelID: channelID}
+		tmpl.ID = id
+		if ruleID != 0 {
+			tmpl.RuleID = types.Int{NullInt64: sql.NullInt64{Int64: ruleID, Valid: true}}
+		}
+		return tmpl
+	}
+
+	channelOnly := newTemplate(1, 1, 0)
+	rule2 := newTemplate(2, 1, 2)
+	rule3 := newTemplate(3, 1, 3)
+	otherChannel := newTemplate(4, 2, 2)
+
+	r := &RuntimeConfig{ConfigSet: ConfigSet{NotificationTemplates: map[int64]*msgtemplate.Template{
+		1: channelOnly, 2: rule2, 3: rule3, 4: otherChannel,
+	}}}
+
+	assert.Same(t, channelOnly, r.GetNotificationTemplate(1, nil))
+	assert.Same(t, channelOnly, r.GetNotificationTemplate(1, []int64{7}))
+	assert.Same(t, rule3, r.GetNotificationTemplate(1, []int64{3}))
+	assert.Same(t, rule2, r.GetNotificationTemplate(1, []int64{3, 2}), "lowest rule ID should win")
+	assert.Same(t, otherChannel, r.GetNotificationTemplate(2, []int64{2}))
+	assert.Nil(t, r.GetNotificationTemplate(2, nil))
+	assert.Nil(t, r.GetNotificationTemplate(3, []int64{2}))
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"slices"
+)
+
+// GetSourceTenant returns the tenant of the source, which is invalid for sources shared by all tenants or unknown ones.
+//
+// The tenant of a source scopes everything resulting from its events, e.g., only the rules, contacts, schedules and
+// channels of this tenant or without any tenant are used for its incidents, see baseconf.TenantEntry.
+func (r *RuntimeConfig) GetSourceTenant(sourceID int64) types.Int {
+	if source := r.Sources[sourceID]; source != nil {
+		return source.TenantID
+	}
+
+	return types.Int{}
+}
+
+// GetTenantContact returns the contact by the given username like GetContact, but only if it is visible to the tenant.
+func (r *RuntimeConfig) GetTenantContact(tenantID types.Int, username string) *recipient.Contact {
+	if contact := r.GetContact(username); contact != nil && contact.VisibleTo(tenantID) {
+		return contact
+	}
+
+	return nil
+}
+
+// GetTenantSourceIDs returns the IDs of all sources visible to the tenant, sorted, or nil for the invalid tenant seeing
+// all sources. Only the incidents of these sources may be accessed within the tenant.
+func (r *RuntimeConfig) GetTenantSourceIDs(tenantID types.Int) []int64 {
+	if !tenantID.Valid {
+		return nil
+	}
+
+	ids := []int64{}
+	for id, source := range r.Sources {
+		if source.VisibleTo(tenantID) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	return ids
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"database/sql"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRuntimeConfig_GetTenantContact(t *testing.T) {
+	t.Parallel()
+
+	tenant := func(id int64) types.Int {
+		return types.Int{NullInt64: sql.NullInt64{Int64: id, Valid: true}}
+	}
+
+	newContact := func(id int64, username string, tenantID types.Int) *recipient.Contact {
+		c := &recipient.Contact{FullName: username, Username: sql.NullString{String: username, Valid: true}}
+		c.ID = id
+		c.TenantID = tenantID
+		return c
+	}
+
+	r := &RuntimeConfig{}
+	r.Contacts = map[int64]*recipient.Contact{
+		1: newContact(1, "jane", tenant(1)),
+		2: newContact(2, "john", tenant(2)),
+		3: newContact(3, "admin", types.Int{}),
+	}
+	r.Sources = map[int64]*Source{1: {}, 2: {}}
+	r.Sources[2].TenantID = tenant(2)
+
+	assert.Equal(t, types.Int{}, r.GetSourceTenant(1), "shared source")
+	assert.Equal(t, tenant(2), r.GetSourceTenant(2))
+	assert.Equal(t, types.Int{}, r.GetSourceTenant(3), "unknown source")
+
+	assert.Equal(t, r.Contacts[1], r.GetTenantContact(tenant(1), "jane"))
+	assert.Nil(t, r.GetTenantContact(tenant(1), "john"), "contact of another tenant")
+	assert.Equal(t, r.Contacts[3], r.GetTenantContact(tenant(1), "admin"), "shared contact")
+	assert.Equal(t, r.Contacts[2], r.GetTenantContact(types.Int{}, "john"), "shared sources see all contacts")
+	assert.Nil(t, r.GetTenantContact(tenant(1), "unknown"))
+}
+
+func TestRuntimeConfig_GetTenantSourceIDs(t *testing.T) {
+	t.Parallel()
+
+	tenant := func(id int64) types.Int {
+		return types.Int{NullInt64: sql.NullInt64{Int64: id, Valid: true}}
+	}
+
+	r := &RuntimeConfig{}
+	r.Sources = map[int64]*Source{1: {}, 2: {}, 3: {}, 4: {}}
+	r.Sources[2].TenantID = tenant(2)
+	r.Sources[3].TenantID = tenant(3)
+	r.Sources[4].TenantID = tenant(2)
+
+	assert.Nil(t, r.GetTenantSourceIDs(types.Int{}), "no tenant sees all sources")
+	assert.Equal(t, []int64{1, 2, 4}, r.GetTenantSourceIDs(tenant(2)))
+	assert.Equal(t, []int64{1}, r.GetTenantSourceIDs(tenant(5)), "shared sources only")
+
+	r.Sources = map[int64]*Source{}
+	assert.Equal(t, []int64{}, r.GetTenantSourceIDs(tenant(2)), "no sources at all")
+
+	r.Sources = map[int64]*Source{1: {}, 2: {}}
+	r.Sources[2].TenantID = tenant(2)
+
+	token := &ApiToken{}
+	assert.Equal(t, types.Int{}, r.GetApiTokenTenant(token), "unscoped token")
+
+	token.SourceID = types.Int{NullInt64: sql.NullInt64{Int64: 2, Valid: true}}
+	assert.Equal(t, tenant(2), r.GetApiTokenTenant(token), "tenant of the bound source")
+
+	token.TenantID = tenant(3)
+	assert.Equal(t, tenant(3), r.GetApiTokenTenant(token), "own tenant takes precedence")
+}
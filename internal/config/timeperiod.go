@@ -14,6 +14,7 @@ func (r *RuntimeConfig) applyPendingTimePeriods() {
 		nil,
 		func(curElement, update *timeperiod.TimePeriod) error {
 			curElement.ChangedAt = update.ChangedAt
+			curElement.NameRaw = update.NameRaw
 			curElement.Name = update.Name
 			return nil
 		},
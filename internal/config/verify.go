@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/icinga/icinga-notifications/internal/channel"
+	"github.com/icinga/icinga-notifications/internal/maintenance"
 	"github.com/icinga/icinga-notifications/internal/recipient"
 	"github.com/icinga/icinga-notifications/internal/rule"
 	"github.com/icinga/icinga-notifications/internal/timeperiod"
@@ -91,6 +92,19 @@ func (r *RuntimeConfig) debugVerify() error {
 		}
 	}
 
+	for id, window := range r.MaintenanceWindows {
+		err := r.debugVerifyMaintenanceWindow(id, window)
+		if err != nil {
+			return fmt.Errorf("RuntimeConfig.MaintenanceWindows[%d]: %w", id, err)
+		}
+	}
+
+	for id, token := range r.ApiTokens {
+		if token.ID != id {
+			return fmt.Errorf("RuntimeConfig.ApiTokens[%d]: API token has ID %d", id, token.ID)
+		}
+	}
+
 	return nil
 }
 
@@ -134,6 +148,35 @@ func (r *RuntimeConfig) debugVerifyContact(id int64, contact *recipient.Contact)
 		}
 	}
 
+	for i, optOut := range contact.OptOuts {
+		if optOut == nil {
+			return fmt.Errorf("OptOuts[%d] is nil", i)
+		}
+
+		if optOut.ContactID != id {
+			return fmt.Errorf("OptOuts[%d] has ContactID = %d instead of %d", i, optOut.ContactID, id)
+		}
+
+		if other := r.ContactOptOuts[optOut.ID]; other != optOut {
+			return fmt.Errorf("OptOuts[%d] is inconsistent with RuntimeConfig.ContactOptOuts[%d] = %p", i, optOut.ID, other)
+		}
+	}
+
+	for i, preference := range contact.ChannelPreferences {
+		if preference == nil {
+			return fmt.Errorf("ChannelPreferences[%d] is nil", i)
+		}
+
+		if preference.ContactID != id {
+			return fmt.Errorf("ChannelPreferences[%d] has ContactID = %d instead of %d", i, preference.ContactID, id)
+		}
+
+		if other := r.ContactChannelPreferences[preference.ID]; other != preference {
+			return fmt.Errorf("ChannelPreferences[%d] is inconsistent with "+
+				"RuntimeConfig.ContactChannelPreferences[%d] = %p", i, preference.ID, other)
+		}
+	}
+
 	return nil
 }
 
@@ -169,6 +212,34 @@ func (r *RuntimeConfig) debugVerifyGroup(id int64, group *recipient.Group) error
 		}
 	}
 
+	for i, region := range group.Regions {
+		if region == nil {
+			return fmt.Errorf("Regions[%d] is nil", i)
+		}
+
+		if region.GroupID != group.ID {
+			return fmt.Errorf("Regions[%d] refers to group %d", i, region.GroupID)
+		}
+
+		if other := r.groupRegions[region.ID]; other != region {
+			return fmt.Errorf("Regions[%d] %p is inconsistent with RuntimeConfig.groupRegions[%d] = %p",
+				i, region, region.ID, other)
+		}
+
+		if region.RegionGroup == nil {
+			return fmt.Errorf("Regions[%d].RegionGroup is nil", i)
+		} else if other := r.Groups[region.RegionGroupID]; other != region.RegionGroup {
+			return fmt.Errorf("Regions[%d].RegionGroup %p is inconsistent with RuntimeConfig.Groups[%d] = %p",
+				i, region.RegionGroup, region.RegionGroupID, other)
+		}
+
+		if region.TimePeriod == nil {
+			return fmt.Errorf("Regions[%d].TimePeriod is nil", i)
+		} else if err := r.debugVerifyTimePeriod(region.TimePeriodID, region.TimePeriod); err != nil {
+			return fmt.Errorf("Regions[%d].TimePeriod: %w", i, err)
+		}
+	}
+
 	return nil
 }
 
@@ -225,6 +296,39 @@ func (r *RuntimeConfig) debugVerifySchedule(id int64, schedule *recipient.Schedu
 		}
 	}
 
+	for i, override := range schedule.Overrides {
+		if override == nil {
+			return fmt.Errorf("Overrides[%d] is nil", i)
+		}
+
+		if override.ScheduleID != schedule.ID {
+			return fmt.Errorf("Overrides[%d] refers to schedule %d", i, override.ScheduleID)
+		}
+
+		if override.Contact != nil {
+			err := r.debugVerifyContact(override.ContactID, override.Contact)
+			if err != nil {
+				return fmt.Errorf("Overrides[%d].Contact: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *RuntimeConfig) debugVerifyMaintenanceWindow(id int64, window *maintenance.Window) error {
+	if window.ID != id {
+		return fmt.Errorf("maintenance window has ID %d but is referenced as %d", window.ID, id)
+	}
+
+	if window.TimePeriodID.Valid && window.TimePeriod == nil {
+		return fmt.Errorf("maintenance window has a TimePeriodID but TimePeriod is nil")
+	}
+
+	if window.ObjectFilterExpr.Valid && window.ObjectFilter == nil {
+		return fmt.Errorf("maintenance window has a ObjectFilterExpr but ObjectFilter is nil")
+	}
+
 	return nil
 }
 
@@ -262,9 +366,14 @@ func (r *RuntimeConfig) debugVerifyRule(id int64, rule *rule.Rule) error {
 				escalationID, escalation.ID, escalationID)
 		}
 
-		if escalation.RuleID != rule.ID {
+		if escalation.PolicyID.Valid {
+			if rule.EscalationPolicy == nil || escalation.PolicyID.Int64 != rule.EscalationPolicy.ID {
+				return fmt.Errorf("Escalations[%d] (ID=%d) has PolicyID = %d while being referenced from rule %d",
+					escalationID, escalation.ID, escalation.PolicyID.Int64, rule.ID)
+			}
+		} else if escalation.RuleID.Int64 != rule.ID {
 			return fmt.Errorf("Escalations[%d] (ID=%d) has RuleID = %d while being referenced from rule %d",
-				escalationID, escalation.ID, escalation.RuleID, rule.ID)
+				escalationID, escalation.ID, escalation.RuleID.Int64, rule.ID)
 		}
 
 		if escalation.ConditionExpr.Valid && escalation.Condition == nil {
@@ -1,14 +1,19 @@
 package daemon
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"github.com/creasty/defaults"
 	"github.com/icinga/icinga-go-library/config"
 	"github.com/icinga/icinga-go-library/database"
 	"github.com/icinga/icinga-go-library/logging"
 	"github.com/icinga/icinga-go-library/utils"
 	"github.com/icinga/icinga-notifications/internal"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
 	"time"
 )
 
@@ -18,13 +23,689 @@ const (
 )
 
 type ConfigFile struct {
-	Listen        string          `yaml:"listen" default:"localhost:5680"`
-	DebugPassword string          `yaml:"debug-password"`
-	ChannelsDir   string          `yaml:"channels-dir"`
-	ApiTimeout    time.Duration   `yaml:"api-timeout" default:"1m"`
-	Icingaweb2URL string          `yaml:"icingaweb2-url"`
-	Database      database.Config `yaml:"database"`
-	Logging       logging.Config  `yaml:"logging"`
+	Listen            string          `yaml:"listen" default:"localhost:5680"`
+	DebugPassword     string          `yaml:"debug-password"`
+	ChannelsDir       string          `yaml:"channels-dir"`
+	ApiTimeout        time.Duration   `yaml:"api-timeout" default:"1m"`
+	Icingaweb2URL     string          `yaml:"icingaweb2-url"`
+	IcsImportInterval time.Duration   `yaml:"ics-import-interval" default:"15m"`
+	SoftDeleteGrace   time.Duration   `yaml:"soft-delete-grace-period" default:"168h"`
+	IncidentAutoClose time.Duration   `yaml:"incident-auto-close-after"`
+	StateExport       time.Duration   `yaml:"state-export-interval"`
+	CatchupDuplicates time.Duration   `yaml:"catch-up-duplicate-window" default:"15m"`
+	CatchupCheckpoint bool            `yaml:"catch-up-checkpoint"`
+	CatchupPageSize   int             `yaml:"catch-up-page-size" default:"1000"`
+	CatchupWorkers    int             `yaml:"catch-up-concurrency" default:"4"`
+	ReadOnly          bool            `yaml:"read-only"`
+	ShutdownTimeout   time.Duration   `yaml:"shutdown-timeout" default:"30s"`
+	EventWorkers      int             `yaml:"event-workers" default:"1"`
+	Database          database.Config `yaml:"database"`
+	Logging           logging.Config  `yaml:"logging"`
+
+	// DatabaseNotifications enables listening for PostgreSQL notifications about changes, see package pgnotify.
+	DatabaseNotifications bool `yaml:"database-notifications"`
+
+	HA             HAConfig             `yaml:"ha"`
+	Sharding       ShardingConfig       `yaml:"sharding"`
+	ListenerTLS    ListenerTLSConfig    `yaml:"listener-tls"`
+	MailGateway    MailGatewayConfig    `yaml:"mail-gateway"`
+	IntegrityCheck IntegrityCheckConfig `yaml:"integrity-check"`
+	Flapping       FlappingConfig       `yaml:"flapping"`
+	MutedSummary   MutedSummaryConfig   `yaml:"muted-summary"`
+	AckLinks       AckLinkConfig        `yaml:"ack-links"`
+	ChatOps        ChatOpsConfig        `yaml:"chatops"`
+	EventBuffer    EventBufferConfig    `yaml:"event-buffer"`
+	EventQueue     EventQueueConfig     `yaml:"event-queue"`
+	Retention      RetentionConfig      `yaml:"retention"`
+	CalDAV         CalDAVConfig         `yaml:"caldav"`
+	SelfTest       SelfTestConfig       `yaml:"self-test"`
+
+	ChannelHealthCheck  ChannelHealthCheckConfig  `yaml:"channel-health-check"`
+	ChannelConcurrency  ChannelConcurrencyConfig  `yaml:"channel-concurrency"`
+	DeliveryFailures    DeliveryFailuresConfig    `yaml:"delivery-failures"`
+	NotificationLatency NotificationLatencyConfig `yaml:"notification-latency"`
+	Scrub               ScrubConfig               `yaml:"scrub"`
+	Throttling          ThrottlingConfig          `yaml:"throttling"`
+	Enrichment          EnrichmentConfig          `yaml:"enrichment"`
+}
+
+// HAConfig configures the election of the responsible instance among multiple daemon instances sharing the database,
+// see package ha.
+type HAConfig struct {
+	// HeartbeatInterval between two heartbeats of this instance, each running an election.
+	HeartbeatInterval time.Duration `yaml:"heartbeat-interval" default:"5s"`
+	// Timeout after the last heartbeat of the responsible instance, until another instance takes over.
+	Timeout time.Duration `yaml:"timeout" default:"30s"`
+}
+
+// Validate checks that the timeout spans multiple heartbeats.
+func (c *HAConfig) Validate() error {
+	if c.HeartbeatInterval <= 0 {
+		return errors.New("ha.heartbeat-interval must be positive")
+	}
+	if c.Timeout < 2*c.HeartbeatInterval {
+		return errors.New("ha.timeout must be at least twice ha.heartbeat-interval")
+	}
+
+	return nil
+}
+
+// ShardingConfig splits the incident processing among multiple active instances by hashing object IDs into shards,
+// see package shard.
+type ShardingConfig struct {
+	// Count of shards, which must be the same for all instances. A single shard disables sharding.
+	Count int `yaml:"count" default:"1"`
+	// Shards served by this instance, numbered from zero. If empty, all shards are served.
+	Shards []int `yaml:"shards"`
+}
+
+// Mask returns a bitmask of the shards served by this instance.
+func (c *ShardingConfig) Mask() uint64 {
+	if len(c.Shards) == 0 {
+		return 1<<c.Count - 1
+	}
+
+	var mask uint64
+	for _, s := range c.Shards {
+		mask |= 1 << s
+	}
+
+	return mask
+}
+
+// Validate checks that the served shards exist, as the shards of all instances are stored as a 63-bit mask.
+func (c *ShardingConfig) Validate() error {
+	if c.Count < 1 || c.Count > 63 {
+		return errors.New("sharding.count must be between 1 and 63")
+	}
+	for _, s := range c.Shards {
+		if s < 0 || s >= c.Count {
+			return fmt.Errorf("sharding.shards must be between 0 and sharding.count - 1, got %d", s)
+		}
+	}
+
+	return nil
+}
+
+// ListenerTLSConfig lets the HTTP listener terminate TLS itself, allowing sources to authenticate by client
+// certificates, see config.Source.VerifyClientCertificate.
+//
+// The certificate is either loaded from files, being reloaded once they change, see package certreload, or obtained
+// via ACME, e.g., from Let's Encrypt.
+type ListenerTLSConfig struct {
+	// Cert and Key are the paths to the PEM-encoded server certificate, possibly followed by intermediates, and its
+	// private key.
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+	// ReloadInterval between two checks whether Cert or Key were modified. A zero value only reloads them on SIGHUP.
+	ReloadInterval time.Duration `yaml:"reload-interval" default:"1m"`
+
+	ACME ListenerACMEConfig `yaml:"acme"`
+}
+
+// ListenerACMEConfig obtains and renews the listener's certificate via ACME, using the TLS-ALPN-01 challenge. Thus, the
+// listener must be reachable on port 443 by the ACME server for each of the domains.
+type ListenerACMEConfig struct {
+	// Domains to obtain the certificate for. ACME is disabled unless at least one domain is set.
+	Domains []string `yaml:"domains"`
+	// Email is the optional contact address of the ACME account, e.g., for expiry notices.
+	Email string `yaml:"email"`
+	// CacheDir stores the account key and the certificates, sparing new orders after a restart.
+	CacheDir string `yaml:"cache-dir" default:"/var/lib/icinga-notifications/acme"`
+	// DirectoryURL of the ACME server, defaulting to Let's Encrypt.
+	DirectoryURL string `yaml:"directory-url"`
+}
+
+// Enabled returns whether the listener serves HTTPS.
+func (c *ListenerTLSConfig) Enabled() bool {
+	return c.Cert != "" || len(c.ACME.Domains) > 0
+}
+
+// Validate checks that the certificate is either loaded from files or obtained via ACME.
+func (c *ListenerTLSConfig) Validate() error {
+	if (c.Cert == "") != (c.Key == "") {
+		return errors.New("listener-tls.cert and listener-tls.key must be set together")
+	}
+	if c.ReloadInterval < 0 {
+		return errors.New("listener-tls.reload-interval must not be negative")
+	}
+	if c.Cert != "" && len(c.ACME.Domains) > 0 {
+		return errors.New("listener-tls.cert and listener-tls.acme are mutually exclusive")
+	}
+	if len(c.ACME.Domains) > 0 && c.ACME.CacheDir == "" {
+		return errors.New("listener-tls.acme.cache-dir must be set")
+	}
+
+	return nil
+}
+
+// NotificationLatencyConfig configures the objective for the latency from receiving an event until its first
+// notification was handed to a channel plugin, see package latency.
+type NotificationLatencyConfig struct {
+	// SLO is the latency to be exceeded for a warning to be logged. A zero value disables the warnings, while the
+	// latencies are measured anyway.
+	SLO time.Duration `yaml:"slo"`
+}
+
+// Validate checks the notification latency configuration.
+func (c *NotificationLatencyConfig) Validate() error {
+	if c.SLO < 0 {
+		return errors.New("notification-latency.slo must not be negative")
+	}
+
+	return nil
+}
+
+// ChannelHealthCheckConfig configures probing the channel plugins, see channel.Status.
+type ChannelHealthCheckConfig struct {
+	// Interval between two health checks of each channel. A zero value disables the health checks.
+	Interval time.Duration `yaml:"interval"`
+	// Timeout for a single health check, after which the channel is considered unhealthy.
+	Timeout time.Duration `yaml:"timeout" default:"30s"`
+}
+
+// Validate checks the channel health check configuration if it is enabled.
+func (c *ChannelHealthCheckConfig) Validate() error {
+	if c.Interval < 0 {
+		return errors.New("channel-health-check.interval must not be negative")
+	}
+	if c.Interval > 0 && c.Timeout <= 0 {
+		return errors.New("channel-health-check.timeout must be positive if channel-health-check.interval is set")
+	}
+
+	return nil
+}
+
+// ChannelConcurrencyConfig limits the notifications sent concurrently by each channel, see channel.Channel.
+type ChannelConcurrencyConfig struct {
+	// MaxConcurrentSends of each channel, after which further notifications are queued per contact and sent to the
+	// contacts in turn. A zero value disables the limit and thus the fair scheduling.
+	MaxConcurrentSends int `yaml:"max-concurrent-sends" default:"10"`
+}
+
+// Validate checks the channel concurrency configuration.
+func (c *ChannelConcurrencyConfig) Validate() error {
+	if c.MaxConcurrentSends < 0 {
+		return errors.New("channel-concurrency.max-concurrent-sends must not be negative")
+	}
+
+	return nil
+}
+
+// DeliveryFailuresConfig configures avoiding channels which recently failed to notify a contact.
+type DeliveryFailuresConfig struct {
+	// Window after a failed notification of a contact via a channel in which further notifications of this contact are
+	// sent via its default channel instead. A zero value disables this.
+	Window time.Duration `yaml:"window" default:"1h"`
+}
+
+// Validate checks the delivery failures configuration.
+func (c *DeliveryFailuresConfig) Validate() error {
+	if c.Window < 0 {
+		return errors.New("delivery-failures.window must not be negative")
+	}
+
+	return nil
+}
+
+// EnrichmentConfig configures attaching organizational metadata as extra tags to the events of all sources before they
+// are processed, see the enrichment package. Both a file and a URL may be configured, the URL taking precedence.
+type EnrichmentConfig struct {
+	// File is a JSON or CSV file mapping the values of the Key tag to extra tags, depending on its extension.
+	File string `yaml:"file"`
+	// Key is the tag whose value is looked up in the File, e.g., "host".
+	Key string `yaml:"key" default:"host"`
+
+	// URL of an HTTP endpoint receiving the event's object as JSON and responding with its extra tags.
+	URL string `yaml:"url"`
+	// Timeout of a request to the URL.
+	Timeout time.Duration `yaml:"timeout" default:"5s"`
+	// CacheTTL is how long the extra tags returned by the URL for an object are reused.
+	CacheTTL time.Duration `yaml:"cache-ttl" default:"5m"`
+}
+
+// Validate checks the enrichment configuration. The file itself is loaded when creating the enrichment.Enricher.
+func (c *EnrichmentConfig) Validate() error {
+	if c.File != "" && c.Key == "" {
+		return errors.New("enrichment.key must not be empty")
+	}
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("enrichment.url must be an HTTP(S) URL, got %q", c.URL)
+		}
+		if c.Timeout <= 0 {
+			return errors.New("enrichment.timeout must be positive")
+		}
+	}
+	if c.CacheTTL < 0 {
+		return errors.New("enrichment.cache-ttl must not be negative")
+	}
+
+	return nil
+}
+
+// ThrottlingConfig configures limiting the notifications per contact, e.g., to protect on-call people during mass
+// outages. Notifications exceeding the limit are recorded as throttled and sent as a single digest after the window.
+type ThrottlingConfig struct {
+	// Notifications is the maximum number of notifications per contact within the window. Zero disables throttling.
+	Notifications int `yaml:"notifications"`
+	// Window in which the notifications of a contact are counted.
+	Window time.Duration `yaml:"window" default:"10m"`
+}
+
+// Validate checks the throttling configuration.
+func (c *ThrottlingConfig) Validate() error {
+	if c.Notifications < 0 {
+		return errors.New("throttling.notifications must not be negative")
+	}
+	if c.Notifications > 0 && c.Window <= 0 {
+		return errors.New("throttling.window must be positive")
+	}
+
+	return nil
+}
+
+// CalDAVConfig configures publishing the on-call shifts to the contacts' CalDAV calendars, see ics.Publisher.
+type CalDAVConfig struct {
+	// Interval between two publications. A zero value disables publishing.
+	Interval time.Duration `yaml:"interval"`
+	// Horizon is how far into the future shifts are published.
+	Horizon time.Duration `yaml:"horizon" default:"720h"`
+	// Username and Password authenticate against the CalDAV servers via HTTP Basic Authentication.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Validate checks the CalDAV configuration if it is enabled.
+func (c *CalDAVConfig) Validate() error {
+	if c.Interval < 0 {
+		return errors.New("caldav.interval must not be negative")
+	}
+	if c.Interval > 0 && c.Horizon <= 0 {
+		return errors.New("caldav.horizon must be positive if caldav.interval is set")
+	}
+
+	return nil
+}
+
+// SelfTestConfig configures the daily test notifications via the channels to a dedicated contact, see package selftest.
+type SelfTestConfig struct {
+	// At is the local time of day, formatted as "15:04", to send the test notifications. An empty value disables them.
+	At string `yaml:"at"`
+	// Contact is the username of the contact receiving the test notifications, e.g., a monitoring of monitoring team.
+	Contact string `yaml:"contact"`
+	// Channels are the IDs of the channels to test. If empty, all channels are tested.
+	Channels []int64 `yaml:"channels"`
+}
+
+// Validate checks the self-test configuration if it is enabled.
+func (c *SelfTestConfig) Validate() error {
+	if c.At == "" {
+		return nil
+	}
+	if _, err := time.Parse("15:04", c.At); err != nil {
+		return fmt.Errorf("self-test.at must be a time of day like 08:30, got %q", c.At)
+	}
+	if c.Contact == "" {
+		return errors.New("self-test.contact must be set if self-test.at is set")
+	}
+
+	return nil
+}
+
+// EventBufferConfig configures buffering events submitted via the HTTP API while the database is unavailable, see
+// package eventbuffer.
+type EventBufferConfig struct {
+	// Size is the maximum number of buffered events. A zero value disables buffering.
+	Size int `yaml:"size" default:"1000"`
+	// File persists the buffered events, allowing them to survive a restart. An empty value keeps them in memory only.
+	File string `yaml:"file"`
+	// RetryInterval between two attempts to process the buffered events.
+	RetryInterval time.Duration `yaml:"retry-interval" default:"5s"`
+}
+
+// Validate checks the event buffer configuration if it is enabled.
+func (c *EventBufferConfig) Validate() error {
+	if c.Size < 0 {
+		return errors.New("event-buffer.size must not be negative")
+	}
+	if c.Size > 0 && c.RetryInterval <= 0 {
+		return errors.New("event-buffer.retry-interval must be positive if event-buffer.size is set")
+	}
+
+	return nil
+}
+
+// EventQueueConfig configures queueing received events in the database before processing them, see package eventqueue.
+type EventQueueConfig struct {
+	// Enabled queues the received events instead of processing them right away.
+	Enabled bool `yaml:"enabled"`
+	// BatchSize is the maximum number of events claimed at once.
+	BatchSize int `yaml:"batch-size" default:"100"`
+	// PollInterval between two checks for queued events, e.g., to retry them after a database failure.
+	PollInterval time.Duration `yaml:"poll-interval" default:"1s"`
+	// VisibilityTimeout after which claimed events not processed yet, e.g., by a crashed instance, are claimed again.
+	VisibilityTimeout time.Duration `yaml:"visibility-timeout" default:"5m"`
+}
+
+// Validate checks the event queue configuration if it is enabled.
+func (c *EventQueueConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.BatchSize <= 0 {
+		return errors.New("event-queue.batch-size must be positive")
+	}
+	if c.PollInterval <= 0 {
+		return errors.New("event-queue.poll-interval must be positive")
+	}
+	if c.VisibilityTimeout <= 0 {
+		return errors.New("event-queue.visibility-timeout must be positive")
+	}
+
+	return nil
+}
+
+// RetentionConfig configures the periodic deletion of old rows, see package retention.
+//
+// Each kind of rows is kept for the given number of days, where 0 keeps them forever.
+type RetentionConfig struct {
+	// Events not belonging to any incident, e.g., OK events of objects without an incident.
+	Events int `yaml:"events"`
+	// History of closed incidents, i.e., the incidents themselves with all their events, history and notifications.
+	History int `yaml:"history"`
+	// Notifications in the history of all incidents, including those still open.
+	Notifications int `yaml:"notifications"`
+	// Interval between two cleanups.
+	Interval time.Duration `yaml:"interval" default:"1h"`
+	// BatchSize is the maximum number of rows, or incidents for History, deleted by a single transaction.
+	BatchSize int `yaml:"batch-size" default:"1000"`
+	// StatementTimeout aborts a single batch taking longer, e.g., as it blocks other queries for too long.
+	StatementTimeout time.Duration `yaml:"statement-timeout" default:"30s"`
+	// Archive all rows before deleting them, see package archive.
+	Archive ArchiveConfig `yaml:"archive"`
+}
+
+// Enabled reports whether any rows are deleted at all.
+func (c *RetentionConfig) Enabled() bool {
+	return c.Events > 0 || c.History > 0 || c.Notifications > 0
+}
+
+// Validate checks the retention configuration if it is enabled.
+func (c *RetentionConfig) Validate() error {
+	if c.Events < 0 || c.History < 0 || c.Notifications < 0 {
+		return errors.New("retention days must not be negative")
+	}
+	if c.Archive.Enabled() && !c.Enabled() {
+		return errors.New("retention.archive requires retention.events, history or notifications, as only deleted" +
+			" rows are archived")
+	}
+	if !c.Enabled() {
+		return nil
+	}
+	if c.Interval <= 0 {
+		return errors.New("retention.interval must be positive")
+	}
+	if c.BatchSize <= 0 {
+		return errors.New("retention.batch-size must be positive")
+	}
+	if c.StatementTimeout <= 0 {
+		return errors.New("retention.statement-timeout must be positive")
+	}
+	if err := c.Archive.Validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ArchiveConfig configures where closed incidents are archived before being deleted, either a local directory or an
+// S3-compatible bucket.
+type ArchiveConfig struct {
+	// Path of the directory the archives are written to.
+	Path string `yaml:"path"`
+	// S3 bucket the archives are uploaded to.
+	S3 S3Config `yaml:"s3"`
+}
+
+// S3Config configures an S3-compatible bucket, addressed path-style, i.e., as "<endpoint>/<bucket>/<key>".
+type S3Config struct {
+	// Endpoint is the base URL of the S3 API, e.g., "https://s3.eu-central-1.amazonaws.com". An empty value disables
+	// uploading to S3.
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region" default:"us-east-1"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access-key"`
+	SecretKey string `yaml:"secret-key"`
+	// Prefix prepended to the key of each archive, e.g., "icinga-notifications/".
+	Prefix string `yaml:"prefix"`
+}
+
+// Enabled reports whether incidents are archived at all.
+func (c *ArchiveConfig) Enabled() bool {
+	return c.Path != "" || c.S3.Endpoint != ""
+}
+
+// Validate checks that at most one destination is configured, which is complete.
+func (c *ArchiveConfig) Validate() error {
+	if c.Path != "" && c.S3.Endpoint != "" {
+		return errors.New("retention.archive.path and retention.archive.s3 are mutually exclusive")
+	}
+	if c.Path != "" && !filepath.IsAbs(c.Path) {
+		return errors.New("retention.archive.path must be absolute")
+	}
+	if c.S3.Endpoint != "" {
+		u, err := url.Parse(c.S3.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("retention.archive.s3.endpoint must be an HTTP(S) URL")
+		}
+		if c.S3.Bucket == "" || c.S3.Region == "" || c.S3.AccessKey == "" || c.S3.SecretKey == "" {
+			return errors.New("retention.archive.s3 requires bucket, region, access-key and secret-key")
+		}
+	}
+
+	return nil
+}
+
+// ChatOpsConfig configures the endpoints receiving actions from interactive chat messages, see package chatops.
+type ChatOpsConfig struct {
+	// SlackSigningSecret verifies requests of the Slack app. An empty value disables the Slack endpoint.
+	SlackSigningSecret string `yaml:"slack-signing-secret"`
+	// TeamsSecret is the base64-encoded security token of the Teams outgoing webhook. An empty value disables the
+	// Teams endpoint.
+	TeamsSecret string `yaml:"teams-secret"`
+}
+
+// Validate checks the chat endpoints configuration.
+func (c *ChatOpsConfig) Validate() error {
+	if c.TeamsSecret != "" {
+		if _, err := base64.StdEncoding.DecodeString(c.TeamsSecret); err != nil {
+			return fmt.Errorf("chatops.teams-secret must be base64-encoded: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// AckLinkConfig configures the signed links embedded in notifications to acknowledge an incident, see package acklink.
+type AckLinkConfig struct {
+	// BaseURL is the externally reachable URL of the HTTP listener. An empty value disables acknowledgement links.
+	BaseURL string `yaml:"base-url"`
+	// Secret is the key to sign the links with. Changing it invalidates all links sent before.
+	Secret string `yaml:"secret"`
+	// Validity is the period in which a link can be used after sending the notification.
+	Validity time.Duration `yaml:"validity" default:"24h"`
+}
+
+// Validate checks the acknowledgement links configuration if it is enabled.
+func (c *AckLinkConfig) Validate() error {
+	if c.BaseURL == "" {
+		return nil
+	}
+
+	if len(c.Secret) < 32 {
+		return errors.New("ack-links.secret must be at least 32 characters long if ack-links.base-url is set")
+	}
+	if c.Validity <= 0 {
+		return errors.New("ack-links.validity must be positive if ack-links.base-url is set")
+	}
+
+	return nil
+}
+
+// MutedSummaryConfig configures the summaries of events received for muted objects.
+type MutedSummaryConfig struct {
+	// Interval in which the recipients of a muted incident are notified about the number of events received meanwhile.
+	// A zero value disables the summaries.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Validate checks the muted summary configuration.
+func (c *MutedSummaryConfig) Validate() error {
+	if c.Interval < 0 {
+		return errors.New("muted-summary.interval must not be negative")
+	}
+
+	return nil
+}
+
+// FlappingConfig configures the detection of objects rapidly changing their severity.
+type FlappingConfig struct {
+	// Transitions is the number of severity changes within Window for an object to be considered flapping.
+	// A zero value disables the flapping detection.
+	Transitions int `yaml:"transitions"`
+	// Window is the period in which the severity changes are counted. A flapping object stops flapping as soon as its
+	// severity did not change for this period.
+	Window time.Duration `yaml:"window" default:"10m"`
+}
+
+// Validate checks the flapping detection configuration if it is enabled.
+func (c *FlappingConfig) Validate() error {
+	if c.Transitions == 0 {
+		return nil
+	}
+
+	if c.Transitions < 2 {
+		return errors.New("flapping.transitions must be at least 2 if set")
+	}
+	if c.Window <= 0 {
+		return errors.New("flapping.window must be positive if flapping.transitions is set")
+	}
+
+	return nil
+}
+
+// ScrubConfig configures masking sensitive data in and limiting the size of the events of all sources, see the scrub
+// package.
+type ScrubConfig struct {
+	// Builtin enables predefined rules by their name, e.g., "passwords", see scrub.Builtin.
+	Builtin []string `yaml:"builtin"`
+	// Rules are additional regular expressions, evaluated after the builtin ones.
+	Rules []ScrubRule `yaml:"rules"`
+
+	// StripControlCharacters removes ANSI escape sequences and control characters other than tabs and newlines.
+	StripControlCharacters bool `yaml:"strip-control-characters"`
+	// MaxMessageLength truncates longer messages to this number of characters, unless zero.
+	MaxMessageLength int `yaml:"max-message-length"`
+	// MaxTags rejects events with more identifying tags, unless zero.
+	MaxTags int `yaml:"max-tags"`
+	// MaxExtraTags drops the extra tags exceeding this number, unless zero.
+	MaxExtraTags int `yaml:"max-extra-tags"`
+}
+
+// ScrubRule replaces all matches of Pattern by Replacement, which may reference capture groups like "${1}". An empty
+// Replacement removes the matches.
+type ScrubRule struct {
+	Pattern     string `yaml:"pattern"`
+	Replacement string `yaml:"replacement"`
+}
+
+// Validate checks the custom scrub rules and the limits. The builtin rules are checked when creating the scrub.Scrubber.
+func (c *ScrubConfig) Validate() error {
+	for i, rule := range c.Rules {
+		if rule.Pattern == "" {
+			return fmt.Errorf("scrub.rules[%d].pattern must not be empty", i)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("scrub.rules[%d] has an invalid regular expression: %w", i, err)
+		}
+	}
+
+	if c.MaxMessageLength < 0 {
+		return errors.New("scrub.max-message-length must not be negative")
+	}
+	if c.MaxTags < 0 {
+		return errors.New("scrub.max-tags must not be negative")
+	}
+	if c.MaxExtraTags < 0 {
+		return errors.New("scrub.max-extra-tags must not be negative")
+	}
+
+	return nil
+}
+
+// IntegrityCheckConfig configures the periodic detection of orphaned rows, see the integrity package.
+type IntegrityCheckConfig struct {
+	// Interval between two checks. A zero value disables the periodic check.
+	Interval time.Duration `yaml:"interval" default:"1h"`
+	// Repair orphaned rows where possible, e.g., by deleting escalation recipients referencing deleted contacts.
+	Repair bool `yaml:"repair"`
+}
+
+// MailGatewayConfig configures the optional LMTP server converting received emails into events.
+type MailGatewayConfig struct {
+	// Listen is either a "host:port" pair or an absolute Unix socket path. An empty value disables the mail gateway.
+	Listen string `yaml:"listen"`
+	// SourceId references the source all events created by the mail gateway are attributed to.
+	SourceId int64 `yaml:"source-id"`
+	// MaxMessageBytes limits the size of a single received email.
+	MaxMessageBytes int64 `yaml:"max-message-bytes" default:"1048576"`
+	// Rules are evaluated in order for each email, the first matching rule creates the event.
+	Rules []MailGatewayRule `yaml:"rules"`
+}
+
+// MailGatewayRule describes how an email is matched and converted into an event.
+//
+// Both Subject and Body are regular expressions. Their named capture groups can be referenced in all other string
+// fields, e.g., as "$host" or "${host}".
+type MailGatewayRule struct {
+	Subject  string            `yaml:"subject"`
+	Body     string            `yaml:"body"`
+	Name     string            `yaml:"name"`
+	Tags     map[string]string `yaml:"tags"`
+	Type     string            `yaml:"type"`
+	Severity string            `yaml:"severity"`
+	Message  string            `yaml:"message"`
+}
+
+// Validate checks the mail gateway configuration if it is enabled.
+func (c *MailGatewayConfig) Validate() error {
+	if c.Listen == "" {
+		return nil
+	}
+
+	if c.SourceId <= 0 {
+		return errors.New("mail-gateway.source-id must be set if mail-gateway.listen is set")
+	}
+	if len(c.Rules) == 0 {
+		return errors.New("mail-gateway.rules must not be empty if mail-gateway.listen is set")
+	}
+
+	for i, rule := range c.Rules {
+		if rule.Subject == "" && rule.Body == "" {
+			return fmt.Errorf("mail-gateway.rules[%d] requires at least one of subject or body", i)
+		}
+		for _, expr := range []string{rule.Subject, rule.Body} {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("mail-gateway.rules[%d] has an invalid regular expression: %w", i, err)
+			}
+		}
+		if len(rule.Tags) == 0 {
+			return fmt.Errorf("mail-gateway.rules[%d].tags must not be empty", i)
+		}
+	}
+
+	return nil
 }
 
 // SetDefaults implements the defaults.Setter interface.
@@ -43,6 +724,84 @@ func (c *ConfigFile) Validate() error {
 	if err := c.Logging.Validate(); err != nil {
 		return err
 	}
+	if err := c.HA.Validate(); err != nil {
+		return err
+	}
+	if err := c.Sharding.Validate(); err != nil {
+		return err
+	}
+	if err := c.ListenerTLS.Validate(); err != nil {
+		return err
+	}
+	if err := c.MailGateway.Validate(); err != nil {
+		return err
+	}
+	if err := c.Flapping.Validate(); err != nil {
+		return err
+	}
+	if err := c.MutedSummary.Validate(); err != nil {
+		return err
+	}
+	if err := c.AckLinks.Validate(); err != nil {
+		return err
+	}
+	if err := c.ChatOps.Validate(); err != nil {
+		return err
+	}
+	if err := c.EventBuffer.Validate(); err != nil {
+		return err
+	}
+	if err := c.EventQueue.Validate(); err != nil {
+		return err
+	}
+	if err := c.Retention.Validate(); err != nil {
+		return err
+	}
+	if err := c.CalDAV.Validate(); err != nil {
+		return err
+	}
+	if err := c.SelfTest.Validate(); err != nil {
+		return err
+	}
+	if err := c.ChannelHealthCheck.Validate(); err != nil {
+		return err
+	}
+	if err := c.ChannelConcurrency.Validate(); err != nil {
+		return err
+	}
+	if err := c.DeliveryFailures.Validate(); err != nil {
+		return err
+	}
+	if err := c.Throttling.Validate(); err != nil {
+		return err
+	}
+	if err := c.Enrichment.Validate(); err != nil {
+		return err
+	}
+	if err := c.NotificationLatency.Validate(); err != nil {
+		return err
+	}
+	if err := c.Scrub.Validate(); err != nil {
+		return err
+	}
+	if c.CatchupDuplicates < 0 {
+		return errors.New("catch-up-duplicate-window must not be negative")
+	}
+	if c.CatchupPageSize < 1 {
+		return errors.New("catch-up-page-size must be at least 1")
+	}
+	if c.CatchupWorkers < 1 {
+		return errors.New("catch-up-concurrency must be at least 1")
+	}
+	if c.ShutdownTimeout < 0 {
+		return errors.New("shutdown-timeout must not be negative")
+	}
+	if c.EventWorkers < 1 {
+		return errors.New("event-workers must be at least 1")
+	}
+	if c.DatabaseNotifications && c.Database.Type != "pgsql" {
+		return errors.New("database-notifications requires a PostgreSQL database")
+	}
 
 	return nil
 }
@@ -59,6 +818,21 @@ type Flags struct {
 	Version bool `long:"version" description:"print version and exit"`
 	// Config is the path to the config file
 	Config string `short:"c" long:"config" description:"path to config file"`
+	// ImportRules is the path to a rules document to be imported into the database before exiting.
+	ImportRules string `long:"import-rules" description:"import rules from a YAML or JSON file into the database and exit"`
+}
+
+// daemonFlags holds the parsed CLI flags as a singleton, set by the ParseFlagsAndConfig func.
+var daemonFlags *Flags
+
+// CLIFlags returns the CLI flags that were parsed while starting the daemon.
+// Panics when ParseFlagsAndConfig was not called earlier.
+func CLIFlags() *Flags {
+	if daemonFlags == nil {
+		panic("ERROR: daemon.CLIFlags() called before daemon.ParseFlagsAndConfig()")
+	}
+
+	return daemonFlags
 }
 
 // daemonConfig holds the configuration state as a singleton.
@@ -92,6 +866,8 @@ func ParseFlagsAndConfig() {
 		os.Exit(ExitSuccess)
 	}
 
+	daemonFlags = &flags
+
 	daemonConfig = new(ConfigFile)
 	if err := config.FromYAMLFile(flags.Config, daemonConfig); err != nil {
 		if errors.Is(err, config.ErrInvalidArgument) {
@@ -0,0 +1,230 @@
+// Package enrichment attaches organizational metadata, e.g., the owning team or a runbook URL, as extra tags to the
+// events of all sources before they are processed. Thus, rules, escalation conditions and message templates can use
+// metadata not known to the sources themselves, looked up in a static file or via an HTTP endpoint.
+package enrichment
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxResponseSize limits the response of the enrichment endpoint.
+const MaxResponseSize = 1 << 20
+
+// Enricher adds the extra tags looked up for the events' objects to them, see Enrich.
+//
+// An Enricher is safe for concurrent use, the zero value does not change any events.
+type Enricher struct {
+	// key is the tag whose value is looked up in the file.
+	key  string
+	file map[string]map[string]string
+
+	url      string
+	client   *http.Client
+	cacheTTL time.Duration
+	cache    map[string]*cacheEntry
+	cacheMu  sync.Mutex
+
+	logger *zap.SugaredLogger
+}
+
+// cacheEntry holds the extra tags returned by the endpoint for an object.
+type cacheEntry struct {
+	tags      map[string]string
+	expiresAt time.Time
+}
+
+// Default enriches the events of all sources, see Enrich. It is set up by the daemon on startup.
+var Default = &Enricher{}
+
+// New creates an Enricher for the given configuration, loading the file right away.
+func New(conf daemon.EnrichmentConfig, logger *zap.SugaredLogger) (*Enricher, error) {
+	e := &Enricher{key: conf.Key, url: conf.URL, cacheTTL: conf.CacheTTL, logger: logger}
+
+	if conf.File != "" {
+		var err error
+		if e.file, err = loadFile(conf.File); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.URL != "" {
+		e.client = &http.Client{Timeout: conf.Timeout}
+		e.cache = make(map[string]*cacheEntry)
+	}
+
+	return e, nil
+}
+
+// loadFile reads the extra tags per tag value from a JSON or CSV file, depending on its extension.
+//
+// A JSON file contains an object mapping each tag value to an object of extra tags, e.g.,
+// {"db-01": {"owner": "dba"}}. The first row of a CSV file names the extra tags of its columns, except for the first
+// column containing the tag values.
+func loadFile(path string) (map[string]map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read enrichment file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		var tags map[string]map[string]string
+		if err := json.Unmarshal(content, &tags); err != nil {
+			return nil, fmt.Errorf("cannot parse enrichment file %q: %w", path, err)
+		}
+
+		return tags, nil
+	case ".csv":
+		records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse enrichment file %q: %w", path, err)
+		}
+		if len(records) == 0 {
+			return nil, nil
+		}
+
+		header := records[0]
+		tags := make(map[string]map[string]string, len(records)-1)
+		for _, record := range records[1:] {
+			row := make(map[string]string, len(header)-1)
+			for i := 1; i < len(header); i++ {
+				if record[i] != "" {
+					row[header[i]] = record[i]
+				}
+			}
+			tags[record[0]] = row
+		}
+
+		return tags, nil
+	default:
+		return nil, fmt.Errorf("enrichment file %q must be either a .json or a .csv file, got %q", path, ext)
+	}
+}
+
+// Enrich adds the extra tags looked up for the event's object to its extra tags.
+//
+// The extra tags sent by the source take precedence over looked up ones, while those returned by the endpoint take
+// precedence over the ones of the file. As the enrichment is optional, a failing endpoint is only logged and the event
+// is processed nonetheless.
+func (e *Enricher) Enrich(ctx context.Context, ev *event.Event) {
+	// Tags are only added if not already present, thus the ones added first take precedence.
+	var tags []map[string]string
+	if e.url != "" {
+		fetched, err := e.lookup(ctx, ev)
+		if err != nil {
+			e.logger.Warnw("Cannot look up extra tags of event", zap.Stringer("event", ev), zap.Error(err))
+		}
+		tags = append(tags, fetched)
+	}
+
+	if value, ok := ev.Tags[e.key]; ok && e.file != nil {
+		tags = append(tags, e.file[value])
+	}
+
+	for _, extraTags := range tags {
+		for tag, value := range extraTags {
+			if ev.ExtraTags == nil {
+				ev.ExtraTags = make(map[string]string)
+			}
+			if _, ok := ev.ExtraTags[tag]; !ok {
+				ev.ExtraTags[tag] = value
+			}
+		}
+	}
+}
+
+// lookup returns the extra tags of the event's object, either from the cache or by requesting them from the endpoint.
+//
+// If the endpoint fails, the expired tags of the object are returned together with the error, if they are still cached.
+// Expired entries are kept for another TTL for this purpose.
+func (e *Enricher) lookup(ctx context.Context, ev *event.Event) (map[string]string, error) {
+	key := object.ID(ev.SourceId, ev.Tags).String()
+	now := time.Now()
+
+	e.cacheMu.Lock()
+	cached := e.cache[key]
+	e.cacheMu.Unlock()
+	if cached != nil && now.Before(cached.expiresAt) {
+		return cached.tags, nil
+	}
+
+	tags, err := e.fetch(ctx, ev)
+	if err != nil {
+		if cached != nil {
+			return cached.tags, err
+		}
+		return nil, err
+	}
+
+	e.cacheMu.Lock()
+	defer e.cacheMu.Unlock()
+
+	for k, entry := range e.cache {
+		if !now.Before(entry.expiresAt.Add(e.cacheTTL)) {
+			delete(e.cache, k)
+		}
+	}
+	if e.cacheTTL > 0 {
+		e.cache[key] = &cacheEntry{tags: tags, expiresAt: now.Add(e.cacheTTL)}
+	}
+
+	return tags, nil
+}
+
+// request is sent to the endpoint, identifying the object of the event.
+type request struct {
+	SourceID  int64             `json:"source_id"`
+	Name      string            `json:"name"`
+	Tags      map[string]string `json:"tags"`
+	ExtraTags map[string]string `json:"extra_tags"`
+}
+
+// fetch requests the extra tags of the event's object from the endpoint.
+func (e *Enricher) fetch(ctx context.Context, ev *event.Event) (map[string]string, error) {
+	body, err := json.Marshal(&request{SourceID: ev.SourceId, Name: ev.Name, Tags: ev.Tags, ExtraTags: ev.ExtraTags})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode == http.StatusNotFound {
+		// The endpoint doesn't know this object, which is no error.
+		return nil, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("enrichment endpoint responded with %s", res.Status)
+	}
+
+	var tags map[string]string
+	if err := json.NewDecoder(io.LimitReader(res.Body, MaxResponseSize)).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("cannot parse response of enrichment endpoint: %w", err)
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,185 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	write := func(t *testing.T, name, content string) string {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		t.Parallel()
+
+		path := write(t, "enrichment.json", `{"db-01": {"owner": "dba", "runbook": "https://wiki.example.com/db"}}`)
+		e, err := New(daemon.EnrichmentConfig{File: path, Key: "host"}, zaptest.NewLogger(t).Sugar())
+		require.NoError(t, err)
+		assert.Equal(t, map[string]map[string]string{
+			"db-01": {"owner": "dba", "runbook": "https://wiki.example.com/db"},
+		}, e.file)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		t.Parallel()
+
+		path := write(t, "enrichment.CSV", "host,owner,runbook\ndb-01,dba,https://wiki.example.com/db\nweb-01,web,\n")
+		e, err := New(daemon.EnrichmentConfig{File: path, Key: "host"}, zaptest.NewLogger(t).Sugar())
+		require.NoError(t, err)
+		assert.Equal(t, map[string]map[string]string{
+			"db-01":  {"owner": "dba", "runbook": "https://wiki.example.com/db"},
+			"web-01": {"owner": "web"},
+		}, e.file, "empty columns must be omitted")
+	})
+
+	for name, file := range map[string][2]string{
+		"InvalidJSON":      {"enrichment.json", `{"db-01": "dba"}`},
+		"InconsistentCSV":  {"enrichment.csv", "host,owner\ndb-01\n"},
+		"UnknownExtension": {"enrichment.yml", "db-01: {owner: dba}"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := New(daemon.EnrichmentConfig{File: write(t, file[0], file[1]), Key: "host"}, nil)
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("MissingFile", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := New(daemon.EnrichmentConfig{File: filepath.Join(t.TempDir(), "missing.json"), Key: "host"}, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestEnricher_Enrich(t *testing.T) {
+	t.Parallel()
+
+	newEvent := func() *event.Event {
+		return &event.Event{
+			SourceId:  1,
+			Name:      "db-01!disk",
+			Tags:      map[string]string{"host": "db-01", "service": "disk"},
+			ExtraTags: map[string]string{"hostgroup/database": ""},
+		}
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+
+		ev := newEvent()
+		Default.Enrich(context.Background(), ev)
+		assert.Equal(t, newEvent(), ev)
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req request
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, request{
+				SourceID:  1,
+				Name:      "db-01!disk",
+				Tags:      map[string]string{"host": "db-01", "service": "disk"},
+				ExtraTags: map[string]string{"hostgroup/database": ""},
+			}, req)
+
+			_, _ = w.Write([]byte(`{"owner": "dba-oncall", "hostgroup/database": "overwritten"}`))
+		}))
+		defer server.Close()
+
+		e := &Enricher{
+			key:    "host",
+			file:   map[string]map[string]string{"db-01": {"owner": "dba", "runbook": "https://wiki.example.com/db"}},
+			url:    server.URL,
+			client: server.Client(),
+			cache:  map[string]*cacheEntry{},
+			logger: zaptest.NewLogger(t).Sugar(),
+		}
+
+		ev := newEvent()
+		e.Enrich(context.Background(), ev)
+		assert.Equal(t, map[string]string{
+			"hostgroup/database": "",
+			"owner":              "dba-oncall",
+			"runbook":            "https://wiki.example.com/db",
+		}, ev.ExtraTags, "source must take precedence over endpoint, endpoint over file")
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		t.Parallel()
+
+		var requests atomic.Int32
+		var failing atomic.Bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			switch {
+			case failing.Load():
+				w.WriteHeader(http.StatusInternalServerError)
+			case r.URL.Path == "/unknown":
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				_, _ = w.Write([]byte(`{"owner": "dba"}`))
+			}
+		}))
+		defer server.Close()
+
+		e := &Enricher{
+			url:      server.URL,
+			client:   server.Client(),
+			cacheTTL: time.Hour,
+			cache:    map[string]*cacheEntry{},
+			logger:   zaptest.NewLogger(t).Sugar(),
+		}
+
+		ev := newEvent()
+		e.Enrich(context.Background(), ev)
+		assert.Equal(t, "dba", ev.ExtraTags["owner"])
+
+		ev = newEvent()
+		e.Enrich(context.Background(), ev)
+		assert.Equal(t, "dba", ev.ExtraTags["owner"])
+		assert.Equal(t, int32(1), requests.Load(), "response must be cached")
+
+		for _, entry := range e.cache {
+			entry.expiresAt = time.Now().Add(-time.Minute)
+		}
+		failing.Store(true)
+
+		ev = newEvent()
+		e.Enrich(context.Background(), ev)
+		assert.Equal(t, "dba", ev.ExtraTags["owner"], "expired response must be used if the endpoint fails")
+		assert.Equal(t, int32(2), requests.Load())
+
+		ev = newEvent()
+		ev.Tags["host"] = "web-01"
+		e.Enrich(context.Background(), ev)
+		assert.Equal(t, map[string]string{"hostgroup/database": ""}, ev.ExtraTags, "failing endpoint must be ignored")
+
+		failing.Store(false)
+		e.url = server.URL + "/unknown"
+		ev = newEvent()
+		ev.Tags["host"] = "app-01"
+		e.Enrich(context.Background(), ev)
+		assert.Equal(t, map[string]string{"hostgroup/database": ""}, ev.ExtraTags, "unknown objects must be ignored")
+	})
+}
@@ -0,0 +1,129 @@
+// Package errs defines the kinds of errors shared across the internal packages, allowing callers to react to errors
+// programmatically instead of by their messages.
+//
+// An error is classified by wrapping it with one of the sentinel errors below, which is then detectable via errors.Is.
+// Callers use this to decide, for example, whether an operation is worth retrying or which HTTP status code to send.
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/icinga/icinga-go-library/retry"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrTransientDB marks database errors which are expected to vanish when retrying, e.g., a lost connection or a
+	// transaction aborted due to a deadlock.
+	ErrTransientDB = errors.New("transient database error")
+
+	// ErrConfigMissing marks errors caused by a referenced configuration object being unknown, e.g., a deleted channel.
+	ErrConfigMissing = errors.New("configuration missing")
+
+	// ErrChannelPermanent marks notification failures reported by the channel plugin itself. As the plugin has already
+	// processed the notification request, those are not retried by the daemon.
+	ErrChannelPermanent = errors.New("permanent channel error")
+
+	// ErrSchemaIncompatible marks operations refused because the database schema version is not supported by this
+	// daemon, e.g., during an upgrade where the schema is already newer.
+	ErrSchemaIncompatible = errors.New("incompatible database schema")
+
+	// ErrShuttingDown marks operations refused because the daemon is shutting down. Those can be retried against
+	// another instance or after the restart.
+	ErrShuttingDown = errors.New("daemon is shutting down")
+)
+
+// WrapDB wraps the error with ErrTransientDB if it is transient, see IsTransientDB.
+//
+// Other errors, including nil, are returned unchanged. Thus, it can be applied to any error returned by a database
+// operation, e.g., `return errs.WrapDB(err)`.
+func WrapDB(err error) error {
+	if err == nil || errors.Is(err, ErrTransientDB) || !IsTransientDB(err) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", ErrTransientDB, err)
+}
+
+// IsTransientDB checks whether a database operation failed due to a temporary condition and may succeed when retried.
+//
+// Besides connection failures, this includes deadlocks, serialization failures and lock wait timeouts. Other errors
+// reported by the database server, e.g., constraint violations, are permanent.
+func IsTransientDB(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, ErrTransientDB) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case
+			1040, // ER_CON_COUNT_ERROR: Too many connections
+			1053, // ER_SERVER_SHUTDOWN: Server shutdown in progress
+			1205, // ER_LOCK_WAIT_TIMEOUT: Lock wait timeout exceeded
+			1213: // ER_LOCK_DEADLOCK: Deadlock found when trying to get lock
+			return true
+		default:
+			return false
+		}
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case
+			"08", // Connection Exception
+			"40", // Transaction Rollback, e.g., serialization_failure or deadlock_detected
+			"53": // Insufficient Resources, e.g., too_many_connections
+			return true
+		case "57": // Operator Intervention, only the shutdown related ones
+			return pqErr.Code != "57014" // query_canceled
+		default:
+			return false
+		}
+	}
+
+	// As all database server errors were handled above, only the network related checks apply.
+	return retry.Retryable(err)
+}
+
+// IsRetryableTx checks whether the database aborted a transaction due to a conflict with a concurrent transaction.
+//
+// This includes deadlocks, serialization failures and lock wait timeouts. As such a transaction had no effect, it can
+// safely be retried from its beginning, in contrast to, e.g., a connection lost while committing.
+func IsRetryableTx(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1205 || mysqlErr.Number == 1213
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Transaction Rollback, e.g., serialization_failure or deadlock_detected, and lock_not_available
+		return pqErr.Code.Class() == "40" || pqErr.Code == "55P03"
+	}
+
+	return false
+}
+
+// IsDuplicateKey checks whether a database operation failed because it violated a primary key or unique constraint,
+// e.g., when a concurrent transaction has just inserted the same row.
+func IsDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062 // ER_DUP_ENTRY: Duplicate entry for key
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" // unique_violation
+	}
+
+	return false
+}
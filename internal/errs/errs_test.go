@@ -0,0 +1,103 @@
+package errs
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestIsTransientDB(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"Nil", nil, false},
+		{"NoRows", sql.ErrNoRows, false},
+		{"Canceled", context.Canceled, false},
+		{"BadConn", fmt.Errorf("query failed: %w", driver.ErrBadConn), true},
+		{"EOF", io.ErrUnexpectedEOF, true},
+		{"MySQLDeadlock", &mysql.MySQLError{Number: 1213}, true},
+		{"MySQLLockWaitTimeout", &mysql.MySQLError{Number: 1205}, true},
+		{"MySQLDuplicateEntry", &mysql.MySQLError{Number: 1062}, false},
+		{"PgsqlSerializationFailure", &pq.Error{Code: "40001"}, true},
+		{"PgsqlDeadlock", &pq.Error{Code: "40P01"}, true},
+		{"PgsqlConnectionFailure", &pq.Error{Code: "08006"}, true},
+		{"PgsqlAdminShutdown", &pq.Error{Code: "57P01"}, true},
+		{"PgsqlQueryCanceled", &pq.Error{Code: "57014"}, false},
+		{"PgsqlUniqueViolation", &pq.Error{Code: "23505"}, false},
+		{"AlreadyWrapped", fmt.Errorf("%w: foo", ErrTransientDB), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.transient, IsTransientDB(tt.err))
+		})
+	}
+}
+
+func TestWrapDB(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, WrapDB(nil))
+
+	permanent := &pq.Error{Code: "23505"}
+	assert.Same(t, permanent, WrapDB(permanent))
+
+	deadlock := &mysql.MySQLError{Number: 1213}
+	wrapped := WrapDB(deadlock)
+	assert.ErrorIs(t, wrapped, ErrTransientDB)
+	assert.ErrorIs(t, wrapped, deadlock)
+	assert.Equal(t, wrapped, WrapDB(wrapped), "must not be wrapped twice")
+	assert.False(t, errors.Is(WrapDB(permanent), ErrTransientDB))
+}
+
+func TestIsRetryableTx(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"Nil", nil, false},
+		{"BadConn", driver.ErrBadConn, false},
+		{"MySQLDeadlock", fmt.Errorf("cannot commit: %w", &mysql.MySQLError{Number: 1213}), true},
+		{"MySQLLockWaitTimeout", &mysql.MySQLError{Number: 1205}, true},
+		{"MySQLTooManyConnections", &mysql.MySQLError{Number: 1040}, false},
+		{"PgsqlSerializationFailure", &pq.Error{Code: "40001"}, true},
+		{"PgsqlDeadlock", &pq.Error{Code: "40P01"}, true},
+		{"PgsqlLockNotAvailable", &pq.Error{Code: "55P03"}, true},
+		{"PgsqlConnectionFailure", &pq.Error{Code: "08006"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.retryable, IsRetryableTx(tt.err))
+		})
+	}
+}
+
+func TestIsDuplicateKey(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, IsDuplicateKey(nil))
+	assert.False(t, IsDuplicateKey(driver.ErrBadConn))
+	assert.True(t, IsDuplicateKey(fmt.Errorf("cannot insert: %w", &mysql.MySQLError{Number: 1062})))
+	assert.False(t, IsDuplicateKey(&mysql.MySQLError{Number: 1213}))
+	assert.True(t, IsDuplicateKey(&pq.Error{Code: "23505"}))
+	assert.False(t, IsDuplicateKey(&pq.Error{Code: "23503"}), "foreign key violation")
+}
@@ -41,6 +41,12 @@ type Event struct {
 	Mute       types.Bool `json:"mute"`
 	MuteReason string     `json:"mute_reason"`
 
+	// TraceID correlates this event with everything caused by it, see EnsureTraceID. Sources may supply their own ID.
+	TraceID string `json:"trace_id"`
+
+	// SeverityChange is set by the daemon for state events changing the severity of an incident, see SeverityChangeOf.
+	SeverityChange string `json:"-"`
+
 	ID int64 `json:"-"`
 }
 
@@ -57,6 +63,7 @@ const (
 	TypeFlappingStart          = "flapping-start"
 	TypeIncidentAge            = "incident-age"
 	TypeMute                   = "mute"
+	TypeMutedSummary           = "muted-summary"
 	TypeState                  = "state"
 	TypeUnmute                 = "unmute"
 )
@@ -86,6 +93,10 @@ func (e *Event) Validate() error {
 		return fmt.Errorf("invalid event: source ID must not be empty")
 	}
 
+	if err := validateTraceID(e.TraceID); err != nil {
+		return fmt.Errorf("invalid event: %w", err)
+	}
+
 	if e.Severity != SeverityNone && e.Type != TypeState {
 		return fmt.Errorf("invalid event: if 'severity' is set, 'type' must be set to %q", TypeState)
 	}
@@ -113,6 +124,7 @@ func (e *Event) Validate() error {
 		TypeFlappingStart,
 		TypeIncidentAge,
 		TypeMute,
+		TypeMutedSummary,
 		TypeState,
 		TypeUnmute:
 		return nil
@@ -128,7 +140,7 @@ func (e *Event) SetMute(muted bool, reason string) {
 }
 
 func (e *Event) String() string {
-	return fmt.Sprintf("[time=%s type=%q severity=%s]", e.Time, e.Type, e.Severity.String())
+	return fmt.Sprintf("[time=%s type=%q severity=%s trace_id=%s]", e.Time, e.Type, e.Severity.String(), e.TraceID)
 }
 
 func (e *Event) FullString() string {
@@ -166,6 +178,9 @@ func (e *Event) FullString() string {
 	if e.Message != "" {
 		_, _ = fmt.Fprintf(&b, "  Message: %q\n", e.Message)
 	}
+	if e.TraceID != "" {
+		_, _ = fmt.Fprintf(&b, "  TraceID: %q\n", e.TraceID)
+	}
 	return b.String()
 }
 
@@ -195,6 +210,7 @@ type EventRow struct {
 	Message    types.String    `db:"message"`
 	Mute       types.Bool      `db:"mute"`
 	MuteReason types.String    `db:"mute_reason"`
+	TraceID    types.String    `db:"trace_id"`
 }
 
 // TableName implements the contracts.TableNamer interface.
@@ -212,5 +228,6 @@ func NewEventRow(e *Event, objectId types.Binary) *EventRow {
 		Message:    utils.ToDBString(e.Message),
 		Mute:       e.Mute,
 		MuteReason: utils.ToDBString(e.MuteReason),
+		TraceID:    utils.ToDBString(e.TraceID),
 	}
 }
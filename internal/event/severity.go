@@ -120,3 +120,22 @@ func (s Severity) Value() (driver.Value, error) {
 func (s *Severity) String() string {
 	return severityToName[*s]
 }
+
+// Directions of a severity change, see SeverityChangeOf.
+const (
+	SeverityIncreased = "increased"
+	SeverityDecreased = "decreased"
+)
+
+// SeverityChangeOf returns whether the severity increased or decreased from old to new, e.g., SeverityDecreased for a
+// recovery, or an empty string if it is unchanged.
+func SeverityChangeOf(old, new Severity) string {
+	switch {
+	case new > old:
+		return SeverityIncreased
+	case new < old:
+		return SeverityDecreased
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,58 @@
+package event
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// MaxTraceIDLength is the maximum length of a trace ID, either generated or supplied by a source.
+const MaxTraceIDLength = 64
+
+// NewTraceID generates a random trace ID, formatted like a W3C Trace Context trace-id of 32 hex characters.
+func NewTraceID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+
+	return hex.EncodeToString(id[:])
+}
+
+// EnsureTraceID sets a new trace ID for this event unless it already has one, e.g., supplied by its source.
+//
+// The trace ID is generated once at ingestion and passed through the whole notification pipeline, i.e., logs, history
+// rows and notification requests to the channel plugins, allowing to follow a single alert across all systems.
+func (e *Event) EnsureTraceID() {
+	if e.TraceID == "" {
+		e.TraceID = NewTraceID()
+	}
+}
+
+// validateTraceID checks that a trace ID is safe to be passed on, e.g., as an HTTP header by the webhook channel.
+func validateTraceID(id string) error {
+	if len(id) > MaxTraceIDLength {
+		return fmt.Errorf("trace ID must not be longer than %d chars, %d given", MaxTraceIDLength, len(id))
+	}
+
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return fmt.Errorf("trace ID %q must only consist of ASCII letters, digits, '-', '_' and '.'", id)
+		}
+	}
+
+	return nil
+}
+
+// traceIDKey is the context key of the trace ID, see ContextWithTraceID.
+type traceIDKey struct{}
+
+// ContextWithTraceID returns a copy of the context carrying the given trace ID, see TraceIDFromContext.
+func ContextWithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceIDFromContext returns the trace ID of the event being processed within this context, or an empty string.
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,46 @@
+package event
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestEvent_EnsureTraceID(t *testing.T) {
+	t.Parallel()
+
+	ev := &Event{}
+	ev.EnsureTraceID()
+	assert.Len(t, ev.TraceID, 32)
+	assert.NoError(t, validateTraceID(ev.TraceID))
+
+	ev.EnsureTraceID()
+	assert.Len(t, ev.TraceID, 32, "an existing trace ID must be kept")
+
+	other := &Event{}
+	other.EnsureTraceID()
+	assert.NotEqual(t, ev.TraceID, other.TraceID)
+
+	supplied := &Event{TraceID: "upstream-42"}
+	supplied.EnsureTraceID()
+	assert.Equal(t, "upstream-42", supplied.TraceID)
+}
+
+func TestValidateTraceID(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validateTraceID(""))
+	assert.NoError(t, validateTraceID("Trace_1.2-3"))
+	assert.NoError(t, validateTraceID(strings.Repeat("a", MaxTraceIDLength)))
+	assert.Error(t, validateTraceID(strings.Repeat("a", MaxTraceIDLength+1)))
+	assert.Error(t, validateTraceID("trace\r\nX-Injected: 1"))
+	assert.Error(t, validateTraceID("trace id"))
+}
+
+func TestTraceIDFromContext(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, TraceIDFromContext(context.Background()))
+	assert.Equal(t, "42", TraceIDFromContext(ContextWithTraceID(context.Background(), "42")))
+}
@@ -0,0 +1,335 @@
+// Package eventbuffer keeps submitted events while the database is unavailable, allowing the daemon to continue
+// accepting events in a degraded mode instead of rejecting them outright.
+//
+// A Buffer holds a bounded number of events in memory, optionally persisted to a file to survive a restart. Once the
+// database is reachable again, Run drains the buffered events in the order they were received. While events are
+// buffered, new events must be buffered as well, so that the events of an object are never processed out of order.
+package eventbuffer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-notifications/internal/errs"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrBufferFull is returned by Buffer.Add if the event was dropped because the buffer has reached its size.
+var ErrBufferFull = errors.New("event buffer is full")
+
+// ProcessFunc processes a single event, e.g., by incident.ProcessEvent.
+type ProcessFunc func(ctx context.Context, ev *event.Event) error
+
+// PingFunc checks whether the database is reachable again, e.g., by database.DB.PingContext.
+type PingFunc func(ctx context.Context) error
+
+// Stats describes the state of a Buffer, as reported by the health endpoint.
+type Stats struct {
+	Degraded      bool       `json:"degraded"`
+	DegradedSince *time.Time `json:"degraded_since,omitempty"`
+	Buffered      int        `json:"buffered"`
+	Capacity      int        `json:"capacity"`
+
+	// The following counters are totals since the daemon was started.
+	BufferedTotal uint64 `json:"buffered_total"`
+	DrainedTotal  uint64 `json:"drained_total"`
+	FailedTotal   uint64 `json:"failed_total"`
+	DroppedTotal  uint64 `json:"dropped_total"`
+}
+
+// record is the representation of a buffered event within the file, including the fields omitted by event.Event's JSON.
+type record struct {
+	Time     time.Time    `json:"time"`
+	SourceID int64        `json:"source_id"`
+	Event    *event.Event `json:"event"`
+}
+
+// Buffer of events received while the database is unavailable, see New.
+type Buffer struct {
+	size    int
+	file    string
+	process ProcessFunc
+	ping    PingFunc
+	logger  *logging.Logger
+
+	mu            sync.Mutex
+	events        []*event.Event
+	degradedSince time.Time
+	buffered      uint64
+	drained       uint64
+	failed        uint64
+	dropped       uint64
+}
+
+// New creates a Buffer of the given size, processing the buffered events by process once ping succeeds.
+//
+// If file is not empty, the buffered events are persisted to it. Events left in the file by a previous run are loaded
+// immediately, putting the Buffer into the degraded mode until they are drained.
+func New(size int, file string, process ProcessFunc, ping PingFunc, logger *logging.Logger) (*Buffer, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("event buffer size must be positive, got %d", size)
+	}
+
+	b := &Buffer{size: size, file: file, process: process, ping: ping, logger: logger}
+	if err := b.load(); err != nil {
+		return nil, err
+	}
+
+	if len(b.events) > 0 {
+		b.degradedSince = time.Now()
+		b.logger.Warnw("Loaded buffered events of a previous run, processing them once the database is reachable",
+			zap.String("file", b.file), zap.Int("events", len(b.events)))
+	}
+
+	return b, nil
+}
+
+// Degraded reports whether events are currently buffered instead of being processed.
+func (b *Buffer) Degraded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return !b.degradedSince.IsZero()
+}
+
+// Add buffers the event and enters the degraded mode.
+//
+// If the buffer is full, the event is dropped and ErrBufferFull is returned, leaving it to the sender to retry later.
+func (b *Buffer) Add(ev *event.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.degradedSince.IsZero() {
+		b.degradedSince = time.Now()
+		b.logger.Warnw("Database is unavailable, buffering events until it is reachable again",
+			zap.Int("capacity", b.size))
+	}
+
+	if len(b.events) >= b.size {
+		if b.dropped == 0 {
+			b.logger.Errorw("Event buffer is full, dropping events", zap.Int("capacity", b.size))
+		}
+		b.dropped++
+		return ErrBufferFull
+	}
+
+	if err := b.appendToFile(ev); err != nil {
+		// The event is still kept in memory, thus only its persistence is lost.
+		b.logger.Errorw("Cannot persist buffered event", zap.String("file", b.file), zap.Error(err))
+	}
+
+	b.events = append(b.events, ev)
+	b.buffered++
+	return nil
+}
+
+// Drain processes the buffered events in order until the buffer is empty or the database fails again.
+//
+// Events failing for other reasons than a transient database error or the daemon shutting down are logged and
+// discarded, just as if they were rejected when submitted. Once the buffer is empty and the database is reachable, the
+// degraded mode is left.
+func (b *Buffer) Drain(ctx context.Context) error {
+	defer func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if err := b.writeFile(); err != nil {
+			b.logger.Errorw("Cannot persist buffered events", zap.String("file", b.file), zap.Error(err))
+		}
+	}()
+
+	for {
+		b.mu.Lock()
+		if len(b.events) == 0 {
+			b.mu.Unlock()
+			break
+		}
+		ev := b.events[0]
+		b.mu.Unlock()
+
+		err := b.process(ctx, ev)
+		if errs.IsTransientDB(err) || errors.Is(err, errs.ErrShuttingDown) || (err != nil && ctx.Err() != nil) {
+			return err
+		}
+
+		b.mu.Lock()
+		b.events[0] = nil
+		b.events = b.events[1:]
+		if err != nil && !errors.Is(err, event.ErrSuperfluousStateChange) &&
+			!errors.Is(err, event.ErrSuperfluousMuteUnmuteEvent) {
+			b.failed++
+			b.logger.Errorw("Failed to process buffered event", zap.Stringer("event", ev), zap.Error(err))
+		} else {
+			b.drained++
+		}
+		b.mu.Unlock()
+
+		// The event was processed regardless of the context, as it would otherwise be processed twice.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	if err := b.ping(ctx); err != nil {
+		return errs.WrapDB(err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.events) == 0 && !b.degradedSince.IsZero() {
+		b.logger.Infow("Database is reachable again, leaving degraded mode",
+			zap.Duration("duration", time.Since(b.degradedSince)), zap.Uint64("drained_total", b.drained),
+			zap.Uint64("dropped_total", b.dropped))
+		b.degradedSince = time.Time{}
+	}
+
+	return nil
+}
+
+// Run drains the buffer every interval while in degraded mode until the context is done.
+func (b *Buffer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if !b.Degraded() {
+			continue
+		}
+
+		if err := b.Drain(ctx); err != nil && ctx.Err() == nil {
+			b.logger.Debugw("Cannot drain event buffer yet", zap.Int("buffered", b.Stats().Buffered), zap.Error(err))
+		}
+	}
+}
+
+// Stats returns the current state of the buffer.
+func (b *Buffer) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := Stats{
+		Degraded:      !b.degradedSince.IsZero(),
+		Buffered:      len(b.events),
+		Capacity:      b.size,
+		BufferedTotal: b.buffered,
+		DrainedTotal:  b.drained,
+		FailedTotal:   b.failed,
+		DroppedTotal:  b.dropped,
+	}
+	if stats.Degraded {
+		since := b.degradedSince
+		stats.DegradedSince = &since
+	}
+
+	return stats
+}
+
+// load reads the events persisted to the file, if any.
+func (b *Buffer) load() error {
+	if b.file == "" {
+		return nil
+	}
+
+	f, err := os.Open(b.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil || r.Event == nil {
+			return fmt.Errorf("cannot parse buffered event in line %d of %q: %v", line, b.file, err)
+		}
+
+		r.Event.Time = r.Time
+		r.Event.SourceId = r.SourceID
+		b.events = append(b.events, r.Event)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("cannot read buffered events from %q: %w", b.file, err)
+	}
+	if len(b.events) > b.size {
+		b.dropped += uint64(len(b.events) - b.size)
+		b.events = b.events[:b.size]
+	}
+
+	return nil
+}
+
+// appendToFile appends a single event to the file, if any. The caller must hold the mutex.
+func (b *Buffer) appendToFile(ev *event.Event) error {
+	if b.file == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(record{Time: ev.Time, SourceID: ev.SourceId, Event: ev})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(b.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	return errors.Join(err, f.Close())
+}
+
+// writeFile replaces the file by the currently buffered events or removes it if there are none. The caller must hold
+// the mutex.
+func (b *Buffer) writeFile() error {
+	if b.file == "" {
+		return nil
+	}
+
+	if len(b.events) == 0 {
+		if err := os.Remove(b.file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(b.file), filepath.Base(b.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, ev := range b.events {
+		if err := enc.Encode(record{Time: ev.Time, SourceID: ev.SourceId, Event: ev}); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+
+	if err := errors.Join(w.Flush(), tmp.Close()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), b.file)
+}
@@ -0,0 +1,146 @@
+package eventbuffer
+
+import (
+	"context"
+	"errors"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-notifications/internal/errs"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDB records the processed events and fails with a transient error while it is down.
+type fakeDB struct {
+	mu        sync.Mutex
+	down      bool
+	processed []string
+}
+
+func (db *fakeDB) setDown(down bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.down = down
+}
+
+func (db *fakeDB) process(_ context.Context, ev *event.Event) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.down {
+		return errs.ErrTransientDB
+	}
+	if ev.Name == "invalid" {
+		return errors.New("invalid event")
+	}
+
+	db.processed = append(db.processed, ev.Name)
+	return nil
+}
+
+func (db *fakeDB) ping(context.Context) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.down {
+		return errors.New("connection refused")
+	}
+
+	return nil
+}
+
+func newBuffer(t *testing.T, size int, file string, db *fakeDB) *Buffer {
+	b, err := New(size, file, db.process, db.ping, logging.NewLogger(zaptest.NewLogger(t).Sugar(), time.Hour))
+	require.NoError(t, err)
+
+	return b
+}
+
+func makeEvent(name string) *event.Event {
+	return &event.Event{
+		Time:     time.Unix(1700000000, 0).UTC(),
+		SourceId: 1,
+		Name:     name,
+		Tags:     map[string]string{"host": name},
+		Type:     event.TypeState,
+		Severity: event.SeverityCrit,
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	t.Parallel()
+
+	db := &fakeDB{down: true}
+	b := newBuffer(t, 3, "", db)
+	assert.False(t, b.Degraded())
+
+	for _, name := range []string{"a", "invalid", "b"} {
+		require.NoError(t, b.Add(makeEvent(name)))
+	}
+	assert.ErrorIs(t, b.Add(makeEvent("c")), ErrBufferFull)
+	assert.True(t, b.Degraded())
+
+	assert.ErrorIs(t, b.Drain(context.Background()), errs.ErrTransientDB)
+	assert.Empty(t, db.processed)
+	assert.Equal(t, 3, b.Stats().Buffered)
+
+	db.setDown(false)
+	require.NoError(t, b.Drain(context.Background()))
+	assert.Equal(t, []string{"a", "b"}, db.processed)
+	assert.False(t, b.Degraded())
+
+	stats := b.Stats()
+	assert.Nil(t, stats.DegradedSince)
+	assert.Equal(t, Stats{Capacity: 3, BufferedTotal: 3, DrainedTotal: 2, FailedTotal: 1, DroppedTotal: 1}, stats)
+}
+
+func TestBuffer_StaysDegradedUntilPingSucceeds(t *testing.T) {
+	t.Parallel()
+
+	db := &fakeDB{down: true}
+	b := newBuffer(t, 1, "", db)
+	require.NoError(t, b.Add(makeEvent("a")))
+	assert.ErrorIs(t, b.Add(makeEvent("b")), ErrBufferFull)
+
+	// Even with all events processed, the degraded mode lasts as long as the database cannot be reached.
+	b.ping = func(context.Context) error { return errors.New("connection refused") }
+	db.setDown(false)
+	assert.Error(t, b.Drain(context.Background()))
+	assert.Equal(t, 0, b.Stats().Buffered)
+	assert.True(t, b.Degraded())
+
+	b.ping = db.ping
+	require.NoError(t, b.Drain(context.Background()))
+	assert.False(t, b.Degraded())
+	assert.Equal(t, []string{"a"}, db.processed)
+}
+
+func TestBuffer_File(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "events.jsonl")
+	db := &fakeDB{down: true}
+
+	b := newBuffer(t, 10, file, db)
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, b.Add(makeEvent(name)))
+	}
+
+	// A restarted daemon continues with the persisted events, including the fields hidden from event.Event's JSON.
+	restarted := newBuffer(t, 2, file, db)
+	assert.True(t, restarted.Degraded())
+	require.Len(t, restarted.events, 2)
+	assert.Equal(t, makeEvent("a"), restarted.events[0])
+	assert.Equal(t, uint64(1), restarted.Stats().DroppedTotal)
+
+	db.setDown(false)
+	require.NoError(t, restarted.Drain(context.Background()))
+	assert.Equal(t, []string{"a", "b"}, db.processed)
+	assert.NoFileExists(t, file)
+}
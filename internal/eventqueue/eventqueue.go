@@ -0,0 +1,382 @@
+// Package eventqueue decouples receiving events from processing them by a durable queue within the database.
+//
+// Producers, e.g., the listener or the Icinga 2 Event Stream clients, only insert each event into the event_queue table,
+// which is quick even for bursts of thousands of events, while a consumer per instance processes them in batches, each
+// object's events in the order they were enqueued. Each claimed batch of entries is hidden from further claims for the visibility timeout, and
+// each entry is deleted once its event was processed. Thus, no event is lost if the daemon crashes, but an event might
+// be processed twice, which is mostly harmless, as a repeated state change is ignored as superfluous.
+package eventqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/errs"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/latency"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/icinga/icinga-notifications/internal/shard"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/icinga/icinga-notifications/internal/workerpool"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProcessFunc processes a single event, e.g., by incident.ProcessEvent.
+type ProcessFunc func(ctx context.Context, ev *event.Event) error
+
+// Entry is a queued event as stored in the event_queue table.
+type Entry struct {
+	ID       int64           `db:"id"`
+	Shard    int             `db:"shard"`
+	SourceID int64           `db:"source_id"`
+	Time     types.UnixMilli `db:"time"`
+	// Event is the JSON representation of the event, lacking the fields stored in their own columns.
+	Event      string          `db:"event"`
+	EnqueuedAt types.UnixMilli `db:"enqueued_at"`
+	// VisibleAt hides the entry from being claimed until then, i.e., while it is being processed.
+	VisibleAt types.UnixMilli `db:"visible_at"`
+	Attempts  int             `db:"attempts"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (e *Entry) TableName() string {
+	return "event_queue"
+}
+
+// newEntry creates an Entry of the event belonging to the given shard, enqueued at the given time.
+func newEntry(ev *event.Event, shardID int, now time.Time) (*Entry, error) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal event: %w", err)
+	}
+
+	return &Entry{
+		Shard:      shardID,
+		SourceID:   ev.SourceId,
+		Time:       types.UnixMilli(ev.Time),
+		Event:      string(data),
+		EnqueuedAt: types.UnixMilli(now),
+		VisibleAt:  types.UnixMilli(now),
+	}, nil
+}
+
+// event restores the queued event.
+func (e *Entry) event() (*event.Event, error) {
+	var ev event.Event
+	if err := json.Unmarshal([]byte(e.Event), &ev); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal event: %w", err)
+	}
+	ev.Time = e.Time.Time()
+	ev.SourceId = e.SourceID
+
+	return &ev, nil
+}
+
+// Stats describes the state of a Queue, as reported by the health endpoint.
+type Stats struct {
+	Pending int `json:"pending"`
+
+	// The following counters are totals since the daemon was started.
+	EnqueuedTotal  uint64 `json:"enqueued_total"`
+	ProcessedTotal uint64 `json:"processed_total"`
+	FailedTotal    uint64 `json:"failed_total"`
+}
+
+// Queue of events in the database, see New.
+type Queue struct {
+	db      *database.DB
+	conf    daemon.EventQueueConfig
+	pool    *workerpool.Pool
+	process ProcessFunc
+	logger  *logging.Logger
+
+	// wake lets Run process newly enqueued events without waiting for the poll interval.
+	wake chan struct{}
+
+	enqueued  atomic.Uint64
+	processed atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// New creates a Queue, whose events are processed by process within the pool once Run is started.
+func New(
+	db *database.DB, conf daemon.EventQueueConfig, pool *workerpool.Pool, process ProcessFunc, logger *logging.Logger,
+) *Queue {
+	return &Queue{
+		db:      db,
+		conf:    conf,
+		pool:    pool,
+		process: process,
+		logger:  logger,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Enqueue stores the event to be processed by Run.
+//
+// Like incident.ProcessEvent, events of objects belonging to shards not served by this instance are refused with
+// shard.ErrNotOwned. Database failures are wrapped by errs.WrapDB.
+func (q *Queue) Enqueue(ctx context.Context, ev *event.Event) error {
+	ev.EnsureTraceID()
+
+	objectID := object.ID(ev.SourceId, ev.Tags)
+	if !shard.Owns(objectID) {
+		return fmt.Errorf("%w: shard %d", shard.ErrNotOwned, shard.Of(objectID, shard.Count()))
+	}
+
+	entry, err := newEntry(ev, shard.Of(objectID, shard.Count()), time.Now())
+	if err != nil {
+		return err
+	}
+
+	stmt := utils.BuildInsertStmtWithout(q.db, entry, "id")
+	if _, err := q.db.NamedExecContext(ctx, stmt, entry); err != nil {
+		return fmt.Errorf("cannot enqueue event: %w", errs.WrapDB(err))
+	}
+
+	q.enqueued.Add(1)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Stats returns the current state of the Queue, including the number of pending events of all instances.
+func (q *Queue) Stats(ctx context.Context) (Stats, error) {
+	stats := Stats{
+		EnqueuedTotal:  q.enqueued.Load(),
+		ProcessedTotal: q.processed.Load(),
+		FailedTotal:    q.failed.Load(),
+	}
+
+	err := q.db.GetContext(ctx, &stats.Pending, `SELECT COUNT(*) FROM "event_queue"`)
+	return stats, errs.WrapDB(err)
+}
+
+// Run processes the queued events of the shards served by this instance, each object's events in the order they were
+// enqueued, until the context is done.
+//
+// Only the responsible instance may run the Queue, see package ha. Thus, entries claimed by a previous run, e.g., before
+// a crash, are made visible again right away instead of waiting for their visibility timeout.
+func (q *Queue) Run(ctx context.Context) {
+	if err := q.reclaim(ctx); err != nil {
+		q.logger.Errorw("Cannot reclaim events of a previous run", zap.Error(err))
+	}
+
+	ticker := time.NewTicker(q.conf.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := q.consume(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					q.logger.Warnw("Cannot process queued events, retrying later", zap.Error(err))
+				}
+				break
+			}
+			if n < q.conf.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-q.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// consume claims a batch of entries and processes their events, returning the number of claimed entries.
+//
+// The events of distinct objects are processed concurrently by the pool. Processing an object's events stops at the
+// first one failing due to a transient database error or the daemon shutting down, releasing its entry and all of the
+// object's following ones, so that they are retried in order. Events failing for other reasons are logged and
+// discarded, just as if they were processed right away.
+func (q *Queue) consume(ctx context.Context) (int, error) {
+	entries, err := q.claim(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		// stopped holds the error stopping the processing of each object's events.
+		stopped = make(map[string]error)
+		retry   []*Entry
+	)
+	stop := func(entry *Entry, objectID []byte, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if _, ok := stopped[string(objectID)]; !ok {
+			stopped[string(objectID)] = err
+		}
+		retry = append(retry, entry)
+	}
+
+	for _, entry := range entries {
+		ev, err := entry.event()
+		if err != nil {
+			q.finish(ctx, entry, err)
+			continue
+		}
+
+		objectID := object.ID(ev.SourceId, ev.Tags)
+		wg.Add(1)
+		err = q.pool.Submit(ctx, objectID, func() {
+			defer wg.Done()
+
+			mu.Lock()
+			prevErr, ok := stopped[string(objectID)]
+			mu.Unlock()
+			if ok {
+				stop(entry, objectID, prevErr)
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				stop(entry, objectID, err)
+				return
+			}
+
+			err := q.process(latency.NewContext(ctx, latency.NewTrace(entry.EnqueuedAt.Time())), ev)
+			if errs.IsTransientDB(err) || errors.Is(err, errs.ErrShuttingDown) {
+				stop(entry, objectID, err)
+				return
+			}
+
+			q.finish(ctx, entry, err)
+		})
+		if err != nil {
+			wg.Done()
+			stop(entry, objectID, err)
+		}
+	}
+
+	wg.Wait()
+	if len(retry) > 0 {
+		q.release(retry)
+		for _, err := range stopped {
+			return len(entries), err
+		}
+	}
+
+	return len(entries), nil
+}
+
+// finish the entry whose event was processed with the given outcome by deleting it.
+func (q *Queue) finish(ctx context.Context, entry *Entry, err error) {
+	if err != nil && !errors.Is(err, event.ErrSuperfluousStateChange) &&
+		!errors.Is(err, event.ErrSuperfluousMuteUnmuteEvent) {
+		q.failed.Add(1)
+		q.logger.Errorw("Failed to process queued event", zap.Int64("entry", entry.ID),
+			zap.String("event", entry.Event), zap.Error(err))
+	} else {
+		q.processed.Add(1)
+	}
+
+	// The event was processed regardless of the context, thus its entry must be deleted anyway.
+	stmt := q.db.Rebind(`DELETE FROM "event_queue" WHERE "id" = ?`)
+	if _, err := q.db.ExecContext(context.WithoutCancel(ctx), stmt, entry.ID); err != nil {
+		q.logger.Errorw("Cannot delete processed event from queue, it will be processed again",
+			zap.Int64("entry", entry.ID), zap.Error(err))
+	}
+}
+
+// claim the oldest visible entries of the served shards, hiding them for the visibility timeout.
+func (q *Queue) claim(ctx context.Context, now time.Time) ([]*Entry, error) {
+	var entries []*Entry
+	err := utils.RunInTx(ctx, q.db, func(tx *sqlx.Tx) error {
+		entries = nil
+
+		stmt, args, err := sqlx.In(
+			q.db.BuildSelectStmt(&Entry{}, &Entry{})+
+				` WHERE "shard" IN (?) AND "visible_at" <= ? ORDER BY "id" LIMIT ? FOR UPDATE`,
+			servedShards(), now.UnixMilli(), q.conf.BatchSize)
+		if err != nil {
+			return err
+		}
+		if err := tx.SelectContext(ctx, &entries, q.db.Rebind(stmt), args...); err != nil {
+			return fmt.Errorf("cannot select queued events: %w", err)
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		stmt, args, err = sqlx.In(
+			`UPDATE "event_queue" SET "visible_at" = ?, "attempts" = "attempts" + 1 WHERE "id" IN (?)`,
+			now.Add(q.conf.VisibilityTimeout).UnixMilli(), entryIDs(entries))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, q.db.Rebind(stmt), args...); err != nil {
+			return fmt.Errorf("cannot claim queued events: %w", err)
+		}
+
+		return nil
+	})
+
+	return entries, errs.WrapDB(err)
+}
+
+// release makes the given claimed entries visible again, so that they are processed next.
+func (q *Queue) release(entries []*Entry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt, args, err := sqlx.In(`UPDATE "event_queue" SET "visible_at" = 0 WHERE "id" IN (?)`, entryIDs(entries))
+	if err == nil {
+		_, err = q.db.ExecContext(ctx, q.db.Rebind(stmt), args...)
+	}
+	if err != nil {
+		q.logger.Warnw("Cannot release queued events, they are processed after the visibility timeout",
+			zap.Int("events", len(entries)), zap.Error(err))
+	}
+}
+
+// reclaim makes all entries of the served shards visible again.
+func (q *Queue) reclaim(ctx context.Context) error {
+	stmt, args, err := sqlx.In(`UPDATE "event_queue" SET "visible_at" = 0 WHERE "shard" IN (?)`, servedShards())
+	if err != nil {
+		return err
+	}
+
+	_, err = q.db.ExecContext(ctx, q.db.Rebind(stmt), args...)
+	return errs.WrapDB(err)
+}
+
+// servedShards returns the shards served by this instance, see package shard.
+func servedShards() []int {
+	var shards []int
+	for i := 0; i < shard.Count(); i++ {
+		if shard.Mask()&(1<<i) != 0 {
+			shards = append(shards, i)
+		}
+	}
+
+	return shards
+}
+
+// entryIDs returns the IDs of the given entries.
+func entryIDs(entries []*Entry) []int64 {
+	ids := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+
+	return ids
+}
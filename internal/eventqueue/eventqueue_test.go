@@ -0,0 +1,50 @@
+package eventqueue
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestEntry(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ev := &event.Event{
+		Time:       now.Add(-time.Second),
+		SourceId:   2,
+		Name:       "web01!http",
+		Tags:       map[string]string{"host": "web01", "service": "http"},
+		ExtraTags:  map[string]string{"hostgroup/linux": ""},
+		Type:       event.TypeState,
+		Severity:   event.SeverityCrit,
+		Message:    "HTTP CRITICAL",
+		Mute:       types.Bool{Bool: true, Valid: true},
+		MuteReason: "maintenance",
+		TraceID:    "abc",
+	}
+
+	entry, err := newEntry(ev, 3, now)
+	require.NoError(t, err)
+	assert.Equal(t, 3, entry.Shard)
+	assert.Equal(t, int64(2), entry.SourceID)
+	assert.Equal(t, types.UnixMilli(now), entry.EnqueuedAt)
+	assert.Equal(t, types.UnixMilli(now), entry.VisibleAt, "new entries should be visible right away")
+
+	restored, err := entry.event()
+	require.NoError(t, err)
+	assert.Equal(t, ev, restored)
+
+	entry.Event = "{"
+	_, err = entry.event()
+	assert.Error(t, err)
+}
+
+func TestServedShards(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []int{0}, servedShards(), "a single shard should be served by default")
+}
@@ -6,6 +6,13 @@ import (
 	"strings"
 )
 
+// ComparisonOperators lists the operators comparing a column with a value, e.g., "host=db-*". A column without an
+// operator checks for its existence.
+var ComparisonOperators = []string{"=", "!=", "<", "<=", ">", ">="}
+
+// LogicalOperators lists the operators combining conditions, in addition to grouping them with parentheses.
+var LogicalOperators = []string{"&", "|", "!"}
+
 type Parser struct {
 	tag                          string
 	pos, length, openParenthesis int
@@ -185,11 +192,20 @@ func (p *Parser) readFilter(nestingLevel int, operator string, rules []Filter) (
 	}
 
 	if nestingLevel == 0 && p.openParenthesis > 0 {
-		return nil, fmt.Errorf("invalid filter '%s', missing %d closing ')' at pos %d", p.tag, p.openParenthesis, p.pos)
+		return nil, &ParseError{
+			Expression: p.tag,
+			Pos:        p.pos,
+			Message:    fmt.Sprintf("missing %d closing ')' at pos %d", p.openParenthesis, p.pos),
+		}
 	}
 
 	if nestingLevel == 0 && p.openParenthesis < 0 {
-		return nil, fmt.Errorf("invalid filter '%s', unexpected closing ')' at pos %d", p.tag, p.pos)
+		return nil, &ParseError{
+			Expression: p.tag,
+			Pos:        p.pos,
+			Unexpected: ")",
+			Message:    fmt.Sprintf("unexpected closing ')' at pos %d", p.pos),
+		}
 	}
 
 	var chain Filter
@@ -353,5 +369,30 @@ func (p *Parser) parseError(invalidChar string, msg string) error {
 		msg = ": " + msg
 	}
 
-	return fmt.Errorf("invalid filter '%s', unexpected %s at pos %d%s", p.tag, invalidChar, p.pos, msg)
+	return &ParseError{
+		Expression: p.tag,
+		Pos:        p.pos,
+		Unexpected: invalidChar,
+		Message:    fmt.Sprintf("unexpected %s at pos %d%s", invalidChar, p.pos, msg),
+	}
+}
+
+// ParseError is returned by Parse for syntactically invalid filter expressions.
+type ParseError struct {
+	Expression string
+
+	// Pos is the number of bytes of the Expression read until the error was detected, i.e., the Unexpected character
+	// is usually found right before this position.
+	Pos int
+
+	// Unexpected is the character causing the error, if any, e.g., a ")" without a matching "(".
+	Unexpected string
+
+	// Message describes the error without repeating the Expression.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid filter '%s', %s", e.Expression, e.Message)
 }
@@ -2,6 +2,7 @@ package filter
 
 import (
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"strings"
 	"testing"
 )
@@ -212,3 +213,33 @@ func FuzzParser(f *testing.F) {
 		}
 	})
 }
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+
+	_, err := Parse("host=a&(service=b")
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, &ParseError{
+		Expression: "host=a&(service=b",
+		Pos:        17,
+		Message:    "missing 1 closing ')' at pos 17",
+	}, parseErr)
+
+	_, err = Parse("host=a)")
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 7, parseErr.Pos)
+	assert.Equal(t, ")", parseErr.Unexpected)
+}
+
+func TestColumns(t *testing.T) {
+	t.Parallel()
+
+	f, err := Parse("host=a&(service=b|!host|env)&!(zone=c*)")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"host", "service", "env", "zone"}, Columns(f))
+
+	f, err = Parse("")
+	require.NoError(t, err)
+	assert.Empty(t, Columns(f))
+}
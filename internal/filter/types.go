@@ -183,6 +183,11 @@ func (c *Condition) Column() string {
 	return c.column
 }
 
+// Operator returns the comparison operator of this Condition.
+func (c *Condition) Operator() CompOperator {
+	return c.op
+}
+
 // Value returns the value of this Condition.
 func (c *Condition) Value() string {
 	return c.value
@@ -204,6 +209,37 @@ func (e *Exists) Eval(filterable Filterable) (bool, error) {
 	return filterable.EvalExists(e.column), nil
 }
 
+// Columns returns the columns referenced by the filter, including those only checked for existence, in order of their
+// first appearance.
+func Columns(f Filter) []string {
+	var columns []string
+	seen := make(map[string]bool)
+
+	var walk func(Filter)
+	walk = func(f Filter) {
+		var column string
+		switch f := f.(type) {
+		case *Chain:
+			for _, rule := range f.rules {
+				walk(rule)
+			}
+			return
+		case *Condition:
+			column = f.column
+		case *Exists:
+			column = f.column
+		}
+
+		if column != "" && !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+	walk(f)
+
+	return columns
+}
+
 var (
 	_ Filter = (*Chain)(nil)
 	_ Filter = (*Exists)(nil)
@@ -0,0 +1,166 @@
+// Package filtercheck validates filter expressions as entered by rule editors, reporting errors in a structured form
+// together with suggestions, instead of only failing once the configuration is loaded.
+package filtercheck
+
+import (
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/filter"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"slices"
+	"time"
+)
+
+const (
+	// RecentEventsWindow is how far back events are considered for the known tag keys of object filters.
+	RecentEventsWindow = 7 * 24 * time.Hour
+
+	// MaxKnownKeys limits the number of known tag keys suggested for object filters.
+	MaxKnownKeys = 1000
+)
+
+// Kind of filter expression, determining the keys it may refer to.
+type Kind string
+
+const (
+	// KindObject is an object filter of a rule or maintenance window, referring to object tags.
+	KindObject Kind = "object"
+
+	// KindEscalation is the condition of a rule escalation, referring to rule.EscalationFilterKeys and object tags.
+	KindEscalation Kind = "escalation"
+)
+
+// Error describes why an expression is invalid.
+type Error struct {
+	Message string `json:"message"`
+
+	// Position is the number of bytes read until a syntax error was detected, see filter.ParseError. It is nil for
+	// other errors, e.g., an unknown escalation condition key.
+	Position *int `json:"position"`
+
+	// Unexpected is the character causing a syntax error, if any.
+	Unexpected string `json:"unexpected,omitempty"`
+}
+
+// Warning points out a possible mistake in a valid expression, e.g., a tag not seen on any recent event.
+type Warning struct {
+	Message string `json:"message"`
+	Key     string `json:"key"`
+
+	// Suggestion is a similar known key, if any, e.g., "hostname" for "hostnme".
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// Suggestions help to write expressions of the checked kind.
+type Suggestions struct {
+	ComparisonOperators []string `json:"comparison_operators"`
+	LogicalOperators    []string `json:"logical_operators"`
+
+	// Keys are the known keys, i.e., the tag keys seen on recent events, preceded by rule.EscalationFilterKeys for
+	// escalation conditions.
+	Keys []string `json:"keys"`
+}
+
+// Result of Check.
+type Result struct {
+	Valid       bool         `json:"valid"`
+	Error       *Error       `json:"error"`
+	Warnings    []*Warning   `json:"warnings"`
+	Suggestions *Suggestions `json:"suggestions"`
+}
+
+// Check validates the expression of the given kind.
+//
+// knownKeys are the tag keys seen on recent events, see tagcatalog.Catalog.Keys. Referring other tags only results in a
+// warning, as a filter may target objects not having sent an event yet. Escalation conditions may additionally refer to
+// rule.EscalationFilterKeys, while other keys reserved for the incident are errors, see rule.ValidateCondition.
+func Check(kind Kind, expression string, knownKeys []string) (*Result, error) {
+	switch kind {
+	case KindObject:
+	case KindEscalation:
+		knownKeys = append(slices.Clip(rule.EscalationFilterKeys), knownKeys...)
+	default:
+		return nil, fmt.Errorf("unknown filter kind %q", kind)
+	}
+
+	result := &Result{
+		Valid:    true,
+		Warnings: []*Warning{},
+		Suggestions: &Suggestions{
+			ComparisonOperators: filter.ComparisonOperators,
+			LogicalOperators:    filter.LogicalOperators,
+			Keys:                knownKeys,
+		},
+	}
+
+	f, err := filter.Parse(expression)
+	if err != nil {
+		result.Valid = false
+		result.Error = &Error{Message: err.Error()}
+
+		var parseErr *filter.ParseError
+		if errors.As(err, &parseErr) {
+			result.Error.Message = parseErr.Message
+			result.Error.Position = &parseErr.Pos
+			result.Error.Unexpected = parseErr.Unexpected
+		}
+
+		return result, nil
+	}
+
+	if kind == KindEscalation {
+		if err := rule.ValidateCondition(f); err != nil {
+			result.Valid = false
+			result.Error = &Error{Message: err.Error()}
+		}
+	}
+
+	for _, key := range filter.Columns(f) {
+		if !slices.Contains(knownKeys, key) {
+			message := fmt.Sprintf("tag %q was not seen on any recent event", key)
+			if kind == KindEscalation && rule.IsReservedKey(key) {
+				message = fmt.Sprintf("unknown escalation condition key %q", key)
+			}
+
+			result.Warnings = append(result.Warnings, &Warning{Message: message, Key: key, Suggestion: closest(key, knownKeys)})
+		}
+	}
+
+	return result, nil
+}
+
+// closest returns the candidate most similar to key, or an empty string if none is similar enough to be a typo.
+func closest(key string, candidates []string) string {
+	best, bestDistance := "", max(2, len(key)/3)+1
+	for _, candidate := range candidates {
+		if d := distance(key, candidate); d < bestDistance || d == bestDistance && best != "" && candidate < best {
+			best, bestDistance = candidate, d
+		}
+	}
+
+	return best
+}
+
+// distance returns the Levenshtein distance of the two strings, counting inserted, deleted and substituted runes.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,84 @@
+package filtercheck
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	knownKeys := []string{"host", "hostgroup/linux", "service"}
+
+	t.Run("Valid", func(t *testing.T) {
+		t.Parallel()
+
+		result, err := Check(KindObject, "host=db-*&service=postgres", knownKeys)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Nil(t, result.Error)
+		assert.Empty(t, result.Warnings)
+		assert.Equal(t, knownKeys, result.Suggestions.Keys)
+		assert.Contains(t, result.Suggestions.ComparisonOperators, "!=")
+	})
+
+	t.Run("SyntaxError", func(t *testing.T) {
+		t.Parallel()
+
+		result, err := Check(KindObject, "host=a)", knownKeys)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.Error)
+		require.NotNil(t, result.Error.Position)
+		assert.Equal(t, 7, *result.Error.Position)
+		assert.Equal(t, ")", result.Error.Unexpected)
+		assert.Equal(t, "unexpected ) at pos 7", result.Error.Message)
+	})
+
+	t.Run("UnknownTag", func(t *testing.T) {
+		t.Parallel()
+
+		result, err := Check(KindObject, "hots=db-01|zone", knownKeys)
+		require.NoError(t, err)
+		assert.True(t, result.Valid, "unknown tags should only result in warnings")
+		assert.Equal(t, []*Warning{
+			{Message: `tag "hots" was not seen on any recent event`, Key: "hots", Suggestion: "host"},
+			{Message: `tag "zone" was not seen on any recent event`, Key: "zone"},
+		}, result.Warnings)
+	})
+
+	t.Run("Escalation", func(t *testing.T) {
+		t.Parallel()
+
+		result, err := Check(KindEscalation, "incident_age>=5m", nil)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Contains(t, result.Suggestions.Keys, "incident_severity")
+
+		result, err = Check(KindEscalation, "incident_severty>=crit", nil)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.Error)
+		assert.Nil(t, result.Error.Position)
+		require.Len(t, result.Warnings, 1)
+		assert.Equal(t, "incident_severity", result.Warnings[0].Suggestion)
+
+		result, err = Check(KindEscalation, "incident_age>=5", nil)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Empty(t, result.Warnings)
+
+		result, err = Check(KindEscalation, "incident_age>=5m&hostgroup/linux&servce=mysql", knownKeys)
+		require.NoError(t, err)
+		assert.True(t, result.Valid, "object tags should be allowed")
+		assert.Contains(t, result.Suggestions.Keys, "incident_age")
+		assert.Contains(t, result.Suggestions.Keys, "hostgroup/linux")
+		assert.Equal(t, []*Warning{
+			{Message: `tag "servce" was not seen on any recent event`, Key: "servce", Suggestion: "service"},
+		}, result.Warnings)
+	})
+
+	_, err := Check("rule", "host", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,242 @@
+// Package ha lets multiple daemon instances share a database in an active/passive setup, similar to Icinga DB.
+//
+// Each instance regularly writes a heartbeat to the daemon_instance table. Only a single instance is responsible, i.e.,
+// processes events and sends notifications, while the others are passive. Once the heartbeat of the responsible
+// instance is older than the timeout, e.g., as it crashed or lost its database connection, a passive instance takes
+// over, loading the open incidents and thus resuming their escalations.
+//
+// With sharding, instances serving disjoint shards are responsible at the same time, see package shard. Thus, each set
+// of shards can be served by an active/passive pair of instances.
+package ha
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/shard"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Instance is a daemon instance as stored in the daemon_instance table.
+type Instance struct {
+	ID          string          `db:"id"`
+	Hostname    string          `db:"hostname"`
+	Responsible types.Bool      `db:"responsible"`
+	Heartbeat   types.UnixMilli `db:"heartbeat"`
+	ShardCount  int             `db:"shard_count"`
+	// Shards is a bitmask of the shards served by the instance.
+	Shards int64 `db:"shards"`
+}
+
+// conflicts returns whether both instances cannot be responsible at the same time, as they serve an overlapping set of
+// shards. Instances configured with different numbers of shards always conflict.
+func (i *Instance) conflicts(other *Instance) bool {
+	return i.ShardCount != other.ShardCount || i.Shards&other.Shards != 0
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (i *Instance) TableName() string {
+	return "daemon_instance"
+}
+
+// HA elects the responsible instance among all daemon instances sharing the database.
+type HA struct {
+	db       *database.DB
+	logger   *logging.Logger
+	instance *Instance
+	interval time.Duration
+	timeout  time.Duration
+
+	active   atomic.Bool
+	takeover chan struct{}
+}
+
+// New registers this daemon instance and runs the first election, so that Active is already determined on return.
+func New(ctx context.Context, db *database.DB, logger *logging.Logger, conf daemon.HAConfig) (*HA, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, errors.Wrap(err, "cannot generate instance ID")
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get hostname")
+	}
+
+	h := &HA{
+		db:     db,
+		logger: logger,
+		instance: &Instance{
+			ID:         hex.EncodeToString(id),
+			Hostname:   hostname,
+			Heartbeat:  types.UnixMilli(time.Now()),
+			ShardCount: shard.Count(),
+			Shards:     int64(shard.Mask()),
+		},
+		interval: conf.HeartbeatInterval,
+		timeout:  conf.Timeout,
+		takeover: make(chan struct{}),
+	}
+
+	// The instance is registered before the first election, as each election locks all registered instances. This
+	// serializes the elections of concurrently starting instances, which could otherwise both become responsible.
+	stmt, _ := db.BuildUpsertStmt(h.instance)
+	if _, err := db.NamedExecContext(ctx, stmt, h.instance); err != nil {
+		return nil, errors.Wrap(err, "cannot register instance")
+	}
+
+	logger.Infow("Registered instance", zap.String("instance_id", h.instance.ID), zap.String("hostname", hostname))
+
+	responsible, err := h.heartbeat(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	h.setResponsible(responsible)
+
+	return h, nil
+}
+
+// Active returns whether this instance is the responsible one.
+func (h *HA) Active() bool {
+	return h.active.Load()
+}
+
+// Takeover returns a channel being closed once this instance became the responsible one.
+func (h *HA) Takeover() <-chan struct{} {
+	return h.takeover
+}
+
+// Run writes heartbeats and elects the responsible instance each interval until the context is done, unregistering
+// this instance afterwards to allow an immediate takeover.
+//
+// An error is returned if this instance lost its responsibility, either as another instance took over or as its own
+// heartbeats failed for longer than the timeout. In both cases, another instance may already process events, so this
+// one must stop immediately.
+func (h *HA) Run(ctx context.Context) error {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	lastHeartbeat := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			h.unregister()
+			return nil
+		}
+
+		now := time.Now()
+		responsible, err := h.heartbeat(ctx, now)
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+
+			h.logger.Errorw("Cannot update heartbeat", zap.Error(err))
+			if h.Active() && now.Sub(lastHeartbeat) >= h.timeout {
+				return fmt.Errorf("lost responsibility as heartbeats failed for %s", now.Sub(lastHeartbeat))
+			}
+
+			continue
+		}
+
+		lastHeartbeat = now
+		if h.Active() && !responsible {
+			return errors.New("lost responsibility as another instance took over")
+		}
+		h.setResponsible(responsible)
+	}
+}
+
+// setResponsible marks this instance as the responsible one, unless it is already or not responsible at all.
+func (h *HA) setResponsible(responsible bool) {
+	if !responsible {
+		if !h.Active() {
+			h.logger.Debug("Another instance is responsible")
+		}
+		return
+	}
+
+	if !h.active.Swap(true) {
+		h.logger.Infow("Taking over as the responsible instance", zap.String("instance_id", h.instance.ID))
+		close(h.takeover)
+	}
+}
+
+// heartbeat writes this instance's heartbeat and elects the responsible instance, returning whether it is this one.
+func (h *HA) heartbeat(ctx context.Context, now time.Time) (bool, error) {
+	var responsible bool
+	err := utils.RunInTx(ctx, h.db, func(tx *sqlx.Tx) error {
+		var instances []*Instance
+		// Locking all instances in the same order prevents deadlocks between concurrent elections.
+		stmt := h.db.BuildSelectStmt(h.instance, h.instance) + ` ORDER BY "id" FOR UPDATE`
+		if err := tx.SelectContext(ctx, &instances, stmt); err != nil {
+			return errors.Wrap(err, "cannot lock instances")
+		}
+
+		var revoke []string
+		responsible, revoke = elect(instances, h.instance, now, h.timeout)
+		if len(revoke) > 0 {
+			stmt, args, err := sqlx.In(`UPDATE "daemon_instance" SET "responsible" = 'n' WHERE "id" IN (?)`, revoke)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, h.db.Rebind(stmt), args...); err != nil {
+				return errors.Wrap(err, "cannot revoke responsibility of timed out instances")
+			}
+		}
+
+		instance := *h.instance
+		instance.Responsible = types.Bool{Bool: responsible, Valid: true}
+		instance.Heartbeat = types.UnixMilli(now)
+		stmt, _ = h.db.BuildUpsertStmt(&instance)
+		if _, err := tx.NamedExecContext(ctx, stmt, &instance); err != nil {
+			return errors.Wrap(err, "cannot update heartbeat")
+		}
+
+		return nil
+	})
+
+	return responsible, err
+}
+
+// unregister deletes this instance, so that a passive instance takes over without waiting for the timeout.
+func (h *HA) unregister() {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := h.db.ExecContext(ctx, h.db.Rebind(`DELETE FROM "daemon_instance" WHERE "id" = ?`), h.instance.ID)
+	if err != nil {
+		h.logger.Warnw("Cannot unregister instance", zap.Error(err))
+	}
+}
+
+// elect returns whether the given instance is responsible, as no other conflicting instance is responsible with a
+// heartbeat within the timeout before now. If so, the IDs of the conflicting instances which timed out are returned
+// as well, to revoke their responsibility.
+func elect(instances []*Instance, self *Instance, now time.Time, timeout time.Duration) (bool, []string) {
+	var revoke []string
+	for _, instance := range instances {
+		if instance.ID == self.ID || !instance.Responsible.Bool || !self.conflicts(instance) {
+			continue
+		}
+
+		if now.Sub(instance.Heartbeat.Time()) < timeout {
+			return false, nil
+		}
+		revoke = append(revoke, instance.ID)
+	}
+
+	return true, revoke
+}
@@ -0,0 +1,69 @@
+package ha
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestElect(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	instance := func(id string, responsible bool, heartbeat time.Time) *Instance {
+		return &Instance{
+			ID:          id,
+			Responsible: types.Bool{Bool: responsible, Valid: true},
+			Heartbeat:   types.UnixMilli(heartbeat),
+			ShardCount:  1,
+			Shards:      1,
+		}
+	}
+	self := instance("a", false, now)
+
+	elected := func(instances ...*Instance) bool {
+		responsible, _ := elect(append(instances, self), self, now, time.Minute)
+		return responsible
+	}
+
+	assert.True(t, elected(), "single instance")
+	assert.True(t, elected(instance("a", true, now)), "already responsible")
+	assert.False(t, elected(instance("b", true, now.Add(-30*time.Second))), "other instance is responsible")
+	assert.True(t, elected(instance("b", true, now.Add(-time.Minute))), "responsible instance timed out")
+	assert.True(t, elected(instance("b", false, now)), "no instance is responsible")
+
+	responsible, revoke := elect([]*Instance{
+		self,
+		instance("b", true, now.Add(-time.Hour)),
+		instance("c", false, now.Add(-time.Hour)),
+	}, self, now, time.Minute)
+	assert.True(t, responsible)
+	assert.Equal(t, []string{"b"}, revoke, "responsibility of the timed out instance should be revoked")
+}
+
+func TestElect_Sharding(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	instance := func(id string, shardCount int, shards int64) *Instance {
+		return &Instance{
+			ID:          id,
+			Responsible: types.Bool{Bool: true, Valid: true},
+			Heartbeat:   types.UnixMilli(now),
+			ShardCount:  shardCount,
+			Shards:      shards,
+		}
+	}
+	self := instance("a", 4, 0b0011)
+	self.Responsible.Bool = false
+
+	elected := func(other *Instance) bool {
+		responsible, _ := elect([]*Instance{self, other}, self, now, time.Minute)
+		return responsible
+	}
+
+	assert.True(t, elected(instance("b", 4, 0b1100)), "disjoint shards")
+	assert.False(t, elected(instance("b", 4, 0b0110)), "overlapping shards")
+	assert.False(t, elected(instance("b", 2, 0b0100)), "different shard count")
+}
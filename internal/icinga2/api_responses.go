@@ -150,18 +150,22 @@ func (d *Downtime) WasCancelled() bool {
 // https://icinga.com/docs/icinga-2/latest/doc/09-object-types/#host
 // https://icinga.com/docs/icinga-2/latest/doc/09-object-types/#service
 type HostServiceRuntimeAttributes struct {
-	Name                      string      `json:"name"`
-	Host                      string      `json:"host_name,omitempty"`
-	Groups                    []string    `json:"groups"`
-	State                     int         `json:"state"`
-	StateType                 int         `json:"state_type"`
-	LastCheckResult           CheckResult `json:"last_check_result"`
-	LastStateChange           UnixFloat   `json:"last_state_change"`
-	DowntimeDepth             int         `json:"downtime_depth"`
-	Acknowledgement           int         `json:"acknowledgement"`
-	IsFlapping                bool        `json:"flapping"`
-	AcknowledgementLastChange UnixFloat   `json:"acknowledgement_last_change"`
-	EnableFlapping            bool        `json:"enable_flapping"`
+	Name                      string         `json:"name"`
+	Host                      string         `json:"host_name,omitempty"`
+	Groups                    []string       `json:"groups"`
+	State                     int            `json:"state"`
+	StateType                 int            `json:"state_type"`
+	LastCheckResult           CheckResult    `json:"last_check_result"`
+	LastStateChange           UnixFloat      `json:"last_state_change"`
+	DowntimeDepth             int            `json:"downtime_depth"`
+	Acknowledgement           int            `json:"acknowledgement"`
+	IsFlapping                bool           `json:"flapping"`
+	AcknowledgementLastChange UnixFloat      `json:"acknowledgement_last_change"`
+	EnableFlapping            bool           `json:"enable_flapping"`
+	Notes                     string         `json:"notes"`
+	NotesUrl                  string         `json:"notes_url"`
+	ActionUrl                 string         `json:"action_url"`
+	Vars                      map[string]any `json:"vars"`
 }
 
 // MarshalLogObject implements the zapcore.ObjectMarshaler interface.
@@ -214,6 +214,13 @@ func TestObjectQueriesResult_UnmarshalJSON(t *testing.T) {
 					DowntimeDepth:             0,
 					Acknowledgement:           AcknowledgementNone,
 					AcknowledgementLastChange: UnixFloat(time.UnixMilli(0)),
+					Vars: map[string]any{
+						"app":        "network",
+						"department": "dev",
+						"env":        "qa",
+						"is_dummy":   true,
+						"location":   "rome",
+					},
 				},
 			},
 		},
@@ -0,0 +1,108 @@
+package icinga2
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"time"
+)
+
+// catchupDuplicates suppresses events generated during the catch-up-phase which are identical to an event generated
+// for the same object during a previous catch-up-phase within the window.
+//
+// Each catch-up-phase, e.g., after every Icinga 2 reload, generates mute or unmute, state and acknowledgement events for
+// all objects, most of them not changing anything. While such events are mostly ignored later on, some are still
+// inserted into the event table, e.g., each state event of an object with an open incident. As events are only compared
+// to the first identical one passed on, such an event is still passed on at least once per window.
+//
+// Events of the Event Stream API are not suppressed, but make the next catch-up event of their object pass, as it may
+// revert the object's state to the one of an earlier catch-up-phase.
+type catchupDuplicates struct {
+	window time.Duration
+	// phase counts the catch-up-phases, as the events of a single phase must not suppress each other.
+	phase uint64
+	// seen maps object names to the last event of each type passed on for them.
+	seen map[string]map[string]catchupEvent
+}
+
+// catchupEvent is an event passed on during a catch-up-phase, identified by the content hash of its relevant fields.
+type catchupEvent struct {
+	hash  [sha256.Size]byte
+	phase uint64
+	time  time.Time
+}
+
+// newCatchupDuplicates creates catchupDuplicates with the given window, disabling the suppression if it is zero.
+func newCatchupDuplicates(window time.Duration) *catchupDuplicates {
+	return &catchupDuplicates{window: window, seen: make(map[string]map[string]catchupEvent)}
+}
+
+// startPhase must be called when a catch-up-phase starts, also dropping the events which cannot suppress others anymore.
+func (d *catchupDuplicates) startPhase(now time.Time) {
+	d.phase++
+
+	for name, types := range d.seen {
+		for typ, seen := range types {
+			if now.Sub(seen.time) >= d.window {
+				delete(types, typ)
+			}
+		}
+		if len(types) == 0 {
+			delete(d.seen, name)
+		}
+	}
+}
+
+// suppress reports whether the catch-up event is a duplicate to be dropped, remembering it otherwise.
+func (d *catchupDuplicates) suppress(ev *event.Event, now time.Time) bool {
+	if d.window <= 0 {
+		return false
+	}
+
+	sum := contentHash(ev)
+	seen, ok := d.seen[ev.Name][ev.Type]
+	if ok && seen.hash == sum && seen.phase < d.phase && now.Sub(seen.time) < d.window {
+		return true
+	}
+
+	if d.seen[ev.Name] == nil {
+		d.seen[ev.Name] = make(map[string]catchupEvent)
+	}
+	d.seen[ev.Name][ev.Type] = catchupEvent{hash: sum, phase: d.phase, time: now}
+
+	return false
+}
+
+// forget the catch-up events of the object of the given Event Stream event, see catchupDuplicates.
+func (d *catchupDuplicates) forget(ev *event.Event) {
+	delete(d.seen, ev.Name)
+}
+
+// contentHash returns the SHA-256 hash of all fields of the event describing the object and its state, i.e., all but
+// its time and IDs.
+func contentHash(ev *event.Event) [sha256.Size]byte {
+	h := sha256.New()
+	// Each field is quoted to tell apart, e.g., the tags {"a": "b c"} and {"a b": "c"}.
+	writeField := func(value any) {
+		_, _ = fmt.Fprintf(h, "%q ", fmt.Sprint(value))
+	}
+
+	for _, value := range []any{ev.SourceId, ev.Name, ev.URL, ev.Type, ev.Severity, ev.Username, ev.Message, ev.Mute,
+		ev.MuteReason} {
+		writeField(value)
+	}
+	for _, tags := range []map[string]string{ev.Tags, ev.ExtraTags} {
+		writeField(len(tags))
+		utils.IterateOrderedMap(tags)(func(k, v string) bool {
+			writeField(k)
+			writeField(v)
+			return true
+		})
+	}
+
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+
+	return sum
+}
@@ -0,0 +1,87 @@
+package icinga2
+
+import (
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCatchupDuplicates(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	newEvent := func(typ string, severity event.Severity) *event.Event {
+		return &event.Event{
+			Time:     now,
+			SourceId: 1,
+			Name:     "web01!http",
+			Tags:     map[string]string{"host": "web01", "service": "http"},
+			Type:     typ,
+			Severity: severity,
+			Message:  "HTTP OK",
+		}
+	}
+
+	t.Run("SuppressesIdenticalEventsOfLaterPhases", func(t *testing.T) {
+		d := newCatchupDuplicates(15 * time.Minute)
+
+		d.startPhase(now)
+		assert.False(t, d.suppress(newEvent(event.TypeUnmute, event.SeverityNone), now))
+		assert.False(t, d.suppress(newEvent(event.TypeState, event.SeverityOK), now))
+
+		d.startPhase(now.Add(time.Minute))
+		assert.True(t, d.suppress(newEvent(event.TypeUnmute, event.SeverityNone), now.Add(time.Minute)))
+		assert.True(t, d.suppress(newEvent(event.TypeState, event.SeverityOK), now.Add(time.Minute)))
+
+		changed := newEvent(event.TypeState, event.SeverityCrit)
+		assert.False(t, d.suppress(changed, now.Add(time.Minute)), "changed severity should pass")
+
+		retagged := newEvent(event.TypeUnmute, event.SeverityNone)
+		retagged.ExtraTags = map[string]string{"hostgroup/linux": ""}
+		assert.False(t, d.suppress(retagged, now.Add(time.Minute)), "changed extra tags should pass")
+	})
+
+	t.Run("PassesEventsOfTheSamePhase", func(t *testing.T) {
+		d := newCatchupDuplicates(15 * time.Minute)
+
+		d.startPhase(now)
+		ack := newEvent(event.TypeAcknowledgementSet, event.SeverityNone)
+		assert.False(t, d.suppress(ack, now))
+		assert.False(t, d.suppress(ack, now), "retried acknowledgement of the same phase should pass")
+	})
+
+	t.Run("PassesOncePerWindow", func(t *testing.T) {
+		d := newCatchupDuplicates(15 * time.Minute)
+
+		for i, want := range []bool{false, true, true, false, true} {
+			at := now.Add(time.Duration(i) * 7 * time.Minute)
+			d.startPhase(at)
+			assert.Equal(t, want, d.suppress(newEvent(event.TypeState, event.SeverityOK), at), "after %v", at.Sub(now))
+		}
+
+		assert.Len(t, d.seen, 1)
+		d.startPhase(now.Add(time.Hour))
+		assert.Empty(t, d.seen, "expired events should be dropped")
+	})
+
+	t.Run("EventStreamResetsObject", func(t *testing.T) {
+		d := newCatchupDuplicates(15 * time.Minute)
+
+		d.startPhase(now)
+		assert.False(t, d.suppress(newEvent(event.TypeState, event.SeverityOK), now))
+
+		// The object became critical and recovered again in between.
+		d.forget(newEvent(event.TypeState, event.SeverityCrit))
+
+		d.startPhase(now.Add(time.Minute))
+		assert.False(t, d.suppress(newEvent(event.TypeState, event.SeverityOK), now.Add(time.Minute)))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		d := newCatchupDuplicates(0)
+
+		d.startPhase(now)
+		assert.False(t, d.suppress(newEvent(event.TypeState, event.SeverityOK), now))
+		d.startPhase(now)
+		assert.False(t, d.suppress(newEvent(event.TypeState, event.SeverityOK), now))
+	})
+}
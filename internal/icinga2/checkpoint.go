@@ -0,0 +1,166 @@
+package icinga2
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/types"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkpointMargin is subtracted from a checkpoint before resuming the catch-up-phase from it, covering a clock skew
+// between Icinga 2 and Icinga Notifications. Events still being processed are already covered, see Checkpoints.Begin.
+const checkpointMargin = 5 * time.Minute
+
+// checkpointInterval is the minimum interval between saving two checkpoints while receiving Event Stream events.
+const checkpointInterval = time.Minute
+
+// Checkpoints persists the time up to which an Icinga 2 source has caught up on all changes, allowing its
+// catch-up-phase to only query the objects changed since then instead of all hosts and services, e.g., after a restart.
+//
+// As each instance only processes the events of the objects of its own shards, see package shard, each combination of
+// shards has its own checkpoint. Otherwise, an instance would resume from a checkpoint advanced by another instance
+// meanwhile, missing the changes of its own objects in between.
+type Checkpoints struct {
+	Db         *database.DB
+	SourceID   int64
+	ShardCount int
+	ShardMask  uint64
+
+	mu sync.Mutex
+	// pending holds the times the events still being processed were received at, see Begin.
+	pending map[uint64]time.Time
+	nextID  uint64
+}
+
+// checkpointRow is a row of the source_checkpoint table.
+type checkpointRow struct {
+	SourceID   int64           `db:"source_id"`
+	ShardCount int             `db:"shard_count"`
+	ShardMask  int64           `db:"shard_mask"`
+	Checkpoint types.UnixMilli `db:"checkpoint"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (r *checkpointRow) TableName() string {
+	return "source_checkpoint"
+}
+
+// Begin marks an event received now as being processed until the returned function is called. Until then, no checkpoint
+// later than the time it was received at is saved, as the event might still be lost, e.g., on shutdown.
+//
+// It can be called on a nil Checkpoints, doing nothing.
+func (c *Checkpoints) Begin() (done func()) {
+	if c == nil {
+		return func() {}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending == nil {
+		c.pending = make(map[uint64]time.Time)
+	}
+	id := c.nextID
+	c.nextID++
+	c.pending[id] = time.Now()
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		delete(c.pending, id)
+	}
+}
+
+// processedUntil returns the time up to which all events are processed, i.e., the given time unless an event received
+// earlier is still being processed, see Begin.
+func (c *Checkpoints) processedUntil(checkpoint time.Time) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, received := range c.pending {
+		if received.Before(checkpoint) {
+			checkpoint = received
+		}
+	}
+
+	return checkpoint
+}
+
+// Load returns the checkpoint of the source and shards, or the zero time if there is none yet.
+func (c *Checkpoints) Load(ctx context.Context) (time.Time, error) {
+	var row checkpointRow
+	stmt := c.Db.Rebind(`SELECT "source_id", "shard_count", "shard_mask", "checkpoint" FROM "source_checkpoint" ` +
+		`WHERE "source_id" = ? AND "shard_count" = ? AND "shard_mask" = ?`)
+	err := c.Db.GetContext(ctx, &row, stmt, c.SourceID, c.ShardCount, int64(c.ShardMask))
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, fmt.Errorf("cannot load catch-up checkpoint: %w", err)
+	}
+
+	return row.Checkpoint.Time(), nil
+}
+
+// Save stores the given time as the checkpoint of the source and shards, unless events received earlier are still being
+// processed. Then, the time the oldest of them was received at is stored instead, see Begin.
+func (c *Checkpoints) Save(ctx context.Context, checkpoint time.Time) error {
+	row := &checkpointRow{
+		SourceID:   c.SourceID,
+		ShardCount: c.ShardCount,
+		ShardMask:  int64(c.ShardMask),
+		Checkpoint: types.UnixMilli(c.processedUntil(checkpoint)),
+	}
+	stmt, _ := c.Db.BuildUpsertStmt(row)
+	if _, err := c.Db.NamedExecContext(ctx, stmt, row); err != nil {
+		return fmt.Errorf("cannot save catch-up checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// MutedObjects returns the names of the source's objects currently muted in Icinga Notifications, e.g., "host!service".
+//
+// As Icinga 2 keeps no timestamp of a downtime's end, these objects must always be caught up on to notice that they
+// were unmuted in the meantime.
+func (c *Checkpoints) MutedObjects(ctx context.Context) ([]string, error) {
+	var names []string
+	stmt := c.Db.Rebind(`SELECT "name" FROM "object" WHERE "source_id" = ? AND "mute_reason" IS NOT NULL`)
+	if err := c.Db.SelectContext(ctx, &names, stmt, c.SourceID); err != nil {
+		return nil, fmt.Errorf("cannot load muted objects: %w", err)
+	}
+
+	return names, nil
+}
+
+// checkpointQuery returns the query of the Icinga 2 objects API for objects of the given type, "host" or "service",
+// which might have changed since the checkpoint, given the names of all objects muted in Icinga Notifications.
+//
+// Besides the objects whose hard state, acknowledgement or flapping changed since then, all objects muted in either
+// Icinga 2 or Icinga Notifications are queried, as the start and end of downtimes are not recorded on the objects.
+func checkpointQuery(objType string, checkpoint time.Time, muted []string) map[string]any {
+	since := float64(checkpoint.Add(-checkpointMargin).UnixMilli()) / 1000
+
+	names := make([]string, 0, len(muted))
+	for _, name := range muted {
+		if strings.Contains(name, "!") == (objType == "service") {
+			names = append(names, name)
+		}
+	}
+
+	filter := fmt.Sprintf(
+		"%[1]s.last_hard_state_change >= checkpoint || %[1]s.acknowledgement_last_change >= checkpoint || "+
+			"%[1]s.flapping_last_change >= checkpoint || %[1]s.downtime_depth > 0 || %[1]s.acknowledgement != 0 || "+
+			"%[1]s.is_flapping || %[1]s.__name in muted",
+		objType)
+
+	return map[string]any{
+		"filter":      filter,
+		"filter_vars": map[string]any{"checkpoint": since, "muted": names},
+	}
+}
@@ -0,0 +1,81 @@
+package icinga2
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-notifications/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCheckpointQuery(t *testing.T) {
+	t.Parallel()
+
+	checkpoint := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	muted := []string{"db-01", "db-01!disk", "web-01!http"}
+
+	hostQuery := checkpointQuery("host", checkpoint, muted)
+	assert.Contains(t, hostQuery["filter"], "host.last_hard_state_change >= checkpoint")
+	assert.Contains(t, hostQuery["filter"], "host.__name in muted")
+	assert.NotContains(t, hostQuery["filter"], "service.")
+	assert.Equal(t, map[string]any{
+		"checkpoint": float64(checkpoint.Add(-checkpointMargin).Unix()),
+		"muted":      []string{"db-01"},
+	}, hostQuery["filter_vars"], "checkpoint must include the margin")
+
+	serviceQuery := checkpointQuery("service", checkpoint, muted)
+	assert.Contains(t, serviceQuery["filter"], "service.acknowledgement_last_change >= checkpoint")
+	assert.Equal(t, []string{"db-01!disk", "web-01!http"},
+		serviceQuery["filter_vars"].(map[string]any)["muted"])
+
+	assert.Equal(t, []string{}, checkpointQuery("service", checkpoint, nil)["filter_vars"].(map[string]any)["muted"],
+		"muted objects must be encoded as an empty array rather than null")
+}
+
+func TestCheckpoints_Begin(t *testing.T) {
+	t.Parallel()
+
+	c := &Checkpoints{}
+	now := time.Now()
+	assert.Equal(t, now, c.processedUntil(now), "checkpoint must not be held back without pending events")
+
+	first := c.Begin()
+	second := c.Begin()
+	received := c.processedUntil(now.Add(time.Hour))
+	assert.False(t, received.After(time.Now()), "checkpoint must be held back by pending events")
+
+	first()
+	assert.False(t, c.processedUntil(now.Add(time.Hour)).Before(received),
+		"checkpoint must advance once the oldest pending event is processed")
+
+	second()
+	assert.Equal(t, now.Add(time.Hour), c.processedUntil(now.Add(time.Hour)))
+
+	var disabled *Checkpoints
+	assert.NotPanics(t, func() { disabled.Begin()() }, "Begin must be usable without checkpoints")
+}
+
+func TestCheckpoints_Load(t *testing.T) {
+	t.Parallel()
+
+	checkpoint := time.UnixMilli(1_700_000_000_000)
+	fake := testutils.NewFakeDB(database.PostgreSQL, func(string, []any) (testutils.FakeResult, error) {
+		return testutils.FakeResult{
+			Columns: []string{"source_id", "shard_count", "shard_mask", "checkpoint"},
+			Rows:    [][]driver.Value{{int64(1), int64(4), int64(0b1010), checkpoint.UnixMilli()}},
+		}, nil
+	})
+
+	c := &Checkpoints{Db: &database.DB{DB: fake.DB}, SourceID: 1, ShardCount: 4, ShardMask: 0b1010}
+	loaded, err := c.Load(context.Background())
+	require.NoError(t, err)
+	assert.True(t, checkpoint.Equal(loaded))
+
+	statements := fake.Statements()
+	require.Len(t, statements, 1)
+	assert.Equal(t, []any{int64(1), int64(4), int64(0b1010)}, statements[0].Args,
+		"checkpoint must be loaded for the served shards only")
+}
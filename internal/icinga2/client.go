@@ -2,6 +2,7 @@ package icinga2
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
@@ -13,6 +14,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -43,8 +45,12 @@ type catchupEventMsg struct {
 // the Client executes a worker within its own goroutine, which dispatches event.Event to the CallbackFn and enforces
 // order during catching up after (re-)connections.
 type Client struct {
-	// ApiBaseURL et al. configure where and how the Icinga 2 API can be reached.
-	ApiBaseURL       string
+	// ApiBaseURLs et al. configure where and how the Icinga 2 API can be reached.
+	//
+	// Multiple URLs refer to the endpoints of the same HA zone, e.g., both masters. One endpoint is used at a time,
+	// starting with the first one and failing over to the next one if it becomes unreachable or unhealthy, see
+	// failover.
+	ApiBaseURLs      []string
 	ApiBasicAuthUser string
 	ApiBasicAuthPass string
 
@@ -60,6 +66,23 @@ type Client struct {
 	// for most setups unless the system is under immense stress or other issues are also present.
 	ApiTimeout time.Duration
 
+	// CatchupDuplicateWindow in which events generated during the catch-up-phase are suppressed if they are identical to
+	// one of a previous catch-up-phase, see catchupDuplicates. A zero value disables the suppression.
+	CatchupDuplicateWindow time.Duration
+
+	// CustomVars lists the custom variables of hosts and services to be attached to events as extra tags, see
+	// addCheckableExtraTags.
+	CustomVars []string
+
+	// CatchupPageSize is the number of objects whose runtime attributes are queried at once during the catch-up-phase.
+	CatchupPageSize int
+	// CatchupConcurrency limits the number of pages being queried and processed at a time per object type.
+	CatchupConcurrency int
+
+	// Checkpoints, if not nil, allows the catch-up-phase to only query the objects which might have changed since the
+	// last checkpoint, which is saved after each catch-up-phase and regularly while receiving Event Stream events.
+	Checkpoints *Checkpoints
+
 	// EventSourceId to be reflected in generated event.Events.
 	EventSourceId int64
 	// IcingaWebRoot points to the Icinga Web 2 endpoint for generated URLs.
@@ -86,6 +109,27 @@ type Client struct {
 	// The LRU cache size is defined as 2^17, and when the actual cached items reach this size, the least used values
 	// will simply be overwritten by the new ones.
 	eventExtraTagsCache *lru.Cache[string, map[string]string]
+
+	// activeEndpoint is the index of the ApiBaseURLs entry currently used for all requests.
+	activeEndpoint atomic.Int64
+}
+
+// failover switches from the given endpoint to the next one of ApiBaseURLs after it failed due to the given error.
+//
+// If the active endpoint was already switched in the meantime, e.g., by a concurrent request failing as well, it is
+// kept. Thus, an unreachable endpoint does not make concurrent requests skip the next one.
+func (client *Client) failover(endpoint int64, err error) {
+	if len(client.ApiBaseURLs) < 2 {
+		return
+	}
+
+	next := (endpoint + 1) % int64(len(client.ApiBaseURLs))
+	if client.activeEndpoint.CompareAndSwap(endpoint, next) {
+		client.Logger.Warnw("Failing over to another Icinga 2 API endpoint",
+			zap.String("from", client.ApiBaseURLs[endpoint]),
+			zap.String("to", client.ApiBaseURLs[next]),
+			zap.Error(err))
+	}
 }
 
 // buildCommonEvent creates an event.Event based on Host and (optional) Service attributes to be specified later.
@@ -170,6 +214,7 @@ func (client *Client) fetchExtraTagsFor(ctx context.Context, host, service strin
 	for _, hostGroup := range queryResult.Attrs.Groups {
 		extraTags["hostgroup/"+hostGroup] = ""
 	}
+	client.addCheckableExtraTags(extraTags, "host", &queryResult.Attrs)
 
 	if service != "" {
 		queryResult, err := client.fetchCheckable(ctx, host, service)
@@ -179,6 +224,7 @@ func (client *Client) fetchExtraTagsFor(ctx context.Context, host, service strin
 		for _, serviceGroup := range queryResult.Attrs.Groups {
 			extraTags["servicegroup/"+serviceGroup] = ""
 		}
+		client.addCheckableExtraTags(extraTags, "service", &queryResult.Attrs)
 	}
 
 	client.eventExtraTagsCache.Add(objectName, extraTags)
@@ -186,6 +232,44 @@ func (client *Client) fetchExtraTagsFor(ctx context.Context, host, service strin
 	return extraTags, nil
 }
 
+// addCheckableExtraTags adds the notes, notes_url and action_url as well as the custom variables listed in
+// Client.CustomVars of a host or service to the extra tags, prefixed by its objType, e.g., "host/notes_url" or
+// "service/vars/os". Empty attributes are omitted, while custom variables other than strings are encoded as JSON.
+func (client *Client) addCheckableExtraTags(
+	extraTags map[string]string,
+	objType string,
+	attrs *HostServiceRuntimeAttributes,
+) {
+	for key, value := range map[string]string{
+		"notes":      attrs.Notes,
+		"notes_url":  attrs.NotesUrl,
+		"action_url": attrs.ActionUrl,
+	} {
+		if value != "" {
+			extraTags[objType+"/"+key] = value
+		}
+	}
+
+	for _, name := range client.CustomVars {
+		value, ok := attrs.Vars[name]
+		if !ok || value == nil {
+			continue
+		}
+
+		str, isString := value.(string)
+		if !isString {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				client.Logger.Warnw("Cannot encode custom variable as extra tag",
+					zap.String("object_type", objType), zap.String("custom_var", name), zap.Error(err))
+				continue
+			}
+			str = string(encoded)
+		}
+		extraTags[objType+"/vars/"+name] = str
+	}
+}
+
 // deleteExtraTagsCacheFor deletes any existing event extra tags of the given Object from the cache store.
 func (client *Client) deleteExtraTagsCacheFor(result *ObjectCreatedDeleted) error {
 	if result.ObjectType != "Host" && result.ObjectType != "Service" {
@@ -464,6 +548,16 @@ func (client *Client) worker() {
 
 		// catchupWorkerDelay slows down future catch-up-phase workers if prior attempts have failed.
 		catchupWorkerDelay time.Duration
+
+		// catchupDuplicates suppresses catch-up events identical to those of previous catch-up-phases, counted by
+		// catchupSuppressed for the current phase.
+		catchupDuplicates = newCatchupDuplicates(client.CatchupDuplicateWindow)
+		catchupSuppressed int
+
+		// catchupStarted is the time the current catch-up-phase started, becoming the checkpoint once it has finished.
+		catchupStarted time.Time
+		// lastCheckpoint is the time the last checkpoint was saved, limiting how often checkpoints are saved.
+		lastCheckpoint time.Time
 	)
 
 	// catchupReset resets all catchup variables to their initial empty state.
@@ -490,6 +584,9 @@ func (client *Client) worker() {
 
 		client.Logger.Info("Worker enters catch-up-phase, start caching up on Event Stream events")
 		catchupReset()
+		catchupDuplicates.startPhase(time.Now())
+		catchupSuppressed = 0
+		catchupStarted = time.Now()
 		catchupEventCh, catchupCancel = client.startCatchupWorkers(catchupWorkerDelay)
 	}
 
@@ -505,7 +602,11 @@ func (client *Client) worker() {
 		case catchupMsg, ok := <-catchupEventCh:
 			// Process an incoming event
 			if ok && catchupMsg.error == nil {
-				client.CallbackFn(catchupMsg.eventMsg.event)
+				if catchupDuplicates.suppress(catchupMsg.eventMsg.event, time.Now()) {
+					catchupSuppressed++
+				} else {
+					client.CallbackFn(catchupMsg.eventMsg.event)
+				}
 				catchupCacheUpdate(catchupMsg.eventMsg)
 				break
 			}
@@ -541,14 +642,20 @@ func (client *Client) worker() {
 					break
 				}
 
+				catchupDuplicates.forget(ev)
 				client.CallbackFn(ev)
 				break
 			}
 
-			client.Logger.Info("Worker leaves catch-up-phase, returning to normal operation")
+			client.Logger.Infow("Worker leaves catch-up-phase, returning to normal operation",
+				zap.Int("suppressed_duplicates", catchupSuppressed))
 			catchupReset()
 			catchupWorkerDelay = 0
 
+			// All changes before the catch-up-phase were queried, while later ones were received and just replayed.
+			client.saveCheckpoint(catchupStarted)
+			lastCheckpoint = time.Now()
+
 		case ev := <-client.eventDispatcherEventStream:
 			// During catch-up-phase, buffer Event Stream events
 			if catchupEventCh != nil {
@@ -557,20 +664,49 @@ func (client *Client) worker() {
 				break
 			}
 
+			catchupDuplicates.forget(ev.event)
 			client.CallbackFn(ev.event)
+
+			// As the Event Stream delivers the events in order, all changes up to now were received. A stalled Event
+			// Stream does not deliver any events, thus cannot advance the checkpoint.
+			if now := time.Now(); now.Sub(lastCheckpoint) >= checkpointInterval {
+				client.saveCheckpoint(now)
+				lastCheckpoint = now
+			}
 		}
 	}
 }
 
+// saveCheckpoint saves the given time as the checkpoint to resume the next catch-up-phase from, if enabled. Failing to
+// do so is only logged, as the next catch-up-phase then just queries more objects.
+func (client *Client) saveCheckpoint(checkpoint time.Time) {
+	if client.Checkpoints == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(client.Ctx, client.ApiTimeout)
+	defer cancel()
+
+	if err := client.Checkpoints.Save(ctx, checkpoint); err != nil {
+		client.Logger.Warnw("Cannot save catch-up checkpoint", zap.Error(err))
+	}
+}
+
 // Process incoming events and reconnect to the Event Stream with catching up on missed objects if necessary.
 //
 // This method blocks as long as the Client runs, which, unless Ctx is cancelled, is forever. While its internal loop
 // takes care of reconnections, messages are being logged while generated event.Event will be dispatched to the
-// CallbackFn function.
+// CallbackFn function. Once it returns, CallbackFn is no longer called.
 func (client *Client) Process() {
 	if client.ApiTimeout == 0 {
 		client.ApiTimeout = time.Minute
 	}
+	if client.CatchupPageSize <= 0 {
+		client.CatchupPageSize = 1000
+	}
+	if client.CatchupConcurrency <= 0 {
+		client.CatchupConcurrency = 1
+	}
 
 	client.eventDispatcherEventStream = make(chan *eventMsg)
 	client.catchupPhaseRequest = make(chan struct{})
@@ -583,7 +719,11 @@ func (client *Client) Process() {
 	}
 	client.eventExtraTagsCache = cache
 
-	go client.worker()
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		client.worker()
+	}()
 
 	for client.Ctx.Err() == nil {
 		err := client.listenEventStream()
@@ -593,4 +733,6 @@ func (client *Client) Process() {
 			client.Logger.Errorw("Event Stream processing was closed")
 		}
 	}
+
+	<-workerDone
 }
@@ -11,10 +11,12 @@ import (
 	"fmt"
 	"github.com/icinga/icinga-notifications/internal/event"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 	"io"
 	"net/http"
 	"net/url"
 	"slices"
+	"sync/atomic"
 	"time"
 )
 
@@ -68,30 +70,130 @@ func extractObjectQueriesResult[T Comment | HostServiceRuntimeAttributes](jsonRe
 	}()
 
 	var objQueriesResults []ObjectQueriesResult[T]
-	err := json.NewDecoder(jsonResp).Decode(&struct {
-		Results *[]ObjectQueriesResult[T] `json:"results"`
-	}{&objQueriesResults})
+	err := decodeObjectQueriesResults(jsonResp, func(objQueriesResult *ObjectQueriesResult[T]) error {
+		objQueriesResults = append(objQueriesResults, *objQueriesResult)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 	return objQueriesResults, nil
 }
 
+// decodeObjectQueriesResults decodes the "results" array out of a JSON response one element at a time, passing each
+// to fn. Thus, unlike decoding the whole response at once, only one element is held in memory at a time.
+//
+// Decoding stops at the first error returned by fn. The response is neither drained nor closed.
+func decodeObjectQueriesResults[T any](jsonResp io.Reader, fn func(*T) error) error {
+	dec := json.NewDecoder(jsonResp)
+
+	expectDelim := func(delim json.Delim) error {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if token != delim {
+			return fmt.Errorf("unexpected JSON token %v, expected %v", token, delim)
+		}
+		return nil
+	}
+
+	if err := expectDelim('{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if key != "results" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if token == nil {
+			// "results": null
+			continue
+		}
+		if token != json.Delim('[') {
+			return fmt.Errorf("unexpected JSON token %v, expected [", token)
+		}
+		for dec.More() {
+			var result T
+			if err := dec.Decode(&result); err != nil {
+				return err
+			}
+			if err := fn(&result); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(']'); err != nil {
+			return err
+		}
+	}
+	return expectDelim('}')
+}
+
 // queryObjectsApi performs a configurable HTTP request against the Icinga 2 API and returns its raw response.
 //
+// The request is sent to the active endpoint. If it cannot be reached, the request is retried against the other
+// endpoints, failing over to the first one reachable, see Client.failover. HTTP errors are returned right away, as
+// another endpoint of the same HA zone would most likely respond the same.
+//
 // The returned io.ReaderCloser MUST be both read to completion and closed to reuse connections.
 func (client *Client) queryObjectsApi(
 	ctx context.Context,
 	urlPaths []string,
 	method string,
-	body io.Reader,
+	body []byte,
+	headers map[string]string,
+) (io.ReadCloser, error) {
+	var err error
+	for range client.ApiBaseURLs {
+		endpoint := client.activeEndpoint.Load()
+
+		var res io.ReadCloser
+		res, err = client.queryEndpoint(ctx, client.ApiBaseURLs[endpoint], urlPaths, method, body, headers)
+		if !errors.Is(err, errEndpointUnreachable) || ctx.Err() != nil {
+			return res, err
+		}
+
+		client.failover(endpoint, err)
+	}
+
+	return nil, err
+}
+
+// errEndpointUnreachable is wrapped by queryEndpoint if the endpoint could not be reached, e.g., as it is down.
+var errEndpointUnreachable = errors.New("Icinga 2 API endpoint is unreachable")
+
+// queryEndpoint performs a configurable HTTP request against the given Icinga 2 API endpoint, see queryObjectsApi.
+func (client *Client) queryEndpoint(
+	ctx context.Context,
+	baseURL string,
+	urlPaths []string,
+	method string,
+	body []byte,
 	headers map[string]string,
 ) (io.ReadCloser, error) {
-	apiUrl, err := url.JoinPath(client.ApiBaseURL, urlPaths...)
+	apiUrl, err := url.JoinPath(baseURL, urlPaths...)
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequestWithContext(ctx, method, apiUrl, body)
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, apiUrl, bodyReader)
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +210,7 @@ func (client *Client) queryObjectsApi(
 	}
 	res, err := httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", errEndpointUnreachable, err)
 	}
 
 	err = checkHTTPResponseStatusCode(res.StatusCode, res.Status)
@@ -142,7 +244,7 @@ func (client *Client) queryObjectsApiQuery(ctx context.Context, objType string,
 		ctx,
 		[]string{"/v1/objects/", objType + "s"},
 		http.MethodPost,
-		bytes.NewReader(reqBody),
+		reqBody,
 		map[string]string{
 			"Accept":                 "application/json",
 			"Content-Type":           "application/json",
@@ -264,148 +366,240 @@ func (client *Client) fetchAcknowledgementComment(ctx context.Context, host, ser
 	return &objQueriesResults[0].Attrs, nil
 }
 
+// missedChangesQuery returns the query of the Icinga 2 objects API for all objects of objType (host, service) to catch
+// up on, i.e., all of them, or only those which might have changed since the last checkpoint, if any, see Checkpoints.
+//
+// If the checkpoint cannot be loaded, all objects are queried, as this only takes longer.
+func (client *Client) missedChangesQuery(ctx context.Context, objType string) map[string]any {
+	if client.Checkpoints != nil {
+		checkpoint, err := client.Checkpoints.Load(ctx)
+		var muted []string
+		if err == nil && !checkpoint.IsZero() {
+			muted, err = client.Checkpoints.MutedObjects(ctx)
+		}
+
+		switch {
+		case err != nil:
+			client.Logger.Warnw("Cannot resume catch-up-phase from checkpoint, querying all objects",
+				zap.String("object_type", objType), zap.Error(err))
+		case !checkpoint.IsZero():
+			client.Logger.Debugw("Resuming catch-up-phase from checkpoint",
+				zap.String("object_type", objType), zap.Time("checkpoint", checkpoint), zap.Int("muted", len(muted)))
+			return checkpointQuery(objType, checkpoint, muted)
+		}
+	}
+
+	return map[string]any{}
+}
+
+// queryMissedChangesNames returns the names of all objects of objType (host, service) to catch up on, see
+// missedChangesQuery. Only their names are queried, keeping the response small even for huge setups.
+func (client *Client) queryMissedChangesNames(ctx context.Context, objType string) ([]string, error) {
+	query := client.missedChangesQuery(ctx, objType)
+	query["attrs"] = []string{"name"}
+
+	jsonRaw, err := client.queryObjectsApiQuery(ctx, objType, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, jsonRaw)
+		_ = jsonRaw.Close()
+	}()
+
+	var names []string
+	err = decodeObjectQueriesResults(jsonRaw, func(result *struct {
+		Name string `json:"name"`
+	}) error {
+		names = append(names, result.Name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// catchupStats counts the events emitted by the concurrent catch-up of one object type.
+type catchupStats struct {
+	stateChangeEvents, muteEvents, unmuteEvents atomic.Int64
+}
+
 // checkMissedChanges queries objType (host, service) from the Icinga 2 API to catch up on missed events.
 //
+// To keep the memory usage flat for huge setups, only the names of the objects are queried at first. Their runtime
+// attributes are then queried in pages of Client.CatchupPageSize objects, up to Client.CatchupConcurrency at a time.
+//
 // If the object's acknowledgement field is non-zero, an Acknowledgement Event will be constructed following the Host or
 // Service object. Each event will be delivered to the channel.
 func (client *Client) checkMissedChanges(ctx context.Context, objType string, catchupEventCh chan *catchupEventMsg) error {
-	jsonRaw, err := client.queryObjectsApiDirect(ctx, objType, "")
-	if err != nil {
-		return err
-	}
-	objQueriesResults, err := extractObjectQueriesResult[HostServiceRuntimeAttributes](jsonRaw)
+	names, err := client.queryMissedChangesNames(ctx, objType)
 	if err != nil {
 		return err
 	}
 
-	var stateChangeEvents, muteEvents, unmuteEvents int
+	var stats catchupStats
 	defer func() {
 		client.Logger.Debugw("Querying API emitted events",
 			zap.String("object_type", objType),
-			zap.Int("state_changes", stateChangeEvents),
-			zap.Int("mute_events", muteEvents),
-			zap.Int("unmute_events", unmuteEvents))
+			zap.Int("objects", len(names)),
+			zap.Int64("state_changes", stats.stateChangeEvents.Load()),
+			zap.Int64("mute_events", stats.muteEvents.Load()),
+			zap.Int64("unmute_events", stats.unmuteEvents.Load()))
 	}()
 
-	for _, objQueriesResult := range objQueriesResults {
-		var hostName, serviceName, objectName string
-		switch objQueriesResult.Type {
-		case "Host":
-			hostName = objQueriesResult.Attrs.Name
-			objectName = hostName
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(client.CatchupConcurrency)
+	for start := 0; start < len(names) && groupCtx.Err() == nil; start += client.CatchupPageSize {
+		page := names[start:min(start+client.CatchupPageSize, len(names))]
+		group.Go(func() error {
+			jsonRaw, err := client.queryObjectsApiQuery(groupCtx, objType, map[string]any{
+				"filter":      objType + ".__name in names",
+				"filter_vars": map[string]any{"names": page},
+			})
+			if err != nil {
+				return err
+			}
+			objQueriesResults, err := extractObjectQueriesResult[HostServiceRuntimeAttributes](jsonRaw)
+			if err != nil {
+				return err
+			}
 
-		case "Service":
-			hostName = objQueriesResult.Attrs.Host
-			serviceName = objQueriesResult.Attrs.Name
-			objectName = hostName + "!" + serviceName
+			for i := range objQueriesResults {
+				if err := client.catchupObject(groupCtx, &objQueriesResults[i], catchupEventCh, &stats); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return group.Wait()
+}
 
-		default:
-			return fmt.Errorf("querying API delivered a wrong object type %q", objQueriesResult.Type)
-		}
+// catchupObject emits the events to catch up on a single object queried by checkMissedChanges.
+func (client *Client) catchupObject(
+	ctx context.Context,
+	objQueriesResult *ObjectQueriesResult[HostServiceRuntimeAttributes],
+	catchupEventCh chan *catchupEventMsg,
+	stats *catchupStats,
+) error {
+	var hostName, serviceName, objectName string
+	switch objQueriesResult.Type {
+	case "Host":
+		hostName = objQueriesResult.Attrs.Name
+		objectName = hostName
+
+	case "Service":
+		hostName = objQueriesResult.Attrs.Host
+		serviceName = objQueriesResult.Attrs.Name
+		objectName = hostName + "!" + serviceName
 
-		// Only process HARD states
-		if objQueriesResult.Attrs.StateType == StateTypeSoft {
-			client.Logger.Debugw("Skipping SOFT event", zap.Inline(&objQueriesResult.Attrs))
-			continue
-		}
+	default:
+		return fmt.Errorf("querying API delivered a wrong object type %q", objQueriesResult.Type)
+	}
 
-		attrs := objQueriesResult.Attrs
-		checkableIsMuted, err := isMuted(ctx, client, &objQueriesResult)
-		if err != nil {
-			return err
-		}
+	// Only process HARD states
+	if objQueriesResult.Attrs.StateType == StateTypeSoft {
+		client.Logger.Debugw("Skipping SOFT event", zap.Inline(&objQueriesResult.Attrs))
+		return nil
+	}
 
-		var fakeEv *event.Event
-		if checkableIsMuted && attrs.Acknowledgement != AcknowledgementNone {
-			ackComment, err := client.fetchAcknowledgementComment(ctx, hostName, serviceName, attrs.AcknowledgementLastChange.Time())
-			if errors.Is(err, errMissingAcknowledgementComment) {
-				// Unfortunately, there is no Acknowledgement object in Icinga 2, but only related runtime attributes
-				// attached to Host or Service objects. Those attributes contain no authorship. The only way to link an
-				// acknowledgement to a contact, when being fetched through the Config Objects API, is to find a
-				// matching Comment object, which contains an author field.
-				//
-				// This is not the case for the Event Stream API, where AcknowledgementSet has an author field.
-				//
-				// However, when no author is present, the Acknowledgement Event cannot be processed. Eventually, the
-				// Incident.processAcknowledgementEvent method will fail hard.
-
-				client.Logger.Infow("Cannot find the comment for an acknowledgement, creating a generic muted event",
-					zap.String("object", objectName), zap.NamedError("reason", err))
-
-				fakeEv, err = client.buildCommonEvent(ctx, hostName, serviceName)
-				if err != nil {
-					return fmt.Errorf("failed to construct checkable fake unmute event: %w", err)
-				}
+	attrs := objQueriesResult.Attrs
+	checkableIsMuted, err := isMuted(ctx, client, objQueriesResult)
+	if err != nil {
+		return err
+	}
+
+	var fakeEv *event.Event
+	if checkableIsMuted && attrs.Acknowledgement != AcknowledgementNone {
+		ackComment, err := client.fetchAcknowledgementComment(ctx, hostName, serviceName, attrs.AcknowledgementLastChange.Time())
+		if errors.Is(err, errMissingAcknowledgementComment) {
+			// Unfortunately, there is no Acknowledgement object in Icinga 2, but only related runtime attributes
+			// attached to Host or Service objects. Those attributes contain no authorship. The only way to link an
+			// acknowledgement to a contact, when being fetched through the Config Objects API, is to find a
+			// matching Comment object, which contains an author field.
+			//
+			// This is not the case for the Event Stream API, where AcknowledgementSet has an author field.
+			//
+			// However, when no author is present, the Acknowledgement Event cannot be processed. Eventually, the
+			// Incident.processAcknowledgementEvent method will fail hard.
+
+			client.Logger.Infow("Cannot find the comment for an acknowledgement, creating a generic muted event",
+				zap.String("object", objectName), zap.NamedError("reason", err))
 
-				fakeEv.Type = event.TypeMute
-				fakeEv.SetMute(true, "Checkable is acknowledged, but we could not find its corresponding comment")
-			} else if err != nil {
-				return fmt.Errorf("fetching acknowledgement comment for %q failed, %w", objectName, err)
-			} else {
-				ack := &Acknowledgement{Host: hostName, Service: serviceName, Author: ackComment.Author, Comment: ackComment.Text}
-				// We do not need to fake ACK set events as they are handled correctly by an incident and any
-				// redundant/successive ACK set events are discarded accordingly.
-				ack.EventType = typeAcknowledgementSet
-				fakeEv, err = client.buildAcknowledgementEvent(ctx, ack)
-				if err != nil {
-					return fmt.Errorf("failed to construct Event from Acknowledgement response, %w", err)
-				}
-			}
-		} else if checkableIsMuted {
 			fakeEv, err = client.buildCommonEvent(ctx, hostName, serviceName)
 			if err != nil {
-				return fmt.Errorf("failed to construct checkable fake mute event: %w", err)
+				return fmt.Errorf("failed to construct checkable fake unmute event: %w", err)
 			}
 
 			fakeEv.Type = event.TypeMute
-			if attrs.DowntimeDepth != 0 {
-				fakeEv.SetMute(true, "Checkable is in downtime, but we missed the Icinga 2 DowntimeStart event")
-			} else {
-				fakeEv.SetMute(true, "Checkable is flapping, but we missed the Icinga 2 FlappingStart event")
-			}
+			fakeEv.SetMute(true, "Checkable is acknowledged, but we could not find its corresponding comment")
+		} else if err != nil {
+			return fmt.Errorf("fetching acknowledgement comment for %q failed, %w", objectName, err)
 		} else {
-			// This could potentially produce numerous superfluous database (event table) entries if we generate such
-			// dummy events after each Icinga 2 / Notifications reload, thus they are being identified as such in
-			// incident#ProcessEvent() and Client.CallbackFn and suppressed accordingly.
-			fakeEv, err = client.buildCommonEvent(ctx, hostName, serviceName)
+			ack := &Acknowledgement{Host: hostName, Service: serviceName, Author: ackComment.Author, Comment: ackComment.Text}
+			// We do not need to fake ACK set events as they are handled correctly by an incident and any
+			// redundant/successive ACK set events are discarded accordingly.
+			ack.EventType = typeAcknowledgementSet
+			fakeEv, err = client.buildAcknowledgementEvent(ctx, ack)
 			if err != nil {
-				return fmt.Errorf("failed to construct checkable fake unmute event: %w", err)
+				return fmt.Errorf("failed to construct Event from Acknowledgement response, %w", err)
 			}
-
-			fakeEv.Type = event.TypeUnmute
-			fakeEv.SetMute(false, "All mute reasons of the checkable are cleared, but we missed the appropriate unmute event")
 		}
-
-		fakeEv.Message = attrs.LastCheckResult.Output
-		ackEvent := *fakeEv
-		select {
-		case catchupEventCh <- &catchupEventMsg{eventMsg: &eventMsg{fakeEv, attrs.LastStateChange.Time()}}:
-			if fakeEv.Type == event.TypeUnmute {
-				unmuteEvents++
-			} else {
-				muteEvents++
-			}
-		case <-ctx.Done():
-			return ctx.Err()
+	} else if checkableIsMuted {
+		fakeEv, err = client.buildCommonEvent(ctx, hostName, serviceName)
+		if err != nil {
+			return fmt.Errorf("failed to construct checkable fake mute event: %w", err)
 		}
 
-		ev, err := client.buildHostServiceEvent(ctx, attrs.LastCheckResult, attrs.State, hostName, serviceName)
+		fakeEv.Type = event.TypeMute
+		if attrs.DowntimeDepth != 0 {
+			fakeEv.SetMute(true, "Checkable is in downtime, but we missed the Icinga 2 DowntimeStart event")
+		} else {
+			fakeEv.SetMute(true, "Checkable is flapping, but we missed the Icinga 2 FlappingStart event")
+		}
+	} else {
+		// This could potentially produce numerous superfluous database (event table) entries if we generate such
+		// dummy events after each Icinga 2 / Notifications reload, thus they are being identified as such in
+		// incident#ProcessEvent() and Client.CallbackFn and suppressed accordingly.
+		fakeEv, err = client.buildCommonEvent(ctx, hostName, serviceName)
 		if err != nil {
-			return fmt.Errorf("failed to construct Event from Host/Service response, %w", err)
+			return fmt.Errorf("failed to construct checkable fake unmute event: %w", err)
 		}
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case catchupEventCh <- &catchupEventMsg{eventMsg: &eventMsg{ev, attrs.LastStateChange.Time()}}:
-			stateChangeEvents++
-			if fakeEv.Type == event.TypeAcknowledgementSet {
-				select {
-				// Retry the AckSet event so that the author of the ack is set as the incident
-				// manager if there was no existing incident before the above state change event.
-				case catchupEventCh <- &catchupEventMsg{eventMsg: &eventMsg{&ackEvent, attrs.LastStateChange.Time()}}:
-				case <-ctx.Done():
-					return ctx.Err()
-				}
+
+		fakeEv.Type = event.TypeUnmute
+		fakeEv.SetMute(false, "All mute reasons of the checkable are cleared, but we missed the appropriate unmute event")
+	}
+
+	fakeEv.Message = attrs.LastCheckResult.Output
+	ackEvent := *fakeEv
+	select {
+	case catchupEventCh <- &catchupEventMsg{eventMsg: &eventMsg{fakeEv, attrs.LastStateChange.Time()}}:
+		if fakeEv.Type == event.TypeUnmute {
+			stats.unmuteEvents.Add(1)
+		} else {
+			stats.muteEvents.Add(1)
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	ev, err := client.buildHostServiceEvent(ctx, attrs.LastCheckResult, attrs.State, hostName, serviceName)
+	if err != nil {
+		return fmt.Errorf("failed to construct Event from Host/Service response, %w", err)
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case catchupEventCh <- &catchupEventMsg{eventMsg: &eventMsg{ev, attrs.LastStateChange.Time()}}:
+		stats.stateChangeEvents.Add(1)
+		if fakeEv.Type == event.TypeAcknowledgementSet {
+			select {
+			// Retry the AckSet event so that the author of the ack is set as the incident
+			// manager if there was no existing incident before the above state change event.
+			case catchupEventCh <- &catchupEventMsg{eventMsg: &eventMsg{&ackEvent, attrs.LastStateChange.Time()}}:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
 		}
 	}
@@ -416,6 +610,8 @@ func (client *Client) checkMissedChanges(ctx context.Context, objType string, ca
 type connectEventStreamReadCloser struct {
 	io.ReadCloser
 	cancel context.CancelFunc
+	// endpoint is the index of the ApiBaseURLs entry this Event Stream is connected to.
+	endpoint int64
 }
 
 // Close the internal ReadCloser with canceling the internal http.Request's context first.
@@ -424,18 +620,25 @@ func (e *connectEventStreamReadCloser) Close() error {
 	return e.ReadCloser.Close()
 }
 
+// errEventStreamFailed is passed to Client.failover if no Event Stream API connection could be established.
+var errEventStreamFailed = errors.New("establishing an Event Stream API connection failed")
+
 // connectEventStream connects to the EventStream, retries until a connection was established.
 //
 // The esTypes is a string array of required Event Stream types.
 //
+// Each attempt connects to the active endpoint, failing over to the next one if it fails. Only after each endpoint has
+// failed once, the next attempt is delayed.
+//
 // An error will only be returned if reconnecting - retrying the (almost) same thing - will not help.
-func (client *Client) connectEventStream(esTypes []string) (io.ReadCloser, error) {
-	apiUrl, err := url.JoinPath(client.ApiBaseURL, "/v1/events")
-	if err != nil {
-		return nil, err
-	}
+func (client *Client) connectEventStream(esTypes []string) (*connectEventStreamReadCloser, error) {
+	for attempt, retryDelay := 1, time.Second; ; attempt++ {
+		endpoint := client.activeEndpoint.Load()
+		apiUrl, err := url.JoinPath(client.ApiBaseURLs[endpoint], "/v1/events")
+		if err != nil {
+			return nil, err
+		}
 
-	for retryDelay := time.Second; ; retryDelay = min(3*time.Minute, 2*retryDelay) {
 		// Always ensure an unique queue name to mitigate possible naming conflicts.
 		queueNameRndBuff := make([]byte, 16)
 		_, _ = rand.Read(queueNameRndBuff)
@@ -472,7 +675,8 @@ func (client *Client) connectEventStream(esTypes []string) (io.ReadCloser, error
 		go func() {
 			defer close(resCh)
 
-			client.Logger.Debugw("Try to establish an Event Stream API connection", zap.String("request_body", string(reqBody)))
+			client.Logger.Debugw("Try to establish an Event Stream API connection",
+				zap.String("endpoint", client.ApiBaseURLs[endpoint]), zap.String("request_body", string(reqBody)))
 			httpClient := &http.Client{Transport: client.ApiHttpTransport}
 			res, err := httpClient.Do(req)
 			if err != nil {
@@ -506,6 +710,7 @@ func (client *Client) connectEventStream(esTypes []string) (io.ReadCloser, error
 				esReadCloser := &connectEventStreamReadCloser{
 					ReadCloser: res.Body,
 					cancel:     reqCancel,
+					endpoint:   endpoint,
 				}
 				return esReadCloser, nil
 			}
@@ -514,6 +719,11 @@ func (client *Client) connectEventStream(esTypes []string) (io.ReadCloser, error
 		}
 		reqCancel()
 
+		client.failover(endpoint, errEventStreamFailed)
+		if attempt%len(client.ApiBaseURLs) != 0 {
+			continue
+		}
+
 		// Rate limit API reconnections: slow down for successive failed attempts but limit to three minutes.
 		// 1s, 2s, 4s, 8s, 16s, 32s, 1m4s, 2m8s, 3m, 3m, 3m, ...
 		select {
@@ -521,6 +731,45 @@ func (client *Client) connectEventStream(esTypes []string) (io.ReadCloser, error
 		case <-client.Ctx.Done():
 			return nil, client.Ctx.Err()
 		}
+		retryDelay = min(3*time.Minute, 2*retryDelay)
+	}
+}
+
+// healthCheckInterval is the interval in which the endpoint of an Event Stream API connection is checked if there are
+// other endpoints to fail over to, see monitorEndpoint.
+const healthCheckInterval = 30 * time.Second
+
+// monitorEndpoint checks the health of the given endpoint via the /v1/status endpoint until the context is done.
+//
+// During an HA failover, the Event Stream API connection to a stopped endpoint might neither deliver events nor be
+// closed, e.g., if its host went down without closing its TCP connections. Thus, if the endpoint fails to respond in
+// time, the Client fails over to the next endpoint and the Event Stream is closed, resulting in a reconnection.
+func (client *Client) monitorEndpoint(ctx context.Context, endpoint int64, eventStream io.Closer) {
+	statusPath := []string{"/v1/status/IcingaApplication/"}
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, healthCheckInterval)
+		res, err := client.queryEndpoint(checkCtx, client.ApiBaseURLs[endpoint], statusPath, http.MethodGet, nil,
+			map[string]string{"Accept": "application/json"})
+		if err == nil {
+			_, _ = io.Copy(io.Discard, res)
+			_ = res.Close()
+		}
+		cancel()
+
+		if err != nil && ctx.Err() == nil {
+			client.failover(endpoint, err)
+			_ = eventStream.Close()
+			return
+		}
 	}
 }
 
@@ -548,6 +797,12 @@ func (client *Client) listenEventStream() error {
 		return err
 	}
 	defer func() { _ = eventStream.Close() }()
+
+	if len(client.ApiBaseURLs) > 1 {
+		monitorCtx, stopMonitor := context.WithCancel(client.Ctx)
+		defer stopMonitor()
+		go client.monitorEndpoint(monitorCtx, eventStream.endpoint, eventStream)
+	}
 	// Purge all event extra tags from our cache store, otherwise we might miss the typeObjectCreated event for
 	// some objects and never get their updated groups when Icinga 2 is reloaded/restarted.
 	defer client.eventExtraTagsCache.Purge()
@@ -638,3 +893,112 @@ func (client *Client) listenEventStream() error {
 	}
 	return lineScanner.Err()
 }
+
+// actionTarget returns the parameters of an Icinga 2 API action identifying the host or service of the given tags.
+func actionTarget(tags map[string]string) (map[string]any, error) {
+	host, service := tags["host"], tags["service"]
+	if host == "" {
+		return nil, errors.New("object has no host tag")
+	}
+
+	if service == "" {
+		return map[string]any{
+			"type":        "Host",
+			"filter":      "host.name == action_host_name",
+			"filter_vars": map[string]string{"action_host_name": host},
+		}, nil
+	}
+
+	return map[string]any{
+		"type":   "Service",
+		"filter": "host.name == action_host_name && service.name == action_service_name",
+		"filter_vars": map[string]string{
+			"action_host_name":    host,
+			"action_service_name": service,
+		},
+	}, nil
+}
+
+// performAction performs an Icinga 2 API action against the object of the given tags.
+//
+// An error is returned if the object is unknown or the action failed for it, e.g., acknowledging an object without a
+// problem, as reported by the result's code.
+func (client *Client) performAction(
+	ctx context.Context, action string, tags map[string]string, params map[string]any,
+) error {
+	body, err := actionTarget(tags)
+	if err != nil {
+		return err
+	}
+	for k, v := range params {
+		body[k] = v
+	}
+
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.queryObjectsApi(
+		ctx,
+		[]string{"/v1/actions/", action},
+		http.MethodPost,
+		reqBody,
+		map[string]string{"Accept": "application/json", "Content-Type": "application/json"})
+	if err != nil {
+		return fmt.Errorf("cannot perform action %q: %w", action, err)
+	}
+
+	defer func() {
+		_, _ = io.Copy(io.Discard, response)
+		_ = response.Close()
+	}()
+
+	type result struct {
+		Code   float64 `json:"code"`
+		Status string  `json:"status"`
+	}
+
+	var results []result
+	err = json.NewDecoder(response).Decode(&struct {
+		Results *[]result `json:"results"`
+	}{&results})
+	if err != nil {
+		return fmt.Errorf("cannot parse result of action %q: %w", action, err)
+	}
+
+	if len(results) == 0 {
+		return fmt.Errorf("action %q matched no object", action)
+	}
+	for _, result := range results {
+		if result.Code < 200 || result.Code > 299 {
+			return fmt.Errorf("action %q failed: %s", action, result.Status)
+		}
+	}
+
+	return nil
+}
+
+// AcknowledgeProblem implements the config.Icinga2Actions interface.
+//
+// As the recipients were already notified by Icinga Notifications, Icinga 2 is told not to send notifications itself.
+func (client *Client) AcknowledgeProblem(ctx context.Context, tags map[string]string, author, comment string) error {
+	return client.performAction(ctx, "acknowledge-problem", tags, map[string]any{
+		"author":  author,
+		"comment": comment,
+		"notify":  false,
+	})
+}
+
+// ScheduleDowntime implements the config.Icinga2Actions interface.
+func (client *Client) ScheduleDowntime(
+	ctx context.Context, tags map[string]string, author, comment string, start, end time.Time,
+) error {
+	return client.performAction(ctx, "schedule-downtime", tags, map[string]any{
+		"author":     author,
+		"comment":    comment,
+		"start_time": start.Unix(),
+		"end_time":   end.Unix(),
+		"fixed":      true,
+	})
+}
@@ -1,9 +1,18 @@
 package icinga2
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestCheckHTTPResponseStatusCode(t *testing.T) {
@@ -27,3 +36,154 @@ func TestCheckHTTPResponseStatusCode(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeObjectQueriesResults(t *testing.T) {
+	t.Parallel()
+
+	type result struct {
+		Name string `json:"name"`
+	}
+
+	decode := func(jsonResp string) ([]string, error) {
+		var names []string
+		err := decodeObjectQueriesResults(strings.NewReader(jsonResp), func(r *result) error {
+			names = append(names, r.Name)
+			return nil
+		})
+		return names, err
+	}
+
+	names, err := decode(`{"meta": {"x": [1, 2]}, "results": [{"name": "db-01"}, {"name": "db-01!disk", "attrs": {}}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db-01", "db-01!disk"}, names)
+
+	names, err = decode(`{"results": null}`)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	_, err = decode(`{"results": {"name": "db-01"}}`)
+	assert.Error(t, err, "results must be an array")
+
+	_, err = decode(`{"results": [{"name": "db-01"}`)
+	assert.Error(t, err, "truncated response")
+
+	errStop := errors.New("stop")
+	err = decodeObjectQueriesResults(strings.NewReader(`{"results": [{}, {}]}`), func(*result) error { return errStop })
+	assert.ErrorIs(t, err, errStop)
+}
+
+func TestClient_PerformAction(t *testing.T) {
+	t.Parallel()
+
+	var body map[string]any
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		switch body["author"] {
+		case "nobody":
+			_, _ = w.Write([]byte(`{"results": []}`))
+		case "failing":
+			_, _ = w.Write([]byte(`{"results": [{"code": 409.0, "status": "No objects found."}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"results": [{"code": 200.0, "status": "Successfully acknowledged problem."}]}`))
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{
+		ApiBaseURLs:      []string{server.URL},
+		ApiHttpTransport: server.Client().Transport,
+		ApiTimeout:       time.Minute,
+	}
+	ctx := context.Background()
+
+	t.Run("AcknowledgeProblem", func(t *testing.T) {
+		require.NoError(t, client.AcknowledgeProblem(ctx, map[string]string{"host": "db-01", "service": "disk"},
+			"jdoe", "On it"))
+		assert.Equal(t, "/v1/actions/acknowledge-problem", path)
+		assert.Equal(t, map[string]any{
+			"type":        "Service",
+			"filter":      "host.name == action_host_name && service.name == action_service_name",
+			"filter_vars": map[string]any{"action_host_name": "db-01", "action_service_name": "disk"},
+			"author":      "jdoe",
+			"comment":     "On it",
+			"notify":      false,
+		}, body)
+	})
+
+	t.Run("ScheduleDowntime", func(t *testing.T) {
+		start := time.Unix(1700000000, 0)
+		require.NoError(t, client.ScheduleDowntime(ctx, map[string]string{"host": "db-01"}, "jdoe", "Snoozed",
+			start, start.Add(time.Hour)))
+		assert.Equal(t, "/v1/actions/schedule-downtime", path)
+		assert.Equal(t, map[string]any{
+			"type":        "Host",
+			"filter":      "host.name == action_host_name",
+			"filter_vars": map[string]any{"action_host_name": "db-01"},
+			"author":      "jdoe",
+			"comment":     "Snoozed",
+			"start_time":  float64(1700000000),
+			"end_time":    float64(1700003600),
+			"fixed":       true,
+		}, body)
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		tags := map[string]string{"host": "db-01"}
+		assert.Error(t, client.AcknowledgeProblem(ctx, tags, "nobody", "-"), "no matching object")
+		assert.Error(t, client.AcknowledgeProblem(ctx, tags, "failing", "-"), "failing result")
+		assert.Error(t, client.AcknowledgeProblem(ctx, map[string]string{"service": "disk"}, "jdoe", "-"),
+			"object without host")
+	})
+}
+
+func TestClient_QueryObjectsApi_Failover(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/v1/status/IcingaApplication/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"results": []}`))
+	}))
+	defer healthy.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	client := &Client{
+		ApiBaseURLs:      []string{down.URL, healthy.URL, down.URL},
+		ApiHttpTransport: http.DefaultTransport,
+		ApiTimeout:       time.Minute,
+		Logger:           zaptest.NewLogger(t).Sugar(),
+	}
+	ctx := context.Background()
+
+	res, err := client.queryObjectsApi(ctx, []string{"/v1/status/IcingaApplication/"}, http.MethodGet, nil, nil)
+	require.NoError(t, err)
+	_ = res.Close()
+	assert.Equal(t, int64(1), client.activeEndpoint.Load(), "should fail over to the next reachable endpoint")
+
+	_, err = client.queryObjectsApi(ctx, []string{"/v1/status/IcingaApplication/missing"}, http.MethodGet, nil, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), client.activeEndpoint.Load(), "should not fail over due to HTTP errors")
+	assert.Equal(t, int32(2), requests.Load())
+
+	client.failover(0, errEventStreamFailed)
+	assert.Equal(t, int64(1), client.activeEndpoint.Load(), "should keep an endpoint switched to concurrently")
+
+	client.failover(1, errEventStreamFailed)
+	client.failover(2, errEventStreamFailed)
+	assert.Equal(t, int64(0), client.activeEndpoint.Load(), "should wrap around")
+
+	client.ApiBaseURLs = []string{down.URL, down.URL}
+	_, err = client.queryObjectsApi(ctx, []string{"/v1/status/IcingaApplication/"}, http.MethodGet, nil, nil)
+	assert.ErrorIs(t, err, errEndpointUnreachable, "should fail if all endpoints are unreachable")
+}
@@ -0,0 +1,37 @@
+package icinga2
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"testing"
+)
+
+func TestClient_AddCheckableExtraTags(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{
+		CustomVars: []string{"owner", "os", "ports", "missing", "empty"},
+		Logger:     zaptest.NewLogger(t).Sugar(),
+	}
+	extraTags := map[string]string{"hostgroup/database": ""}
+	client.addCheckableExtraTags(extraTags, "service", &HostServiceRuntimeAttributes{
+		Notes:    "Disk of the primary database",
+		NotesUrl: "https://wiki.example.com/db/disk",
+		Vars: map[string]any{
+			"owner": "dba",
+			"os":    nil,
+			"ports": []any{5432.0, 6432.0},
+			"empty": "",
+			"other": "ignored",
+		},
+	})
+
+	assert.Equal(t, map[string]string{
+		"hostgroup/database": "",
+		"service/notes":      "Disk of the primary database",
+		"service/notes_url":  "https://wiki.example.com/db/disk",
+		"service/vars/owner": "dba",
+		"service/vars/ports": "[5432,6432]",
+		"service/vars/empty": "",
+	}, extraTags)
+}
@@ -12,8 +12,14 @@ import (
 	"github.com/icinga/icinga-notifications/internal"
 	"github.com/icinga/icinga-notifications/internal/config"
 	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/errs"
 	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/eventqueue"
 	"github.com/icinga/icinga-notifications/internal/incident"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/icinga/icinga-notifications/internal/scrub"
+	"github.com/icinga/icinga-notifications/internal/shard"
+	"github.com/icinga/icinga-notifications/internal/workerpool"
 	"go.uber.org/zap"
 	"net/http"
 	"sync"
@@ -27,10 +33,17 @@ type Launcher struct {
 	Logs          *logging.Logging
 	Db            *database.DB
 	RuntimeConfig *config.RuntimeConfig
+	// Queue receives the events instead of processing them right away, unless it is nil.
+	Queue *eventqueue.Queue
+	// Pool processes the events of distinct objects concurrently, unless it is nil.
+	Pool *workerpool.Pool
 
 	mutex          sync.Mutex
 	isReady        bool
+	isStopped      bool
 	waitingSources []*config.Source
+	// clients counts the running Event Stream Clients, see Wait.
+	clients sync.WaitGroup
 }
 
 // Launch either directly launches an Icinga 2 Event Stream Client for this Source or enqueues it until the Launcher is Ready.
@@ -64,12 +77,29 @@ func (launcher *Launcher) Ready() {
 	launcher.waitingSources = nil
 }
 
+// Wait waits for all Event Stream Clients to stop once the Launcher's context is done. Afterwards, no further Clients
+// are launched, thus no further events are submitted to the Pool.
+func (launcher *Launcher) Wait() {
+	launcher.mutex.Lock()
+	launcher.isStopped = true
+	launcher.mutex.Unlock()
+
+	launcher.clients.Wait()
+}
+
 // launch a new Icinga 2 Event Stream API Client based on the config.Source configuration.
+//
+// Must be called with mutex held.
 func (launcher *Launcher) launch(src *config.Source) {
 	logger := launcher.Logs.GetChildLogger("icinga2").With(zap.Int64("source_id", src.ID))
 
+	if launcher.isStopped {
+		logger.Debug("Not launching Event Stream Client as the Launcher was stopped")
+		return
+	}
+
 	if src.Type != config.SourceTypeIcinga2 ||
-		!src.Icinga2BaseURL.Valid ||
+		len(src.Icinga2BaseURLs()) == 0 ||
 		!src.Icinga2AuthUser.Valid ||
 		!src.Icinga2AuthPass.Valid {
 		logger.Error("Source is either not of type icinga2 or not fully populated")
@@ -113,9 +143,19 @@ func (launcher *Launcher) launch(src *config.Source) {
 		trans.TLSClientConfig.InsecureSkipVerify = true
 	}
 
+	var checkpoints *Checkpoints
+	if daemon.Config().CatchupCheckpoint {
+		checkpoints = &Checkpoints{
+			Db:         launcher.Db,
+			SourceID:   src.ID,
+			ShardCount: shard.Count(),
+			ShardMask:  shard.Mask(),
+		}
+	}
+
 	subCtx, subCtxCancel := context.WithCancel(launcher.Ctx)
 	client := &Client{
-		ApiBaseURL:       src.Icinga2BaseURL.String,
+		ApiBaseURLs:      src.Icinga2BaseURLs(),
 		ApiBasicAuthUser: src.Icinga2AuthUser.String,
 		ApiBasicAuthPass: src.Icinga2AuthPass.String,
 
@@ -123,22 +163,66 @@ func (launcher *Launcher) launch(src *config.Source) {
 
 		ApiTimeout: daemon.Config().ApiTimeout,
 
+		CatchupDuplicateWindow: daemon.Config().CatchupDuplicates,
+		CatchupPageSize:        daemon.Config().CatchupPageSize,
+		CatchupConcurrency:     daemon.Config().CatchupWorkers,
+		Checkpoints:            checkpoints,
+
+		CustomVars: src.Icinga2CustomVarNames(),
+
 		EventSourceId: src.ID,
 		IcingaWebRoot: daemon.Config().Icingaweb2URL,
 
 		CallbackFn: func(ev *event.Event) {
+			launcher.RuntimeConfig.MapEventSeverity(ev)
 			l := logger.With(zap.Stringer("event", ev))
+			if err := launcher.RuntimeConfig.RenderEventURL(ev); err != nil {
+				l.Warnw("Cannot render URL of event, keeping the Icinga Web one", zap.Error(err))
+			}
+			if err := scrub.Default.ScrubEvent(ev); err != nil {
+				l.Errorw("Dropping event exceeding the limits", zap.Error(err))
+				return
+			}
+
+			// The checkpoint must not advance beyond this event until it is processed, see Checkpoints.Begin.
+			done := checkpoints.Begin()
+			process := func() {
+				defer done()
+
+				// Events already submitted to the Pool are processed even if the Client was stopped meanwhile,
+				// e.g., on shutdown, see Wait.
+				ctx := context.WithoutCancel(subCtx)
+
+				var err error
+				if launcher.Queue != nil {
+					err = launcher.Queue.Enqueue(ctx, ev)
+				} else {
+					err = incident.ProcessEvent(ctx, launcher.Db, launcher.Logs, launcher.RuntimeConfig, ev)
+				}
+				switch {
+				case errors.Is(err, event.ErrSuperfluousStateChange):
+					l.Debugw("Stopped processing event with superfluous state change", zap.Error(err))
+				case errors.Is(err, event.ErrSuperfluousMuteUnmuteEvent):
+					l.Debugw("Stopped processing event with superfluous (un)mute object", zap.Error(err))
+				case errors.Is(err, shard.ErrNotOwned):
+					l.Debugw("Ignoring event of an object served by another instance", zap.Error(err))
+				case errors.Is(err, errs.ErrShuttingDown):
+					l.Infow("Not processing event as the daemon is shutting down")
+				case errors.Is(err, errs.ErrTransientDB):
+					l.Errorw("Cannot process event due to a temporary database failure", zap.Error(err))
+				case err != nil:
+					l.Errorw("Cannot process event", zap.Error(err))
+				default:
+					l.Debug("Successfully processed event over callback")
+				}
+			}
 
-			err := incident.ProcessEvent(subCtx, launcher.Db, launcher.Logs, launcher.RuntimeConfig, ev)
-			switch {
-			case errors.Is(err, event.ErrSuperfluousStateChange):
-				l.Debugw("Stopped processing event with superfluous state change", zap.Error(err))
-			case errors.Is(err, event.ErrSuperfluousMuteUnmuteEvent):
-				l.Debugw("Stopped processing event with superfluous (un)mute object", zap.Error(err))
-			case err != nil:
-				l.Errorw("Cannot process event", zap.Error(err))
-			default:
-				l.Debug("Successfully processed event over callback")
+			// Events of distinct objects are processed concurrently, unless they are only enqueued anyway.
+			if launcher.Pool == nil || launcher.Queue != nil {
+				process()
+			} else if err := launcher.Pool.Submit(subCtx, object.ID(ev.SourceId, ev.Tags), process); err != nil {
+				done()
+				l.Debugw("Dropping event as the Event Stream Client was stopped", zap.Error(err))
 			}
 		},
 		Ctx:       subCtx,
@@ -146,6 +230,13 @@ func (launcher *Launcher) launch(src *config.Source) {
 		Logger:    logger,
 	}
 
-	go client.Process()
+	launcher.clients.Add(1)
+	go func() {
+		defer launcher.clients.Done()
+		client.Process()
+	}()
 	src.Icinga2SourceCancel = subCtxCancel
+	if src.Icinga2WriteBack.Valid && src.Icinga2WriteBack.Bool {
+		src.Icinga2Actions = client
+	}
 }
@@ -0,0 +1,345 @@
+package ics
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AddressTypeCalDAV is the contact address type holding the URL of a CalDAV calendar collection, e.g.,
+// "https://cloud.example.com/remote.php/dav/calendars/jdoe/on-call/", to publish the contact's on-call shifts to.
+const AddressTypeCalDAV = "caldav"
+
+// caldavResourcePrefix marks the calendar resources created by the Publisher, as a calendar may contain other events.
+const caldavResourcePrefix = "icinga-notifications-"
+
+// caldavStep is the granularity of published shifts, being the same as for the iCalendar export.
+const caldavStep = 30 * time.Minute
+
+// caldavPropfind requests nothing but the resources of a collection.
+const caldavPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/></d:prop></d:propfind>`
+
+// Publisher periodically writes the on-call shifts of all schedules to the CalDAV calendars of the contacts, as
+// referenced by their AddressTypeCalDAV address.
+//
+// Each shift is stored as its own calendar resource. Shifts changed by overrides or modified rotations are updated,
+// while upcoming shifts no longer present are deleted. Past shifts are kept as a record.
+type Publisher struct {
+	RuntimeConfig *config.RuntimeConfig
+	Logger        *logging.Logger
+	Interval      time.Duration
+	// Horizon is how far into the future shifts are published.
+	Horizon  time.Duration
+	Username string
+	Password string
+	Client   *http.Client
+
+	// published maps each calendar collection URL to its resources written by the Publisher, mapped to a fingerprint of
+	// their content. Collections are listed once to also learn about resources written before a restart.
+	published map[string]map[string]string
+}
+
+// caldavShift is a shift to be published as a calendar resource.
+type caldavShift struct {
+	event       *Event
+	fingerprint string
+}
+
+// Run the publish loop until the context is done, starting immediately.
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.publishAll(ctx, time.Now())
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// publishAll computes the shifts of all contacts having a CalDAV address and synchronizes their calendars.
+//
+// Shifts are computed from the start of the previous day on, so that the start of a shift currently in progress, and
+// thus the identity of its resource, stays the same between two runs.
+func (p *Publisher) publishAll(ctx context.Context, now time.Time) {
+	from := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	desired := p.desiredShifts(from, now.Add(p.Horizon))
+
+	if p.published == nil {
+		p.published = make(map[string]map[string]string)
+	}
+	for collection := range p.published {
+		if _, ok := desired[collection]; !ok {
+			desired[collection] = nil
+		}
+	}
+
+	for collection, shifts := range desired {
+		logger := p.Logger.With(zap.String("calendar", collection))
+		if err := p.sync(ctx, collection, shifts, from); err != nil {
+			logger.Errorw("Cannot publish on-call shifts to CalDAV calendar", zap.Error(err))
+			continue
+		}
+
+		if len(p.published[collection]) == 0 && shifts == nil {
+			delete(p.published, collection)
+		}
+		logger.Debugw("Published on-call shifts to CalDAV calendar", zap.Int("shifts", len(shifts)))
+	}
+}
+
+// desiredShifts returns the shifts between from and to of all contacts with a CalDAV address, per calendar collection
+// URL and resource name.
+func (p *Publisher) desiredShifts(from, to time.Time) map[string]map[string]*caldavShift {
+	p.RuntimeConfig.RLock()
+	defer p.RuntimeConfig.RUnlock()
+
+	calendars := make(map[int64]string)
+	for _, contact := range p.RuntimeConfig.Contacts {
+		for _, addr := range contact.Addresses {
+			if addr.Type == AddressTypeCalDAV && addr.Address != "" {
+				calendars[contact.ID] = addr.Address
+			}
+		}
+	}
+
+	desired := make(map[string]map[string]*caldavShift)
+	for _, schedule := range p.RuntimeConfig.Schedules {
+		for _, ev := range ScheduleEvents(schedule, from, to, caldavStep) {
+			var contactID, start int64
+			if _, err := fmt.Sscanf(ev.UID, "schedule-%d-contact-%d-%d@", new(int64), &contactID, &start); err != nil {
+				continue
+			}
+
+			collection, ok := calendars[contactID]
+			if !ok {
+				continue
+			}
+
+			ev.Summary = "On call: " + schedule.Name
+			ev.Attendees = nil
+
+			if desired[collection] == nil {
+				desired[collection] = make(map[string]*caldavShift)
+			}
+			desired[collection][caldavResourceName(ev.UID)] = &caldavShift{
+				event:       ev,
+				fingerprint: fmt.Sprintf("%d-%d-%s", ev.Start.Unix(), ev.End.Unix(), ev.Summary),
+			}
+		}
+	}
+
+	return desired
+}
+
+// sync writes the new and changed shifts to the collection and deletes the upcoming resources no longer desired.
+func (p *Publisher) sync(ctx context.Context, collection string, shifts map[string]*caldavShift, from time.Time) error {
+	published, ok := p.published[collection]
+	if !ok {
+		names, err := p.list(ctx, collection)
+		if err != nil {
+			return err
+		}
+
+		published = make(map[string]string)
+		for _, name := range names {
+			published[name] = ""
+		}
+		p.published[collection] = published
+	}
+
+	for name, shift := range shifts {
+		if !shift.event.Start.Equal(from) {
+			continue
+		}
+
+		// The shift is in progress since before the computed period, thus continue the resource published for it.
+		if prev, ok := continuedResource(published, name, from); ok {
+			start, _ := caldavResourceStart(prev)
+			shift.event.Start = start
+			shift.event.UID = strings.TrimSuffix(strings.TrimPrefix(prev, caldavResourcePrefix), ".ics") +
+				"@icinga-notifications"
+			shift.fingerprint = fmt.Sprintf("%d-%d-%s", start.Unix(), shift.event.End.Unix(), shift.event.Summary)
+
+			delete(shifts, name)
+			shifts[prev] = shift
+		}
+	}
+
+	for name, shift := range shifts {
+		if published[name] == shift.fingerprint {
+			continue
+		}
+
+		var body bytes.Buffer
+		if err := Write(&body, shift.event.Summary, []*Event{shift.event}); err != nil {
+			return err
+		}
+		if err := p.do(ctx, http.MethodPut, collection, name, &body); err != nil {
+			return err
+		}
+		published[name] = shift.fingerprint
+	}
+
+	for name := range published {
+		if _, ok := shifts[name]; ok {
+			continue
+		}
+
+		if start, ok := caldavResourceStart(name); ok && start.Before(from) {
+			// Shifts before the computed period are history and thus kept, only forgetting about them.
+			delete(published, name)
+			continue
+		}
+
+		if err := p.do(ctx, http.MethodDelete, collection, name, nil); err != nil {
+			return err
+		}
+		delete(published, name)
+	}
+
+	return nil
+}
+
+// list returns the names of the resources in the collection created by the Publisher.
+func (p *Publisher) list(ctx context.Context, collection string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", collection, strings.NewReader(caldavPropfind))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.SetBasicAuth(p.Username, p.Password)
+
+	res, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("cannot list calendar resources, unexpected HTTP status %q", res.Status)
+	}
+
+	var multistatus struct {
+		Responses []struct {
+			Href string `xml:"DAV: href"`
+		} `xml:"DAV: response"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(res.Body, maxFeedSize)).Decode(&multistatus); err != nil {
+		return nil, fmt.Errorf("cannot parse PROPFIND response: %w", err)
+	}
+
+	var names []string
+	for _, r := range multistatus.Responses {
+		href, err := url.PathUnescape(r.Href)
+		if err != nil {
+			continue
+		}
+
+		if name := path.Base(href); strings.HasPrefix(name, caldavResourcePrefix) {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// do performs a PUT or DELETE request for the named resource within the collection.
+func (p *Publisher) do(ctx context.Context, method, collection, name string, body io.Reader) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	target, err := url.JoinPath(collection, name)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	}
+	req.SetBasicAuth(p.Username, p.Password)
+
+	res, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+
+	if res.StatusCode/100 != 2 && !(method == http.MethodDelete && res.StatusCode == http.StatusNotFound) {
+		return fmt.Errorf("cannot %s %q, unexpected HTTP status %q", method, name, res.Status)
+	}
+
+	return nil
+}
+
+// continuedResource returns the published resource of the same schedule and contact as the named one which started
+// before from and did not end before from, i.e., the resource of the same shift as seen by an earlier run.
+//
+// Resources whose end is unknown, as they were published before a restart, are assumed to be still in progress.
+func continuedResource(published map[string]string, name string, from time.Time) (string, bool) {
+	prefix := name[:strings.LastIndexByte(name, '-')+1]
+
+	var latest string
+	var latestStart time.Time
+	for other, fingerprint := range published {
+		if !strings.HasPrefix(other, prefix) {
+			continue
+		}
+
+		start, ok := caldavResourceStart(other)
+		if !ok || !start.Before(from) || start.Before(latestStart) {
+			continue
+		}
+
+		var end int64
+		if _, err := fmt.Sscanf(fingerprint, "%d-%d-", new(int64), &end); err == nil && time.Unix(end, 0).Before(from) {
+			continue
+		}
+
+		latest, latestStart = other, start
+	}
+
+	return latest, latest != ""
+}
+
+// caldavResourceName derives the resource name from an event's UID as created by ScheduleEvents.
+func caldavResourceName(uid string) string {
+	name, _, _ := strings.Cut(uid, "@")
+	return caldavResourcePrefix + name + ".ics"
+}
+
+// caldavResourceStart returns the start of the shift encoded in the resource name, see caldavResourceName.
+func caldavResourceStart(name string) (time.Time, bool) {
+	name = strings.TrimSuffix(name, ".ics")
+	unix, err := strconv.ParseInt(name[strings.LastIndexByte(name, '-')+1:], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(unix, 0), true
+}
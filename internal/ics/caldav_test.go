@@ -0,0 +1,136 @@
+package ics
+
+import (
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCalDAV is a minimal CalDAV collection storing the calendar resources by name.
+type fakeCalDAV struct {
+	mu        sync.Mutex
+	resources map[string]string
+}
+
+func (c *fakeCalDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := path.Base(r.URL.Path)
+	switch r.Method {
+	case "PROPFIND":
+		w.WriteHeader(http.StatusMultiStatus)
+		_, _ = fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+		_, _ = fmt.Fprintf(w, `<d:response><d:href>%s</d:href></d:response>`, r.URL.Path)
+		for name := range c.resources {
+			_, _ = fmt.Fprintf(w, `<d:response><d:href>%s</d:href></d:response>`, path.Join(r.URL.Path, name))
+		}
+		_, _ = fmt.Fprint(w, `</d:multistatus>`)
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		c.resources[name] = string(body)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		delete(c.resources, name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (c *fakeCalDAV) names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.resources))
+	for name := range c.resources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+func TestPublisher(t *testing.T) {
+	t.Parallel()
+
+	calendar := &fakeCalDAV{resources: map[string]string{"other.ics": "BEGIN:VCALENDAR"}}
+	server := httptest.NewServer(calendar)
+	defer server.Close()
+
+	jane := &recipient.Contact{FullName: "Jane Doe", Addresses: []*recipient.Address{
+		{Type: AddressTypeCalDAV, Address: server.URL + "/calendars/jane/on-call/"},
+	}}
+	jane.ID = 1
+	john := &recipient.Contact{FullName: "John Doe"}
+	john.ID = 2
+
+	now := time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC)
+	shift := func(contact *recipient.Contact, from, to time.Duration) *recipient.ImportedShift {
+		entry := &timeperiod.Entry{
+			StartTime: types.UnixMilli(now.Add(from)),
+			EndTime:   types.UnixMilli(now.Add(to)),
+			Timezone:  "UTC",
+		}
+		require.NoError(t, entry.Init())
+		return &recipient.ImportedShift{Contact: contact, Entry: entry}
+	}
+
+	schedule := &recipient.Schedule{Name: "On-Call"}
+	schedule.ID = 23
+	schedule.SetImportedShifts([]*recipient.ImportedShift{
+		shift(jane, -72*time.Hour, 2*time.Hour), // in progress since before the computed period
+		shift(john, 2*time.Hour, 4*time.Hour),
+		shift(jane, 4*time.Hour, 6*time.Hour),
+	})
+
+	runtimeConfig := &config.RuntimeConfig{}
+	runtimeConfig.Contacts = map[int64]*recipient.Contact{jane.ID: jane, john.ID: john}
+	runtimeConfig.Schedules = map[int64]*recipient.Schedule{schedule.ID: schedule}
+
+	p := &Publisher{
+		RuntimeConfig: runtimeConfig,
+		Logger:        logging.NewLogger(zaptest.NewLogger(t).Sugar(), time.Hour),
+		Horizon:       24 * time.Hour,
+		Client:        server.Client(),
+	}
+
+	from := time.Date(2024, 1, 9, 0, 0, 0, 0, time.UTC)
+	later := now.Add(4 * time.Hour).Unix()
+	p.publishAll(context.Background(), now)
+	assert.Equal(t, []string{
+		fmt.Sprintf("icinga-notifications-schedule-23-contact-1-%d.ics", from.Unix()),
+		fmt.Sprintf("icinga-notifications-schedule-23-contact-1-%d.ics", later),
+		"other.ics",
+	}, calendar.names(), "only Jane's shifts should be published")
+	assert.Contains(t, calendar.resources[calendar.names()[1]], "SUMMARY:On call: On-Call")
+
+	// A day later, the shift in progress continues its resource, even after a restart.
+	p.published = nil
+	p.publishAll(context.Background(), now.Add(24*time.Hour))
+	assert.Equal(t, fmt.Sprintf("icinga-notifications-schedule-23-contact-1-%d.ics", from.Unix()), calendar.names()[0])
+	assert.Len(t, calendar.names(), 3)
+
+	// Upcoming shifts removed from the schedule are deleted, while past ones are kept.
+	schedule.SetImportedShifts([]*recipient.ImportedShift{shift(jane, -72*time.Hour, 2*time.Hour)})
+	p.publishAll(context.Background(), now.Add(24*time.Hour))
+	assert.Equal(t, []string{
+		fmt.Sprintf("icinga-notifications-schedule-23-contact-1-%d.ics", from.Unix()),
+		"other.ics",
+	}, calendar.names())
+	assert.True(t, strings.HasPrefix(calendar.resources[calendar.names()[0]], "BEGIN:VCALENDAR"))
+}
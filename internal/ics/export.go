@@ -0,0 +1,64 @@
+package ics
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"slices"
+	"time"
+)
+
+// ScheduleEvents computes the on-call shifts of a schedule between from and to as events, one for each contact's
+// contiguous shift.
+//
+// The schedule is sampled in the given step, so shifts are only as accurate as this granularity. The schedule, and
+// thus the RuntimeConfig, must be locked for reading.
+func ScheduleEvents(schedule *recipient.Schedule, from, to time.Time, step time.Duration) []*Event {
+	var events []*Event
+	open := make(map[int64]*Event)
+
+	closeShift := func(contactID int64, end time.Time) {
+		ev := open[contactID]
+		ev.End = end
+		events = append(events, ev)
+		delete(open, contactID)
+	}
+
+	for t := from; t.Before(to); t = t.Add(step) {
+		active := make(map[int64]struct{})
+		for _, contact := range schedule.GetContactsAt(t) {
+			active[contact.ID] = struct{}{}
+			if _, ok := open[contact.ID]; ok {
+				continue
+			}
+
+			ev := &Event{
+				UID:     fmt.Sprintf("schedule-%d-contact-%d-%d@icinga-notifications", schedule.ID, contact.ID, t.Unix()),
+				Summary: contact.FullName,
+				Start:   t,
+			}
+			for _, addr := range contact.Addresses {
+				if addr.Type == "email" {
+					ev.Attendees = append(ev.Attendees, addr.Address)
+				}
+			}
+			open[contact.ID] = ev
+		}
+
+		for contactID := range open {
+			if _, ok := active[contactID]; !ok {
+				closeShift(contactID, t)
+			}
+		}
+	}
+
+	for contactID := range open {
+		closeShift(contactID, to)
+	}
+
+	slices.SortFunc(events, func(a, b *Event) int {
+		return cmp.Or(a.Start.Compare(b.Start), cmp.Compare(a.UID, b.UID))
+	})
+
+	return events
+}
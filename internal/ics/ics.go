@@ -0,0 +1,308 @@
+// Package ics implements the subset of iCalendar, RFC 5545, required to import and export on-call shifts.
+//
+// Only VEVENT components are supported. Their start and end time, an optional RRULE, the SUMMARY and the ATTENDEE
+// properties are evaluated, while everything else is ignored.
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Event is a single VEVENT, possibly recurring through its RRule.
+type Event struct {
+	UID     string
+	Summary string
+
+	// Start and End of the event, or its first occurrence for recurring events.
+	Start time.Time
+	End   time.Time
+
+	// RRule is the raw recurrence rule without the "RRULE:" prefix, if present.
+	RRule string
+
+	// Attendees lists the attendees' email addresses, without the "mailto:" prefix.
+	Attendees []string
+}
+
+// Parse reads all VEVENT components from an iCalendar stream.
+//
+// Times given in a TZID are loaded in this location, times without any zone information are treated as UTC. An all-day
+// event, having a DATE value, spans whole days in UTC.
+func Parse(r io.Reader) ([]*Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events []*Event
+		cur    *Event
+		hasEnd bool
+		dur    time.Duration
+	)
+
+	for i, line := range lines {
+		name, params, value, ok := parseContentLine(line)
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid content line %q", i+1, line)
+		}
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			cur, hasEnd, dur = &Event{}, false, 0
+
+		case cur == nil:
+			continue
+
+		case name == "END" && value == "VEVENT":
+			if cur.Start.IsZero() {
+				return nil, fmt.Errorf("line %d: VEVENT %q without DTSTART", i+1, cur.UID)
+			}
+			if !hasEnd {
+				if dur <= 0 {
+					dur = 24 * time.Hour
+				}
+				cur.End = cur.Start.Add(dur)
+			}
+			if !cur.Start.Before(cur.End) {
+				return nil, fmt.Errorf("line %d: VEVENT %q does not end after its start", i+1, cur.UID)
+			}
+
+			events = append(events, cur)
+			cur = nil
+
+		case name == "UID":
+			cur.UID = unescapeText(value)
+
+		case name == "SUMMARY":
+			cur.Summary = unescapeText(value)
+
+		case name == "DTSTART":
+			if cur.Start, err = parseTime(value, params); err != nil {
+				return nil, fmt.Errorf("line %d: invalid DTSTART: %w", i+1, err)
+			}
+
+		case name == "DTEND":
+			if cur.End, err = parseTime(value, params); err != nil {
+				return nil, fmt.Errorf("line %d: invalid DTEND: %w", i+1, err)
+			}
+			hasEnd = true
+
+		case name == "DURATION":
+			if dur, err = parseDuration(value); err != nil {
+				return nil, fmt.Errorf("line %d: invalid DURATION: %w", i+1, err)
+			}
+
+		case name == "RRULE":
+			cur.RRule = value
+
+		case name == "ATTENDEE":
+			if addr, ok := cutPrefixFold(value, "mailto:"); ok {
+				cur.Attendees = append(cur.Attendees, addr)
+			}
+		}
+	}
+
+	return events, nil
+}
+
+// Write encodes the events as an iCalendar stream with a single VCALENDAR.
+func Write(w io.Writer, calendarName string, events []*Event) error {
+	bw := bufio.NewWriter(w)
+	writeLine := func(line string) {
+		// Lines must not be longer than 75 octets, excluding the line break. Folded lines start with a single space.
+		for len(line) > 75 {
+			cut := 75
+			for cut > 0 && !isRuneStart(line[cut]) {
+				cut--
+			}
+			_, _ = bw.WriteString(line[:cut] + "\r\n")
+			line = " " + line[cut:]
+		}
+		_, _ = bw.WriteString(line + "\r\n")
+	}
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//Icinga GmbH//Icinga Notifications//EN")
+	writeLine("CALSCALE:GREGORIAN")
+	writeLine("X-WR-CALNAME:" + escapeText(calendarName))
+
+	stamp := time.Now().UTC().Format(utcFormat)
+	for _, ev := range events {
+		writeLine("BEGIN:VEVENT")
+		writeLine("UID:" + escapeText(ev.UID))
+		writeLine("DTSTAMP:" + stamp)
+		writeLine("DTSTART:" + ev.Start.UTC().Format(utcFormat))
+		writeLine("DTEND:" + ev.End.UTC().Format(utcFormat))
+		writeLine("SUMMARY:" + escapeText(ev.Summary))
+		if ev.RRule != "" {
+			writeLine("RRULE:" + ev.RRule)
+		}
+		for _, attendee := range ev.Attendees {
+			writeLine("ATTENDEE:mailto:" + attendee)
+		}
+		writeLine("END:VEVENT")
+	}
+
+	writeLine("END:VCALENDAR")
+	return bw.Flush()
+}
+
+const (
+	utcFormat   = "20060102T150405Z"
+	localFormat = "20060102T150405"
+	dateFormat  = "20060102"
+)
+
+// unfold reads all content lines, joining folded lines.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+		} else {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// parseContentLine splits a content line into its upper-cased name, its parameters and its value.
+func parseContentLine(line string) (name string, params map[string]string, value string, ok bool) {
+	// The value starts after the first colon not being part of a quoted parameter value.
+	inQuotes := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if c == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", false
+	}
+
+	value = line[colon+1:]
+	parts := strings.Split(line[:colon], ";")
+	name = strings.ToUpper(parts[0])
+	params = make(map[string]string)
+	for _, param := range parts[1:] {
+		k, v, _ := strings.Cut(param, "=")
+		params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+
+	return name, params, value, name != ""
+}
+
+// parseTime parses a DATE or DATE-TIME value, respecting both the VALUE and the TZID parameter.
+func parseTime(value string, params map[string]string) (time.Time, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(dateFormat) {
+		return time.ParseInLocation(dateFormat, value, time.UTC)
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(utcFormat, value)
+	}
+
+	loc := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	return time.ParseInLocation(localFormat, value, loc)
+}
+
+// parseDuration parses a DURATION value like "PT8H" or "P1DT12H", ignoring any sign.
+func parseDuration(value string) (time.Duration, error) {
+	s := strings.TrimLeft(value, "+-")
+	s, ok := strings.CutPrefix(s, "P")
+	if !ok {
+		return 0, fmt.Errorf("duration %q does not start with P", value)
+	}
+
+	var (
+		d      time.Duration
+		num    int
+		inTime bool
+		digits bool
+	)
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			num = num*10 + int(c-'0')
+			digits = true
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		}
+
+		if !digits {
+			return 0, fmt.Errorf("duration %q has a unit without a number", value)
+		}
+
+		switch {
+		case c == 'W' && !inTime:
+			d += time.Duration(num) * 7 * 24 * time.Hour
+		case c == 'D' && !inTime:
+			d += time.Duration(num) * 24 * time.Hour
+		case c == 'H' && inTime:
+			d += time.Duration(num) * time.Hour
+		case c == 'M' && inTime:
+			d += time.Duration(num) * time.Minute
+		case c == 'S' && inTime:
+			d += time.Duration(num) * time.Second
+		default:
+			return 0, fmt.Errorf("duration %q has an unexpected %q", value, c)
+		}
+		num, digits = 0, false
+	}
+
+	return d, nil
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// escapeText escapes a TEXT value.
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// unescapeText reverts escapeText.
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
+
+// cutPrefixFold is strings.CutPrefix, but case-insensitive.
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+// isRuneStart reports whether the byte could be the first byte of an encoded UTF-8 rune.
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
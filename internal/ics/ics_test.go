@@ -0,0 +1,161 @@
+package ics
+
+import (
+	"bytes"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	feed := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Berlin",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:utc@example.com",
+		"DTSTART:20240101T080000Z",
+		"DTEND:20240101T160000Z",
+		"SUMMARY:Jane Doe\\, primary",
+		"ATTENDEE;CN=Jane Doe:MAILTO:jane@example.com",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:tzid@example.com",
+		"DTSTART;TZID=Europe/Berlin:20240101T080000",
+		"DURATION:PT8H30M",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO",
+		"SUMMARY:a very long summary being folded over multiple lines as it exceeds the limit of",
+		"  75 octets",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:date@example.com",
+		"DTSTART;VALUE=DATE:20240102",
+		"SUMMARY:jdoe",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := Parse(strings.NewReader(feed))
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, &Event{
+		UID:       "utc@example.com",
+		Summary:   "Jane Doe, primary",
+		Start:     time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC),
+		End:       time.Date(2024, 1, 1, 16, 0, 0, 0, time.UTC),
+		Attendees: []string{"jane@example.com"},
+	}, events[0])
+
+	assert.Equal(t, "tzid@example.com", events[1].UID)
+	assert.Equal(t, "a very long summary being folded over multiple lines as it exceeds the limit of 75 octets",
+		events[1].Summary)
+	assert.True(t, time.Date(2024, 1, 1, 8, 0, 0, 0, berlin).Equal(events[1].Start))
+	assert.Equal(t, "Europe/Berlin", events[1].Start.Location().String())
+	assert.Equal(t, 8*time.Hour+30*time.Minute, events[1].End.Sub(events[1].Start))
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO", events[1].RRule)
+
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), events[2].Start)
+	assert.Equal(t, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), events[2].End)
+
+	t.Run("Invalid", func(t *testing.T) {
+		t.Parallel()
+
+		for name, feed := range map[string]string{
+			"MissingColon":    "BEGIN:VEVENT\nDTSTART\nEND:VEVENT",
+			"MissingStart":    "BEGIN:VEVENT\nUID:foo\nEND:VEVENT",
+			"EndBeforeStart":  "BEGIN:VEVENT\nDTSTART:20240101T080000Z\nDTEND:20240101T070000Z\nEND:VEVENT",
+			"InvalidDuration": "BEGIN:VEVENT\nDTSTART:20240101T080000Z\nDURATION:8H\nEND:VEVENT",
+			"UnknownTimezone": "BEGIN:VEVENT\nDTSTART;TZID=Nowhere/Void:20240101T080000\nEND:VEVENT",
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				_, err := Parse(strings.NewReader(feed))
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	events := []*Event{{
+		UID:       "shift-1@icinga-notifications",
+		Summary:   "Doe, Jane; on-call\n" + strings.Repeat("ä", 50),
+		Start:     time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC),
+		End:       time.Date(2024, 1, 1, 16, 0, 0, 0, time.UTC),
+		Attendees: []string{"jane@example.com"},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "On-Call", events))
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75, "line %q must be folded", line)
+	}
+
+	parsed, err := Parse(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, events, parsed)
+}
+
+func TestScheduleEvents(t *testing.T) {
+	t.Parallel()
+
+	jane := &recipient.Contact{FullName: "Jane Doe", Addresses: []*recipient.Address{{Type: "email", Address: "jane@example.com"}}}
+	jane.ID = 1
+	john := &recipient.Contact{FullName: "John Doe"}
+	john.ID = 2
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	shift := func(contact *recipient.Contact, from, to time.Duration) *recipient.ImportedShift {
+		entry := &timeperiod.Entry{
+			StartTime: types.UnixMilli(start.Add(from)),
+			EndTime:   types.UnixMilli(start.Add(to)),
+			Timezone:  "UTC",
+		}
+		require.NoError(t, entry.Init())
+		return &recipient.ImportedShift{Contact: contact, Entry: entry}
+	}
+
+	schedule := &recipient.Schedule{Name: "On-Call"}
+	schedule.ID = 23
+	schedule.SetImportedShifts([]*recipient.ImportedShift{
+		shift(jane, 0, 8*time.Hour),
+		shift(john, 6*time.Hour, 12*time.Hour),
+		shift(jane, 12*time.Hour, 14*time.Hour),
+	})
+
+	events := ScheduleEvents(schedule, start, start.Add(24*time.Hour), time.Hour)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, "Jane Doe", events[0].Summary)
+	assert.Equal(t, []string{"jane@example.com"}, events[0].Attendees)
+	assert.Equal(t, start, events[0].Start)
+	assert.Equal(t, start.Add(8*time.Hour), events[0].End)
+
+	assert.Equal(t, "John Doe", events[1].Summary)
+	assert.Empty(t, events[1].Attendees)
+	assert.Equal(t, start.Add(6*time.Hour), events[1].Start)
+	assert.Equal(t, start.Add(12*time.Hour), events[1].End)
+
+	assert.Equal(t, "Jane Doe", events[2].Summary)
+	assert.Equal(t, start.Add(12*time.Hour), events[2].Start)
+	assert.Equal(t, start.Add(14*time.Hour), events[2].End)
+
+	assert.NotEqual(t, events[0].UID, events[2].UID, "UIDs must be unique")
+}
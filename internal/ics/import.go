@@ -0,0 +1,162 @@
+package ics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxFeedSize limits the size of a single iCalendar feed to be imported.
+const maxFeedSize = 16 << 20
+
+// Importer periodically fetches the iCalendar feeds of all schedules and imports their events as on-call shifts.
+type Importer struct {
+	RuntimeConfig *config.RuntimeConfig
+	Logger        *logging.Logger
+	Interval      time.Duration
+	Client        *http.Client
+}
+
+// Run the import loop until the context is done, starting with an immediate import.
+func (imp *Importer) Run(ctx context.Context) {
+	ticker := time.NewTicker(imp.Interval)
+	defer ticker.Stop()
+
+	for {
+		imp.importAll(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// importAll imports the feeds of all schedules having an ics_feed_url.
+//
+// Shifts of schedules whose feed was removed are cleared. If a feed cannot be fetched, the previously imported shifts
+// are kept to not lose on-call information due to a temporary outage.
+func (imp *Importer) importAll(ctx context.Context) {
+	feeds := make(map[*recipient.Schedule]string)
+
+	imp.RuntimeConfig.RLock()
+	for _, schedule := range imp.RuntimeConfig.Schedules {
+		if schedule.IcsFeedURL.Valid && schedule.IcsFeedURL.String != "" {
+			feeds[schedule] = schedule.IcsFeedURL.String
+		} else if len(schedule.GetImportedShifts()) > 0 {
+			schedule.SetImportedShifts(nil)
+		}
+	}
+	imp.RuntimeConfig.RUnlock()
+
+	for schedule, feedURL := range feeds {
+		logger := imp.Logger.With(zap.Object("schedule", schedule))
+
+		events, err := imp.fetch(ctx, feedURL)
+		if err != nil {
+			logger.Errorw("Cannot fetch iCalendar feed, keeping previously imported shifts", zap.Error(err))
+			continue
+		}
+
+		shifts := imp.toShifts(events, logger)
+		schedule.SetImportedShifts(shifts)
+		logger.Debugw("Imported iCalendar feed", zap.Int("events", len(events)), zap.Int("shifts", len(shifts)))
+	}
+}
+
+// fetch retrieves and parses an iCalendar feed.
+func (imp *Importer) fetch(ctx context.Context, feedURL string) ([]*Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := imp.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %q", res.Status)
+	}
+
+	return Parse(io.LimitReader(res.Body, maxFeedSize))
+}
+
+// toShifts assigns each event to a contact and converts it into an ImportedShift.
+//
+// An event's contact is found by its attendees' email addresses first, falling back to its summary matching either a
+// contact's username or full name. Events without any matching contact are skipped.
+func (imp *Importer) toShifts(events []*Event, logger *zap.SugaredLogger) []*recipient.ImportedShift {
+	imp.RuntimeConfig.RLock()
+	defer imp.RuntimeConfig.RUnlock()
+
+	var shifts []*recipient.ImportedShift
+	for _, ev := range events {
+		logger := logger.With(zap.String("uid", ev.UID), zap.String("summary", ev.Summary))
+
+		contact := imp.findContact(ev)
+		if contact == nil {
+			logger.Debug("Skipping iCalendar event without matching contact")
+			continue
+		}
+
+		entry := &timeperiod.Entry{
+			StartTime: types.UnixMilli(ev.Start),
+			EndTime:   types.UnixMilli(ev.End),
+			Timezone:  ev.Start.Location().String(),
+			RRule:     sql.NullString{String: ev.RRule, Valid: ev.RRule != ""},
+		}
+		if err := entry.Init(); err != nil {
+			logger.Warnw("Skipping invalid iCalendar event", zap.Error(err))
+			continue
+		}
+
+		shifts = append(shifts, &recipient.ImportedShift{Contact: contact, Entry: entry})
+	}
+
+	return shifts
+}
+
+// findContact returns the contact an event belongs to or nil. The RuntimeConfig must be locked for reading.
+func (imp *Importer) findContact(ev *Event) *recipient.Contact {
+	for _, attendee := range ev.Attendees {
+		for _, contact := range imp.RuntimeConfig.Contacts {
+			for _, addr := range contact.Addresses {
+				if addr.Type == "email" && strings.EqualFold(addr.Address, attendee) {
+					return contact
+				}
+			}
+		}
+	}
+
+	summary := strings.TrimSpace(ev.Summary)
+	if summary == "" {
+		return nil
+	}
+	if contact := imp.RuntimeConfig.GetContact(summary); contact != nil {
+		return contact
+	}
+	for _, contact := range imp.RuntimeConfig.Contacts {
+		if strings.EqualFold(contact.FullName, summary) {
+			return contact
+		}
+	}
+
+	return nil
+}
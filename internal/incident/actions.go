@@ -0,0 +1,232 @@
+package incident
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/acklink"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"go.uber.org/zap"
+	"slices"
+	"time"
+)
+
+// Acknowledge processes an acknowledgement of this incident by the contact of the given username, making them a manager
+// of this incident. Acknowledging an incident already managed by this contact has no effect.
+//
+// The acknowledgement is written back to the object's Icinga 2 source, if enabled, see writeBack.
+func (i *Incident) Acknowledge(ctx context.Context, username, comment string) error {
+	if !i.isOpen() {
+		return ErrIncidentClosed
+	}
+
+	err := i.ProcessEvent(ctx, &event.Event{
+		Time:     time.Now(),
+		SourceId: i.Object.SourceID,
+		Type:     event.TypeAcknowledgementSet,
+		Username: username,
+		Message:  comment,
+	})
+	if err != nil {
+		return err
+	}
+
+	if comment == "" {
+		// Icinga 2 requires a comment for each acknowledgement.
+		comment = "Acknowledged via Icinga Notifications"
+	}
+	i.writeBack(ctx, "acknowledge-problem", func(ctx context.Context, actions config.Icinga2Actions) error {
+		return actions.AcknowledgeProblem(ctx, i.Object.Tags, username, comment)
+	})
+
+	return nil
+}
+
+// Close closes this incident by processing a synthetic OK state event, regardless of the object's actual state.
+//
+// Like for a recovery reported by the source, the incident's managers and subscribers are notified. If the source
+// reports a problem of the object afterwards, a new incident is opened.
+func (i *Incident) Close(ctx context.Context, reason string) error {
+	if !i.isOpen() {
+		return ErrIncidentClosed
+	}
+
+	err := i.ProcessEvent(ctx, &event.Event{
+		Time:     time.Now(),
+		SourceId: i.Object.SourceID,
+		Type:     event.TypeState,
+		Severity: event.SeverityOK,
+		Message:  fmt.Sprintf("Incident closed manually: %s", reason),
+	})
+	if errors.Is(err, event.ErrSuperfluousStateChange) {
+		// The incident was closed in the meantime.
+		return ErrIncidentClosed
+	}
+
+	return err
+}
+
+// ErrNoEscalation is returned by Escalate if all escalations of the incident's rules have already been triggered.
+var ErrNoEscalation = errors.New("incident has no further escalations")
+
+// Snooze postpones renotifications and time-based escalations of this incident for the given duration, e.g., while the
+// contact of the given username is looking into it, and notifies the incident's recipients about it.
+//
+// In contrast to Acknowledge, the contact does not become a manager. State changes are processed as usual. If enabled,
+// a downtime covering the snooze is scheduled at the object's Icinga 2 source, see writeBack.
+func (i *Incident) Snooze(ctx context.Context, username string, d time.Duration) error {
+	if !i.isOpen() {
+		return ErrIncidentClosed
+	}
+
+	now := time.Now()
+	until := now.Add(d)
+	message := fmt.Sprintf("Incident snoozed by %s for %v", username, d)
+	err := i.ProcessEvent(ctx, &event.Event{
+		Time:     now,
+		SourceId: i.Object.SourceID,
+		Type:     event.TypeCustom,
+		Username: username,
+		Message:  message,
+	})
+	if err != nil {
+		return err
+	}
+
+	i.writeBack(ctx, "schedule-downtime", func(ctx context.Context, actions config.Icinga2Actions) error {
+		return actions.ScheduleDowntime(ctx, i.Object.Tags, username, message, now, until)
+	})
+
+	i.Lock()
+	defer i.Unlock()
+
+	i.runtimeConfig.RLock()
+	defer i.runtimeConfig.RUnlock()
+
+	i.logger.Infow("Snoozed incident", zap.String("username", username), zap.Time("until", until))
+	i.snoozedUntil = until
+	i.scheduleRenotification()
+
+	return nil
+}
+
+// Escalate triggers all escalations of this incident's rules which have not been triggered yet on behalf of the contact
+// of the given username, regardless of their conditions, and notifies their recipients. This also ends a Snooze.
+//
+// If there are no such escalations left, ErrNoEscalation is returned.
+func (i *Incident) Escalate(ctx context.Context, username string) error {
+	i.Lock()
+	defer i.Unlock()
+
+	i.runtimeConfig.RLock()
+	defer i.runtimeConfig.RUnlock()
+
+	if i.StartedAt.Time().IsZero() || !i.RecoveredAt.Time().IsZero() {
+		return ErrIncidentClosed
+	}
+
+	var escalations []*rule.Escalation
+	for rID := range i.Rules {
+		if r := i.runtimeConfig.Rules[rID]; r != nil {
+			for _, escalation := range r.Escalations {
+				if _, ok := i.EscalationState[escalation.ID]; !ok && !slices.Contains(escalations, escalation) {
+					escalations = append(escalations, escalation)
+				}
+			}
+		}
+	}
+	if len(escalations) == 0 {
+		return ErrNoEscalation
+	}
+
+	ev := &event.Event{
+		Time:     time.Now(),
+		SourceId: i.Object.SourceID,
+		Type:     event.TypeCustom,
+		Username: username,
+		Message:  fmt.Sprintf("Incident escalated manually by %s", username),
+	}
+	ev.EnsureTraceID()
+	ctx = event.ContextWithTraceID(ctx, ev.TraceID)
+
+	i.snoozedUntil = time.Time{}
+	defer i.scheduleRenotification()
+
+	if err := i.notifyEscalations(ctx, ev, escalations); err != nil {
+		return err
+	}
+
+	i.logger.Infow("Escalated incident manually", zap.String("username", username),
+		zap.Int("escalations", len(escalations)))
+
+	return nil
+}
+
+// writeBack performs an action in the Icinga 2 source of this incident's object in the background, keeping both
+// systems consistent, if enabled for the source, see config.Source.Icinga2WriteBack.
+//
+// As the action was already taken in Icinga Notifications, a failure is only logged.
+func (i *Incident) writeBack(
+	ctx context.Context, action string, fn func(context.Context, config.Icinga2Actions) error,
+) {
+	i.runtimeConfig.RLock()
+	var actions config.Icinga2Actions
+	if src := i.runtimeConfig.Sources[i.Object.SourceID]; src != nil {
+		actions = src.Icinga2Actions
+	}
+	i.runtimeConfig.RUnlock()
+
+	if actions == nil || !inflight.begin() {
+		return
+	}
+
+	logger := i.logger.With(zap.String("action", action))
+	ctx, cancel := inflight.detach(ctx)
+	go func() {
+		defer inflight.end()
+		defer cancel()
+
+		if err := fn(ctx, actions); err != nil {
+			logger.Warnw("Cannot write action back to Icinga 2", zap.Error(err))
+			return
+		}
+		logger.Info("Wrote action back to Icinga 2")
+	}()
+}
+
+// isOpen checks whether this incident was neither closed in the meantime nor is just being created.
+func (i *Incident) isOpen() bool {
+	i.Lock()
+	defer i.Unlock()
+
+	return !i.StartedAt.Time().IsZero() && i.RecoveredAt.Time().IsZero()
+}
+
+// acknowledgeURL returns a signed link allowing the contact to acknowledge this incident from the notification about
+// the given event, or an empty string if acknowledgement links are disabled or do not apply, see acklink.
+//
+// Links are only created for problem notifications and renotifications of open incidents to contacts not managing
+// them yet, as the acknowledgement is processed on behalf of their username. The incident must be locked by the caller.
+func (i *Incident) acknowledgeURL(contact *recipient.Contact, ev *event.Event) string {
+	isProblem := ev.Type == event.TypeState && ev.Severity > event.SeverityOK || ev.Type == event.TypeIncidentAge
+	if !isProblem || !i.RecoveredAt.Time().IsZero() || !contact.Username.Valid {
+		return ""
+	}
+	if state := i.Recipients[recipient.ToKey(contact)]; state != nil && state.Role == RoleManager {
+		return ""
+	}
+
+	signer, err := acklink.NewSigner(&daemon.Config().AckLinks)
+	if err != nil {
+		i.logger.Errorw("Cannot create acknowledgement link", zap.Error(err))
+		return ""
+	} else if signer == nil {
+		return ""
+	}
+
+	return signer.URL(i.Id, contact.ID, time.Now())
+}
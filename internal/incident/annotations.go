@@ -0,0 +1,78 @@
+package incident
+
+import (
+	"context"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"maps"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// annotationDirective matches a "name=value" directive within an acknowledgement comment, e.g., "ticket=INC1234" or
+// `eta="2h 30m"`. A directive must start at the beginning of the comment or after whitespace, not to match within URLs.
+var annotationDirective = regexp.MustCompile(`(?:^|\s)([a-zA-Z][\w.-]{0,254})=(?:"([^"]*)"|(\S+))`)
+
+// parseAnnotations returns the directives of the comment, mapping their lowercase names to their values. Punctuation
+// ending an unquoted value is dropped, as in "ticket=INC1234, working on it". If a name is used multiple times, its last
+// value wins.
+func parseAnnotations(comment string) map[string]string {
+	annotations := make(map[string]string)
+	for _, match := range annotationDirective.FindAllStringSubmatch(comment, -1) {
+		value := match[2]
+		if value == "" {
+			value = strings.TrimRight(match[3], ",;.")
+		}
+		if value != "" {
+			annotations[strings.ToLower(match[1])] = value
+		}
+	}
+
+	return annotations
+}
+
+// addAnnotations stores the directives of the acknowledgement event's comment as annotations of this incident,
+// replacing those of the same name, see parseAnnotations.
+//
+// Annotations are available to the notification templates as {{ .Annotations.ticket }}, for example, allowing all
+// further notifications to reference the ticket mentioned by the contact acknowledging the incident.
+func (i *Incident) addAnnotations(ctx context.Context, tx *sqlx.Tx, ev *event.Event) error {
+	parsed := parseAnnotations(ev.Message)
+	if len(parsed) == 0 {
+		return nil
+	}
+
+	rows := make([]*AnnotationRow, 0, len(parsed))
+	utils.IterateOrderedMap(parsed)(func(name, value string) bool {
+		rows = append(rows, &AnnotationRow{
+			IncidentID: i.Id,
+			Name:       name,
+			Value:      value,
+			EventID:    utils.ToDBInt(ev.ID),
+			ChangedAt:  types.UnixMilli(time.Now()),
+		})
+		return true
+	})
+
+	stmt, _ := i.db.BuildUpsertStmt(&AnnotationRow{})
+	if _, err := tx.NamedExecContext(ctx, stmt, rows); err != nil {
+		i.logger.Errorw("Failed to upsert incident annotations", zap.Error(err))
+		return err
+	}
+
+	// Replace the map instead of modifying it, so that a snapshot keeps the previous annotations.
+	annotations := maps.Clone(i.Annotations)
+	if annotations == nil {
+		annotations = make(map[string]string, len(parsed))
+	}
+	maps.Copy(annotations, parsed)
+	i.Annotations = annotations
+
+	i.logger.Infow("Annotated incident from acknowledgement comment", zap.Any("annotations", parsed))
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package incident
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		comment string
+		want    map[string]string
+	}{
+		{"Empty", "", map[string]string{}},
+		{"WithoutDirectives", "Looking into it, disk is full", map[string]string{}},
+		{"Directives", "ticket=INC1234 eta=2h", map[string]string{"ticket": "INC1234", "eta": "2h"}},
+		{"WithinText", "Working on it, ticket=INC1234, see chat", map[string]string{"ticket": "INC1234"}},
+		{"Quoted", `owner="Jane Doe" eta=2h`, map[string]string{"owner": "Jane Doe", "eta": "2h"}},
+		{"CaseInsensitive", "Ticket=INC1 TICKET=INC2", map[string]string{"ticket": "INC2"}},
+		{"IgnoresURLs", "see https://example.com/?ticket=INC1234", map[string]string{}},
+		{"IgnoresEmptyValues", `ticket= eta=2h note="" id=.`, map[string]string{"eta": "2h"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.want, parseAnnotations(test.comment))
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package incident
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"go.uber.org/zap"
+	"time"
+)
+
+// autoCloseCheckInterval is the interval in which all current incidents are checked for inactivity.
+const autoCloseCheckInterval = time.Minute
+
+// AutoCloseInactive periodically closes all current incidents not having received any event for too long, until the
+// context is done.
+//
+// The inactivity duration is taken from the incident's source, see config.Source.IncidentAutoCloseAfter, falling back
+// to the given daemon-wide default. This prevents incidents from lingering forever, e.g., after a source disappeared.
+func AutoCloseInactive(
+	ctx context.Context, logger *logging.Logger, runtimeConfig *config.RuntimeConfig, defaultAfter time.Duration,
+) {
+	ticker := time.NewTicker(autoCloseCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		for _, i := range GetCurrentIncidents() {
+			runtimeConfig.RLock()
+			after := defaultAfter
+			if source := runtimeConfig.Sources[i.Object.SourceID]; source != nil {
+				after = source.IncidentAutoCloseAfter(defaultAfter)
+			}
+			runtimeConfig.RUnlock()
+
+			if err := i.autoClose(ctx, after); err != nil {
+				logger.Errorw("Cannot automatically close inactive incident", zap.String("object", i.Object.DisplayName()),
+					zap.Int64("incident", i.ID()), zap.Error(err))
+			}
+		}
+	}
+}
+
+// autoCloseDue checks whether the incident did not receive any event within the given duration before now.
+func (i *Incident) autoCloseDue(now time.Time, after time.Duration) bool {
+	if after <= 0 || !i.RecoveredAt.Time().IsZero() || i.StartedAt.Time().IsZero() {
+		return false
+	}
+
+	lastEventAt := i.lastEventAt
+	if lastEventAt.IsZero() {
+		lastEventAt = i.StartedAt.Time()
+	}
+
+	return now.Sub(lastEventAt) >= after
+}
+
+// autoClose closes the incident by processing a synthetic OK state event if it has been inactive for the given duration.
+//
+// Closing the incident this way results in the regular closing history entries and notifies the incident's managers
+// and subscribers just like a recovery reported by the source.
+func (i *Incident) autoClose(ctx context.Context, after time.Duration) error {
+	now := time.Now()
+
+	i.Lock()
+	due := i.autoCloseDue(now, after)
+	i.Unlock()
+
+	if !due {
+		return nil
+	}
+
+	if !inflight.begin() {
+		// The incident is closed after the next start instead.
+		return nil
+	}
+	defer inflight.end()
+
+	i.logger.Infow("Closing incident due to inactivity", zap.Duration("after", after))
+
+	err := i.ProcessEvent(ctx, &event.Event{
+		Time:     now,
+		SourceId: i.Object.SourceID,
+		Type:     event.TypeState,
+		Severity: event.SeverityOK,
+		Message:  fmt.Sprintf("Incident closed automatically after not receiving any event for %v", after),
+	})
+	if errors.Is(err, event.ErrSuperfluousStateChange) {
+		// The source reported a recovery in the meantime.
+		return nil
+	}
+
+	return err
+}
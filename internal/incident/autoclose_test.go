@@ -0,0 +1,44 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestIncident_autoCloseDue(t *testing.T) {
+	t.Parallel()
+
+	startedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		lastEventAt time.Time
+		recovered   bool
+		now         time.Time
+		after       time.Duration
+		due         bool
+	}{
+		{"Disabled", time.Time{}, false, startedAt.Add(48 * time.Hour), 0, false},
+		{"RecentEvent", startedAt.Add(20 * time.Hour), false, startedAt.Add(30 * time.Hour), 24 * time.Hour, false},
+		{"Inactive", startedAt.Add(time.Hour), false, startedAt.Add(25 * time.Hour), 24 * time.Hour, true},
+		{"NoEventSinceStart", time.Time{}, false, startedAt.Add(24 * time.Hour), 24 * time.Hour, true},
+		{"AlreadyRecovered", time.Time{}, true, startedAt.Add(48 * time.Hour), 24 * time.Hour, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			i := NewIncident(nil, nil, nil, nil)
+			i.StartedAt = types.UnixMilli(startedAt)
+			i.lastEventAt = tt.lastEventAt
+			if tt.recovered {
+				i.RecoveredAt = types.UnixMilli(startedAt.Add(time.Hour))
+			}
+
+			assert.Equal(t, tt.due, i.autoCloseDue(tt.now, tt.after))
+		})
+	}
+}
@@ -82,6 +82,7 @@ type HistoryRow struct {
 	Message           types.String      `db:"message"`
 	NotificationState NotificationState `db:"notification_state"`
 	SentAt            types.UnixMilli   `db:"sent_at"`
+	TraceID           types.String      `db:"trace_id"`
 }
 
 // TableName implements the contracts.TableNamer interface.
@@ -91,7 +92,14 @@ func (h *HistoryRow) TableName() string {
 
 // Sync persists the current state of this history to the database and retrieves the just inserted history ID.
 // Returns error when failed to execute the query.
+//
+// Unless set explicitly, the trace ID of the event being processed is taken from the context, see
+// event.ContextWithTraceID.
 func (h *HistoryRow) Sync(ctx context.Context, db *database.DB, tx *sqlx.Tx) error {
+	if !h.TraceID.Valid {
+		h.TraceID = utils.ToDBString(event.TraceIDFromContext(ctx))
+	}
+
 	historyId, err := utils.InsertAndFetchId(ctx, tx, utils.BuildInsertStmtWithout(db, h, "id"), h)
 	if err != nil {
 		return err
@@ -102,6 +110,39 @@ func (h *HistoryRow) Sync(ctx context.Context, db *database.DB, tx *sqlx.Tx) err
 	return nil
 }
 
+// syncHistoryRows persists all the given history entries like HistoryRow.Sync, but in bulk where the database allows
+// it, see utils.BulkInsertAndFetchIds.
+func syncHistoryRows(ctx context.Context, db *database.DB, tx *sqlx.Tx, rows []*HistoryRow) error {
+	for _, h := range rows {
+		if !h.TraceID.Valid {
+			h.TraceID = utils.ToDBString(event.TraceIDFromContext(ctx))
+		}
+	}
+
+	ids, err := utils.BulkInsertAndFetchIds(ctx, tx, utils.BuildInsertStmtWithout(db, &HistoryRow{}, "id"), rows)
+	if err != nil {
+		return err
+	}
+
+	for n, h := range rows {
+		h.ID = ids[n]
+	}
+
+	return nil
+}
+
+// HistoryRuleRow references a rule escalation contributing to a notification of the incident history.
+type HistoryRuleRow struct {
+	HistoryID        int64 `db:"incident_history_id"`
+	RuleEscalationID int64 `db:"rule_escalation_id"`
+	RuleID           int64 `db:"rule_id"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (h *HistoryRuleRow) TableName() string {
+	return "incident_history_rule"
+}
+
 // NotificationEntry is used to cache a set of incident history fields of type Notified.
 //
 // The event processing workflow is performed in a separate transaction before trying to send the actual
@@ -120,3 +161,109 @@ type NotificationEntry struct {
 func (h *NotificationEntry) TableName() string {
 	return "incident_history"
 }
+
+// WatchRow represents a single incident watch database entry, i.e., a contact watching an incident.
+type WatchRow struct {
+	IncidentID  int64           `db:"incident_id"`
+	ContactID   int64           `db:"contact_id"`
+	MinSeverity event.Severity  `db:"min_severity"`
+	CreatedAt   types.UnixMilli `db:"created_at"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (w *WatchRow) TableName() string {
+	return "incident_watch"
+}
+
+// Upsert implements the contracts.Upserter interface.
+func (w *WatchRow) Upsert() interface{} {
+	return &struct {
+		MinSeverity event.Severity `db:"min_severity"`
+	}{MinSeverity: w.MinSeverity}
+}
+
+// ChannelStateRow represents the state a channel plugin keeps about an incident, see plugin.StatefulNotifier.
+type ChannelStateRow struct {
+	IncidentID int64           `db:"incident_id"`
+	ChannelID  int64           `db:"channel_id"`
+	State      string          `db:"state"`
+	ChangedAt  types.UnixMilli `db:"changed_at"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (c *ChannelStateRow) TableName() string {
+	return "incident_channel_state"
+}
+
+// Upsert implements the contracts.Upserter interface.
+func (c *ChannelStateRow) Upsert() interface{} {
+	return &struct {
+		State     string          `db:"state"`
+		ChangedAt types.UnixMilli `db:"changed_at"`
+	}{State: c.State, ChangedAt: c.ChangedAt}
+}
+
+// AnnotationRow is a named value of an incident, parsed from an acknowledgement comment, see Incident.addAnnotations.
+type AnnotationRow struct {
+	IncidentID int64           `db:"incident_id"`
+	Name       string          `db:"name"`
+	Value      string          `db:"value"`
+	EventID    types.Int       `db:"event_id"`
+	ChangedAt  types.UnixMilli `db:"changed_at"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (a *AnnotationRow) TableName() string {
+	return "incident_annotation"
+}
+
+// Upsert implements the contracts.Upserter interface.
+func (a *AnnotationRow) Upsert() interface{} {
+	return &struct {
+		Value     string          `db:"value"`
+		EventID   types.Int       `db:"event_id"`
+		ChangedAt types.UnixMilli `db:"changed_at"`
+	}{Value: a.Value, EventID: a.EventID, ChangedAt: a.ChangedAt}
+}
+
+// lastNotificationRow represents the time of the last sent notification of an incident, aggregated from its history.
+type lastNotificationRow struct {
+	IncidentID int64           `db:"incident_id"`
+	SentAt     types.UnixMilli `db:"sent_at"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (n *lastNotificationRow) TableName() string {
+	return "incident_history"
+}
+
+// lastEventRow represents the time of the last event received for an incident, aggregated from its events.
+type lastEventRow struct {
+	IncidentID int64           `db:"incident_id"`
+	Time       types.UnixMilli `db:"time"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (e *lastEventRow) TableName() string {
+	return "incident_event"
+}
+
+// DeliveryStateRow represents when a contact was last selected by an escalation recipient delivering to a single
+// contact, see rule.EscalationRecipient.SelectContact.
+type DeliveryStateRow struct {
+	EscalationRecipientID int64           `db:"rule_escalation_recipient_id"`
+	ContactID             int64           `db:"contact_id"`
+	LastSelectedAt        types.UnixMilli `db:"last_selected_at"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (d *DeliveryStateRow) TableName() string {
+	return "rule_escalation_recipient_delivery_state"
+}
+
+// Upsert implements the contracts.Upserter interface.
+func (d *DeliveryStateRow) Upsert() interface{} {
+	return &struct {
+		LastSelectedAt types.UnixMilli `db:"last_selected_at"`
+	}{LastSelectedAt: d.LastSelectedAt}
+}
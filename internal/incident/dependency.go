@@ -0,0 +1,71 @@
+package incident
+
+import (
+	"context"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/jmoiron/sqlx"
+	"sync"
+)
+
+// criticalObjects holds the IDs of all objects having an open incident of at least critical severity.
+//
+// It allows checking the parents of an object, see object.Dependency, without locking their incidents, which might
+// be processing an event themselves.
+var criticalObjects = &objectSet{ids: map[string]struct{}{}}
+
+// objectSet is a set of object IDs safe for concurrent use.
+type objectSet struct {
+	ids map[string]struct{}
+	mu  sync.RWMutex
+}
+
+// set adds the object ID to the set if present is true and removes it otherwise.
+func (s *objectSet) set(id types.Binary, present bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if present {
+		s.ids[id.String()] = struct{}{}
+	} else {
+		delete(s.ids, id.String())
+	}
+}
+
+// containsAny returns the first of the given object IDs contained in the set, or nil if there is none.
+func (s *objectSet) containsAny(ids []types.Binary) types.Binary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, id := range ids {
+		if _, ok := s.ids[id.String()]; ok {
+			return id
+		}
+	}
+
+	return nil
+}
+
+// updateCriticalObject updates the criticalObjects registry based on this incident's current state.
+//
+// It must be called after committing any severity change or closure of the incident.
+func (i *Incident) updateCriticalObject() {
+	if i.Object == nil {
+		return
+	}
+
+	open := !i.StartedAt.Time().IsZero() && i.RecoveredAt.Time().IsZero()
+	criticalObjects.set(i.Object.ID, open && i.Severity >= event.SeverityCrit)
+}
+
+// criticalParent returns the ID of an object this incident's object depends on and which has an open incident of at
+// least critical severity, or nil if there is none.
+func (i *Incident) criticalParent(ctx context.Context, tx *sqlx.Tx) (types.Binary, error) {
+	parents, err := object.ParentIDs(ctx, tx, i.Object.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return criticalObjects.containsAny(parents), nil
+}
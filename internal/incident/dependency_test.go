@@ -0,0 +1,67 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"testing"
+	"time"
+)
+
+func TestObjectSet(t *testing.T) {
+	t.Parallel()
+
+	s := &objectSet{ids: map[string]struct{}{}}
+	a, b, c := types.Binary{1}, types.Binary{2}, types.Binary{3}
+
+	assert.Nil(t, s.containsAny([]types.Binary{a, b}))
+
+	s.set(b, true)
+	assert.Equal(t, b, s.containsAny([]types.Binary{a, b, c}))
+	assert.Nil(t, s.containsAny([]types.Binary{a, c}))
+	assert.Nil(t, s.containsAny(nil))
+
+	s.set(b, false)
+	assert.Nil(t, s.containsAny([]types.Binary{a, b, c}))
+}
+
+func TestIncident_updateCriticalObject(t *testing.T) {
+	t.Parallel()
+
+	// Use IDs no other test uses, as criticalObjects is shared by all incidents.
+	i := NewIncident(nil, &object.Object{ID: []byte{0xde, 0x9e, 0x01}}, nil, zaptest.NewLogger(t).Sugar())
+	isCritical := func() bool { return criticalObjects.containsAny([]types.Binary{i.Object.ID}) != nil }
+
+	tests := []struct {
+		name      string
+		started   bool
+		recovered bool
+		severity  event.Severity
+		critical  bool
+	}{
+		{"NotStarted", false, false, event.SeverityCrit, false},
+		{"Warning", true, false, event.SeverityWarning, false},
+		{"Critical", true, false, event.SeverityCrit, true},
+		{"Emergency", true, false, event.SeverityEmerg, true},
+		{"Recovered", true, true, event.SeverityOK, false},
+	}
+
+	// The subtests are run sequentially, as all of them update the same object.
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i.StartedAt, i.RecoveredAt = types.UnixMilli{}, types.UnixMilli{}
+			if tt.started {
+				i.StartedAt = types.UnixMilli(time.Now())
+			}
+			if tt.recovered {
+				i.RecoveredAt = types.UnixMilli(time.Now())
+			}
+			i.Severity = tt.severity
+
+			i.updateCriticalObject()
+			assert.Equal(t, tt.critical, isCritical())
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package incident
+
+import (
+	"context"
+	"sync"
+)
+
+// inflight tracks the events being processed and the escalations being triggered, allowing Drain to wait for them.
+var inflight = newDrainer()
+
+// drainer counts the operations in progress, refusing new ones once draining started.
+type drainer struct {
+	mu       sync.Mutex
+	active   int
+	draining bool
+	// idle is closed once draining and no operation is in progress anymore.
+	idle chan struct{}
+
+	// abort is cancelled once draining timed out, aborting the notifications still being delivered.
+	abort       context.Context
+	abortCancel context.CancelFunc
+}
+
+func newDrainer() *drainer {
+	abort, cancel := context.WithCancel(context.Background())
+	return &drainer{idle: make(chan struct{}), abort: abort, abortCancel: cancel}
+}
+
+// begin an operation, which must be ended by calling end. False is returned if draining already started, in which case
+// the operation must not be started.
+func (d *drainer) begin() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.draining {
+		return false
+	}
+
+	d.active++
+	return true
+}
+
+// end an operation started by begin.
+func (d *drainer) end() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.active--
+	if d.draining && d.active == 0 {
+		close(d.idle)
+	}
+}
+
+// detach returns a context keeping the values of the given one, but not being cancelled with it. Instead, it is
+// cancelled once draining timed out. Thus, an event being processed is not abandoned halfway, e.g., as the Icinga 2
+// Event Stream delivering it was stopped. The returned cancel function must be called to release its resources.
+func (d *drainer) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(d.abort, cancel)
+
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// drain refuses new operations and waits until all in progress are done or the context is done. In the latter case,
+// the remaining ones are aborted and their number is returned.
+func (d *drainer) drain(ctx context.Context) int {
+	d.mu.Lock()
+	if !d.draining {
+		d.draining = true
+		if d.active == 0 {
+			close(d.idle)
+		}
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.idle:
+		return 0
+	case <-ctx.Done():
+		d.abortCancel()
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.active
+	}
+}
+
+// Drain stops processing further events and escalations and waits until those in progress, including the delivery of
+// their notifications, are done.
+//
+// Once the context is done, the notifications still being delivered are aborted and the number of operations still in
+// progress is returned. Undelivered notifications stay pending in the incident history. Events received while draining
+// are refused with errs.ErrShuttingDown, while escalations due meanwhile are triggered after the next start, as the
+// escalations of all open incidents are reevaluated when loading them.
+func Drain(ctx context.Context) int {
+	return inflight.drain(ctx)
+}
@@ -0,0 +1,63 @@
+package incident
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDrainer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("WaitsForOperations", func(t *testing.T) {
+		t.Parallel()
+
+		d := newDrainer()
+		require.True(t, d.begin())
+
+		done := make(chan int)
+		go func() { done <- d.drain(context.Background()) }()
+
+		assert.Eventually(t, func() bool { return !d.begin() }, time.Second, time.Millisecond,
+			"new operations should be refused while draining")
+
+		select {
+		case <-done:
+			t.Fatal("drain should wait for the operation in progress")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		d.end()
+		assert.Equal(t, 0, <-done)
+	})
+
+	t.Run("AbortsOnTimeout", func(t *testing.T) {
+		t.Parallel()
+
+		d := newDrainer()
+		require.True(t, d.begin())
+
+		parent, cancelParent := context.WithCancel(context.Background())
+		ctx, cancel := d.detach(parent)
+		defer cancel()
+
+		cancelParent()
+		assert.NoError(t, ctx.Err(), "detached context should outlive its parent")
+
+		drainCtx, cancelDrain := context.WithCancel(context.Background())
+		cancelDrain()
+		assert.Equal(t, 1, d.drain(drainCtx))
+		assert.Eventually(t, func() bool { return ctx.Err() != nil }, time.Second, time.Millisecond,
+			"detached context should be aborted")
+	})
+
+	t.Run("Idle", func(t *testing.T) {
+		t.Parallel()
+
+		d := newDrainer()
+		assert.Equal(t, 0, d.drain(context.Background()))
+		assert.Equal(t, 0, d.drain(context.Background()), "draining twice should not block")
+	})
+}
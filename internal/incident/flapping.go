@@ -0,0 +1,179 @@
+package incident
+
+import (
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// flapping tracks the recent severity changes of all objects, shared by all incidents.
+//
+// As an incident is closed on its object's recovery, a flapping object results in a series of incidents. Thus, the
+// severity changes are tracked per object and not per incident.
+var flapping = &flapDetector{objects: map[string]*flapState{}}
+
+// flapState holds the recent severity changes of a single object.
+type flapState struct {
+	// transitions are the times of the severity changes within the window, oldest first.
+	transitions []time.Time
+
+	// flapping is set once the object had enough severity changes within the window.
+	flapping bool
+
+	// startedAt is the time of the severity change the object started flapping with.
+	startedAt time.Time
+
+	// incident is the latest incident having processed a severity change of this object. It is notified once the
+	// object stopped flapping, even if it was closed in the meantime.
+	incident *Incident
+
+	// timer calls flapDetector.expire once the object's severity did not change for the whole window.
+	timer *time.Timer
+
+	// expiresAt is the time at which timer is supposed to fire, guarding against an outdated timer firing late.
+	expiresAt time.Time
+}
+
+// flapDetector detects objects rapidly oscillating between severities, i.e., a given number of severity changes within
+// a given window. While an object is flapping, notifications about its severity changes are suppressed. Its recipients
+// are only notified once about the object starting and stopping to flap.
+type flapDetector struct {
+	objects map[string]*flapState
+	mu      sync.Mutex
+}
+
+// transition records a severity change of the incident's object at the given time.
+//
+// It returns whether the object is flapping, including this change, and whether it started flapping due to this
+// change. Once the object's severity did not change for the whole window, it stops flapping, see expire.
+func (d *flapDetector) transition(i *Incident, t time.Time, threshold int, window time.Duration) (bool, bool) {
+	if threshold <= 0 || window <= 0 {
+		return false, false
+	}
+
+	key := i.Object.ID.String()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.objects[key]
+	if !ok {
+		state = &flapState{}
+		d.objects[key] = state
+	}
+
+	state.incident = i
+	if n := len(state.transitions); n > 0 && state.transitions[n-1].Equal(t) {
+		// The same severity change is processed again, as its previous database transaction was rolled back.
+		return state.flapping, state.startedAt.Equal(t)
+	}
+
+	state.transitions = append(state.transitions, t)
+	for len(state.transitions) > 0 && !state.transitions[0].After(t.Add(-window)) {
+		state.transitions = state.transitions[1:]
+	}
+
+	started := !state.flapping && len(state.transitions) >= threshold
+	if started {
+		state.flapping = true
+		state.startedAt = t
+	}
+
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+	state.expiresAt = time.Now().Add(window)
+	state.timer = time.AfterFunc(window, func() { d.expire(key, state, window) })
+
+	return state.flapping, started
+}
+
+// isFlapping returns whether the incident's object is currently flapping.
+func (d *flapDetector) isFlapping(i *Incident) bool {
+	if i.Object == nil {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.objects[i.Object.ID.String()]
+	return ok && state.flapping
+}
+
+// expire forgets the object's severity changes after the window elapsed without any further change.
+//
+// If the object was flapping, the latest incident's recipients are notified about the object no longer flapping.
+func (d *flapDetector) expire(key string, state *flapState, window time.Duration) {
+	d.mu.Lock()
+	if d.objects[key] != state || time.Now().Before(state.expiresAt) {
+		// Either there was another severity change in the meantime or this state was already replaced.
+		d.mu.Unlock()
+		return
+	}
+	delete(d.objects, key)
+	d.mu.Unlock()
+
+	if !state.flapping || state.incident == nil {
+		return
+	}
+
+	i := state.incident
+	if !inflight.begin() {
+		i.logger.Debug("Not processing flapping end while shutting down")
+		return
+	}
+	defer inflight.end()
+
+	ev := &event.Event{
+		Time:     time.Now(),
+		SourceId: i.Object.SourceID,
+		Type:     event.TypeFlappingEnd,
+		Message:  fmt.Sprintf("Object stopped flapping, its severity did not change for %v", window),
+	}
+
+	i.logger.Infow("Object stopped flapping", zap.Duration("window", window))
+	if err := i.ProcessEvent(context.Background(), ev); err != nil {
+		i.logger.Errorw("Cannot process flapping end event", zap.Error(err))
+	}
+}
+
+// processFlapping records the severity change of the given state event for the flapping detection.
+//
+// If the object just started flapping, a flapping start event is synced and returned to be notified instead of the
+// state event. Otherwise, nil is returned.
+func (i *Incident) processFlapping(ctx context.Context, tx *sqlx.Tx, ev *event.Event) (*event.Event, error) {
+	conf := daemon.Config().Flapping
+	_, started := flapping.transition(i, ev.Time, conf.Transitions, conf.Window)
+	if !started {
+		return nil, nil
+	}
+
+	i.logger.Infow("Object started flapping, suppressing notifications of its severity changes",
+		zap.Int("transitions", conf.Transitions), zap.Duration("window", conf.Window))
+
+	flapEv := &event.Event{
+		Time:     ev.Time,
+		SourceId: ev.SourceId,
+		Type:     event.TypeFlappingStart,
+		TraceID:  ev.TraceID,
+		Message: fmt.Sprintf("Object started flapping, its severity changed %d times within %v, currently %s",
+			conf.Transitions, conf.Window, i.Severity.String()),
+	}
+	if err := flapEv.Sync(ctx, tx, i.db, i.Object.ID); err != nil {
+		i.logger.Errorw("Failed to insert flapping start event", zap.Error(err))
+		return nil, err
+	}
+
+	if err := i.AddEvent(ctx, tx, flapEv); err != nil {
+		i.logger.Errorw("Cannot insert incident event to the database", zap.Error(err))
+		return nil, err
+	}
+
+	return flapEv, nil
+}
@@ -0,0 +1,102 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"testing"
+	"time"
+)
+
+func TestFlapDetector(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	newIncident := func(t *testing.T, id byte) *Incident {
+		return NewIncident(nil, &object.Object{ID: []byte{id}}, nil, zaptest.NewLogger(t).Sugar())
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+
+		d := &flapDetector{objects: map[string]*flapState{}}
+		i := newIncident(t, 1)
+		for n := 0; n < 10; n++ {
+			isFlapping, started := d.transition(i, start.Add(time.Duration(n)*time.Second), 0, time.Hour)
+			assert.False(t, isFlapping)
+			assert.False(t, started)
+		}
+		assert.Empty(t, d.objects)
+	})
+
+	t.Run("StartsOnce", func(t *testing.T) {
+		t.Parallel()
+
+		d := &flapDetector{objects: map[string]*flapState{}}
+		i := newIncident(t, 1)
+		other := newIncident(t, 2)
+
+		var starts []int
+		for n := 0; n < 6; n++ {
+			isFlapping, started := d.transition(i, start.Add(time.Duration(n)*time.Minute), 3, time.Hour)
+			assert.Equal(t, n >= 2, isFlapping, "transition %d", n)
+			if started {
+				starts = append(starts, n)
+			}
+		}
+
+		assert.Equal(t, []int{2}, starts)
+		assert.True(t, d.isFlapping(i))
+		assert.False(t, d.isFlapping(other), "other objects must not be affected")
+	})
+
+	t.Run("Replayed", func(t *testing.T) {
+		t.Parallel()
+
+		d := &flapDetector{objects: map[string]*flapState{}}
+		i := newIncident(t, 1)
+		d.transition(i, start, 2, time.Hour)
+
+		for attempt := 0; attempt < 2; attempt++ {
+			isFlapping, started := d.transition(i, start.Add(time.Minute), 2, time.Hour)
+			assert.True(t, isFlapping)
+			assert.True(t, started, "a replayed transition must report the start again")
+		}
+		assert.Len(t, d.objects[i.Object.ID.String()].transitions, 2)
+	})
+
+	t.Run("OutsideWindow", func(t *testing.T) {
+		t.Parallel()
+
+		d := &flapDetector{objects: map[string]*flapState{}}
+		i := newIncident(t, 1)
+		for n := 0; n < 5; n++ {
+			isFlapping, _ := d.transition(i, start.Add(time.Duration(n)*10*time.Minute), 3, 15*time.Minute)
+			assert.False(t, isFlapping, "transition %d", n)
+		}
+		assert.Len(t, d.objects[i.Object.ID.String()].transitions, 2)
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		t.Parallel()
+
+		d := &flapDetector{objects: map[string]*flapState{}}
+		i := newIncident(t, 1)
+		d.transition(i, start, 3, time.Hour)
+
+		key := i.Object.ID.String()
+		state := d.objects[key]
+		require.NotNil(t, state)
+		state.timer.Stop()
+
+		d.expire(key, state, time.Hour)
+		assert.Contains(t, d.objects, key, "must not expire before the window elapsed")
+
+		state.expiresAt = time.Now().Add(-time.Second)
+		d.expire(key, state, time.Hour)
+		assert.NotContains(t, d.objects, key)
+		assert.False(t, d.isFlapping(i))
+	})
+}
@@ -0,0 +1,177 @@
+package incident
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-notifications/internal/archive"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"slices"
+	"time"
+)
+
+// ArchiveWarning explains responses merging entries read from the archive, which is considerably slower than the
+// database, e.g., as archives are downloaded from an S3 bucket.
+const ArchiveWarning = "The incident history was merged with the archive, which takes considerably longer to read" +
+	" than the database."
+
+// ListIncidentHistory is like ListHistory for the history of a single incident, but merges the entries deleted by the
+// retention from the archive if necessary, see LoadArchivedHistory. The page's Warning is set in that case.
+func ListIncidentHistory(
+	ctx context.Context, db *database.DB, a *archive.Archiver, f *HistoryFilter,
+) (*HistoryPage, error) {
+	summary, entries, archived, err := LoadArchivedHistory(ctx, db, a, f.IncidentID)
+	if err != nil {
+		return nil, err
+	}
+	if f.SourceIDs != nil && !slices.Contains(f.SourceIDs, summary.SourceID) {
+		return nil, fmt.Errorf("%w: %d", ErrIncidentNotFound, f.IncidentID)
+	}
+	if !archived {
+		return ListHistory(ctx, db, f)
+	}
+
+	page := f.page(entries)
+	page.Warning = ArchiveWarning
+
+	return page, nil
+}
+
+// LoadArchivedHistory loads the summary and the whole history of the incident of the given ID, oldest first, from both
+// the database and the archive, if entries might have been deleted by the retention, see package archive.
+//
+// As reading the archive is slow, this is only the case if the incident isn't in the database anymore or started before
+// the retention's notifications cutoff. Otherwise, archived is false and the history isn't loaded at all, leaving it to
+// the caller's database queries. An error wrapping ErrIncidentNotFound is returned for an incident neither in the
+// database nor in the archive. Without an archive.Archiver, nothing is read from the archive.
+func LoadArchivedHistory(
+	ctx context.Context, db *database.DB, a *archive.Archiver, id int64,
+) (summary *Summary, history []*HistoryEntry, archived bool, err error) {
+	summary, err = GetSummary(ctx, db, id)
+	if a == nil || err == nil && !notificationsMayBeArchived(summary) {
+		return summary, nil, false, err
+	}
+
+	if errors.Is(err, ErrIncidentNotFound) {
+		record, err := a.Incident(ctx, id)
+		if errors.Is(err, archive.ErrNotArchived) {
+			return nil, nil, false, fmt.Errorf("%w: %d", ErrIncidentNotFound, id)
+		} else if err != nil {
+			return nil, nil, false, err
+		}
+
+		if summary, err = archivedSummary(record); err != nil {
+			return nil, nil, false, err
+		}
+		if history, err = decodeArchivedHistory(record.History); err != nil {
+			return nil, nil, false, err
+		}
+	} else if err != nil {
+		return nil, nil, false, err
+	} else {
+		stmt := db.Rebind(fmt.Sprintf(`SELECT %s FROM "incident_history" h WHERE h."incident_id" = ?`,
+			historyColumns))
+		if err := db.SelectContext(ctx, &history, stmt, id); err != nil {
+			return nil, nil, false, fmt.Errorf("cannot fetch incident history: %w", err)
+		}
+	}
+
+	if notificationsMayBeArchived(summary) {
+		rows, err := a.Notifications(ctx, id, summary.StartedAt.Time())
+		if err != nil {
+			return nil, nil, false, err
+		}
+
+		notifications, err := decodeArchivedHistory(rows)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		history = append(history, notifications...)
+	}
+
+	return summary, mergeHistory(history), true, nil
+}
+
+// notificationsMayBeArchived reports whether notifications of the incident might have been deleted by the retention.
+func notificationsMayBeArchived(summary *Summary) bool {
+	days := daemon.Config().Retention.Notifications
+	return days > 0 && summary.StartedAt.Time().Before(time.Now().AddDate(0, 0, -days))
+}
+
+// archivedSummary decodes the Summary of an archived incident, see archive.Record.
+func archivedSummary(record *archive.Record) (*Summary, error) {
+	summary := &Summary{}
+	if err := archive.Decode(record.Incident, summary); err != nil {
+		return nil, fmt.Errorf("cannot decode archived incident: %w", err)
+	}
+
+	var object struct {
+		Name     string `json:"name"`
+		SourceID int64  `json:"source_id"`
+	}
+	if err := archive.Decode(record.Object, &object); err != nil {
+		return nil, fmt.Errorf("cannot decode archived object: %w", err)
+	}
+	summary.ObjectName, summary.SourceID = object.Name, object.SourceID
+
+	return summary, nil
+}
+
+// decodeArchivedHistory decodes archived incident_history rows.
+func decodeArchivedHistory(rows []map[string]any) ([]*HistoryEntry, error) {
+	history := make([]*HistoryEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := &HistoryEntry{}
+		if err := archive.Decode(row, entry); err != nil {
+			return nil, fmt.Errorf("cannot decode archived incident history: %w", err)
+		}
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
+
+// mergeHistory sorts the history entries oldest first and drops duplicates, e.g., of rows archived multiple times.
+func mergeHistory(history []*HistoryEntry) []*HistoryEntry {
+	slices.SortStableFunc(history, func(a, b *HistoryEntry) int {
+		if c := a.Time.Time().Compare(b.Time.Time()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return slices.CompactFunc(history, func(a, b *HistoryEntry) bool { return a.ID == b.ID })
+}
+
+// page returns the page of the history entries matching the filter, like ListHistory does for the database.
+func (f *HistoryFilter) page(history []*HistoryEntry) *HistoryPage {
+	matching := make([]*HistoryEntry, 0, len(history))
+	for _, entry := range history {
+		if f.matches(entry) {
+			matching = append(matching, entry)
+		}
+	}
+
+	limit := f.Limit
+	if limit == 0 {
+		limit = DefaultListLimit
+	}
+	start := min(f.Offset, len(matching))
+	end := min(start+limit, len(matching))
+
+	return &HistoryPage{Total: int64(len(matching)), History: matching[start:end]}
+}
+
+// matches reports whether the history entry matches the filter's Types, Since and Until.
+func (f *HistoryFilter) matches(entry *HistoryEntry) bool {
+	if len(f.Types) > 0 && !slices.ContainsFunc(f.Types, func(t HistoryEventType) bool {
+		return t.String() == entry.Type
+	}) {
+		return false
+	}
+
+	t := entry.Time.Time()
+	return (f.Since.IsZero() || !t.Before(f.Since)) && (f.Until.IsZero() || !t.After(f.Until))
+}
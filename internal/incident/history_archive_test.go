@@ -0,0 +1,112 @@
+package incident
+
+import (
+	"encoding/json"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/archive"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestArchivedHistory(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(id int64, offset time.Duration, typ string) *HistoryEntry {
+		return &HistoryEntry{ID: id, IncidentID: 1, Time: types.UnixMilli(start.Add(offset)), Type: typ}
+	}
+
+	t.Run("Summary", func(t *testing.T) {
+		t.Parallel()
+
+		summary, err := archivedSummary(&archive.Record{
+			Incident: map[string]any{
+				"id":           json.Number("1"),
+				"object_id":    "00ff",
+				"started_at":   json.Number("1704067200000"),
+				"recovered_at": nil,
+				"severity":     "crit",
+			},
+			Object: map[string]any{"name": "example.com!ping", "source_id": json.Number("3")},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &Summary{
+			ID:         1,
+			ObjectID:   types.Binary{0x00, 0xff},
+			ObjectName: "example.com!ping",
+			SourceID:   3,
+			StartedAt:  types.UnixMilli(time.UnixMilli(start.UnixMilli())),
+			Severity:   event.SeverityCrit,
+		}, summary)
+	})
+
+	t.Run("Decode", func(t *testing.T) {
+		t.Parallel()
+
+		history, err := decodeArchivedHistory([]map[string]any{{
+			"id":           json.Number("7"),
+			"incident_id":  json.Number("1"),
+			"time":         json.Number("1704067200000"),
+			"type":         "notified",
+			"contact_id":   json.Number("2"),
+			"channel_id":   nil,
+			"message":      "PING CRITICAL",
+			"unknown_cols": "are ignored",
+		}})
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, int64(7), history[0].ID)
+		assert.Equal(t, int64(1), history[0].IncidentID)
+		assert.True(t, start.Equal(history[0].Time.Time()))
+		assert.Equal(t, "notified", history[0].Type)
+		assert.True(t, history[0].ContactID.Valid)
+		assert.Equal(t, int64(2), history[0].ContactID.Int64)
+		assert.False(t, history[0].ChannelID.Valid)
+		assert.Equal(t, "PING CRITICAL", history[0].Message.String)
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		t.Parallel()
+
+		merged := mergeHistory([]*HistoryEntry{
+			entry(3, time.Minute, "notified"),
+			entry(1, 0, "opened"),
+			entry(2, time.Minute, "notified"),
+			entry(3, time.Minute, "notified"),
+		})
+		require.Len(t, merged, 3, "duplicates must be dropped")
+		for i, id := range []int64{1, 2, 3} {
+			assert.Equal(t, id, merged[i].ID)
+		}
+	})
+
+	t.Run("Page", func(t *testing.T) {
+		t.Parallel()
+
+		history := []*HistoryEntry{
+			entry(1, 0, "opened"),
+			entry(2, time.Minute, "notified"),
+			entry(3, 2*time.Minute, "notified"),
+			entry(4, 3*time.Minute, "closed"),
+		}
+
+		page := (&HistoryFilter{}).page(history)
+		assert.Equal(t, int64(4), page.Total)
+		assert.Len(t, page.History, 4)
+
+		page = (&HistoryFilter{Types: []HistoryEventType{Notified}, Limit: 1, Offset: 1}).page(history)
+		assert.Equal(t, int64(2), page.Total)
+		require.Len(t, page.History, 1)
+		assert.Equal(t, int64(3), page.History[0].ID)
+
+		page = (&HistoryFilter{Since: start.Add(time.Minute), Until: start.Add(2 * time.Minute)}).page(history)
+		assert.Equal(t, int64(2), page.Total, "time range must be inclusive")
+
+		page = (&HistoryFilter{Offset: 10}).page(history)
+		assert.Equal(t, int64(4), page.Total)
+		assert.Empty(t, page.History)
+	})
+}
@@ -19,18 +19,24 @@ const (
 	RecipientRoleChanged
 	Closed
 	Notified
+	Renotified
+	AcknowledgementQuorumReached
+	ContactChannelDegraded
 )
 
 var historyTypeByName = map[string]HistoryEventType{
-	"opened":                    Opened,
-	"muted":                     Muted,
-	"unmuted":                   Unmuted,
-	"incident_severity_changed": IncidentSeverityChanged,
-	"rule_matched":              RuleMatched,
-	"escalation_triggered":      EscalationTriggered,
-	"recipient_role_changed":    RecipientRoleChanged,
-	"closed":                    Closed,
-	"notified":                  Notified,
+	"opened":                         Opened,
+	"muted":                          Muted,
+	"unmuted":                        Unmuted,
+	"incident_severity_changed":      IncidentSeverityChanged,
+	"rule_matched":                   RuleMatched,
+	"escalation_triggered":           EscalationTriggered,
+	"recipient_role_changed":         RecipientRoleChanged,
+	"closed":                         Closed,
+	"notified":                       Notified,
+	"renotified":                     Renotified,
+	"acknowledgement_quorum_reached": AcknowledgementQuorumReached,
+	"contact_channel_degraded":       ContactChannelDegraded,
 }
 
 var historyEventTypeToName = func() map[HistoryEventType]string {
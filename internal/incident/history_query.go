@@ -0,0 +1,247 @@
+package incident
+
+import (
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/jmoiron/sqlx"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryFilter restricts the history entries returned by ListHistory.
+//
+// Either IncidentID or ObjectID must be set. Other zero values do not restrict the result, except for Limit, which
+// defaults to DefaultListLimit.
+type HistoryFilter struct {
+	IncidentID int64
+	ObjectID   types.Binary
+
+	// Types restricts the result to history entries of any of these types, e.g., Notified.
+	Types []HistoryEventType
+
+	// Since and Until restrict the result to history entries within this time range, both inclusive.
+	Since time.Time
+	Until time.Time
+
+	// SourceIDs treats the incident or object as unknown unless it belongs to any of these sources, if not nil,
+	// e.g., to only disclose it to API tokens of a tenant, see config.RuntimeConfig.GetTenantSourceIDs.
+	SourceIDs []int64
+
+	Limit  int
+	Offset int
+}
+
+// ParseHistoryFilter parses a HistoryFilter from URL query parameters, e.g., as used by the HTTP API.
+//
+// Supported parameters are "type" (comma-separated list), "since" and "until" (either Unix milliseconds or RFC 3339),
+// "limit" and "offset". The incident or object the history belongs to is not part of the query and must be set by the
+// caller.
+func ParseHistoryFilter(query url.Values) (*HistoryFilter, error) {
+	f := &HistoryFilter{}
+
+	if names := query.Get("type"); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			historyType, ok := historyTypeByName[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown history type %q", strings.TrimSpace(name))
+			}
+			f.Types = append(f.Types, historyType)
+		}
+	}
+
+	for param, dest := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+
+		if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+			*dest = time.UnixMilli(ms)
+		} else if t, err := time.Parse(time.RFC3339, value); err == nil {
+			*dest = t
+		} else {
+			return nil, fmt.Errorf("%s must be either Unix milliseconds or RFC 3339, got %q", param, value)
+		}
+	}
+
+	if !f.Since.IsZero() && !f.Until.IsZero() && f.Until.Before(f.Since) {
+		return nil, fmt.Errorf("until must not be before since")
+	}
+
+	for param, dest := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative integer, got %q", param, value)
+		}
+		*dest = n
+	}
+
+	if f.Limit > MaxListLimit {
+		return nil, fmt.Errorf("limit must not exceed %d", MaxListLimit)
+	}
+
+	return f, nil
+}
+
+// where builds the WHERE clause of this filter with "?" placeholders, referencing the incident_history table as "h" and
+// the incident table as "i", together with its arguments.
+func (f *HistoryFilter) where() (string, []any, error) {
+	var conditions []string
+	var args []any
+
+	if f.IncidentID != 0 {
+		conditions = append(conditions, `h."incident_id" = ?`)
+		args = append(args, f.IncidentID)
+	}
+
+	if f.ObjectID.Valid() {
+		conditions = append(conditions, `i."object_id" = ?`)
+		args = append(args, f.ObjectID)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil, fmt.Errorf("either an incident or an object must be given")
+	}
+
+	if len(f.Types) > 0 {
+		in, inArgs, err := sqlx.In(`h."type" IN (?)`, f.Types)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, in)
+		args = append(args, inArgs...)
+	}
+
+	if !f.Since.IsZero() {
+		conditions = append(conditions, `h."time" >= ?`)
+		args = append(args, types.UnixMilli(f.Since))
+	}
+
+	if !f.Until.IsZero() {
+		conditions = append(conditions, `h."time" <= ?`)
+		args = append(args, types.UnixMilli(f.Until))
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// HistoryEntry is a read-only representation of an incident history row, as returned by ListHistory.
+//
+// Enum columns are represented by their names and are null if they do not apply to the entry's type.
+type HistoryEntry struct {
+	ID                int64           `db:"id" json:"id"`
+	IncidentID        int64           `db:"incident_id" json:"incident_id"`
+	Time              types.UnixMilli `db:"time" json:"time"`
+	Type              string          `db:"type" json:"type"`
+	EventID           types.Int       `db:"event_id" json:"event_id"`
+	RuleID            types.Int       `db:"rule_id" json:"rule_id"`
+	RuleEscalationID  types.Int       `db:"rule_escalation_id" json:"rule_escalation_id"`
+	ContactID         types.Int       `db:"contact_id" json:"contact_id"`
+	ContactGroupID    types.Int       `db:"contactgroup_id" json:"contactgroup_id"`
+	ScheduleID        types.Int       `db:"schedule_id" json:"schedule_id"`
+	ChannelID         types.Int       `db:"channel_id" json:"channel_id"`
+	NewSeverity       types.String    `db:"new_severity" json:"new_severity"`
+	OldSeverity       types.String    `db:"old_severity" json:"old_severity"`
+	NewRecipientRole  types.String    `db:"new_recipient_role" json:"new_recipient_role"`
+	OldRecipientRole  types.String    `db:"old_recipient_role" json:"old_recipient_role"`
+	NotificationState types.String    `db:"notification_state" json:"notification_state"`
+	SentAt            types.UnixMilli `db:"sent_at" json:"sent_at"`
+	Message           types.String    `db:"message" json:"message"`
+	TraceID           types.String    `db:"trace_id" json:"trace_id"`
+}
+
+// historyColumns selects the columns of a HistoryEntry from the incident_history table referenced as "h".
+const historyColumns = `h."id", h."incident_id", h."time", h."type", h."event_id", h."rule_id",
+	h."rule_escalation_id", h."contact_id", h."contactgroup_id", h."schedule_id", h."channel_id",
+	h."new_severity", h."old_severity", h."new_recipient_role", h."old_recipient_role",
+	h."notification_state", h."sent_at", h."message", h."trace_id"`
+
+// HistoryPage is a page of history entries together with the total number of entries matching the filter.
+type HistoryPage struct {
+	Total   int64           `json:"total"`
+	History []*HistoryEntry `json:"history"`
+
+	// Warning is set to ArchiveWarning if entries were read from the archive, see ListIncidentHistory.
+	Warning string `json:"warning,omitempty"`
+}
+
+// ListHistory returns the history entries matching the given filter from the database, oldest first.
+//
+// If the filter's incident or object does not exist, an error wrapping either ErrIncidentNotFound or ErrObjectNotFound
+// is returned, allowing to tell an unknown reference apart from an empty history.
+func ListHistory(ctx context.Context, db *database.DB, f *HistoryFilter) (*HistoryPage, error) {
+	where, args, err := f.where()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := historyReferenceExists(ctx, db, f); err != nil {
+		return nil, err
+	}
+
+	page := &HistoryPage{History: []*HistoryEntry{}}
+	stmt := db.Rebind(fmt.Sprintf(`SELECT COUNT(*)
+		FROM "incident_history" h
+		INNER JOIN "incident" i ON i."id" = h."incident_id"
+		WHERE %s`, where))
+	if err := db.GetContext(ctx, &page.Total, stmt, args...); err != nil {
+		return nil, fmt.Errorf("cannot count incident history: %w", err)
+	}
+
+	limit := f.Limit
+	if limit == 0 {
+		limit = DefaultListLimit
+	}
+
+	stmt = db.Rebind(fmt.Sprintf(`SELECT %s
+		FROM "incident_history" h
+		INNER JOIN "incident" i ON i."id" = h."incident_id"
+		WHERE %s
+		ORDER BY h."time", h."id"
+		LIMIT %d OFFSET %d`, historyColumns, where, limit, f.Offset))
+	if err := db.SelectContext(ctx, &page.History, stmt, args...); err != nil {
+		return nil, fmt.Errorf("cannot list incident history: %w", err)
+	}
+
+	return page, nil
+}
+
+// historyReferenceExists checks that the incident or object of the given filter exists and belongs to any of the
+// filter's SourceIDs, if set.
+func historyReferenceExists(ctx context.Context, db *database.DB, f *HistoryFilter) error {
+	table, id, notFound := "incident", any(f.IncidentID), ErrIncidentNotFound
+	query := `SELECT COUNT(*) FROM "incident" i INNER JOIN "object" o ON o."id" = i."object_id" WHERE i."id" = ?`
+	if f.IncidentID == 0 {
+		table, id, notFound = "object", f.ObjectID, ErrObjectNotFound
+		query = `SELECT COUNT(*) FROM "object" o WHERE o."id" = ?`
+	}
+
+	args := []any{id}
+	if f.SourceIDs != nil {
+		in, inArgs, err := sourcesCondition(f.SourceIDs)
+		if err != nil {
+			return err
+		}
+		query += " AND " + in
+		args = append(args, inArgs...)
+	}
+
+	var count int64
+	if err := db.GetContext(ctx, &count, db.Rebind(query), args...); err != nil {
+		return fmt.Errorf("cannot fetch %s: %w", table, err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %v", notFound, id)
+	}
+
+	return nil
+}
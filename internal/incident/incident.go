@@ -1,25 +1,39 @@
 package incident
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/icinga/icinga-go-library/backoff"
 	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/retry"
 	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/channel"
 	"github.com/icinga/icinga-notifications/internal/config"
 	"github.com/icinga/icinga-notifications/internal/contracts"
 	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/errs"
 	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/latency"
+	"github.com/icinga/icinga-notifications/internal/msgtemplate"
 	"github.com/icinga/icinga-notifications/internal/object"
 	"github.com/icinga/icinga-notifications/internal/recipient"
 	"github.com/icinga/icinga-notifications/internal/rule"
 	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
+	"slices"
 	"sync"
 	"time"
 )
 
+// notifyRetryTimeout limits how long sending a single notification is retried if the channel plugin is unreachable.
+const notifyRetryTimeout = 10 * time.Second
+
 type ruleID = int64
 type escalationID = int64
 
@@ -36,6 +50,15 @@ type Incident struct {
 	Rules           map[ruleID]struct{}               `db:"-"`
 	Recipients      map[recipient.Key]*RecipientState `db:"-"`
 
+	// Watches maps contact IDs to their watch of this incident, see Watch.
+	Watches map[int64]*WatchRow `db:"-"`
+
+	// ChannelStates maps channel IDs to the state their plugin keeps about this incident, see plugin.StatefulNotifier.
+	ChannelStates map[int64]json.RawMessage `db:"-"`
+
+	// Annotations are named values parsed from acknowledgement comments, e.g., a ticket reference, see addAnnotations.
+	Annotations map[string]string `db:"-"`
+
 	// timer calls RetriggerEscalations the next time any escalation could be reached on the incident.
 	//
 	// For example, if there are escalations configured for incident_age>=1h and incident_age>=2h, if the incident
@@ -44,10 +67,37 @@ type Incident struct {
 	// be reached solely based on the incident aging, so no more timer is necessary and timer stores nil.
 	timer *time.Timer
 
+	// timerDeadline is the time at which the current timer fires, allowing to only replace it with an earlier one.
+	timerDeadline time.Time
+
+	// lastNotifiedAt is the time of the last successfully sent notification, used for notification_unanswered_for.
+	lastNotifiedAt time.Time
+
+	// lastEventAt is the time of the last event received for this incident, used to close inactive incidents.
+	lastEventAt time.Time
+
+	// renotifyTimer calls Renotify the next time any triggered escalation's renotify interval elapses.
+	renotifyTimer *time.Timer
+
+	// renotifiedAt maps escalation IDs to the time of their last renotification, see Renotify.
+	renotifiedAt map[escalationID]time.Time
+
+	// snoozedUntil postpones renotifications and time-based escalations up to this time, see Snooze.
+	snoozedUntil time.Time
+
 	// isMuted indicates whether the current Object was already muted before the ongoing event.Event being processed.
 	// This prevents us from generating multiple muted histories when receiving several events that mute our Object.
 	isMuted bool
 
+	// mutedEvents counts the events received while muted since the last summary, sent by mutedSummaryTimer, see
+	// recordMutedEvent.
+	mutedEvents       int
+	mutedSummaryTimer *time.Timer
+
+	// throttleReservations holds the notifications counted by throttleNotification within the ongoing transaction, see
+	// settleThrottle.
+	throttleReservations []*throttleReservation
+
 	db            *database.DB
 	logger        *zap.SugaredLogger
 	runtimeConfig *config.RuntimeConfig
@@ -66,6 +116,10 @@ func NewIncident(
 		EscalationState: map[escalationID]*EscalationState{},
 		Rules:           map[ruleID]struct{}{},
 		Recipients:      map[recipient.Key]*RecipientState{},
+		Watches:         map[int64]*WatchRow{},
+		ChannelStates:   map[int64]json.RawMessage{},
+		Annotations:     map[string]string{},
+		renotifiedAt:    map[escalationID]time.Time{},
 	}
 
 	if obj != nil {
@@ -92,25 +146,50 @@ func (i *Incident) ID() int64 {
 }
 
 func (i *Incident) HasManager() bool {
+	return i.countManagers() > 0
+}
+
+// countManagers returns the number of distinct managers of this incident, ignoring deleted recipients.
+func (i *Incident) countManagers() int64 {
+	var managers int64
 	for recipientKey, state := range i.Recipients {
 		if i.runtimeConfig.GetRecipient(recipientKey) == nil {
 			i.logger.Debugw("Incident refers unknown recipient key, might got deleted", zap.Inline(recipientKey))
 			continue
 		}
 		if state.Role == RoleManager {
-			return true
+			managers++
 		}
 	}
 
-	return false
+	return managers
+}
+
+// requiredAcknowledgements returns the number of managers required for this incident to be handled, being the highest
+// rule.Escalation.RequiredAcknowledgements of all triggered escalations, but at least one.
+func (i *Incident) requiredAcknowledgements() int64 {
+	required := int64(1)
+	for escalationID := range i.EscalationState {
+		if escalation := i.runtimeConfig.GetRuleEscalation(escalationID); escalation != nil {
+			required = max(required, escalation.RequiredAcknowledgements)
+		}
+	}
+
+	return required
+}
+
+// IsHandled returns whether the incident has as many managers as required by its triggered escalations, see
+// rule.Escalation.RequiredAcknowledgements. Without a quorum configured, this is the case once it has any manager.
+func (i *Incident) IsHandled() bool {
+	return i.countManagers() >= i.requiredAcknowledgements()
 }
 
 // IsNotifiable returns whether contacts in the given role should be notified about this incident.
 //
-// For a managed incident, only managers and subscribers should be notified, for unmanaged incidents,
+// For a handled incident, only managers and subscribers should be notified, for unhandled incidents,
 // regular recipients are notified as well.
 func (i *Incident) IsNotifiable(role ContactRole) bool {
-	if !i.HasManager() {
+	if !i.IsHandled() {
 		return true
 	}
 
@@ -118,7 +197,14 @@ func (i *Incident) IsNotifiable(role ContactRole) bool {
 }
 
 // ProcessEvent processes the given event for the current incident in an own transaction.
+//
+// If the transaction is aborted due to a conflict with a concurrent one, e.g., a deadlock, the incident's in-memory
+// state is restored and the event is processed again in a new transaction, see utils.RetryTx.
 func (i *Incident) ProcessEvent(ctx context.Context, ev *event.Event) error {
+	// Synthetic events, e.g., closing an inactive incident, are traced as well.
+	ev.EnsureTraceID()
+	ctx = event.ContextWithTraceID(ctx, ev.TraceID)
+
 	i.Lock()
 	defer i.Unlock()
 
@@ -136,23 +222,72 @@ func (i *Incident) ProcessEvent(ctx context.Context, ev *event.Event) error {
 		return event.ErrSuperfluousMuteUnmuteEvent
 	}
 
+	wasOpen := i.RecoveredAt.Time().IsZero()
+
+	var notifyEv *event.Event
+	var notifications []*NotificationEntry
+	err := utils.RetryTx(ctx, func() error {
+		snapshot := i.snapshot(ev)
+
+		var err error
+		notifyEv, notifications, err = i.processEventInTx(ctx, ev)
+		if err != nil {
+			snapshot.restore()
+		}
+
+		return err
+	})
+	i.settleThrottle(err == nil)
+	if errors.Is(err, errSuperfluousAckEvent) {
+		// That ack error type indicates that the acknowledgement author was already a manager, thus
+		// we can safely ignore that event without even having committed the DB transaction.
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if wasOpen && !i.RecoveredAt.Time().IsZero() {
+		RemoveCurrent(i.Object)
+	}
+	i.updateCriticalObject()
+
+	i.recordMutedEvent(ev, daemon.Config().MutedSummary.Interval)
+
+	// We've just committed the DB transaction and can safely update the incident muted flag.
+	i.isMuted = i.Object.IsMuted()
+
+	// Newly triggered escalations, managers or a recovery affect upcoming renotifications.
+	i.scheduleRenotification()
+
+	return i.notifyContacts(ctx, notifyEv, notifications)
+}
+
+// processEventInTx processes the given event within a new transaction, which is committed on success.
+//
+// It returns the event to notify the recipients about, which might differ from the given one, e.g., when the object
+// started flapping, together with the pending notifications.
+func (i *Incident) processEventInTx(ctx context.Context, ev *event.Event) (*event.Event, []*NotificationEntry, error) {
+	trace := latency.FromContext(ctx)
+	stopDBSync := trace.Track(latency.PhaseDBSync)
+
 	tx, err := i.db.BeginTxx(ctx, nil)
 	if err != nil {
 		i.logger.Errorw("Cannot start a db transaction", zap.Error(err))
-		return err
+		return nil, nil, err
 	}
 	defer func() { _ = tx.Rollback() }()
 
 	if err = ev.Sync(ctx, tx, i.db, i.Object.ID); err != nil {
 		i.logger.Errorw("Failed to insert event and fetch its ID", zap.String("event", ev.String()), zap.Error(err))
-		return err
+		return nil, nil, err
 	}
 
+	oldSeverity := i.Severity
 	isNew := i.StartedAt.Time().IsZero()
 	if isNew {
 		err = i.processIncidentOpenedEvent(ctx, tx, ev)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 
 		i.logger = i.logger.With(zap.String("incident", i.String()))
@@ -160,86 +295,138 @@ func (i *Incident) ProcessEvent(ctx context.Context, ev *event.Event) error {
 
 	if err = i.AddEvent(ctx, tx, ev); err != nil {
 		i.logger.Errorw("Cannot insert incident event to the database", zap.Error(err))
-		return err
+		return nil, nil, err
+	}
+	stopDBSync()
+
+	if ev.Type != event.TypeMutedSummary {
+		// Summaries are synthesized by the daemon and thus no sign of life of the source, see sendMutedSummary.
+		i.lastEventAt = ev.Time
 	}
 
 	if err := i.handleMuteUnmute(ctx, tx, ev); err != nil {
 		i.logger.Errorw("Cannot insert incident muted history", zap.String("event", ev.String()), zap.Error(err))
-		return err
+		return nil, nil, err
 	}
 
+	// flapEv is only set if the object just started flapping, replacing the notifications of this event.
+	var flapEv *event.Event
+
 	switch ev.Type {
 	case event.TypeState:
 		if !isNew {
 			if err := i.processSeverityChangedEvent(ctx, tx, ev); err != nil {
-				return err
+				return nil, nil, err
 			}
 		}
+		ev.SeverityChange = event.SeverityChangeOf(oldSeverity, i.Severity)
+
+		flapEv, err = i.processFlapping(ctx, tx, ev)
+		if err != nil {
+			return nil, nil, err
+		}
 
 		// Check if any (additional) rules match this object. Filters of rules that already have a state don't have
 		// to be checked again, these rules already matched and stay effective for the ongoing incident.
+		stopRuleEval := trace.Track(latency.PhaseRuleEval)
 		err = i.evaluateRules(ctx, tx, ev.ID)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
+		stopRuleEval()
 
 		// Re-evaluate escalations based on the newly evaluated rules.
-		escalations, err := i.evaluateEscalations(ev.Time)
+		stopEscalationEval := trace.Track(latency.PhaseEscalationEval)
+		escalations, err := i.evaluateEscalations(ev.Time, oldSeverity)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 
 		if err := i.triggerEscalations(ctx, tx, ev, escalations); err != nil {
-			return err
+			return nil, nil, err
 		}
+		stopEscalationEval()
 	case event.TypeAcknowledgementSet:
 		if err := i.processAcknowledgementEvent(ctx, tx, ev); err != nil {
-			if errors.Is(err, errSuperfluousAckEvent) {
-				// That ack error type indicates that the acknowledgement author was already a manager, thus
-				// we can safely ignore that event and return without even committing the DB transaction.
-				return nil
-			}
-
-			return err
+			return nil, nil, err
 		}
 	}
 
-	var notifications []*NotificationEntry
-	notifications, err = i.generateNotifications(ctx, tx, ev, i.getRecipientsChannel(ev.Time))
+	contactChs := i.getRecipientsChannel(ev.Time, oldSeverity)
+	i.loadWatcherChannels(contactChs, oldSeverity)
+
+	notifications, err := i.generateNotifications(ctx, tx, ev, contactChs, Notified)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
+	notifyEv := ev
+	if flapEv != nil {
+		// The notifications of the state event itself were just suppressed, as the object is flapping now.
+		notifications, err = i.generateNotifications(ctx, tx, flapEv, contactChs, Notified)
+		if err != nil {
+			return nil, nil, err
+		}
+		notifyEv = flapEv
+	}
+
+	if !i.RecoveredAt.Time().IsZero() {
+		if err := i.removeWatches(ctx, tx); err != nil {
+			i.logger.Errorw("Cannot remove watches of the closed incident", zap.Error(err))
+			return nil, nil, err
+		}
+	}
+
+	stopCommit := trace.Track(latency.PhaseDBSync)
 	if err = tx.Commit(); err != nil {
 		i.logger.Errorw("Cannot commit db transaction", zap.Error(err))
-		return err
+		return nil, nil, err
 	}
+	stopCommit()
 
-	// We've just committed the DB transaction and can safely update the incident muted flag.
-	i.isMuted = i.Object.IsMuted()
-
-	return i.notifyContacts(ctx, ev, notifications)
+	return notifyEv, notifications, nil
 }
 
 // RetriggerEscalations tries to re-evaluate the escalations and notify contacts.
 func (i *Incident) RetriggerEscalations(ev *event.Event) {
+	if !inflight.begin() {
+		i.logger.Debug("Not re-evaluating escalations while shutting down")
+		return
+	}
+	defer inflight.end()
+
 	i.Lock()
 	defer i.Unlock()
 
 	i.runtimeConfig.RLock()
 	defer i.runtimeConfig.RUnlock()
 
+	defer i.scheduleRenotification()
+
 	if !i.RecoveredAt.Time().IsZero() {
 		// Incident is recovered in the meantime.
 		return
 	}
 
-	if !time.Now().After(ev.Time) {
+	now := time.Now()
+	if !now.After(ev.Time) {
 		i.logger.DPanicw("Event from the future", zap.Time("event_time", ev.Time), zap.Any("event", ev))
 		return
 	}
 
-	escalations, err := i.evaluateEscalations(ev.Time)
+	if now.Before(i.snoozedUntil) {
+		i.logger.Debugw("Postponing escalation reevaluation of snoozed incident", zap.Time("until", i.snoozedUntil))
+
+		// This might be called by the current timer, which must not prevent scheduling the next one.
+		if i.timer != nil {
+			i.timer.Stop()
+			i.timer = nil
+		}
+		i.scheduleReevaluation(now, i.snoozedUntil.Sub(now))
+		return
+	}
+
+	escalations, err := i.evaluateEscalations(ev.Time, i.Severity)
 	if err != nil {
 		i.logger.Errorw("Reevaluating time-based escalations failed", zap.Error(err))
 		return
@@ -250,9 +437,24 @@ func (i *Incident) RetriggerEscalations(ev *event.Event) {
 		return
 	}
 
+	if err := i.notifyEscalations(context.Background(), ev, escalations); err != nil {
+		i.logger.Errorw("Reevaluating time-based escalations failed", zap.Error(err))
+		return
+	}
+
+	i.logger.Info("Successfully reevaluated time-based escalations")
+}
+
+// notifyEscalations triggers the given escalations in an own transaction and notifies their recipients about the event.
+//
+// The incident and the runtime config must be locked by the caller.
+func (i *Incident) notifyEscalations(ctx context.Context, ev *event.Event, escalations []*rule.Escalation) error {
 	var notifications []*NotificationEntry
-	ctx := context.Background()
-	err = utils.RunInTx(ctx, i.db, func(tx *sqlx.Tx) error {
+	snapshot := i.snapshot(ev)
+	err := utils.RunInTx(ctx, i.db, func(tx *sqlx.Tx) error {
+		// Undo the changes of a previous attempt, as its transaction was rolled back.
+		snapshot.restore()
+
 		err := ev.Sync(ctx, tx, i.db, i.Object.ID)
 		if err != nil {
 			return err
@@ -271,19 +473,19 @@ func (i *Incident) RetriggerEscalations(ev *event.Event) {
 			channels.LoadFromEscalationRecipients(escalation, ev.Time, i.isRecipientNotifiable)
 		}
 
-		notifications, err = i.generateNotifications(ctx, tx, ev, channels)
+		notifications, err = i.generateNotifications(ctx, tx, ev, channels, Notified)
 		return err
 	})
+	i.settleThrottle(err == nil)
 	if err != nil {
-		i.logger.Errorw("Reevaluating time-based escalations failed", zap.Error(err))
-	} else {
-		if err = i.notifyContacts(ctx, ev, notifications); err != nil {
-			i.logger.Errorw("Failed to notify reevaluated escalation recipients", zap.Error(err))
-			return
-		}
+		return err
+	}
 
-		i.logger.Info("Successfully reevaluated time-based escalations")
+	if err := i.notifyContacts(ctx, ev, notifications); err != nil {
+		return fmt.Errorf("cannot notify escalation recipients: %w", err)
 	}
+
+	return nil
 }
 
 func (i *Incident) processSeverityChangedEvent(ctx context.Context, tx *sqlx.Tx, ev *event.Event) error {
@@ -315,8 +517,6 @@ func (i *Incident) processSeverityChangedEvent(ctx context.Context, tx *sqlx.Tx,
 		i.RecoveredAt = types.UnixMilli(time.Now())
 		i.logger.Info("All sources recovered, closing incident")
 
-		RemoveCurrent(i.Object)
-
 		hr = &HistoryRow{
 			IncidentID: i.Id,
 			EventID:    utils.ToDBInt(ev.ID),
@@ -331,6 +531,7 @@ func (i *Incident) processSeverityChangedEvent(ctx context.Context, tx *sqlx.Tx,
 
 		if i.timer != nil {
 			i.timer.Stop()
+			i.timer = nil
 		}
 	}
 
@@ -404,7 +605,7 @@ func (i *Incident) evaluateRules(ctx context.Context, tx *sqlx.Tx, eventID int64
 	}
 
 	for _, r := range i.runtimeConfig.Rules {
-		if _, ok := i.Rules[r.ID]; !ok {
+		if _, ok := i.Rules[r.ID]; !ok && i.isVisible(r) {
 			matched, err := r.Eval(i.Object)
 			if err != nil {
 				i.logger.Warnw("Failed to evaluate object filter", zap.Object("rule", r), zap.Error(err))
@@ -440,9 +641,10 @@ func (i *Incident) evaluateRules(ctx context.Context, tx *sqlx.Tx, eventID int64
 	return nil
 }
 
-// evaluateEscalations evaluates this incidents rule escalations to be triggered if they aren't already.
+// evaluateEscalations evaluates this incidents rule escalations to be triggered if they aren't already, oldSeverity
+// being the incident's severity before the current event.
 // Returns the newly evaluated escalations to be triggered or an error on database failure.
-func (i *Incident) evaluateEscalations(eventTime time.Time) ([]*rule.Escalation, error) {
+func (i *Incident) evaluateEscalations(eventTime time.Time, oldSeverity event.Severity) ([]*rule.Escalation, error) {
 	if i.EscalationState == nil {
 		i.EscalationState = make(map[int64]*EscalationState)
 	}
@@ -453,9 +655,10 @@ func (i *Incident) evaluateEscalations(eventTime time.Time) ([]*rule.Escalation,
 		i.logger.Info("Stopping reevaluate timer due to escalation evaluation")
 		i.timer.Stop()
 		i.timer = nil
+		i.timerDeadline = time.Time{}
 	}
 
-	filterContext := &rule.EscalationFilter{IncidentAge: eventTime.Sub(i.StartedAt.Time()), IncidentSeverity: i.Severity}
+	filterContext := i.escalationFilter(eventTime, oldSeverity)
 
 	var escalations []*rule.Escalation
 	retryAfter := rule.RetryNever
@@ -467,9 +670,10 @@ func (i *Incident) evaluateEscalations(eventTime time.Time) ([]*rule.Escalation,
 			continue
 		}
 
-		// Check if new escalation stages are reached
+		// Check if new escalation stages are reached. Escalations of a policy shared by multiple rules are only
+		// evaluated once.
 		for _, escalation := range r.Escalations {
-			if _, ok := i.EscalationState[escalation.ID]; !ok {
+			if _, ok := i.EscalationState[escalation.ID]; !ok && !slices.Contains(escalations, escalation) {
 				matched, err := escalation.Eval(filterContext)
 				if err != nil {
 					i.logger.Warnw(
@@ -487,34 +691,125 @@ func (i *Incident) evaluateEscalations(eventTime time.Time) ([]*rule.Escalation,
 	}
 
 	if retryAfter != rule.RetryNever {
-		// The retryAfter duration is relative to the incident duration represented by the escalation filter,
-		// i.e. if an incident is 15m old and an escalation rule evaluates incident_age>=1h the retryAfter would
-		// contain 45m (1h - incident age (15m)). Therefore, we have to use the event time instead of the incident
-		// start time here.
-		nextEvalAt := eventTime.Add(retryAfter)
-
-		i.logger.Infow("Scheduling escalation reevaluation", zap.Duration("after", retryAfter), zap.Time("at", nextEvalAt))
-		i.timer = time.AfterFunc(retryAfter, func() {
-			i.logger.Info("Reevaluating escalations")
-
-			i.RetriggerEscalations(&event.Event{
-				Time:    nextEvalAt,
-				Type:    event.TypeIncidentAge,
-				Message: fmt.Sprintf("Incident reached age %v", nextEvalAt.Sub(i.StartedAt.Time())),
-			})
-		})
+		i.scheduleReevaluation(eventTime, retryAfter)
 	}
 
 	return escalations, nil
 }
 
+// escalationFilter returns the rule.EscalationFilter representing this incident at the given time, after its severity
+// changed from oldSeverity. Pass the current severity if the evaluated event didn't change it.
+func (i *Incident) escalationFilter(t time.Time, oldSeverity event.Severity) *rule.EscalationFilter {
+	filterContext := &rule.EscalationFilter{
+		IncidentAge:      t.Sub(i.StartedAt.Time()),
+		IncidentSeverity: i.Severity,
+		SeverityChange:   event.SeverityChangeOf(oldSeverity, i.Severity),
+		Time:             t,
+		TimePeriods:      i.runtimeConfig.GetTimePeriod,
+	}
+	if i.Object != nil {
+		filterContext.Object = i.Object
+	}
+	if !i.lastNotifiedAt.IsZero() && !i.IsHandled() {
+		filterContext.NotificationUnanswered = true
+		filterContext.NotificationUnansweredFor = max(t.Sub(i.lastNotifiedAt), 0)
+	}
+
+	return filterContext
+}
+
+// scheduleReevaluation starts a timer to reevaluate the escalations after retryAfter, relative to the eventTime.
+//
+// An already running timer is only replaced if the new one fires earlier.
+func (i *Incident) scheduleReevaluation(eventTime time.Time, retryAfter time.Duration) {
+	deadline := time.Now().Add(retryAfter)
+	if i.timer != nil {
+		if !deadline.Before(i.timerDeadline) {
+			return
+		}
+
+		i.timer.Stop()
+	}
+
+	// The retryAfter duration is relative to the incident duration represented by the escalation filter,
+	// i.e. if an incident is 15m old and an escalation rule evaluates incident_age>=1h the retryAfter would
+	// contain 45m (1h - incident age (15m)). Therefore, we have to use the event time instead of the incident
+	// start time here.
+	nextEvalAt := eventTime.Add(retryAfter)
+
+	i.logger.Infow("Scheduling escalation reevaluation", zap.Duration("after", retryAfter), zap.Time("at", nextEvalAt))
+	i.timerDeadline = deadline
+	i.timer = time.AfterFunc(retryAfter, func() {
+		i.logger.Info("Reevaluating escalations")
+
+		i.RetriggerEscalations(&event.Event{
+			Time:    nextEvalAt,
+			Type:    event.TypeIncidentAge,
+			Message: fmt.Sprintf("Incident reached age %v", nextEvalAt.Sub(i.StartedAt.Time())),
+		})
+	})
+}
+
+// scheduleUnansweredReevaluation schedules a reevaluation for escalations with a notification_unanswered_for
+// condition, after a notification was just sent at the given time.
+func (i *Incident) scheduleUnansweredReevaluation(notifiedAt time.Time) {
+	filterContext := i.escalationFilter(notifiedAt, i.Severity)
+	if !filterContext.NotificationUnanswered {
+		return
+	}
+
+	retryAfter := rule.RetryNever
+	for rID := range i.Rules {
+		r := i.runtimeConfig.Rules[rID]
+		if r == nil {
+			continue
+		}
+
+		for _, escalation := range r.Escalations {
+			if _, ok := i.EscalationState[escalation.ID]; !ok && escalation.Condition != nil {
+				retryAfter = min(retryAfter, filterContext.ReevaluateAfter(escalation.Condition))
+			}
+		}
+	}
+
+	if retryAfter != rule.RetryNever {
+		i.scheduleReevaluation(notifiedAt, retryAfter)
+	}
+}
+
+// escalationRule returns the rule the escalation was reached for, or nil if the rule is unknown.
+//
+// An escalation of an escalation policy is shared by all rules referencing the policy, see rule.EscalationPolicy. Thus,
+// the incident's matched rule with the lowest ID including the escalation is used for it.
+func (i *Incident) escalationRule(escalation *rule.Escalation) *rule.Rule {
+	if escalation.RuleID.Valid {
+		return i.runtimeConfig.Rules[escalation.RuleID.Int64]
+	}
+
+	var match *rule.Rule
+	for rID := range i.Rules {
+		r := i.runtimeConfig.Rules[rID]
+		if r != nil && r.Escalations[escalation.ID] != nil && (match == nil || r.ID < match.ID) {
+			match = r
+		}
+	}
+
+	return match
+}
+
 // triggerEscalations triggers the given escalations and generates incident history items for each of them.
+// In read-only mode, no escalations are triggered at all, see SetReadOnly.
 // Returns an error on database failure.
 func (i *Incident) triggerEscalations(ctx context.Context, tx *sqlx.Tx, ev *event.Event, escalations []*rule.Escalation) error {
+	if len(escalations) > 0 && ReadOnly() {
+		i.logger.Warnw("Not triggering escalations in read-only mode", zap.Int("escalations", len(escalations)))
+		return nil
+	}
+
 	for _, escalation := range escalations {
-		r := i.runtimeConfig.Rules[escalation.RuleID]
+		r := i.escalationRule(escalation)
 		if r == nil {
-			i.logger.Debugw("Incident refers unknown rule, might got deleted", zap.Int64("rule_id", escalation.RuleID))
+			i.logger.Debugw("Incident refers unknown rule, might got deleted", zap.Object("escalation", escalation))
 			continue
 		}
 
@@ -558,7 +853,21 @@ func (i *Incident) triggerEscalations(ctx context.Context, tx *sqlx.Tx, ev *even
 
 // notifyContacts executes all the given pending notifications of the current incident.
 // Returns error on database failure or if the provided context is cancelled.
+//
+// Notifications are not abandoned when the context is cancelled, e.g., by stopping the daemon, but only once draining
+// timed out, see Drain. Those not executed by then stay pending in the incident history.
 func (i *Incident) notifyContacts(ctx context.Context, ev *event.Event, notifications []*NotificationEntry) error {
+	ctx, cancel := inflight.detach(ctx)
+	defer cancel()
+
+	notified := false
+	defer func() {
+		if notified && i.RecoveredAt.Time().IsZero() {
+			i.lastNotifiedAt = time.Now()
+			i.scheduleUnansweredReevaluation(i.lastNotifiedAt)
+		}
+	}()
+
 	for _, notification := range notifications {
 		contact := i.runtimeConfig.Contacts[notification.ContactID]
 		if contact == nil {
@@ -566,10 +875,13 @@ func (i *Incident) notifyContacts(ctx context.Context, ev *event.Event, notifica
 			continue
 		}
 
-		if i.notifyContact(contact, ev, notification.ChannelID) != nil {
+		err := i.notifyContact(ctx, contact, ev, notification.ChannelID)
+		deliveryFailures.record(contact.ID, notification.ChannelID, err != nil, time.Now())
+		if err != nil {
 			notification.State = NotificationStateFailed
 		} else {
 			notification.State = NotificationStateSent
+			notified = true
 		}
 
 		notification.SentAt = types.UnixMilli(time.Now())
@@ -590,38 +902,105 @@ func (i *Incident) notifyContacts(ctx context.Context, ev *event.Event, notifica
 }
 
 // notifyContact notifies the given recipient via a channel matching the given ID.
-func (i *Incident) notifyContact(contact *recipient.Contact, ev *event.Event, chID int64) error {
+//
+// Failures to reach the channel plugin, e.g., as it is being restarted, are retried for up to notifyRetryTimeout.
+// Errors reported by the plugin itself (errs.ErrChannelPermanent) and unknown channels (errs.ErrConfigMissing) are not.
+func (i *Incident) notifyContact(ctx context.Context, contact *recipient.Contact, ev *event.Event, chID int64) error {
+	logger := i.logger.With(zap.String("trace_id", ev.TraceID))
+
 	ch := i.runtimeConfig.Channels[chID]
 	if ch == nil {
-		i.logger.Errorw("Could not find config for channel", zap.Int64("channel_id", chID))
+		logger.Errorw("Could not find config for channel", zap.Int64("channel_id", chID))
 
-		return fmt.Errorf("could not find config for channel ID: %d", chID)
+		return fmt.Errorf("%w: could not find config for channel ID: %d", errs.ErrConfigMissing, chID)
 	}
 
-	i.logger.Infow(fmt.Sprintf("Notify contact %q via %q of type %q", contact.FullName, ch.Name, ch.Type),
+	logger.Infow(fmt.Sprintf("Notify contact %q via %q of type %q", contact.FullName, ch.Name, ch.Type),
 		zap.Int64("channel_id", chID), zap.String("event_type", ev.Type))
 
-	err := ch.Notify(contact, i, ev, daemon.Config().Icingaweb2URL)
+	rules := i.getContributingRules(contact, chID, ev.Time)
+	req := channel.NewNotificationRequest(contact, i.getContactReasons(contact, ev.Time), i, ev,
+		daemon.Config().Icingaweb2URL, i.acknowledgeURL(contact, ev))
+	for _, r := range rules {
+		req.Rules = append(req.Rules, &plugin.Rule{
+			Name:        r.Name,
+			Description: r.Description.String,
+			RunbookUrl:  r.RunbookURL.String,
+			ChatChannel: r.ChatChannel.String,
+		})
+	}
+	i.renderNotification(ctx, req, rules, chID)
+	req.State = i.ChannelStates[chID]
+
+	var state json.RawMessage
+	trace := latency.FromContext(ctx)
+	stopPluginCall := trace.Track(latency.PhasePluginCall)
+	err := retry.WithBackoff(
+		ctx,
+		func(context.Context) (err error) {
+			state, err = ch.Notify(req)
+			return err
+		},
+		func(err error) bool { return !errors.Is(err, errs.ErrChannelPermanent) },
+		backoff.NewExponentialWithJitter(100*time.Millisecond, 2*time.Second),
+		retry.Settings{
+			Timeout: notifyRetryTimeout,
+			OnRetryableError: func(_ time.Duration, attempt uint64, err, _ error) {
+				logger.Warnw("Failed to send notification via channel plugin, retrying", zap.String("type", ch.Type),
+					zap.Uint64("attempt", attempt), zap.Error(err))
+			},
+		},
+	)
+	stopPluginCall()
 	if err != nil {
-		i.logger.Errorw("Failed to send notification via channel plugin", zap.String("type", ch.Type), zap.Error(err))
+		logger.Errorw("Failed to send notification via channel plugin", zap.String("type", ch.Type), zap.Error(err))
 		return err
 	}
 
-	i.logger.Infow("Successfully sent a notification via channel plugin", zap.String("type", ch.Type),
+	if state != nil && !bytes.Equal(state, i.ChannelStates[chID]) {
+		i.updateChannelState(ctx, chID, state)
+	}
+
+	slo := daemon.Config().NotificationLatency.SLO
+	if sample, exceeded := latency.Default.Record(trace, time.Now(), slo); exceeded {
+		fields := []any{zap.Duration("latency", sample.Total), zap.Duration("slo", slo)}
+		for phase, d := range sample.Phases {
+			fields = append(fields, zap.Duration(string(phase), d))
+		}
+		logger.Warnw("First notification of event exceeded the latency SLO", fields...)
+	}
+
+	logger.Infow("Successfully sent a notification via channel plugin", zap.String("type", ch.Type),
 		zap.String("contact", contact.FullName), zap.String("event_type", ev.Type))
 
 	return nil
 }
 
+// updateChannelState stores the state reported by the channel's plugin, passed back with its next notification.
+//
+// Failing to persist it is only logged, as the notification was sent nonetheless. The state is kept in memory anyway,
+// thus it is only lost when the daemon is restarted.
+func (i *Incident) updateChannelState(ctx context.Context, chID int64, state json.RawMessage) {
+	i.ChannelStates[chID] = state
+
+	row := &ChannelStateRow{IncidentID: i.Id, ChannelID: chID, State: string(state), ChangedAt: types.UnixMilli(time.Now())}
+	stmt, _ := i.db.BuildUpsertStmt(row)
+	if _, err := i.db.NamedExecContext(ctx, stmt, row); err != nil {
+		i.logger.Errorw("Failed to upsert channel state of incident", zap.Int64("channel_id", chID), zap.Error(err))
+	}
+}
+
 // errSuperfluousAckEvent is returned when the same ack author submits two successive ack set events on an incident.
 // This is error is going to be used only within this incident package.
 var errSuperfluousAckEvent = errors.New("superfluous acknowledgement set event, author is already a manager")
 
 // processAcknowledgementEvent processes the given ack event.
 // Promotes the ack author to incident.RoleManager if it's not already the case and generates a history entry.
+// Directives within the comment, e.g., "ticket=INC1234", are stored as annotations of the incident, see addAnnotations.
+// If the triggered escalations require multiple acknowledgements, reaching their quorum is recorded in the history.
 // Returns error on database failure.
 func (i *Incident) processAcknowledgementEvent(ctx context.Context, tx *sqlx.Tx, ev *event.Event) error {
-	contact := i.runtimeConfig.GetContact(ev.Username)
+	contact := i.runtimeConfig.GetTenantContact(i.runtimeConfig.GetSourceTenant(i.Object.SourceID), ev.Username)
 	if contact == nil {
 		i.logger.Warnw("Ignoring acknowledgement event from an unknown author", zap.String("author", ev.Username))
 
@@ -640,6 +1019,8 @@ func (i *Incident) processAcknowledgementEvent(ctx context.Context, tx *sqlx.Tx,
 			i.logger.Debugw("Ignoring acknowledgement-set event, author is already a manager", zap.String("author", ev.Username))
 			return errSuperfluousAckEvent
 		}
+
+		state.Role = newRole
 	} else {
 		i.Recipients[recipientKey] = &RecipientState{Role: newRole}
 	}
@@ -671,11 +1052,36 @@ func (i *Incident) processAcknowledgementEvent(ctx context.Context, tx *sqlx.Tx,
 		return err
 	}
 
+	if err := i.addAnnotations(ctx, tx, ev); err != nil {
+		return err
+	}
+
+	if required := i.requiredAcknowledgements(); required > 1 {
+		managers := i.countManagers()
+		i.logger.Infow("Incident requires multiple acknowledgements",
+			zap.Int64("acknowledgements", managers), zap.Int64("required", required))
+
+		if managers == required {
+			hr := &HistoryRow{
+				IncidentID: i.Id,
+				EventID:    utils.ToDBInt(ev.ID),
+				Type:       AcknowledgementQuorumReached,
+				Time:       types.UnixMilli(time.Now()),
+				Message:    utils.ToDBString(fmt.Sprintf("acknowledged by %d of %d required contacts", managers, required)),
+			}
+			if err := hr.Sync(ctx, i.db, tx); err != nil {
+				i.logger.Errorw("Failed to add acknowledgement quorum reached history", zap.Error(err))
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
-// getRecipientsChannel returns all the configured channels of the current incident and escalation recipients.
-func (i *Incident) getRecipientsChannel(t time.Time) rule.ContactChannels {
+// getRecipientsChannel returns all the configured channels of the current incident and escalation recipients,
+// restricted to the preferences of the contacts, see applyContactPreferences.
+func (i *Incident) getRecipientsChannel(t time.Time, oldSeverity event.Severity) rule.ContactChannels {
 	contactChs := make(rule.ContactChannels)
 	// Load all escalations recipients channels
 	for escalationID := range i.EscalationState {
@@ -685,6 +1091,15 @@ func (i *Incident) getRecipientsChannel(t time.Time) rule.ContactChannels {
 			continue
 		}
 
+		if escalation.DependsOnSeverityChange() {
+			// Such escalations only notify of events matching their condition, see Escalation.DependsOnSeverityChange.
+			if matched, err := escalation.Eval(i.escalationFilter(t, oldSeverity)); err != nil || !matched {
+				i.logger.Debugw("Not notifying recipients of escalation not matching the severity change",
+					zap.Object("escalation", escalation), zap.Error(err))
+				continue
+			}
+		}
+
 		contactChs.LoadFromEscalationRecipients(escalation, t, i.isRecipientNotifiable)
 	}
 
@@ -717,9 +1132,192 @@ func (i *Incident) getRecipientsChannel(t time.Time) rule.ContactChannels {
 		}
 	}
 
+	i.applyContactPreferences(contactChs, t, oldSeverity)
+
 	return contactChs
 }
 
+// renderNotification renders the subject and message of the notification request from the template configured for the
+// channel and the rules of the escalations resolving to the contact, see getContributingRules and
+// config.RuntimeConfig.GetNotificationTemplate.
+//
+// If the template fails to render, e.g., due to referring a non-existent field, the defaults are used instead, as a
+// broken template must not prevent notifications from being sent.
+func (i *Incident) renderNotification(
+	ctx context.Context, req *plugin.NotificationRequest, rules []*rule.Rule, chID int64,
+) {
+	ruleIDs := make([]int64, 0, len(rules))
+	for _, r := range rules {
+		ruleIDs = append(ruleIDs, r.ID)
+	}
+
+	tmpl := i.runtimeConfig.GetNotificationTemplate(chID, ruleIDs)
+	data := msgtemplate.NewData(req, func() ([]*msgtemplate.HistoryEntry, error) { return i.templateHistory(ctx) })
+	data.Annotations = i.Annotations
+
+	subject, message, err := tmpl.Render(data)
+	if err != nil && tmpl != nil {
+		i.logger.Warnw("Failed to render notification template, using the default instead",
+			zap.Object("template", tmpl), zap.Error(err))
+
+		subject, message, err = msgtemplate.RenderDefault(data)
+	}
+	if err != nil {
+		i.logger.Errorw("Failed to render default notification template", zap.Error(err))
+		return
+	}
+
+	req.Subject = subject
+	req.Message = message
+}
+
+// templateHistory loads the latest history entries of this incident to be used in notification templates.
+func (i *Incident) templateHistory(ctx context.Context) ([]*msgtemplate.HistoryEntry, error) {
+	var rows []struct {
+		Time     types.UnixMilli `db:"time"`
+		Type     string          `db:"type"`
+		Severity types.String    `db:"new_severity"`
+		Message  types.String    `db:"message"`
+	}
+
+	stmt := i.db.Rebind(fmt.Sprintf(`SELECT "time", "type", "new_severity", "message" FROM "incident_history"
+		WHERE "incident_id" = ? ORDER BY "time" DESC, "id" DESC LIMIT %d`, msgtemplate.HistoryLimit))
+	if err := i.db.SelectContext(ctx, &rows, stmt, i.Id); err != nil {
+		return nil, fmt.Errorf("cannot fetch incident history: %w", err)
+	}
+
+	history := make([]*msgtemplate.HistoryEntry, 0, len(rows))
+	for j := len(rows) - 1; j >= 0; j-- {
+		row := rows[j]
+		history = append(history, &msgtemplate.HistoryEntry{
+			Time:     row.Time.Time(),
+			Type:     row.Type,
+			Severity: row.Severity.String,
+			Message:  row.Message.String,
+		})
+	}
+
+	return history, nil
+}
+
+// getContributingEscalations returns the triggered escalations resolving to the given contact for the given channel at
+// the given time, ordered by their IDs.
+//
+// As overlapping escalations, e.g., of multiple rules, result in a single notification per contact and channel, these
+// are referenced by the notification's history entry.
+func (i *Incident) getContributingEscalations(contact *recipient.Contact, chID int64, t time.Time) []*rule.Escalation {
+	var escalations []*rule.Escalation
+	for escalationID := range i.EscalationState {
+		escalation := i.runtimeConfig.GetRuleEscalation(escalationID)
+		if escalation == nil {
+			continue
+		}
+
+		for _, er := range escalation.Recipients {
+			if !i.isRecipientNotifiable(er.Key) {
+				continue
+			}
+			if er.SelectsSingleContact() && !i.isRecipientNotifiable(recipient.ToKey(contact)) {
+				continue
+			}
+
+			channelID := contact.DefaultChannelID
+			if er.ChannelID.Valid {
+				channelID = er.ChannelID.Int64
+			}
+
+			if channelID == chID && recipient.GetMembershipAt(er.Recipient, contact, t) != nil {
+				escalations = append(escalations, escalation)
+				break
+			}
+		}
+	}
+
+	slices.SortFunc(escalations, func(a, b *rule.Escalation) int { return cmp.Compare(a.ID, b.ID) })
+
+	return escalations
+}
+
+// getContributingRules returns the rules of the escalations resolving to the given contact for the given channel at the
+// given time, see getContributingEscalations, ordered by their IDs.
+func (i *Incident) getContributingRules(contact *recipient.Contact, chID int64, t time.Time) []*rule.Rule {
+	var rules []*rule.Rule
+	for _, escalation := range i.getContributingEscalations(contact, chID, t) {
+		if r := i.escalationRule(escalation); r != nil && !slices.Contains(rules, r) {
+			rules = append(rules, r)
+		}
+	}
+
+	slices.SortFunc(rules, func(a, b *rule.Rule) int { return cmp.Compare(a.ID, b.ID) })
+
+	return rules
+}
+
+// getContactReasons explains why the given contact is notified about the current incident at the given time.
+//
+// Each escalation recipient and each incident recipient with a notifiable role resolving to this contact results in a
+// reason, describing through which group, schedule and rotation the contact was resolved. A contact watching this
+// incident results in an additional reason with the "watcher" role.
+func (i *Incident) getContactReasons(contact *recipient.Contact, t time.Time) []*plugin.Reason {
+	var reasons []*plugin.Reason
+
+	newReason := func(m *recipient.Membership) *plugin.Reason {
+		reason := &plugin.Reason{}
+		if m.Group != nil {
+			reason.Group = m.Group.Name
+		}
+		if m.Schedule != nil {
+			reason.Schedule = m.Schedule.Name
+		}
+		if m.Rotation != nil {
+			reason.Rotation = m.Rotation.Name
+		}
+		return reason
+	}
+
+	for escalationID := range i.EscalationState {
+		escalation := i.runtimeConfig.GetRuleEscalation(escalationID)
+		if escalation == nil {
+			continue
+		}
+
+		for _, escalationRecipient := range escalation.Recipients {
+			if !i.isRecipientNotifiable(escalationRecipient.Key) {
+				continue
+			}
+
+			if m := recipient.GetMembershipAt(escalationRecipient.Recipient, contact, t); m != nil {
+				reason := newReason(m)
+				reason.Escalation = escalation.DisplayName()
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+
+	for recipientKey, state := range i.Recipients {
+		if !i.IsNotifiable(state.Role) {
+			continue
+		}
+
+		r := i.runtimeConfig.GetRecipient(recipientKey)
+		if r == nil {
+			continue
+		}
+
+		if m := recipient.GetMembershipAt(r, contact, t); m != nil {
+			reason := newReason(m)
+			reason.Role = state.Role.String()
+			reasons = append(reasons, reason)
+		}
+	}
+
+	if _, ok := i.Watches[contact.ID]; ok {
+		reasons = append(reasons, &plugin.Reason{Role: "watcher"})
+	}
+
+	return reasons
+}
+
 // restoreRecipients reloads the current incident recipients from the database.
 // Returns error on database failure.
 func (i *Incident) restoreRecipients(ctx context.Context) error {
@@ -753,6 +1351,18 @@ func (i *Incident) isRecipientNotifiable(key recipient.Key) bool {
 	return i.IsNotifiable(state.Role)
 }
 
+// isVisible reports whether the configuration object, e.g., a rule, contact or channel, may be used for this incident,
+// i.e., it belongs to the tenant of the incident's source or is shared, see config.RuntimeConfig.GetSourceTenant.
+//
+// Objects which cannot be scoped to a tenant, e.g., groups, are always visible.
+func (i *Incident) isVisible(obj any) bool {
+	if scoped, ok := obj.(interface{ VisibleTo(types.Int) bool }); ok {
+		return scoped.VisibleTo(i.runtimeConfig.GetSourceTenant(i.Object.SourceID))
+	}
+
+	return true
+}
+
 type EscalationState struct {
 	IncidentID       int64           `db:"incident_id"`
 	RuleEscalationID int64           `db:"rule_escalation_id"`
@@ -0,0 +1,102 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"testing"
+	"time"
+)
+
+func TestIncident_IsHandled(t *testing.T) {
+	t.Parallel()
+
+	single := &rule.Escalation{RequiredAcknowledgements: 1}
+	single.ID = 1
+	quorum := &rule.Escalation{RequiredAcknowledgements: 2}
+	quorum.ID = 2
+
+	r := &rule.Rule{Escalations: map[int64]*rule.Escalation{single.ID: single, quorum.ID: quorum}}
+	r.ID = 1
+
+	runtimeConfig := &config.RuntimeConfig{}
+	runtimeConfig.Rules = map[int64]*rule.Rule{r.ID: r}
+	runtimeConfig.Contacts = map[int64]*recipient.Contact{}
+	var contacts []*recipient.Contact
+	for id := int64(1); id <= 3; id++ {
+		contact := &recipient.Contact{FullName: "contact"}
+		contact.ID = id
+		runtimeConfig.Contacts[id] = contact
+		contacts = append(contacts, contact)
+	}
+
+	i := NewIncident(nil, nil, runtimeConfig, zaptest.NewLogger(t).Sugar())
+	i.lastNotifiedAt = time.Now()
+	i.EscalationState[single.ID] = &EscalationState{RuleEscalationID: single.ID}
+	i.Recipients[recipient.ToKey(contacts[0])] = &RecipientState{Role: RoleRecipient}
+	assert.False(t, i.IsHandled())
+
+	i.Recipients[recipient.ToKey(contacts[0])].Role = RoleManager
+	assert.True(t, i.IsHandled(), "a single manager should handle an incident without quorum")
+	assert.False(t, i.IsNotifiable(RoleRecipient))
+
+	i.EscalationState[quorum.ID] = &EscalationState{RuleEscalationID: quorum.ID, TriggeredAt: types.UnixMilli(time.Now())}
+	assert.Equal(t, int64(2), i.requiredAcknowledgements())
+	assert.False(t, i.IsHandled(), "the quorum of a triggered escalation should be required")
+	assert.True(t, i.IsNotifiable(RoleRecipient), "recipients should be notified until the quorum is reached")
+	assert.True(t, i.escalationFilter(time.Now(), i.Severity).NotificationUnanswered)
+
+	i.Recipients[recipient.ToKey(contacts[1])] = &RecipientState{Role: RoleSubscriber}
+	assert.False(t, i.IsHandled(), "subscribers should not count as acknowledgements")
+
+	i.Recipients[recipient.ToKey(contacts[2])] = &RecipientState{Role: RoleManager}
+	assert.True(t, i.IsHandled())
+	assert.False(t, i.escalationFilter(time.Now(), i.Severity).NotificationUnanswered)
+
+	delete(runtimeConfig.Contacts, contacts[2].ID)
+	assert.False(t, i.IsHandled(), "deleted contacts should not count as acknowledgements")
+}
+
+func TestIncident_escalationRule(t *testing.T) {
+	t.Parallel()
+
+	own := &rule.Escalation{RuleID: utils.ToDBInt(1)}
+	own.ID = 11
+	shared := &rule.Escalation{PolicyID: utils.ToDBInt(5)}
+	shared.ID = 51
+
+	newRule := func(id int64, escalations ...*rule.Escalation) *rule.Rule {
+		r := &rule.Rule{Escalations: map[int64]*rule.Escalation{}}
+		r.ID = id
+		for _, e := range escalations {
+			r.Escalations[e.ID] = e
+		}
+		return r
+	}
+
+	runtimeConfig := &config.RuntimeConfig{}
+	runtimeConfig.Rules = map[int64]*rule.Rule{
+		1: newRule(1, own, shared),
+		2: newRule(2, shared),
+		3: newRule(3, shared),
+	}
+
+	i := NewIncident(nil, nil, runtimeConfig, zaptest.NewLogger(t).Sugar())
+	i.Rules[3] = struct{}{}
+	i.Rules[2] = struct{}{}
+
+	assert.Same(t, runtimeConfig.Rules[1], i.escalationRule(own), "own escalations belong to their rule")
+	assert.Same(t, runtimeConfig.Rules[2], i.escalationRule(shared), "lowest matched rule sharing the policy")
+
+	escalations, err := i.evaluateEscalations(time.Now(), i.Severity)
+	assert.NoError(t, err)
+	assert.Equal(t, []*rule.Escalation{shared}, escalations, "shared escalations should only be reached once")
+
+	delete(i.Rules, 2)
+	delete(i.Rules, 3)
+	assert.Nil(t, i.escalationRule(shared), "no matched rule shares the policy")
+}
@@ -2,19 +2,26 @@ package incident
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/icinga/icinga-go-library/com"
 	"github.com/icinga/icinga-go-library/database"
 	"github.com/icinga/icinga-go-library/logging"
 	"github.com/icinga/icinga-go-library/types"
 	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/enrichment"
+	"github.com/icinga/icinga-notifications/internal/errs"
 	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/latency"
 	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/icinga/icinga-notifications/internal/shard"
+	"github.com/icinga/icinga-notifications/internal/tagcatalog"
 	"github.com/icinga/icinga-notifications/internal/utils"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
+	"maps"
 	"sync"
 	"time"
 )
@@ -25,6 +32,7 @@ var (
 )
 
 // LoadOpenIncidents loads all active (not yet closed) incidents from the database and restores all their states.
+// Incidents of objects belonging to shards not served by this instance are skipped, see shard.Owns.
 // Returns error on any database failure.
 func LoadOpenIncidents(ctx context.Context, db *database.DB, logger *logging.Logger, runtimeConfig *config.RuntimeConfig) error {
 	logger.Info("Loading all active incidents from database")
@@ -49,6 +57,9 @@ func LoadOpenIncidents(ctx context.Context, db *database.DB, logger *logging.Log
 			if err := rows.StructScan(i); err != nil {
 				return err
 			}
+			if !shard.Owns(i.ObjectID) {
+				continue
+			}
 
 			select {
 			case incidents <- i:
@@ -102,14 +113,22 @@ func LoadOpenIncidents(ctx context.Context, db *database.DB, logger *logging.Log
 						defer i.runtimeConfig.RUnlock()
 
 						escalation := i.runtimeConfig.GetRuleEscalation(state.RuleEscalationID)
-						if escalation != nil {
-							i.Rules[escalation.RuleID] = struct{}{}
+						if escalation != nil && escalation.RuleID.Valid {
+							i.Rules[escalation.RuleID.Int64] = struct{}{}
 						}
 					})
 					if err != nil {
 						return errors.Wrap(err, "cannot restore incident rule escalation states")
 					}
 
+					// Restore all matched incident rules, as escalations of escalation policies don't refer to a rule.
+					err = utils.ForEachRow[RuleRow](ctx, db, "incident_id", incidentIds, func(r *RuleRow) {
+						incidentsById[r.IncidentID].Rules[r.RuleID] = struct{}{}
+					})
+					if err != nil {
+						return errors.Wrap(err, "cannot restore incident rules")
+					}
+
 					// Restore incident recipients matching the given incident ids.
 					err = utils.ForEachRow[ContactRow](ctx, db, "incident_id", incidentIds, func(c *ContactRow) {
 						incidentsById[c.IncidentID].Recipients[c.Key] = &RecipientState{Role: c.Role}
@@ -118,6 +137,44 @@ func LoadOpenIncidents(ctx context.Context, db *database.DB, logger *logging.Log
 						return errors.Wrap(err, "cannot restore incident recipients")
 					}
 
+					// Restore incident watches matching the given incident ids.
+					err = utils.ForEachRow[WatchRow](ctx, db, "incident_id", incidentIds, func(w *WatchRow) {
+						incidentsById[w.IncidentID].Watches[w.ContactID] = w
+					})
+					if err != nil {
+						return errors.Wrap(err, "cannot restore incident watches")
+					}
+
+					// Restore the states kept by channel plugins about the incidents.
+					err = utils.ForEachRow[ChannelStateRow](ctx, db, "incident_id", incidentIds, func(c *ChannelStateRow) {
+						incidentsById[c.IncidentID].ChannelStates[c.ChannelID] = json.RawMessage(c.State)
+					})
+					if err != nil {
+						return errors.Wrap(err, "cannot restore incident channel states")
+					}
+
+					// Restore the annotations parsed from acknowledgement comments.
+					err = utils.ForEachRow[AnnotationRow](ctx, db, "incident_id", incidentIds, func(a *AnnotationRow) {
+						incidentsById[a.IncidentID].Annotations[a.Name] = a.Value
+					})
+					if err != nil {
+						return errors.Wrap(err, "cannot restore incident annotations")
+					}
+
+					// Restore the volatile state exported by a StateExporter, if any. Only the incidents without such
+					// a state have to be restored from their history and events.
+					unexported := maps.Clone(incidentsById)
+					err = utils.ForEachRow[StateRow](ctx, db, "incident_id", incidentIds, func(s *StateRow) {
+						incidentsById[s.IncidentID].restoreState(s)
+						delete(unexported, s.IncidentID)
+					})
+					if err != nil {
+						return errors.Wrap(err, "cannot restore exported incident states")
+					}
+					if err := restoreStateFromHistory(ctx, db, unexported); err != nil {
+						return err
+					}
+
 					for _, i := range incidentsById {
 						i.Object = object.GetFromCache(i.ObjectID)
 						i.isMuted = i.Object.IsMuted()
@@ -127,6 +184,7 @@ func LoadOpenIncidents(ctx context.Context, db *database.DB, logger *logging.Log
 						currentIncidentsMu.Lock()
 						currentIncidents[i.Object] = i
 						currentIncidentsMu.Unlock()
+						i.updateCriticalObject()
 
 						i.RetriggerEscalations(&event.Event{
 							Time:    time.Now(),
@@ -144,6 +202,63 @@ func LoadOpenIncidents(ctx context.Context, db *database.DB, logger *logging.Log
 	return g.Wait()
 }
 
+// restoreStateFromHistory restores the volatile state of the given incidents by their IDs from their history and events,
+// see StateRow.
+func restoreStateFromHistory(ctx context.Context, db *database.DB, incidents map[int64]*Incident) error {
+	if len(incidents) == 0 {
+		return nil
+	}
+
+	incidentIds := make([]int64, 0, len(incidents))
+	for id := range incidents {
+		incidentIds = append(incidentIds, id)
+	}
+
+	// Restore the time of the last sent notification for notification_unanswered_for and renotifications.
+	stmt, args, err := sqlx.In(
+		`SELECT "incident_id", MAX("sent_at") AS "sent_at" FROM "incident_history"`+
+			` WHERE "type" IN ('notified', 'renotified') AND "notification_state" = 'sent'`+
+			` AND "incident_id" IN (?)`+
+			` GROUP BY "incident_id"`,
+		incidentIds)
+	if err != nil {
+		return errors.Wrap(err, "cannot build placeholders for last notifications query")
+	}
+	err = utils.ExecAndApply[lastNotificationRow](ctx, db, stmt, args, func(n *lastNotificationRow) {
+		i := incidents[n.IncidentID]
+		i.lastNotifiedAt = n.SentAt.Time()
+
+		// Without knowing which escalation was renotified last, defer all renotifications accordingly.
+		for escalationID := range i.EscalationState {
+			i.renotifiedAt[escalationID] = i.lastNotifiedAt
+		}
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot restore last incident notifications")
+	}
+
+	// Restore the time of the last received event to close inactive incidents. Events synthesized by the daemon itself,
+	// e.g., for time-based escalations, are no sign of life of the source.
+	stmt, args, err = sqlx.In(
+		`SELECT "incident_event"."incident_id", MAX("event"."time") AS "time" FROM "incident_event"`+
+			` INNER JOIN "event" ON "event"."id" = "incident_event"."event_id"`+
+			` WHERE "event"."type" NOT IN ('incident-age', 'muted-summary')`+
+			` AND "incident_event"."incident_id" IN (?)`+
+			` GROUP BY "incident_event"."incident_id"`,
+		incidentIds)
+	if err != nil {
+		return errors.Wrap(err, "cannot build placeholders for last events query")
+	}
+	err = utils.ExecAndApply[lastEventRow](ctx, db, stmt, args, func(e *lastEventRow) {
+		incidents[e.IncidentID].lastEventAt = e.Time.Time()
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot restore last incident events")
+	}
+
+	return nil
+}
+
 func GetCurrent(
 	ctx context.Context, db *database.DB, obj *object.Object, logger *logging.Logger, runtimeConfig *config.RuntimeConfig,
 	create bool,
@@ -197,12 +312,50 @@ func GetCurrentIncidents() map[int64]*Incident {
 	return m
 }
 
+// GetCurrentByID returns the current, i.e., not yet closed, incident with the given ID or nil.
+func GetCurrentByID(id int64) *Incident {
+	currentIncidentsMu.Lock()
+	defer currentIncidentsMu.Unlock()
+
+	for _, incident := range currentIncidents {
+		if incident.Id == id {
+			return incident
+		}
+	}
+	return nil
+}
+
+// Invalidate reloads the recipients and watches of the current incident with the given ID from the database, as they
+// might have been changed by Icinga Web or another instance. Incidents unknown to this instance are ignored, while an
+// ID of 0 invalidates all current incidents, e.g., when changes might have been missed.
+func Invalidate(ctx context.Context, id int64) error {
+	var incidents []*Incident
+	if id == 0 {
+		for _, i := range GetCurrentIncidents() {
+			incidents = append(incidents, i)
+		}
+	} else if i := GetCurrentByID(id); i != nil {
+		incidents = append(incidents, i)
+	}
+
+	for _, i := range incidents {
+		if err := i.reload(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ProcessEvent from an event.Event.
 //
 // This function first gets this Event's object.Object and its incident.Incident. Then, after performing some safety
-// checks, it calls the Incident.ProcessEvent method.
+// checks, it calls the Incident.ProcessEvent method. Unless the event already has a trace ID, a new one is generated.
 //
-// The returned error might be wrapped around event.ErrSuperfluousStateChange.
+// The returned error might be wrapped around event.ErrSuperfluousStateChange or shard.ErrNotOwned, the latter for
+// events of objects belonging to shards not served by this instance. Once the daemon is shutting down, events are
+// refused with errs.ErrShuttingDown, while those already being processed are completed even if the context is
+// cancelled, see Drain.
 func ProcessEvent(
 	ctx context.Context,
 	db *database.DB,
@@ -210,6 +363,29 @@ func ProcessEvent(
 	runtimeConfig *config.RuntimeConfig,
 	ev *event.Event,
 ) error {
+	ev.EnsureTraceID()
+
+	if objectID := object.ID(ev.SourceId, ev.Tags); !shard.Owns(objectID) {
+		return fmt.Errorf("%w: shard %d", shard.ErrNotOwned, shard.Of(objectID, shard.Count()))
+	}
+
+	if !inflight.begin() {
+		return errs.ErrShuttingDown
+	}
+	defer inflight.end()
+
+	ctx, cancel := inflight.detach(ctx)
+	defer cancel()
+
+	// Enrich the event before its object is synced, as the object's extra tags are replaced by those of the event.
+	enrichment.Default.Enrich(ctx, ev)
+
+	// Events of sources other than the listener, e.g., Icinga 2, are considered received when being processed.
+	if latency.FromContext(ctx) == nil {
+		ctx = latency.NewContext(ctx, latency.NewTrace(time.Now()))
+	}
+	stopDBSync := latency.FromContext(ctx).Track(latency.PhaseDBSync)
+
 	var wasObjectMuted bool
 	if obj := object.GetFromCache(object.ID(ev.SourceId, ev.Tags)); obj != nil {
 		wasObjectMuted = obj.IsMuted()
@@ -217,9 +393,11 @@ func ProcessEvent(
 
 	obj, err := object.FromEvent(ctx, db, ev)
 	if err != nil {
-		return fmt.Errorf("cannot sync event object: %w", err)
+		return fmt.Errorf("cannot sync event object: %w", errs.WrapDB(err))
 	}
 
+	tagcatalog.Default.Observe(ev.SourceId, ev.Tags, ev.ExtraTags, time.Now())
+
 	createIncident := ev.Severity != event.SeverityNone && ev.Severity != event.SeverityOK
 	currentIncident, err := GetCurrent(
 		ctx,
@@ -229,8 +407,9 @@ func ProcessEvent(
 		runtimeConfig,
 		createIncident)
 	if err != nil {
-		return fmt.Errorf("cannot get current incident for %q: %w", obj.DisplayName(), err)
+		return fmt.Errorf("cannot get current incident for %q: %w", obj.DisplayName(), errs.WrapDB(err))
 	}
+	stopDBSync()
 
 	if currentIncident == nil {
 		switch {
@@ -245,9 +424,12 @@ func ProcessEvent(
 			}
 
 			// There is no active incident, but the event appears to be relevant, so try to persist it in the DB.
-			err = utils.RunInTx(ctx, db, func(tx *sqlx.Tx) error { return ev.Sync(ctx, tx, db, obj.ID) })
+			err = utils.RunInTx(ctx, db, func(tx *sqlx.Tx) error {
+				ev.ID = 0 // The event of a previous attempt was rolled back.
+				return ev.Sync(ctx, tx, db, obj.ID)
+			})
 			if err != nil {
-				return errors.New("cannot sync non-state event to the database")
+				return fmt.Errorf("cannot sync non-state event to the database: %w", errs.WrapDB(err))
 			}
 
 			return nil
@@ -258,5 +440,5 @@ func ProcessEvent(
 		}
 	}
 
-	return currentIncident.ProcessEvent(ctx, ev)
+	return errs.WrapDB(currentIncident.ProcessEvent(ctx, ev))
 }
@@ -0,0 +1,73 @@
+package incident
+
+import (
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"go.uber.org/zap"
+	"time"
+)
+
+// recordMutedEvent counts the given event if it was processed while the object was and still is muted, i.e., its
+// notifications were suppressed, see generateNotifications. The first counted event starts a timer sending a summary
+// of all counted events to the recipients after the interval, see sendMutedSummary.
+//
+// Once the object is unmuted, the counter is reset. A non-positive interval disables the summaries.
+func (i *Incident) recordMutedEvent(ev *event.Event, interval time.Duration) {
+	if interval <= 0 || ev.Type == event.TypeMutedSummary {
+		return
+	}
+
+	if !i.isMuted || !i.Object.IsMuted() {
+		if !i.Object.IsMuted() {
+			i.mutedEvents = 0
+			if i.mutedSummaryTimer != nil {
+				i.mutedSummaryTimer.Stop()
+				i.mutedSummaryTimer = nil
+			}
+		}
+		return
+	}
+
+	i.mutedEvents++
+	if i.mutedSummaryTimer == nil {
+		i.logger.Debugw("Scheduling summary of events while muted", zap.Duration("after", interval))
+		i.mutedSummaryTimer = time.AfterFunc(interval, func() { i.sendMutedSummary(interval) })
+	}
+}
+
+// sendMutedSummary notifies the recipients about the number of events received while the object was muted during the
+// last interval, allowing them to notice a deteriorating object despite a long silence.
+func (i *Incident) sendMutedSummary(interval time.Duration) {
+	if !inflight.begin() {
+		i.logger.Debug("Not sending summary of events while muted while shutting down")
+		return
+	}
+	defer inflight.end()
+
+	i.Lock()
+	count := i.mutedEvents
+	i.mutedEvents = 0
+	i.mutedSummaryTimer = nil
+	muted := i.isMuted && i.Object.IsMuted()
+	closed := !i.RecoveredAt.Time().IsZero()
+	reason := i.Object.MuteReason.String
+	i.Unlock()
+
+	if count == 0 || !muted || closed {
+		return
+	}
+
+	ev := &event.Event{
+		Time:     time.Now(),
+		SourceId: i.Object.SourceID,
+		Type:     event.TypeMutedSummary,
+		Message: fmt.Sprintf("%s produced %d events within the last %v while muted: %s",
+			i.Object.DisplayName(), count, interval, reason),
+	}
+
+	i.logger.Infow("Sending summary of events while muted", zap.Int("events", count), zap.Duration("interval", interval))
+	if err := i.ProcessEvent(context.Background(), ev); err != nil {
+		i.logger.Errorw("Cannot process muted summary event", zap.Error(err))
+	}
+}
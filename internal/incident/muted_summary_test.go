@@ -0,0 +1,78 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"testing"
+	"time"
+)
+
+func TestIncident_RecordMutedEvent(t *testing.T) {
+	t.Parallel()
+
+	newMutedIncident := func(t *testing.T) *Incident {
+		obj := &object.Object{ID: []byte{1}, MuteReason: types.MakeString("maintenance")}
+		i := NewIncident(nil, obj, nil, zaptest.NewLogger(t).Sugar())
+		i.isMuted = true
+		t.Cleanup(func() {
+			if i.mutedSummaryTimer != nil {
+				i.mutedSummaryTimer.Stop()
+			}
+		})
+
+		return i
+	}
+
+	t.Run("Counts", func(t *testing.T) {
+		t.Parallel()
+
+		i := newMutedIncident(t)
+		for n := 0; n < 3; n++ {
+			i.recordMutedEvent(&event.Event{Type: event.TypeState}, time.Hour)
+		}
+		i.recordMutedEvent(&event.Event{Type: event.TypeMutedSummary}, time.Hour)
+
+		assert.Equal(t, 3, i.mutedEvents)
+		require.NotNil(t, i.mutedSummaryTimer)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+
+		i := newMutedIncident(t)
+		i.recordMutedEvent(&event.Event{Type: event.TypeState}, 0)
+
+		assert.Equal(t, 0, i.mutedEvents)
+		assert.Nil(t, i.mutedSummaryTimer)
+	})
+
+	t.Run("ResetOnUnmute", func(t *testing.T) {
+		t.Parallel()
+
+		i := newMutedIncident(t)
+		i.recordMutedEvent(&event.Event{Type: event.TypeState}, time.Hour)
+		require.Equal(t, 1, i.mutedEvents)
+
+		i.Object.MuteReason = types.String{}
+		i.recordMutedEvent(&event.Event{Type: event.TypeUnmute}, time.Hour)
+
+		assert.Equal(t, 0, i.mutedEvents)
+		assert.Nil(t, i.mutedSummaryTimer)
+	})
+
+	t.Run("NotYetMuted", func(t *testing.T) {
+		t.Parallel()
+
+		// The event muting the object was not suppressed, as the incident was not muted before.
+		i := newMutedIncident(t)
+		i.isMuted = false
+		i.recordMutedEvent(&event.Event{Type: event.TypeMute}, time.Hour)
+
+		assert.Equal(t, 0, i.mutedEvents)
+		assert.Nil(t, i.mutedSummaryTimer)
+	})
+}
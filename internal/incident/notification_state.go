@@ -13,6 +13,7 @@ const (
 	NotificationStatePending
 	NotificationStateSent
 	NotificationStateFailed
+	NotificationStateThrottled
 )
 
 var notificationStatTypeByName = map[string]NotificationState{
@@ -20,6 +21,7 @@ var notificationStatTypeByName = map[string]NotificationState{
 	"pending":    NotificationStatePending,
 	"sent":       NotificationStateSent,
 	"failed":     NotificationStateFailed,
+	"throttled":  NotificationStateThrottled,
 }
 
 var notificationStateTypeToName = func() map[NotificationState]string {
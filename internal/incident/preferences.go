@@ -0,0 +1,85 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"go.uber.org/zap"
+	"time"
+)
+
+// applyContactPreferences restricts the contact channels to the notification preferences of the contacts.
+//
+// Contacts out of office are replaced by their substitutes, notified via their default channel. Afterwards, contacts
+// within their quiet hours are dropped unless the incident is at least critical, as well as channels whose minimum
+// severity is not reached. Like the thresholds of watches, severities are checked against both the current and the
+// previous severity, thus contacts are also informed when the incident leaves their severity range, e.g., recovers.
+func (i *Incident) applyContactPreferences(contactChs rule.ContactChannels, t time.Time, oldSeverity event.Severity) {
+	var absent []*recipient.Contact
+	for contact := range contactChs {
+		if contact.OutOfOffice.Bool {
+			absent = append(absent, contact)
+		}
+	}
+
+	for _, contact := range absent {
+		delete(contactChs, contact)
+
+		substitute := i.getSubstitute(contact)
+		if substitute == nil {
+			i.logger.Infow("Not notifying contact out of office without an available substitute",
+				zap.String("contact", contact.FullName))
+			continue
+		}
+
+		i.logger.Infow("Notifying substitute of contact out of office",
+			zap.String("contact", contact.FullName), zap.String("substitute", substitute.FullName))
+		if contactChs[substitute] == nil {
+			contactChs[substitute] = map[int64]bool{substitute.DefaultChannelID: true}
+		}
+	}
+
+	severity := max(i.Severity, oldSeverity)
+	for contact, channels := range contactChs {
+		if severity < event.SeverityCrit && contact.QuietHoursTimePeriodID.Valid {
+			tp := i.runtimeConfig.TimePeriods[contact.QuietHoursTimePeriodID.Int64]
+			if tp != nil && tp.Contains(t) {
+				i.logger.Debugw("Not notifying contact within their quiet hours", zap.String("contact", contact.FullName),
+					zap.Object("timeperiod", tp))
+				delete(contactChs, contact)
+				continue
+			}
+		}
+
+		for chID := range channels {
+			if minSeverity := contact.MinSeverity(chID); severity < minSeverity {
+				i.logger.Debugw("Not notifying contact via channel below their minimum severity",
+					zap.String("contact", contact.FullName), zap.Int64("channel_id", chID),
+					zap.Stringer("min_severity", &minSeverity))
+				delete(channels, chID)
+			}
+		}
+		if len(channels) == 0 {
+			delete(contactChs, contact)
+		}
+	}
+}
+
+// getSubstitute returns the substitute of a contact out of office, following the substitutes of substitutes being out
+// of office as well, or nil if there is none.
+func (i *Incident) getSubstitute(contact *recipient.Contact) *recipient.Contact {
+	visited := map[int64]bool{contact.ID: true}
+	for contact.OutOfOffice.Bool {
+		if !contact.SubstituteContactID.Valid || visited[contact.SubstituteContactID.Int64] {
+			return nil
+		}
+		visited[contact.SubstituteContactID.Int64] = true
+
+		contact = i.runtimeConfig.Contacts[contact.SubstituteContactID.Int64]
+		if contact == nil {
+			return nil
+		}
+	}
+
+	return contact
+}
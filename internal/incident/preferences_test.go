@@ -0,0 +1,133 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"testing"
+	"time"
+)
+
+func TestIncident_applyContactPreferences(t *testing.T) {
+	t.Parallel()
+
+	newContact := func(id int64, name string) *recipient.Contact {
+		c := &recipient.Contact{FullName: name, DefaultChannelID: 3}
+		c.ID = id
+		return c
+	}
+	outOfOffice := func(c *recipient.Contact, substitute int64) *recipient.Contact {
+		c.OutOfOffice = types.Bool{Bool: true, Valid: true}
+		if substitute != 0 {
+			c.SubstituteContactID = utils.ToDBInt(substitute)
+		}
+		return c
+	}
+
+	start := time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC)
+	entry := &timeperiod.Entry{
+		StartTime: types.UnixMilli(start),
+		EndTime:   types.UnixMilli(start.Add(8 * time.Hour)),
+		Timezone:  "UTC",
+	}
+	require.NoError(t, entry.Init())
+	night := &timeperiod.TimePeriod{Entries: []*timeperiod.Entry{entry}}
+	night.ID = 1
+
+	apply := func(
+		t *testing.T, contacts []*recipient.Contact, contactChs rule.ContactChannels, at time.Time,
+		oldSeverity, severity event.Severity,
+	) rule.ContactChannels {
+		runtimeConfig := &config.RuntimeConfig{}
+		runtimeConfig.TimePeriods = map[int64]*timeperiod.TimePeriod{night.ID: night}
+		runtimeConfig.Contacts = make(map[int64]*recipient.Contact)
+		for _, c := range contacts {
+			runtimeConfig.Contacts[c.ID] = c
+		}
+
+		i := NewIncident(nil, nil, runtimeConfig, zaptest.NewLogger(t).Sugar())
+		i.Severity = severity
+		i.applyContactPreferences(contactChs, at, oldSeverity)
+		return contactChs
+	}
+
+	t.Run("NoPreferences", func(t *testing.T) {
+		t.Parallel()
+
+		jane := newContact(1, "Jane Doe")
+		contactChs := apply(t, []*recipient.Contact{jane}, rule.ContactChannels{jane: {3: true, 5: true}}, start,
+			event.SeverityNone, event.SeverityWarning)
+		assert.Equal(t, rule.ContactChannels{jane: {3: true, 5: true}}, contactChs)
+	})
+
+	t.Run("MinSeverity", func(t *testing.T) {
+		t.Parallel()
+
+		jane := newContact(1, "Jane Doe")
+		jane.ChannelPreferences = []*recipient.ChannelPreference{
+			{ContactID: 1, ChannelID: 5, MinSeverity: event.SeverityCrit},
+		}
+		contacts := []*recipient.Contact{jane}
+
+		assert.Equal(t, rule.ContactChannels{jane: {3: true}},
+			apply(t, contacts, rule.ContactChannels{jane: {3: true, 5: true}}, start, event.SeverityOK,
+				event.SeverityWarning))
+		assert.Equal(t, rule.ContactChannels{jane: {3: true, 5: true}},
+			apply(t, contacts, rule.ContactChannels{jane: {3: true, 5: true}}, start, event.SeverityWarning,
+				event.SeverityCrit))
+		assert.Equal(t, rule.ContactChannels{jane: {3: true, 5: true}},
+			apply(t, contacts, rule.ContactChannels{jane: {3: true, 5: true}}, start, event.SeverityCrit,
+				event.SeverityOK), "recovery must be delivered")
+		assert.Empty(t, apply(t, contacts, rule.ContactChannels{jane: {5: true}}, start, event.SeverityOK,
+			event.SeverityErr), "contacts without channels must be dropped")
+	})
+
+	t.Run("QuietHours", func(t *testing.T) {
+		t.Parallel()
+
+		jane := newContact(1, "Jane Doe")
+		jane.QuietHoursTimePeriodID = utils.ToDBInt(night.ID)
+		contacts := []*recipient.Contact{jane}
+
+		assert.Empty(t, apply(t, contacts, rule.ContactChannels{jane: {3: true}}, start.Add(time.Hour),
+			event.SeverityOK, event.SeverityErr))
+		assert.Equal(t, rule.ContactChannels{jane: {3: true}},
+			apply(t, contacts, rule.ContactChannels{jane: {3: true}}, start.Add(time.Hour), event.SeverityOK,
+				event.SeverityCrit), "critical notifications must be delivered")
+		assert.Equal(t, rule.ContactChannels{jane: {3: true}},
+			apply(t, contacts, rule.ContactChannels{jane: {3: true}}, start.Add(-time.Hour), event.SeverityOK,
+				event.SeverityErr), "outside of the quiet hours")
+	})
+
+	t.Run("OutOfOffice", func(t *testing.T) {
+		t.Parallel()
+
+		jane := outOfOffice(newContact(1, "Jane Doe"), 2)
+		john := outOfOffice(newContact(2, "John Doe"), 3)
+		erika := newContact(3, "Erika Mustermann")
+		erika.DefaultChannelID = 7
+
+		contactChs := apply(t, []*recipient.Contact{jane, john, erika}, rule.ContactChannels{jane: {5: true}}, start,
+			event.SeverityOK, event.SeverityCrit)
+		assert.Equal(t, rule.ContactChannels{erika: {7: true}}, contactChs, "substitutes must be followed")
+	})
+
+	t.Run("OutOfOfficeWithoutSubstitute", func(t *testing.T) {
+		t.Parallel()
+
+		jane := outOfOffice(newContact(1, "Jane Doe"), 0)
+		john := outOfOffice(newContact(2, "John Doe"), 3)
+		cycle := outOfOffice(newContact(3, "Erika Mustermann"), 2)
+
+		contactChs := apply(t, []*recipient.Contact{jane, john, cycle},
+			rule.ContactChannels{jane: {3: true}, john: {3: true}}, start, event.SeverityOK, event.SeverityCrit)
+		assert.Empty(t, contactChs)
+	})
+}
@@ -0,0 +1,306 @@
+package incident
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/jmoiron/sqlx"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultListLimit is the number of incidents returned by List if ListFilter.Limit is not set.
+const DefaultListLimit = 100
+
+// MaxListLimit is the maximum number of incidents returned by a single List call.
+const MaxListLimit = 1000
+
+// ListFilter restricts the incidents returned by List and counted by CountBySeverity.
+//
+// Zero values do not restrict the result, except for Limit, which defaults to DefaultListLimit.
+type ListFilter struct {
+	// Open restricts the result to either open or closed incidents, if set.
+	Open *bool
+
+	// Severities restricts the result to incidents having any of these severities.
+	Severities []event.Severity
+
+	SourceID int64
+	ObjectID types.Binary
+
+	// SourceIDs restricts the result to incidents of these sources unless nil, e.g., to those visible to the tenant of
+	// an API token, see config.RuntimeConfig.GetTenantSourceIDs.
+	SourceIDs []int64
+
+	Limit  int
+	Offset int
+}
+
+// ParseListFilter parses a ListFilter from URL query parameters, e.g., as used by the HTTP API.
+//
+// Supported parameters are "state" ("open" or "closed"), "severity" (comma-separated list), "source_id", "object_id"
+// (hex-encoded), "limit" and "offset".
+func ParseListFilter(query url.Values) (*ListFilter, error) {
+	f := &ListFilter{}
+
+	switch state := query.Get("state"); state {
+	case "":
+	case "open", "closed":
+		open := state == "open"
+		f.Open = &open
+	default:
+		return nil, fmt.Errorf("state must be either open or closed, got %q", state)
+	}
+
+	if severities := query.Get("severity"); severities != "" {
+		for _, name := range strings.Split(severities, ",") {
+			severity, err := event.GetSeverityByName(strings.TrimSpace(name))
+			if err != nil {
+				return nil, err
+			}
+			f.Severities = append(f.Severities, severity)
+		}
+	}
+
+	if objectID := query.Get("object_id"); objectID != "" {
+		if err := f.ObjectID.UnmarshalText([]byte(objectID)); err != nil {
+			return nil, fmt.Errorf("object_id must be hex-encoded: %w", err)
+		}
+	}
+
+	for param, dest := range map[string]any{"source_id": &f.SourceID, "limit": &f.Limit, "offset": &f.Offset} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative integer, got %q", param, value)
+		}
+
+		switch dest := dest.(type) {
+		case *int64:
+			*dest = n
+		case *int:
+			*dest = int(n)
+		}
+	}
+
+	if f.Limit > MaxListLimit {
+		return nil, fmt.Errorf("limit must not exceed %d", MaxListLimit)
+	}
+
+	return f, nil
+}
+
+// where builds the WHERE clause of this filter with "?" placeholders, referencing the incident table as "i" and the
+// object table as "o", together with its arguments.
+func (f *ListFilter) where() (string, []any, error) {
+	conditions := []string{"1 = 1"}
+	var args []any
+
+	if f.Open != nil {
+		if *f.Open {
+			conditions = append(conditions, `i."recovered_at" IS NULL`)
+		} else {
+			conditions = append(conditions, `i."recovered_at" IS NOT NULL`)
+		}
+	}
+
+	if len(f.Severities) > 0 {
+		in, inArgs, err := sqlx.In(`i."severity" IN (?)`, f.Severities)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, in)
+		args = append(args, inArgs...)
+	}
+
+	if f.SourceID != 0 {
+		conditions = append(conditions, `o."source_id" = ?`)
+		args = append(args, f.SourceID)
+	}
+
+	if f.ObjectID.Valid() {
+		conditions = append(conditions, `i."object_id" = ?`)
+		args = append(args, f.ObjectID)
+	}
+
+	if f.SourceIDs != nil {
+		in, inArgs, err := sourcesCondition(f.SourceIDs)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, in)
+		args = append(args, inArgs...)
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// sourcesCondition returns a condition restricting the object table "o" to the given sources, matching nothing if there
+// are none, together with its arguments.
+func sourcesCondition(sourceIDs []int64) (string, []any, error) {
+	if len(sourceIDs) == 0 {
+		return "1 = 0", nil, nil
+	}
+
+	return sqlx.In(`o."source_id" IN (?)`, sourceIDs)
+}
+
+// Summary is a read-only representation of an incident, including its object's name and source, as returned by List.
+type Summary struct {
+	ID          int64           `db:"id" json:"id"`
+	ObjectID    types.Binary    `db:"object_id" json:"object_id"`
+	ObjectName  string          `db:"object_name" json:"object_name"`
+	SourceID    int64           `db:"source_id" json:"source_id"`
+	StartedAt   types.UnixMilli `db:"started_at" json:"started_at"`
+	RecoveredAt types.UnixMilli `db:"recovered_at" json:"recovered_at"`
+	Severity    event.Severity  `db:"severity" json:"severity"`
+}
+
+// List returns the incidents matching the given filter from the database, latest first.
+//
+// In contrast to GetCurrentIncidents, closed incidents are included as well, allowing to replace direct database access
+// of other components, e.g., Icinga Notifications Web.
+func List(ctx context.Context, db *database.DB, f *ListFilter) ([]*Summary, error) {
+	where, args, err := f.where()
+	if err != nil {
+		return nil, err
+	}
+
+	limit := f.Limit
+	if limit == 0 {
+		limit = DefaultListLimit
+	}
+
+	stmt := db.Rebind(fmt.Sprintf(`SELECT i."id", i."object_id", o."name" AS "object_name", o."source_id",
+			i."started_at", i."recovered_at", i."severity"
+		FROM "incident" i
+		INNER JOIN "object" o ON o."id" = i."object_id"
+		WHERE %s
+		ORDER BY i."started_at" DESC, i."id" DESC
+		LIMIT %d OFFSET %d`, where, limit, f.Offset))
+
+	incidents := []*Summary{}
+	if err := db.SelectContext(ctx, &incidents, stmt, args...); err != nil {
+		return nil, fmt.Errorf("cannot list incidents: %w", err)
+	}
+
+	return incidents, nil
+}
+
+// ErrIncidentNotFound is returned by GetSummary for an unknown incident ID.
+var ErrIncidentNotFound = errors.New("incident not found")
+
+// GetSummary returns the incident of the given ID from the database, regardless of whether it is open or closed.
+func GetSummary(ctx context.Context, db *database.DB, id int64) (*Summary, error) {
+	var incidents []*Summary
+	stmt := db.Rebind(`SELECT i."id", i."object_id", o."name" AS "object_name", o."source_id",
+			i."started_at", i."recovered_at", i."severity"
+		FROM "incident" i
+		INNER JOIN "object" o ON o."id" = i."object_id"
+		WHERE i."id" = ?`)
+	if err := db.SelectContext(ctx, &incidents, stmt, id); err != nil {
+		return nil, fmt.Errorf("cannot fetch incident: %w", err)
+	}
+	if len(incidents) == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrIncidentNotFound, id)
+	}
+
+	return incidents[0], nil
+}
+
+// CountBySeverity returns the number of incidents matching the given filter per severity name from the database.
+//
+// The filter's Severities, Limit and Offset are ignored. Severities without any incident are included with zero.
+func CountBySeverity(ctx context.Context, db *database.DB, f *ListFilter) (map[string]int64, error) {
+	unrestricted := *f
+	unrestricted.Severities = nil
+	where, args, err := unrestricted.where()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := db.Rebind(fmt.Sprintf(`SELECT i."severity", COUNT(*) AS "count"
+		FROM "incident" i
+		INNER JOIN "object" o ON o."id" = i."object_id"
+		WHERE %s
+		GROUP BY i."severity"`, where))
+
+	var rows []struct {
+		Severity event.Severity `db:"severity"`
+		Count    int64          `db:"count"`
+	}
+	if err := db.SelectContext(ctx, &rows, stmt, args...); err != nil {
+		return nil, fmt.Errorf("cannot count incidents: %w", err)
+	}
+
+	counts := make(map[string]int64)
+	for severity := event.SeverityOK; severity <= event.SeverityEmerg; severity++ {
+		counts[severity.String()] = 0
+	}
+	for _, row := range rows {
+		counts[row.Severity.String()] = row.Count
+	}
+
+	return counts, nil
+}
+
+// ObjectIncidents is the result of GetObjectIncidents, an object together with its incidents.
+type ObjectIncidents struct {
+	ID        types.Binary      `db:"id" json:"id"`
+	SourceID  int64             `db:"source_id" json:"source_id"`
+	Name      string            `db:"name" json:"name"`
+	URL       types.String      `db:"url" json:"url"`
+	Tags      map[string]string `db:"-" json:"tags"`
+	Incidents []*Summary        `db:"-" json:"incidents"`
+}
+
+// ErrObjectNotFound is returned by GetObjectIncidents for an unknown object ID.
+var ErrObjectNotFound = errors.New("object not found")
+
+// GetObjectIncidents returns the object of the given ID, including its identifying tags, together with its latest
+// incidents from the database, limited by the given filter.
+func GetObjectIncidents(ctx context.Context, db *database.DB, f *ListFilter) (*ObjectIncidents, error) {
+	if !f.ObjectID.Valid() {
+		return nil, errors.New("object_id must be set")
+	}
+
+	var objects []*ObjectIncidents
+	stmt := db.Rebind(`SELECT "id", "source_id", "name", "url" FROM "object" WHERE "id" = ?`)
+	if err := db.SelectContext(ctx, &objects, stmt, f.ObjectID); err != nil {
+		return nil, fmt.Errorf("cannot fetch object: %w", err)
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, f.ObjectID)
+	}
+	obj := objects[0]
+
+	var tags []struct {
+		Tag   string `db:"tag"`
+		Value string `db:"value"`
+	}
+	stmt = db.Rebind(`SELECT "tag", "value" FROM "object_id_tag" WHERE "object_id" = ?`)
+	if err := db.SelectContext(ctx, &tags, stmt, f.ObjectID); err != nil {
+		return nil, fmt.Errorf("cannot fetch object tags: %w", err)
+	}
+
+	obj.Tags = make(map[string]string, len(tags))
+	for _, tag := range tags {
+		obj.Tags[tag.Tag] = tag.Value
+	}
+
+	incidents, err := List(ctx, db, f)
+	if err != nil {
+		return nil, err
+	}
+	obj.Incidents = incidents
+
+	return obj, nil
+}
@@ -0,0 +1,129 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseListFilter(t *testing.T) {
+	t.Parallel()
+
+	open := true
+	objectID := types.Binary{0xca, 0xfe}
+
+	tests := []struct {
+		name  string
+		query string
+		want  *ListFilter
+		where string
+		args  []any
+	}{
+		{"Empty", "", &ListFilter{}, "1 = 1", nil},
+		{"Open", "state=open", &ListFilter{Open: &open}, `1 = 1 AND i."recovered_at" IS NULL`, nil},
+		{
+			"Severities",
+			"severity=crit,%20warning",
+			&ListFilter{Severities: []event.Severity{event.SeverityCrit, event.SeverityWarning}},
+			`1 = 1 AND i."severity" IN (?, ?)`,
+			[]any{event.SeverityCrit, event.SeverityWarning},
+		},
+		{
+			"ObjectAndSource",
+			"object_id=cafe&source_id=2&limit=10&offset=20",
+			&ListFilter{SourceID: 2, ObjectID: objectID, Limit: 10, Offset: 20},
+			`1 = 1 AND o."source_id" = ? AND i."object_id" = ?`,
+			[]any{int64(2), objectID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			f, err := ParseListFilter(query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, f)
+
+			where, args, err := f.where()
+			require.NoError(t, err)
+			assert.Equal(t, tt.where, where)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+
+	for _, query := range []string{"state=gone", "severity=fatal", "object_id=xyz", "limit=-1", "limit=1001"} {
+		t.Run(query, func(t *testing.T) {
+			t.Parallel()
+
+			values, err := url.ParseQuery(query)
+			require.NoError(t, err)
+
+			_, err = ParseListFilter(values)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestListFilter_SourceIDs(t *testing.T) {
+	t.Parallel()
+
+	f := &ListFilter{SourceID: 2, SourceIDs: []int64{1, 2}}
+	where, args, err := f.where()
+	require.NoError(t, err)
+	assert.Equal(t, `1 = 1 AND o."source_id" = ? AND o."source_id" IN (?, ?)`, where)
+	assert.Equal(t, []any{int64(2), int64(1), int64(2)}, args)
+
+	f = &ListFilter{SourceIDs: []int64{}}
+	where, args, err = f.where()
+	require.NoError(t, err)
+	assert.Equal(t, "1 = 1 AND 1 = 0", where, "a tenant without sources sees no incidents")
+	assert.Empty(t, args)
+}
+
+func TestParseHistoryFilter(t *testing.T) {
+	t.Parallel()
+
+	since := time.UnixMilli(1700000000000)
+	until := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	query, err := url.ParseQuery("type=notified,%20rule_matched&since=1700000000000&until=2024-01-01T00:00:00Z&limit=5")
+	require.NoError(t, err)
+
+	f, err := ParseHistoryFilter(query)
+	require.NoError(t, err)
+	assert.Equal(t, &HistoryFilter{
+		Types: []HistoryEventType{Notified, RuleMatched},
+		Since: since,
+		Until: until,
+		Limit: 5,
+	}, f)
+
+	_, _, err = f.where()
+	assert.Error(t, err, "either an incident or an object must be referenced")
+
+	f.IncidentID = 42
+	where, args, err := f.where()
+	require.NoError(t, err)
+	assert.Equal(t, `h."incident_id" = ? AND h."type" IN (?, ?) AND h."time" >= ? AND h."time" <= ?`, where)
+	assert.Equal(t, []any{int64(42), Notified, RuleMatched, types.UnixMilli(since), types.UnixMilli(until)}, args)
+
+	for _, query := range []string{"type=unknown", "since=yesterday", "since=2&until=1", "offset=-1", "limit=1001"} {
+		t.Run(query, func(t *testing.T) {
+			t.Parallel()
+
+			values, err := url.ParseQuery(query)
+			require.NoError(t, err)
+
+			_, err = ParseHistoryFilter(values)
+			assert.Error(t, err)
+		})
+	}
+}
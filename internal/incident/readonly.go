@@ -0,0 +1,42 @@
+package incident
+
+import (
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"sync/atomic"
+	"time"
+)
+
+// readOnly is set while the daemon is in read-only mode, see SetReadOnly.
+var readOnly atomic.Bool
+
+// SetReadOnly enables or disables the read-only mode and reports whether the mode was changed.
+//
+// While in read-only mode, events are still processed and recorded, but no escalations are triggered and all
+// notifications are suppressed, i.e., only recorded in the incident history. This allows, e.g., maintaining the database
+// or rehearsing a migration without notifying anyone. Once disabled again, the escalations of all open incidents are
+// re-evaluated in the background, like after a restart, triggering those missed in the meantime.
+func SetReadOnly(enabled bool) bool {
+	if readOnly.Swap(enabled) == enabled {
+		return false
+	}
+
+	if !enabled {
+		go func() {
+			for _, i := range GetCurrentIncidents() {
+				i.RetriggerEscalations(&event.Event{
+					Time:    time.Now(),
+					Type:    event.TypeIncidentAge,
+					Message: fmt.Sprintf("Incident reached age %v (read-only mode was disabled)", time.Since(i.StartedAt.Time())),
+				})
+			}
+		}()
+	}
+
+	return true
+}
+
+// ReadOnly reports whether the daemon is in read-only mode, see SetReadOnly.
+func ReadOnly() bool {
+	return readOnly.Load()
+}
@@ -0,0 +1,20 @@
+package incident
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSetReadOnly(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, ReadOnly(), "read-only mode should be disabled by default")
+
+	assert.True(t, SetReadOnly(true), "enabling should change the mode")
+	assert.True(t, ReadOnly())
+	assert.False(t, SetReadOnly(true), "enabling again should not change the mode")
+
+	assert.True(t, SetReadOnly(false), "disabling should change the mode")
+	assert.False(t, ReadOnly())
+	assert.False(t, SetReadOnly(false), "disabling again should not change the mode")
+}
@@ -0,0 +1,135 @@
+package incident
+
+import (
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"time"
+)
+
+// nextRenotification returns the earliest time at which any triggered escalation with a renotify interval is due to
+// repeat its notifications, together with all escalations being due at the given time.
+//
+// A zero time is returned if there are no escalations to renotify, e.g., as the incident is already handled.
+func (i *Incident) nextRenotification(now time.Time) (time.Time, []*rule.Escalation) {
+	if !i.RecoveredAt.Time().IsZero() || i.IsHandled() {
+		return time.Time{}, nil
+	}
+
+	var next time.Time
+	var due []*rule.Escalation
+	for escalationID, state := range i.EscalationState {
+		escalation := i.runtimeConfig.GetRuleEscalation(escalationID)
+		if escalation == nil || escalation.RenotifyInterval <= 0 {
+			continue
+		}
+
+		notifiedAt := state.TriggeredAt.Time()
+		if renotifiedAt, ok := i.renotifiedAt[escalationID]; ok && renotifiedAt.After(notifiedAt) {
+			notifiedAt = renotifiedAt
+		}
+
+		at := notifiedAt.Add(escalation.RenotifyInterval)
+		if at.Before(i.snoozedUntil) {
+			at = i.snoozedUntil
+		}
+		if !at.After(now) {
+			due = append(due, escalation)
+		}
+		if next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+
+	return next, due
+}
+
+// scheduleRenotification (re)starts the renotify timer for the next due escalation, or stops it if there is none.
+func (i *Incident) scheduleRenotification() {
+	if i.renotifyTimer != nil {
+		i.renotifyTimer.Stop()
+		i.renotifyTimer = nil
+	}
+
+	next, _ := i.nextRenotification(time.Now())
+	if next.IsZero() {
+		return
+	}
+
+	i.logger.Debugw("Scheduling renotification", zap.Time("at", next))
+	i.renotifyTimer = time.AfterFunc(max(time.Until(next), 0), i.Renotify)
+}
+
+// Renotify repeats the notifications of all escalations whose renotify interval has elapsed.
+//
+// Renotifications are recorded as Renotified incident history entries and stop as soon as the incident has a manager,
+// e.g., after being acknowledged, or recovers.
+func (i *Incident) Renotify() {
+	if !inflight.begin() {
+		i.logger.Debug("Not renotifying while shutting down")
+		return
+	}
+	defer inflight.end()
+
+	i.Lock()
+	defer i.Unlock()
+
+	i.runtimeConfig.RLock()
+	defer i.runtimeConfig.RUnlock()
+
+	defer i.scheduleRenotification()
+
+	now := time.Now()
+	_, due := i.nextRenotification(now)
+	if len(due) == 0 {
+		return
+	}
+
+	ev := &event.Event{
+		Time:    now,
+		Type:    event.TypeIncidentAge,
+		Message: fmt.Sprintf("Incident is still unacknowledged after %v", now.Sub(i.StartedAt.Time()).Round(time.Second)),
+	}
+
+	channels := make(rule.ContactChannels)
+	for _, escalation := range due {
+		channels.LoadFromEscalationRecipients(escalation, now, i.isRecipientNotifiable)
+		i.renotifiedAt[escalation.ID] = now
+	}
+
+	var notifications []*NotificationEntry
+	ctx := context.Background()
+	snapshot := i.snapshot(ev)
+	err := utils.RunInTx(ctx, i.db, func(tx *sqlx.Tx) error {
+		// Undo the changes of a previous attempt, as its transaction was rolled back.
+		snapshot.restore()
+
+		if err := ev.Sync(ctx, tx, i.db, i.Object.ID); err != nil {
+			return err
+		}
+
+		if err := i.AddEvent(ctx, tx, ev); err != nil {
+			return fmt.Errorf("cannot insert incident event to the database: %w", err)
+		}
+
+		var err error
+		notifications, err = i.generateNotifications(ctx, tx, ev, channels, Renotified)
+		return err
+	})
+	i.settleThrottle(err == nil)
+	if err != nil {
+		i.logger.Errorw("Cannot generate renotifications", zap.Error(err))
+		return
+	}
+
+	if err := i.notifyContacts(ctx, ev, notifications); err != nil {
+		i.logger.Errorw("Failed to renotify escalation recipients", zap.Error(err))
+		return
+	}
+
+	i.logger.Infow("Successfully renotified unacknowledged incident", zap.Int("escalations", len(due)))
+}
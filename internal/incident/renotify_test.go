@@ -0,0 +1,113 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"testing"
+	"time"
+)
+
+func TestIncident_nextRenotification(t *testing.T) {
+	t.Parallel()
+
+	renotifying := &rule.Escalation{RenotifyInterval: 30 * time.Minute}
+	renotifying.ID = 1
+	once := &rule.Escalation{}
+	once.ID = 2
+
+	r := &rule.Rule{Escalations: map[int64]*rule.Escalation{renotifying.ID: renotifying, once.ID: once}}
+	r.ID = 1
+
+	runtimeConfig := &config.RuntimeConfig{}
+	runtimeConfig.Rules = map[int64]*rule.Rule{r.ID: r}
+
+	triggeredAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	newIncident := func(t *testing.T) *Incident {
+		i := NewIncident(nil, nil, runtimeConfig, zaptest.NewLogger(t).Sugar())
+		i.StartedAt = types.UnixMilli(triggeredAt)
+		for _, escalation := range []*rule.Escalation{renotifying, once} {
+			i.EscalationState[escalation.ID] = &EscalationState{
+				RuleEscalationID: escalation.ID,
+				TriggeredAt:      types.UnixMilli(triggeredAt),
+			}
+		}
+
+		return i
+	}
+
+	t.Run("NotYetDue", func(t *testing.T) {
+		t.Parallel()
+
+		next, due := newIncident(t).nextRenotification(triggeredAt.Add(10 * time.Minute))
+		assert.Equal(t, triggeredAt.Add(30*time.Minute), next)
+		assert.Empty(t, due)
+	})
+
+	t.Run("Due", func(t *testing.T) {
+		t.Parallel()
+
+		next, due := newIncident(t).nextRenotification(triggeredAt.Add(30 * time.Minute))
+		assert.Equal(t, triggeredAt.Add(30*time.Minute), next)
+		assert.Equal(t, []*rule.Escalation{renotifying}, due)
+	})
+
+	t.Run("AfterRenotification", func(t *testing.T) {
+		t.Parallel()
+
+		i := newIncident(t)
+		i.renotifiedAt[renotifying.ID] = triggeredAt.Add(30 * time.Minute)
+
+		next, due := i.nextRenotification(triggeredAt.Add(45 * time.Minute))
+		assert.Equal(t, triggeredAt.Add(time.Hour), next)
+		assert.Empty(t, due)
+	})
+
+	t.Run("Snoozed", func(t *testing.T) {
+		t.Parallel()
+
+		i := newIncident(t)
+		i.snoozedUntil = triggeredAt.Add(90 * time.Minute)
+
+		next, due := i.nextRenotification(triggeredAt.Add(45 * time.Minute))
+		assert.Equal(t, i.snoozedUntil, next)
+		assert.Empty(t, due)
+
+		next, due = i.nextRenotification(i.snoozedUntil)
+		assert.Equal(t, i.snoozedUntil, next)
+		assert.Equal(t, []*rule.Escalation{renotifying}, due)
+	})
+
+	t.Run("HasManager", func(t *testing.T) {
+		t.Parallel()
+
+		contact := &recipient.Contact{}
+		contact.ID = 1
+		runtimeConfig := &config.RuntimeConfig{}
+		runtimeConfig.Rules = map[int64]*rule.Rule{r.ID: r}
+		runtimeConfig.Contacts = map[int64]*recipient.Contact{contact.ID: contact}
+
+		i := newIncident(t)
+		i.runtimeConfig = runtimeConfig
+		i.Recipients[recipient.ToKey(contact)] = &RecipientState{Role: RoleManager}
+
+		next, due := i.nextRenotification(triggeredAt.Add(time.Hour))
+		assert.True(t, next.IsZero())
+		assert.Empty(t, due)
+	})
+
+	t.Run("Recovered", func(t *testing.T) {
+		t.Parallel()
+
+		i := newIncident(t)
+		i.RecoveredAt = types.UnixMilli(triggeredAt.Add(time.Minute))
+
+		next, due := i.nextRenotification(triggeredAt.Add(time.Hour))
+		assert.True(t, next.IsZero())
+		assert.Empty(t, due)
+	})
+}
@@ -0,0 +1,139 @@
+package incident
+
+import (
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// deliveryFailures tracks the latest failed notification of each contact via each channel, shared by all incidents.
+var deliveryFailures = &failureTracker{failedAt: map[contactChannel]time.Time{}}
+
+// contactChannel identifies a channel of a contact.
+type contactChannel struct {
+	contactID int64
+	channelID int64
+}
+
+// failureTracker remembers when notifying a contact via a channel failed the last time, until it succeeds again.
+type failureTracker struct {
+	failedAt map[contactChannel]time.Time
+	mu       sync.Mutex
+}
+
+// record the outcome of notifying the contact via the channel at the given time.
+func (f *failureTracker) record(contactID, channelID int64, failed bool, t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := contactChannel{contactID: contactID, channelID: channelID}
+	if failed {
+		f.failedAt[key] = t
+	} else {
+		delete(f.failedAt, key)
+	}
+}
+
+// failedSince returns when notifying the contact via the channel failed the last time within the window before t,
+// or the zero time if it didn't.
+func (f *failureTracker) failedSince(contactID, channelID int64, t time.Time, window time.Duration) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	failedAt, ok := f.failedAt[contactChannel{contactID: contactID, channelID: channelID}]
+	if !ok || window <= 0 || t.Sub(failedAt) >= window {
+		return time.Time{}
+	}
+
+	return failedAt
+}
+
+// degradedReason explains why the channel is unlikely to reach the contact at the given time, or returns an empty
+// string if there is no reason to avoid the channel.
+//
+// A channel is degraded if notifying the contact via it failed within the window, or if all the contact's addresses of
+// the channel's type are unverified, see recipient.Address.Unverified.
+func (i *Incident) degradedReason(contact *recipient.Contact, chID int64, t time.Time, window time.Duration) string {
+	failedAt := deliveryFailures.failedSince(contact.ID, chID, t, window)
+	if !failedAt.IsZero() {
+		return fmt.Sprintf("notifying the contact via this channel failed at %s", failedAt.Format(time.RFC3339))
+	}
+
+	if ch := i.runtimeConfig.Channels[chID]; ch != nil {
+		unverified := 0
+		for _, address := range contact.Addresses {
+			if address.Type == ch.Type {
+				if !address.Unverified() {
+					return ""
+				}
+				unverified++
+			}
+		}
+		if unverified > 0 {
+			return fmt.Sprintf("the contact's %s address is not verified", ch.Type)
+		}
+	}
+
+	return ""
+}
+
+// routeAroundDegradedChannels replaces the degraded channels of the contacts with their default channel, recording a
+// ContactChannelDegraded history entry for each, see degradedReason and the daemon's delivery-failures.window.
+//
+// A degraded channel is still used if the contact's default channel is the degraded one itself or degraded as well, as
+// a notification via an unreliable channel is better than none at all.
+func (i *Incident) routeAroundDegradedChannels(
+	ctx context.Context, tx *sqlx.Tx, ev *event.Event, contactChannels rule.ContactChannels,
+) (rule.ContactChannels, error) {
+	now := time.Now()
+	window := daemon.Config().DeliveryFailures.Window
+	routed := make(rule.ContactChannels, len(contactChannels))
+
+	for contact, channels := range contactChannels {
+		routed[contact] = make(map[int64]bool, len(channels))
+		for chID := range channels {
+			reason := i.degradedReason(contact, chID, now, window)
+			if reason == "" {
+				routed[contact][chID] = true
+				continue
+			}
+
+			fallbackID := contact.DefaultChannelID
+			message := fmt.Sprintf("Channel is degraded as %s, notifying via the contact's default channel instead", reason)
+			if fallbackID == chID || i.runtimeConfig.Channels[fallbackID] == nil || i.degradedReason(contact, fallbackID, now, window) != "" {
+				fallbackID = chID
+				message = fmt.Sprintf("Channel is degraded as %s, but the contact has no other channel to fall back to", reason)
+			}
+
+			i.logger.Warnw("Routing notification around degraded channel", zap.String("contact", contact.FullName),
+				zap.Int64("channel_id", chID), zap.Int64("fallback_channel_id", fallbackID), zap.String("reason", reason))
+
+			hr := &HistoryRow{
+				IncidentID: i.Id,
+				Key:        recipient.ToKey(contact),
+				EventID:    utils.ToDBInt(ev.ID),
+				Time:       types.UnixMilli(now),
+				Type:       ContactChannelDegraded,
+				ChannelID:  utils.ToDBInt(chID),
+				Message:    utils.ToDBString(message),
+			}
+			if err := hr.Sync(ctx, i.db, tx); err != nil {
+				i.logger.Errorw("Failed to insert contact channel degraded history", zap.Error(err))
+				return nil, err
+			}
+
+			routed[contact][fallbackID] = true
+		}
+	}
+
+	return routed, nil
+}
@@ -0,0 +1,74 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/channel"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"testing"
+	"time"
+)
+
+func TestFailureTracker(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	f := &failureTracker{failedAt: map[contactChannel]time.Time{}}
+
+	assert.True(t, f.failedSince(1, 2, start, time.Hour).IsZero(), "nothing failed yet")
+
+	f.record(1, 2, true, start)
+	assert.Equal(t, start, f.failedSince(1, 2, start.Add(30*time.Minute), time.Hour))
+	assert.True(t, f.failedSince(1, 3, start.Add(30*time.Minute), time.Hour).IsZero(), "other channel did not fail")
+	assert.True(t, f.failedSince(1, 2, start.Add(time.Hour), time.Hour).IsZero(), "failure should expire")
+	assert.True(t, f.failedSince(1, 2, start, 0).IsZero(), "zero window should disable tracking")
+
+	f.record(1, 2, false, start.Add(time.Minute))
+	assert.True(t, f.failedSince(1, 2, start.Add(time.Minute), time.Hour).IsZero(), "success should clear failure")
+	assert.Empty(t, f.failedAt)
+}
+
+func TestIncident_degradedReason(t *testing.T) {
+	t.Parallel()
+
+	newChannel := func(typ string) *channel.Channel {
+		return &channel.Channel{Type: typ}
+	}
+
+	runtimeConfig := &config.RuntimeConfig{}
+	runtimeConfig.Channels = map[int64]*channel.Channel{
+		1: newChannel("email"),
+		2: newChannel("rocketchat"),
+		3: newChannel("webhook"),
+	}
+
+	address := func(typ string, verified types.Bool) *recipient.Address {
+		return &recipient.Address{Type: typ, Verified: verified}
+	}
+
+	// The contact ID is unique to this test, as deliveryFailures is shared with all other tests of the package.
+	contact := &recipient.Contact{FullName: "Jane Doe", Addresses: []*recipient.Address{
+		address("email", types.Bool{Bool: false, Valid: true}),
+		address("email", types.Bool{Bool: true, Valid: true}),
+		address("rocketchat", types.Bool{Bool: false, Valid: true}),
+	}}
+	contact.ID = 1001
+
+	i := NewIncident(nil, nil, runtimeConfig, zaptest.NewLogger(t).Sugar())
+	now := time.Now()
+
+	assert.Empty(t, i.degradedReason(contact, 1, now, time.Hour), "one verified email address is enough")
+	assert.Equal(t, "the contact's rocketchat address is not verified", i.degradedReason(contact, 2, now, time.Hour))
+	assert.Empty(t, i.degradedReason(contact, 3, now, time.Hour), "without addresses, there is nothing to verify")
+	assert.Empty(t, i.degradedReason(contact, 4, now, time.Hour), "unknown channels are not degraded")
+
+	failedAt := now.Add(-time.Minute)
+	deliveryFailures.record(contact.ID, 1, true, failedAt)
+	assert.Contains(t, i.degradedReason(contact, 1, now, time.Hour), failedAt.Format(time.RFC3339))
+	assert.Empty(t, i.degradedReason(contact, 1, now, 0), "zero window should ignore failures")
+
+	deliveryFailures.record(contact.ID, 1, false, now)
+	assert.Empty(t, i.degradedReason(contact, 1, now, time.Hour))
+}
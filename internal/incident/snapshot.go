@@ -0,0 +1,89 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"go.uber.org/zap"
+	"maps"
+	"time"
+)
+
+// snapshot holds the in-memory state of an incident and the event being processed, as modified while processing the
+// event within a database transaction. It allows restoring that state after the transaction was rolled back.
+type snapshot struct {
+	incident *Incident
+	ev       *event.Event
+
+	id          int64
+	startedAt   types.UnixMilli
+	recoveredAt types.UnixMilli
+	severity    event.Severity
+	lastEventAt time.Time
+	logger      *zap.SugaredLogger
+	eventID     int64
+
+	escalationState map[escalationID]EscalationState
+	rules           map[ruleID]struct{}
+	recipients      map[recipient.Key]RecipientState
+	watches         map[int64]*WatchRow
+	annotations     map[string]string
+}
+
+// snapshot captures the incident's current in-memory state and the given event's ID, see snapshot.restore.
+func (i *Incident) snapshot(ev *event.Event) *snapshot {
+	s := &snapshot{
+		incident:        i,
+		ev:              ev,
+		id:              i.Id,
+		startedAt:       i.StartedAt,
+		recoveredAt:     i.RecoveredAt,
+		severity:        i.Severity,
+		lastEventAt:     i.lastEventAt,
+		logger:          i.logger,
+		eventID:         ev.ID,
+		escalationState: make(map[escalationID]EscalationState, len(i.EscalationState)),
+		rules:           maps.Clone(i.Rules),
+		recipients:      make(map[recipient.Key]RecipientState, len(i.Recipients)),
+		watches:         i.Watches,
+		annotations:     i.Annotations,
+	}
+
+	for id, state := range i.EscalationState {
+		s.escalationState[id] = *state
+	}
+	for key, state := range i.Recipients {
+		s.recipients[key] = *state
+	}
+
+	return s
+}
+
+// restore resets the incident and event to the state captured by Incident.snapshot and cancels the throttling
+// decisions staged since, see Incident.settleThrottle.
+func (s *snapshot) restore() {
+	i := s.incident
+	i.Id = s.id
+	i.StartedAt = s.startedAt
+	i.RecoveredAt = s.recoveredAt
+	i.Severity = s.severity
+	i.lastEventAt = s.lastEventAt
+	i.logger = s.logger
+	i.Rules = s.rules
+	i.Watches = s.watches
+	i.Annotations = s.annotations
+	s.ev.ID = s.eventID
+
+	// Notifications of the rolled back transaction were never sent, thus don't count towards the throttling limit.
+	i.settleThrottle(false)
+
+	i.EscalationState = make(map[escalationID]*EscalationState, len(s.escalationState))
+	for id, state := range s.escalationState {
+		i.EscalationState[id] = &state
+	}
+
+	i.Recipients = make(map[recipient.Key]*RecipientState, len(s.recipients))
+	for key, state := range s.recipients {
+		i.Recipients[key] = &state
+	}
+}
@@ -0,0 +1,47 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"testing"
+	"time"
+)
+
+func TestIncident_snapshot(t *testing.T) {
+	t.Parallel()
+
+	i := NewIncident(nil, &object.Object{ID: []byte{1}}, nil, zaptest.NewLogger(t).Sugar())
+	i.Id = 1
+	i.Severity = event.SeverityWarning
+	i.StartedAt = types.UnixMilli(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	i.Rules[1] = struct{}{}
+	i.EscalationState[1] = &EscalationState{RuleEscalationID: 1}
+	contact := recipient.Key{ContactID: utils.ToDBInt(1)}
+	i.Recipients[contact] = &RecipientState{Role: RoleRecipient}
+
+	ev := &event.Event{Type: event.TypeState, Severity: event.SeverityOK}
+	snapshot := i.snapshot(ev)
+
+	// Apply some changes as done by processing an event, which are rolled back afterward.
+	ev.ID = 42
+	i.Severity = event.SeverityOK
+	i.RecoveredAt = types.UnixMilli(time.Now())
+	i.Rules[2] = struct{}{}
+	i.EscalationState[1].IncidentID = i.Id
+	i.EscalationState[2] = &EscalationState{RuleEscalationID: 2}
+	i.Recipients[contact].Role = RoleManager
+
+	snapshot.restore()
+
+	assert.Zero(t, ev.ID)
+	assert.Equal(t, event.SeverityWarning, i.Severity)
+	assert.True(t, i.RecoveredAt.Time().IsZero())
+	assert.Equal(t, map[ruleID]struct{}{1: {}}, i.Rules)
+	assert.Equal(t, map[escalationID]*EscalationState{1: {RuleEscalationID: 1}}, i.EscalationState)
+	assert.Equal(t, RoleRecipient, i.Recipients[contact].Role)
+}
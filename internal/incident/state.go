@@ -0,0 +1,143 @@
+package incident
+
+import (
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-go-library/types"
+	"go.uber.org/zap"
+	"time"
+)
+
+// StateRow is the exported volatile in-memory state of a current incident, see StateExporter.
+//
+// Without it, LoadOpenIncidents has to recompute these times from the whole incident history and events.
+type StateRow struct {
+	IncidentID     int64           `db:"incident_id"`
+	LastNotifiedAt types.UnixMilli `db:"last_notified_at"`
+	LastEventAt    types.UnixMilli `db:"last_event_at"`
+	ExportedAt     types.UnixMilli `db:"exported_at"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (s *StateRow) TableName() string {
+	return "incident_state"
+}
+
+// Upsert implements the contracts.Upserter interface.
+func (s *StateRow) Upsert() interface{} {
+	return &struct {
+		LastNotifiedAt types.UnixMilli `db:"last_notified_at"`
+		LastEventAt    types.UnixMilli `db:"last_event_at"`
+		ExportedAt     types.UnixMilli `db:"exported_at"`
+	}{}
+}
+
+// equal compares the exported state, ignoring the export time.
+func (s *StateRow) equal(other *StateRow) bool {
+	return other != nil &&
+		s.IncidentID == other.IncidentID &&
+		s.LastNotifiedAt.Time().Equal(other.LastNotifiedAt.Time()) &&
+		s.LastEventAt.Time().Equal(other.LastEventAt.Time())
+}
+
+// stateRow returns the current volatile state of this incident to be exported.
+func (i *Incident) stateRow(now time.Time) *StateRow {
+	i.Lock()
+	defer i.Unlock()
+
+	return &StateRow{
+		IncidentID:     i.Id,
+		LastNotifiedAt: types.UnixMilli(i.lastNotifiedAt),
+		LastEventAt:    types.UnixMilli(i.lastEventAt),
+		ExportedAt:     types.UnixMilli(now),
+	}
+}
+
+// restoreState applies the previously exported state to this incident, which is in the process of being loaded.
+func (i *Incident) restoreState(s *StateRow) {
+	i.lastEventAt = s.LastEventAt.Time()
+	i.lastNotifiedAt = s.LastNotifiedAt.Time()
+
+	// Like when restoring from the history, defer all renotifications based on the last sent notification.
+	if !i.lastNotifiedAt.IsZero() {
+		for escalationID := range i.EscalationState {
+			i.renotifiedAt[escalationID] = i.lastNotifiedAt
+		}
+	}
+}
+
+// StateExporter periodically exports the volatile in-memory state of all current incidents to the database.
+//
+// Thus, another daemon taking over, e.g., a warm standby, or this daemon after a restart resumes its timers for
+// unanswered notifications, renotifications and closing inactive incidents right away, see LoadOpenIncidents. Any
+// other state is either persisted right away, e.g., escalation states and pending notifications, or derived from it.
+type StateExporter struct {
+	DB       *database.DB
+	Logger   *logging.Logger
+	Interval time.Duration
+
+	// exported holds the last exported state per incident ID, allowing to only write changed states.
+	exported map[int64]*StateRow
+}
+
+// Run the export loop until the context is done. A final export is performed when shutting down.
+func (e *StateExporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.export(ctx); err != nil {
+				e.Logger.Errorw("Cannot export incident states", zap.Error(err))
+			}
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), e.Interval)
+			if err := e.export(shutdownCtx); err != nil {
+				e.Logger.Errorw("Cannot export incident states on shutdown", zap.Error(err))
+			}
+			cancel()
+
+			return
+		}
+	}
+}
+
+// export writes the changed states of all current incidents and removes the states of closed incidents.
+func (e *StateExporter) export(ctx context.Context) error {
+	now := time.Now()
+	var changed []*StateRow
+	current := make(map[int64]*StateRow)
+	for id, i := range GetCurrentIncidents() {
+		state := i.stateRow(now)
+		if state.IncidentID == 0 {
+			// The incident is just being created.
+			continue
+		}
+
+		current[id] = state
+		if !state.equal(e.exported[id]) {
+			changed = append(changed, state)
+		}
+	}
+
+	if len(changed) > 0 {
+		stmt, _ := e.DB.BuildUpsertStmt(&StateRow{})
+		if _, err := e.DB.NamedExecContext(ctx, stmt, changed); err != nil {
+			return fmt.Errorf("cannot upsert incident states: %w", err)
+		}
+	}
+
+	_, err := e.DB.ExecContext(ctx, `DELETE FROM "incident_state" WHERE "incident_id" IN (
+		SELECT "id" FROM "incident" WHERE "recovered_at" IS NOT NULL)`)
+	if err != nil {
+		return fmt.Errorf("cannot delete states of closed incidents: %w", err)
+	}
+
+	e.Logger.Debugw("Exported incident states", zap.Int("changed", len(changed)), zap.Int("current", len(current)))
+	e.exported = current
+
+	return nil
+}
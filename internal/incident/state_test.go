@@ -0,0 +1,49 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"testing"
+	"time"
+)
+
+func TestIncident_exportAndRestoreState(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().Truncate(time.Millisecond)
+	lastNotifiedAt := now.Add(-time.Hour)
+	lastEventAt := now.Add(-time.Minute)
+
+	i := NewIncident(nil, nil, nil, zaptest.NewLogger(t).Sugar())
+	i.Id = 42
+	i.lastNotifiedAt = lastNotifiedAt
+	i.lastEventAt = lastEventAt
+
+	state := i.stateRow(now)
+	assert.Equal(t, int64(42), state.IncidentID)
+	assert.True(t, state.equal(i.stateRow(now.Add(time.Minute))), "the export time must not be compared")
+	assert.False(t, state.equal(nil))
+
+	i.lastEventAt = now
+	assert.False(t, state.equal(i.stateRow(now)))
+
+	restored := NewIncident(nil, nil, nil, zaptest.NewLogger(t).Sugar())
+	restored.EscalationState[1] = &EscalationState{RuleEscalationID: 1}
+	restored.restoreState(state)
+
+	assert.Equal(t, lastNotifiedAt, restored.lastNotifiedAt)
+	assert.Equal(t, lastEventAt, restored.lastEventAt)
+	assert.Equal(t, map[escalationID]time.Time{1: lastNotifiedAt}, restored.renotifiedAt)
+
+	t.Run("NeverNotified", func(t *testing.T) {
+		t.Parallel()
+
+		restored := NewIncident(nil, nil, nil, zaptest.NewLogger(t).Sugar())
+		restored.EscalationState[1] = &EscalationState{RuleEscalationID: 1}
+		restored.restoreState(&StateRow{IncidentID: 42, LastEventAt: types.UnixMilli(lastEventAt)})
+
+		assert.True(t, restored.lastNotifiedAt.IsZero())
+		assert.Empty(t, restored.renotifiedAt)
+	})
+}
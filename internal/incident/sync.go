@@ -64,64 +64,143 @@ func (i *Incident) AddEvent(ctx context.Context, tx *sqlx.Tx, ev *event.Event) e
 
 // AddRecipient adds recipient from the given *rule.Escalation to this incident.
 // Syncs also all the recipients with the database and returns an error on db failure.
+//
+// For escalation recipients selecting a single contact, the selected contact is added as an additional recipient.
 func (i *Incident) AddRecipient(ctx context.Context, tx *sqlx.Tx, escalation *rule.Escalation, eventId int64) error {
 	newRole := RoleRecipient
-	if i.HasManager() {
+	if i.IsHandled() {
 		newRole = RoleSubscriber
 	}
 
 	for _, escalationRecipient := range escalation.Recipients {
-		r := escalationRecipient.Recipient
-		cr := &ContactRow{IncidentID: i.Id, Role: newRole}
-
-		recipientKey := recipient.ToKey(r)
-		cr.Key = recipientKey
-
-		state, ok := i.Recipients[recipientKey]
-		if !ok {
-			i.Recipients[recipientKey] = &RecipientState{Role: newRole}
-		} else {
-			if state.Role < newRole {
-				oldRole := state.Role
-				state.Role = newRole
-
-				i.logger.Infof("Contact %q role changed from %s to %s", r, state.Role.String(), newRole.String())
-
-				hr := &HistoryRow{
-					IncidentID:       i.Id,
-					EventID:          utils.ToDBInt(eventId),
-					Key:              cr.Key,
-					Time:             types.UnixMilli(time.Now()),
-					Type:             RecipientRoleChanged,
-					NewRecipientRole: newRole,
-					OldRecipientRole: oldRole,
-				}
-
-				if err := hr.Sync(ctx, i.db, tx); err != nil {
-					i.logger.Errorw(
-						"Failed to insert recipient role changed incident history", zap.Object("escalation", escalation),
-						zap.String("recipients", r.String()), zap.Error(err),
-					)
-					return err
-				}
-			}
-			cr.Role = state.Role
+		if !i.isVisible(escalationRecipient.Recipient) {
+			i.logger.Warnw("Ignoring escalation recipient of another tenant", zap.Object("escalation", escalation),
+				zap.Object("recipient", escalationRecipient))
+			continue
 		}
 
-		stmt, _ := i.db.BuildUpsertStmt(cr)
-		_, err := tx.NamedExecContext(ctx, stmt, cr)
-		if err != nil {
-			i.logger.Errorw(
-				"Failed to upsert incident recipient", zap.Object("escalation", escalation),
-				zap.String("recipient", r.String()), zap.Error(err),
-			)
+		if err := i.addRecipient(ctx, tx, escalation, escalationRecipient.Recipient, newRole, eventId); err != nil {
 			return err
 		}
+
+		if escalationRecipient.SelectsSingleContact() {
+			contact, err := i.selectContact(ctx, tx, escalationRecipient)
+			if err != nil {
+				i.logger.Errorw(
+					"Failed to select contact of escalation recipient", zap.Object("escalation", escalation),
+					zap.Object("recipient", escalationRecipient), zap.Error(err),
+				)
+				return err
+			}
+
+			if contact == nil {
+				i.logger.Warnw("Escalation recipient expanded to no contacts", zap.Object("escalation", escalation),
+					zap.Object("recipient", escalationRecipient))
+				continue
+			}
+
+			i.logger.Infow("Selected contact of escalation recipient", zap.Object("escalation", escalation),
+				zap.Object("recipient", escalationRecipient), zap.String("contact", contact.String()))
+
+			if err := i.addRecipient(ctx, tx, escalation, contact, newRole, eventId); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// addRecipient adds a single recipient to this incident in the given role, unless it already has a higher role.
+func (i *Incident) addRecipient(
+	ctx context.Context, tx *sqlx.Tx, escalation *rule.Escalation, r recipient.Recipient, newRole ContactRole, eventId int64,
+) error {
+	cr := &ContactRow{IncidentID: i.Id, Role: newRole}
+
+	recipientKey := recipient.ToKey(r)
+	cr.Key = recipientKey
+
+	state, ok := i.Recipients[recipientKey]
+	if !ok {
+		i.Recipients[recipientKey] = &RecipientState{Role: newRole}
+	} else {
+		if state.Role < newRole {
+			oldRole := state.Role
+			state.Role = newRole
+
+			i.logger.Infof("Contact %q role changed from %s to %s", r, state.Role.String(), newRole.String())
+
+			hr := &HistoryRow{
+				IncidentID:       i.Id,
+				EventID:          utils.ToDBInt(eventId),
+				Key:              cr.Key,
+				Time:             types.UnixMilli(time.Now()),
+				Type:             RecipientRoleChanged,
+				NewRecipientRole: newRole,
+				OldRecipientRole: oldRole,
+			}
+
+			if err := hr.Sync(ctx, i.db, tx); err != nil {
+				i.logger.Errorw(
+					"Failed to insert recipient role changed incident history", zap.Object("escalation", escalation),
+					zap.String("recipients", r.String()), zap.Error(err),
+				)
+				return err
+			}
+		}
+		cr.Role = state.Role
+	}
+
+	stmt, _ := i.db.BuildUpsertStmt(cr)
+	_, err := tx.NamedExecContext(ctx, stmt, cr)
+	if err != nil {
+		i.logger.Errorw(
+			"Failed to upsert incident recipient", zap.Object("escalation", escalation),
+			zap.String("recipient", r.String()), zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// selectContact selects the single contact to be notified for the escalation recipient and persists this selection.
+// Returns nil if the recipient currently has no contacts.
+func (i *Incident) selectContact(
+	ctx context.Context, tx *sqlx.Tx, escalationRecipient *rule.EscalationRecipient,
+) (*recipient.Contact, error) {
+	now := time.Now()
+	contacts := escalationRecipient.Recipient.GetContactsAt(now)
+	if len(contacts) == 0 {
+		return nil, nil
+	}
+
+	var states []*DeliveryStateRow
+	stmt := i.db.BuildSelectStmt(new(DeliveryStateRow), new(DeliveryStateRow)) + ` WHERE "rule_escalation_recipient_id" = ?`
+	if err := tx.SelectContext(ctx, &states, tx.Rebind(stmt), escalationRecipient.ID); err != nil {
+		return nil, err
+	}
+
+	lastSelected := make(map[int64]time.Time, len(states))
+	for _, state := range states {
+		lastSelected[state.ContactID] = state.LastSelectedAt.Time()
+	}
+
+	contact := escalationRecipient.SelectContact(contacts, lastSelected)
+
+	state := &DeliveryStateRow{
+		EscalationRecipientID: escalationRecipient.ID,
+		ContactID:             contact.ID,
+		LastSelectedAt:        types.UnixMilli(now),
+	}
+	upsertStmt, _ := i.db.BuildUpsertStmt(state)
+	if _, err := tx.NamedExecContext(ctx, upsertStmt, state); err != nil {
+		return nil, err
+	}
+
+	return contact, nil
+}
+
 // AddRuleMatched syncs the given *rule.Rule to the database.
 // Returns an error on database failure.
 func (i *Incident) AddRuleMatched(ctx context.Context, tx *sqlx.Tx, r *rule.Rule) error {
@@ -132,49 +211,187 @@ func (i *Incident) AddRuleMatched(ctx context.Context, tx *sqlx.Tx, r *rule.Rule
 	return err
 }
 
-// generateNotifications generates incident notification histories of the given recipients.
+// generateNotifications generates incident notification histories of the given type, i.e., either Notified or
+// Renotified, for the given recipients.
 //
 // This function will just insert NotificationStateSuppressed incident histories and return an empty slice if
-// the current Object is muted, otherwise a slice of pending *NotificationEntry(ies) that can be used to update
-// the corresponding histories after the actual notifications have been sent out.
+// the current Object is muted or the daemon is in read-only mode, otherwise a slice of pending *NotificationEntry(ies) that can be used to update
+// the corresponding histories after the actual notifications have been sent out. Notifications of contacts who opted
+// out of a channel are suppressed individually, see recipient.OptOut, and those exceeding the contact's limit are
+// throttled, see Incident.throttleNotification, while degraded channels are replaced by the contact's default channel,
+// see Incident.routeAroundDegradedChannels. Contacts and channels of another tenant than the incident's one are
+// skipped, see Incident.isVisible.
 func (i *Incident) generateNotifications(
-	ctx context.Context, tx *sqlx.Tx, ev *event.Event, contactChannels rule.ContactChannels,
+	ctx context.Context, tx *sqlx.Tx, ev *event.Event, contactChannels rule.ContactChannels, historyType HistoryEventType,
 ) ([]*NotificationEntry, error) {
 	var notifications []*NotificationEntry
-	suppress := i.isMuted && i.Object.IsMuted()
+	// Summaries of the events while muted are the only notifications sent while muted, see sendMutedSummary.
+	suppress := i.isMuted && i.Object.IsMuted() && ev.Type != event.TypeMutedSummary
+	if ev.Type == event.TypeState && flapping.isFlapping(i) {
+		// Severity changes of flapping objects are only recorded, see flapDetector.
+		suppress = true
+	}
+	if ReadOnly() && len(contactChannels) > 0 {
+		i.logger.Warnw("Suppressing notifications in read-only mode", zap.String("event", ev.String()))
+		suppress = true
+	}
+	if window := i.runtimeConfig.GetMaintenanceWindow(i.Object, ev.Time); window != nil {
+		i.logger.Infow("Suppressing notifications of object in maintenance", zap.Object("maintenance_window", window))
+		suppress = true
+	}
+	if !suppress && (ev.Type == event.TypeState || ev.Type == event.TypeIncidentAge) {
+		parent, err := i.criticalParent(ctx, tx)
+		if err != nil {
+			i.logger.Errorw("Cannot check the parent objects", zap.Error(err))
+			return nil, err
+		}
+		if parent != nil {
+			i.logger.Infow("Suppressing notifications of object depending on a critical parent object",
+				zap.Stringer("parent_object_id", parent))
+			suppress = true
+		}
+	}
+
+	if !suppress {
+		var err error
+		if contactChannels, err = i.routeAroundDegradedChannels(ctx, tx, ev, contactChannels); err != nil {
+			return nil, err
+		}
+	}
+
+	// All history entries are inserted at once below, as large escalations might notify hundreds of contacts.
+	type pendingNotification struct {
+		hr         *HistoryRow
+		contact    *recipient.Contact
+		channelID  int64
+		suppressed bool
+		throttled  bool
+	}
+	var pending []*pendingNotification
+
+	// Overlapping rules or escalations might resolve to the same contact and channel, e.g., via different groups.
+	// Each contact is only notified once per event and channel, referencing all contributing escalations.
+	notified := make(map[notificationKey]bool)
 	for contact, channels := range contactChannels {
+		if !i.isVisible(contact) {
+			i.logger.Warnw("Skipping notifications of contact of another tenant", zap.String("contact", contact.FullName))
+			continue
+		}
+
 		for chID := range channels {
+			if ch := i.runtimeConfig.Channels[chID]; ch != nil && !i.isVisible(ch) {
+				i.logger.Warnw("Skipping notification via channel of another tenant",
+					zap.String("contact", contact.FullName), zap.Int64("channel_id", chID))
+				continue
+			}
+
+			key := notificationKey{eventID: ev.ID, contactID: contact.ID, channelID: chID}
+			if notified[key] {
+				i.logger.Debugw("Skipping duplicate notification",
+					zap.String("contact", contact.FullName), zap.Int64("channel_id", chID))
+				continue
+			}
+			notified[key] = true
+
+			suppressed := suppress
+			if optOut := contact.OptedOut(chID, ev.Time); optOut != nil && !suppress {
+				i.logger.Infow("Suppressing notification of opted out contact",
+					zap.String("contact", contact.FullName), zap.Object("opt_out", optOut))
+				suppressed = true
+			}
+
+			throttled := false
+			if !suppressed && i.throttleNotification(contact.ID, time.Now(), ev.Message) {
+				i.logger.Infow("Throttling notification of contact, sending a digest later",
+					zap.String("contact", contact.FullName), zap.Int64("channel_id", chID))
+				throttled = true
+			}
+
 			hr := &HistoryRow{
 				IncidentID:        i.Id,
 				Key:               recipient.ToKey(contact),
 				EventID:           utils.ToDBInt(ev.ID),
 				Time:              types.UnixMilli(time.Now()),
-				Type:              Notified,
+				Type:              historyType,
 				ChannelID:         utils.ToDBInt(chID),
 				NotificationState: NotificationStatePending,
 				Message:           utils.ToDBString(ev.Message),
 			}
-			if suppress {
+			if suppressed {
 				hr.NotificationState = NotificationStateSuppressed
+			} else if throttled {
+				hr.NotificationState = NotificationStateThrottled
 			}
 
-			if err := hr.Sync(ctx, i.db, tx); err != nil {
-				i.logger.Errorw("Failed to insert incident notification history",
-					zap.String("contact", contact.FullName), zap.Bool("incident_muted", i.Object.IsMuted()),
-					zap.Error(err))
-				return nil, err
-			}
+			pending = append(pending, &pendingNotification{
+				hr:         hr,
+				contact:    contact,
+				channelID:  chID,
+				suppressed: suppressed,
+				throttled:  throttled,
+			})
+		}
+	}
 
-			if !suppress {
-				notifications = append(notifications, &NotificationEntry{
-					HistoryRowID: hr.ID,
-					ContactID:    contact.ID,
-					State:        NotificationStatePending,
-					ChannelID:    chID,
-				})
-			}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	historyRows := make([]*HistoryRow, 0, len(pending))
+	for _, p := range pending {
+		historyRows = append(historyRows, p.hr)
+	}
+	if err := syncHistoryRows(ctx, i.db, tx, historyRows); err != nil {
+		i.logger.Errorw("Failed to insert incident notification history", zap.Int("notifications", len(pending)),
+			zap.Bool("incident_muted", i.Object.IsMuted()), zap.Error(err))
+		return nil, err
+	}
+
+	var historyRules []*HistoryRuleRow
+	for _, p := range pending {
+		historyRules = append(historyRules, i.historyRules(p.hr, p.contact, p.channelID, ev.Time)...)
+
+		if !p.suppressed && !p.throttled {
+			notifications = append(notifications, &NotificationEntry{
+				HistoryRowID: p.hr.ID,
+				ContactID:    p.contact.ID,
+				State:        NotificationStatePending,
+				ChannelID:    p.channelID,
+			})
+		}
+	}
+
+	if len(historyRules) > 0 {
+		stmt, _ := i.db.BuildInsertStmt(&HistoryRuleRow{})
+		if _, err := tx.NamedExecContext(ctx, stmt, historyRules); err != nil {
+			i.logger.Errorw("Failed to insert contributing rules of incident notification history", zap.Error(err))
+			return nil, err
 		}
 	}
 
 	return notifications, nil
 }
+
+// notificationKey identifies a single notification, i.e., an event being sent to a contact via a channel.
+type notificationKey struct {
+	eventID   int64
+	contactID int64
+	channelID int64
+}
+
+// historyRules returns the references of all escalations contributing to the given notification history entry, see
+// Incident.getContributingEscalations.
+func (i *Incident) historyRules(hr *HistoryRow, contact *recipient.Contact, chID int64, t time.Time) []*HistoryRuleRow {
+	escalations := i.getContributingEscalations(contact, chID, t)
+	rows := make([]*HistoryRuleRow, 0, len(escalations))
+	for _, escalation := range escalations {
+		ruleID := escalation.RuleID.Int64
+		if r := i.escalationRule(escalation); r != nil {
+			ruleID = r.ID
+		}
+
+		rows = append(rows, &HistoryRuleRow{HistoryID: hr.ID, RuleEscalationID: escalation.ID, RuleID: ruleID})
+	}
+
+	return rows
+}
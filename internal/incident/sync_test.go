@@ -0,0 +1,66 @@
+package incident
+
+import (
+	"database/sql"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"testing"
+	"time"
+)
+
+func TestIncident_getContributingEscalations(t *testing.T) {
+	t.Parallel()
+
+	contact := &recipient.Contact{FullName: "Jane Doe", DefaultChannelID: 3}
+	contact.ID = 1
+
+	group := &recipient.Group{Name: "Ops", Members: []*recipient.Contact{contact}}
+	group.ID = 10
+
+	newEscalation := func(id, ruleID int64, r recipient.Recipient, channelID int64) *rule.Escalation {
+		er := &rule.EscalationRecipient{Key: recipient.ToKey(r), Recipient: r}
+		if channelID != 0 {
+			er.ChannelID = sql.NullInt64{Int64: channelID, Valid: true}
+		}
+
+		e := &rule.Escalation{RuleID: utils.ToDBInt(ruleID), Recipients: []*rule.EscalationRecipient{er}}
+		e.ID = id
+		return e
+	}
+
+	// Both rules notify the contact via the default channel, directly and via a group. The second rule additionally
+	// uses another channel, while the escalation of the third rule was not triggered.
+	runtimeConfig := &config.RuntimeConfig{}
+	runtimeConfig.Rules = map[int64]*rule.Rule{
+		1: {Escalations: map[int64]*rule.Escalation{11: newEscalation(11, 1, group, 0)}},
+		2: {Escalations: map[int64]*rule.Escalation{
+			21: newEscalation(21, 2, contact, 0),
+			22: newEscalation(22, 2, contact, 5),
+		}},
+		3: {Escalations: map[int64]*rule.Escalation{31: newEscalation(31, 3, contact, 0)}},
+	}
+
+	i := NewIncident(nil, nil, runtimeConfig, zaptest.NewLogger(t).Sugar())
+	for _, id := range []int64{11, 21, 22} {
+		i.EscalationState[id] = &EscalationState{RuleEscalationID: id}
+	}
+	i.Recipients[recipient.ToKey(group)] = &RecipientState{Role: RoleRecipient}
+	i.Recipients[recipient.ToKey(contact)] = &RecipientState{Role: RoleRecipient}
+
+	ids := func(escalations []*rule.Escalation) []int64 {
+		var ids []int64
+		for _, e := range escalations {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	now := time.Now()
+	assert.Equal(t, []int64{11, 21}, ids(i.getContributingEscalations(contact, 3, now)))
+	assert.Equal(t, []int64{22}, ids(i.getContributingEscalations(contact, 5, now)))
+	assert.Empty(t, i.getContributingEscalations(contact, 7, now))
+}
@@ -0,0 +1,237 @@
+package incident
+
+import (
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/channel"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/msgtemplate"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+// notificationThrottle limits the notifications of each contact, shared by all incidents, see throttle.
+var notificationThrottle = &throttle{contacts: map[int64]*throttledContact{}}
+
+// throttle counts the notifications of each contact within a sliding window, collecting those exceeding the limit.
+type throttle struct {
+	contacts map[int64]*throttledContact
+	mu       sync.Mutex
+}
+
+// throttledContact holds the notifications of a single contact, see throttle.
+type throttledContact struct {
+	// sent holds the times of the notifications within the window, oldest first.
+	sent []time.Time
+	// throttled holds the summaries of the notifications exceeding the limit, not yet sent as a digest.
+	throttled []string
+}
+
+// throttleReservation is a notification counted by throttle.reserve but not yet settled, as the transaction
+// generating it might still be rolled back, see throttle.commit and throttle.cancel.
+type throttleReservation struct {
+	contactID int64
+	t         time.Time
+	window    time.Duration
+	summary   string
+	throttled bool
+}
+
+// reserve counts a notification of the contact at the given time, returning whether it must be throttled.
+//
+// A notification within the limit occupies its slot immediately, so that concurrently processed incidents can't exceed
+// the limit, and releases it again when cancelled. A notification exceeding the limit within the window before t is
+// only collected for the next digest once committed, see takeDigest. A non-positive limit disables throttling and
+// returns nil.
+func (th *throttle) reserve(
+	contactID int64, t time.Time, limit int, window time.Duration, summary string,
+) *throttleReservation {
+	if limit <= 0 {
+		return nil
+	}
+
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	c := th.contacts[contactID]
+	if c == nil {
+		c = &throttledContact{}
+		th.contacts[contactID] = c
+	}
+
+	expired := 0
+	for expired < len(c.sent) && t.Sub(c.sent[expired]) >= window {
+		expired++
+	}
+	c.sent = c.sent[expired:]
+
+	r := &throttleReservation{contactID: contactID, t: t, window: window, summary: summary}
+	if len(c.sent) < limit {
+		c.sent = append(c.sent, t)
+	} else {
+		r.throttled = true
+	}
+
+	return r
+}
+
+// commit settles a reservation after its transaction was committed. It returns whether this is the first collected
+// notification, i.e., the digest must be scheduled.
+func (th *throttle) commit(r *throttleReservation) bool {
+	if !r.throttled {
+		return false
+	}
+
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	c := th.contacts[r.contactID]
+	if c == nil {
+		c = &throttledContact{}
+		th.contacts[r.contactID] = c
+	}
+
+	c.throttled = append(c.throttled, r.summary)
+	return len(c.throttled) == 1
+}
+
+// cancel releases a reservation after its transaction was rolled back, as if it was never made.
+func (th *throttle) cancel(r *throttleReservation) {
+	if r.throttled {
+		return
+	}
+
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	c := th.contacts[r.contactID]
+	if c == nil {
+		return
+	}
+
+	for n, t := range c.sent {
+		if t.Equal(r.t) {
+			c.sent = append(c.sent[:n:n], c.sent[n+1:]...)
+			break
+		}
+	}
+	if len(c.sent) == 0 && len(c.throttled) == 0 {
+		delete(th.contacts, r.contactID)
+	}
+}
+
+// takeDigest returns and resets the summaries of the contact's throttled notifications.
+func (th *throttle) takeDigest(contactID int64) []string {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	c := th.contacts[contactID]
+	if c == nil {
+		return nil
+	}
+
+	throttled := c.throttled
+	c.throttled = nil
+	if len(c.sent) == 0 {
+		delete(th.contacts, contactID)
+	}
+
+	return throttled
+}
+
+// throttleNotification checks whether the contact may receive another notification about the given message, see
+// throttle.reserve and the daemon's throttling configuration.
+//
+// As this happens within a database transaction, the decision is only staged and must be settled by settleThrottle
+// once the transaction was either committed or rolled back.
+func (i *Incident) throttleNotification(contactID int64, t time.Time, message string) bool {
+	cfg := daemon.Config().Throttling
+	summary := fmt.Sprintf("%s: %s", i.Object.DisplayName(), message)
+
+	r := notificationThrottle.reserve(contactID, t, cfg.Notifications, cfg.Window, summary)
+	if r == nil {
+		return false
+	}
+
+	i.throttleReservations = append(i.throttleReservations, r)
+	return r.throttled
+}
+
+// settleThrottle commits or cancels the throttling decisions staged by throttleNotification, depending on whether their
+// transaction was committed. The first committed throttled notification of a contact schedules a digest of all
+// throttled ones after the window, see sendThrottledDigest.
+func (i *Incident) settleThrottle(committed bool) {
+	for _, r := range i.throttleReservations {
+		if !committed {
+			notificationThrottle.cancel(r)
+		} else if notificationThrottle.commit(r) {
+			runtimeConfig, logger, contactID, window := i.runtimeConfig, i.logger, r.contactID, r.window
+			time.AfterFunc(window, func() { sendThrottledDigest(runtimeConfig, logger, contactID, window) })
+		}
+	}
+
+	i.throttleReservations = nil
+}
+
+// sendThrottledDigest notifies the contact via its default channel about all notifications throttled since the last
+// digest, so that they are at least aware of them.
+//
+// The digest is rendered from the channel's default template, as it doesn't belong to a single incident and thus no
+// rule. Failing to send it is only logged, as the throttled notifications are recorded in the incident history anyway.
+func sendThrottledDigest(
+	runtimeConfig *config.RuntimeConfig, logger *zap.SugaredLogger, contactID int64, window time.Duration,
+) {
+	if !inflight.begin() {
+		logger.Debug("Not sending digest of throttled notifications while shutting down")
+		return
+	}
+	defer inflight.end()
+
+	throttled := notificationThrottle.takeDigest(contactID)
+	if len(throttled) == 0 {
+		return
+	}
+
+	runtimeConfig.RLock()
+	contact := runtimeConfig.Contacts[contactID]
+	var ch *channel.Channel
+	var tmpl *msgtemplate.Template
+	if contact != nil {
+		ch = runtimeConfig.Channels[contact.DefaultChannelID]
+		tmpl = runtimeConfig.GetNotificationTemplate(contact.DefaultChannelID, nil)
+	}
+	runtimeConfig.RUnlock()
+
+	if contact == nil || ch == nil {
+		logger.Warnw("Cannot send digest of throttled notifications to unknown contact or channel",
+			zap.Int64("contact_id", contactID), zap.Int("notifications", len(throttled)))
+		return
+	}
+
+	var message strings.Builder
+	_, _ = fmt.Fprintf(&message, "%d notifications within the last %v were throttled:\n", len(throttled), window)
+	for _, summary := range throttled {
+		_, _ = fmt.Fprintf(&message, "\n- %s", summary)
+	}
+
+	req := channel.NewDigestNotificationRequest(contact, daemon.Config().Icingaweb2URL, time.Now(), message.String())
+	data := msgtemplate.NewData(req, nil)
+	subject, body, err := tmpl.Render(data)
+	if err != nil && tmpl != nil {
+		subject, body, err = msgtemplate.RenderDefault(data)
+	}
+	if err != nil {
+		logger.Errorw("Failed to render digest of throttled notifications", zap.Error(err))
+		return
+	}
+	req.Subject, req.Message = subject, body
+
+	logger.Infow("Sending digest of throttled notifications", zap.String("contact", contact.FullName),
+		zap.Object("channel", ch), zap.Int("notifications", len(throttled)))
+	if _, err := ch.Notify(req); err != nil {
+		logger.Errorw("Failed to send digest of throttled notifications", zap.String("contact", contact.FullName),
+			zap.Object("channel", ch), zap.Error(err))
+	}
+}
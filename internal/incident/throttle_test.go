@@ -0,0 +1,78 @@
+package incident
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := 10 * time.Minute
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+
+		th := &throttle{contacts: map[int64]*throttledContact{}}
+		for n := 0; n < 10; n++ {
+			assert.Nil(t, th.reserve(1, start, 0, window, "ignored"))
+		}
+		assert.Empty(t, th.contacts)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		t.Parallel()
+
+		th := &throttle{contacts: map[int64]*throttledContact{}}
+		for n := 0; n < 2; n++ {
+			r := th.reserve(1, start.Add(time.Duration(n)*time.Minute), 2, window, "sent")
+			require.NotNil(t, r)
+			assert.False(t, r.throttled)
+			assert.False(t, th.commit(r))
+		}
+
+		r := th.reserve(1, start.Add(2*time.Minute), 2, window, "host-1: DOWN")
+		assert.True(t, r.throttled)
+		assert.True(t, th.commit(r), "first throttled notification must schedule the digest")
+
+		r = th.reserve(1, start.Add(3*time.Minute), 2, window, "host-2: DOWN")
+		assert.True(t, r.throttled)
+		assert.False(t, th.commit(r))
+
+		r = th.reserve(2, start.Add(3*time.Minute), 2, window, "other contact")
+		assert.False(t, r.throttled, "contacts must be throttled individually")
+
+		r = th.reserve(1, start.Add(window), 2, window, "host-3: DOWN")
+		assert.False(t, r.throttled, "notifications must leave the window")
+
+		assert.Equal(t, []string{"host-1: DOWN", "host-2: DOWN"}, th.takeDigest(1))
+		assert.Empty(t, th.takeDigest(1))
+		assert.Empty(t, th.takeDigest(3))
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		t.Parallel()
+
+		th := &throttle{contacts: map[int64]*throttledContact{}}
+		sent := th.reserve(1, start, 1, window, "host-1: DOWN")
+		assert.False(t, sent.throttled)
+
+		throttled := th.reserve(1, start, 1, window, "host-2: DOWN")
+		assert.True(t, throttled.throttled, "pending notifications must count towards the limit")
+
+		th.cancel(throttled)
+		th.cancel(sent)
+		assert.Empty(t, th.contacts, "cancelled notifications must not be counted")
+
+		for n := 0; n < 3; n++ {
+			// A retried transaction reserves the same notification again after cancelling the previous attempt.
+			r := th.reserve(1, start, 1, window, "host-1: DOWN")
+			assert.False(t, r.throttled, "retried notifications must not throttle themselves")
+			th.cancel(r)
+		}
+		assert.Empty(t, th.takeDigest(1), "cancelled throttled notifications must not be collected")
+	})
+}
@@ -0,0 +1,137 @@
+package incident
+
+import (
+	"context"
+	"errors"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"time"
+)
+
+// ErrIncidentClosed is returned when trying to watch an incident which was closed in the meantime.
+var ErrIncidentClosed = errors.New("incident is already closed")
+
+// Watch subscribes the contact to all subsequent updates of this incident reaching at least the given severity.
+//
+// Watching contacts are notified independently of any rules or escalations, using their default channel. Passing
+// event.SeverityNone as minSeverity subscribes to all updates. Watching an already watched incident again updates
+// the severity threshold.
+func (i *Incident) Watch(ctx context.Context, contact *recipient.Contact, minSeverity event.Severity) error {
+	i.Lock()
+	defer i.Unlock()
+
+	if !i.RecoveredAt.Time().IsZero() {
+		return ErrIncidentClosed
+	}
+
+	w := &WatchRow{
+		IncidentID:  i.Id,
+		ContactID:   contact.ID,
+		MinSeverity: minSeverity,
+		CreatedAt:   types.UnixMilli(time.Now()),
+	}
+
+	stmt, _ := i.db.BuildUpsertStmt(w)
+	if _, err := i.db.NamedExecContext(ctx, stmt, w); err != nil {
+		i.logger.Errorw("Failed to upsert incident watch", zap.String("contact", contact.String()), zap.Error(err))
+		return err
+	}
+
+	i.Watches[contact.ID] = w
+	i.logger.Infow("Contact started watching incident",
+		zap.String("contact", contact.String()), zap.Stringer("min_severity", &w.MinSeverity))
+
+	return nil
+}
+
+// Unwatch removes the contact's subscription of this incident, if any.
+func (i *Incident) Unwatch(ctx context.Context, contact *recipient.Contact) error {
+	i.Lock()
+	defer i.Unlock()
+
+	if _, ok := i.Watches[contact.ID]; !ok {
+		return nil
+	}
+
+	stmt := i.db.Rebind(`DELETE FROM "incident_watch" WHERE "incident_id" = ? AND "contact_id" = ?`)
+	if _, err := i.db.ExecContext(ctx, stmt, i.Id, contact.ID); err != nil {
+		i.logger.Errorw("Failed to delete incident watch", zap.String("contact", contact.String()), zap.Error(err))
+		return err
+	}
+
+	delete(i.Watches, contact.ID)
+	i.logger.Infow("Contact stopped watching incident", zap.String("contact", contact.String()))
+
+	return nil
+}
+
+// loadWatcherChannels adds the default channel of each watching contact whose severity threshold is reached.
+//
+// The threshold is checked against both the current and the previous severity. Thus, watchers are also informed when
+// the incident leaves their watched severity range, e.g., when it recovers.
+func (i *Incident) loadWatcherChannels(contactChs rule.ContactChannels, oldSeverity event.Severity) {
+	for contactID, w := range i.Watches {
+		if i.Severity < w.MinSeverity && oldSeverity < w.MinSeverity {
+			continue
+		}
+
+		contact := i.runtimeConfig.Contacts[contactID]
+		if contact == nil {
+			i.logger.Debugw("Incident watch refers unknown contact, might got deleted", zap.Int64("contact_id", contactID))
+			continue
+		}
+
+		if contactChs[contact] == nil {
+			contactChs[contact] = map[int64]bool{contact.DefaultChannelID: true}
+		}
+	}
+}
+
+// reload the recipients and watches of this incident from the database, see Invalidate.
+func (i *Incident) reload(ctx context.Context) error {
+	i.Lock()
+	defer i.Unlock()
+
+	// Incidents not yet written to the database have nothing to reload.
+	if i.StartedAt.Time().IsZero() {
+		return nil
+	}
+
+	if err := i.restoreRecipients(ctx); err != nil {
+		return err
+	}
+
+	var watches []*WatchRow
+	stmt := i.db.Rebind(i.db.BuildSelectStmt(new(WatchRow), new(WatchRow)) + ` WHERE "incident_id" = ?`)
+	if err := i.db.SelectContext(ctx, &watches, stmt, i.Id); err != nil {
+		i.logger.Errorw("Failed to reload incident watches from the database", zap.Error(err))
+		return err
+	}
+
+	i.Watches = make(map[int64]*WatchRow, len(watches))
+	for _, w := range watches {
+		i.Watches[w.ContactID] = w
+	}
+
+	return nil
+}
+
+// removeWatches deletes all watches of this incident, called when the incident is closed.
+func (i *Incident) removeWatches(ctx context.Context, tx *sqlx.Tx) error {
+	if len(i.Watches) == 0 {
+		return nil
+	}
+
+	stmt := tx.Rebind(`DELETE FROM "incident_watch" WHERE "incident_id" = ?`)
+	if _, err := tx.ExecContext(ctx, stmt, i.Id); err != nil {
+		return err
+	}
+
+	i.Watches = make(map[int64]*WatchRow)
+
+	return nil
+}
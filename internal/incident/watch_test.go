@@ -0,0 +1,80 @@
+package incident
+
+import (
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"testing"
+)
+
+func TestIncident_loadWatcherChannels(t *testing.T) {
+	t.Parallel()
+
+	contact := &recipient.Contact{FullName: "Jane Doe", DefaultChannelID: 3}
+	contact.ID = 1
+
+	runtimeConfig := &config.RuntimeConfig{}
+	runtimeConfig.Contacts = map[int64]*recipient.Contact{contact.ID: contact}
+
+	tests := []struct {
+		name        string
+		minSeverity event.Severity
+		oldSeverity event.Severity
+		severity    event.Severity
+		notified    bool
+	}{
+		{"AllUpdates", event.SeverityNone, event.SeverityWarning, event.SeverityErr, true},
+		{"BelowThreshold", event.SeverityCrit, event.SeverityWarning, event.SeverityErr, false},
+		{"ReachingThreshold", event.SeverityCrit, event.SeverityWarning, event.SeverityCrit, true},
+		{"AboveThreshold", event.SeverityCrit, event.SeverityCrit, event.SeverityEmerg, true},
+		{"LeavingThreshold", event.SeverityCrit, event.SeverityCrit, event.SeverityOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			i := NewIncident(nil, nil, runtimeConfig, zaptest.NewLogger(t).Sugar())
+			i.Severity = tt.severity
+			i.Watches[contact.ID] = &WatchRow{ContactID: contact.ID, MinSeverity: tt.minSeverity}
+
+			contactChs := make(rule.ContactChannels)
+			i.loadWatcherChannels(contactChs, tt.oldSeverity)
+
+			if tt.notified {
+				assert.Equal(t, rule.ContactChannels{contact: {3: true}}, contactChs)
+			} else {
+				assert.Empty(t, contactChs)
+			}
+		})
+	}
+
+	t.Run("KeepsEscalationChannels", func(t *testing.T) {
+		t.Parallel()
+
+		i := NewIncident(nil, nil, runtimeConfig, zaptest.NewLogger(t).Sugar())
+		i.Severity = event.SeverityCrit
+		i.Watches[contact.ID] = &WatchRow{ContactID: contact.ID}
+
+		contactChs := rule.ContactChannels{contact: {5: true}}
+		i.loadWatcherChannels(contactChs, event.SeverityCrit)
+
+		assert.Equal(t, rule.ContactChannels{contact: {5: true}}, contactChs)
+	})
+
+	t.Run("UnknownContact", func(t *testing.T) {
+		t.Parallel()
+
+		i := NewIncident(nil, nil, runtimeConfig, zaptest.NewLogger(t).Sugar())
+		i.Severity = event.SeverityCrit
+		i.Watches[42] = &WatchRow{ContactID: 42}
+
+		contactChs := make(rule.ContactChannels)
+		i.loadWatcherChannels(contactChs, event.SeverityCrit)
+
+		assert.Empty(t, contactChs)
+	})
+}
@@ -0,0 +1,224 @@
+// Package integrity detects orphaned rows, i.e., non-deleted rows referencing deleted ones.
+//
+// As the configuration is only soft-deleted, foreign keys cannot prevent those references. Orphaned rows may result in
+// notification black holes, e.g., an escalation recipient referencing a deleted contact silently notifies no one.
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/schema"
+	"go.uber.org/zap"
+	"time"
+)
+
+// check describes a single kind of orphaned rows.
+type check struct {
+	name  string
+	table string
+	// condition selects the orphaned rows of the table.
+	condition string
+	// repair is the SET clause of an UPDATE fixing the orphaned rows, or empty if they must be fixed manually.
+	repair string
+}
+
+// deletedIn returns a condition matching the column referencing a deleted row of the given table.
+func deletedIn(column, table string) string {
+	return fmt.Sprintf(`"%s" IN (SELECT "id" FROM "%s" WHERE "deleted" = 'y')`, column, table)
+}
+
+var checks = []check{
+	{
+		name:      "escalation_recipient_contact",
+		table:     "rule_escalation_recipient",
+		condition: deletedIn("contact_id", "contact"),
+		repair:    `"deleted" = 'y'`,
+	},
+	{
+		name:      "escalation_recipient_contactgroup",
+		table:     "rule_escalation_recipient",
+		condition: deletedIn("contactgroup_id", "contactgroup"),
+		repair:    `"deleted" = 'y'`,
+	},
+	{
+		name:      "escalation_recipient_schedule",
+		table:     "rule_escalation_recipient",
+		condition: deletedIn("schedule_id", "schedule"),
+		repair:    `"deleted" = 'y'`,
+	},
+	{
+		// Without an explicit channel, the contacts' default channels are used.
+		name:      "escalation_recipient_channel",
+		table:     "rule_escalation_recipient",
+		condition: deletedIn("channel_id", "channel"),
+		repair:    `"channel_id" = NULL`,
+	},
+	{
+		name:      "escalation_recipient_escalation",
+		table:     "rule_escalation_recipient",
+		condition: deletedIn("rule_escalation_id", "rule_escalation"),
+		repair:    `"deleted" = 'y'`,
+	},
+	{
+		name:      "escalation_rule",
+		table:     "rule_escalation",
+		condition: deletedIn("rule_id", "rule"),
+		repair:    `"deleted" = 'y', "position" = NULL`,
+	},
+	{
+		name:      "escalation_policy",
+		table:     "rule_escalation",
+		condition: deletedIn("escalation_policy_id", "escalation_policy"),
+		repair:    `"deleted" = 'y', "position" = NULL`,
+	},
+	{
+		// Without the policy, the rule only uses its own escalations.
+		name:      "rule_escalation_policy",
+		table:     "rule",
+		condition: deletedIn("escalation_policy_id", "escalation_policy"),
+		repair:    `"escalation_policy_id" = NULL`,
+	},
+	{
+		name:      "contact_address_contact",
+		table:     "contact_address",
+		condition: deletedIn("contact_id", "contact"),
+		repair:    `"deleted" = 'y'`,
+	},
+	{
+		name:      "contact_channel_preference",
+		table:     "contact_channel_preference",
+		condition: deletedIn("contact_id", "contact") + " OR " + deletedIn("channel_id", "channel"),
+		repair:    `"deleted" = 'y'`,
+	},
+	{
+		// Notifications of contacts out of office without a substitute are dropped, as if they were not a recipient.
+		// The derived table lets MySQL update the contact table while selecting from it.
+		name:  "contact_substitute",
+		table: "contact",
+		condition: `"substitute_contact_id" IN (SELECT "id" FROM ` +
+			`(SELECT "id" FROM "contact" WHERE "deleted" = 'y') AS "deleted_contact")`,
+		repair: `"substitute_contact_id" = NULL`,
+	},
+	{
+		name:      "contact_quiet_hours",
+		table:     "contact",
+		condition: deletedIn("quiet_hours_timeperiod_id", "timeperiod"),
+		repair:    `"quiet_hours_timeperiod_id" = NULL`,
+	},
+	{
+		name:      "contactgroup_member",
+		table:     "contactgroup_member",
+		condition: deletedIn("contact_id", "contact") + " OR " + deletedIn("contactgroup_id", "contactgroup"),
+		repair:    `"deleted" = 'y'`,
+	},
+	{
+		name:      "rotation_member_contact",
+		table:     "rotation_member",
+		condition: deletedIn("contact_id", "contact") + " OR " + deletedIn("contactgroup_id", "contactgroup"),
+		repair:    `"deleted" = 'y', "position" = NULL`,
+	},
+	{
+		// As the default channel is mandatory, it must be chosen manually.
+		name:      "contact_default_channel",
+		table:     "contact",
+		condition: deletedIn("default_channel_id", "channel"),
+	},
+}
+
+// Result of a single check, reporting the number of orphaned rows found and repaired.
+type Result struct {
+	Check    string `json:"check"`
+	Table    string `json:"table"`
+	Orphaned int64  `json:"orphaned"`
+	Repaired int64  `json:"repaired"`
+}
+
+// Run all checks once, repairing orphaned rows where possible if repair is set.
+//
+// Besides the configuration checks, current incidents of objects belonging to a deleted source are reported as well.
+// Those are never repaired, as no further events can arrive for them to be closed. Repairing is refused unless the
+// database schema is supported, see schema.RequireCompatible.
+func Run(ctx context.Context, db *database.DB, repair bool) ([]*Result, error) {
+	if repair {
+		if err := schema.RequireCompatible(); err != nil {
+			return nil, err
+		}
+	}
+
+	var results []*Result
+	for _, c := range checks {
+		condition := fmt.Sprintf(`"deleted" = 'n' AND (%s)`, c.condition)
+
+		result := &Result{Check: c.name, Table: c.table}
+		stmt := fmt.Sprintf(`SELECT COUNT(*) FROM "%s" WHERE %s`, c.table, condition)
+		if err := db.GetContext(ctx, &result.Orphaned, stmt); err != nil {
+			return nil, fmt.Errorf("cannot run integrity check %q: %w", c.name, err)
+		}
+
+		if repair && c.repair != "" && result.Orphaned > 0 {
+			stmt := db.Rebind(fmt.Sprintf(`UPDATE "%s" SET %s, "changed_at" = ? WHERE %s`, c.table, c.repair, condition))
+			res, err := db.ExecContext(ctx, stmt, types.UnixMilli(time.Now()))
+			if err != nil {
+				return nil, fmt.Errorf("cannot repair integrity check %q: %w", c.name, err)
+			}
+
+			if result.Repaired, err = res.RowsAffected(); err != nil {
+				return nil, err
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	result := &Result{Check: "incident_source", Table: "incident"}
+	stmt := `SELECT COUNT(*) FROM "incident" WHERE "recovered_at" IS NULL AND "object_id" IN (
+		SELECT "id" FROM "object" WHERE ` + deletedIn("source_id", "source") + `)`
+	if err := db.GetContext(ctx, &result.Orphaned, stmt); err != nil {
+		return nil, fmt.Errorf("cannot run integrity check %q: %w", result.Check, err)
+	}
+	results = append(results, result)
+
+	return results, nil
+}
+
+// Checker periodically runs all checks and logs their findings.
+type Checker struct {
+	DB       *database.DB
+	Logger   *logging.Logger
+	Interval time.Duration
+	Repair   bool
+}
+
+// Run the check loop until the context is done, starting with an immediate check.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		results, err := Run(ctx, c.DB, c.Repair)
+		if err != nil {
+			c.Logger.Errorw("Cannot check referential integrity", zap.Error(err))
+		}
+
+		for _, result := range results {
+			if result.Orphaned == 0 {
+				continue
+			}
+
+			c.Logger.Warnw("Found orphaned rows referencing deleted objects",
+				zap.String("check", result.Check),
+				zap.String("table", result.Table),
+				zap.Int64("orphaned", result.Orphaned),
+				zap.Int64("repaired", result.Repaired))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
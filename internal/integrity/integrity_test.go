@@ -0,0 +1,26 @@
+package integrity
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDeletedIn(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `"contact_id" IN (SELECT "id" FROM "contact" WHERE "deleted" = 'y')`, deletedIn("contact_id", "contact"))
+}
+
+func TestChecks(t *testing.T) {
+	t.Parallel()
+
+	names := make(map[string]struct{})
+	for _, c := range checks {
+		assert.NotEmpty(t, c.table, c.name)
+		assert.NotEmpty(t, c.condition, c.name)
+
+		_, duplicate := names[c.name]
+		assert.False(t, duplicate, "check %q is defined multiple times", c.name)
+		names[c.name] = struct{}{}
+	}
+}
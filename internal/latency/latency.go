@@ -0,0 +1,190 @@
+// Package latency measures the time from receiving an event until its first notification was handed to a channel
+// plugin, broken down by the processing phases, and checks it against a service level objective (SLO).
+//
+// A Trace is started when an event is received and passed along its processing via the context, see NewContext. Once
+// the first notification was sent, the Trace is recorded by a Recorder, aggregating the latencies of recent events.
+package latency
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Phase of the event processing, each measured separately.
+type Phase string
+
+const (
+	// PhaseDBSync covers writing the event, its object and the incident to the database.
+	PhaseDBSync Phase = "db_sync"
+	// PhaseRuleEval covers evaluating the object filters of the rules.
+	PhaseRuleEval Phase = "rule_eval"
+	// PhaseEscalationEval covers evaluating and triggering the escalations of the matching rules.
+	PhaseEscalationEval Phase = "escalation_eval"
+	// PhasePluginCall covers the calls of the channel plugins until the first notification was sent.
+	PhasePluginCall Phase = "plugin_call"
+)
+
+// Trace of the processing of a single event, safe for concurrent use.
+//
+// All methods may be called on a nil *Trace, doing nothing, so that events processed without a Trace need no checks.
+type Trace struct {
+	receivedAt time.Time
+
+	mu       sync.Mutex
+	phases   map[Phase]time.Duration
+	recorded bool
+}
+
+// NewTrace starts a Trace of an event received at the given time.
+func NewTrace(receivedAt time.Time) *Trace {
+	return &Trace{receivedAt: receivedAt, phases: make(map[Phase]time.Duration)}
+}
+
+// Add the duration to the phase. A phase may be added multiple times, e.g., when a transaction is retried.
+func (t *Trace) Add(phase Phase, d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.phases[phase] += d
+}
+
+// Track starts measuring the phase, being added once the returned function is called.
+func (t *Trace) Track(phase Phase) func() {
+	start := time.Now()
+	return func() { t.Add(phase, time.Since(start)) }
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying the Trace.
+func NewContext(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the Trace carried by the context or nil if there is none.
+func FromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(contextKey{}).(*Trace)
+	return t
+}
+
+// Sample is the recorded latency of a single event.
+type Sample struct {
+	Total  time.Duration
+	Phases map[Phase]time.Duration
+}
+
+// Stats describes the latencies recorded by a Recorder, as reported by the health endpoint.
+//
+// All durations are in seconds. The percentiles cover the latest events as counted by Window, while all other values
+// cover all events since the daemon was started.
+type Stats struct {
+	Count            uint64            `json:"count"`
+	SLO              float64           `json:"slo,omitempty"`
+	SLOExceededTotal uint64            `json:"slo_exceeded_total"`
+	Window           int               `json:"window"`
+	P50              float64           `json:"p50"`
+	P95              float64           `json:"p95"`
+	P99              float64           `json:"p99"`
+	Max              float64           `json:"max"`
+	PhasesAvg        map[Phase]float64 `json:"phases_avg"`
+}
+
+// Recorder aggregates the latencies of traced events, safe for concurrent use.
+type Recorder struct {
+	mu          sync.Mutex
+	window      []time.Duration
+	next        int
+	count       uint64
+	exceeded    uint64
+	max         time.Duration
+	phaseTotals map[Phase]time.Duration
+}
+
+// NewRecorder creates a Recorder calculating the percentiles over the given number of latest events.
+func NewRecorder(window int) *Recorder {
+	return &Recorder{window: make([]time.Duration, 0, window), phaseTotals: make(map[Phase]time.Duration)}
+}
+
+// Default is the Recorder of all events processed by this daemon, see incident.ProcessEvent.
+var Default = NewRecorder(1000)
+
+// Record the traced event's first notification, sent at the given time, and check it against the SLO if non-zero.
+//
+// It returns the recorded Sample and whether it exceeded the SLO. As only the first notification of an event is
+// relevant, nil is returned if the Trace was already recorded or is nil.
+func (r *Recorder) Record(t *Trace, now time.Time, slo time.Duration) (*Sample, bool) {
+	if t == nil {
+		return nil, false
+	}
+
+	t.mu.Lock()
+	if t.recorded {
+		t.mu.Unlock()
+		return nil, false
+	}
+	t.recorded = true
+	sample := &Sample{Total: now.Sub(t.receivedAt), Phases: make(map[Phase]time.Duration, len(t.phases))}
+	for phase, d := range t.phases {
+		sample.Phases[phase] = d
+	}
+	t.mu.Unlock()
+
+	exceeded := slo > 0 && sample.Total > slo
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.window) < cap(r.window) {
+		r.window = append(r.window, sample.Total)
+	} else {
+		r.window[r.next] = sample.Total
+		r.next = (r.next + 1) % len(r.window)
+	}
+
+	r.count++
+	if exceeded {
+		r.exceeded++
+	}
+	r.max = max(r.max, sample.Total)
+	for phase, d := range sample.Phases {
+		r.phaseTotals[phase] += d
+	}
+
+	return sample, exceeded
+}
+
+// Stats returns the aggregated latencies together with the SLO they were checked against.
+func (r *Recorder) Stats(slo time.Duration) Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := Stats{
+		Count:            r.count,
+		SLO:              slo.Seconds(),
+		SLOExceededTotal: r.exceeded,
+		Window:           len(r.window),
+		Max:              r.max.Seconds(),
+		PhasesAvg:        make(map[Phase]float64, len(r.phaseTotals)),
+	}
+	for phase, d := range r.phaseTotals {
+		stats.PhasesAvg[phase] = d.Seconds() / float64(r.count)
+	}
+
+	if len(r.window) > 0 {
+		sorted := slices.Clone(r.window)
+		slices.Sort(sorted)
+
+		percentile := func(p int) float64 {
+			return sorted[(len(sorted)*p+99)/100-1].Seconds()
+		}
+		stats.P50, stats.P95, stats.P99 = percentile(50), percentile(95), percentile(99)
+	}
+
+	return stats
+}
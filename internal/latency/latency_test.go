@@ -0,0 +1,63 @@
+package latency
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	received := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRecorder(3)
+
+	for n, total := range []time.Duration{4 * time.Second, time.Second, 2 * time.Second, 3 * time.Second} {
+		trace := NewTrace(received)
+		trace.Add(PhaseDBSync, 100*time.Millisecond)
+		trace.Add(PhaseDBSync, 100*time.Millisecond)
+		trace.Add(PhasePluginCall, time.Duration(n)*time.Second)
+
+		sample, exceeded := r.Record(trace, received.Add(total), 3*time.Second)
+		require.NotNil(t, sample)
+		assert.Equal(t, total, sample.Total)
+		assert.Equal(t, 200*time.Millisecond, sample.Phases[PhaseDBSync])
+		assert.Equal(t, total > 3*time.Second, exceeded)
+
+		sample, _ = r.Record(trace, received.Add(time.Hour), 3*time.Second)
+		assert.Nil(t, sample, "only the first notification should be recorded")
+	}
+
+	assert.Equal(t, Stats{
+		Count:            4,
+		SLO:              3,
+		SLOExceededTotal: 1,
+		Window:           3,
+		P50:              2,
+		P95:              3,
+		P99:              3,
+		Max:              4,
+		PhasesAvg:        map[Phase]float64{PhaseDBSync: 0.2, PhasePluginCall: 1.5},
+	}, r.Stats(3*time.Second))
+}
+
+func TestTrace_Nil(t *testing.T) {
+	t.Parallel()
+
+	trace := FromContext(context.Background())
+	require.Nil(t, trace)
+
+	assert.NotPanics(t, func() {
+		trace.Track(PhaseRuleEval)()
+		trace.Add(PhaseRuleEval, time.Second)
+	})
+
+	sample, exceeded := NewRecorder(1).Record(trace, time.Now(), time.Second)
+	assert.Nil(t, sample)
+	assert.False(t, exceeded)
+
+	trace = NewTrace(time.Now())
+	assert.Same(t, trace, FromContext(NewContext(context.Background(), trace)))
+}
@@ -0,0 +1,197 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/acklink"
+	"github.com/icinga/icinga-notifications/internal/audit"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/incident"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"go.uber.org/zap"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// ackLinkPage is rendered for all requests to the AcknowledgeLink endpoint, either asking for confirmation or showing
+// the result. The link is only redeemed by submitting the form, as mail scanners may follow links in notifications.
+var ackLinkPage = template.Must(template.New("acknowledge").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Icinga Notifications</title></head>
+<body>
+<p>{{.Message}}</p>
+{{- if .Token}}
+<form method="post">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Acknowledge incident #{{.IncidentID}}</button>
+</form>
+{{- end}}
+</body>
+</html>
+`))
+
+// AcknowledgeLink handles the signed links embedded in notifications, see acklink.Signer.
+//
+// A GET request asks for confirmation, while a POST request redeems the link and acknowledges the incident on behalf of
+// the contact the link was sent to, making them a manager. No further authentication is required.
+func (l *Listener) AcknowledgeLink(w http.ResponseWriter, r *http.Request) {
+	render := func(statusCode int, message string, claims *acklink.Claims, token string) {
+		data := struct {
+			Message    string
+			Token      string
+			IncidentID int64
+		}{Message: message, Token: token}
+		if claims != nil {
+			data.IncidentID = claims.IncidentID
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(statusCode)
+		_ = ackLinkPage.Execute(w, data)
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = fmt.Fprintln(w, "GET or POST required")
+		return
+	}
+
+	token := r.FormValue("token")
+	link, statusCode, message := l.verifyAckLink(token)
+	if link == nil {
+		render(statusCode, message, nil, "")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		message := fmt.Sprintf("Acknowledge incident #%d of %s as %s?",
+			link.claims.IncidentID, link.incident.IncidentObject().DisplayName(), link.contact.FullName)
+		render(http.StatusOK, message, link.claims, token)
+		return
+	}
+
+	statusCode, message = l.redeemAckLink(w, r, link, "Acknowledged via link in notification")
+	render(statusCode, message, nil, "")
+}
+
+// voiceResponse is the TwiML document answering the AcknowledgeVoice endpoint, read out to the callee.
+var voiceResponse = template.Must(template.New("voice").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<Response><Say>{{.}}</Say></Response>
+`))
+
+// AcknowledgeVoice handles the keypad input of voice calls placed by channel plugins, e.g., Twilio.
+//
+// The plugin gathers a single digit and posts it as "Digits" to the acklink.VoicePath of the notification's
+// acknowledgement link, including its token. Pressing 1 redeems the link just like AcknowledgeLink does, while any other
+// input leaves the incident unacknowledged. The result is returned as TwiML to be read out to the callee, thus always
+// with status 200, as the call would end with a generic error message otherwise.
+func (l *Listener) AcknowledgeVoice(w http.ResponseWriter, r *http.Request) {
+	say := func(message string) {
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		_ = voiceResponse.Execute(w, message)
+	}
+
+	if r.FormValue("Digits") != "1" {
+		say("The incident was not acknowledged. Goodbye.")
+		return
+	}
+
+	link, _, message := l.verifyAckLink(r.FormValue("token"))
+	if link == nil {
+		say(message)
+		return
+	}
+
+	_, message = l.redeemAckLink(w, r, link, "Acknowledged via voice call")
+	say(message)
+}
+
+// verifiedAckLink is a valid acknowledgement link of an existing contact for an open incident, see verifyAckLink.
+type verifiedAckLink struct {
+	claims   *acklink.Claims
+	contact  *recipient.Contact
+	incident *incident.Incident
+}
+
+// verifyAckLink checks the token of an acknowledgement link, returning nil together with the status code and a message
+// to be shown to the user if it cannot be used.
+func (l *Listener) verifyAckLink(token string) (*verifiedAckLink, int, string) {
+	signer, err := acklink.NewSigner(&daemon.Config().AckLinks)
+	if err != nil || signer == nil {
+		return nil, http.StatusNotFound, "Acknowledgement links are disabled."
+	}
+
+	claims, err := signer.Verify(token, time.Now())
+	if errors.Is(err, acklink.ErrExpiredLink) {
+		return nil, http.StatusGone, "This link has expired."
+	} else if err != nil {
+		l.logger.Warnw("Received an invalid acknowledgement link", zap.Error(err))
+		return nil, http.StatusBadRequest, "This link is invalid."
+	}
+
+	i := incident.GetCurrentByID(claims.IncidentID)
+
+	l.runtimeConfig.RLock()
+	var contact *recipient.Contact
+	if c := l.runtimeConfig.Contacts[claims.ContactID]; c != nil && c.Username.Valid {
+		contact = c
+		// The contact may have been moved to another tenant since the link was sent, see GetTenantContact.
+		if i != nil {
+			contact = l.runtimeConfig.GetTenantContact(l.runtimeConfig.GetSourceTenant(i.Object.SourceID),
+				c.Username.String)
+		}
+	}
+	l.runtimeConfig.RUnlock()
+
+	switch {
+	case contact == nil:
+		return nil, http.StatusNotFound, "The contact this link was sent to no longer exists."
+	case i == nil:
+		return nil, http.StatusConflict, fmt.Sprintf("Incident #%d is already closed.", claims.IncidentID)
+	}
+
+	return &verifiedAckLink{claims: claims, contact: contact, incident: i}, http.StatusOK, ""
+}
+
+// redeemAckLink redeems the verified link and acknowledges its incident with the given comment on behalf of the
+// contact, returning the status code and a message to be shown to the user.
+func (l *Listener) redeemAckLink(
+	w http.ResponseWriter, r *http.Request, link *verifiedAckLink, comment string,
+) (int, string) {
+	claims, contact := link.claims, link.contact
+
+	if err := acklink.Redeem(r.Context(), l.db, claims); errors.Is(err, acklink.ErrLinkUsed) {
+		return http.StatusConflict, "This link has already been used."
+	} else if err != nil {
+		l.logger.Errorw("Cannot redeem acknowledgement link", zap.Int64("incident_id", claims.IncidentID),
+			zap.Int64("contact_id", claims.ContactID), zap.Error(err))
+		return errorStatusCode(w, err, http.StatusInternalServerError),
+			"The link could not be redeemed, please try again later."
+	}
+
+	err := link.incident.Acknowledge(r.Context(), contact.Username.String, comment)
+	if err != nil {
+		// The incident wasn't acknowledged, thus allow the link to be used again, e.g., when trying again later.
+		if err := acklink.Release(context.WithoutCancel(r.Context()), l.db, claims); err != nil {
+			l.logger.Errorw("Cannot release acknowledgement link", zap.Int64("incident_id", claims.IncidentID),
+				zap.Int64("contact_id", claims.ContactID), zap.Error(err))
+		}
+	}
+	if errors.Is(err, incident.ErrIncidentClosed) {
+		return http.StatusConflict, fmt.Sprintf("Incident #%d is already closed.", claims.IncidentID)
+	} else if err != nil {
+		l.logger.Errorw("Cannot acknowledge incident via link", zap.Int64("incident_id", claims.IncidentID),
+			zap.Int64("contact_id", claims.ContactID), zap.Error(err))
+		return errorStatusCode(w, err, http.StatusInternalServerError),
+			"The incident could not be acknowledged, please try again later."
+	}
+
+	l.logger.Infow("Acknowledged incident via link", zap.Int64("incident_id", claims.IncidentID),
+		zap.String("contact", contact.Username.String))
+	l.recordAudit(r, audit.ActionAcknowledge, audit.ContactActor(contact.Username.String),
+		audit.Target("incident", claims.IncidentID), nil, map[string]string{"comment": comment})
+
+	return http.StatusOK, fmt.Sprintf("Incident #%d has been acknowledged by %s.", claims.IncidentID, contact.FullName)
+}
@@ -0,0 +1,501 @@
+package listener
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/audit"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/incident"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/report"
+	"go.uber.org/zap"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// registerApi registers the handlers of the versioned HTTP API.
+//
+// In contrast to the other endpoints, all of them are authenticated by a bearer token, see config.ApiToken, and both
+// their requests and responses are JSON encoded, including errors. This allows external tools, e.g., chatbots, to
+// interact with incidents without access to the database. Reading requires the token to be granted the read_incidents
+// capability, while all changes require manage_incidents. Tokens of a tenant only access the incidents and contacts
+// visible to their tenant, treating all others as unknown, see config.ApiToken.
+func (l *Listener) registerApi() {
+	read, manage := config.ApiCapabilityReadIncidents, config.ApiCapabilityManageIncidents
+
+	l.mux.HandleFunc("GET /v1/incidents", l.apiHandler(read, l.apiListIncidents))
+	l.mux.HandleFunc("GET /v1/incidents/{id}", l.apiHandler(read, l.apiGetIncident))
+	l.mux.HandleFunc("GET /v1/incidents/{id}/history", l.apiHandler(read, l.apiIncidentHistory))
+	l.mux.HandleFunc("GET /v1/incidents/{id}/export", l.apiExportIncident)
+	l.mux.HandleFunc("GET /v1/objects/{id}/history", l.apiHandler(read, l.apiObjectHistory))
+	l.mux.HandleFunc("POST /v1/incidents/{id}/acknowledge", l.apiHandler(manage, l.apiAcknowledgeIncident))
+	l.mux.HandleFunc("POST /v1/incidents/{id}/close", l.apiHandler(manage, l.apiCloseIncident))
+	l.mux.HandleFunc("POST /v1/incidents/{id}/subscribe", l.apiHandler(manage, l.apiSubscribeIncident))
+	l.mux.HandleFunc("DELETE /v1/incidents/{id}/subscribe", l.apiHandler(manage, l.apiSubscribeIncident))
+	l.mux.HandleFunc("GET /v1/contacts/{username}/opt-outs", l.apiHandler(read, l.apiListOptOuts))
+	l.mux.HandleFunc("POST /v1/contacts/{username}/opt-outs", l.apiHandler(manage, l.apiCreateOptOut))
+	l.mux.HandleFunc("DELETE /v1/contacts/{username}/opt-outs/{id}", l.apiHandler(manage, l.apiEndOptOut))
+	l.mux.HandleFunc("GET /v1/opt-out-gaps", l.apiHandler(read, l.apiOptOutGaps))
+	l.mux.HandleFunc("POST /v1/filters/validate", l.apiHandler(read, l.apiValidateFilter))
+	l.mux.HandleFunc("GET /v1/tags", l.apiHandler(read, l.apiListTags))
+	l.mux.HandleFunc("GET /v1/channels", l.apiHandler(read, l.apiListChannels))
+	l.mux.HandleFunc("GET /v1/channel-types", l.apiHandler(read, l.apiListChannelTypes))
+	l.mux.HandleFunc("POST /v1/channels/{id}/test", l.apiHandler(manage, l.apiTestChannel))
+	l.mux.HandleFunc("GET /v1/schedules/{id}/simulation", l.apiHandler(read, l.apiSimulateSchedule))
+	l.mux.HandleFunc("GET /v1/timeperiods/{id}/simulation", l.apiHandler(read, l.apiSimulateTimePeriod))
+	l.mux.HandleFunc("GET /v1/audit-log", l.apiHandler(manage, l.apiListAuditLog))
+}
+
+// apiError is returned by the API handlers to send an error response with the given status code.
+type apiError struct {
+	statusCode int
+	message    string
+}
+
+// Error implements the error interface.
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// newApiError creates an apiError with a formatted message.
+func newApiError(statusCode int, format string, a ...any) *apiError {
+	return &apiError{statusCode: statusCode, message: fmt.Sprintf(format, a...)}
+}
+
+// apiHandler authenticates the request by its bearer token, which must be granted the capability, and sends the
+// handler's result as JSON.
+//
+// An error returned by the handler is sent as a JSON object with an "error" message. Unless it is an apiError, its
+// details are only logged and the status code is derived from it, see errorStatusCode.
+func (l *Listener) apiHandler(
+	capability config.ApiCapability, handler func(*http.Request, *config.ApiToken) (any, error),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		apiToken := l.apiAuthenticate(w, req, capability)
+		if apiToken == nil {
+			return
+		}
+
+		result, err := handler(req, apiToken)
+		if err != nil {
+			l.writeApiError(w, req, apiToken, err)
+			return
+		}
+
+		writeApiResponse(w, http.StatusOK, result)
+	}
+}
+
+// apiAuthenticate returns the API token of the request's bearer token if it was granted the capability. Otherwise, an
+// error response is sent and nil is returned.
+func (l *Listener) apiAuthenticate(
+	w http.ResponseWriter, req *http.Request, capability config.ApiCapability,
+) *config.ApiToken {
+	apiToken, err := l.checkApiToken(w, req, capability)
+	if err != nil {
+		writeApiResponse(w, err.statusCode, map[string]string{"error": err.message})
+		return nil
+	}
+
+	return apiToken
+}
+
+// checkApiToken returns the API token of the request's bearer token if it was granted the capability, logging each
+// use of a token. Otherwise, an apiError with either the 401 or the 403 status code is returned.
+func (l *Listener) checkApiToken(
+	w http.ResponseWriter, req *http.Request, capability config.ApiCapability,
+) (*config.ApiToken, *apiError) {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	apiToken := l.runtimeConfig.GetApiToken(strings.TrimSpace(token))
+	if !ok || apiToken == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="icinga-notifications"`)
+		return nil, newApiError(http.StatusUnauthorized, "valid bearer token required")
+	}
+
+	logger := l.logger.With(zap.Object("api_token", apiToken), zap.String("capability", string(capability)),
+		zap.String("method", req.Method), zap.String("path", req.URL.Path), zap.String("remote_addr", req.RemoteAddr))
+	if !apiToken.Allows(capability) {
+		logger.Warnw("Rejecting request of an API token lacking the required capability")
+		return nil, newApiError(http.StatusForbidden, "API token lacks the %s capability", capability)
+	}
+
+	logger.Debugw("Authenticated request by API token")
+	return apiToken, nil
+}
+
+// authenticateSource returns the source on whose behalf the request was sent. Otherwise, an apiError with either the
+// 401 or the 403 status code is returned.
+//
+// The source is either authenticated by a bearer token bound to it and granted the capability, see config.ApiToken, or
+// by its listener password via HTTP Basic Authentication or by its client certificate via mutual TLS, see
+// config.Source.VerifyClientCertificate. The latter two imply all capabilities of the source's endpoints.
+//
+// A client certificate not accepted by any source is ignored, allowing the request to be authenticated otherwise.
+func (l *Listener) authenticateSource(
+	w http.ResponseWriter, req *http.Request, capability config.ApiCapability,
+) (*config.Source, *apiError) {
+	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		if source := l.runtimeConfig.GetSourceFromClientCertificate(req.TLS.PeerCertificates, l.logger); source != nil {
+			return source, nil
+		}
+	}
+
+	if strings.HasPrefix(req.Header.Get("Authorization"), "Bearer ") {
+		apiToken, err := l.checkApiToken(w, req, capability)
+		if err != nil {
+			return nil, err
+		}
+
+		source := l.runtimeConfig.GetApiTokenSource(apiToken)
+		if source == nil {
+			l.logger.Warnw("Rejecting request of an API token not bound to a source", zap.Object("api_token", apiToken),
+				zap.String("method", req.Method), zap.String("path", req.URL.Path))
+			return nil, newApiError(http.StatusForbidden, "API token is not bound to a source")
+		}
+
+		return source, nil
+	}
+
+	var source *config.Source
+	if authUser, authPass, authOk := req.BasicAuth(); authOk {
+		source = l.runtimeConfig.GetSourceFromCredentials(authUser, authPass, l.logger)
+	}
+	if source == nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="icinga-notifications"`)
+		return nil, newApiError(http.StatusUnauthorized, "HTTP authorization required")
+	}
+
+	return source, nil
+}
+
+// writeApiError sends the error as a JSON object with an "error" message, see apiHandler.
+func (l *Listener) writeApiError(w http.ResponseWriter, req *http.Request, apiToken *config.ApiToken, err error) {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		l.logger.Errorw("Cannot handle API request", zap.String("method", req.Method),
+			zap.String("url", req.URL.String()), zap.Object("api_token", apiToken), zap.Error(err))
+		apiErr = newApiError(errorStatusCode(w, err, http.StatusInternalServerError),
+			"request could not be handled, see server logs for details")
+	}
+
+	writeApiResponse(w, apiErr.statusCode, map[string]string{"error": apiErr.message})
+}
+
+// writeApiResponse sends the given value as JSON with the given status code.
+func writeApiResponse(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// apiTokenSourceIDs returns the IDs of the sources whose incidents the API token may access, or nil for all sources.
+func (l *Listener) apiTokenSourceIDs(apiToken *config.ApiToken) []int64 {
+	tenantID := l.runtimeConfig.GetApiTokenTenant(apiToken)
+
+	l.runtimeConfig.RLock()
+	defer l.runtimeConfig.RUnlock()
+
+	return l.runtimeConfig.GetTenantSourceIDs(tenantID)
+}
+
+// apiTokenSeesSource returns whether the API token may access the incidents of the source, see apiTokenSourceIDs.
+func (l *Listener) apiTokenSeesSource(apiToken *config.ApiToken, sourceID int64) bool {
+	sourceIDs := l.apiTokenSourceIDs(apiToken)
+	return sourceIDs == nil || slices.Contains(sourceIDs, sourceID)
+}
+
+// apiTokenContact returns the contact of the given username if it is visible to the API token's tenant, or nil.
+func (l *Listener) apiTokenContact(apiToken *config.ApiToken, username string) *recipient.Contact {
+	tenantID := l.runtimeConfig.GetApiTokenTenant(apiToken)
+
+	l.runtimeConfig.RLock()
+	defer l.runtimeConfig.RUnlock()
+
+	return l.runtimeConfig.GetTenantContact(tenantID, username)
+}
+
+// apiListIncidents lists the incidents matching the query parameters, see incident.ParseListFilter.
+func (l *Listener) apiListIncidents(req *http.Request, apiToken *config.ApiToken) (any, error) {
+	f, err := incident.ParseListFilter(req.URL.Query())
+	if err != nil {
+		return nil, newApiError(http.StatusBadRequest, "%v", err)
+	}
+	f.SourceIDs = l.apiTokenSourceIDs(apiToken)
+
+	return incident.List(req.Context(), l.db, f)
+}
+
+// apiGetIncident returns a single incident, regardless of whether it is open or closed.
+func (l *Listener) apiGetIncident(req *http.Request, apiToken *config.ApiToken) (any, error) {
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil, newApiError(http.StatusBadRequest, "incident ID must be an integer, got %q", req.PathValue("id"))
+	}
+
+	summary, err := incident.GetSummary(req.Context(), l.db, id)
+	if err == nil && !l.apiTokenSeesSource(apiToken, summary.SourceID) {
+		err = fmt.Errorf("%w: %d", incident.ErrIncidentNotFound, id)
+	}
+	if errors.Is(err, incident.ErrIncidentNotFound) {
+		return nil, newApiError(http.StatusNotFound, "%v", err)
+	}
+
+	return summary, err
+}
+
+// apiIncidentHistory returns a page of the history of an incident, see incident.ParseHistoryFilter, merged with the
+// archive if necessary, see incident.ListIncidentHistory.
+func (l *Listener) apiIncidentHistory(req *http.Request, apiToken *config.ApiToken) (any, error) {
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil, newApiError(http.StatusBadRequest, "incident ID must be an integer, got %q", req.PathValue("id"))
+	}
+
+	f, err := incident.ParseHistoryFilter(req.URL.Query())
+	if err != nil {
+		return nil, newApiError(http.StatusBadRequest, "%v", err)
+	}
+	f.IncidentID = id
+	f.SourceIDs = l.apiTokenSourceIDs(apiToken)
+
+	start := time.Now()
+	page, err := incident.ListIncidentHistory(req.Context(), l.db, l.archive, f)
+	if errors.Is(err, incident.ErrIncidentNotFound) {
+		return nil, newApiError(http.StatusNotFound, "%v", err)
+	}
+	if page != nil && page.Warning != "" {
+		l.logger.Infow("Merged incident history with the archive", zap.Int64("incident", id),
+			zap.Duration("took", time.Since(start)))
+	}
+
+	return page, err
+}
+
+// apiObjectHistory returns a page of the history of all incidents of an object, see incident.ParseHistoryFilter.
+func (l *Listener) apiObjectHistory(req *http.Request, apiToken *config.ApiToken) (any, error) {
+	var objectID types.Binary
+	if err := objectID.UnmarshalText([]byte(req.PathValue("id"))); err != nil || !objectID.Valid() {
+		return nil, newApiError(http.StatusBadRequest, "object ID must be hex-encoded, got %q", req.PathValue("id"))
+	}
+
+	f, err := incident.ParseHistoryFilter(req.URL.Query())
+	if err != nil {
+		return nil, newApiError(http.StatusBadRequest, "%v", err)
+	}
+	f.ObjectID = objectID
+	f.SourceIDs = l.apiTokenSourceIDs(apiToken)
+
+	return l.listApiHistory(req, f)
+}
+
+// listApiHistory lists the history of the given filter, mapping an unknown incident or object to a 404 status code.
+func (l *Listener) listApiHistory(req *http.Request, f *incident.HistoryFilter) (any, error) {
+	page, err := incident.ListHistory(req.Context(), l.db, f)
+	if errors.Is(err, incident.ErrIncidentNotFound) || errors.Is(err, incident.ErrObjectNotFound) {
+		return nil, newApiError(http.StatusNotFound, "%v", err)
+	}
+
+	return page, err
+}
+
+// apiExportIncident sends the report of an incident in the format of the "format" query parameter, either "csv" or
+// "pdf", as an attachment, see report.Report.
+//
+// In contrast to the other handlers, only errors are sent as JSON, thus it is not wrapped by apiHandler.
+func (l *Listener) apiExportIncident(w http.ResponseWriter, req *http.Request) {
+	apiToken := l.apiAuthenticate(w, req, config.ApiCapabilityReadIncidents)
+	if apiToken == nil {
+		return
+	}
+
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		l.writeApiError(w, req, apiToken,
+			newApiError(http.StatusBadRequest, "incident ID must be an integer, got %q", req.PathValue("id")))
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	contentType, ok := report.Formats[format]
+	if !ok {
+		l.writeApiError(w, req, apiToken,
+			newApiError(http.StatusBadRequest, "format must be either csv or pdf, got %q", format))
+		return
+	}
+
+	start := time.Now()
+	r, err := report.Load(req.Context(), l.db, l.archive, id)
+	if err == nil && !l.apiTokenSeesSource(apiToken, r.Incident.SourceID) {
+		err = fmt.Errorf("%w: %d", incident.ErrIncidentNotFound, id)
+	}
+	if errors.Is(err, incident.ErrIncidentNotFound) {
+		err = newApiError(http.StatusNotFound, "%v", err)
+	}
+	if err != nil {
+		l.writeApiError(w, req, apiToken, err)
+		return
+	}
+	if r.Archived {
+		l.logger.Infow("Merged incident report with the archive", zap.Int64("incident", id),
+			zap.Duration("took", time.Since(start)))
+		w.Header().Set("Warning", fmt.Sprintf("299 - %q", incident.ArchiveWarning))
+	}
+
+	// Render into a buffer first, allowing to still send an error response.
+	var buf bytes.Buffer
+	if err := r.Render(&buf, format); err != nil {
+		l.writeApiError(w, req, apiToken, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="incident-%d.%s"`, id, format))
+	_, _ = buf.WriteTo(w)
+}
+
+// apiAcknowledgeIncident acknowledges an open incident on behalf of the contact given in the request body.
+func (l *Listener) apiAcknowledgeIncident(req *http.Request, apiToken *config.ApiToken) (any, error) {
+	var body struct {
+		Username string `json:"username"`
+		Comment  string `json:"comment"`
+	}
+	i, contact, err := l.parseApiIncidentAction(req, apiToken, &body, &body.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := body.Comment
+	if comment == "" {
+		comment = fmt.Sprintf("Acknowledged via API token %q", apiToken.Name)
+	}
+
+	if err := i.Acknowledge(req.Context(), contact.Username.String, comment); errors.Is(err, incident.ErrIncidentClosed) {
+		return nil, newApiError(http.StatusConflict, "%v", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	l.recordAudit(req, audit.ActionAcknowledge, audit.ApiTokenActor(apiToken.Name), audit.Target("incident", i.ID()),
+		nil, map[string]string{"contact": contact.Username.String, "comment": comment})
+
+	message := fmt.Sprintf("incident %d acknowledged by %q", i.ID(), contact.Username.String)
+	return map[string]string{"message": message}, nil
+}
+
+// apiCloseIncident closes an open incident manually, see incident.Incident.Close.
+func (l *Listener) apiCloseIncident(req *http.Request, apiToken *config.ApiToken) (any, error) {
+	var body struct {
+		Comment string `json:"comment"`
+	}
+	i, _, err := l.parseApiIncidentAction(req, apiToken, &body, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := fmt.Sprintf("via API token %q", apiToken.Name)
+	if body.Comment != "" {
+		reason = fmt.Sprintf("%s (%s)", body.Comment, reason)
+	}
+
+	if err := i.Close(req.Context(), reason); errors.Is(err, incident.ErrIncidentClosed) {
+		return nil, newApiError(http.StatusConflict, "%v", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	l.recordAudit(req, audit.ActionClose, audit.ApiTokenActor(apiToken.Name), audit.Target("incident", i.ID()),
+		nil, map[string]string{"reason": reason})
+
+	return map[string]string{"message": fmt.Sprintf("incident %d closed", i.ID())}, nil
+}
+
+// apiSubscribeIncident lets the contact given in the request body watch an open incident, or stop watching it for a
+// DELETE request, see incident.Incident.Watch.
+func (l *Listener) apiSubscribeIncident(req *http.Request, apiToken *config.ApiToken) (any, error) {
+	var body struct {
+		Username    string         `json:"username"`
+		MinSeverity event.Severity `json:"min_severity"`
+	}
+	i, contact, err := l.parseApiIncidentAction(req, apiToken, &body, &body.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	var message, action string
+	var before, after any
+	if req.Method == http.MethodDelete {
+		err = i.Unwatch(req.Context(), contact)
+		message = fmt.Sprintf("contact %q unsubscribed from incident %d", contact.Username.String, i.ID())
+		action, before = audit.ActionUnsubscribe, map[string]string{"contact": contact.Username.String}
+	} else {
+		err = i.Watch(req.Context(), contact, body.MinSeverity)
+		message = fmt.Sprintf("contact %q subscribed to incident %d", contact.Username.String, i.ID())
+		action = audit.ActionSubscribe
+		after = map[string]string{"contact": contact.Username.String, "min_severity": body.MinSeverity.String()}
+	}
+	if errors.Is(err, incident.ErrIncidentClosed) {
+		return nil, newApiError(http.StatusConflict, "%v", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	l.recordAudit(req, action, audit.ApiTokenActor(apiToken.Name), audit.Target("incident", i.ID()), before, after)
+
+	return map[string]string{"message": message}, nil
+}
+
+// parseApiIncidentAction decodes the JSON request body into body and returns the current incident of the request path.
+//
+// If username is not nil, it must point to a field of the body referencing a contact by its username, which is then
+// returned as well. An empty body is allowed for actions not requiring a contact. Both the incident and the contact
+// must be visible to the API token's tenant, and the contact to the incident's tenant as well.
+func (l *Listener) parseApiIncidentAction(
+	req *http.Request, apiToken *config.ApiToken, body any, username *string,
+) (*incident.Incident, *recipient.Contact, error) {
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil, nil, newApiError(http.StatusBadRequest, "incident ID must be an integer, got %q", req.PathValue("id"))
+	}
+
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(body); err != nil {
+			return nil, nil, newApiError(http.StatusBadRequest, "cannot parse JSON body: %v", err)
+		}
+	}
+
+	if username != nil && *username == "" {
+		return nil, nil, newApiError(http.StatusBadRequest, "username must be set")
+	}
+
+	i := incident.GetCurrentByID(id)
+	if i == nil || !l.apiTokenSeesSource(apiToken, i.Object.SourceID) {
+		return nil, nil, newApiError(http.StatusNotFound, "no open incident with ID %d", id)
+	}
+
+	var contact *recipient.Contact
+	if username != nil {
+		contact = l.apiTokenContact(apiToken, *username)
+
+		l.runtimeConfig.RLock()
+		if contact != nil && !contact.VisibleTo(l.runtimeConfig.GetSourceTenant(i.Object.SourceID)) {
+			contact = nil
+		}
+		l.runtimeConfig.RUnlock()
+
+		if contact == nil {
+			return nil, nil, newApiError(http.StatusNotFound, "unknown contact %q", *username)
+		}
+	}
+
+	return i, contact, nil
+}
@@ -0,0 +1,43 @@
+package listener
+
+import (
+	"context"
+	"github.com/icinga/icinga-notifications/internal/audit"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// recordAudit records a manual action taken by the request in the audit log, see audit.NewEntry.
+//
+// As the action itself already succeeded, failing to record it is only logged. The entry is recorded even if the
+// request was cancelled in the meantime.
+func (l *Listener) recordAudit(req *http.Request, action, actor, target string, before, after any) {
+	e, err := audit.NewEntry(action, actor, target, before, after)
+	if err == nil {
+		e.RemoteAddr = utils.ToDBString(audit.RemoteIP(req.RemoteAddr))
+		err = audit.Record(context.WithoutCancel(req.Context()), l.db, e)
+	}
+	if err != nil {
+		l.logger.Errorw("Cannot record action in the audit log", zap.String("action", action),
+			zap.String("actor", actor), zap.String("target", target), zap.Error(err))
+	}
+}
+
+// apiListAuditLog returns the newest entries of the audit log, see audit.ParseFilter.
+//
+// As the audit log reveals the actions of all contacts and API tokens, it requires the manage_incidents capability.
+func (l *Listener) apiListAuditLog(req *http.Request, _ *config.ApiToken) (any, error) {
+	f, err := audit.ParseFilter(req.URL.Query())
+	if err != nil {
+		return nil, newApiError(http.StatusBadRequest, "%v", err)
+	}
+
+	entries, err := audit.List(req.Context(), l.db, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{"entries": entries}, nil
+}
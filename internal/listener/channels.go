@@ -0,0 +1,156 @@
+package listener
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/audit"
+	"github.com/icinga/icinga-notifications/internal/channel"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/msgtemplate"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"go.uber.org/zap"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// channelStatus is a channel as listed by apiListChannels, omitting its config which may contain credentials.
+type channelStatus struct {
+	ID     int64           `json:"id"`
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	Status *channel.Status `json:"status"`
+}
+
+// apiListChannels lists all channels together with the result of their latest health check, being null if health
+// checks are disabled or the channel was not checked yet.
+func (l *Listener) apiListChannels(_ *http.Request, _ *config.ApiToken) (any, error) {
+	l.runtimeConfig.RLock()
+	defer l.runtimeConfig.RUnlock()
+
+	channels := []*channelStatus{}
+	for _, c := range l.runtimeConfig.Channels {
+		channels = append(channels, &channelStatus{ID: c.ID, Name: c.Name, Type: c.Type, Status: c.Status()})
+	}
+	slices.SortFunc(channels, func(a, b *channelStatus) int { return cmp.Compare(a.ID, b.ID) })
+
+	return map[string]any{"channels": channels}, nil
+}
+
+// channelType is a channel plugin as listed by apiListChannelTypes, including its type omitted by plugin.Info's JSON.
+type channelType struct {
+	Type string `json:"type"`
+	*plugin.Info
+}
+
+// apiListChannelTypes lists the channel plugins found by the latest scan of the channels directory, allowing to build
+// configuration forms from their config options, see channel.Discovered.
+func (l *Listener) apiListChannelTypes(_ *http.Request, _ *config.ApiToken) (any, error) {
+	plugins, discoveredAt := channel.Discovered()
+
+	types := []*channelType{}
+	for _, info := range plugins {
+		types = append(types, &channelType{Type: info.Type, Info: info})
+	}
+
+	return map[string]any{"channel_types": types, "discovered_at": discoveredAt}, nil
+}
+
+// countUnhealthyChannels returns the number of channels whose latest health check failed.
+func (l *Listener) countUnhealthyChannels() int {
+	l.runtimeConfig.RLock()
+	defer l.runtimeConfig.RUnlock()
+
+	var unhealthy int
+	for _, c := range l.runtimeConfig.Channels {
+		if status := c.Status(); status != nil && !status.Healthy {
+			unhealthy++
+		}
+	}
+
+	return unhealthy
+}
+
+// apiTestChannel sends a synthetic test notification via the channel, see channel.NewTestNotificationRequest.
+//
+// The recipient is either an existing contact, given by its "username", or an ad-hoc "address" of the channel's type,
+// e.g., an email address. The notification is rendered from the channel's template without a rule. If the template
+// fails, the default is used and the error is reported as "template_error", allowing to verify templates as well.
+func (l *Listener) apiTestChannel(req *http.Request, apiToken *config.ApiToken) (any, error) {
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil, newApiError(http.StatusBadRequest, "channel ID must be an integer, got %q", req.PathValue("id"))
+	}
+
+	var body struct {
+		Username string `json:"username"`
+		Address  string `json:"address"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, newApiError(http.StatusBadRequest, "cannot parse JSON body: %v", err)
+	}
+	if (body.Username == "") == (body.Address == "") {
+		return nil, newApiError(http.StatusBadRequest, "either username or address must be set")
+	}
+
+	tenantID := l.runtimeConfig.GetApiTokenTenant(apiToken)
+
+	l.runtimeConfig.RLock()
+	ch := l.runtimeConfig.Channels[id]
+	tmpl := l.runtimeConfig.GetNotificationTemplate(id, nil)
+	var contact *recipient.Contact
+	if body.Username != "" {
+		contact = l.runtimeConfig.GetTenantContact(tenantID, body.Username)
+	}
+	l.runtimeConfig.RUnlock()
+
+	if ch == nil || !ch.VisibleTo(tenantID) {
+		return nil, newApiError(http.StatusNotFound, "unknown channel %d", id)
+	}
+	if body.Username != "" && contact == nil {
+		return nil, newApiError(http.StatusNotFound, "unknown contact %q", body.Username)
+	}
+	if contact == nil {
+		contact = &recipient.Contact{
+			FullName:  body.Address,
+			Addresses: []*recipient.Address{{Type: ch.Type, Address: body.Address}},
+		}
+	}
+
+	nr := channel.NewTestNotificationRequest(contact, daemon.Config().Icingaweb2URL, time.Now())
+	data := msgtemplate.NewData(nr, nil)
+
+	result := map[string]string{}
+	subject, message, err := tmpl.Render(data)
+	if err != nil {
+		result["template_error"] = err.Error()
+		if subject, message, err = msgtemplate.RenderDefault(data); err != nil {
+			return nil, err
+		}
+	}
+	nr.Subject, nr.Message = subject, message
+
+	logger := l.logger.With(zap.Object("channel", ch), zap.String("recipient", contact.FullName),
+		zap.Object("api_token", apiToken))
+	// Failed tests are recorded as well, as the notification may have been sent anyway.
+	err = ch.NotifyTest(nr)
+	tested := map[string]string{"recipient": contact.FullName}
+	if err != nil {
+		tested["error"] = err.Error()
+	}
+	l.recordAudit(req, audit.ActionChannelTest, audit.ApiTokenActor(apiToken.Name), audit.Target("channel", id), nil,
+		tested)
+
+	if err != nil {
+		logger.Warnw("Failed to send test notification", zap.Error(err))
+		return nil, newApiError(http.StatusBadGateway, "cannot send test notification: %v", err)
+	}
+
+	logger.Info("Sent test notification")
+	result["message"] = fmt.Sprintf("test notification sent via channel %q to %q", ch.Name, contact.FullName)
+	return result, nil
+}
@@ -0,0 +1,171 @@
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/audit"
+	"github.com/icinga/icinga-notifications/internal/chatops"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/incident"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxChatOpsBodyBytes limits the size of chat callback requests, which are fully read to verify their signature.
+const maxChatOpsBodyBytes = 1 << 20
+
+// SlackCallback handles interaction requests of a Slack app, e.g., a click on an "Acknowledge" button of a message.
+//
+// The result is posted to the interaction's response URL, only visible to the user who clicked the button.
+func (l *Listener) SlackCallback(w http.ResponseWriter, r *http.Request) {
+	secret := daemon.Config().ChatOps.SlackSigningSecret
+	if secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxChatOpsBodyBytes))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, "cannot read request body: %v\n", err)
+		return
+	}
+
+	if err := chatops.VerifySlack(secret, r.Header, body, time.Now()); err != nil {
+		l.logger.Warnw("Rejecting Slack callback", zap.Error(err))
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = fmt.Fprintln(w, err)
+		return
+	}
+
+	callback, err := chatops.ParseSlack(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintln(w, err)
+		return
+	}
+
+	message := l.handleChatOpsCallback(r, callback)
+
+	// Slack expects the response within three seconds, the message is posted separately.
+	w.WriteHeader(http.StatusOK)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := chatops.RespondSlack(ctx, callback.ResponseURL, message); err != nil {
+			l.logger.Warnw("Cannot respond to Slack callback", zap.Error(err))
+		}
+	}()
+}
+
+// TeamsCallback handles messages sent to a Teams outgoing webhook, e.g., "@Icinga ack 42", and Adaptive Card actions.
+//
+// The result is returned as the webhook's reply message.
+func (l *Listener) TeamsCallback(w http.ResponseWriter, r *http.Request) {
+	secret := daemon.Config().ChatOps.TeamsSecret
+	if secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxChatOpsBodyBytes))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, "cannot read request body: %v\n", err)
+		return
+	}
+
+	if err := chatops.VerifyTeams(secret, r.Header, body); err != nil {
+		l.logger.Warnw("Rejecting Teams callback", zap.Error(err))
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = fmt.Fprintln(w, err)
+		return
+	}
+
+	var message string
+	if callback, err := chatops.ParseTeams(body); err != nil {
+		message = fmt.Sprintf("Cannot understand this message: %v. Try \"ack 42\", \"snooze 42\" or \"escalate 42\".", err)
+	} else {
+		message = l.handleChatOpsCallback(r, callback)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"type": "message", "text": message})
+}
+
+// handleChatOpsCallback performs the action of the callback on behalf of the contact having the chat user's address
+// and returns a message describing the result for the chat user.
+func (l *Listener) handleChatOpsCallback(r *http.Request, callback *chatops.Callback) string {
+	ctx := r.Context()
+
+	l.runtimeConfig.RLock()
+	var contact *recipient.Contact
+	if c := l.runtimeConfig.GetContactByAddress(callback.AddressType, callback.Address); c != nil && c.Username.Valid {
+		contact = c
+	}
+	l.runtimeConfig.RUnlock()
+
+	if contact == nil {
+		return fmt.Sprintf("There is no contact with the %s address %q and a username.", callback.AddressType,
+			callback.Address)
+	}
+
+	i := incident.GetCurrentByID(callback.IncidentID)
+	if i == nil {
+		return fmt.Sprintf("Incident #%d is already closed.", callback.IncidentID)
+	}
+
+	// Contacts of other tenants must not act on the incident, treating them like unknown ones.
+	l.runtimeConfig.RLock()
+	contact = l.runtimeConfig.GetTenantContact(l.runtimeConfig.GetSourceTenant(i.Object.SourceID),
+		contact.Username.String)
+	l.runtimeConfig.RUnlock()
+	if contact == nil {
+		return fmt.Sprintf("There is no contact with the %s address %q and a username.", callback.AddressType,
+			callback.Address)
+	}
+
+	username := contact.Username.String
+	var err error
+	var message, action string
+	var after any
+	switch callback.Action {
+	case chatops.ActionAcknowledge:
+		comment := fmt.Sprintf("Acknowledged via %s", callback.AddressType)
+		err = i.Acknowledge(ctx, username, comment)
+		message = fmt.Sprintf("Incident #%d has been acknowledged by %s.", callback.IncidentID, contact.FullName)
+		action, after = audit.ActionAcknowledge, map[string]string{"comment": comment}
+	case chatops.ActionSnooze:
+		err = i.Snooze(ctx, username, chatops.SnoozeDuration)
+		message = fmt.Sprintf("Incident #%d has been snoozed for %v by %s.", callback.IncidentID,
+			chatops.SnoozeDuration, contact.FullName)
+		action, after = audit.ActionSnooze, map[string]string{"duration": chatops.SnoozeDuration.String()}
+	case chatops.ActionEscalate:
+		err = i.Escalate(ctx, username)
+		message = fmt.Sprintf("Incident #%d has been escalated by %s.", callback.IncidentID, contact.FullName)
+		action = audit.ActionEscalate
+	}
+
+	switch {
+	case errors.Is(err, incident.ErrIncidentClosed):
+		return fmt.Sprintf("Incident #%d is already closed.", callback.IncidentID)
+	case errors.Is(err, incident.ErrNoEscalation):
+		return fmt.Sprintf("Incident #%d has no further escalations.", callback.IncidentID)
+	case err != nil:
+		l.logger.Errorw("Cannot handle chat callback", zap.String("action", string(callback.Action)),
+			zap.Int64("incident_id", callback.IncidentID), zap.String("contact", username), zap.Error(err))
+		return fmt.Sprintf("Incident #%d could not be updated, please try again later.", callback.IncidentID)
+	}
+
+	l.logger.Infow("Handled chat callback", zap.String("action", string(callback.Action)),
+		zap.Int64("incident_id", callback.IncidentID), zap.String("contact", username))
+	l.recordAudit(r, action, audit.ContactActor(username), audit.Target("incident", callback.IncidentID), nil, after)
+
+	return message
+}
@@ -0,0 +1,104 @@
+package listener
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// ObjectDependencies lists, adds or removes the object dependencies of the authenticated source.
+//
+// Both objects are identified by their tags, like in events. A GET request lists all dependencies of the source, while
+// POST adds and DELETE removes the dependency given in the JSON body, see object.Dependency.
+func (l *Listener) ObjectDependencies(w http.ResponseWriter, req *http.Request) {
+	abort := func(statusCode int, format string, a ...any) {
+		msg := format
+		if len(a) > 0 {
+			msg = fmt.Sprintf(format, a...)
+		}
+
+		http.Error(w, msg, statusCode)
+		l.logger.Debugw("Abort object dependency request", zap.Int("status_code", statusCode), zap.String("message", msg))
+	}
+
+	if req.Method != http.MethodGet && req.Method != http.MethodPost && req.Method != http.MethodDelete {
+		abort(http.StatusMethodNotAllowed, "GET, POST or DELETE required")
+		return
+	}
+
+	source, authErr := l.authenticateSource(w, req, config.ApiCapabilityIngestEvents)
+	if authErr != nil {
+		abort(authErr.statusCode, authErr.message)
+		return
+	}
+
+	if req.Method == http.MethodGet {
+		dependencies, err := object.ListDependencies(req.Context(), l.db, source.ID)
+		if err != nil {
+			l.logger.Errorw("Cannot list object dependencies", zap.Int64("source", source.ID), zap.Error(err))
+			abort(errorStatusCode(w, err, http.StatusInternalServerError), "cannot list object dependencies")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(dependencies)
+		return
+	}
+
+	var dependency struct {
+		Parent map[string]string `json:"parent"`
+		Child  map[string]string `json:"child"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&dependency); err != nil {
+		abort(http.StatusBadRequest, "cannot parse JSON body: %v", err)
+		return
+	}
+	if len(dependency.Parent) == 0 || len(dependency.Child) == 0 {
+		abort(http.StatusBadRequest, "parent and child tags must be set")
+		return
+	}
+
+	parentID := object.ID(source.ID, dependency.Parent)
+	childID := object.ID(source.ID, dependency.Child)
+	if parentID.String() == childID.String() {
+		abort(http.StatusBadRequest, "an object cannot depend on itself")
+		return
+	}
+
+	logger := l.logger.With(zap.Int64("source", source.ID),
+		zap.Stringer("parent_object_id", parentID), zap.Stringer("child_object_id", childID))
+
+	if req.Method == http.MethodPost {
+		if err := object.AddDependency(req.Context(), l.db, source.ID, parentID, childID); err != nil {
+			logger.Errorw("Cannot add object dependency", zap.Error(err))
+			abort(errorStatusCode(w, err, http.StatusInternalServerError),
+				"object dependency could not be added, see server logs for details")
+			return
+		}
+
+		logger.Infow("Added object dependency")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "object %s depends on object %s\n", childID, parentID)
+		return
+	}
+
+	removed, err := object.RemoveDependency(req.Context(), l.db, source.ID, parentID, childID)
+	if err != nil {
+		logger.Errorw("Cannot remove object dependency", zap.Error(err))
+		abort(errorStatusCode(w, err, http.StatusInternalServerError),
+			"object dependency could not be removed, see server logs for details")
+		return
+	} else if !removed {
+		abort(http.StatusNotFound, "object %s does not depend on object %s", childID, parentID)
+		return
+	}
+
+	logger.Infow("Removed object dependency")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, "object %s no longer depends on object %s\n", childID, parentID)
+}
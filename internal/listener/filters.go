@@ -0,0 +1,70 @@
+package listener
+
+import (
+	"encoding/json"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/filtercheck"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/icinga/icinga-notifications/internal/tagcatalog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiValidateFilter checks an object filter or escalation condition without saving it, see filtercheck.Check.
+//
+// An invalid expression is not an error of the request, thus the result is always sent with 200 OK.
+func (l *Listener) apiValidateFilter(req *http.Request, _ *config.ApiToken) (any, error) {
+	var body struct {
+		Kind       filtercheck.Kind `json:"kind"`
+		Expression string           `json:"expression"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, newApiError(http.StatusBadRequest, "cannot parse JSON body: %v", err)
+	}
+
+	var knownKeys []string
+	if body.Kind == filtercheck.KindObject || body.Kind == filtercheck.KindEscalation {
+		// The tag catalog is empty after a restart, so fall back to the tags of objects with recent events.
+		knownKeys = tagcatalog.Default.Keys(time.Now())
+		if len(knownKeys) == 0 {
+			var err error
+			since := time.Now().Add(-filtercheck.RecentEventsWindow)
+			knownKeys, err = object.RecentTagKeys(req.Context(), l.db, since, filtercheck.MaxKnownKeys)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result, err := filtercheck.Check(body.Kind, body.Expression, knownKeys)
+	if err != nil {
+		return nil, newApiError(http.StatusBadRequest, "%v", err)
+	}
+
+	return result, nil
+}
+
+// apiListTags lists the tags observed on recent events together with sample values, see tagcatalog.Catalog. The
+// optional query parameters "source_id" and "prefix" restrict the result to a single source or to keys starting with
+// the prefix.
+func (l *Listener) apiListTags(req *http.Request, _ *config.ApiToken) (any, error) {
+	var sourceID int64
+	if v := req.URL.Query().Get("source_id"); v != "" {
+		var err error
+		if sourceID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, newApiError(http.StatusBadRequest, "source_id must be an integer, got %q", v)
+		}
+	}
+
+	prefix := req.URL.Query().Get("prefix")
+	tags := []*tagcatalog.Tag{}
+	for _, tag := range tagcatalog.Default.List(sourceID, time.Now()) {
+		if strings.HasPrefix(tag.Key, prefix) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return map[string]any{"tags": tags}, nil
+}
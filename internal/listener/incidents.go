@@ -0,0 +1,122 @@
+package listener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/incident"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// ListIncidents returns the incidents matching the filter of the query parameters, see incident.ParseListFilter.
+//
+// This and the other read-only incident endpoints mirror what Icinga Notifications Web reads from the database, for
+// setups where it cannot access the database directly. They are protected by the debug-password.
+func (l *Listener) ListIncidents(w http.ResponseWriter, r *http.Request) {
+	l.handleIncidentQuery(w, r, func(f *incident.ListFilter) (any, error) {
+		return incident.List(r.Context(), l.db, f)
+	})
+}
+
+// CountIncidents returns the number of incidents per severity, see incident.CountBySeverity.
+func (l *Listener) CountIncidents(w http.ResponseWriter, r *http.Request) {
+	l.handleIncidentQuery(w, r, func(f *incident.ListFilter) (any, error) {
+		return incident.CountBySeverity(r.Context(), l.db, f)
+	})
+}
+
+// ObjectIncidents returns the object given by the object_id query parameter with its incidents, see
+// incident.GetObjectIncidents.
+func (l *Listener) ObjectIncidents(w http.ResponseWriter, r *http.Request) {
+	l.handleIncidentQuery(w, r, func(f *incident.ListFilter) (any, error) {
+		if !f.ObjectID.Valid() {
+			return nil, errMissingObjectID
+		}
+
+		return incident.GetObjectIncidents(r.Context(), l.db, f)
+	})
+}
+
+// errMissingObjectID is returned to the client if the object-incidents endpoint is requested without an object_id.
+var errMissingObjectID = errors.New("object_id query parameter required")
+
+// handleIncidentQuery parses the incident filter from the request's query parameters, passes it to the query function
+// and sends its result as JSON. On failure, an error response is sent instead.
+func (l *Listener) handleIncidentQuery(
+	w http.ResponseWriter, r *http.Request, query func(*incident.ListFilter) (any, error),
+) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = fmt.Fprintln(w, "GET required")
+		return
+	}
+
+	if !l.checkDebugPassword(w, r) {
+		return
+	}
+
+	f, err := incident.ParseListFilter(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintln(w, err)
+		return
+	}
+
+	result, err := query(f)
+	switch {
+	case errors.Is(err, errMissingObjectID):
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintln(w, err)
+		return
+	case errors.Is(err, incident.ErrObjectNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintln(w, err)
+		return
+	case err != nil:
+		l.logger.Errorw("Cannot query incidents", zap.String("query", r.URL.RawQuery), zap.Error(err))
+		w.WriteHeader(errorStatusCode(w, err, http.StatusInternalServerError))
+		_, _ = fmt.Fprintln(w, "incidents could not be queried, see server logs for details")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(result)
+}
+
+// SearchObjects returns the known objects matching the filter of the query parameters together with facet counts, see
+// object.ParseSearchFilter and object.Search. Like the incident queries, it is protected by the debug-password.
+func (l *Listener) SearchObjects(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = fmt.Fprintln(w, "GET required")
+		return
+	}
+
+	if !l.checkDebugPassword(w, r) {
+		return
+	}
+
+	f, err := object.ParseSearchFilter(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintln(w, err)
+		return
+	}
+
+	result, err := object.Search(r.Context(), l.db, f)
+	if err != nil {
+		l.logger.Errorw("Cannot search objects", zap.String("query", r.URL.RawQuery), zap.Error(err))
+		w.WriteHeader(errorStatusCode(w, err, http.StatusInternalServerError))
+		_, _ = fmt.Fprintln(w, "objects could not be searched, see server logs for details")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(result)
+}
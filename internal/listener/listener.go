@@ -9,12 +9,30 @@ import (
 	"github.com/icinga/icinga-go-library/database"
 	"github.com/icinga/icinga-go-library/logging"
 	"github.com/icinga/icinga-notifications/internal"
+	"github.com/icinga/icinga-notifications/internal/acklink"
+	"github.com/icinga/icinga-notifications/internal/archive"
+	"github.com/icinga/icinga-notifications/internal/audit"
 	"github.com/icinga/icinga-notifications/internal/config"
 	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/errs"
 	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/eventbuffer"
+	"github.com/icinga/icinga-notifications/internal/eventqueue"
+	"github.com/icinga/icinga-notifications/internal/ics"
 	"github.com/icinga/icinga-notifications/internal/incident"
+	"github.com/icinga/icinga-notifications/internal/integrity"
+	"github.com/icinga/icinga-notifications/internal/latency"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/icinga/icinga-notifications/internal/retention"
+	"github.com/icinga/icinga-notifications/internal/ruleimport"
+	"github.com/icinga/icinga-notifications/internal/schema"
+	"github.com/icinga/icinga-notifications/internal/scrub"
+	"github.com/icinga/icinga-notifications/internal/shard"
+	"github.com/icinga/icinga-notifications/internal/softdelete"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
 	"go.uber.org/zap"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -23,21 +41,56 @@ type Listener struct {
 	logger        *logging.Logger
 	runtimeConfig *config.RuntimeConfig
 
+	// buffer keeps submitted events while the database is unavailable. It is nil if buffering is disabled.
+	buffer *eventbuffer.Buffer
+	// queue decouples processing submitted events from responding to their requests. It is nil if queueing is disabled.
+	queue *eventqueue.Queue
+	// archive is read for the incident history deleted by the retention. It is nil if archiving is disabled.
+	archive *archive.Archiver
+
 	logs *logging.Logging
 	mux  http.ServeMux
 }
 
-func NewListener(db *database.DB, runtimeConfig *config.RuntimeConfig, logs *logging.Logging) *Listener {
+func NewListener(
+	db *database.DB,
+	runtimeConfig *config.RuntimeConfig,
+	buffer *eventbuffer.Buffer,
+	queue *eventqueue.Queue,
+	archiver *archive.Archiver,
+	logs *logging.Logging,
+) *Listener {
 	l := &Listener{
 		db:            db,
+		buffer:        buffer,
+		queue:         queue,
+		archive:       archiver,
 		logger:        logs.GetChildLogger("listener"),
 		logs:          logs,
 		runtimeConfig: runtimeConfig,
 	}
 	l.mux.HandleFunc("/process-event", l.ProcessEvent)
+	l.mux.HandleFunc("/watch-incident", l.WatchIncident)
+	l.mux.HandleFunc("/object-dependencies", l.ObjectDependencies)
+	l.mux.HandleFunc("/import-rules", l.ImportRules)
+	l.mux.HandleFunc("/soft-delete", l.SoftDelete)
+	l.mux.HandleFunc("/restore", l.Restore)
+	l.mux.HandleFunc("/incidents", l.ListIncidents)
+	l.mux.HandleFunc("/incident-counts", l.CountIncidents)
+	l.mux.HandleFunc("/object-incidents", l.ObjectIncidents)
+	l.mux.HandleFunc("/objects", l.SearchObjects)
 	l.mux.HandleFunc("/dump-config", l.DumpConfig)
 	l.mux.HandleFunc("/dump-incidents", l.DumpIncidents)
 	l.mux.HandleFunc("/dump-schedules", l.DumpSchedules)
+	l.mux.HandleFunc("/check-integrity", l.CheckIntegrity)
+	l.mux.HandleFunc("/read-only", l.ReadOnly)
+	l.mux.HandleFunc("/schedule.ics", l.ExportScheduleIcs)
+	l.mux.HandleFunc("/health", l.Health)
+	l.mux.HandleFunc(acklink.Path, l.AcknowledgeLink)
+	l.mux.HandleFunc("POST "+acklink.VoicePath, l.AcknowledgeVoice)
+	l.mux.HandleFunc("POST /chatops/slack", l.SlackCallback)
+	l.mux.HandleFunc("POST /chatops/teams", l.TeamsCallback)
+	l.registerApi()
 	return l
 }
 
@@ -52,9 +105,13 @@ func (l *Listener) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 // context is done, the web server shuts down gracefully with a hard limit of three seconds.
 //
 // An error is returned in every case except for a gracefully context-based shutdown without hitting the time limit.
+//
+// With the daemon's listener-tls configured, the web server serves HTTPS and requests, but does not require, client
+// certificates. Those are verified per source when authenticating a request, see Listener.authenticateSource. The
+// server certificate is either reloaded from its files or obtained via ACME, see Listener.tlsConfig.
 func (l *Listener) Run(ctx context.Context) error {
 	listenAddr := daemon.Config().Listen
-	l.logger.Infof("Starting listener on http://%s", listenAddr)
+	tlsConf := daemon.Config().ListenerTLS
 	server := &http.Server{
 		Addr:        listenAddr,
 		Handler:     l,
@@ -63,9 +120,23 @@ func (l *Listener) Run(ctx context.Context) error {
 	}
 
 	serverErr := make(chan error)
-	go func() {
-		serverErr <- server.ListenAndServe()
-	}()
+	if tlsConf.Enabled() {
+		tlsConfig, err := l.tlsConfig(ctx, &tlsConf)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = tlsConfig
+
+		l.logger.Infof("Starting listener on https://%s", listenAddr)
+		go func() {
+			serverErr <- server.ListenAndServeTLS("", "")
+		}()
+	} else {
+		l.logger.Infof("Starting listener on http://%s", listenAddr)
+		go func() {
+			serverErr <- server.ListenAndServe()
+		}()
+	}
 
 	select {
 	case <-ctx.Done():
@@ -100,18 +171,22 @@ func (l *Listener) ProcessEvent(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	var source *config.Source
-	if authUser, authPass, authOk := req.BasicAuth(); authOk {
-		source = l.runtimeConfig.GetSourceFromCredentials(authUser, authPass, l.logger)
+	trace := latency.NewTrace(time.Now())
+
+	source, authErr := l.authenticateSource(w, req, config.ApiCapabilityIngestEvents)
+	if authErr != nil {
+		abort(authErr.statusCode, nil, authErr.message)
+		return
 	}
-	if source == nil {
-		w.Header().Set("WWW-Authenticate", `Basic realm="icinga-notifications"`)
-		abort(http.StatusUnauthorized, nil, "HTTP authorization required")
+
+	body, err := source.TransformEventBody(req.Body)
+	if err != nil {
+		abort(http.StatusBadRequest, nil, "cannot transform body: %v", err)
 		return
 	}
 
 	var ev event.Event
-	err := json.NewDecoder(req.Body).Decode(&ev)
+	err = json.NewDecoder(body).Decode(&ev)
 	if err != nil {
 		abort(http.StatusBadRequest, nil, "cannot parse JSON body: %v", err)
 		return
@@ -132,14 +207,64 @@ func (l *Listener) ProcessEvent(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Map and scrub the event before it is buffered, possibly to a file, or processed, as buffered events are processed
+	// as they are.
+	l.runtimeConfig.MapEventSeverity(&ev)
+	if err := l.runtimeConfig.RenderEventURL(&ev); err != nil {
+		l.logger.Warnw("Cannot render URL of event, keeping the submitted one",
+			zap.Stringer("event", &ev), zap.Error(err))
+	}
+	if err := scrub.Default.ScrubEvent(&ev); err != nil {
+		abort(http.StatusBadRequest, &ev, err.Error())
+		return
+	}
+
+	// Let the source correlate its event with the logs and notifications of this daemon.
+	ev.EnsureTraceID()
+	w.Header().Set(plugin.TraceIDHeader, ev.TraceID)
+
+	// Reject events of other shards before they are buffered, letting the source retry with another instance.
+	if objectID := object.ID(ev.SourceId, ev.Tags); !shard.Owns(objectID) {
+		abort(http.StatusMisdirectedRequest, &ev, "%v: shard %d", shard.ErrNotOwned, shard.Of(objectID, shard.Count()))
+		return
+	}
+
+	// Mute and unmute events are recorded once accepted, as buffered or queued events are processed later.
+	if ev.Type == event.TypeMute || ev.Type == event.TypeUnmute {
+		action := audit.ActionMute
+		if ev.Type == event.TypeUnmute {
+			action = audit.ActionUnmute
+		}
+		target := audit.Target("object", object.ID(ev.SourceId, ev.Tags))
+		l.recordAudit(req, action, audit.SourceActor(source.Name), target, nil, map[string]string{"reason": ev.MuteReason})
+	}
+
+	// Keep buffering while buffered events are left, as processing a new event first would reorder the object's events.
+	if l.buffer != nil && l.buffer.Degraded() {
+		l.bufferEvent(w, &ev, abort)
+		return
+	}
+
+	if l.queue != nil {
+		l.enqueueEvent(w, &ev, abort)
+		return
+	}
+
 	l.logger.Infow("Processing event", zap.String("event", ev.String()))
-	err = incident.ProcessEvent(context.Background(), l.db, l.logs, l.runtimeConfig, &ev)
+	ctx := latency.NewContext(context.Background(), trace)
+	err = incident.ProcessEvent(ctx, l.db, l.logs, l.runtimeConfig, &ev)
 	if errors.Is(err, event.ErrSuperfluousStateChange) || errors.Is(err, event.ErrSuperfluousMuteUnmuteEvent) {
 		abort(http.StatusNotAcceptable, &ev, "%v", err)
 		return
+	} else if l.buffer != nil && errs.IsTransientDB(err) {
+		l.logger.Warnw("Cannot process event due to a database error, buffering it", zap.Stringer("event", &ev),
+			zap.Error(err))
+		l.bufferEvent(w, &ev, abort)
+		return
 	} else if err != nil {
 		l.logger.Errorw("Failed to successfully process event", zap.Stringer("event", &ev), zap.Error(err))
-		abort(http.StatusInternalServerError, &ev, "event could not be processed successfully, see server logs for details")
+		abort(errorStatusCode(w, err, http.StatusInternalServerError), &ev,
+			"event could not be processed successfully, see server logs for details")
 		return
 	}
 
@@ -150,6 +275,308 @@ func (l *Listener) ProcessEvent(w http.ResponseWriter, req *http.Request) {
 	_, _ = fmt.Fprintln(w)
 }
 
+// bufferEvent adds the event to the buffer to be processed once the database is available again and responds with 202
+// Accepted, or aborts with 503 Service Unavailable if the buffer is full.
+func (l *Listener) bufferEvent(
+	w http.ResponseWriter, ev *event.Event, abort func(statusCode int, ev *event.Event, format string, a ...any),
+) {
+	if err := l.buffer.Add(ev); err != nil {
+		w.Header().Set("Retry-After", "5")
+		abort(http.StatusServiceUnavailable, ev, "database unavailable and %v, event dropped", err)
+		return
+	}
+
+	l.logger.Infow("Buffered event while the database is unavailable", zap.String("event", ev.String()))
+
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = fmt.Fprintln(w, "event buffered, it will be processed once the database is available again")
+	_, _ = fmt.Fprintln(w)
+}
+
+// enqueueEvent stores the event in the queue to be processed later, or buffers it if the database is unavailable.
+func (l *Listener) enqueueEvent(
+	w http.ResponseWriter, ev *event.Event, abort func(statusCode int, ev *event.Event, format string, a ...any),
+) {
+	err := l.queue.Enqueue(context.Background(), ev)
+	if l.buffer != nil && errs.IsTransientDB(err) {
+		l.logger.Warnw("Cannot enqueue event due to a database error, buffering it", zap.Stringer("event", ev),
+			zap.Error(err))
+		l.bufferEvent(w, ev, abort)
+		return
+	} else if err != nil {
+		l.logger.Errorw("Failed to enqueue event", zap.Stringer("event", ev), zap.Error(err))
+		abort(errorStatusCode(w, err, http.StatusInternalServerError), ev,
+			"event could not be enqueued, see server logs for details")
+		return
+	}
+
+	l.logger.Infow("Enqueued event", zap.String("event", ev.String()))
+
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = fmt.Fprintln(w, "event enqueued, it will be processed shortly")
+	_, _ = fmt.Fprintln(w)
+}
+
+// WatchIncident lets a contact watch or unwatch a current incident, authenticated on behalf of a source.
+//
+// A POST request starts watching the incident or updates the severity threshold of an existing watch, while a DELETE
+// request stops watching it. Both expect a JSON body referencing the incident by its ID and the contact by its username.
+func (l *Listener) WatchIncident(w http.ResponseWriter, req *http.Request) {
+	abort := func(statusCode int, format string, a ...any) {
+		msg := format
+		if len(a) > 0 {
+			msg = fmt.Sprintf(format, a...)
+		}
+
+		http.Error(w, msg, statusCode)
+		l.logger.Debugw("Abort incident watch request", zap.Int("status_code", statusCode), zap.String("message", msg))
+	}
+
+	if req.Method != http.MethodPost && req.Method != http.MethodDelete {
+		abort(http.StatusMethodNotAllowed, "POST or DELETE required")
+		return
+	}
+
+	source, authErr := l.authenticateSource(w, req, config.ApiCapabilityManageIncidents)
+	if authErr != nil {
+		abort(authErr.statusCode, authErr.message)
+		return
+	}
+
+	var watch struct {
+		IncidentID  int64          `json:"incident_id"`
+		Username    string         `json:"username"`
+		MinSeverity event.Severity `json:"min_severity"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&watch); err != nil {
+		abort(http.StatusBadRequest, "cannot parse JSON body: %v", err)
+		return
+	}
+	if watch.Username == "" {
+		abort(http.StatusBadRequest, "username must be set")
+		return
+	}
+
+	l.runtimeConfig.RLock()
+	contact := l.runtimeConfig.GetTenantContact(source.TenantID, watch.Username)
+	l.runtimeConfig.RUnlock()
+	if contact == nil {
+		abort(http.StatusNotFound, "unknown contact %q", watch.Username)
+		return
+	}
+
+	i := incident.GetCurrentByID(watch.IncidentID)
+	if i != nil {
+		// Incidents of other tenants are treated as unknown, not to disclose their existence.
+		l.runtimeConfig.RLock()
+		visible := source.VisibleTo(l.runtimeConfig.GetSourceTenant(i.Object.SourceID))
+		l.runtimeConfig.RUnlock()
+		if !visible {
+			i = nil
+		}
+	}
+	if i == nil {
+		abort(http.StatusNotFound, "no current incident with ID %d", watch.IncidentID)
+		return
+	}
+
+	var err error
+	var action string
+	var before, after any
+	if req.Method == http.MethodPost {
+		err = i.Watch(req.Context(), contact, watch.MinSeverity)
+		action = audit.ActionSubscribe
+		after = map[string]string{"contact": contact.Username.String, "min_severity": watch.MinSeverity.String()}
+	} else {
+		err = i.Unwatch(req.Context(), contact)
+		action, before = audit.ActionUnsubscribe, map[string]string{"contact": contact.Username.String}
+	}
+	if errors.Is(err, incident.ErrIncidentClosed) {
+		abort(http.StatusNotFound, "%v", err)
+		return
+	} else if err != nil {
+		abort(http.StatusInternalServerError, "incident watch could not be updated, see server logs for details")
+		return
+	}
+
+	l.recordAudit(req, action, audit.SourceActor(source.Name), audit.Target("incident", i.Id), before, after)
+
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodPost {
+		_, _ = fmt.Fprintf(w, "contact %q is watching incident %d\n", contact.Username.String, i.Id)
+	} else {
+		_, _ = fmt.Fprintf(w, "contact %q stopped watching incident %d\n", contact.Username.String, i.Id)
+	}
+}
+
+// ImportRules imports a YAML or JSON document of rules atomically, see ruleimport.Import.
+//
+// As this endpoint modifies the configuration, it is protected by the debug-password like the dump endpoints.
+func (l *Listener) ImportRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = fmt.Fprintln(w, "POST required")
+		return
+	}
+
+	if !l.checkDebugPassword(w, r) {
+		return
+	}
+
+	doc, err := ruleimport.ParseDocument(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintln(w, err)
+		return
+	}
+
+	result, err := ruleimport.Import(r.Context(), l.db, doc)
+	if err != nil {
+		l.logger.Warnw("Cannot import rules", zap.Error(err))
+		w.WriteHeader(errorStatusCode(w, err, http.StatusUnprocessableEntity))
+		_, _ = fmt.Fprintln(w, err)
+		return
+	}
+
+	l.logger.Infow("Imported rules", zap.Strings("created", result.Created), zap.Strings("replaced", result.Replaced))
+	l.recordAudit(r, audit.ActionImportRules, audit.ActorDebugPassword, "", nil, result)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(result)
+}
+
+// SoftDelete lists restorable deletions on a GET request and deletes an object on a POST request, see softdelete.Delete.
+//
+// As this endpoint modifies the configuration, it is protected by the debug-password like the dump endpoints.
+func (l *Listener) SoftDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = fmt.Fprintln(w, "GET or POST required")
+		return
+	}
+
+	if !l.checkDebugPassword(w, r) {
+		return
+	}
+
+	var result any
+	if r.Method == http.MethodGet {
+		deletions, err := softdelete.List(r.Context(), l.db, daemon.Config().SoftDeleteGrace)
+		if err != nil {
+			l.logger.Errorw("Cannot list soft-deleted objects", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprintln(w, "soft-deleted objects could not be listed, see server logs for details")
+			return
+		}
+		result = deletions
+	} else {
+		deletion, ok := l.handleSoftDeletion(w, r, softdelete.Delete)
+		if !ok {
+			return
+		}
+		l.logger.Infow("Soft-deleted object",
+			zap.String("type", string(deletion.ObjectType)), zap.Int64("id", deletion.ObjectID))
+		l.recordAudit(r, audit.ActionSoftDelete, audit.ActorDebugPassword,
+			audit.Target(string(deletion.ObjectType), deletion.ObjectID), nil, deletion)
+		result = deletion
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(result)
+}
+
+// Restore reverts a deletion made via the SoftDelete endpoint within the grace period, see softdelete.Restore.
+//
+// As this endpoint modifies the configuration, it is protected by the debug-password like the dump endpoints.
+func (l *Listener) Restore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = fmt.Fprintln(w, "POST required")
+		return
+	}
+
+	if !l.checkDebugPassword(w, r) {
+		return
+	}
+
+	deletion, ok := l.handleSoftDeletion(w, r, softdelete.Restore)
+	if !ok {
+		return
+	}
+	l.logger.Infow("Restored soft-deleted object",
+		zap.String("type", string(deletion.ObjectType)), zap.Int64("id", deletion.ObjectID))
+	l.recordAudit(r, audit.ActionRestore, audit.ActorDebugPassword,
+		audit.Target(string(deletion.ObjectType), deletion.ObjectID), deletion, nil)
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, "restored %s %d\n", deletion.ObjectType, deletion.ObjectID)
+}
+
+// handleSoftDeletion decodes the object reference from the request's JSON body and passes it to either
+// softdelete.Delete or softdelete.Restore. On failure, an error response is sent and false is returned.
+func (l *Listener) handleSoftDeletion(
+	w http.ResponseWriter,
+	r *http.Request,
+	fn func(context.Context, *database.DB, softdelete.ObjectType, int64, time.Duration) (*softdelete.Deletion, error),
+) (*softdelete.Deletion, bool) {
+	var object struct {
+		Type softdelete.ObjectType `json:"type"`
+		ID   int64                 `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&object); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, "cannot parse JSON body: %v\n", err)
+		return nil, false
+	}
+	if err := object.Type.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintln(w, err)
+		return nil, false
+	}
+
+	deletion, err := fn(r.Context(), l.db, object.Type, object.ID, daemon.Config().SoftDeleteGrace)
+	switch {
+	case errors.Is(err, softdelete.ErrNotFound), errors.Is(err, softdelete.ErrNotRestorable):
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintln(w, err)
+		return nil, false
+	case errors.Is(err, softdelete.ErrInUse):
+		w.WriteHeader(http.StatusConflict)
+		_, _ = fmt.Fprintln(w, err)
+		return nil, false
+	case err != nil:
+		l.logger.Errorw("Cannot change soft-deletion of object", zap.String("type", string(object.Type)),
+			zap.Int64("id", object.ID), zap.Error(err))
+		w.WriteHeader(errorStatusCode(w, err, http.StatusInternalServerError))
+		_, _ = fmt.Fprintln(w, "object could not be changed, see server logs for details")
+		return nil, false
+	}
+
+	return deletion, true
+}
+
+// errorStatusCode returns the HTTP status code to respond with for the given error, based on its kind from the errs
+// package, or the fallback status code for any other error.
+//
+// Transient database errors and requests refused while shutting down result in 503 Service Unavailable with a
+// Retry-After header set, hinting the client to resubmit the request later, while references to unknown configuration
+// objects result in 422 Unprocessable Entity.
+func errorStatusCode(w http.ResponseWriter, err error, fallback int) int {
+	switch {
+	case errs.IsTransientDB(err), errors.Is(err, errs.ErrShuttingDown):
+		w.Header().Set("Retry-After", "5")
+		return http.StatusServiceUnavailable
+	case errors.Is(err, errs.ErrConfigMissing):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, errs.ErrSchemaIncompatible):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
+
 // checkDebugPassword checks if the valid debug password was provided. If there is no password configured or the
 // supplied password is incorrect, it sends an error code and returns false. True is returned if access is allowed.
 func (l *Listener) checkDebugPassword(w http.ResponseWriter, r *http.Request) bool {
@@ -258,3 +685,177 @@ func (l *Listener) DumpSchedules(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w)
 	}
 }
+
+// ExportScheduleIcs exports the on-call shifts of the schedule, referenced by the "id" query parameter, as iCalendar.
+//
+// The exported shifts range from one week in the past to four weeks in the future.
+func (l *Listener) ExportScheduleIcs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = fmt.Fprintln(w, "GET required")
+		return
+	}
+
+	if !l.checkDebugPassword(w, r) {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintln(w, "query parameter id must be a schedule ID")
+		return
+	}
+
+	l.runtimeConfig.RLock()
+	schedule := l.runtimeConfig.Schedules[id]
+	if schedule == nil {
+		l.runtimeConfig.RUnlock()
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintln(w, "schedule not found")
+		return
+	}
+
+	// Use the same granularity as the configuration in Icinga Notifications Web, see DumpSchedules.
+	step := 30 * time.Minute
+	now := time.Now().Truncate(step)
+	name := schedule.Name
+	events := ics.ScheduleEvents(schedule, now.AddDate(0, 0, -7), now.AddDate(0, 0, 28), step)
+	l.runtimeConfig.RUnlock()
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if err := ics.Write(w, name, events); err != nil {
+		l.logger.Errorw("Cannot write iCalendar export", zap.Int64("schedule_id", id), zap.Error(err))
+	}
+}
+
+// CheckIntegrity runs all integrity checks without repairing anything and returns their results as JSON.
+func (l *Listener) CheckIntegrity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = fmt.Fprintln(w, "GET required")
+		return
+	}
+
+	if !l.checkDebugPassword(w, r) {
+		return
+	}
+
+	results, err := integrity.Run(r.Context(), l.db, false)
+	if err != nil {
+		l.logger.Errorw("Cannot check referential integrity", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintln(w, "integrity check failed, see server logs for details")
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(results)
+}
+
+// ReadOnly reports whether the daemon is in read-only mode on a GET request and enables or disables it on a POST
+// request, see incident.SetReadOnly.
+//
+// As this endpoint affects all notifications, it is protected by the debug-password like the dump endpoints.
+func (l *Listener) ReadOnly(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = fmt.Fprintln(w, "GET or POST required")
+		return
+	}
+
+	if !l.checkDebugPassword(w, r) {
+		return
+	}
+
+	var mode struct {
+		ReadOnly *bool `json:"read_only"`
+	}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&mode); err != nil || mode.ReadOnly == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintln(w, `JSON body with a boolean "read_only" required`)
+			return
+		}
+
+		if incident.SetReadOnly(*mode.ReadOnly) {
+			if *mode.ReadOnly {
+				l.logger.Warn("Entered read-only mode, no escalations will be triggered and no notifications will be sent")
+			} else {
+				l.logger.Info("Left read-only mode, re-evaluating the escalations of all open incidents")
+			}
+			l.recordAudit(r, audit.ActionReadOnly, audit.ActorDebugPassword, "",
+				map[string]bool{"read_only": !*mode.ReadOnly}, map[string]bool{"read_only": *mode.ReadOnly})
+		}
+	}
+
+	readOnly := incident.ReadOnly()
+	mode.ReadOnly = &readOnly
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(mode)
+}
+
+// Health reports the daemon version and the database schema version together with the range supported by the daemon.
+// If event buffering is enabled, it also reports whether the daemon is degraded, i.e., buffering events due to an
+// unavailable database, together with the buffer's counters. If channel health checks are enabled, the number of
+// channels failing their latest check is reported as well, next to whether the daemon is in read-only mode.
+//
+// It requires no authentication, allowing the Icinga Web module and monitoring to check the compatibility of both
+// during mixed-version upgrades without access to any credentials.
+func (l *Listener) Health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = fmt.Fprintln(w, "GET required")
+		return
+	}
+
+	info := schema.Current()
+	health := struct {
+		Version string `json:"version"`
+		Schema  struct {
+			*schema.Info
+			Compatible bool `json:"compatible"`
+		} `json:"schema"`
+		Degraded    bool               `json:"degraded"`
+		ReadOnly    bool               `json:"read_only"`
+		EventBuffer *eventbuffer.Stats `json:"event_buffer,omitempty"`
+		EventQueue  *eventqueue.Stats  `json:"event_queue,omitempty"`
+		Retention   *retention.Stats   `json:"retention,omitempty"`
+
+		UnhealthyChannels *int `json:"unhealthy_channels,omitempty"`
+
+		NotificationLatency latency.Stats `json:"notification_latency"`
+	}{Version: internal.Version.Version, ReadOnly: incident.ReadOnly()}
+	health.Schema.Info = info
+	health.Schema.Compatible = info.Compatible()
+	if l.buffer != nil {
+		stats := l.buffer.Stats()
+		health.Degraded = stats.Degraded
+		health.EventBuffer = &stats
+	}
+	if l.queue != nil {
+		if stats, err := l.queue.Stats(r.Context()); err != nil {
+			l.logger.Warnw("Cannot count queued events", zap.Error(err))
+		} else {
+			health.EventQueue = &stats
+		}
+	}
+	if daemon.Config().Retention.Enabled() {
+		stats := retention.CurrentStats()
+		health.Retention = &stats
+	}
+	health.NotificationLatency = latency.Default.Stats(daemon.Config().NotificationLatency.SLO)
+	if daemon.Config().ChannelHealthCheck.Interval > 0 {
+		unhealthy := l.countUnhealthyChannels()
+		health.UnhealthyChannels = &unhealthy
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(health)
+}
@@ -0,0 +1,173 @@
+package listener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/audit"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/optout"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// apiOptOut is the JSON representation of a recipient.OptOut.
+type apiOptOut struct {
+	ID        int64           `json:"id"`
+	ChannelID types.Int       `json:"channel_id"`
+	Reason    types.String    `json:"reason"`
+	CreatedBy types.String    `json:"created_by"`
+	CreatedAt types.UnixMilli `json:"created_at"`
+	ExpiresAt types.UnixMilli `json:"expires_at"`
+	EndedAt   types.UnixMilli `json:"ended_at"`
+	EndedBy   types.String    `json:"ended_by"`
+}
+
+func newApiOptOut(o *recipient.OptOut) *apiOptOut {
+	return &apiOptOut{
+		ID:        o.ID,
+		ChannelID: o.ChannelID,
+		Reason:    o.Reason,
+		CreatedBy: o.CreatedBy,
+		CreatedAt: o.CreatedAt,
+		ExpiresAt: o.ExpiresAt,
+		EndedAt:   o.EndedAt,
+		EndedBy:   o.EndedBy,
+	}
+}
+
+// apiOptOutContact returns the contact referenced by the username of the request path, if visible to the API token.
+func (l *Listener) apiOptOutContact(req *http.Request, apiToken *config.ApiToken) (*recipient.Contact, error) {
+	contact := l.apiTokenContact(apiToken, req.PathValue("username"))
+	if contact == nil {
+		return nil, newApiError(http.StatusNotFound, "unknown contact %q", req.PathValue("username"))
+	}
+
+	return contact, nil
+}
+
+// apiListOptOuts lists the opt-outs of a contact which have not ended yet.
+func (l *Listener) apiListOptOuts(req *http.Request, apiToken *config.ApiToken) (any, error) {
+	contact, err := l.apiOptOutContact(req, apiToken)
+	if err != nil {
+		return nil, err
+	}
+
+	l.runtimeConfig.RLock()
+	defer l.runtimeConfig.RUnlock()
+
+	now := time.Now()
+	optOuts := []*apiOptOut{}
+	for _, o := range contact.OptOuts {
+		if o.End().After(now) {
+			optOuts = append(optOuts, newApiOptOut(o))
+		}
+	}
+
+	return map[string]any{"opt_outs": optOuts}, nil
+}
+
+// apiCreateOptOut opts a contact out of a channel, or all channels, until the given expiry, see optout.Create.
+func (l *Listener) apiCreateOptOut(req *http.Request, apiToken *config.ApiToken) (any, error) {
+	contact, err := l.apiOptOutContact(req, apiToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		ChannelID int64     `json:"channel_id"`
+		ExpiresAt time.Time `json:"expires_at"`
+		Duration  string    `json:"duration"`
+		Reason    string    `json:"reason"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, newApiError(http.StatusBadRequest, "cannot parse JSON body: %v", err)
+	}
+
+	if body.Duration != "" {
+		if !body.ExpiresAt.IsZero() {
+			return nil, newApiError(http.StatusBadRequest, "either expires_at or duration must be set, not both")
+		}
+
+		d, err := time.ParseDuration(body.Duration)
+		if err != nil {
+			return nil, newApiError(http.StatusBadRequest, "cannot parse duration: %v", err)
+		}
+		body.ExpiresAt = time.Now().Add(d)
+	}
+
+	if body.ChannelID != 0 {
+		l.runtimeConfig.RLock()
+		_, ok := l.runtimeConfig.Channels[body.ChannelID]
+		l.runtimeConfig.RUnlock()
+		if !ok {
+			return nil, newApiError(http.StatusUnprocessableEntity, "unknown channel %d", body.ChannelID)
+		}
+	}
+
+	r := &optout.Request{
+		ContactID: contact.ID,
+		ChannelID: body.ChannelID,
+		Reason:    body.Reason,
+		ExpiresAt: body.ExpiresAt,
+	}
+	if err := r.Validate(time.Now()); err != nil {
+		return nil, newApiError(http.StatusUnprocessableEntity, "%v", err)
+	}
+
+	optOut, err := optout.Create(req.Context(), l.db, r, fmt.Sprintf("API token %q", apiToken.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	l.logger.Infow("Contact opted out of notifications", zap.String("contact", contact.Username.String),
+		zap.Object("opt_out", optOut), zap.Object("api_token", apiToken))
+	l.recordAudit(req, audit.ActionOptOut, audit.ApiTokenActor(apiToken.Name),
+		audit.Target("contact", contact.Username.String), nil, newApiOptOut(optOut))
+
+	return newApiOptOut(optOut), nil
+}
+
+// apiEndOptOut ends an opt-out of a contact before its expiry, see optout.End.
+func (l *Listener) apiEndOptOut(req *http.Request, apiToken *config.ApiToken) (any, error) {
+	contact, err := l.apiOptOutContact(req, apiToken)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil, newApiError(http.StatusBadRequest, "opt-out ID must be an integer, got %q", req.PathValue("id"))
+	}
+
+	err = optout.End(req.Context(), l.db, contact.ID, id, fmt.Sprintf("API token %q", apiToken.Name))
+	if errors.Is(err, optout.ErrNotFound) {
+		return nil, newApiError(http.StatusNotFound, "%v", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	l.logger.Infow("Ended opt-out of contact", zap.String("contact", contact.Username.String),
+		zap.Int64("opt_out_id", id), zap.Object("api_token", apiToken))
+	l.recordAudit(req, audit.ActionOptOutEnd, audit.ApiTokenActor(apiToken.Name),
+		audit.Target("contact", contact.Username.String), map[string]int64{"opt_out_id": id}, nil)
+
+	return map[string]string{"message": fmt.Sprintf("opt-out %d of contact %q ended", id, contact.Username.String)}, nil
+}
+
+// apiOptOutGaps lists the rule escalations whose recipients have currently all opted out, see config.OptOutGap.
+func (l *Listener) apiOptOutGaps(_ *http.Request, _ *config.ApiToken) (any, error) {
+	l.runtimeConfig.RLock()
+	defer l.runtimeConfig.RUnlock()
+
+	gaps := l.runtimeConfig.OptOutGaps(time.Now())
+	if gaps == nil {
+		gaps = []*config.OptOutGap{}
+	}
+
+	return map[string]any{"gaps": gaps}, nil
+}
@@ -0,0 +1,126 @@
+package listener
+
+import (
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultSimulationRange is simulated if the "to" query parameter is omitted.
+const defaultSimulationRange = 7 * 24 * time.Hour
+
+// simulationRange parses the "from", "to" and "timezone" query parameters of a simulation.
+//
+// The times may either be given in RFC 3339 format or as dates, referring to midnight in the time zone, which defaults
+// to the daemon's local time zone. Without "from", the simulation starts at the current time, truncated to minutes.
+func simulationRange(req *http.Request) (from, to time.Time, loc *time.Location, err error) {
+	query := req.URL.Query()
+
+	loc = time.Local
+	if name := query.Get("timezone"); name != "" {
+		if loc, err = time.LoadLocation(name); err != nil {
+			return time.Time{}, time.Time{}, nil, newApiError(http.StatusBadRequest, "invalid timezone %q", name)
+		}
+	}
+
+	parse := func(param string, fallback time.Time) (time.Time, error) {
+		value := query.Get(param)
+		if value == "" {
+			return fallback, nil
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t.In(loc), nil
+		}
+		if t, err := time.ParseInLocation(time.DateOnly, value, loc); err == nil {
+			return t, nil
+		}
+
+		return time.Time{}, newApiError(http.StatusBadRequest, "%s must be an RFC 3339 time or a date, got %q", param, value)
+	}
+
+	if from, err = parse("from", time.Now().In(loc).Truncate(time.Minute)); err != nil {
+		return
+	}
+	if to, err = parse("to", from.Add(defaultSimulationRange)); err != nil {
+		return
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, nil, newApiError(http.StatusBadRequest, "from must be before to")
+	}
+	if to.Sub(from) > timeperiod.MaxSimulationRange {
+		return time.Time{}, time.Time{}, nil, newApiError(http.StatusBadRequest,
+			"cannot simulate more than %v", timeperiod.MaxSimulationRange)
+	}
+
+	return from, to, loc, nil
+}
+
+// apiSimulateSchedule lists the contacts on call in the schedule within the queried range, see simulationRange.
+func (l *Listener) apiSimulateSchedule(req *http.Request, _ *config.ApiToken) (any, error) {
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil, newApiError(http.StatusBadRequest, "schedule ID must be an integer")
+	}
+
+	from, to, loc, err := simulationRange(req)
+	if err != nil {
+		return nil, err
+	}
+
+	l.runtimeConfig.RLock()
+	defer l.runtimeConfig.RUnlock()
+
+	schedule := l.runtimeConfig.Schedules[id]
+	if schedule == nil {
+		return nil, newApiError(http.StatusNotFound, "schedule not found")
+	}
+
+	return map[string]any{
+		"id":               schedule.ID,
+		"name":             schedule.Name,
+		"timezone":         loc.String(),
+		"shifts":           schedule.Simulate(from, to),
+		"zone_transitions": zoneTransitions(loc, from, to),
+	}, nil
+}
+
+// apiSimulateTimePeriod lists when the time period is active within the queried range, see simulationRange.
+func (l *Listener) apiSimulateTimePeriod(req *http.Request, _ *config.ApiToken) (any, error) {
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil, newApiError(http.StatusBadRequest, "time period ID must be an integer")
+	}
+
+	from, to, loc, err := simulationRange(req)
+	if err != nil {
+		return nil, err
+	}
+
+	l.runtimeConfig.RLock()
+	defer l.runtimeConfig.RUnlock()
+
+	period := l.runtimeConfig.TimePeriods[id]
+	if period == nil {
+		return nil, newApiError(http.StatusNotFound, "time period not found")
+	}
+
+	return map[string]any{
+		"id":               period.ID,
+		"name":             period.Name,
+		"timezone":         loc.String(),
+		"intervals":        period.Simulate(from, to),
+		"zone_transitions": zoneTransitions(loc, from, to),
+	}, nil
+}
+
+// zoneTransitions returns the timeperiod.ZoneTransitions, being an empty list instead of null in JSON.
+func zoneTransitions(loc *time.Location, from, to time.Time) []*timeperiod.ZoneTransition {
+	if transitions := timeperiod.ZoneTransitions(loc, from, to); transitions != nil {
+		return transitions
+	}
+
+	return []*timeperiod.ZoneTransition{}
+}
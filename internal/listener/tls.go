@@ -0,0 +1,48 @@
+package listener
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/icinga/icinga-notifications/internal/certreload"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsConfig creates the web server's TLS configuration, either serving the certificate obtained via ACME or the one
+// loaded from files. The latter is reloaded in the background until the context is done, see certreload.Reloader.
+//
+// Client certificates are requested, but not required, to be verified per source, see Listener.authenticateSource.
+func (l *Listener) tlsConfig(ctx context.Context, conf *daemon.ListenerTLSConfig) (*tls.Config, error) {
+	var tlsConfig *tls.Config
+
+	if len(conf.ACME.Domains) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(conf.ACME.Domains...),
+			Cache:      autocert.DirCache(conf.ACME.CacheDir),
+			Email:      conf.ACME.Email,
+		}
+		if conf.ACME.DirectoryURL != "" {
+			manager.Client = &acme.Client{DirectoryURL: conf.ACME.DirectoryURL}
+		}
+
+		l.logger.Infow("Obtaining TLS certificate via ACME", zap.Strings("domains", conf.ACME.Domains))
+		tlsConfig = manager.TLSConfig()
+	} else {
+		reloader, err := certreload.New(conf.Cert, conf.Key, l.logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load listener TLS certificate")
+		}
+
+		go reloader.Run(ctx, conf.ReloadInterval)
+		tlsConfig = &tls.Config{GetCertificate: reloader.GetCertificate}
+	}
+
+	tlsConfig.MinVersion = tls.VersionTLS12
+	tlsConfig.ClientAuth = tls.RequestClientCert
+
+	return tlsConfig, nil
+}
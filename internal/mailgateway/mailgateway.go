@@ -0,0 +1,204 @@
+// Package mailgateway converts emails delivered via LMTP into events.
+//
+// This allows legacy systems, only capable of sending emails, to open and close incidents. Each received email is
+// matched against the configured rules and the first matching rule creates an event for the configured source.
+package mailgateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/emersion/go-smtp"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/errs"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/eventqueue"
+	"github.com/icinga/icinga-notifications/internal/incident"
+	"github.com/icinga/icinga-notifications/internal/scrub"
+	"github.com/icinga/icinga-notifications/internal/shard"
+	"github.com/jhillyerd/enmime"
+	"go.uber.org/zap"
+	"io"
+	"strings"
+	"time"
+)
+
+// Gateway is an LMTP server, creating events out of received emails.
+type Gateway struct {
+	db            *database.DB
+	logs          *logging.Logging
+	logger        *logging.Logger
+	runtimeConfig *config.RuntimeConfig
+	// queue receives the events instead of processing them right away, unless it is nil.
+	queue *eventqueue.Queue
+
+	conf  daemon.MailGatewayConfig
+	rules []*rule
+}
+
+// NewGateway creates a Gateway for the given configuration, which is expected to be validated already.
+func NewGateway(
+	conf daemon.MailGatewayConfig,
+	db *database.DB,
+	runtimeConfig *config.RuntimeConfig,
+	queue *eventqueue.Queue,
+	logs *logging.Logging,
+) (*Gateway, error) {
+	g := &Gateway{
+		db:            db,
+		logs:          logs,
+		logger:        logs.GetChildLogger("mail-gateway"),
+		runtimeConfig: runtimeConfig,
+		queue:         queue,
+		conf:          conf,
+	}
+
+	for i, ruleConf := range conf.Rules {
+		r, err := newRule(ruleConf)
+		if err != nil {
+			return nil, fmt.Errorf("mail gateway rule %d: %w", i, err)
+		}
+		g.rules = append(g.rules, r)
+	}
+
+	return g, nil
+}
+
+// Run the LMTP server and block until either the server fails or the context is done.
+func (g *Gateway) Run(ctx context.Context) error {
+	server := smtp.NewServer(smtp.BackendFunc(func(*smtp.Conn) (smtp.Session, error) {
+		return &session{gateway: g, ctx: ctx}, nil
+	}))
+	server.LMTP = true
+	server.Addr = g.conf.Listen
+	server.Network = "tcp"
+	if strings.HasPrefix(g.conf.Listen, "/") {
+		server.Network = "unix"
+	}
+	server.Domain = "icinga-notifications"
+	server.MaxMessageBytes = g.conf.MaxMessageBytes
+	server.ReadTimeout = 30 * time.Second
+	server.WriteTimeout = 30 * time.Second
+
+	g.logger.Infof("Starting mail gateway on lmtp %s://%s", server.Network, server.Addr)
+
+	serverErr := make(chan error)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+
+	case err := <-serverErr:
+		return err
+	}
+}
+
+// processMail parses a received email and processes the event of the first matching rule.
+//
+// Emails not matching any rule are only logged and not rejected, as there is nothing the sender could do about it.
+func (g *Gateway) processMail(ctx context.Context, r io.Reader) error {
+	envelope, err := enmime.ReadEnvelope(r)
+	if err != nil {
+		return fmt.Errorf("cannot parse email: %w", err)
+	}
+
+	subject := envelope.GetHeader("Subject")
+	logger := g.logger.With(zap.String("subject", subject), zap.String("message_id", envelope.GetHeader("Message-Id")))
+
+	for i, r := range g.rules {
+		ev, err := r.match(subject, envelope.Text)
+		if err != nil {
+			logger.Errorw("Cannot create event from matching rule", zap.Int("rule", i), zap.Error(err))
+			return err
+		} else if ev == nil {
+			continue
+		}
+
+		ev.Time = time.Now()
+		ev.SourceId = g.conf.SourceId
+		if err := ev.Validate(); err != nil {
+			logger.Errorw("Matching rule created an invalid event", zap.Int("rule", i), zap.Error(err))
+			return err
+		}
+		g.runtimeConfig.MapEventSeverity(ev)
+		if err := g.runtimeConfig.RenderEventURL(ev); err != nil {
+			logger.Warnw("Cannot render URL of event, keeping the original one", zap.Int("rule", i), zap.Error(err))
+		}
+		if err := scrub.Default.ScrubEvent(ev); err != nil {
+			logger.Errorw("Matching rule created an event exceeding the limits", zap.Int("rule", i), zap.Error(err))
+			return err
+		}
+
+		logger.Infow("Processing event from email", zap.Int("rule", i), zap.Stringer("event", ev))
+		if g.queue != nil {
+			err = g.queue.Enqueue(ctx, ev)
+		} else {
+			err = incident.ProcessEvent(ctx, g.db, g.logs, g.runtimeConfig, ev)
+		}
+		if errors.Is(err, event.ErrSuperfluousStateChange) || errors.Is(err, event.ErrSuperfluousMuteUnmuteEvent) {
+			logger.Debugw("Ignoring superfluous event from email", zap.Stringer("event", ev), zap.Error(err))
+			return nil
+		} else if errors.Is(err, shard.ErrNotOwned) {
+			logger.Debugw("Ignoring event from email of an object served by another instance",
+				zap.Stringer("event", ev), zap.Error(err))
+			return nil
+		} else if errors.Is(err, errs.ErrShuttingDown) {
+			logger.Infow("Deferring email as the daemon is shutting down", zap.Stringer("event", ev))
+			return err
+		} else if err != nil {
+			logger.Errorw("Failed to successfully process event from email", zap.Stringer("event", ev), zap.Error(err))
+			return err
+		}
+
+		return nil
+	}
+
+	logger.Debug("Email does not match any rule, discarding it")
+	return nil
+}
+
+// session implements smtp.Session for a single LMTP connection.
+type session struct {
+	gateway *Gateway
+	ctx     context.Context
+}
+
+// Reset implements the smtp.Session interface.
+func (s *session) Reset() {}
+
+// Logout implements the smtp.Session interface.
+func (s *session) Logout() error {
+	return nil
+}
+
+// Mail implements the smtp.Session interface.
+func (s *session) Mail(string, *smtp.MailOptions) error {
+	return nil
+}
+
+// Rcpt implements the smtp.Session interface.
+func (s *session) Rcpt(string, *smtp.RcptOptions) error {
+	return nil
+}
+
+// Data implements the smtp.Session interface.
+//
+// Processing errors result in a temporary failure, letting the delivering MTA retry later on.
+func (s *session) Data(r io.Reader) error {
+	if err := s.gateway.processMail(s.ctx, r); err != nil {
+		return &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+			Message:      "Email could not be processed, see server logs for details",
+		}
+	}
+	return nil
+}
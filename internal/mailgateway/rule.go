@@ -0,0 +1,100 @@
+package mailgateway
+
+import (
+	"fmt"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"os"
+	"regexp"
+)
+
+// rule is the compiled form of a daemon.MailGatewayRule.
+type rule struct {
+	conf    daemon.MailGatewayRule
+	subject *regexp.Regexp
+	body    *regexp.Regexp
+}
+
+// newRule compiles the regular expressions of a daemon.MailGatewayRule.
+func newRule(conf daemon.MailGatewayRule) (*rule, error) {
+	r := &rule{conf: conf}
+
+	var err error
+	if conf.Subject != "" {
+		if r.subject, err = regexp.Compile(conf.Subject); err != nil {
+			return nil, fmt.Errorf("cannot compile subject regular expression: %w", err)
+		}
+	}
+	if conf.Body != "" {
+		if r.body, err = regexp.Compile(conf.Body); err != nil {
+			return nil, fmt.Errorf("cannot compile body regular expression: %w", err)
+		}
+	}
+
+	return r, nil
+}
+
+// match checks whether this rule applies to an email and returns the resulting event.
+//
+// If the rule does not match, nil is returned. Fields unrelated to the email's content, like the Time or SourceId,
+// are not populated.
+func (r *rule) match(subject, body string) (*event.Event, error) {
+	captures := make(map[string]string)
+
+	for _, m := range []struct {
+		re    *regexp.Regexp
+		input string
+	}{{r.subject, subject}, {r.body, body}} {
+		if m.re == nil {
+			continue
+		}
+
+		submatches := m.re.FindStringSubmatch(m.input)
+		if submatches == nil {
+			return nil, nil
+		}
+		for i, name := range m.re.SubexpNames() {
+			if name != "" {
+				captures[name] = submatches[i]
+			}
+		}
+	}
+
+	expand := func(template string) string {
+		return expandCaptures(template, captures)
+	}
+
+	ev := &event.Event{
+		Name:    expand(r.conf.Name),
+		Tags:    make(map[string]string, len(r.conf.Tags)),
+		Type:    expand(r.conf.Type),
+		Message: expand(r.conf.Message),
+	}
+	for k, v := range r.conf.Tags {
+		ev.Tags[k] = expand(v)
+	}
+
+	if ev.Type == "" {
+		ev.Type = event.TypeState
+	}
+	if severity := expand(r.conf.Severity); severity != "" {
+		var err error
+		if ev.Severity, err = event.GetSeverityByName(severity); err != nil {
+			return nil, err
+		}
+	}
+	if ev.Message == "" {
+		ev.Message = subject
+	}
+
+	return ev, nil
+}
+
+// expandCaptures replaces all "$name" and "${name}" references within template by their captured values.
+//
+// Unknown references are replaced by an empty string, as are references to optional groups which did not match.
+func expandCaptures(template string, captures map[string]string) string {
+	return os.Expand(template, func(name string) string {
+		return captures[name]
+	})
+}
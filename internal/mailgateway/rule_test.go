@@ -0,0 +1,67 @@
+package mailgateway
+
+import (
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRule_Match(t *testing.T) {
+	t.Parallel()
+
+	r, err := newRule(daemon.MailGatewayRule{
+		Subject:  `^PROBLEM: (?P<host>\S+) is (?P<state>DOWN|UNREACHABLE)`,
+		Body:     `(?m)^Output: (?P<output>.*)$`,
+		Name:     "$host",
+		Tags:     map[string]string{"host": "${host}"},
+		Severity: "crit",
+		Message:  "$state: $output",
+	})
+	require.NoError(t, err)
+
+	t.Run("Match", func(t *testing.T) {
+		t.Parallel()
+
+		ev, err := r.match("PROBLEM: dummy-42 is DOWN", "Host: dummy-42\nOutput: PING CRITICAL\n")
+		require.NoError(t, err)
+		require.NotNil(t, ev)
+
+		assert.Equal(t, "dummy-42", ev.Name)
+		assert.Equal(t, map[string]string{"host": "dummy-42"}, ev.Tags)
+		assert.Equal(t, event.TypeState, ev.Type)
+		assert.Equal(t, event.SeverityCrit, ev.Severity)
+		assert.Equal(t, "DOWN: PING CRITICAL", ev.Message)
+	})
+
+	t.Run("SubjectMismatch", func(t *testing.T) {
+		t.Parallel()
+
+		ev, err := r.match("RECOVERY: dummy-42 is UP", "Output: PING OK\n")
+		require.NoError(t, err)
+		assert.Nil(t, ev)
+	})
+
+	t.Run("BodyMismatch", func(t *testing.T) {
+		t.Parallel()
+
+		ev, err := r.match("PROBLEM: dummy-42 is DOWN", "no output given")
+		require.NoError(t, err)
+		assert.Nil(t, ev)
+	})
+
+	t.Run("InvalidSeverity", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := newRule(daemon.MailGatewayRule{
+			Subject:  `severity=(?P<severity>\w+)`,
+			Tags:     map[string]string{"host": "foo"},
+			Severity: "$severity",
+		})
+		require.NoError(t, err)
+
+		_, err = r.match("severity=huge", "")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,103 @@
+// Package maintenance implements maintenance windows, suppressing notifications for a set of objects.
+//
+// In contrast to Icinga 2 downtimes, which are reported as mute events by their source, maintenance windows are managed
+// by Icinga Notifications itself. Thus, they are available for all sources, including those without any downtime concept.
+package maintenance
+
+import (
+	"errors"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"github.com/icinga/icinga-notifications/internal/filter"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"go.uber.org/zap/zapcore"
+	"time"
+)
+
+// Window is a period in which notifications for all objects matching its filter are suppressed.
+//
+// The period is limited by the optional start and end time, and further restricted to the optional time period, e.g.,
+// for a weekly recurring maintenance. A window without an object filter applies to all objects.
+type Window struct {
+	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+
+	Name             string                 `db:"name"`
+	StartTime        types.UnixMilli        `db:"start_time"`
+	EndTime          types.UnixMilli        `db:"end_time"`
+	TimePeriod       *timeperiod.TimePeriod `db:"-"`
+	TimePeriodID     types.Int              `db:"timeperiod_id"`
+	ObjectFilter     filter.Filter          `db:"-"`
+	ObjectFilterExpr types.String           `db:"object_filter"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (w *Window) TableName() string {
+	return "maintenance_window"
+}
+
+// IncrementalInitAndValidate implements the config.IncrementalConfigurableInitAndValidatable interface.
+func (w *Window) IncrementalInitAndValidate() error {
+	if !w.StartTime.Time().IsZero() && !w.EndTime.Time().IsZero() && !w.EndTime.Time().After(w.StartTime.Time()) {
+		return errors.New("end_time must be after start_time")
+	}
+
+	if w.ObjectFilterExpr.Valid {
+		f, err := filter.Parse(w.ObjectFilterExpr.String)
+		if err != nil {
+			return err
+		}
+
+		w.ObjectFilter = f
+	}
+
+	return nil
+}
+
+// MarshalLogObject implements the zapcore.ObjectMarshaler interface.
+func (w *Window) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddInt64("id", w.ID)
+	encoder.AddString("name", w.Name)
+
+	if !w.StartTime.Time().IsZero() {
+		encoder.AddTime("start_time", w.StartTime.Time())
+	}
+	if !w.EndTime.Time().IsZero() {
+		encoder.AddTime("end_time", w.EndTime.Time())
+	}
+	if w.TimePeriodID.Valid {
+		encoder.AddInt64("timeperiod_id", w.TimePeriodID.Int64)
+	}
+	if w.ObjectFilterExpr.Valid && w.ObjectFilterExpr.String != "" {
+		encoder.AddString("object_filter", w.ObjectFilterExpr.String)
+	}
+
+	return nil
+}
+
+// IsActive returns whether the given time lies within this maintenance window, ignoring its object filter.
+func (w *Window) IsActive(t time.Time) bool {
+	if start := w.StartTime.Time(); !start.IsZero() && t.Before(start) {
+		return false
+	}
+	if end := w.EndTime.Time(); !end.IsZero() && !t.Before(end) {
+		return false
+	}
+	if w.TimePeriodID.Valid && (w.TimePeriod == nil || !w.TimePeriod.Contains(t)) {
+		return false
+	}
+
+	return true
+}
+
+// Covers returns whether the given object is in maintenance at the given time, i.e., the window is active and its
+// object filter matches.
+func (w *Window) Covers(filterable filter.Filterable, t time.Time) (bool, error) {
+	if !w.IsActive(t) {
+		return false, nil
+	}
+	if w.ObjectFilter == nil {
+		return true, nil
+	}
+
+	return w.ObjectFilter.Eval(filterable)
+}
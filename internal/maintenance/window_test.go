@@ -0,0 +1,60 @@
+package maintenance
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestWindow_Covers(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	db := &object.Object{Tags: map[string]string{"host": "db-1"}}
+	web := &object.Object{Tags: map[string]string{"host": "web-1"}}
+
+	tests := []struct {
+		name   string
+		window *Window
+		obj    *object.Object
+		t      time.Time
+		covers bool
+	}{
+		{"Unbounded", &Window{}, web, start, true},
+		{"BeforeStart", &Window{StartTime: types.UnixMilli(start)}, web, start.Add(-time.Second), false},
+		{"AtStart", &Window{StartTime: types.UnixMilli(start), EndTime: types.UnixMilli(end)}, web, start, true},
+		{"AtEnd", &Window{StartTime: types.UnixMilli(start), EndTime: types.UnixMilli(end)}, web, end, false},
+		{"FilterMatches", &Window{ObjectFilterExpr: utils.ToDBString("host=db-*")}, db, start, true},
+		{"FilterDoesNotMatch", &Window{ObjectFilterExpr: utils.ToDBString("host=db-*")}, web, start, false},
+		{"UnknownTimePeriod", &Window{TimePeriodID: utils.ToDBInt(1)}, web, start, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.NoError(t, tt.window.IncrementalInitAndValidate())
+
+			covers, err := tt.window.Covers(tt.obj, tt.t)
+			require.NoError(t, err)
+			assert.Equal(t, tt.covers, covers)
+		})
+	}
+}
+
+func TestWindow_IncrementalInitAndValidate(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+
+	w := &Window{StartTime: types.UnixMilli(start), EndTime: types.UnixMilli(start)}
+	assert.Error(t, w.IncrementalInitAndValidate(), "end_time must be after start_time")
+
+	w = &Window{ObjectFilterExpr: utils.ToDBString("host=(")}
+	assert.Error(t, w.IncrementalInitAndValidate(), "invalid object filter")
+}
@@ -0,0 +1,52 @@
+package msgtemplate
+
+import (
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"time"
+)
+
+// HistoryLimit is the maximum number of entries returned by Data.History.
+const HistoryLimit = 10
+
+// HistoryEntry is an entry of an incident's history as exposed to templates.
+type HistoryEntry struct {
+	Time     time.Time
+	Type     string
+	Severity string
+	Message  string
+}
+
+// Data is passed to templates, providing all fields of the plugin.NotificationRequest, e.g., {{ .Object.Tags.host }},
+// the incident's annotations, e.g., {{ .Annotations.ticket }}, and its recent history via {{ range .History }}.
+type Data struct {
+	*plugin.NotificationRequest
+
+	// Annotations of the incident, parsed from acknowledgement comments like "ticket=INC1234".
+	Annotations map[string]string
+
+	loadHistory func() ([]*HistoryEntry, error)
+	history     []*HistoryEntry
+	historyErr  error
+	loaded      bool
+}
+
+// NewData creates the Data for a notification request.
+//
+// The loadHistory function is only called if a template uses the history, as doing so usually requires a database
+// query. It may be nil if no history is available.
+func NewData(req *plugin.NotificationRequest, loadHistory func() ([]*HistoryEntry, error)) *Data {
+	return &Data{NotificationRequest: req, loadHistory: loadHistory}
+}
+
+// History returns up to HistoryLimit of the incident's latest history entries, oldest first.
+func (d *Data) History() ([]*HistoryEntry, error) {
+	if !d.loaded && d.loadHistory != nil {
+		d.history, d.historyErr = d.loadHistory()
+		if len(d.history) > HistoryLimit {
+			d.history = d.history[len(d.history)-HistoryLimit:]
+		}
+	}
+	d.loaded = true
+
+	return d.history, d.historyErr
+}
@@ -0,0 +1,123 @@
+package msgtemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// funcs are the helpers available to all templates in addition to the text/template builtins.
+//
+// Their names and argument orders follow the Sprig library commonly used with Go templates, allowing pipelines like
+// `{{ .Event.Message | trunc 200 }}`. None of them allows accessing files, the network or the environment.
+var funcs = template.FuncMap{
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"title":      title,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":       func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"trunc":      trunc,
+	"indent":     indent,
+	"nindent":    func(n int, s string) string { return "\n" + indent(n, s) },
+	"quote":      strconv.Quote,
+	"repeat":     repeat,
+	"default":    defaultValue,
+	"coalesce":   coalesce,
+	"date":       func(layout string, t time.Time) string { return t.Format(layout) },
+	"since":      func(t time.Time) time.Duration { return time.Since(t).Round(time.Second) },
+	"now":        time.Now,
+	"toJson":     toJson,
+}
+
+// title converts the first letter of each word to upper case.
+func title(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		defer func() { prev = r }()
+		if unicode.IsSpace(prev) {
+			return unicode.ToUpper(r)
+		}
+		return r
+	}, s)
+}
+
+// trunc shortens the string to at most n characters.
+func trunc(n int, s string) string {
+	if runes := []rune(s); n >= 0 && len(runes) > n {
+		return string(runes[:n])
+	}
+
+	return s
+}
+
+// indent prefixes each line of the string with n spaces.
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", max(n, 0))
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// repeat returns the string repeated n times, limited to maxOutputBytes.
+func repeat(n int, s string) (string, error) {
+	if n < 0 || n > 0 && len(s)*n > maxOutputBytes {
+		return "", fmt.Errorf("repeat: result exceeds %d bytes", maxOutputBytes)
+	}
+
+	return strings.Repeat(s, n), nil
+}
+
+// defaultValue returns the value unless it is empty, see isEmpty, in which case def is returned.
+func defaultValue(def, value any) any {
+	if isEmpty(value) {
+		return def
+	}
+
+	return value
+}
+
+// coalesce returns the first non-empty value, see isEmpty, or nil if all are empty.
+func coalesce(values ...any) any {
+	for _, v := range values {
+		if !isEmpty(v) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// isEmpty checks whether the value is nil or the zero value of its type, including empty strings, maps and slices.
+func isEmpty(value any) bool {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// toJson encodes the value as JSON, e.g., to embed tags in a structured message.
+func toJson(value any) (string, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
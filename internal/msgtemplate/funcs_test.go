@@ -0,0 +1,41 @@
+package msgtemplate
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestFuncs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{ "hello world" | title }}`, "Hello World"},
+		{`{{ "  x  " | trim }}`, "x"},
+		{`{{ "db-01.example.com" | trimSuffix ".example.com" }}`, "db-01"},
+		{`{{ "a-b-c" | replace "-" "_" }}`, "a_b_c"},
+		{`{{ if "postgres" | hasPrefix "post" }}yes{{ end }}`, "yes"},
+		{`{{ split "," "a,b,c" | join " | " }}`, "a | b | c"},
+		{`{{ "äöüß" | trunc 2 }}`, "äö"},
+		{`{{ "a\nb" | indent 2 }}`, "  a\n  b"},
+		{`{{ "" | default "none" }}`, "none"},
+		{`{{ coalesce "" "second" "third" }}`, "second"},
+		{`{{ "say \"hi\"" | quote }}`, `"say \"hi\""`},
+		{`{{ repeat 3 "ab" }}`, "ababab"},
+		{`{{ toJson (split "," "a,b") }}`, `["a","b"]`},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := template.New("test").Funcs(funcs).Parse(tt.template)
+		require.NoError(t, err, tt.template)
+
+		var out strings.Builder
+		require.NoError(t, tmpl.Execute(&out, nil), tt.template)
+		assert.Equal(t, tt.expected, out.String(), tt.template)
+	}
+}
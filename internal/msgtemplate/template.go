@@ -0,0 +1,198 @@
+// Package msgtemplate renders the subject and message of notifications from Go templates configured per channel and
+// optionally per rule, replacing the fixed format of plugin.FormatSubject and plugin.FormatMessage.
+//
+// Templates are executed with text/template on Data, providing access to the incident, the event, the object's tags
+// and the incident's recent history. Only the helpers of funcs are available in addition to the builtins, neither of
+// which allows accessing files or the network, and the output size is limited.
+package msgtemplate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"go.uber.org/zap/zapcore"
+	"strings"
+	"text/template"
+)
+
+// maxOutputBytes limits the size of a rendered subject or message.
+const maxOutputBytes = 64 * 1024
+
+// subjectLineBreaks folds multi-line subjects, as a line break would end the header of an email, for example.
+var subjectLineBreaks = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+
+// ErrOutputTooLarge is returned if a template renders more than maxOutputBytes.
+var ErrOutputTooLarge = fmt.Errorf("template output exceeds %d bytes", maxOutputBytes)
+
+// DefaultSubject renders the same subject as plugin.FormatSubject.
+const DefaultSubject = `[#{{ .Incident.Id }}] {{ .Event.Type }}
+{{- if eq .Event.Type "state" }} {{ .Object.Name }} is {{ .Incident.Severity }}
+{{- else if or (eq .Event.Type "acknowledgement-cleared") (eq .Event.Type "downtime-removed") }} from {{ .Object.Name }}
+{{- else }} on {{ .Object.Name }}
+{{- end }}`
+
+// DefaultMessage renders the same message as plugin.FormatMessage.
+const DefaultMessage = `{{ if .Event.Message -}}
+{{ if eq .Event.Type "state" }}Output{{ else }}Comment{{ end }}: {{ .Event.Message }}
+
+{{ end -}}
+When: {{ date "2006-01-02 15:04:05 MST" .Event.Time }}
+
+{{ if .Event.Username -}}
+Author: {{ .Event.Username }}
+
+{{ end -}}
+Object: {{ .Object.Url }}
+
+Tags:
+{{ range $k, $v := .Object.Tags }}{{ $k }}: {{ $v }}
+{{ end -}}
+{{ if .Object.ExtraTags }}
+Extra Tags:
+{{ range $k, $v := .Object.ExtraTags }}{{ $k }}: {{ $v }}
+{{ end -}}
+{{ end }}
+Incident: {{ .Incident.Url }}
+{{- if .AcknowledgeUrl }}
+Acknowledge: {{ .AcknowledgeUrl }}
+{{- end }}
+{{- range .Rules }}{{ if .HasAnnotations }}
+
+Rule {{ .Name }}:
+{{- with .Description }}
+{{ . }}
+{{- end }}
+{{- with .RunbookUrl }}
+Runbook: {{ . }}
+{{- end }}
+{{- with .ChatChannel }}
+Chat: {{ . }}
+{{- end }}
+{{- end }}{{ end }}
+{{- if .Reasons }}
+
+You are receiving this notification as:
+{{ range .Reasons }}- {{ . }}
+{{ end -}}
+{{ end -}}`
+
+var (
+	defaultSubject = template.Must(parse("subject", DefaultSubject))
+	defaultMessage = template.Must(parse("message", DefaultMessage))
+)
+
+// Template overrides the subject and message of notifications sent via a channel, optionally only for notifications
+// caused by a specific rule. Either part may be NULL to keep the default, DefaultSubject or DefaultMessage.
+type Template struct {
+	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+
+	ChannelID       int64              `db:"channel_id"`
+	RuleID          types.Int          `db:"rule_id"`
+	Subject         types.String       `db:"subject"`
+	SubjectTemplate *template.Template `db:"-"`
+	Message         types.String       `db:"message"`
+	MessageTemplate *template.Template `db:"-"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (t *Template) TableName() string {
+	return "notification_template"
+}
+
+// IncrementalInitAndValidate implements the config.IncrementalConfigurableInitAndValidatable interface.
+func (t *Template) IncrementalInitAndValidate() error {
+	if !t.Subject.Valid && !t.Message.Valid {
+		return errors.New("template must define a subject or a message")
+	}
+
+	var err error
+	if t.Subject.Valid {
+		if t.SubjectTemplate, err = parse("subject", t.Subject.String); err != nil {
+			return err
+		}
+	}
+	if t.Message.Valid {
+		if t.MessageTemplate, err = parse("message", t.Message.String); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MarshalLogObject implements the zapcore.ObjectMarshaler interface.
+func (t *Template) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddInt64("id", t.ID)
+	encoder.AddInt64("channel_id", t.ChannelID)
+	if t.RuleID.Valid {
+		encoder.AddInt64("rule_id", t.RuleID.Int64)
+	}
+	return nil
+}
+
+// Render the subject and message of a notification, using the defaults for parts not defined by this template.
+//
+// A nil Template renders both defaults. Line breaks in the subject are replaced by spaces.
+func (t *Template) Render(data *Data) (subject, message string, err error) {
+	subjectTmpl, messageTmpl := defaultSubject, defaultMessage
+	if t != nil && t.SubjectTemplate != nil {
+		subjectTmpl = t.SubjectTemplate
+	}
+	if t != nil && t.MessageTemplate != nil {
+		messageTmpl = t.MessageTemplate
+	}
+
+	if subject, err = execute(subjectTmpl, data); err != nil {
+		return "", "", err
+	}
+	if message, err = execute(messageTmpl, data); err != nil {
+		return "", "", err
+	}
+
+	return subjectLineBreaks.Replace(strings.TrimSpace(subject)), message, nil
+}
+
+// RenderDefault renders the subject and message using DefaultSubject and DefaultMessage.
+func RenderDefault(data *Data) (subject, message string, err error) {
+	return (*Template)(nil).Render(data)
+}
+
+// parse a template with the sandboxed helpers. Referring to undefined map keys, e.g., missing tags, renders nothing.
+func parse(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s template: %w", name, err)
+	}
+
+	return t, nil
+}
+
+// execute a template, aborting once its output exceeds maxOutputBytes.
+func execute(t *template.Template, data *Data) (string, error) {
+	w := &limitedWriter{}
+	if err := t.Execute(w, data); err != nil {
+		if errors.Is(err, ErrOutputTooLarge) {
+			return "", ErrOutputTooLarge
+		}
+
+		return "", fmt.Errorf("cannot render %s template: %w", t.Name(), err)
+	}
+
+	return w.String(), nil
+}
+
+// limitedWriter is a bytes.Buffer refusing to grow beyond maxOutputBytes.
+type limitedWriter struct {
+	bytes.Buffer
+}
+
+// Write implements the io.Writer interface.
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > maxOutputBytes {
+		return 0, ErrOutputTooLarge
+	}
+
+	return w.Buffer.Write(p)
+}
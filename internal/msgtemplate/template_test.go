@@ -0,0 +1,184 @@
+package msgtemplate
+
+import (
+	"errors"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func makeRequest() *plugin.NotificationRequest {
+	return &plugin.NotificationRequest{
+		Contact: &plugin.Contact{FullName: "Jane Doe"},
+		Object: &plugin.Object{
+			Name:      "db-01!postgres",
+			Url:       "https://icinga.example.com/icingadb/service?name=postgres&host.name=db-01",
+			Tags:      map[string]string{"service": "postgres", "host": "db-01"},
+			ExtraTags: map[string]string{"team": "dba"},
+		},
+		Incident: &plugin.Incident{Id: 42, Url: "https://icinga.example.com/notifications/incident?id=42", Severity: "crit"},
+		Event: &plugin.Event{
+			Time:    time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+			Type:    "state",
+			Message: "connection refused",
+		},
+		Reasons: []*plugin.Reason{{Escalation: "Database"}, {Role: "subscriber"}},
+		Rules: []*plugin.Rule{
+			{Name: "Database", Description: "Customer database", RunbookUrl: "https://wiki.example.com/db"},
+			{Name: "All"},
+			{Name: "Postgres", ChatChannel: "#team-dba"},
+		},
+		AcknowledgeUrl: "https://notifications.example.com/acknowledge?token=x",
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		modify func(req *plugin.NotificationRequest)
+	}{
+		{name: "State"},
+		{name: "Custom", modify: func(req *plugin.NotificationRequest) {
+			req.Event.Type = "custom"
+			req.Event.Username = "icingaadmin"
+		}},
+		{name: "AcknowledgementCleared", modify: func(req *plugin.NotificationRequest) {
+			req.Event.Type = "acknowledgement-cleared"
+			req.Event.Message = ""
+		}},
+		{name: "Minimal", modify: func(req *plugin.NotificationRequest) {
+			req.Event.Message = ""
+			req.Object.Tags = nil
+			req.Object.ExtraTags = nil
+			req.Reasons = nil
+			req.Rules = nil
+			req.AcknowledgeUrl = ""
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := makeRequest()
+			if tt.modify != nil {
+				tt.modify(req)
+			}
+
+			subject, message, err := RenderDefault(NewData(req, nil))
+			require.NoError(t, err)
+
+			var expected strings.Builder
+			plugin.FormatMessage(&expected, req)
+			assert.Equal(t, plugin.FormatSubject(req), subject)
+			assert.Equal(t, expected.String(), message)
+		})
+	}
+}
+
+func TestTemplate_Render(t *testing.T) {
+	t.Parallel()
+
+	tmpl := &Template{
+		Subject: types.MakeString("{{ .Object.Tags.host | upper }}\nis {{ .Incident.Severity }} " +
+			`{{ .Object.Tags.missing | default "-" }}`),
+		Message: types.MakeString(`{{ .Event.Message | trunc 10 }}
+{{ range .History }}{{ .Type }} {{ .Severity }}
+{{ end }}{{ toJson .Object.ExtraTags }}`),
+	}
+	require.NoError(t, tmpl.IncrementalInitAndValidate())
+
+	calls := 0
+	data := NewData(makeRequest(), func() ([]*HistoryEntry, error) {
+		calls++
+		return []*HistoryEntry{{Type: "opened", Severity: "warning"}, {Type: "severity_changed", Severity: "crit"}}, nil
+	})
+
+	subject, message, err := tmpl.Render(data)
+	require.NoError(t, err)
+	assert.Equal(t, "DB-01 is crit -", subject)
+	assert.Equal(t, "connection\nopened warning\nseverity_changed crit\n{\"team\":\"dba\"}", message)
+
+	_, _, err = tmpl.Render(data)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls, "history should only be loaded once")
+
+	t.Run("PartialTemplate", func(t *testing.T) {
+		t.Parallel()
+
+		tmpl := &Template{Subject: types.MakeString("Incident {{ .Incident.Id }}")}
+		require.NoError(t, tmpl.IncrementalInitAndValidate())
+
+		req := makeRequest()
+		subject, message, err := tmpl.Render(NewData(req, nil))
+		require.NoError(t, err)
+
+		var expected strings.Builder
+		plugin.FormatMessage(&expected, req)
+		assert.Equal(t, "Incident 42", subject)
+		assert.Equal(t, expected.String(), message, "message should fall back to the default")
+	})
+
+	t.Run("Annotations", func(t *testing.T) {
+		t.Parallel()
+
+		tmpl := &Template{Subject: types.MakeString(`{{ with .Annotations.ticket }}[{{ . }}] {{ end }}{{ .Object.Name }}`)}
+		require.NoError(t, tmpl.IncrementalInitAndValidate())
+
+		data := NewData(makeRequest(), nil)
+		subject, _, err := tmpl.Render(data)
+		require.NoError(t, err)
+		assert.Equal(t, "db-01!postgres", subject, "missing annotations should render empty")
+
+		data.Annotations = map[string]string{"ticket": "INC1234"}
+		subject, _, err = tmpl.Render(data)
+		require.NoError(t, err)
+		assert.Equal(t, "[INC1234] db-01!postgres", subject)
+	})
+
+	t.Run("HistoryError", func(t *testing.T) {
+		t.Parallel()
+
+		tmpl := &Template{Message: types.MakeString("{{ range .History }}{{ .Type }}{{ end }}")}
+		require.NoError(t, tmpl.IncrementalInitAndValidate())
+
+		_, _, err := tmpl.Render(NewData(makeRequest(), func() ([]*HistoryEntry, error) {
+			return nil, errors.New("database unavailable")
+		}))
+		assert.ErrorContains(t, err, "database unavailable")
+	})
+}
+
+func TestTemplate_IncrementalInitAndValidate(t *testing.T) {
+	t.Parallel()
+
+	for name, tmpl := range map[string]*Template{
+		"Empty":          {},
+		"Syntax":         {Subject: types.MakeString("{{ .Incident.Id ")},
+		"UnknownFunc":    {Message: types.MakeString(`{{ readFile "/etc/passwd" }}`)},
+		"NoEnvFunctions": {Message: types.MakeString(`{{ env "HOME" }}`)},
+	} {
+		assert.Error(t, tmpl.IncrementalInitAndValidate(), name)
+	}
+}
+
+func TestOutputLimit(t *testing.T) {
+	t.Parallel()
+
+	for name, text := range map[string]string{
+		"Repeat": `{{ repeat 100000 "x" }}`,
+		"Range":  `{{ range $i, $_ := split "," (repeat 30000 ",") }}{{ $.Object.Url }}{{ end }}`,
+	} {
+		tmpl := &Template{Message: types.MakeString(text)}
+		require.NoError(t, tmpl.IncrementalInitAndValidate(), name)
+
+		_, _, err := tmpl.Render(NewData(makeRequest(), nil))
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,109 @@
+// Package oauth2 implements the parts of OAuth 2.0 needed by channel plugins to authenticate against external services,
+// i.e., fetching access tokens by the client credentials grant, see RFC 6749, section 4.4.
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expiryLeeway is subtracted from the lifetime of access tokens, refreshing them before they expire while in use.
+const expiryLeeway = time.Minute
+
+// requestTimeout limits the time to fetch an access token.
+const requestTimeout = 30 * time.Second
+
+// ClientCredentials fetches access tokens using the client credentials grant, caching them until shortly before they
+// expire. It is safe for concurrent use.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scope        string
+
+	// Client is used for token requests, defaulting to http.DefaultClient.
+	Client *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// Token returns a valid access token, fetching a new one if the cached token is about to expire.
+func (s *ClientCredentials) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expiry) {
+		return s.token, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.ClientID},
+		"client_secret": {s.ClientSecret},
+	}
+	if s.Scope != "" {
+		form.Set("scope", s.Scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot fetch OAuth 2.0 access token: %w", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("cannot read OAuth 2.0 token response: %w", err)
+	}
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("cannot parse OAuth 2.0 token response (%s): %w", res.Status, err)
+	}
+	if res.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("OAuth 2.0 token request failed with %s: %s %s", res.Status, token.Error,
+			token.ErrorDescription)
+	}
+
+	s.token = token.AccessToken
+	s.expiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - expiryLeeway)
+
+	return s.token, nil
+}
+
+// Invalidate discards the cached access token, e.g., after the server rejected it.
+func (s *ClientCredentials) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = ""
+}
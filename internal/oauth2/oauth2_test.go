@@ -0,0 +1,56 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClientCredentials(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+
+		assert.NoError(t, r.ParseForm())
+		if r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprint(w, `{"error": "invalid_client", "error_description": "bad secret"}`)
+			return
+		}
+
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "https://outlook.office365.com/.default", r.PostForm.Get("scope"))
+		_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 3600}`, n)
+	}))
+	defer server.Close()
+
+	s := &ClientCredentials{
+		TokenURL:     server.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scope:        "https://outlook.office365.com/.default",
+		Client:       server.Client(),
+	}
+
+	token, err := s.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	token, err = s.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token, "token should be cached until it expires")
+
+	s.Invalidate()
+	token, err = s.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+
+	s = &ClientCredentials{TokenURL: server.URL, ClientID: "id", ClientSecret: "wrong", Client: server.Client()}
+	_, err = s.Token(context.Background())
+	assert.ErrorContains(t, err, "invalid_client bad secret")
+}
@@ -0,0 +1,92 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// Dependency makes the child object depend on the parent object, e.g., a host behind a switch.
+//
+// While the parent has an open incident of at least critical severity, notifications of the child are suppressed, as
+// its problems are most likely caused by its parent.
+type Dependency struct {
+	ParentObjectID types.Binary    `db:"parent_object_id" json:"parent_object_id"`
+	ChildObjectID  types.Binary    `db:"child_object_id" json:"child_object_id"`
+	SourceID       int64           `db:"source_id" json:"source_id"`
+	ChangedAt      types.UnixMilli `db:"changed_at" json:"changed_at"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (d *Dependency) TableName() string {
+	return "object_dependency"
+}
+
+// AddDependency stores the dependency of the child on the parent object, configured by the given source.
+//
+// Adding an already existing dependency again only updates its source and change time.
+func AddDependency(ctx context.Context, db *database.DB, sourceID int64, parent, child types.Binary) error {
+	if bytes.Equal(parent, child) {
+		return fmt.Errorf("object %s cannot depend on itself", parent)
+	}
+
+	d := &Dependency{
+		ParentObjectID: parent,
+		ChildObjectID:  child,
+		SourceID:       sourceID,
+		ChangedAt:      types.UnixMilli(time.Now()),
+	}
+
+	stmt, _ := db.BuildUpsertStmt(d)
+	if _, err := db.NamedExecContext(ctx, stmt, d); err != nil {
+		return fmt.Errorf("cannot upsert object dependency: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveDependency deletes the dependency of the child on the parent object configured by the given source.
+//
+// It returns whether such a dependency existed.
+func RemoveDependency(ctx context.Context, db *database.DB, sourceID int64, parent, child types.Binary) (bool, error) {
+	stmt := db.Rebind(`DELETE FROM "object_dependency"
+		WHERE "parent_object_id" = ? AND "child_object_id" = ? AND "source_id" = ?`)
+	res, err := db.ExecContext(ctx, stmt, parent, child, sourceID)
+	if err != nil {
+		return false, fmt.Errorf("cannot delete object dependency: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// ListDependencies returns all dependencies configured by the given source.
+func ListDependencies(ctx context.Context, db *database.DB, sourceID int64) ([]*Dependency, error) {
+	dependencies := []*Dependency{}
+	stmt := db.Rebind(`SELECT "parent_object_id", "child_object_id", "source_id", "changed_at" FROM "object_dependency"
+		WHERE "source_id" = ? ORDER BY "changed_at"`)
+	if err := db.SelectContext(ctx, &dependencies, stmt, sourceID); err != nil {
+		return nil, fmt.Errorf("cannot list object dependencies: %w", err)
+	}
+
+	return dependencies, nil
+}
+
+// ParentIDs returns the IDs of all objects the given object directly depends on.
+func ParentIDs(ctx context.Context, tx *sqlx.Tx, childID types.Binary) ([]types.Binary, error) {
+	var parents []types.Binary
+	stmt := tx.Rebind(`SELECT "parent_object_id" FROM "object_dependency" WHERE "child_object_id" = ?`)
+	if err := tx.SelectContext(ctx, &parents, stmt, childID); err != nil {
+		return nil, fmt.Errorf("cannot fetch parent objects: %w", err)
+	}
+
+	return parents, nil
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/icinga/icinga-go-library/types"
 	"github.com/icinga/icinga-notifications/internal/event"
 	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/jmoiron/sqlx"
 	"regexp"
 	"sort"
 	"strings"
@@ -96,40 +97,34 @@ func FromEvent(ctx context.Context, db *database.DB, ev *event.Event) (*Object,
 		}
 	}
 
-	tx, err := db.BeginTxx(ctx, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to start object database transaction: %w", err)
-	}
-	defer func() { _ = tx.Rollback() }()
-
-	stmt, _ := db.BuildUpsertStmt(&Object{})
-	_, err = tx.NamedExecContext(ctx, stmt, newObject)
-	if err != nil {
-		return nil, fmt.Errorf("failed to insert object: %w", err)
-	}
-
-	stmt, _ = db.BuildUpsertStmt(&IdTagRow{})
-	_, err = tx.NamedExecContext(ctx, stmt, mapToTagRows(newObject.ID, ev.Tags))
-	if err != nil {
-		return nil, fmt.Errorf("failed to upsert object id tags: %w", err)
-	}
+	err := utils.RunInTx(ctx, db, func(tx *sqlx.Tx) error {
+		stmt, _ := db.BuildUpsertStmt(&Object{})
+		if _, err := tx.NamedExecContext(ctx, stmt, newObject); err != nil {
+			return fmt.Errorf("failed to insert object: %w", err)
+		}
 
-	extraTag := &ExtraTagRow{ObjectId: newObject.ID}
-	_, err = tx.NamedExecContext(ctx, `DELETE FROM "object_extra_tag" WHERE "object_id" = :object_id`, extraTag)
-	if err != nil {
-		return nil, fmt.Errorf("failed to delete object extra tags: %w", err)
-	}
+		stmt, _ = db.BuildUpsertStmt(&IdTagRow{})
+		if _, err := tx.NamedExecContext(ctx, stmt, mapToTagRows(newObject.ID, ev.Tags)); err != nil {
+			return fmt.Errorf("failed to upsert object id tags: %w", err)
+		}
 
-	if len(ev.ExtraTags) > 0 {
-		stmt, _ := db.BuildInsertStmt(extraTag)
-		_, err = tx.NamedExecContext(ctx, stmt, mapToTagRows(newObject.ID, ev.ExtraTags))
+		extraTag := &ExtraTagRow{ObjectId: newObject.ID}
+		_, err := tx.NamedExecContext(ctx, `DELETE FROM "object_extra_tag" WHERE "object_id" = :object_id`, extraTag)
 		if err != nil {
-			return nil, fmt.Errorf("failed to insert object extra tags: %w", err)
+			return fmt.Errorf("failed to delete object extra tags: %w", err)
 		}
-	}
 
-	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("cannot commit object database transaction: %w", err)
+		if len(ev.ExtraTags) > 0 {
+			stmt, _ := db.BuildInsertStmt(extraTag)
+			if _, err := tx.NamedExecContext(ctx, stmt, mapToTagRows(newObject.ID, ev.ExtraTags)); err != nil {
+				return fmt.Errorf("failed to insert object extra tags: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot sync object to the database: %w", err)
 	}
 
 	if !objectExists {
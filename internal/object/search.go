@@ -0,0 +1,292 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/jmoiron/sqlx"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSearchLimit is the number of objects returned by Search if SearchFilter.Limit is not set.
+const DefaultSearchLimit = 100
+
+// MaxSearchLimit is the maximum number of objects returned by a single Search call.
+const MaxSearchLimit = 1000
+
+// SourceFacet is the name of the facet counting the matching objects per source ID, which is always part of a
+// SearchResult.
+const SourceFacet = "source"
+
+// TagCondition restricts a search to objects having a tag, either an identifying or an extra one.
+type TagCondition struct {
+	Tag string
+
+	// Value must match the tag's value exactly, if set. Otherwise, only the tag's existence is checked.
+	Value *string
+}
+
+// SearchFilter restricts the objects returned by Search.
+//
+// Zero values do not restrict the result, except for Limit, which defaults to DefaultSearchLimit.
+type SearchFilter struct {
+	// Name must be contained in the object's name, ignoring the case, if set.
+	Name string
+
+	// Tags must all be present on the object.
+	Tags []TagCondition
+
+	SourceID int64
+
+	// Facets are extra tag prefixes, e.g., "hostgroup", to count the matching objects per group for. An object is
+	// part of the group "foo" of the facet "hostgroup" if it has an extra tag "hostgroup/foo".
+	Facets []string
+
+	Limit  int
+	Offset int
+}
+
+// ParseSearchFilter parses a SearchFilter from URL query parameters, e.g., as used by the HTTP API.
+//
+// Supported parameters are "name", "tag" (repeatable, either "key=value" or just "key"), "source_id", "facet"
+// (repeatable), "limit" and "offset".
+func ParseSearchFilter(query url.Values) (*SearchFilter, error) {
+	f := &SearchFilter{Name: query.Get("name")}
+
+	for _, tag := range query["tag"] {
+		key, value, hasValue := strings.Cut(tag, "=")
+		if key == "" {
+			return nil, fmt.Errorf("tag must not be empty, got %q", tag)
+		}
+
+		cond := TagCondition{Tag: key}
+		if hasValue {
+			cond.Value = &value
+		}
+		f.Tags = append(f.Tags, cond)
+	}
+
+	for _, facet := range query["facet"] {
+		facet = strings.TrimSuffix(facet, "/")
+		if facet == "" || facet == SourceFacet {
+			return nil, fmt.Errorf("facet must be an extra tag prefix, got %q", facet)
+		}
+		f.Facets = append(f.Facets, facet)
+	}
+
+	for param, dest := range map[string]any{"source_id": &f.SourceID, "limit": &f.Limit, "offset": &f.Offset} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative integer, got %q", param, value)
+		}
+
+		switch dest := dest.(type) {
+		case *int64:
+			*dest = n
+		case *int:
+			*dest = int(n)
+		}
+	}
+
+	if f.Limit > MaxSearchLimit {
+		return nil, fmt.Errorf("limit must not exceed %d", MaxSearchLimit)
+	}
+
+	return f, nil
+}
+
+// where builds the WHERE clause of this filter with "?" placeholders, referencing the object table as "o", together
+// with its arguments.
+func (f *SearchFilter) where() (string, []any) {
+	conditions := []string{"1 = 1"}
+	var args []any
+
+	if f.Name != "" {
+		conditions = append(conditions, `LOWER(o."name") LIKE ?`)
+		args = append(args, "%"+escapeLike(strings.ToLower(f.Name))+"%")
+	}
+
+	if f.SourceID != 0 {
+		conditions = append(conditions, `o."source_id" = ?`)
+		args = append(args, f.SourceID)
+	}
+
+	for _, cond := range f.Tags {
+		var exists []string
+		for _, table := range []string{"object_id_tag", "object_extra_tag"} {
+			subquery := fmt.Sprintf(`SELECT 1 FROM %q t WHERE t."object_id" = o."id" AND t."tag" = ?`, table)
+			args = append(args, cond.Tag)
+			if cond.Value != nil {
+				subquery += ` AND t."value" = ?`
+				args = append(args, *cond.Value)
+			}
+			exists = append(exists, "EXISTS ("+subquery+")")
+		}
+		conditions = append(conditions, "("+strings.Join(exists, " OR ")+")")
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern using the default escape character, which is a backslash in both
+// MySQL and PostgreSQL.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// SearchHit is a single object returned by Search, including its tags.
+type SearchHit struct {
+	ID        types.Binary      `db:"id" json:"id"`
+	SourceID  int64             `db:"source_id" json:"source_id"`
+	Name      string            `db:"name" json:"name"`
+	URL       types.String      `db:"url" json:"url"`
+	Tags      map[string]string `db:"-" json:"tags"`
+	ExtraTags map[string]string `db:"-" json:"extra_tags"`
+}
+
+// SearchResult is the result of Search.
+type SearchResult struct {
+	// Total is the number of all matching objects, regardless of the filter's Limit and Offset.
+	Total int64 `json:"total"`
+
+	Objects []*SearchHit `json:"objects"`
+
+	// Facets maps each facet name to the number of matching objects per group. The SourceFacet is keyed by source IDs.
+	Facets map[string]map[string]int64 `json:"facets"`
+}
+
+// Search returns the known objects matching the given filter from the database, ordered by their names, together with
+// the number of matching objects per source and per group of the filter's facets.
+//
+// This allows user interfaces to offer object pickers and assists in writing object filters.
+func Search(ctx context.Context, db *database.DB, f *SearchFilter) (*SearchResult, error) {
+	where, args := f.where()
+	result := &SearchResult{Objects: []*SearchHit{}, Facets: map[string]map[string]int64{}}
+
+	stmt := db.Rebind(fmt.Sprintf(`SELECT COUNT(*) FROM "object" o WHERE %s`, where))
+	if err := db.GetContext(ctx, &result.Total, stmt, args...); err != nil {
+		return nil, fmt.Errorf("cannot count objects: %w", err)
+	}
+
+	limit := f.Limit
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+
+	stmt = db.Rebind(fmt.Sprintf(`SELECT o."id", o."source_id", o."name", o."url" FROM "object" o
+		WHERE %s
+		ORDER BY o."name", o."id"
+		LIMIT %d OFFSET %d`, where, limit, f.Offset))
+	if err := db.SelectContext(ctx, &result.Objects, stmt, args...); err != nil {
+		return nil, fmt.Errorf("cannot search objects: %w", err)
+	}
+
+	if err := loadSearchHitTags(ctx, db, result.Objects); err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		Group string `db:"grp"`
+		Count int64  `db:"count"`
+	}
+	stmt = db.Rebind(fmt.Sprintf(`SELECT o."source_id" AS "grp", COUNT(*) AS "count" FROM "object" o
+		WHERE %s
+		GROUP BY o."source_id"`, where))
+	if err := db.SelectContext(ctx, &counts, stmt, args...); err != nil {
+		return nil, fmt.Errorf("cannot count objects per source: %w", err)
+	}
+
+	result.Facets[SourceFacet] = make(map[string]int64, len(counts))
+	for _, c := range counts {
+		result.Facets[SourceFacet][c.Group] = c.Count
+	}
+
+	for _, facet := range f.Facets {
+		prefix := facet + "/"
+		counts = counts[:0]
+		stmt = db.Rebind(fmt.Sprintf(`SELECT f."tag" AS "grp", COUNT(*) AS "count" FROM "object_extra_tag" f
+			INNER JOIN "object" o ON o."id" = f."object_id"
+			WHERE f."tag" LIKE ? AND %s
+			GROUP BY f."tag"`, where))
+		if err := db.SelectContext(ctx, &counts, stmt, append([]any{escapeLike(prefix) + "%"}, args...)...); err != nil {
+			return nil, fmt.Errorf("cannot count objects per %s: %w", facet, err)
+		}
+
+		result.Facets[facet] = make(map[string]int64, len(counts))
+		for _, c := range counts {
+			result.Facets[facet][strings.TrimPrefix(c.Group, prefix)] = c.Count
+		}
+	}
+
+	return result, nil
+}
+
+// loadSearchHitTags fetches the identifying and extra tags of the given objects from the database.
+func loadSearchHitTags(ctx context.Context, db *database.DB, hits []*SearchHit) error {
+	if len(hits) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*SearchHit, len(hits))
+	ids := make([]types.Binary, 0, len(hits))
+	for _, hit := range hits {
+		hit.Tags = map[string]string{}
+		hit.ExtraTags = map[string]string{}
+		byID[hit.ID.String()] = hit
+		ids = append(ids, hit.ID)
+	}
+
+	for _, table := range []string{"object_id_tag", "object_extra_tag"} {
+		query := fmt.Sprintf(`SELECT "object_id", "tag", "value" FROM %q WHERE "object_id" IN (?)`, table)
+		stmt, args, err := sqlx.In(query, ids)
+		if err != nil {
+			return fmt.Errorf("cannot build placeholders for %s query: %w", table, err)
+		}
+
+		var tags []*TagRow
+		if err := db.SelectContext(ctx, &tags, db.Rebind(stmt), args...); err != nil {
+			return fmt.Errorf("cannot fetch object tags: %w", err)
+		}
+
+		for _, tag := range tags {
+			hit := byID[tag.ObjectId.String()]
+			if table == "object_id_tag" {
+				hit.Tags[tag.Tag] = tag.Value
+			} else {
+				hit.ExtraTags[tag.Tag] = tag.Value
+			}
+		}
+	}
+
+	return nil
+}
+
+// RecentTagKeys returns the distinct keys of both identifying and extra tags of objects with events since the given
+// time, sorted and limited to the given number of keys.
+func RecentTagKeys(ctx context.Context, db *database.DB, since time.Time, limit int) ([]string, error) {
+	stmt := db.Rebind(fmt.Sprintf(`SELECT "tag" FROM (
+			SELECT t."tag" FROM "object_id_tag" t
+			WHERE t."object_id" IN (SELECT e."object_id" FROM "event" e WHERE e."time" >= ?)
+			UNION
+			SELECT t."tag" FROM "object_extra_tag" t
+			WHERE t."object_id" IN (SELECT e."object_id" FROM "event" e WHERE e."time" >= ?)
+		) tags
+		ORDER BY "tag"
+		LIMIT %d`, limit))
+
+	keys := []string{}
+	if err := db.SelectContext(ctx, &keys, stmt, types.UnixMilli(since), types.UnixMilli(since)); err != nil {
+		return nil, fmt.Errorf("cannot fetch recent tag keys: %w", err)
+	}
+
+	return keys, nil
+}
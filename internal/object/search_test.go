@@ -0,0 +1,79 @@
+package object
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+)
+
+func TestParseSearchFilter(t *testing.T) {
+	t.Parallel()
+
+	linux := "linux"
+	const tagExists = `(EXISTS (SELECT 1 FROM "object_id_tag" t WHERE t."object_id" = o."id" AND t."tag" = ?)` +
+		` OR EXISTS (SELECT 1 FROM "object_extra_tag" t WHERE t."object_id" = o."id" AND t."tag" = ?))`
+	const tagEquals = `(EXISTS (SELECT 1 FROM "object_id_tag" t WHERE t."object_id" = o."id" AND t."tag" = ?` +
+		` AND t."value" = ?) OR EXISTS (SELECT 1 FROM "object_extra_tag" t WHERE t."object_id" = o."id"` +
+		` AND t."tag" = ? AND t."value" = ?))`
+
+	tests := []struct {
+		name  string
+		query string
+		want  *SearchFilter
+		where string
+		args  []any
+	}{
+		{"Empty", "", &SearchFilter{}, "1 = 1", nil},
+		{
+			"Name",
+			"name=Web_01%25",
+			&SearchFilter{Name: "Web_01%"},
+			`1 = 1 AND LOWER(o."name") LIKE ?`,
+			[]any{`%web\_01\%%`},
+		},
+		{
+			"Tags",
+			"tag=service&tag=os%3Dlinux&source_id=2",
+			&SearchFilter{Tags: []TagCondition{{Tag: "service"}, {Tag: "os", Value: &linux}}, SourceID: 2},
+			`1 = 1 AND o."source_id" = ? AND ` + tagExists + " AND " + tagEquals,
+			[]any{int64(2), "service", "service", "os", "linux", "os", "linux"},
+		},
+		{
+			"Facets",
+			"facet=hostgroup&facet=servicegroup/&limit=10&offset=20",
+			&SearchFilter{Facets: []string{"hostgroup", "servicegroup"}, Limit: 10, Offset: 20},
+			"1 = 1",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			f, err := ParseSearchFilter(query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, f)
+
+			where, args := f.where()
+			assert.Equal(t, tt.where, where)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+
+	for _, query := range []string{"tag=", "tag=%3Dfoo", "facet=", "facet=source", "source_id=x", "limit=1001"} {
+		t.Run(query, func(t *testing.T) {
+			t.Parallel()
+
+			values, err := url.ParseQuery(query)
+			require.NoError(t, err)
+
+			_, err = ParseSearchFilter(values)
+			assert.Error(t, err)
+		})
+	}
+}
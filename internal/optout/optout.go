@@ -0,0 +1,105 @@
+// Package optout creates and ends opt-outs of contacts from their notification channels, see recipient.OptOut.
+//
+// Both are recorded with the actor, e.g., the name of an API token, and the rows are never deleted, allowing to audit
+// who stopped notifications to whom and when. The RuntimeConfig picks up the changes with its next synchronization.
+package optout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// MaxDuration limits how long an opt-out may last, as an opt-out lasting for months is rather a configuration change.
+const MaxDuration = 90 * 24 * time.Hour
+
+// ErrNotFound is returned by End if the contact has no such opt-out which has not ended yet.
+var ErrNotFound = errors.New("opt-out does not exist or has already ended")
+
+// Request describes a new opt-out, see Create.
+type Request struct {
+	ContactID int64
+	// ChannelID of the channel to opt out of, or 0 for all channels.
+	ChannelID int64
+	Reason    string
+	ExpiresAt time.Time
+}
+
+// Validate checks that the opt-out expires within MaxDuration from now.
+func (r *Request) Validate(now time.Time) error {
+	if r.ExpiresAt.IsZero() {
+		return errors.New("an opt-out must expire")
+	}
+	if !r.ExpiresAt.After(now) {
+		return errors.New("an opt-out must expire in the future")
+	}
+	if r.ExpiresAt.Sub(now) > MaxDuration {
+		return fmt.Errorf("an opt-out must not last longer than %v", MaxDuration)
+	}
+
+	return nil
+}
+
+// Create inserts the requested opt-out on behalf of the given actor and returns it.
+func Create(ctx context.Context, db *database.DB, req *Request, actor string) (*recipient.OptOut, error) {
+	now := time.Now()
+	if err := req.Validate(now); err != nil {
+		return nil, err
+	}
+
+	optOut := &recipient.OptOut{
+		IncrementalPkDbEntry: baseconf.IncrementalPkDbEntry[int64]{
+			IncrementalDbEntry: baseconf.IncrementalDbEntry{
+				ChangedAt: types.UnixMilli(now),
+				Deleted:   types.Bool{Bool: false, Valid: true},
+			},
+		},
+		ContactID: req.ContactID,
+		ChannelID: utils.ToDBInt(req.ChannelID),
+		Reason:    utils.ToDBString(req.Reason),
+		CreatedBy: utils.ToDBString(actor),
+		CreatedAt: types.UnixMilli(now),
+		ExpiresAt: types.UnixMilli(req.ExpiresAt),
+	}
+
+	err := utils.RunInTx(ctx, db, func(tx *sqlx.Tx) error {
+		id, err := utils.InsertAndFetchId(ctx, tx, utils.BuildInsertStmtWithout(db, optOut, "id"), optOut)
+		if err != nil {
+			return fmt.Errorf("cannot insert opt-out: %w", err)
+		}
+
+		optOut.ID = id
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return optOut, nil
+}
+
+// End ends the contact's opt-out of the given ID before its expiry on behalf of the given actor.
+func End(ctx context.Context, db *database.DB, contactID, id int64, actor string) error {
+	now := types.UnixMilli(time.Now())
+	stmt := db.Rebind(`UPDATE "contact_opt_out" SET "ended_at" = ?, "ended_by" = ?, "changed_at" = ?
+		WHERE "id" = ? AND "contact_id" = ? AND "deleted" = 'n' AND "ended_at" IS NULL AND "expires_at" > ?`)
+	res, err := db.ExecContext(ctx, stmt, now, utils.ToDBString(actor), now, id, contactID, now)
+	if err != nil {
+		return fmt.Errorf("cannot end opt-out: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("cannot end opt-out: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package optout
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRequest_Validate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		valid     bool
+	}{
+		{"Hours", now.Add(8 * time.Hour), true},
+		{"MaxDuration", now.Add(MaxDuration), true},
+		{"NoExpiry", time.Time{}, false},
+		{"Past", now.Add(-time.Second), false},
+		{"Now", now, false},
+		{"TooLong", now.Add(MaxDuration + time.Second), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Request{ContactID: 1, ExpiresAt: tt.expiresAt}).Validate(now)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Package pgnotify listens for the notifications sent by the triggers of the PostgreSQL schema.
+//
+// Without it, configuration changes made by Icinga Web are only noticed by the periodic synchronization and changes of
+// incident recipients and watches not at all until the next event of the incident. LISTEN/NOTIFY delivers them within
+// milliseconds instead. As notifications are lost while being disconnected, each handler is called with an empty
+// payload after reconnecting, allowing it to catch up on everything.
+package pgnotify
+
+import (
+	"context"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// ConfigChannel is notified with the name of the table about changes of the configuration.
+	ConfigChannel = "icinga_notifications_config"
+	// IncidentChannel is notified with the ID of the incident about changes of its recipients or watches.
+	IncidentChannel = "icinga_notifications_incident"
+)
+
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+
+	// pingInterval between two checks of an idle connection, detecting a broken one without waiting for TCP timeouts.
+	pingInterval = 90 * time.Second
+)
+
+// Handler handles the payload of a notification, or an empty one after reconnecting.
+type Handler func(ctx context.Context, payload string)
+
+// Listener dispatches the notifications of the channels to their handlers.
+type Listener struct {
+	Database database.Config
+	Logger   *logging.Logger
+	// Handlers by the channel they listen on.
+	Handlers map[string]Handler
+}
+
+// Run listens for notifications until ctx is cancelled, reconnecting to the database if necessary.
+func (l *Listener) Run(ctx context.Context) error {
+	listener := pq.NewListener(dsn(&l.Database), minReconnectInterval, maxReconnectInterval,
+		func(ev pq.ListenerEventType, err error) {
+			switch ev {
+			case pq.ListenerEventConnected:
+				l.Logger.Info("Listening for database notifications")
+			case pq.ListenerEventReconnected:
+				l.Logger.Info("Reconnected to the database, listening for notifications again")
+			case pq.ListenerEventDisconnected, pq.ListenerEventConnectionAttemptFailed:
+				l.Logger.Warnw("Cannot listen for database notifications, changes are noticed by polling until reconnected",
+					zap.Error(err))
+			}
+		})
+	defer func() { _ = listener.Close() }()
+
+	for channel := range l.Handlers {
+		// While not connected yet, the channel is listened on once connected.
+		if err := listener.Listen(channel); err != nil {
+			return errors.Wrapf(err, "cannot listen on channel %q", channel)
+		}
+	}
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case n := <-listener.Notify:
+			if n == nil {
+				// Reconnected, notifications in between are lost.
+				for _, handle := range l.Handlers {
+					handle(ctx, "")
+				}
+				continue
+			}
+
+			l.Logger.Debugw("Received database notification", zap.String("channel", n.Channel),
+				zap.String("payload", n.Extra))
+			if handle := l.Handlers[n.Channel]; handle != nil {
+				handle(ctx, n.Extra)
+			}
+		case <-ticker.C:
+			// A failed ping lets the listener reconnect, which is logged by the event callback.
+			_ = listener.Ping()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// dsn returns the connection URI for the PostgreSQL database like database.NewDbFromConfig builds it, which cannot be
+// reused, as the notifications require a dedicated connection outside the connection pool.
+func dsn(c *database.Config) string {
+	uri := &url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(c.User, c.Password),
+		Path:   "/" + url.PathEscape(c.Database),
+	}
+
+	port := c.Port
+	if port == 0 {
+		port = 5432
+	}
+
+	query := url.Values{
+		"connect_timeout": {"60"},
+		// See database.NewDbFromConfig why the host is part of the query string.
+		"host": {c.Host},
+		"port": {strconv.Itoa(port)},
+	}
+
+	if c.TlsOptions.Enable {
+		if c.TlsOptions.Insecure {
+			query.Set("sslmode", "require")
+		} else {
+			query.Set("sslmode", "verify-full")
+		}
+
+		if c.TlsOptions.Cert != "" {
+			query.Set("sslcert", c.TlsOptions.Cert)
+		}
+		if c.TlsOptions.Key != "" {
+			query.Set("sslkey", c.TlsOptions.Key)
+		}
+		if c.TlsOptions.Ca != "" {
+			query.Set("sslrootcert", c.TlsOptions.Ca)
+		}
+	} else {
+		query.Set("sslmode", "disable")
+	}
+
+	uri.RawQuery = query.Encode()
+
+	return uri.String()
+}
@@ -0,0 +1,43 @@
+package pgnotify
+
+import (
+	"github.com/icinga/icinga-go-library/config"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+)
+
+func TestDSN(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Defaults", func(t *testing.T) {
+		t.Parallel()
+
+		u, err := url.Parse(dsn(&database.Config{Host: "/run/postgresql", Database: "notifications", User: "icinga"}))
+		require.NoError(t, err)
+
+		assert.Equal(t, "icinga", u.User.Username())
+		assert.Equal(t, "/notifications", u.Path)
+		assert.Equal(t, "/run/postgresql", u.Query().Get("host"), "host must be passed in the query string")
+		assert.Equal(t, "5432", u.Query().Get("port"))
+		assert.Equal(t, "disable", u.Query().Get("sslmode"))
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		t.Parallel()
+
+		u, err := url.Parse(dsn(&database.Config{
+			Host:       "db.example.com",
+			Port:       5433,
+			Database:   "notifications",
+			TlsOptions: config.TLS{Enable: true, Ca: "/etc/ca.crt"},
+		}))
+		require.NoError(t, err)
+
+		assert.Equal(t, "5433", u.Query().Get("port"))
+		assert.Equal(t, "verify-full", u.Query().Get("sslmode"))
+		assert.Equal(t, "/etc/ca.crt", u.Query().Get("sslrootcert"))
+	})
+}
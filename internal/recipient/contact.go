@@ -2,6 +2,7 @@ package recipient
 
 import (
 	"database/sql"
+	"github.com/icinga/icinga-go-library/types"
 	"github.com/icinga/icinga-notifications/internal/config/baseconf"
 	"go.uber.org/zap/zapcore"
 	"time"
@@ -9,11 +10,20 @@ import (
 
 type Contact struct {
 	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+	baseconf.TenantEntry                 `db:",inline"`
 
 	FullName         string         `db:"full_name"`
 	Username         sql.NullString `db:"username"`
 	DefaultChannelID int64          `db:"default_channel_id"`
 	Addresses        []*Address     `db:"-"`
+	OptOuts          []*OptOut      `db:"-"`
+
+	// OutOfOffice contacts are not notified, but their substitute contact instead, if any.
+	OutOfOffice         types.Bool `db:"out_of_office"`
+	SubstituteContactID types.Int  `db:"substitute_contact_id"`
+	// QuietHoursTimePeriodID references the time period during which only critical notifications are delivered.
+	QuietHoursTimePeriodID types.Int            `db:"quiet_hours_timeperiod_id"`
+	ChannelPreferences     []*ChannelPreference `db:"-"`
 }
 
 func (c *Contact) String() string {
@@ -37,9 +47,16 @@ var _ Recipient = (*Contact)(nil)
 type Address struct {
 	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
 
-	ContactID int64  `db:"contact_id"`
-	Type      string `db:"type"`
-	Address   string `db:"address"`
+	ContactID int64      `db:"contact_id"`
+	Type      string     `db:"type"`
+	Address   string     `db:"address"`
+	Verified  types.Bool `db:"verified"`
+}
+
+// Unverified reports whether the address is explicitly marked as not verified. Addresses without a verification
+// state, e.g., of synthetic contacts, are considered verified.
+func (a *Address) Unverified() bool {
+	return a.Verified.Valid && !a.Verified.Bool
 }
 
 // MarshalLogObject implements the zapcore.ObjectMarshaler interface.
@@ -2,6 +2,7 @@ package recipient
 
 import (
 	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
 	"go.uber.org/zap/zapcore"
 	"time"
 )
@@ -9,12 +10,58 @@ import (
 type Group struct {
 	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
 
-	Name    string     `db:"name"`
-	Members []*Contact `db:"-"`
+	Name    string         `db:"name"`
+	Members []*Contact     `db:"-"`
+	Regions []*GroupRegion `db:"-"`
 }
 
+// GetContactsAt returns the group's members at the given time.
+//
+// For a group without regions, these are always all of its members. Otherwise, the group follows the sun and only the
+// members of all regions active at this time are returned instead of the group's own members. If no region is active,
+// the members of all regions are returned as a fallback, ensuring that nobody is missed due to gaps in the regions'
+// time periods.
 func (g *Group) GetContactsAt(t time.Time) []*Contact {
-	return g.Members
+	if len(g.Regions) == 0 {
+		return g.Members
+	}
+
+	var active []*GroupRegion
+	for _, region := range g.Regions {
+		if region.TimePeriod != nil && region.TimePeriod.Contains(t) {
+			active = append(active, region)
+		}
+	}
+	if len(active) == 0 {
+		active = g.Regions
+	}
+
+	var contacts []*Contact
+	seen := make(map[int64]struct{})
+	for _, region := range active {
+		if region.RegionGroup == nil {
+			continue
+		}
+
+		for _, contact := range region.RegionGroup.Members {
+			if _, ok := seen[contact.ID]; !ok {
+				seen[contact.ID] = struct{}{}
+				contacts = append(contacts, contact)
+			}
+		}
+	}
+
+	return contacts
+}
+
+// hasMember checks whether the Contact is a member of this Group.
+func (g *Group) hasMember(contact *Contact) bool {
+	for _, member := range g.Members {
+		if member.ID == contact.ID {
+			return true
+		}
+	}
+	return false
 }
 
 func (g *Group) TableName() string {
@@ -33,6 +80,30 @@ func (g *Group) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 	return nil
 }
 
+// GroupRegion assigns a regional group to a follow-the-sun Group, being active within the TimePeriod.
+type GroupRegion struct {
+	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+
+	GroupID       int64                  `db:"contactgroup_id"`
+	RegionGroupID int64                  `db:"region_contactgroup_id"`
+	RegionGroup   *Group                 `db:"-"`
+	TimePeriodID  int64                  `db:"timeperiod_id"`
+	TimePeriod    *timeperiod.TimePeriod `db:"-"`
+}
+
+func (r *GroupRegion) TableName() string {
+	return "contactgroup_region"
+}
+
+// MarshalLogObject implements the zapcore.ObjectMarshaler interface.
+func (r *GroupRegion) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddInt64("id", r.ID)
+	encoder.AddInt64("contactgroup_id", r.GroupID)
+	encoder.AddInt64("region_contactgroup_id", r.RegionGroupID)
+	encoder.AddInt64("timeperiod_id", r.TimePeriodID)
+	return nil
+}
+
 // GroupMemberKey represents the combined primary key of GroupMember.
 type GroupMemberKey struct {
 	GroupId   int64 `db:"contactgroup_id"`
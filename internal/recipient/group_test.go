@@ -0,0 +1,74 @@
+package recipient
+
+import (
+	"database/sql"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestGroup_GetContactsAt(t *testing.T) {
+	newContact := func(id int64, name string) *Contact {
+		return &Contact{IncrementalPkDbEntry: baseconf.IncrementalPkDbEntry[int64]{ID: id}, FullName: name}
+	}
+
+	// businessHours creates a time period covering 09:00 to 17:00 on each weekday within the given timezone.
+	businessHours := func(tz string) *timeperiod.TimePeriod {
+		loc, err := time.LoadLocation(tz)
+		require.NoError(t, err)
+
+		start := time.Date(2024, 1, 1, 9, 0, 0, 0, loc) // Monday
+		entry := &timeperiod.Entry{
+			StartTime: types.UnixMilli(start),
+			EndTime:   types.UnixMilli(start.Add(8 * time.Hour)),
+			Timezone:  tz,
+			RRule:     sql.NullString{String: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", Valid: true},
+		}
+		require.NoError(t, entry.Init())
+
+		return &timeperiod.TimePeriod{Entries: []*timeperiod.Entry{entry}}
+	}
+
+	lead := newContact(1, "Lead")
+	berlin := newContact(2, "Berlin")
+	newYork := newContact(3, "New York")
+
+	groupBerlin := &Group{Name: "Berlin", Members: []*Contact{berlin, lead}}
+	groupNewYork := &Group{Name: "New York", Members: []*Contact{newYork}}
+
+	group := &Group{
+		Name:    "Follow the Sun",
+		Members: []*Contact{lead},
+		Regions: []*GroupRegion{
+			{RegionGroup: groupBerlin, TimePeriod: businessHours("Europe/Berlin")},
+			{RegionGroup: groupNewYork, TimePeriod: businessHours("America/New_York")},
+		},
+	}
+
+	tests := []struct {
+		name string
+		time time.Time
+		want []*Contact
+	}{
+		{"berlin-morning", time.Date(2024, 3, 13, 8, 0, 0, 0, time.UTC), []*Contact{berlin, lead}},
+		{"overlap", time.Date(2024, 3, 13, 14, 30, 0, 0, time.UTC), []*Contact{berlin, lead, newYork}},
+		{"new-york-afternoon", time.Date(2024, 3, 13, 18, 0, 0, 0, time.UTC), []*Contact{newYork}},
+		{"nobody-active-fallback", time.Date(2024, 3, 16, 12, 0, 0, 0, time.UTC), []*Contact{berlin, lead, newYork}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, group.GetContactsAt(tt.time))
+		})
+	}
+
+	assert.Equal(t, []*Contact{newYork}, groupNewYork.GetContactsAt(time.Now()), "group without regions")
+
+	newYorkAfternoon := time.Date(2024, 3, 13, 18, 0, 0, 0, time.UTC)
+	assert.Nil(t, GetMembershipAt(group, lead, newYorkAfternoon),
+		"own members of a group with regions must not be notified while not part of an active region")
+	assert.Equal(t, &Membership{Group: group}, GetMembershipAt(group, newYork, newYorkAfternoon))
+}
@@ -0,0 +1,84 @@
+package recipient
+
+import (
+	"errors"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"go.uber.org/zap/zapcore"
+	"time"
+)
+
+// OptOut temporarily stops a contact from being notified via a channel, or via all channels if ChannelID is NULL.
+//
+// Each opt-out must expire, preventing a forgotten opt-out from silently breaking the notification routing. Opt-outs
+// are never deleted but ended early by setting EndedAt, thus they also serve as an audit trail of who opted out when
+// and why.
+type OptOut struct {
+	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+
+	ContactID int64           `db:"contact_id"`
+	ChannelID types.Int       `db:"channel_id"`
+	Reason    types.String    `db:"reason"`
+	CreatedBy types.String    `db:"created_by"`
+	CreatedAt types.UnixMilli `db:"created_at"`
+	ExpiresAt types.UnixMilli `db:"expires_at"`
+	EndedAt   types.UnixMilli `db:"ended_at"`
+	EndedBy   types.String    `db:"ended_by"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (o *OptOut) TableName() string {
+	return "contact_opt_out"
+}
+
+// IncrementalInitAndValidate implements the config.IncrementalConfigurableInitAndValidatable interface.
+func (o *OptOut) IncrementalInitAndValidate() error {
+	if o.ExpiresAt.Time().IsZero() {
+		return errors.New("opt-out must expire")
+	}
+	if !o.ExpiresAt.Time().After(o.CreatedAt.Time()) {
+		return errors.New("opt-out must expire after it was created")
+	}
+
+	return nil
+}
+
+// Covers checks whether this opt-out applies to the given channel at the given time.
+func (o *OptOut) Covers(channelID int64, t time.Time) bool {
+	if o.ChannelID.Valid && o.ChannelID.Int64 != channelID {
+		return false
+	}
+
+	return !t.Before(o.CreatedAt.Time()) && t.Before(o.End())
+}
+
+// End returns when this opt-out ends, i.e., either its expiry or when it was ended early.
+func (o *OptOut) End() time.Time {
+	if ended := o.EndedAt.Time(); !ended.IsZero() && ended.Before(o.ExpiresAt.Time()) {
+		return ended
+	}
+
+	return o.ExpiresAt.Time()
+}
+
+// MarshalLogObject implements the zapcore.ObjectMarshaler interface.
+func (o *OptOut) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddInt64("id", o.ID)
+	encoder.AddInt64("contact_id", o.ContactID)
+	if o.ChannelID.Valid {
+		encoder.AddInt64("channel_id", o.ChannelID.Int64)
+	}
+	encoder.AddTime("ends_at", o.End())
+	return nil
+}
+
+// OptedOut returns an opt-out of this contact covering the given channel at the given time, or nil if there is none.
+func (c *Contact) OptedOut(channelID int64, t time.Time) *OptOut {
+	for _, o := range c.OptOuts {
+		if o.Covers(channelID, t) {
+			return o
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package recipient
+
+import (
+	"database/sql"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestOptOut(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	expiresAt := createdAt.Add(8 * time.Hour)
+
+	email := &OptOut{ContactID: 1, ChannelID: types.Int{NullInt64: sql.NullInt64{Int64: 2, Valid: true}},
+		CreatedAt: types.UnixMilli(createdAt), ExpiresAt: types.UnixMilli(expiresAt)}
+	all := &OptOut{ContactID: 1, CreatedAt: types.UnixMilli(createdAt), ExpiresAt: types.UnixMilli(expiresAt)}
+
+	t.Run("Validate", func(t *testing.T) {
+		t.Parallel()
+
+		assert.NoError(t, email.IncrementalInitAndValidate())
+		assert.Error(t, (&OptOut{CreatedAt: types.UnixMilli(createdAt)}).IncrementalInitAndValidate(),
+			"opt-outs must expire")
+		assert.Error(t, (&OptOut{CreatedAt: types.UnixMilli(expiresAt), ExpiresAt: types.UnixMilli(createdAt)}).
+			IncrementalInitAndValidate())
+	})
+
+	t.Run("Covers", func(t *testing.T) {
+		t.Parallel()
+
+		assert.True(t, email.Covers(2, createdAt))
+		assert.True(t, email.Covers(2, expiresAt.Add(-time.Second)))
+		assert.False(t, email.Covers(3, createdAt), "other channels are not covered")
+		assert.False(t, email.Covers(2, createdAt.Add(-time.Second)))
+		assert.False(t, email.Covers(2, expiresAt), "expired opt-outs are not covered")
+
+		assert.True(t, all.Covers(3, createdAt))
+
+		ended := *all
+		ended.EndedAt = types.UnixMilli(createdAt.Add(time.Hour))
+		assert.Equal(t, createdAt.Add(time.Hour), ended.End())
+		assert.True(t, ended.Covers(3, createdAt))
+		assert.False(t, ended.Covers(3, createdAt.Add(time.Hour)), "ended opt-outs are not covered")
+	})
+
+	t.Run("Contact", func(t *testing.T) {
+		t.Parallel()
+
+		contact := &Contact{OptOuts: []*OptOut{email}}
+		assert.Same(t, email, contact.OptedOut(2, createdAt))
+		assert.Nil(t, contact.OptedOut(3, createdAt))
+		assert.Nil(t, (&Contact{}).OptedOut(2, createdAt))
+	})
+}
@@ -0,0 +1,43 @@
+package recipient
+
+import (
+	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"go.uber.org/zap/zapcore"
+)
+
+// ChannelPreference lets a contact be notified via a channel only about incidents of at least a minimum severity.
+type ChannelPreference struct {
+	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+
+	ContactID   int64          `db:"contact_id"`
+	ChannelID   int64          `db:"channel_id"`
+	MinSeverity event.Severity `db:"min_severity"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (p *ChannelPreference) TableName() string {
+	return "contact_channel_preference"
+}
+
+// MarshalLogObject implements the zapcore.ObjectMarshaler interface.
+func (p *ChannelPreference) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddInt64("id", p.ID)
+	encoder.AddInt64("contact_id", p.ContactID)
+	encoder.AddInt64("channel_id", p.ChannelID)
+	encoder.AddString("min_severity", p.MinSeverity.String())
+	return nil
+}
+
+// MinSeverity returns the minimum severity this contact wants to be notified about via the given channel, or
+// event.SeverityNone if there is no preference. If multiple preferences exist for the channel, the highest one wins.
+func (c *Contact) MinSeverity(channelID int64) event.Severity {
+	minSeverity := event.SeverityNone
+	for _, p := range c.ChannelPreferences {
+		if p.ChannelID == channelID && p.MinSeverity > minSeverity {
+			minSeverity = p.MinSeverity
+		}
+	}
+
+	return minSeverity
+}
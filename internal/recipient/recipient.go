@@ -5,6 +5,7 @@ import (
 	"github.com/icinga/icinga-go-library/types"
 	"github.com/icinga/icinga-notifications/internal/utils"
 	"go.uber.org/zap/zapcore"
+	"slices"
 	"time"
 )
 
@@ -64,3 +65,46 @@ func ToKey(r Recipient) Key {
 		panic(fmt.Sprintf("unexpected recipient type: %T", r))
 	}
 }
+
+// Membership describes through which group, schedule and rotation a Recipient resolved to a specific Contact.
+//
+// All members are optional and only set if they were part of the resolution, e.g., a Contact being directly used as a
+// Recipient results in an empty Membership.
+type Membership struct {
+	Group    *Group
+	Schedule *Schedule
+	Rotation *Rotation
+}
+
+// GetMembershipAt checks whether the Recipient resolves to the Contact at the given time and explains how.
+//
+// If the Contact is not part of the Recipient at this time, nil is returned.
+func GetMembershipAt(r Recipient, contact *Contact, t time.Time) *Membership {
+	switch v := r.(type) {
+	case *Contact:
+		if v.ID == contact.ID {
+			return &Membership{}
+		}
+	case *Group:
+		for _, member := range v.GetContactsAt(t) {
+			if member.ID == contact.ID {
+				return &Membership{Group: v}
+			}
+		}
+	case *Schedule:
+		if !slices.ContainsFunc(v.GetContactsAt(t), func(c *Contact) bool { return c.ID == contact.ID }) {
+			return nil
+		}
+
+		// The contact might also be on call due to an override, taking over the currently active rotation.
+		m := &Membership{Schedule: v}
+		rotation, member := v.rotationResolver.getActiveMemberAt(t)
+		m.Rotation = rotation
+		if member != nil && member.ContactGroup != nil && member.ContactGroup.hasMember(contact) {
+			m.Group = member.ContactGroup
+		}
+		return m
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package recipient
+
+import (
+	"database/sql"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestGetMembershipAt(t *testing.T) {
+	newContact := func(id int64, name string) *Contact {
+		return &Contact{IncrementalPkDbEntry: baseconf.IncrementalPkDbEntry[int64]{ID: id}, FullName: name}
+	}
+
+	alice := newContact(1, "Alice")
+	bob := newContact(2, "Bob")
+	carol := newContact(3, "Carol")
+
+	group := &Group{Name: "Team DB", Members: []*Contact{bob}}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rotation := &Rotation{
+		Name:          "Primary",
+		ActualHandoff: types.UnixMilli(start),
+		Priority:      sql.NullInt32{Int32: 0, Valid: true},
+		Members: []*RotationMember{
+			{
+				Contact: alice,
+				TimePeriodEntries: map[int64]*timeperiod.Entry{1: {
+					StartTime: types.UnixMilli(start),
+					EndTime:   types.UnixMilli(start.Add(12 * time.Hour)),
+					Timezone:  "UTC",
+					RRule:     sql.NullString{String: "FREQ=DAILY", Valid: true},
+				}},
+			},
+			{
+				ContactGroup: group,
+				TimePeriodEntries: map[int64]*timeperiod.Entry{2: {
+					StartTime: types.UnixMilli(start.Add(12 * time.Hour)),
+					EndTime:   types.UnixMilli(start.Add(24 * time.Hour)),
+					Timezone:  "UTC",
+					RRule:     sql.NullString{String: "FREQ=DAILY", Valid: true},
+				}},
+			},
+		},
+	}
+	for _, member := range rotation.Members {
+		for _, entry := range member.TimePeriodEntries {
+			require.NoError(t, entry.Init())
+		}
+	}
+
+	schedule := &Schedule{Name: "DB On-Call", Rotations: []*Rotation{rotation}}
+	schedule.RefreshRotations()
+
+	morning := start.Add(48*time.Hour + 6*time.Hour)
+	evening := start.Add(48*time.Hour + 18*time.Hour)
+
+	assert.Equal(t, &Membership{}, GetMembershipAt(alice, alice, morning))
+	assert.Nil(t, GetMembershipAt(alice, bob, morning))
+
+	assert.Equal(t, &Membership{Group: group}, GetMembershipAt(group, bob, morning))
+	assert.Nil(t, GetMembershipAt(group, alice, morning))
+
+	assert.Equal(t, &Membership{Schedule: schedule, Rotation: rotation}, GetMembershipAt(schedule, alice, morning))
+	assert.Nil(t, GetMembershipAt(schedule, bob, morning))
+	assert.Equal(t, &Membership{Group: group, Schedule: schedule, Rotation: rotation},
+		GetMembershipAt(schedule, bob, evening))
+	assert.Nil(t, GetMembershipAt(schedule, alice, evening))
+	assert.Nil(t, GetMembershipAt(schedule, carol, evening))
+}
+
+func TestSchedule_GetContactsAt_Overrides(t *testing.T) {
+	newContact := func(id int64, name string) *Contact {
+		return &Contact{IncrementalPkDbEntry: baseconf.IncrementalPkDbEntry[int64]{ID: id}, FullName: name}
+	}
+
+	alice := newContact(1, "Alice")
+	bob := newContact(2, "Bob")
+	carol := newContact(3, "Carol")
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := &timeperiod.Entry{
+		StartTime: types.UnixMilli(start),
+		EndTime:   types.UnixMilli(start.Add(24 * time.Hour)),
+		Timezone:  "UTC",
+		RRule:     sql.NullString{String: "FREQ=DAILY", Valid: true},
+	}
+	require.NoError(t, entry.Init())
+
+	rotation := &Rotation{
+		Name:          "Primary",
+		ActualHandoff: types.UnixMilli(start),
+		Priority:      sql.NullInt32{Int32: 0, Valid: true},
+		Members: []*RotationMember{{
+			ContactGroup:      &Group{Name: "Team", Members: []*Contact{alice, bob}},
+			TimePeriodEntries: map[int64]*timeperiod.Entry{1: entry},
+		}},
+	}
+
+	newOverride := func(id int64, contact *Contact, replaced *Contact, from, to time.Time) *ScheduleOverride {
+		override := &ScheduleOverride{
+			IncrementalPkDbEntry: baseconf.IncrementalPkDbEntry[int64]{ID: id},
+			ContactID:            contact.ID,
+			Contact:              contact,
+			StartTime:            types.UnixMilli(from),
+			EndTime:              types.UnixMilli(to),
+		}
+		if replaced != nil {
+			override.ReplacedContactID = sql.NullInt64{Int64: replaced.ID, Valid: true}
+		}
+		return override
+	}
+
+	day := func(d int) time.Time { return start.AddDate(0, 0, d) }
+
+	schedule := &Schedule{
+		Name:      "On-Call",
+		Rotations: []*Rotation{rotation},
+		Overrides: []*ScheduleOverride{
+			// Carol covers for Alice on the 3rd day.
+			newOverride(1, carol, alice, day(2), day(3)),
+			// Bob covers for Alice on the 5th day, being already on call himself.
+			newOverride(2, bob, alice, day(4), day(5)),
+			// Carol takes over the whole schedule on the 7th day.
+			newOverride(3, carol, nil, day(6), day(7)),
+		},
+	}
+	schedule.RefreshRotations()
+
+	assert.Equal(t, []*Contact{alice, bob}, schedule.GetContactsAt(day(1).Add(time.Hour)))
+	assert.Equal(t, []*Contact{carol, bob}, schedule.GetContactsAt(day(2).Add(time.Hour)))
+	assert.Equal(t, []*Contact{alice, bob}, schedule.GetContactsAt(day(3)), "override end is exclusive")
+	assert.Equal(t, []*Contact{bob}, schedule.GetContactsAt(day(4).Add(time.Hour)))
+	assert.Equal(t, []*Contact{carol}, schedule.GetContactsAt(day(6).Add(time.Hour)))
+
+	assert.Equal(t, &Membership{Schedule: schedule, Rotation: rotation},
+		GetMembershipAt(schedule, carol, day(2).Add(time.Hour)))
+	assert.Nil(t, GetMembershipAt(schedule, alice, day(2).Add(time.Hour)))
+}
@@ -92,28 +92,39 @@ func (r *rotationResolver) getRotationsAt(t time.Time) []*Rotation {
 	return rotations
 }
 
-// getContactsAt evaluates the rotations by priority and returns all contacts active at the given time.
-func (r *rotationResolver) getContactsAt(t time.Time) []*Contact {
+// getActiveMemberAt evaluates the rotations by priority and returns the active rotation and its member at the given
+// time. If no rotation member is active, both return values are nil.
+func (r *rotationResolver) getActiveMemberAt(t time.Time) (*Rotation, *RotationMember) {
 	rotations := r.getRotationsAt(t)
 	for _, rotation := range rotations {
 		for _, member := range rotation.Members {
 			for _, entry := range member.TimePeriodEntries {
 				if entry.Contains(t) {
-					var contacts []*Contact
-
-					if member.Contact != nil {
-						contacts = append(contacts, member.Contact)
-					}
-
-					if member.ContactGroup != nil {
-						contacts = append(contacts, member.ContactGroup.Members...)
-					}
-
-					return contacts
+					return rotation, member
 				}
 			}
 		}
 	}
 
-	return nil
+	return nil, nil
+}
+
+// getContactsAt evaluates the rotations by priority and returns all contacts active at the given time.
+func (r *rotationResolver) getContactsAt(t time.Time) []*Contact {
+	_, member := r.getActiveMemberAt(t)
+	if member == nil {
+		return nil
+	}
+
+	var contacts []*Contact
+
+	if member.Contact != nil {
+		contacts = append(contacts, member.Contact)
+	}
+
+	if member.ContactGroup != nil {
+		contacts = append(contacts, member.ContactGroup.Members...)
+	}
+
+	return contacts
 }
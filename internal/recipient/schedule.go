@@ -2,20 +2,50 @@ package recipient
 
 import (
 	"database/sql"
+	"fmt"
 	"github.com/icinga/icinga-go-library/types"
 	"github.com/icinga/icinga-notifications/internal/config/baseconf"
 	"github.com/icinga/icinga-notifications/internal/timeperiod"
 	"go.uber.org/zap/zapcore"
+	"slices"
+	"sync/atomic"
 	"time"
 )
 
 type Schedule struct {
 	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+	baseconf.TenantEntry                 `db:",inline"`
 
-	Name string `db:"name"`
+	Name       string       `db:"name"`
+	IcsFeedURL types.String `db:"ics_feed_url"`
 
-	Rotations        []*Rotation `db:"-"`
+	Rotations        []*Rotation         `db:"-"`
+	Overrides        []*ScheduleOverride `db:"-"`
 	rotationResolver rotationResolver
+
+	// importedShifts are populated from the IcsFeedURL and might be updated concurrently to the configuration.
+	importedShifts atomic.Pointer[[]*ImportedShift]
+}
+
+// ImportedShift is an on-call shift of a Contact, imported from a Schedule's iCalendar feed.
+type ImportedShift struct {
+	Contact *Contact
+	Entry   *timeperiod.Entry
+}
+
+// SetImportedShifts replaces all shifts previously imported from the schedule's iCalendar feed.
+//
+// In contrast to all other members, this method might be called without holding the configuration lock.
+func (s *Schedule) SetImportedShifts(shifts []*ImportedShift) {
+	s.importedShifts.Store(&shifts)
+}
+
+// GetImportedShifts returns all shifts imported from the schedule's iCalendar feed.
+func (s *Schedule) GetImportedShifts() []*ImportedShift {
+	if shifts := s.importedShifts.Load(); shifts != nil {
+		return *shifts
+	}
+	return nil
 }
 
 // RefreshRotations updates the internally cached rotations.
@@ -78,9 +108,122 @@ func (r *RotationMember) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 	return nil
 }
 
+// ScheduleOverride temporarily assigns a Contact to a Schedule between StartTime and EndTime.
+//
+// If ReplacedContactID is set, the Contact only takes over the shifts of the replaced contact. Otherwise, the Contact
+// takes over the whole schedule.
+type ScheduleOverride struct {
+	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+
+	ScheduleID        int64           `db:"schedule_id"`
+	ContactID         int64           `db:"contact_id"`
+	Contact           *Contact        `db:"-"`
+	ReplacedContactID sql.NullInt64   `db:"replaced_contact_id"`
+	StartTime         types.UnixMilli `db:"start_time"`
+	EndTime           types.UnixMilli `db:"end_time"`
+}
+
+// IncrementalInitAndValidate implements the config.IncrementalConfigurableInitAndValidatable interface.
+func (o *ScheduleOverride) IncrementalInitAndValidate() error {
+	if !o.StartTime.Time().Before(o.EndTime.Time()) {
+		return fmt.Errorf("schedule override start time %v is not before its end time %v",
+			o.StartTime.Time(), o.EndTime.Time())
+	}
+	return nil
+}
+
+func (o *ScheduleOverride) TableName() string {
+	return "schedule_override"
+}
+
+// MarshalLogObject implements the zapcore.ObjectMarshaler interface.
+func (o *ScheduleOverride) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddInt64("id", o.ID)
+	encoder.AddInt64("schedule_id", o.ScheduleID)
+	encoder.AddInt64("contact_id", o.ContactID)
+	if o.ReplacedContactID.Valid {
+		encoder.AddInt64("replaced_contact_id", o.ReplacedContactID.Int64)
+	}
+	encoder.AddTime("start_time", o.StartTime.Time())
+	encoder.AddTime("end_time", o.EndTime.Time())
+	return nil
+}
+
+// Contains returns whether the override is active at the given time.
+func (o *ScheduleOverride) Contains(t time.Time) bool {
+	return !t.Before(o.StartTime.Time()) && t.Before(o.EndTime.Time())
+}
+
 // GetContactsAt returns the contacts that are active in the schedule at the given time.
+//
+// First, the rotations are evaluated and complemented by the shifts imported from the iCalendar feed. Afterwards, all
+// active overrides are applied in order of their creation, either replacing a single contact or the whole schedule.
 func (s *Schedule) GetContactsAt(t time.Time) []*Contact {
-	return s.rotationResolver.getContactsAt(t)
+	contacts := s.rotationResolver.getContactsAt(t)
+
+	for _, shift := range s.GetImportedShifts() {
+		if shift.Entry.Contains(t) && !slices.ContainsFunc(contacts, func(c *Contact) bool { return c.ID == shift.Contact.ID }) {
+			contacts = append(contacts, shift.Contact)
+		}
+	}
+
+	for _, override := range s.Overrides {
+		if override.Contact == nil || !override.Contains(t) {
+			continue
+		}
+
+		if !override.ReplacedContactID.Valid {
+			contacts = []*Contact{override.Contact}
+			continue
+		}
+
+		replaced := make([]*Contact, 0, len(contacts))
+		for _, contact := range contacts {
+			if contact.ID == override.ReplacedContactID.Int64 {
+				contact = override.Contact
+			}
+			if !slices.ContainsFunc(replaced, func(c *Contact) bool { return c.ID == contact.ID }) {
+				replaced = append(replaced, contact)
+			}
+		}
+		contacts = replaced
+	}
+
+	return contacts
+}
+
+// NextTransition returns a time strictly after the given base time when the contacts of the schedule may change, e.g.,
+// by a rotation handoff, a shift starting or ending or an override.
+//
+// Like timeperiod.TimePeriod.NextTransition, the returned time is at most a day after base and a change is not
+// guaranteed to happen at it.
+func (s *Schedule) NextTransition(base time.Time) time.Time {
+	transition := base.Add(24 * time.Hour)
+	consider := func(t time.Time) {
+		if t.After(base) && t.Before(transition) {
+			transition = t
+		}
+	}
+
+	for _, rotation := range s.Rotations {
+		consider(rotation.ActualHandoff.Time())
+		for _, member := range rotation.Members {
+			for _, entry := range member.TimePeriodEntries {
+				consider(entry.NextTransition(base))
+			}
+		}
+	}
+
+	for _, shift := range s.GetImportedShifts() {
+		consider(shift.Entry.NextTransition(base))
+	}
+
+	for _, override := range s.Overrides {
+		consider(override.StartTime.Time())
+		consider(override.EndTime.Time())
+	}
+
+	return transition
 }
 
 func (s *Schedule) String() string {
@@ -0,0 +1,39 @@
+package recipient
+
+import (
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"slices"
+	"time"
+)
+
+// Shift is a range of time during which the same contacts are on call in a schedule, see Schedule.Simulate.
+type Shift struct {
+	Start    time.Time       `json:"start"`
+	End      time.Time       `json:"end"`
+	Contacts []*ShiftContact `json:"contacts"`
+}
+
+// ShiftContact is a contact being on call during a Shift.
+type ShiftContact struct {
+	ID       int64  `json:"id"`
+	FullName string `json:"full_name"`
+	Username string `json:"username,omitempty"`
+}
+
+// Simulate returns the consecutive shifts from from to to, including those nobody is on call during.
+func (s *Schedule) Simulate(from, to time.Time) []*Shift {
+	spans := timeperiod.Simulate(from, to, s.NextTransition, s.GetContactsAt, func(a, b []*Contact) bool {
+		return slices.EqualFunc(a, b, func(a, b *Contact) bool { return a.ID == b.ID })
+	})
+
+	shifts := make([]*Shift, 0, len(spans))
+	for _, span := range spans {
+		shift := &Shift{Start: span.Start, End: span.End, Contacts: []*ShiftContact{}}
+		for _, c := range span.Value {
+			shift.Contacts = append(shift.Contacts, &ShiftContact{ID: c.ID, FullName: c.FullName, Username: c.Username.String})
+		}
+		shifts = append(shifts, shift)
+	}
+
+	return shifts
+}
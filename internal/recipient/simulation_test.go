@@ -0,0 +1,68 @@
+package recipient
+
+import (
+	"database/sql"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSchedule_Simulate(t *testing.T) {
+	alice := &Contact{
+		IncrementalPkDbEntry: baseconf.IncrementalPkDbEntry[int64]{ID: 1},
+		FullName:             "Alice",
+		Username:             sql.NullString{String: "alice", Valid: true},
+	}
+	bob := &Contact{IncrementalPkDbEntry: baseconf.IncrementalPkDbEntry[int64]{ID: 2}, FullName: "Bob"}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hours := func(h int) time.Time { return start.Add(time.Duration(h) * time.Hour) }
+
+	// Alice is on call from 08:00 to 20:00 and Bob from 20:00 to 06:00, leaving a gap from 06:00 to 08:00.
+	newEntry := func(from, to time.Time) *timeperiod.Entry {
+		e := &timeperiod.Entry{
+			StartTime: types.UnixMilli(from),
+			EndTime:   types.UnixMilli(to),
+			Timezone:  "UTC",
+			RRule:     sql.NullString{String: "FREQ=DAILY", Valid: true},
+		}
+		require.NoError(t, e.Init())
+		return e
+	}
+
+	schedule := &Schedule{
+		Name: "On-Call",
+		Rotations: []*Rotation{{
+			Name:          "Primary",
+			ActualHandoff: types.UnixMilli(start),
+			Priority:      sql.NullInt32{Int32: 0, Valid: true},
+			Members: []*RotationMember{
+				{Contact: alice, TimePeriodEntries: map[int64]*timeperiod.Entry{1: newEntry(hours(8), hours(20))}},
+				{Contact: bob, TimePeriodEntries: map[int64]*timeperiod.Entry{2: newEntry(hours(20), hours(30))}},
+			},
+		}},
+		Overrides: []*ScheduleOverride{{
+			ContactID: bob.ID,
+			Contact:   bob,
+			StartTime: types.UnixMilli(hours(26)),
+			EndTime:   types.UnixMilli(hours(34)),
+		}},
+	}
+	schedule.RefreshRotations()
+
+	aliceShift := []*ShiftContact{{ID: 1, FullName: "Alice", Username: "alice"}}
+	bobShift := []*ShiftContact{{ID: 2, FullName: "Bob"}}
+
+	assert.Equal(t, []*Shift{
+		{Start: hours(0), End: hours(8), Contacts: []*ShiftContact{}},
+		{Start: hours(8), End: hours(20), Contacts: aliceShift},
+		// Bob's override starting within his own shift fills the gap and covers Alice's first hours.
+		{Start: hours(20), End: hours(34), Contacts: bobShift},
+		{Start: hours(34), End: hours(44), Contacts: aliceShift},
+		{Start: hours(44), End: hours(48), Contacts: bobShift},
+	}, schedule.Simulate(hours(0), hours(48)))
+}
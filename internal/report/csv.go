@@ -0,0 +1,51 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// csvHeader lists the columns written by WriteCSV.
+var csvHeader = []string{
+	"incident_id", "object", "incident_severity", "started_at", "recovered_at",
+	"time", "type", "description", "recipient", "channel", "rule", "notification_state", "message",
+}
+
+// WriteCSV writes the report's timeline as CSV, one row per entry, each repeating the incident's columns.
+//
+// This flat layout can be imported into IT service management tools, e.g., as ServiceNow import set or Jira issue
+// comments, without any further transformation.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	incidentColumns := []string{
+		strconv.FormatInt(r.Incident.ID, 10),
+		r.Incident.ObjectName,
+		r.Incident.Severity.String(),
+		formatTime(r.Incident.StartedAt.Time()),
+		formatTime(r.Incident.RecoveredAt.Time()),
+	}
+
+	for _, e := range r.Timeline {
+		row := append(append([]string(nil), incidentColumns...),
+			formatTime(e.Time.Time()),
+			e.Type,
+			e.Description(),
+			e.Recipient.String,
+			e.Channel.String,
+			e.Rule.String,
+			e.NotificationState.String,
+			e.Message.String,
+		)
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
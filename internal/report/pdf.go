@@ -0,0 +1,227 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// pdfPageWidth and pdfPageHeight are the dimensions of an A4 page in points.
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+
+	// pdfFontSize is the size of the monospaced Courier font, allowing to wrap lines exactly by their length.
+	pdfFontSize    = 9
+	pdfLineHeight  = 12
+	pdfCharsPerRow = (pdfPageWidth - 2*pdfMargin) * 10 / (pdfFontSize * 6) // Courier glyphs are 0.6 em wide.
+)
+
+// pdfLine is a single line of text positioned on a page.
+type pdfLine struct {
+	y    float64
+	bold bool
+	size float64
+	text string
+}
+
+// pdfDocument is a minimal PDF writer for plain text documents, using the standard fonts Courier and Courier-Bold.
+//
+// It avoids depending on a PDF library for rendering a few pages of text. Characters not representable in the fonts'
+// Latin-1 encoding are replaced by "?".
+type pdfDocument struct {
+	pages [][]pdfLine
+	y     float64
+}
+
+// line adds a line of text, wrapped to the page width, starting a new page when the current one is full.
+func (d *pdfDocument) line(text string, bold bool) {
+	d.styledLine(text, bold, pdfFontSize)
+}
+
+// heading adds a bold line of a larger font size, preceded by some space.
+func (d *pdfDocument) heading(text string) {
+	d.space()
+	d.styledLine(text, true, pdfFontSize+3)
+}
+
+// space adds an empty line, unless at the top of a page.
+func (d *pdfDocument) space() {
+	if len(d.pages) > 0 && d.y < pdfPageHeight-pdfMargin {
+		d.y -= pdfLineHeight
+	}
+}
+
+func (d *pdfDocument) styledLine(text string, bold bool, size float64) {
+	for _, wrapped := range wrap(text, int(float64(pdfCharsPerRow)*pdfFontSize/size)) {
+		if len(d.pages) == 0 || d.y < pdfMargin+pdfLineHeight {
+			d.pages = append(d.pages, nil)
+			d.y = pdfPageHeight - pdfMargin
+		}
+
+		d.y -= size + 3
+		page := &d.pages[len(d.pages)-1]
+		*page = append(*page, pdfLine{y: d.y, bold: bold, size: size, text: wrapped})
+	}
+}
+
+// wrap splits the text into lines of at most width characters, preferring to break at spaces.
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		runes := []rune(paragraph)
+		for len(runes) > width {
+			cut := width
+			for i := width - 1; i > width/2; i-- {
+				if runes[i] == ' ' {
+					cut = i + 1
+					break
+				}
+			}
+			lines = append(lines, strings.TrimRight(string(runes[:cut]), " "))
+			runes = runes[cut:]
+		}
+		lines = append(lines, string(runes))
+	}
+
+	return lines
+}
+
+// escapePDFString escapes text for a PDF string literal and replaces characters outside of Latin-1.
+func escapePDFString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r < 0x20 || r > 0xff:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+
+	return b.String()
+}
+
+// WriteTo writes the document as PDF, implementing the io.WriterTo interface.
+func (d *pdfDocument) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		_, _ = fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// Objects 1 to 4 are fixed, followed by a page and its content stream for each page.
+	var kids []string
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+
+		var content strings.Builder
+		for _, l := range page {
+			font := "F1"
+			if l.bold {
+				font = "F2"
+			}
+			_, _ = fmt.Fprintf(&content, "BT /%s %g Tf %d %g Td (%s) Tj ET\n",
+				font, l.size, pdfMargin, l.y, escapePDFString(l.text))
+		}
+		_, _ = fmt.Fprintf(&content, "BT /F1 7 Tf %d %d Td (Page %d of %d) Tj ET\n",
+			pdfMargin, pdfMargin/2, i+1, len(d.pages))
+
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	_, _ = fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		_, _ = fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	_, _ = fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.WriteTo(w)
+}
+
+// WritePDF writes the report as a PDF document, consisting of the incident's summary, participants and timeline.
+func (r *Report) WritePDF(w io.Writer) error {
+	doc := &pdfDocument{}
+	doc.styledLine(fmt.Sprintf("Incident #%d: %s", r.Incident.ID, r.Incident.ObjectName), true, pdfFontSize+5)
+	doc.line(fmt.Sprintf("Generated at %s", formatTime(r.GeneratedAt)), false)
+
+	doc.heading("Summary")
+	field := func(label, value string) {
+		doc.line(fmt.Sprintf("%-15s %s", label+":", value), false)
+	}
+
+	recovered := formatTime(r.Incident.RecoveredAt.Time())
+	if recovered == "" {
+		recovered = "still open"
+	}
+	field("Severity", r.Incident.Severity.String())
+	field("Started at", formatTime(r.Incident.StartedAt.Time()))
+	field("Recovered at", recovered)
+	field("Duration", formatDuration(r.Duration()))
+	if d, ok := r.TimeToFirstNotification(); ok {
+		field("Notified", "after "+formatDuration(d))
+	}
+	if d, ok := r.TimeToAcknowledge(); ok {
+		field("Acknowledged", "after "+formatDuration(d))
+	}
+
+	counts := r.NotificationCounts()
+	var states []string
+	for _, state := range []string{"sent", "failed", "pending", "suppressed", "throttled"} {
+		if counts[state] > 0 {
+			states = append(states, fmt.Sprintf("%d %s", counts[state], state))
+		}
+	}
+	if len(states) > 0 {
+		field("Notifications", strings.Join(states, ", "))
+	}
+
+	doc.heading("Participants")
+	if len(r.Participants) == 0 {
+		doc.line("none", false)
+	}
+	for _, p := range r.Participants {
+		doc.line(fmt.Sprintf("%-10s %-12s %s", p.Role, p.Kind, p.Name.String), false)
+	}
+
+	doc.heading("Timeline")
+	for _, e := range r.Timeline {
+		doc.line(fmt.Sprintf("%s  %s", formatTime(e.Time.Time()), e.Description()), false)
+		if e.Message.Valid && e.Message.String != "" {
+			for _, l := range wrap(e.Message.String, pdfCharsPerRow-4) {
+				doc.line("    "+l, false)
+			}
+		}
+	}
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// formatDuration rounds the duration to seconds for a concise representation.
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
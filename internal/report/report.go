@@ -0,0 +1,312 @@
+// Package report renders incident reports, e.g., to be attached to tickets of IT service management tools.
+//
+// A Report consists of the incident itself, its participants and its timeline, i.e., its whole history including all
+// notifications. It can be rendered as CSV, one row per timeline entry for an import into tools like ServiceNow or
+// Jira, or as a PDF document for compliance records.
+package report
+
+import (
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/archive"
+	"github.com/icinga/icinga-notifications/internal/incident"
+	"github.com/jmoiron/sqlx"
+	"io"
+	"strings"
+	"time"
+)
+
+// Formats supported by Render, mapped to their content types.
+var Formats = map[string]string{
+	"csv": "text/csv; charset=utf-8",
+	"pdf": "application/pdf",
+}
+
+// Participant is a recipient of the incident together with its final role.
+type Participant struct {
+	Kind string       `db:"kind"`
+	Name types.String `db:"name"`
+	Role string       `db:"role"`
+}
+
+// Entry is a single entry of the incident's timeline, i.e., a row of its history with the names of referenced objects.
+type Entry struct {
+	Time              types.UnixMilli `db:"time"`
+	Type              string          `db:"type"`
+	NewSeverity       types.String    `db:"new_severity"`
+	OldSeverity       types.String    `db:"old_severity"`
+	NewRecipientRole  types.String    `db:"new_recipient_role"`
+	OldRecipientRole  types.String    `db:"old_recipient_role"`
+	NotificationState types.String    `db:"notification_state"`
+	Recipient         types.String    `db:"recipient"`
+	Channel           types.String    `db:"channel"`
+	Rule              types.String    `db:"rule"`
+	Message           types.String    `db:"message"`
+}
+
+// Description summarizes the entry in a human-readable form, e.g., "notified Jane Doe via Email (sent)".
+func (e *Entry) Description() string {
+	var b strings.Builder
+	b.WriteString(strings.ReplaceAll(e.Type, "_", " "))
+
+	switch {
+	case e.OldSeverity.Valid && e.NewSeverity.Valid:
+		_, _ = fmt.Fprintf(&b, " from %s to %s", e.OldSeverity.String, e.NewSeverity.String)
+	case e.NewSeverity.Valid:
+		_, _ = fmt.Fprintf(&b, " with severity %s", e.NewSeverity.String)
+	}
+
+	if e.Rule.Valid {
+		_, _ = fmt.Fprintf(&b, " by rule %s", e.Rule.String)
+	}
+	if e.Recipient.Valid {
+		_, _ = fmt.Fprintf(&b, ": %s", e.Recipient.String)
+	}
+	if e.OldRecipientRole.Valid || e.NewRecipientRole.Valid {
+		_, _ = fmt.Fprintf(&b, " from %s to %s", orNone(e.OldRecipientRole), orNone(e.NewRecipientRole))
+	}
+	if e.Channel.Valid {
+		_, _ = fmt.Fprintf(&b, " via %s", e.Channel.String)
+	}
+	if e.NotificationState.Valid {
+		_, _ = fmt.Fprintf(&b, " (%s)", e.NotificationState.String)
+	}
+
+	return b.String()
+}
+
+// orNone returns the string or "none" if it is NULL.
+func orNone(s types.String) string {
+	if !s.Valid {
+		return "none"
+	}
+
+	return s.String
+}
+
+// Report of a single incident, see Load.
+type Report struct {
+	Incident     *incident.Summary
+	Participants []*Participant
+	Timeline     []*Entry
+
+	// GeneratedAt is the time the report was loaded, used as the end of open incidents for all durations.
+	GeneratedAt time.Time
+
+	// Archived is set if the timeline was merged with the archive, see incident.LoadArchivedHistory.
+	Archived bool
+}
+
+// Duration returns how long the incident was open, up to the report's generation for open incidents.
+func (r *Report) Duration() time.Duration {
+	end := r.Incident.RecoveredAt.Time()
+	if end.IsZero() {
+		end = r.GeneratedAt
+	}
+
+	return end.Sub(r.Incident.StartedAt.Time())
+}
+
+// TimeToFirstNotification returns how long it took until the first notification was sent, or false if none was.
+func (r *Report) TimeToFirstNotification() (time.Duration, bool) {
+	for _, e := range r.Timeline {
+		if e.NotificationState.Valid && e.NotificationState.String == "sent" {
+			return e.Time.Time().Sub(r.Incident.StartedAt.Time()), true
+		}
+	}
+
+	return 0, false
+}
+
+// TimeToAcknowledge returns how long it took until the incident got its first manager, or false if it never had one.
+func (r *Report) TimeToAcknowledge() (time.Duration, bool) {
+	for _, e := range r.Timeline {
+		if e.Type == "recipient_role_changed" && e.NewRecipientRole.Valid && e.NewRecipientRole.String == "manager" {
+			return e.Time.Time().Sub(r.Incident.StartedAt.Time()), true
+		}
+	}
+
+	return 0, false
+}
+
+// NotificationCounts returns the number of notifications per state, e.g., "sent" or "failed".
+func (r *Report) NotificationCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, e := range r.Timeline {
+		if e.NotificationState.Valid {
+			counts[e.NotificationState.String]++
+		}
+	}
+
+	return counts
+}
+
+// Load the report of the incident of the given ID from the database, merged with the archive if given and necessary,
+// see incident.LoadArchivedHistory. The participants of incidents deleted by the retention aren't archived, thus
+// their reports only consist of the timeline.
+//
+// An error wrapping incident.ErrIncidentNotFound is returned for an unknown incident.
+func Load(ctx context.Context, db *database.DB, a *archive.Archiver, incidentID int64) (*Report, error) {
+	summary, history, archived, err := incident.LoadArchivedHistory(ctx, db, a, incidentID)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{Incident: summary, GeneratedAt: time.Now(), Archived: archived}
+
+	stmt := db.Rebind(`SELECT CASE
+				WHEN ic."contact_id" IS NOT NULL THEN 'contact'
+				WHEN ic."contactgroup_id" IS NOT NULL THEN 'group'
+				ELSE 'schedule'
+			END AS "kind",
+			COALESCE(c."full_name", cg."name", s."name") AS "name", ic."role"
+		FROM "incident_contact" ic
+		LEFT JOIN "contact" c ON c."id" = ic."contact_id"
+		LEFT JOIN "contactgroup" cg ON cg."id" = ic."contactgroup_id"
+		LEFT JOIN "schedule" s ON s."id" = ic."schedule_id"
+		WHERE ic."incident_id" = ?
+		ORDER BY "kind", "name"`)
+	if err := db.SelectContext(ctx, &r.Participants, stmt, incidentID); err != nil {
+		return nil, fmt.Errorf("cannot fetch incident participants: %w", err)
+	}
+
+	if archived {
+		if r.Timeline, err = loadTimeline(ctx, db, history); err != nil {
+			return nil, err
+		}
+
+		return r, nil
+	}
+
+	stmt = db.Rebind(`SELECT h."time", h."type", h."new_severity", h."old_severity", h."new_recipient_role",
+			h."old_recipient_role", h."notification_state", h."message",
+			COALESCE(c."full_name", cg."name", s."name") AS "recipient", ch."name" AS "channel", ru."name" AS "rule"
+		FROM "incident_history" h
+		LEFT JOIN "contact" c ON c."id" = h."contact_id"
+		LEFT JOIN "contactgroup" cg ON cg."id" = h."contactgroup_id"
+		LEFT JOIN "schedule" s ON s."id" = h."schedule_id"
+		LEFT JOIN "channel" ch ON ch."id" = h."channel_id"
+		LEFT JOIN "rule" ru ON ru."id" = h."rule_id"
+		WHERE h."incident_id" = ?
+		ORDER BY h."time", h."id"`)
+	if err := db.SelectContext(ctx, &r.Timeline, stmt, incidentID); err != nil {
+		return nil, fmt.Errorf("cannot fetch incident timeline: %w", err)
+	}
+
+	return r, nil
+}
+
+// loadTimeline converts the history entries, e.g., read from the archive, into timeline entries, fetching the names of
+// the recipients, channels and rules referenced from the database. References to objects deleted since are left NULL.
+func loadTimeline(ctx context.Context, db *database.DB, history []*incident.HistoryEntry) ([]*Entry, error) {
+	references := map[string]func(*incident.HistoryEntry) types.Int{
+		"contact":      func(h *incident.HistoryEntry) types.Int { return h.ContactID },
+		"contactgroup": func(h *incident.HistoryEntry) types.Int { return h.ContactGroupID },
+		"schedule":     func(h *incident.HistoryEntry) types.Int { return h.ScheduleID },
+		"channel":      func(h *incident.HistoryEntry) types.Int { return h.ChannelID },
+		"rule":         func(h *incident.HistoryEntry) types.Int { return h.RuleID },
+	}
+
+	names := make(map[string]map[int64]types.String, len(references))
+	for table, reference := range references {
+		var ids []int64
+		for _, h := range history {
+			if id := reference(h); id.Valid {
+				ids = append(ids, id.Int64)
+			}
+		}
+
+		column := "name"
+		if table == "contact" {
+			column = "full_name"
+		}
+
+		var err error
+		if names[table], err = fetchNames(ctx, db, table, column, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	name := func(table string, id types.Int) types.String {
+		return names[table][id.Int64]
+	}
+
+	timeline := make([]*Entry, 0, len(history))
+	for _, h := range history {
+		recipient := name("contact", h.ContactID)
+		if !recipient.Valid {
+			recipient = name("contactgroup", h.ContactGroupID)
+		}
+		if !recipient.Valid {
+			recipient = name("schedule", h.ScheduleID)
+		}
+
+		timeline = append(timeline, &Entry{
+			Time:              h.Time,
+			Type:              h.Type,
+			NewSeverity:       h.NewSeverity,
+			OldSeverity:       h.OldSeverity,
+			NewRecipientRole:  h.NewRecipientRole,
+			OldRecipientRole:  h.OldRecipientRole,
+			NotificationState: h.NotificationState,
+			Recipient:         recipient,
+			Channel:           name("channel", h.ChannelID),
+			Rule:              name("rule", h.RuleID),
+			Message:           h.Message,
+		})
+	}
+
+	return timeline, nil
+}
+
+// fetchNames fetches the names of the rows of the given IDs, keyed by their ID.
+func fetchNames(
+	ctx context.Context, db *database.DB, table, column string, ids []int64,
+) (map[int64]types.String, error) {
+	names := make(map[int64]types.String)
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	stmt := fmt.Sprintf(`SELECT "id", "%s" AS "name" FROM "%s" WHERE "id" IN (?)`, column, table)
+	query, args, err := sqlx.In(stmt, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		ID   int64        `db:"id"`
+		Name types.String `db:"name"`
+	}
+	if err := db.SelectContext(ctx, &rows, db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("cannot fetch names of table %q: %w", table, err)
+	}
+	for _, row := range rows {
+		names[row.ID] = row.Name
+	}
+
+	return names, nil
+}
+
+// Render writes the report in the given format, which must be a key of Formats.
+func (r *Report) Render(w io.Writer, format string) error {
+	switch format {
+	case "csv":
+		return r.WriteCSV(w)
+	case "pdf":
+		return r.WritePDF(w)
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+}
+
+// formatTime formats timestamps uniformly across all formats, leaving zero times empty.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,144 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/incident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := func(offset time.Duration, typ string, e *Entry) *Entry {
+		e.Time = types.UnixMilli(start.Add(offset))
+		e.Type = typ
+		return e
+	}
+
+	return &Report{
+		Incident: &incident.Summary{
+			ID:         42,
+			ObjectName: "example.com!ping",
+			StartedAt:  types.UnixMilli(start),
+			Severity:   event.SeverityCrit,
+		},
+		Participants: []*Participant{{Kind: "contact", Name: types.MakeString("Jane Doe"), Role: "manager"}},
+		Timeline: []*Entry{
+			entry(0, "opened", &Entry{NewSeverity: types.MakeString("crit"), Message: types.MakeString("PING (CRITICAL)")}),
+			entry(time.Minute, "notified", &Entry{
+				Recipient:         types.MakeString("Jane Doe"),
+				Channel:           types.MakeString("Email"),
+				NotificationState: types.MakeString("sent"),
+			}),
+			entry(time.Minute, "notified", &Entry{
+				Recipient:         types.MakeString("John Doe"),
+				Channel:           types.MakeString("Webhook"),
+				NotificationState: types.MakeString("failed"),
+			}),
+			entry(5*time.Minute, "recipient_role_changed", &Entry{
+				Recipient:        types.MakeString("Jane Doe"),
+				OldRecipientRole: types.MakeString("recipient"),
+				NewRecipientRole: types.MakeString("manager"),
+			}),
+		},
+		GeneratedAt: start.Add(time.Hour),
+	}
+}
+
+func TestEntry_Description(t *testing.T) {
+	t.Parallel()
+
+	r := testReport()
+	assert.Equal(t, "opened with severity crit", r.Timeline[0].Description())
+	assert.Equal(t, "notified: Jane Doe via Email (sent)", r.Timeline[1].Description())
+	assert.Equal(t, "recipient role changed: Jane Doe from recipient to manager", r.Timeline[3].Description())
+	assert.Equal(t, "escalation triggered by rule Ping",
+		(&Entry{Type: "escalation_triggered", Rule: types.MakeString("Ping")}).Description())
+}
+
+func TestReport_Durations(t *testing.T) {
+	t.Parallel()
+
+	r := testReport()
+	assert.Equal(t, time.Hour, r.Duration(), "open incidents should last until the report's generation")
+
+	d, ok := r.TimeToFirstNotification()
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, d)
+
+	d, ok = r.TimeToAcknowledge()
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Minute, d)
+
+	assert.Equal(t, map[string]int{"sent": 1, "failed": 1}, r.NotificationCounts())
+
+	r.Incident.RecoveredAt = types.UnixMilli(r.Incident.StartedAt.Time().Add(10 * time.Minute))
+	r.Timeline = r.Timeline[:1]
+	assert.Equal(t, 10*time.Minute, r.Duration())
+
+	_, ok = r.TimeToFirstNotification()
+	assert.False(t, ok)
+	_, ok = r.TimeToAcknowledge()
+	assert.False(t, ok)
+}
+
+func TestReport_WriteCSV(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, testReport().Render(&buf, "csv"))
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+	assert.Equal(t, csvHeader, rows[0])
+	assert.Equal(t, []string{
+		"42", "example.com!ping", "crit", "2024-01-02T03:04:05Z", "",
+		"2024-01-02T03:05:05Z", "notified", "notified: Jane Doe via Email (sent)", "Jane Doe", "Email", "", "sent", "",
+	}, rows[2])
+}
+
+func TestReport_WritePDF(t *testing.T) {
+	t.Parallel()
+
+	r := testReport()
+	r.Timeline[0].Message = types.MakeString(strings.Repeat("(long) ", 50))
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Render(&buf, "pdf"))
+
+	pdf := buf.String()
+	assert.True(t, strings.HasPrefix(pdf, "%PDF-1.4\n"))
+	assert.True(t, strings.HasSuffix(pdf, "%%EOF\n"))
+	assert.Contains(t, pdf, `(Incident #42: example.com!ping) Tj`)
+	assert.Contains(t, pdf, `\(long\)`)
+	assert.Contains(t, pdf, "(Page 1 of 1) Tj")
+
+	assert.Error(t, r.Render(&buf, "xlsx"))
+}
+
+func TestWrap(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{""}, wrap("", 10))
+	assert.Equal(t, []string{"foo bar", "baz"}, wrap("foo bar baz", 10))
+	assert.Equal(t, []string{"abcdefghij", "klm"}, wrap("abcdefghijklm", 10))
+	assert.Equal(t, []string{"foo", "bar"}, wrap("foo\nbar", 10))
+
+	for _, line := range wrap(strings.Repeat("lorem ipsum ", 100), 30) {
+		assert.LessOrEqual(t, len(line), 30)
+	}
+}
+
+func TestEscapePDFString(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `a\(b\)c\\d e`, escapePDFString("a(b)c\\d\te"))
+	assert.Equal(t, "caf\xe9 ?", escapePDFString("café 😀"))
+}
@@ -0,0 +1,281 @@
+// Package retention periodically deletes old events, incidents and notifications, so that the database does not grow
+// unbounded.
+//
+// Rows are deleted in small batches, each in its own transaction limited by a timeout, so that the cleanup neither
+// blocks the processing of events for long nor piles up huge transactions. If configured, each batch is archived
+// before it is deleted, see package archive.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/archive"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/schema"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Kinds of rows deleted, see daemon.RetentionConfig.
+const (
+	KindEvents        = "events"
+	KindHistory       = "history"
+	KindNotifications = "notifications"
+)
+
+// Stats counts the rows deleted since the daemon was started, as reported by the health endpoint.
+type Stats struct {
+	// DeletedTotal by kind, counting incidents for KindHistory.
+	DeletedTotal map[string]uint64 `json:"deleted_total"`
+	// LastRun is when the last cleanup finished, or nil if none did yet.
+	LastRun *time.Time `json:"last_run,omitempty"`
+}
+
+var (
+	stats   = Stats{DeletedTotal: map[string]uint64{KindEvents: 0, KindHistory: 0, KindNotifications: 0}}
+	statsMu sync.Mutex
+)
+
+// CurrentStats returns a copy of the current Stats.
+func CurrentStats() Stats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	current := stats
+	current.DeletedTotal = maps.Clone(stats.DeletedTotal)
+
+	return current
+}
+
+// kind of rows deleted by the cleanup, each selected, archived and deleted batch by batch, see Cleaner.deleteBatch.
+type kind struct {
+	name string
+	days int
+	// query selects the IDs of up to limit rows older than cutoff, bound to the cutoff and limit in this order.
+	query string
+	// archive the selected rows before they are deleted, see archive.Archiver.
+	archive func(a *archive.Archiver, ctx context.Context, db sqlx.ExtContext, ids []int64) error
+	// delete the selected rows within tx.
+	delete func(ctx context.Context, tx *sqlx.Tx, ids []int64) error
+}
+
+// Cleaner periodically deletes the rows older than configured.
+type Cleaner struct {
+	DB     *database.DB
+	Logger *logging.Logger
+	Config daemon.RetentionConfig
+	// Archiver archives all rows before they are deleted, unless nil. Rows failing to be archived are kept.
+	Archiver *archive.Archiver
+}
+
+// Run the cleanup loop until the context is done, starting with an immediate cleanup.
+func (c *Cleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Config.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.cleanup(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// cleanup deletes all rows older than configured once.
+//
+// Incidents are deleted first, so that their notifications are archived along with them and their events are deleted
+// by the same cleanup.
+func (c *Cleaner) cleanup(ctx context.Context) {
+	if err := schema.RequireCompatible(); err != nil {
+		c.Logger.Errorw("Refusing to delete old rows", zap.Error(err))
+		return
+	}
+
+	kinds := []kind{{
+		name:    KindHistory,
+		days:    c.Config.History,
+		query:   selectIncidents,
+		archive: (*archive.Archiver).ArchiveIncidents,
+		delete:  deleteIncidents,
+	}, {
+		name:    KindNotifications,
+		days:    c.Config.Notifications,
+		query:   selectNotifications,
+		archive: (*archive.Archiver).ArchiveNotifications,
+		delete:  deleteNotifications,
+	}, {
+		name:    KindEvents,
+		days:    c.Config.Events,
+		query:   selectEvents,
+		archive: (*archive.Archiver).ArchiveEvents,
+		delete:  deleteEvents,
+	}}
+
+	for _, k := range kinds {
+		if k.days <= 0 {
+			continue
+		}
+
+		cutoff := time.Now().AddDate(0, 0, -k.days)
+		deleted, err := c.deleteBefore(ctx, k, types.UnixMilli(cutoff))
+		if err != nil {
+			c.Logger.Errorw("Cannot delete old rows", zap.String("kind", k.name), zap.Int("deleted", deleted),
+				zap.Error(err))
+		} else if deleted > 0 {
+			c.Logger.Infow("Deleted old rows", zap.String("kind", k.name), zap.Time("before", cutoff),
+				zap.Int("deleted", deleted))
+		}
+	}
+
+	now := time.Now()
+	statsMu.Lock()
+	stats.LastRun = &now
+	statsMu.Unlock()
+}
+
+// deleteBefore deletes all rows of the kind older than cutoff batch by batch, returning the number of deleted rows.
+func (c *Cleaner) deleteBefore(ctx context.Context, k kind, cutoff types.UnixMilli) (int, error) {
+	total := 0
+	for {
+		deleted, err := c.deleteBatch(ctx, k, cutoff)
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+		statsMu.Lock()
+		stats.DeletedTotal[k.name] += uint64(deleted)
+		statsMu.Unlock()
+
+		if deleted < c.Config.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// deleteBatch selects, archives and deletes a single batch, returning the number of deleted rows.
+//
+// If configured, the selected rows are archived before and outside the transaction deleting them, so that they are
+// only deleted once archived and a slow upload doesn't hold any locks. Selecting and deleting the rows are each aborted
+// after the statement timeout.
+func (c *Cleaner) deleteBatch(ctx context.Context, k kind, cutoff types.UnixMilli) (int, error) {
+	ids, err := c.selectBatch(ctx, k, cutoff)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	if c.Archiver != nil {
+		if err := k.archive(c.Archiver, ctx, c.DB, ids); err != nil {
+			return 0, fmt.Errorf("cannot archive %s: %w", k.name, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Config.StatementTimeout)
+	defer cancel()
+
+	err = utils.RunInTx(ctx, c.DB, func(tx *sqlx.Tx) error {
+		return k.delete(ctx, tx, ids)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
+// selectBatch selects the IDs of the next batch of rows older than cutoff, see kind.query.
+func (c *Cleaner) selectBatch(ctx context.Context, k kind, cutoff types.UnixMilli) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Config.StatementTimeout)
+	defer cancel()
+
+	var ids []int64
+	if err := c.DB.SelectContext(ctx, &ids, c.DB.Rebind(k.query), cutoff, c.Config.BatchSize); err != nil {
+		return nil, fmt.Errorf("cannot select old %s: %w", k.name, err)
+	}
+
+	return ids, nil
+}
+
+// selectEvents selects events not belonging to any incident, e.g., those of objects without an incident.
+const selectEvents = `SELECT "id" FROM "event" WHERE "time" < ?` +
+	` AND NOT EXISTS (SELECT 1 FROM "incident_event" WHERE "incident_event"."event_id" = "event"."id")` +
+	` AND NOT EXISTS (SELECT 1 FROM "incident_history" WHERE "incident_history"."event_id" = "event"."id")` +
+	` AND NOT EXISTS (SELECT 1 FROM "incident_annotation" WHERE "incident_annotation"."event_id" = "event"."id")` +
+	` ORDER BY "id" LIMIT ?`
+
+// deleteEvents deletes the events selected by selectEvents.
+func deleteEvents(ctx context.Context, tx *sqlx.Tx, ids []int64) error {
+	return deleteIn(ctx, tx, "event", "id", ids)
+}
+
+// selectNotifications selects the notifications of the incident history, except pending ones still to be delivered.
+const selectNotifications = `SELECT "id" FROM "incident_history"` +
+	` WHERE "time" < ? AND "type" IN ('notified', 'renotified')` +
+	` AND ("notification_state" IS NULL OR "notification_state" <> 'pending')` +
+	` ORDER BY "id" LIMIT ?`
+
+// deleteNotifications deletes the notifications selected by selectNotifications.
+func deleteNotifications(ctx context.Context, tx *sqlx.Tx, ids []int64) error {
+	if err := deleteIn(ctx, tx, "incident_history_rule", "incident_history_id", ids); err != nil {
+		return err
+	}
+
+	return deleteIn(ctx, tx, "incident_history", "id", ids)
+}
+
+// incidentTables reference incidents by their "incident_id" column, ordered so that referencing rows are deleted first.
+var incidentTables = []string{
+	"incident_event", "incident_contact", "incident_watch", "incident_channel_state", "incident_annotation",
+	"incident_rule", "incident_history", "incident_rule_escalation_state", "incident_ack_link", "incident_state",
+}
+
+// selectIncidents selects the incidents closed before cutoff.
+const selectIncidents = `SELECT "id" FROM "incident" WHERE "recovered_at" < ? ORDER BY "id" LIMIT ?`
+
+// deleteIncidents deletes the incidents selected by selectIncidents with all rows referencing them. Their events are
+// left to deleteEvents.
+func deleteIncidents(ctx context.Context, tx *sqlx.Tx, ids []int64) error {
+	query, args, err := sqlx.In(`DELETE FROM "incident_history_rule" WHERE "incident_history_id" IN (`+
+		`SELECT "id" FROM "incident_history" WHERE "incident_id" IN (?))`, ids)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, tx.Rebind(query), args...); err != nil {
+		return fmt.Errorf("cannot delete rows of table %q: %w", "incident_history_rule", err)
+	}
+
+	for _, table := range incidentTables {
+		if err := deleteIn(ctx, tx, table, "incident_id", ids); err != nil {
+			return err
+		}
+	}
+
+	return deleteIn(ctx, tx, "incident", "id", ids)
+}
+
+// deleteIn deletes the rows of the table whose column matches any of the values.
+func deleteIn(ctx context.Context, tx *sqlx.Tx, table, column string, values []int64) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In(fmt.Sprintf(`DELETE FROM "%s" WHERE "%s" IN (?)`, table, column), values)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, tx.Rebind(query), args...); err != nil {
+		return fmt.Errorf("cannot delete rows of table %q: %w", table, err)
+	}
+
+	return nil
+}
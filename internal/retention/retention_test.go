@@ -0,0 +1,190 @@
+package retention
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCurrentStats(t *testing.T) {
+	t.Parallel()
+
+	current := CurrentStats()
+	assert.Contains(t, current.DeletedTotal, KindEvents)
+	assert.Contains(t, current.DeletedTotal, KindHistory)
+	assert.Contains(t, current.DeletedTotal, KindNotifications)
+
+	current.DeletedTotal[KindEvents] += 42
+	assert.NotEqual(t, current.DeletedTotal[KindEvents], CurrentStats().DeletedTotal[KindEvents],
+		"stats must be copied")
+}
+
+func TestCleaner_deleteBatch(t *testing.T) {
+	t.Parallel()
+
+	fake := testutils.NewFakeDB(database.PostgreSQL, func(query string, _ []any) (testutils.FakeResult, error) {
+		if strings.HasPrefix(query, "SELECT") {
+			return testutils.FakeResult{Columns: []string{"id"}, Rows: [][]driver.Value{{int64(3)}, {int64(5)}}}, nil
+		}
+		return testutils.FakeResult{RowsAffected: 2}, nil
+	})
+
+	c := &Cleaner{
+		DB:     &database.DB{DB: fake.DB},
+		Config: daemon.RetentionConfig{BatchSize: 2, StatementTimeout: time.Minute},
+	}
+	k := kind{name: KindNotifications, query: selectNotifications, delete: deleteNotifications}
+
+	cutoff := types.UnixMilli(time.UnixMilli(1_700_000_000_000))
+	deleted, err := c.deleteBatch(context.Background(), k, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	assert.Equal(t, []testutils.FakeStatement{
+		{Query: fake.DB.Rebind(selectNotifications), Args: []any{int64(1_700_000_000_000), int64(2)}},
+		{
+			Query: `DELETE FROM "incident_history_rule" WHERE "incident_history_id" IN ($1, $2)`,
+			Args:  []any{int64(3), int64(5)},
+		},
+		{Query: `DELETE FROM "incident_history" WHERE "id" IN ($1, $2)`, Args: []any{int64(3), int64(5)}},
+	}, fake.Statements())
+}
+
+func TestSelectNotifications(t *testing.T) {
+	t.Parallel()
+
+	assert.Contains(t, selectNotifications, `"notification_state" <> 'pending'`,
+		"pending notifications are still to be delivered and must be kept")
+	assert.Contains(t, selectNotifications, `"notification_state" IS NULL OR`,
+		"notifications without a state predate it and must be deleted")
+
+	enums := schemaEnums(t)
+	assert.Contains(t, enums["notification_state_type"], "pending")
+	for _, typ := range []string{"notified", "renotified"} {
+		assert.Contains(t, enums["incident_history_event_type"], typ)
+		assert.Contains(t, selectNotifications, "'"+typ+"'")
+	}
+}
+
+func TestSelectEvents(t *testing.T) {
+	t.Parallel()
+
+	for _, dialect := range []string{"pgsql", "mysql"} {
+		for table, references := range schemaForeignKeys(t, dialect) {
+			if slices.Contains(references, "event") {
+				assert.Containsf(t, selectEvents,
+					`NOT EXISTS (SELECT 1 FROM "`+table+`" WHERE "`+table+`"."event_id" = "event"."id")`,
+					"%s: events referenced by %q must be kept", dialect, table)
+			}
+		}
+	}
+}
+
+func TestDeleteIncidents(t *testing.T) {
+	t.Parallel()
+
+	fake := testutils.NewFakeDB(database.PostgreSQL, nil)
+	tx, err := fake.DB.Beginx()
+	require.NoError(t, err)
+	require.NoError(t, deleteIncidents(context.Background(), tx, []int64{1, 2}))
+	require.NoError(t, tx.Commit())
+
+	statements := fake.Statements()
+	require.Len(t, statements, len(incidentTables)+2)
+	assert.Equal(t, testutils.FakeStatement{
+		Query: `DELETE FROM "incident_history_rule" WHERE "incident_history_id" IN (` +
+			`SELECT "id" FROM "incident_history" WHERE "incident_id" IN ($1, $2))`,
+		Args: []any{int64(1), int64(2)},
+	}, statements[0])
+	for i, table := range incidentTables {
+		assert.Equal(t, testutils.FakeStatement{
+			Query: `DELETE FROM "` + table + `" WHERE "incident_id" IN ($1, $2)`,
+			Args:  []any{int64(1), int64(2)},
+		}, statements[i+1])
+	}
+	assert.Equal(t, testutils.FakeStatement{
+		Query: `DELETE FROM "incident" WHERE "id" IN ($1, $2)`,
+		Args:  []any{int64(1), int64(2)},
+	}, statements[len(statements)-1])
+}
+
+func TestIncidentTables_DeleteOrder(t *testing.T) {
+	t.Parallel()
+
+	// Tables in the order deleteIncidents deletes from them.
+	order := append(append([]string{"incident_history_rule"}, incidentTables...), "incident")
+
+	for _, dialect := range []string{"pgsql", "mysql"} {
+		for table, references := range schemaForeignKeys(t, dialect) {
+			if slices.Contains(references, "incident") {
+				assert.Containsf(t, incidentTables, table, "%s: rows of %q must be deleted with their incident",
+					dialect, table)
+			}
+
+			pos := slices.Index(order, table)
+			if pos < 0 {
+				continue
+			}
+
+			for _, referenced := range references {
+				if refPos := slices.Index(order, referenced); refPos >= 0 && referenced != table {
+					assert.Lessf(t, pos, refPos, "%s: %q references %q and must be deleted first",
+						dialect, table, referenced)
+				}
+			}
+		}
+	}
+}
+
+var (
+	createTableRe = regexp.MustCompile(`(?s)CREATE TABLE (\w+) \((.*?)\n\)`)
+	referencesRe  = regexp.MustCompile(`FOREIGN KEY \([^)]*\) REFERENCES (\w+)\s*\(`)
+	createEnumRe  = regexp.MustCompile(`(?s)CREATE TYPE (\w+) AS ENUM \((.*?)\);`)
+	enumValueRe   = regexp.MustCompile(`'(\w+)'`)
+)
+
+// readSchema returns the full schema of the dialect, i.e., "pgsql" or "mysql".
+func readSchema(t *testing.T, dialect string) string {
+	schema, err := os.ReadFile("../../schema/" + dialect + "/schema.sql")
+	require.NoError(t, err)
+
+	return string(schema)
+}
+
+// schemaForeignKeys maps all tables of the dialect's schema to the tables referenced by their foreign keys.
+func schemaForeignKeys(t *testing.T, dialect string) map[string][]string {
+	tables := make(map[string][]string)
+	for _, table := range createTableRe.FindAllStringSubmatch(readSchema(t, dialect), -1) {
+		tables[table[1]] = []string{}
+		for _, reference := range referencesRe.FindAllStringSubmatch(table[2], -1) {
+			tables[table[1]] = append(tables[table[1]], reference[1])
+		}
+	}
+	require.NotEmpty(t, tables)
+
+	return tables
+}
+
+// schemaEnums maps all enum types of the PostgreSQL schema to their values.
+func schemaEnums(t *testing.T) map[string][]string {
+	enums := make(map[string][]string)
+	for _, enum := range createEnumRe.FindAllStringSubmatch(readSchema(t, "pgsql"), -1) {
+		for _, value := range enumValueRe.FindAllStringSubmatch(enum[2], -1) {
+			enums[enum[1]] = append(enums[enum[1]], value[1])
+		}
+	}
+	require.NotEmpty(t, enums)
+
+	return enums
+}
@@ -4,16 +4,54 @@ import (
 	"fmt"
 	"github.com/icinga/icinga-notifications/internal/event"
 	"github.com/icinga/icinga-notifications/internal/filter"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
 	"math"
+	"slices"
+	"strings"
 	"time"
 )
 
 // RetryNever indicates that an escalation condition should never be retried once it has been evaluated.
 const RetryNever = time.Duration(math.MaxInt64)
 
+// EscalationFilter represents an incident when evaluating escalation conditions.
+//
+// Next to EscalationFilterKeys, conditions may refer to the tags and extra tags of the incident's object, e.g.,
+// "hostgroup/prod" or "service", allowing different escalations for the same rule depending on the object.
 type EscalationFilter struct {
+	// Object is the incident's object, referred to by all keys not being EscalationFilterKeys. It may be nil.
+	Object filter.Filterable
+
 	IncidentAge      time.Duration
 	IncidentSeverity event.Severity
+
+	// NotificationUnansweredFor is the time passed since the last notification of the incident. It is only set if
+	// NotificationUnanswered is true, i.e., at least one notification was sent and nobody acknowledged the incident.
+	NotificationUnansweredFor time.Duration
+	NotificationUnanswered    bool
+
+	// SeverityChange is the direction of the incident's severity change by the evaluated event, see
+	// event.SeverityChangeOf, referred to by severity_change conditions. It is empty if the severity didn't change.
+	SeverityChange string
+
+	// Time is the point in time the incident is evaluated at, used for within_timeperiod conditions.
+	Time time.Time
+	// TimePeriods returns the time period of the given name, or nil if there is none. It may be nil itself, in which
+	// case all within_timeperiod conditions fail.
+	TimePeriods func(name string) *timeperiod.TimePeriod
+}
+
+// withinTimePeriod returns whether the time period of the given name contains e.Time.
+func (e *EscalationFilter) withinTimePeriod(name string) (bool, error) {
+	var tp *timeperiod.TimePeriod
+	if e.TimePeriods != nil {
+		tp = e.TimePeriods(name)
+	}
+	if tp == nil {
+		return false, fmt.Errorf("unknown time period %q", name)
+	}
+
+	return tp.Contains(e.Time), nil
 }
 
 // ReevaluateAfter returns the duration after which escalationCond should be reevaluated the
@@ -28,13 +66,30 @@ type EscalationFilter struct {
 func (e *EscalationFilter) ReevaluateAfter(escalationCond filter.Filter) time.Duration {
 	retryAfter := RetryNever
 	for _, condition := range escalationCond.ExtractConditions() {
-		if condition.Column() == "incident_age" {
+		switch condition.Column() {
+		case "incident_age":
 			v, err := time.ParseDuration(condition.Value())
 			if err == nil && v > e.IncidentAge {
 				// The incident age is compared with a value in the future. Once that age is
 				// reached, the escalation could trigger, so consider that time for reevaluation.
 				retryAfter = min(retryAfter, v-e.IncidentAge)
 			}
+		case "notification_unanswered_for":
+			v, err := time.ParseDuration(condition.Value())
+			if err == nil && e.NotificationUnanswered && v > e.NotificationUnansweredFor {
+				// Same as for the incident age, unless somebody acknowledges the incident in the meantime. In this
+				// case, the reevaluation won't trigger this escalation as the condition no longer exists.
+				retryAfter = min(retryAfter, v-e.NotificationUnansweredFor)
+			}
+		case "within_timeperiod":
+			if e.TimePeriods == nil {
+				continue
+			}
+			if tp := e.TimePeriods(condition.Value()); tp != nil {
+				// The time period may be entered or left at its next transition, either of which might let the
+				// escalation trigger, depending on the condition's operator.
+				retryAfter = min(retryAfter, tp.NextTransition(e.Time).Sub(e.Time))
+			}
 		}
 	}
 
@@ -50,6 +105,13 @@ func (e *EscalationFilter) EvalEqual(key string, value string) (bool, error) {
 		}
 
 		return e.IncidentAge == age, nil
+	case "notification_unanswered_for":
+		unansweredFor, err := time.ParseDuration(value)
+		if err != nil {
+			return false, err
+		}
+
+		return e.NotificationUnansweredFor == unansweredFor, nil
 	case "incident_severity":
 		severity, err := event.GetSeverityByName(value)
 		if err != nil {
@@ -57,8 +119,20 @@ func (e *EscalationFilter) EvalEqual(key string, value string) (bool, error) {
 		}
 
 		return e.IncidentSeverity == severity, nil
+	case "within_timeperiod":
+		return e.withinTimePeriod(value)
+	case "severity_change":
+		if value != event.SeverityIncreased && value != event.SeverityDecreased {
+			return false, fmt.Errorf("invalid severity change %q", value)
+		}
+
+		return e.SeverityChange == value, nil
 	default:
-		return false, nil
+		if e.Object == nil {
+			return false, nil
+		}
+
+		return e.Object.EvalEqual(key, value)
 	}
 }
 
@@ -71,6 +145,13 @@ func (e *EscalationFilter) EvalLess(key string, value string) (bool, error) {
 		}
 
 		return e.IncidentAge < age, nil
+	case "notification_unanswered_for":
+		unansweredFor, err := time.ParseDuration(value)
+		if err != nil {
+			return false, err
+		}
+
+		return e.NotificationUnansweredFor < unansweredFor, nil
 	case "incident_severity":
 		severity, err := event.GetSeverityByName(value)
 		if err != nil {
@@ -78,13 +159,26 @@ func (e *EscalationFilter) EvalLess(key string, value string) (bool, error) {
 		}
 
 		return e.IncidentSeverity < severity, nil
+	case "within_timeperiod", "severity_change":
+		return false, fmt.Errorf("escalation condition %q only supports equality", key)
 	default:
-		return false, nil
+		if e.Object == nil {
+			return false, nil
+		}
+
+		return e.Object.EvalLess(key, value)
 	}
 }
 
 func (e *EscalationFilter) EvalLike(key string, value string) (bool, error) {
-	return false, fmt.Errorf("escalation filter does not support wildcard matches")
+	if slices.Contains(EscalationFilterKeys, key) {
+		return false, fmt.Errorf("escalation filter does not support wildcard matches")
+	}
+	if e.Object == nil {
+		return false, nil
+	}
+
+	return e.Object.EvalLike(key, value)
 }
 
 func (e *EscalationFilter) EvalLessOrEqual(key string, value string) (bool, error) {
@@ -96,6 +190,13 @@ func (e *EscalationFilter) EvalLessOrEqual(key string, value string) (bool, erro
 		}
 
 		return e.IncidentAge <= age, nil
+	case "notification_unanswered_for":
+		unansweredFor, err := time.ParseDuration(value)
+		if err != nil {
+			return false, err
+		}
+
+		return e.NotificationUnansweredFor <= unansweredFor, nil
 	case "incident_severity":
 		severity, err := event.GetSeverityByName(value)
 		if err != nil {
@@ -103,18 +204,99 @@ func (e *EscalationFilter) EvalLessOrEqual(key string, value string) (bool, erro
 		}
 
 		return e.IncidentSeverity <= severity, nil
+	case "within_timeperiod", "severity_change":
+		return false, fmt.Errorf("escalation condition %q only supports equality", key)
 	default:
-		return false, nil
+		if e.Object == nil {
+			return false, nil
+		}
+
+		return e.Object.EvalLessOrEqual(key, value)
 	}
 }
 
 func (e *EscalationFilter) EvalExists(key string) bool {
 	switch key {
-	case "incident_age":
-		fallthrough
-	case "incident_severity":
+	case "incident_age", "incident_severity", "within_timeperiod":
 		return true
+	case "notification_unanswered_for":
+		return e.NotificationUnanswered
+	case "severity_change":
+		return e.SeverityChange != ""
 	default:
-		return false
+		return e.Object != nil && e.Object.EvalExists(key)
+	}
+}
+
+// EscalationFilterKeys are the keys of the incident itself supported by EscalationFilter. All other keys refer to the
+// tags of the incident's object.
+var EscalationFilterKeys = []string{
+	"incident_age", "incident_severity", "notification_unanswered_for", "severity_change", "within_timeperiod",
+}
+
+// equalityOnlyKeys are the EscalationFilterKeys whose values cannot be ordered, i.e., only support = and !=.
+var equalityOnlyKeys = []string{"severity_change", "within_timeperiod"}
+
+// reservedKeyPrefixes are the prefixes of EscalationFilterKeys. Other keys starting with them are considered typos of
+// EscalationFilterKeys rather than object tags.
+var reservedKeyPrefixes = []string{"incident_", "notification_"}
+
+// IsReservedKey reports whether the key of an escalation condition refers to the incident itself rather than an
+// object tag, i.e., is either one of EscalationFilterKeys or starts with one of their prefixes.
+func IsReservedKey(key string) bool {
+	if slices.Contains(EscalationFilterKeys, key) {
+		return true
+	}
+	for _, prefix := range reservedKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
 	}
+
+	return false
+}
+
+// ValidateCondition checks that an escalation condition uses keys of EscalationFilterKeys with valid values, next to
+// object tags.
+//
+// Unknown keys of the incident never match and invalid values result in evaluation errors, so both are mistakes only
+// noticed once the escalation fails to trigger. Object tags are not checked, as the condition may target objects not
+// having sent an event yet.
+func ValidateCondition(cond filter.Filter) error {
+	for _, column := range filter.Columns(cond) {
+		if IsReservedKey(column) && !slices.Contains(EscalationFilterKeys, column) {
+			return fmt.Errorf("unknown escalation condition key %q", column)
+		}
+	}
+
+	for _, condition := range cond.ExtractConditions() {
+		column, op := condition.Column(), condition.Operator()
+		if !slices.Contains(EscalationFilterKeys, column) {
+			continue
+		}
+
+		if op == filter.Like || op == filter.UnLike {
+			return fmt.Errorf("escalation condition %q does not support wildcard matches", column)
+		}
+		if slices.Contains(equalityOnlyKeys, column) && op != filter.Equal && op != filter.UnEqual {
+			return fmt.Errorf("escalation condition %q only supports equality", column)
+		}
+
+		var err error
+		switch column {
+		case "incident_age", "notification_unanswered_for":
+			_, err = time.ParseDuration(condition.Value())
+		case "incident_severity":
+			_, err = event.GetSeverityByName(condition.Value())
+		case "severity_change":
+			if v := condition.Value(); v != event.SeverityIncreased && v != event.SeverityDecreased {
+				err = fmt.Errorf("must be either %q or %q, got %q", event.SeverityIncreased, event.SeverityDecreased, v)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value for escalation condition %q: %w", column, err)
+		}
+	}
+
+	return nil
 }
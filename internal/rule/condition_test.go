@@ -0,0 +1,237 @@
+package rule
+
+import (
+	"database/sql"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/event"
+	"github.com/icinga/icinga-notifications/internal/filter"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestEscalationFilter_NotificationUnansweredFor(t *testing.T) {
+	t.Parallel()
+
+	unanswered := &EscalationFilter{NotificationUnanswered: true, NotificationUnansweredFor: 20 * time.Minute}
+	answered := &EscalationFilter{}
+
+	tests := []struct {
+		condition  string
+		ef         *EscalationFilter
+		matches    bool
+		retryAfter time.Duration
+	}{
+		{"notification_unanswered_for>=15m", unanswered, true, RetryNever},
+		{"notification_unanswered_for>=30m", unanswered, false, 10 * time.Minute},
+		{"notification_unanswered_for<30m", unanswered, true, 10 * time.Minute},
+		{"notification_unanswered_for=20m", unanswered, true, RetryNever},
+		{"notification_unanswered_for>=15m", answered, false, RetryNever},
+		{"notification_unanswered_for<30m", answered, false, RetryNever},
+		{"notification_unanswered_for", unanswered, true, RetryNever},
+		{"notification_unanswered_for", answered, false, RetryNever},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			t.Parallel()
+
+			cond, err := filter.Parse(tt.condition)
+			require.NoError(t, err)
+
+			matches, err := cond.Eval(tt.ef)
+			require.NoError(t, err)
+			assert.Equal(t, tt.matches, matches)
+
+			assert.Equal(t, tt.retryAfter, tt.ef.ReevaluateAfter(cond))
+		})
+	}
+}
+
+func TestEscalationFilter_ObjectTags(t *testing.T) {
+	t.Parallel()
+
+	ef := &EscalationFilter{
+		Object: &object.Object{
+			Tags:      map[string]string{"host": "db-01", "service": "mysql"},
+			ExtraTags: map[string]string{"hostgroup/prod": ""},
+		},
+		IncidentSeverity: event.SeverityCrit,
+	}
+
+	tests := []struct {
+		condition string
+		ef        *EscalationFilter
+		matches   bool
+	}{
+		{"incident_severity>=crit&hostgroup/prod", ef, true},
+		{"incident_severity>=crit&!hostgroup/prod", ef, false},
+		{"service=mysql", ef, true},
+		{"host=db-*", ef, true},
+		{"host=web-*", ef, false},
+		{"host<db-02", ef, true},
+		{"host>=db-02", ef, false},
+		{"hostgroup/prod", &EscalationFilter{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			t.Parallel()
+
+			cond, err := filter.Parse(tt.condition)
+			require.NoError(t, err)
+
+			matches, err := cond.Eval(tt.ef)
+			require.NoError(t, err)
+			assert.Equal(t, tt.matches, matches)
+		})
+	}
+}
+
+func TestEscalationFilter_WithinTimePeriod(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	entry := &timeperiod.Entry{
+		StartTime: types.UnixMilli(start),
+		EndTime:   types.UnixMilli(start.Add(8 * time.Hour)),
+		Timezone:  "UTC",
+		RRule:     sql.NullString{String: "FREQ=DAILY", Valid: true},
+	}
+	require.NoError(t, entry.Init())
+	businessHours := &timeperiod.TimePeriod{Entries: []*timeperiod.Entry{entry}}
+
+	lookup := func(name string) *timeperiod.TimePeriod {
+		if name == "business-hours" {
+			return businessHours
+		}
+		return nil
+	}
+
+	tests := []struct {
+		condition  string
+		at         time.Time
+		matches    bool
+		retryAfter time.Duration
+	}{
+		{"within_timeperiod=business-hours", start.Add(time.Hour), true, 7 * time.Hour},
+		{"within_timeperiod=business-hours", start.Add(-time.Hour), false, time.Hour},
+		{"within_timeperiod!=business-hours", start.Add(10 * time.Hour), true, 14 * time.Hour},
+		{"within_timeperiod!=business-hours", start.Add(2 * time.Hour), false, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition+"@"+tt.at.Format(time.Kitchen), func(t *testing.T) {
+			t.Parallel()
+
+			cond, err := filter.Parse(tt.condition)
+			require.NoError(t, err)
+
+			ef := &EscalationFilter{Time: tt.at, TimePeriods: lookup}
+			matches, err := cond.Eval(ef)
+			require.NoError(t, err)
+			assert.Equal(t, tt.matches, matches)
+
+			assert.Equal(t, tt.retryAfter, ef.ReevaluateAfter(cond))
+		})
+	}
+
+	t.Run("Unknown", func(t *testing.T) {
+		t.Parallel()
+
+		cond, err := filter.Parse("within_timeperiod=after-hours")
+		require.NoError(t, err)
+
+		_, err = cond.Eval(&EscalationFilter{Time: start, TimePeriods: lookup})
+		assert.ErrorContains(t, err, `unknown time period "after-hours"`)
+		assert.Equal(t, RetryNever, (&EscalationFilter{Time: start, TimePeriods: lookup}).ReevaluateAfter(cond))
+	})
+}
+
+func TestEscalationFilter_SeverityChange(t *testing.T) {
+	t.Parallel()
+
+	increased := &EscalationFilter{
+		IncidentSeverity: event.SeverityCrit,
+		SeverityChange:   event.SeverityChangeOf(event.SeverityWarning, event.SeverityCrit),
+	}
+	decreased := &EscalationFilter{
+		IncidentSeverity: event.SeverityWarning,
+		SeverityChange:   event.SeverityChangeOf(event.SeverityCrit, event.SeverityWarning),
+	}
+	unchanged := &EscalationFilter{
+		IncidentSeverity: event.SeverityCrit,
+		SeverityChange:   event.SeverityChangeOf(event.SeverityCrit, event.SeverityCrit),
+	}
+
+	tests := []struct {
+		condition string
+		ef        *EscalationFilter
+		matches   bool
+	}{
+		{"severity_change=increased", increased, true},
+		{"severity_change=increased", decreased, false},
+		{"severity_change=increased", unchanged, false},
+		{"severity_change=decreased", decreased, true},
+		{"severity_change!=decreased&incident_severity>=crit", increased, true},
+		{"severity_change", unchanged, false},
+		{"!severity_change", unchanged, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			t.Parallel()
+
+			cond, err := filter.Parse(tt.condition)
+			require.NoError(t, err)
+
+			matches, err := cond.Eval(tt.ef)
+			require.NoError(t, err)
+			assert.Equal(t, tt.matches, matches)
+		})
+	}
+
+	cond, err := filter.Parse("severity_change=up")
+	require.NoError(t, err)
+	_, err = cond.Eval(increased)
+	assert.Error(t, err)
+}
+
+func TestValidateCondition(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		condition string
+		wantErr   string
+	}{
+		{condition: "incident_age>=10m&incident_severity>=crit"},
+		{condition: "notification_unanswered_for>15m|!notification_unanswered_for"},
+		{condition: "incident_serverity>=crit", wantErr: `unknown escalation condition key "incident_serverity"`},
+		{condition: "incident_age>=1h&hostgroup/prod"},
+		{condition: "service=mysql*|!host"},
+		{condition: "within_timeperiod!=business-hours&incident_severity>=crit"},
+		{condition: "within_timeperiod>=business-hours", wantErr: "only supports equality"},
+		{condition: "severity_change=increased|severity_change!=decreased"},
+		{condition: "severity_change<increased", wantErr: "only supports equality"},
+		{condition: "severity_change=up", wantErr: `invalid value for escalation condition "severity_change"`},
+		{condition: "notification_sent", wantErr: `unknown escalation condition key "notification_sent"`},
+		{condition: "incident_age>=10", wantErr: `invalid value for escalation condition "incident_age"`},
+		{condition: "incident_severity=critical", wantErr: `invalid value for escalation condition "incident_severity"`},
+		{condition: "incident_severity=cr*", wantErr: "does not support wildcard matches"},
+	}
+
+	for _, tt := range tests {
+		cond, err := filter.Parse(tt.condition)
+		require.NoError(t, err)
+
+		err = ValidateCondition(cond)
+		if tt.wantErr == "" {
+			assert.NoError(t, err, tt.condition)
+		} else {
+			assert.ErrorContains(t, err, tt.wantErr, tt.condition)
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package rule
 
 import (
+	"cmp"
 	"database/sql"
 	"fmt"
+	"github.com/icinga/icinga-go-library/types"
 	"github.com/icinga/icinga-notifications/internal/config/baseconf"
 	"github.com/icinga/icinga-notifications/internal/filter"
 	"github.com/icinga/icinga-notifications/internal/recipient"
 	"go.uber.org/zap/zapcore"
+	"slices"
 	"strings"
 	"time"
 )
@@ -14,18 +17,36 @@ import (
 type Escalation struct {
 	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
 
-	RuleID        int64          `db:"rule_id"`
+	// Each escalation belongs to either a rule or an EscalationPolicy, being referenced by multiple rules.
+	RuleID   types.Int `db:"rule_id"`
+	PolicyID types.Int `db:"escalation_policy_id"`
+
 	NameRaw       sql.NullString `db:"name"`
 	Condition     filter.Filter  `db:"-"`
 	ConditionExpr sql.NullString `db:"condition"`
 	FallbackForID sql.NullInt64  `db:"fallback_for"`
 	Fallbacks     []*Escalation  `db:"-"`
 
+	// RenotifyInterval repeats the notifications of this escalation until the incident has a manager or recovers.
+	// A zero value disables repeated notifications.
+	RenotifyInterval    time.Duration `db:"-"`
+	RenotifyIntervalRaw sql.NullInt64 `db:"renotify_interval"`
+
+	// RequiredAcknowledgements is the number of distinct managers, i.e., contacts having acknowledged the incident,
+	// required for it to be considered handled once this escalation was triggered, being at least one. Until then,
+	// recipients are still notified and escalations depending on notification_unanswered_for may still trigger.
+	RequiredAcknowledgements    int64         `db:"-"`
+	RequiredAcknowledgementsRaw sql.NullInt64 `db:"required_acknowledgements"`
+
 	Recipients []*EscalationRecipient `db:"-"`
 }
 
 // IncrementalInitAndValidate implements the config.IncrementalConfigurableInitAndValidatable interface.
 func (e *Escalation) IncrementalInitAndValidate() error {
+	if e.RuleID.Valid == e.PolicyID.Valid {
+		return fmt.Errorf("escalation must belong to either a rule or an escalation policy")
+	}
+
 	if e.ConditionExpr.Valid {
 		cond, err := filter.Parse(e.ConditionExpr.String)
 		if err != nil {
@@ -35,6 +56,23 @@ func (e *Escalation) IncrementalInitAndValidate() error {
 		e.Condition = cond
 	}
 
+	if e.RenotifyIntervalRaw.Valid {
+		if e.RenotifyIntervalRaw.Int64 <= 0 {
+			return fmt.Errorf("renotify interval must be positive, got %d", e.RenotifyIntervalRaw.Int64)
+		}
+
+		e.RenotifyInterval = time.Duration(e.RenotifyIntervalRaw.Int64) * time.Millisecond
+	}
+
+	e.RequiredAcknowledgements = 1
+	if e.RequiredAcknowledgementsRaw.Valid {
+		if e.RequiredAcknowledgementsRaw.Int64 < 1 {
+			return fmt.Errorf("required acknowledgements must be at least one, got %d", e.RequiredAcknowledgementsRaw.Int64)
+		}
+
+		e.RequiredAcknowledgements = e.RequiredAcknowledgementsRaw.Int64
+	}
+
 	if e.FallbackForID.Valid {
 		// TODO: implement fallbacks (needs extra validation: mismatching rule_id, cycles)
 		return fmt.Errorf("ignoring fallback escalation (not yet implemented)")
@@ -50,7 +88,12 @@ func (e *Escalation) IncrementalInitAndValidate() error {
 // https://pkg.go.dev/go.uber.org/zap/zapcore#ObjectMarshaler
 func (e *Escalation) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 	encoder.AddInt64("id", e.ID)
-	encoder.AddInt64("rule_id", e.RuleID)
+	if e.RuleID.Valid {
+		encoder.AddInt64("rule_id", e.RuleID.Int64)
+	}
+	if e.PolicyID.Valid {
+		encoder.AddInt64("escalation_policy_id", e.PolicyID.Int64)
+	}
 	encoder.AddString("name", e.DisplayName())
 
 	if e.ConditionExpr.Valid && e.ConditionExpr.String != "" {
@@ -59,6 +102,12 @@ func (e *Escalation) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 	if e.FallbackForID.Valid && e.FallbackForID.Int64 != 0 {
 		encoder.AddInt64("fallback_for", e.FallbackForID.Int64)
 	}
+	if e.RenotifyInterval > 0 {
+		encoder.AddDuration("renotify_interval", e.RenotifyInterval)
+	}
+	if e.RequiredAcknowledgements > 1 {
+		encoder.AddInt64("required_acknowledgements", e.RequiredAcknowledgements)
+	}
 
 	return nil
 }
@@ -73,6 +122,13 @@ func (e *Escalation) Eval(filterable *EscalationFilter) (bool, error) {
 	return e.Condition.Eval(filterable)
 }
 
+// DependsOnSeverityChange reports whether the escalation's condition refers to the severity change of the current
+// event. Such an escalation only notifies its recipients of the events matching its condition, e.g., only of
+// escalating incidents for severity_change=increased, instead of all further events once triggered.
+func (e *Escalation) DependsOnSeverityChange() bool {
+	return e.Condition != nil && slices.Contains(filter.Columns(e.Condition), "severity_change")
+}
+
 func (e *Escalation) DisplayName() string {
 	if e.NameRaw.Valid && e.NameRaw.String != "" {
 		return e.NameRaw.String
@@ -114,6 +170,20 @@ func (e *Escalation) TableName() string {
 	return "rule_escalation"
 }
 
+// DeliveryStrategy defines how an EscalationRecipient resolving to multiple contacts is notified.
+type DeliveryStrategy string
+
+const (
+	// DeliveryAll notifies all contacts, being the default.
+	DeliveryAll DeliveryStrategy = "all"
+
+	// DeliveryRoundRobin notifies a single contact per incident, rotating through all contacts ordered by their IDs.
+	DeliveryRoundRobin DeliveryStrategy = "round-robin"
+
+	// DeliveryLeastRecentlyNotified notifies the single contact which was selected the longest time ago.
+	DeliveryLeastRecentlyNotified DeliveryStrategy = "least-recently-notified"
+)
+
 type EscalationRecipient struct {
 	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
 
@@ -121,6 +191,61 @@ type EscalationRecipient struct {
 	ChannelID     sql.NullInt64 `db:"channel_id"`
 	recipient.Key `db:",inline"`
 	Recipient     recipient.Recipient `db:"-"`
+	Delivery      DeliveryStrategy    `db:"delivery"`
+}
+
+// IncrementalInitAndValidate implements the config.IncrementalConfigurableInitAndValidatable interface.
+func (r *EscalationRecipient) IncrementalInitAndValidate() error {
+	switch r.Delivery {
+	case "":
+		r.Delivery = DeliveryAll
+	case DeliveryAll, DeliveryRoundRobin, DeliveryLeastRecentlyNotified:
+	default:
+		return fmt.Errorf("unknown delivery strategy %q", r.Delivery)
+	}
+
+	return nil
+}
+
+// SelectsSingleContact returns whether only a single contact is notified, as selected by SelectContact.
+func (r *EscalationRecipient) SelectsSingleContact() bool {
+	return r.Delivery == DeliveryRoundRobin || r.Delivery == DeliveryLeastRecentlyNotified
+}
+
+// SelectContact picks the single contact to be notified from the recipient's current contacts.
+//
+// The lastSelected map holds the time each contact was previously selected by this recipient. Returns nil if there
+// are no contacts.
+func (r *EscalationRecipient) SelectContact(contacts []*recipient.Contact, lastSelected map[int64]time.Time) *recipient.Contact {
+	if len(contacts) == 0 {
+		return nil
+	}
+
+	contacts = slices.Clone(contacts)
+	slices.SortFunc(contacts, func(a, b *recipient.Contact) int { return cmp.Compare(a.ID, b.ID) })
+
+	switch r.Delivery {
+	case DeliveryRoundRobin:
+		// Continue after the most recently selected contact, starting over with the first one.
+		next := 0
+		var latest time.Time
+		for i, c := range contacts {
+			if t, ok := lastSelected[c.ID]; ok && t.After(latest) {
+				latest = t
+				next = (i + 1) % len(contacts)
+			}
+		}
+		return contacts[next]
+
+	case DeliveryLeastRecentlyNotified:
+		// Contacts never selected before have a zero time and are thus preferred.
+		return slices.MinFunc(contacts, func(a, b *recipient.Contact) int {
+			return lastSelected[a.ID].Compare(lastSelected[b.ID])
+		})
+
+	default:
+		return contacts[0]
+	}
 }
 
 // MarshalLogObject implements the zapcore.ObjectMarshaler interface.
@@ -130,6 +255,9 @@ func (r *EscalationRecipient) MarshalLogObject(encoder zapcore.ObjectEncoder) er
 	if r.ChannelID.Valid {
 		encoder.AddInt64("channel_id", r.ChannelID.Int64)
 	}
+	if r.SelectsSingleContact() {
+		encoder.AddString("delivery", string(r.Delivery))
+	}
 	return r.Key.MarshalLogObject(encoder)
 }
 
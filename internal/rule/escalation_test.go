@@ -0,0 +1,94 @@
+package rule
+
+import (
+	"database/sql"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestEscalationRecipient_SelectContact(t *testing.T) {
+	t.Parallel()
+
+	var contacts []*recipient.Contact
+	for id := int64(1); id <= 3; id++ {
+		c := &recipient.Contact{}
+		c.ID = id
+		contacts = append(contacts, c)
+	}
+	// GetContactsAt does not guarantee any order, thus shuffle them.
+	contacts[0], contacts[2] = contacts[2], contacts[0]
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		delivery     DeliveryStrategy
+		lastSelected map[int64]time.Time
+		expected     int64
+	}{
+		{"RoundRobinFirst", DeliveryRoundRobin, nil, 1},
+		{"RoundRobinNext", DeliveryRoundRobin, map[int64]time.Time{1: base, 2: base.Add(time.Hour)}, 3},
+		{"RoundRobinWrap", DeliveryRoundRobin, map[int64]time.Time{1: base, 3: base.Add(time.Hour)}, 1},
+		{"RoundRobinLeftContact", DeliveryRoundRobin, map[int64]time.Time{2: base, 42: base.Add(time.Hour)}, 3},
+		{"LeastRecentlyNever", DeliveryLeastRecentlyNotified, map[int64]time.Time{1: base, 3: base}, 2},
+		{"LeastRecentlyOldest", DeliveryLeastRecentlyNotified, map[int64]time.Time{
+			1: base.Add(time.Hour), 2: base, 3: base.Add(2 * time.Hour),
+		}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			er := &EscalationRecipient{Delivery: tt.delivery}
+			require.True(t, er.SelectsSingleContact())
+
+			selected := er.SelectContact(contacts, tt.lastSelected)
+			require.NotNil(t, selected)
+			assert.Equal(t, tt.expected, selected.ID)
+		})
+	}
+
+	t.Run("NoContacts", func(t *testing.T) {
+		t.Parallel()
+
+		er := &EscalationRecipient{Delivery: DeliveryRoundRobin}
+		assert.Nil(t, er.SelectContact(nil, nil))
+	})
+}
+
+func TestEscalationRecipient_IncrementalInitAndValidate(t *testing.T) {
+	t.Parallel()
+
+	er := &EscalationRecipient{}
+	require.NoError(t, er.IncrementalInitAndValidate())
+	assert.Equal(t, DeliveryAll, er.Delivery)
+	assert.False(t, er.SelectsSingleContact())
+
+	er = &EscalationRecipient{Delivery: "random"}
+	assert.Error(t, er.IncrementalInitAndValidate())
+}
+
+func TestEscalation_DependsOnSeverityChange(t *testing.T) {
+	t.Parallel()
+
+	for expr, expected := range map[string]bool{
+		"":                                  false,
+		"incident_severity>=crit":           false,
+		"severity_change=increased":         true,
+		"incident_age>=1h|!severity_change": true,
+		"hostgroup/prod&severity_change=decreased": true,
+	} {
+		e := &Escalation{RuleID: utils.ToDBInt(1)}
+		if expr != "" {
+			e.ConditionExpr = sql.NullString{String: expr, Valid: true}
+		}
+		require.NoError(t, e.IncrementalInitAndValidate())
+
+		assert.Equal(t, expected, e.DependsOnSeverityChange(), expr)
+	}
+}
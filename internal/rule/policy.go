@@ -0,0 +1,25 @@
+package rule
+
+import (
+	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"go.uber.org/zap/zapcore"
+)
+
+// EscalationPolicy is a named set of escalations, defined once and referenced by any number of rules.
+//
+// The escalations of a policy have their PolicyID set instead of a RuleID. Each rule referencing the policy, see
+// Rule.EscalationPolicyID, includes them in its Escalations next to its own ones, which is resolved by the
+// config.RuntimeConfig. Thus, organizations with many similar rules don't have to duplicate their escalations.
+type EscalationPolicy struct {
+	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+
+	Name        string                `db:"name"`
+	Escalations map[int64]*Escalation `db:"-"`
+}
+
+// MarshalLogObject implements the zapcore.ObjectMarshaler interface.
+func (p *EscalationPolicy) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddInt64("id", p.ID)
+	encoder.AddString("name", p.Name)
+	return nil
+}
@@ -1,24 +1,36 @@
 package rule
 
 import (
+	"fmt"
 	"github.com/icinga/icinga-go-library/types"
 	"github.com/icinga/icinga-notifications/internal/config/baseconf"
 	"github.com/icinga/icinga-notifications/internal/filter"
 	"github.com/icinga/icinga-notifications/internal/recipient"
 	"github.com/icinga/icinga-notifications/internal/timeperiod"
 	"go.uber.org/zap/zapcore"
+	"net/url"
 	"time"
 )
 
 type Rule struct {
 	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+	baseconf.TenantEntry                 `db:",inline"`
 
 	Name             string                 `db:"name"`
 	TimePeriod       *timeperiod.TimePeriod `db:"-"`
 	TimePeriodID     types.Int              `db:"timeperiod_id"`
 	ObjectFilter     filter.Filter          `db:"-"`
 	ObjectFilterExpr types.String           `db:"object_filter"`
-	Escalations      map[int64]*Escalation  `db:"-"`
+
+	// Escalations contains both the rule's own escalations and those of its EscalationPolicy, if any.
+	Escalations        map[int64]*Escalation `db:"-"`
+	EscalationPolicy   *EscalationPolicy     `db:"-"`
+	EscalationPolicyID types.Int             `db:"escalation_policy_id"`
+
+	// Description, RunbookURL and ChatChannel annotate all notifications caused by this rule, see plugin.Rule.
+	Description types.String `db:"description"`
+	RunbookURL  types.String `db:"runbook_url"`
+	ChatChannel types.String `db:"chat_channel"`
 }
 
 // IncrementalInitAndValidate implements the config.IncrementalConfigurableInitAndValidatable interface.
@@ -32,6 +44,16 @@ func (r *Rule) IncrementalInitAndValidate() error {
 		r.ObjectFilter = f
 	}
 
+	if r.RunbookURL.Valid {
+		u, err := url.Parse(r.RunbookURL.String)
+		if err != nil {
+			return fmt.Errorf("invalid runbook URL: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("runbook URL %q must be an http or https URL", r.RunbookURL.String)
+		}
+	}
+
 	return nil
 }
 
@@ -46,6 +68,9 @@ func (r *Rule) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 	if r.ObjectFilterExpr.Valid && r.ObjectFilterExpr.String != "" {
 		encoder.AddString("object_filter", r.ObjectFilterExpr.String)
 	}
+	if r.EscalationPolicyID.Valid {
+		encoder.AddInt64("escalation_policy_id", r.EscalationPolicyID.Int64)
+	}
 
 	return nil
 }
@@ -75,9 +100,16 @@ func (ch ContactChannels) LoadFromEscalationRecipients(escalation *Escalation, t
 // LoadRecipientChannel loads recipient channel to the current map.
 // You can provide this method a callback to control whether the channel of a specific contact should
 // be loaded, and it will skip those for whom the callback returns false. Pass AlwaysNotifiable for default actions.
+//
+// If the recipient selects a single contact, see EscalationRecipient.SelectsSingleContact, the callback is also
+// consulted for each contact, only loading the channel of the contacts being notifiable on their own.
 func (ch ContactChannels) LoadRecipientChannel(er *EscalationRecipient, t time.Time, isNotifiable func(recipient.Key) bool) {
 	if isNotifiable(er.Key) {
 		for _, c := range er.Recipient.GetContactsAt(t) {
+			if er.SelectsSingleContact() && !isNotifiable(recipient.ToKey(c)) {
+				continue
+			}
+
 			if ch[c] == nil {
 				ch[c] = make(map[int64]bool)
 			}
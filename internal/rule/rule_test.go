@@ -0,0 +1,26 @@
+package rule
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRule_IncrementalInitAndValidate(t *testing.T) {
+	t.Parallel()
+
+	for runbookURL, valid := range map[string]bool{
+		"https://wiki.example.com/runbooks/db": true,
+		"http://wiki/db?section=postgres":      true,
+		"wiki.example.com/runbooks/db":         false,
+		"javascript:alert(1)":                  false,
+		"https://wiki.example.com/%zz":         false,
+	} {
+		r := &Rule{Name: "Database", RunbookURL: types.MakeString(runbookURL)}
+		if valid {
+			assert.NoError(t, r.IncrementalInitAndValidate(), runbookURL)
+		} else {
+			assert.Error(t, r.IncrementalInitAndValidate(), runbookURL)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package ruleimport imports a complete set of rules, including their escalations and recipients, from a document.
+//
+// Referenced objects, e.g., contacts or channels, are identified by their names to allow managing rules independently
+// of database IDs. Importing a rule replaces an existing rule of the same name.
+package ruleimport
+
+import (
+	"errors"
+	"fmt"
+	"github.com/goccy/go-yaml"
+	"github.com/icinga/icinga-notifications/internal/filter"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"io"
+	"strings"
+	"time"
+)
+
+// Document is the root of an import document, either encoded as YAML or as JSON.
+type Document struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Rule to be imported with all its escalations.
+type Rule struct {
+	Name string `yaml:"name"`
+
+	// TimePeriodID optionally references a time period restricting this rule.
+	TimePeriodID int64 `yaml:"timeperiod_id"`
+
+	// ObjectFilter restricts the objects this rule applies to, using the same syntax as configured via the web UI.
+	ObjectFilter string `yaml:"object_filter"`
+
+	Escalations []*Escalation `yaml:"escalations"`
+}
+
+// Escalation of a Rule, being imported in the order of the rule's escalations.
+type Escalation struct {
+	Name       string       `yaml:"name"`
+	Condition  string       `yaml:"condition"`
+	Recipients []*Recipient `yaml:"recipients"`
+
+	// RenotifyInterval optionally repeats the notifications until the incident has a manager or recovers.
+	RenotifyInterval time.Duration `yaml:"renotify_interval"`
+
+	// RequiredAcknowledgements optionally requires this many distinct contacts to acknowledge the incident for it to be
+	// considered handled, see rule.Escalation.RequiredAcknowledgements.
+	RequiredAcknowledgements int64 `yaml:"required_acknowledgements"`
+}
+
+// Recipient of an Escalation, either a contact by its username, a contact group or a schedule by their names.
+type Recipient struct {
+	Contact  string `yaml:"contact"`
+	Group    string `yaml:"group"`
+	Schedule string `yaml:"schedule"`
+
+	// Channel optionally names the channel to be used. Otherwise, the contacts' default channels are used.
+	Channel string `yaml:"channel"`
+
+	// Delivery optionally sets the delivery strategy for groups and schedules, defaulting to rule.DeliveryAll.
+	Delivery rule.DeliveryStrategy `yaml:"delivery"`
+}
+
+// ParseDocument decodes a YAML or JSON document and validates it.
+//
+// Unknown fields are rejected to catch typos early. The returned error lists all validation errors at once.
+func ParseDocument(r io.Reader) (*Document, error) {
+	doc := &Document{}
+	if err := yaml.NewDecoder(r, yaml.DisallowUnknownField()).Decode(doc); err != nil {
+		return nil, fmt.Errorf("cannot decode document: %w", err)
+	}
+
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// Validate checks the document for errors not requiring any database access, e.g., syntax errors in filters.
+func (d *Document) Validate() error {
+	if len(d.Rules) == 0 {
+		return errors.New("document does not contain any rules")
+	}
+
+	var errs []error
+	names := make(map[string]struct{})
+	for i, r := range d.Rules {
+		if r == nil {
+			errs = append(errs, fmt.Errorf("rules[%d] must not be empty", i))
+			continue
+		}
+
+		if r.Name == "" {
+			errs = append(errs, fmt.Errorf("rules[%d] requires a name", i))
+		} else if _, ok := names[strings.ToLower(r.Name)]; ok {
+			errs = append(errs, fmt.Errorf("rules[%d]: rule %q is defined multiple times", i, r.Name))
+		} else {
+			names[strings.ToLower(r.Name)] = struct{}{}
+		}
+
+		if r.ObjectFilter != "" {
+			if _, err := filter.Parse(r.ObjectFilter); err != nil {
+				errs = append(errs, fmt.Errorf("rules[%d]: invalid object_filter: %w", i, err))
+			}
+		}
+
+		for j, e := range r.Escalations {
+			if e == nil {
+				errs = append(errs, fmt.Errorf("rules[%d].escalations[%d] must not be empty", i, j))
+				continue
+			}
+
+			if e.Condition != "" {
+				if _, err := filter.Parse(e.Condition); err != nil {
+					errs = append(errs, fmt.Errorf("rules[%d].escalations[%d]: invalid condition: %w", i, j, err))
+				}
+			}
+
+			if e.RenotifyInterval < 0 {
+				errs = append(errs, fmt.Errorf("rules[%d].escalations[%d]: renotify_interval must not be negative", i, j))
+			}
+
+			if e.RequiredAcknowledgements < 0 {
+				errs = append(errs, fmt.Errorf("rules[%d].escalations[%d]: required_acknowledgements must not be negative", i, j))
+			}
+
+			if len(e.Recipients) == 0 {
+				errs = append(errs, fmt.Errorf("rules[%d].escalations[%d] requires at least one recipient", i, j))
+			}
+
+			for k, rec := range e.Recipients {
+				set := 0
+				if rec != nil {
+					for _, ref := range []string{rec.Contact, rec.Group, rec.Schedule} {
+						if ref != "" {
+							set++
+						}
+					}
+				}
+				if set != 1 {
+					errs = append(errs, fmt.Errorf(
+						"rules[%d].escalations[%d].recipients[%d] requires exactly one of contact, group or schedule",
+						i, j, k))
+					continue
+				}
+
+				er := &rule.EscalationRecipient{Delivery: rec.Delivery}
+				if err := er.IncrementalInitAndValidate(); err != nil {
+					errs = append(errs, fmt.Errorf("rules[%d].escalations[%d].recipients[%d]: %w", i, j, k, err))
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
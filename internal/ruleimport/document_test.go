@@ -0,0 +1,133 @@
+package ruleimport
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDocument(t *testing.T) {
+	t.Parallel()
+
+	t.Run("YAML", func(t *testing.T) {
+		t.Parallel()
+
+		doc, err := ParseDocument(strings.NewReader(`
+rules:
+  - name: Production Databases
+    object_filter: "host=db-*"
+    escalations:
+      - condition: "incident_severity>=crit"
+        recipients:
+          - schedule: DBA On-Call
+      - name: Team Lead
+        condition: "incident_age>=1h"
+        renotify_interval: 30m
+        required_acknowledgements: 2
+        recipients:
+          - contact: jdoe
+            channel: SMS
+`))
+		require.NoError(t, err)
+
+		assert.Equal(t, &Document{Rules: []*Rule{{
+			Name:         "Production Databases",
+			ObjectFilter: "host=db-*",
+			Escalations: []*Escalation{
+				{Condition: "incident_severity>=crit", Recipients: []*Recipient{{Schedule: "DBA On-Call"}}},
+				{
+					Name:             "Team Lead",
+					Condition:        "incident_age>=1h",
+					RenotifyInterval: 30 * time.Minute,
+					Recipients:       []*Recipient{{Contact: "jdoe", Channel: "SMS"}},
+
+					RequiredAcknowledgements: 2,
+				},
+			},
+		}}}, doc)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		t.Parallel()
+
+		doc, err := ParseDocument(strings.NewReader(
+			`{"rules": [{"name": "All", "timeperiod_id": 3, "escalations": [{"recipients": [{"group": "Ops"}]}]}]}`))
+		require.NoError(t, err)
+
+		assert.Equal(t, &Document{Rules: []*Rule{{
+			Name:         "All",
+			TimePeriodID: 3,
+			Escalations:  []*Escalation{{Recipients: []*Recipient{{Group: "Ops"}}}},
+		}}}, doc)
+	})
+
+	t.Run("UnknownField", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := ParseDocument(strings.NewReader("rules:\n  - name: foo\n    filter: host=foo\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestDocument_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		doc    *Document
+		errors []string
+	}{
+		{
+			name:   "NoRules",
+			doc:    &Document{},
+			errors: []string{"document does not contain any rules"},
+		},
+		{
+			name: "Valid",
+			doc: &Document{Rules: []*Rule{
+				{Name: "foo", ObjectFilter: "host=foo"},
+				{Name: "bar", Escalations: []*Escalation{{Recipients: []*Recipient{{Contact: "jdoe"}}}}},
+			}},
+		},
+		{
+			name: "Everything Wrong",
+			doc: &Document{Rules: []*Rule{
+				{Name: "foo", ObjectFilter: "host=(foo"},
+				{Name: "FOO"},
+				{Escalations: []*Escalation{
+					{Condition: "(incident_age>=1h", Recipients: []*Recipient{{Contact: "jdoe"}}},
+					{},
+					{Recipients: []*Recipient{{Contact: "jdoe", Group: "Ops"}, {Channel: "SMS"}}},
+				}},
+			}},
+			errors: []string{
+				"rules[0]: invalid object_filter",
+				`rules[1]: rule "FOO" is defined multiple times`,
+				"rules[2] requires a name",
+				"rules[2].escalations[0]: invalid condition",
+				"rules[2].escalations[1] requires at least one recipient",
+				"rules[2].escalations[2].recipients[0] requires exactly one of contact, group or schedule",
+				"rules[2].escalations[2].recipients[1] requires exactly one of contact, group or schedule",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.doc.Validate()
+			if len(tt.errors) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			for _, msg := range tt.errors {
+				assert.ErrorContains(t, err, msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package ruleimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/config/baseconf"
+	"github.com/icinga/icinga-notifications/internal/errs"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/rule"
+	"github.com/icinga/icinga-notifications/internal/schema"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// Result summarizes a successful import by the affected rules' names.
+type Result struct {
+	// Created lists all rules not existing before.
+	Created []string `json:"created"`
+
+	// Replaced lists all rules which already existed and were replaced.
+	Replaced []string `json:"replaced"`
+}
+
+// Import writes all rules of the document to the database within a single transaction.
+//
+// Existing rules of the same name are marked as deleted together with their escalations and recipients before the
+// imported rules are inserted. If any referenced object cannot be found, nothing is changed and an error listing all
+// unresolvable references is returned. The running daemon picks up the changes through its regular config updates.
+//
+// As existing rules are replaced, the import is refused unless the database schema is supported, see schema.Load.
+func Import(ctx context.Context, db *database.DB, doc *Document) (*Result, error) {
+	if err := schema.RequireCompatible(); err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	err := utils.RunInTx(ctx, db, func(tx *sqlx.Tx) error {
+		*result = Result{} // Forget the rules of a previous attempt, as its transaction was rolled back.
+		imp := &importer{ctx: ctx, db: db, tx: tx, now: types.UnixMilli(time.Now()), ids: make(map[string]int64)}
+		return imp.importDocument(doc, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// importer holds the state of a single Import run.
+type importer struct {
+	ctx context.Context
+	db  *database.DB
+	tx  *sqlx.Tx
+	now types.UnixMilli
+
+	// ids caches resolved references, keyed by table, column and value.
+	ids map[string]int64
+}
+
+func (imp *importer) importDocument(doc *Document, result *Result) error {
+	// Resolve all references first to report all errors at once before writing anything.
+	var errs []error
+	for i, r := range doc.Rules {
+		if r.TimePeriodID != 0 {
+			if _, err := imp.resolve("timeperiod", "id", r.TimePeriodID); err != nil {
+				errs = append(errs, fmt.Errorf("rules[%d]: %w", i, err))
+			}
+		}
+
+		for j, e := range r.Escalations {
+			for k, rec := range e.Recipients {
+				if _, err := imp.resolveRecipient(rec); err != nil {
+					errs = append(errs, fmt.Errorf("rules[%d].escalations[%d].recipients[%d]: %w", i, j, k, err))
+				}
+				if rec.Channel != "" {
+					if _, err := imp.resolve("channel", "name", rec.Channel); err != nil {
+						errs = append(errs, fmt.Errorf("rules[%d].escalations[%d].recipients[%d]: %w", i, j, k, err))
+					}
+				}
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	for _, r := range doc.Rules {
+		replaced, err := imp.deleteRule(r.Name)
+		if err != nil {
+			return fmt.Errorf("cannot replace rule %q: %w", r.Name, err)
+		}
+
+		if err := imp.insertRule(r); err != nil {
+			return fmt.Errorf("cannot insert rule %q: %w", r.Name, err)
+		}
+
+		if replaced {
+			result.Replaced = append(result.Replaced, r.Name)
+		} else {
+			result.Created = append(result.Created, r.Name)
+		}
+	}
+
+	return nil
+}
+
+// resolve returns the ID of the non-deleted row of the table having the given column value.
+func (imp *importer) resolve(table, column string, value any) (int64, error) {
+	cacheKey := fmt.Sprintf("%s\x00%s\x00%v", table, column, value)
+	if id, ok := imp.ids[cacheKey]; ok {
+		return id, nil
+	}
+
+	var ids []int64
+	stmt := imp.tx.Rebind(fmt.Sprintf(`SELECT "id" FROM "%s" WHERE "%s" = ? AND "deleted" = 'n'`, table, column))
+	if err := imp.tx.SelectContext(imp.ctx, &ids, stmt, value); err != nil {
+		return 0, err
+	}
+
+	switch len(ids) {
+	case 0:
+		return 0, fmt.Errorf("%w: %s with %s %v does not exist", errs.ErrConfigMissing, table, column, value)
+	case 1:
+		imp.ids[cacheKey] = ids[0]
+		return ids[0], nil
+	default:
+		return 0, fmt.Errorf("%s with %s %v is ambiguous", table, column, value)
+	}
+}
+
+// resolveRecipient returns the recipient.Key of the referenced contact, contact group or schedule.
+func (imp *importer) resolveRecipient(rec *Recipient) (recipient.Key, error) {
+	var key recipient.Key
+	var err error
+
+	switch {
+	case rec.Contact != "":
+		key.ContactID.Int64, err = imp.resolve("contact", "username", rec.Contact)
+		key.ContactID.Valid = err == nil
+	case rec.Group != "":
+		key.GroupID.Int64, err = imp.resolve("contactgroup", "name", rec.Group)
+		key.GroupID.Valid = err == nil
+	case rec.Schedule != "":
+		key.ScheduleID.Int64, err = imp.resolve("schedule", "name", rec.Schedule)
+		key.ScheduleID.Valid = err == nil
+	}
+
+	return key, err
+}
+
+// deleteRule marks all existing rules of the given name as deleted, including their escalations and recipients.
+// It returns whether any rule was deleted.
+func (imp *importer) deleteRule(name string) (bool, error) {
+	var ids []int64
+	stmt := imp.tx.Rebind(`SELECT "id" FROM "rule" WHERE "name" = ? AND "deleted" = 'n'`)
+	if err := imp.tx.SelectContext(imp.ctx, &ids, stmt, name); err != nil {
+		return false, err
+	}
+
+	for _, id := range ids {
+		for _, query := range []string{
+			`UPDATE "rule_escalation_recipient" SET "deleted" = 'y', "changed_at" = ?
+				WHERE "deleted" = 'n' AND "rule_escalation_id" IN (SELECT "id" FROM "rule_escalation" WHERE "rule_id" = ?)`,
+			// The position must be NULLed for deleted escalations, see the rule_escalation table's constraints.
+			`UPDATE "rule_escalation" SET "deleted" = 'y', "position" = NULL, "changed_at" = ?
+				WHERE "deleted" = 'n' AND "rule_id" = ?`,
+			`UPDATE "rule" SET "deleted" = 'y', "changed_at" = ? WHERE "id" = ?`,
+		} {
+			if _, err := imp.tx.ExecContext(imp.ctx, imp.tx.Rebind(query), imp.now, id); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return len(ids) > 0, nil
+}
+
+// insertRule inserts the rule with all its escalations and recipients. All references must be resolvable.
+func (imp *importer) insertRule(r *Rule) error {
+	entry := baseconf.IncrementalPkDbEntry[int64]{
+		IncrementalDbEntry: baseconf.IncrementalDbEntry{
+			ChangedAt: imp.now,
+			Deleted:   types.Bool{Bool: false, Valid: true},
+		},
+	}
+
+	ruleID, err := imp.insert(&ruleRow{
+		IncrementalPkDbEntry: entry,
+		Name:                 r.Name,
+		TimePeriodID:         utils.ToDBInt(r.TimePeriodID),
+		ObjectFilter:         utils.ToDBString(r.ObjectFilter),
+	})
+	if err != nil {
+		return err
+	}
+
+	for position, e := range r.Escalations {
+		escalationID, err := imp.insert(&escalationRow{
+			IncrementalPkDbEntry: entry,
+			RuleID:               ruleID,
+			Position:             position,
+			Condition:            utils.ToDBString(e.Condition),
+			Name:                 utils.ToDBString(e.Name),
+			RenotifyInterval:     utils.ToDBInt(e.RenotifyInterval.Milliseconds()),
+
+			RequiredAcknowledgements: utils.ToDBInt(e.RequiredAcknowledgements),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, rec := range e.Recipients {
+			key, err := imp.resolveRecipient(rec)
+			if err != nil {
+				return err
+			}
+
+			var channelID int64
+			if rec.Channel != "" {
+				if channelID, err = imp.resolve("channel", "name", rec.Channel); err != nil {
+					return err
+				}
+			}
+
+			delivery := rec.Delivery
+			if delivery == "" {
+				delivery = rule.DeliveryAll
+			}
+
+			_, err = imp.insert(&escalationRecipientRow{
+				IncrementalPkDbEntry: entry,
+				EscalationID:         escalationID,
+				Key:                  key,
+				ChannelID:            utils.ToDBInt(channelID),
+				Delivery:             delivery,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// insert a row and return its newly assigned ID.
+func (imp *importer) insert(row any) (int64, error) {
+	return utils.InsertAndFetchId(imp.ctx, imp.tx, utils.BuildInsertStmtWithout(imp.db, row, "id"), row)
+}
+
+type ruleRow struct {
+	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+
+	Name         string       `db:"name"`
+	TimePeriodID types.Int    `db:"timeperiod_id"`
+	ObjectFilter types.String `db:"object_filter"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (r *ruleRow) TableName() string {
+	return "rule"
+}
+
+type escalationRow struct {
+	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+
+	RuleID    int64        `db:"rule_id"`
+	Position  int          `db:"position"`
+	Condition types.String `db:"condition"`
+	Name      types.String `db:"name"`
+
+	RenotifyInterval         types.Int `db:"renotify_interval"`
+	RequiredAcknowledgements types.Int `db:"required_acknowledgements"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (e *escalationRow) TableName() string {
+	return "rule_escalation"
+}
+
+type escalationRecipientRow struct {
+	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
+
+	EscalationID  int64 `db:"rule_escalation_id"`
+	recipient.Key `db:",inline"`
+	ChannelID     types.Int             `db:"channel_id"`
+	Delivery      rule.DeliveryStrategy `db:"delivery"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (r *escalationRecipientRow) TableName() string {
+	return "rule_escalation_recipient"
+}
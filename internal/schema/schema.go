@@ -0,0 +1,124 @@
+// Package schema implements the version handshake with the database schema, which is shared with the Icinga Web module.
+//
+// Both the daemon and the web module may be upgraded independently of each other and of the schema. Thus, the daemon
+// checks the version of the schema at startup and refuses to start against a schema older than MinVersion. Against a
+// newer schema version, it only refuses to run destructive operations, e.g., deleting or repairing configuration
+// objects, as it does not know this version. This prevents silently corrupting data during a mixed-version upgrade.
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-notifications/internal/errs"
+	"sync/atomic"
+)
+
+const (
+	// MinVersion is the oldest schema version supported by this daemon.
+	MinVersion = 2
+
+	// MaxVersion is the newest schema version supported by this daemon, i.e., the one shipped with it.
+	MaxVersion = 2
+)
+
+// Info describes the schema version found in the database and the range supported by this daemon.
+type Info struct {
+	// Version of the database schema, or 0 if it is unknown, e.g., because the schema predates the version table.
+	Version      int `json:"version"`
+	MinSupported int `json:"min_supported"`
+	MaxSupported int `json:"max_supported"`
+}
+
+// NewInfo creates an Info for the given schema version with the range supported by this daemon.
+func NewInfo(version int) *Info {
+	return &Info{Version: version, MinSupported: MinVersion, MaxSupported: MaxVersion}
+}
+
+// Compatible reports whether the schema version is within the supported range.
+func (i *Info) Compatible() bool {
+	return i.Version >= i.MinSupported && i.Version <= i.MaxSupported
+}
+
+// Check returns an error wrapping errs.ErrSchemaIncompatible unless the schema version is within the supported range.
+func (i *Info) Check() error {
+	switch {
+	case i.Version == 0:
+		return fmt.Errorf("%w: unknown schema version, expected %d to %d", errs.ErrSchemaIncompatible, i.MinSupported,
+			i.MaxSupported)
+	case i.Version < i.MinSupported:
+		return fmt.Errorf("%w: schema version %d is too old, please upgrade the schema to at least version %d",
+			errs.ErrSchemaIncompatible, i.Version, i.MinSupported)
+	case i.Version > i.MaxSupported:
+		return fmt.Errorf("%w: schema version %d is newer than the supported version %d, please upgrade the daemon",
+			errs.ErrSchemaIncompatible, i.Version, i.MaxSupported)
+	default:
+		return nil
+	}
+}
+
+// current holds the Info of the last Load, or nil if the schema version has not been loaded yet.
+var current atomic.Pointer[Info]
+
+// Load reads the current schema version from the database and stores it for Current and RequireCompatible.
+//
+// A missing version table is not considered an error, but results in version 0, as it indicates a schema predating
+// the version handshake. Only other database errors are returned.
+func Load(ctx context.Context, db *database.DB) (*Info, error) {
+	exists, err := tableExists(ctx, db, "icinga_notifications_schema")
+	if err != nil {
+		return nil, errs.WrapDB(err)
+	}
+
+	var version sql.NullInt64
+	if exists {
+		err := db.QueryRowxContext(ctx, `SELECT MAX("version") FROM "icinga_notifications_schema"`).Scan(&version)
+		if err != nil {
+			return nil, errs.WrapDB(fmt.Errorf("cannot fetch schema version: %w", err))
+		}
+	}
+
+	info := NewInfo(int(version.Int64))
+	current.Store(info)
+
+	return info, nil
+}
+
+// tableExists checks whether the given table exists in the currently used database or schema.
+func tableExists(ctx context.Context, db *database.DB, table string) (bool, error) {
+	var query string
+	switch db.DriverName() {
+	case database.PostgreSQL:
+		query = `SELECT 1 FROM "information_schema"."tables" WHERE "table_schema" = CURRENT_SCHEMA() AND "table_name" = ?`
+	default:
+		query = `SELECT 1 FROM "information_schema"."tables" WHERE "table_schema" = DATABASE() AND "table_name" = ?`
+	}
+
+	var exists int
+	err := db.QueryRowxContext(ctx, db.Rebind(query), table).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("cannot check whether table %q exists: %w", table, err)
+	}
+
+	return true, nil
+}
+
+// Current returns the Info of the last Load, or an Info with the unknown version 0 if it has not been loaded yet.
+func Current() *Info {
+	if info := current.Load(); info != nil {
+		return info
+	}
+
+	return NewInfo(0)
+}
+
+// RequireCompatible must be called before any destructive operation, i.e., one deleting or rewriting existing rows.
+//
+// It returns an error wrapping errs.ErrSchemaIncompatible unless the loaded schema version is supported, see Load.
+func RequireCompatible() error {
+	return Current().Check()
+}
@@ -0,0 +1,48 @@
+package schema
+
+import (
+	"github.com/icinga/icinga-notifications/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestInfo_Check(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		info       *Info
+		compatible bool
+	}{
+		{"unknown", &Info{Version: 0, MinSupported: 2, MaxSupported: 3}, false},
+		{"too-old", &Info{Version: 1, MinSupported: 2, MaxSupported: 3}, false},
+		{"min", &Info{Version: 2, MinSupported: 2, MaxSupported: 3}, true},
+		{"max", &Info{Version: 3, MinSupported: 2, MaxSupported: 3}, true},
+		{"too-new", &Info{Version: 4, MinSupported: 2, MaxSupported: 3}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.compatible, tt.info.Compatible())
+			if err := tt.info.Check(); tt.compatible {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, errs.ErrSchemaIncompatible)
+			}
+		})
+	}
+}
+
+func TestRequireCompatible(t *testing.T) {
+	info := Current()
+	require.Equal(t, 0, info.Version, "the schema version must be unknown before loading it")
+	assert.ErrorIs(t, RequireCompatible(), errs.ErrSchemaIncompatible)
+
+	current.Store(NewInfo(MaxVersion))
+	defer current.Store(nil)
+
+	assert.NoError(t, RequireCompatible())
+}
@@ -0,0 +1,187 @@
+// Package selftest sends daily test notifications via the channels to a dedicated contact, e.g., a monitoring of
+// monitoring team, detecting channels which silently broke, e.g., due to expired credentials, before they are needed.
+package selftest
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/logging"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/channel"
+	"github.com/icinga/icinga-notifications/internal/config"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/msgtemplate"
+	"github.com/icinga/icinga-notifications/internal/recipient"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"go.uber.org/zap"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Result of testing a single channel, as stored in the channel_self_test table.
+type Result struct {
+	ChannelID int64           `db:"channel_id"`
+	ContactID int64           `db:"contact_id"`
+	Time      types.UnixMilli `db:"time"`
+	Success   types.Bool      `db:"success"`
+	Error     types.String    `db:"error"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (r *Result) TableName() string {
+	return "channel_self_test"
+}
+
+// Tester sends the test notifications daily, recording the result of each channel.
+//
+// If some channels fail, the contact is alerted about them via the channels which succeeded.
+type Tester struct {
+	DB            *database.DB
+	RuntimeConfig *config.RuntimeConfig
+	Logger        *logging.Logger
+	Config        daemon.SelfTestConfig
+}
+
+// Run the daily self-tests at the configured time until the context is done.
+func (t *Tester) Run(ctx context.Context) {
+	at, err := time.Parse("15:04", t.Config.At)
+	if err != nil {
+		t.Logger.Errorw("Cannot schedule self-tests", zap.Error(err))
+		return
+	}
+
+	for {
+		next := nextRun(time.Now(), at)
+		t.Logger.Debugw("Scheduled next self-test", zap.Time("at", next))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case now := <-timer.C:
+			t.RunOnce(ctx, now)
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// RunOnce tests all configured channels sequentially, returning their results.
+func (t *Tester) RunOnce(ctx context.Context, now time.Time) []*Result {
+	t.RuntimeConfig.RLock()
+	contact := t.RuntimeConfig.GetContact(t.Config.Contact)
+	var channels []*channel.Channel
+	templates := make(map[int64]*msgtemplate.Template)
+	for _, ch := range t.RuntimeConfig.Channels {
+		if len(t.Config.Channels) == 0 || slices.Contains(t.Config.Channels, ch.ID) {
+			channels = append(channels, ch)
+			templates[ch.ID] = t.RuntimeConfig.GetNotificationTemplate(ch.ID, nil)
+		}
+	}
+	t.RuntimeConfig.RUnlock()
+
+	if contact == nil {
+		t.Logger.Errorw("Cannot run self-test, unknown contact", zap.String("contact", t.Config.Contact))
+		return nil
+	}
+	for _, id := range t.Config.Channels {
+		if !slices.ContainsFunc(channels, func(ch *channel.Channel) bool { return ch.ID == id }) {
+			t.Logger.Warnw("Cannot self-test unknown channel", zap.Int64("channel_id", id))
+		}
+	}
+	slices.SortFunc(channels, func(a, b *channel.Channel) int { return cmp.Compare(a.ID, b.ID) })
+
+	t.Logger.Infow("Running self-test", zap.String("contact", contact.FullName), zap.Int("channels", len(channels)))
+
+	var results []*Result
+	var succeeded, failed []*channel.Channel
+	for _, ch := range channels {
+		result := &Result{
+			ChannelID: ch.ID,
+			ContactID: contact.ID,
+			Time:      types.UnixMilli(now),
+			Success:   types.Bool{Bool: true, Valid: true},
+		}
+
+		message := "This is the daily self-test of the channel. No action is required."
+		if err := send(ch, templates[ch.ID], contact, now, message); err != nil {
+			t.Logger.Warnw("Self-test of channel failed", zap.Object("channel", ch), zap.Error(err))
+			result.Success.Bool = false
+			result.Error = utils.ToDBString(err.Error())
+			failed = append(failed, ch)
+		} else {
+			t.Logger.Debugw("Self-test of channel succeeded", zap.Object("channel", ch))
+			succeeded = append(succeeded, ch)
+		}
+
+		stmt, _ := t.DB.BuildInsertStmt(result)
+		if _, err := t.DB.NamedExecContext(ctx, stmt, result); err != nil {
+			t.Logger.Errorw("Cannot record self-test result", zap.Object("channel", ch), zap.Error(err))
+		}
+
+		results = append(results, result)
+	}
+
+	if len(failed) == 0 {
+		return results
+	}
+	if len(succeeded) == 0 {
+		t.Logger.Errorw("Self-test failed for all channels, cannot alert anyone", zap.Int("channels", len(failed)))
+		return results
+	}
+
+	message := alertMessage(failed, results)
+	for _, ch := range succeeded {
+		if err := send(ch, templates[ch.ID], contact, now, message); err != nil {
+			t.Logger.Errorw("Cannot alert about failed self-test", zap.Object("channel", ch), zap.Error(err))
+		}
+	}
+
+	return results
+}
+
+// send a test notification with the given message via the channel to the contact, see channel.NotifyTest.
+//
+// If the channel's template fails, the default one is used, as the channel itself is to be tested.
+func send(ch *channel.Channel, tmpl *msgtemplate.Template, contact *recipient.Contact, now time.Time, message string) error {
+	nr := channel.NewTestNotificationRequest(contact, daemon.Config().Icingaweb2URL, now)
+	nr.Event.Message = message
+
+	data := msgtemplate.NewData(nr, nil)
+	subject, body, err := tmpl.Render(data)
+	if err != nil {
+		if subject, body, err = msgtemplate.RenderDefault(data); err != nil {
+			return err
+		}
+	}
+	nr.Subject, nr.Message = subject, body
+
+	return ch.NotifyTest(nr)
+}
+
+// alertMessage describes the failed channels together with their errors.
+func alertMessage(failed []*channel.Channel, results []*Result) string {
+	var b strings.Builder
+	b.WriteString("The daily self-test failed for the following channels, which may not deliver notifications:\n")
+	for _, ch := range failed {
+		for _, result := range results {
+			if result.ChannelID == ch.ID {
+				_, _ = fmt.Fprintf(&b, "\n- %s (%s): %s", ch.Name, ch.Type, result.Error.String)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// nextRun returns the next occurrence of the time of day after now, in now's location.
+func nextRun(now time.Time, at time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, at.Hour(), at.Minute(), 0, 0, now.Location())
+	}
+
+	return next
+}
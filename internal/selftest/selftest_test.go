@@ -0,0 +1,61 @@
+package selftest
+
+import (
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/channel"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNextRun(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(0, 1, 1, 8, 30, 0, 0, time.UTC)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("cannot load time zone: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"LaterToday", time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)},
+		{"Tomorrow", time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 8, 30, 0, 0, time.UTC)},
+		{"Exactly", time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC), time.Date(2024, 3, 2, 8, 30, 0, 0, time.UTC)},
+		{"EndOfMonth", time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)},
+		{"DaylightSaving", time.Date(2024, 3, 30, 12, 0, 0, 0, berlin), time.Date(2024, 3, 31, 8, 30, 0, 0, berlin)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, nextRun(tt.now, at))
+		})
+	}
+}
+
+func TestAlertMessage(t *testing.T) {
+	t.Parallel()
+
+	newChannel := func(id int64, name, typ string) *channel.Channel {
+		ch := &channel.Channel{Name: name, Type: typ}
+		ch.ID = id
+		return ch
+	}
+
+	failed := []*channel.Channel{newChannel(1, "Mail", "email"), newChannel(3, "Chat", "rocketchat")}
+	results := []*Result{
+		{ChannelID: 1, Error: utils.ToDBString("connection refused")},
+		{ChannelID: 2, Success: types.Bool{Bool: true, Valid: true}},
+		{ChannelID: 3, Error: utils.ToDBString("invalid token")},
+	}
+
+	assert.Equal(t, "The daily self-test failed for the following channels, which may not deliver notifications:\n"+
+		"\n- Mail (email): connection refused"+
+		"\n- Chat (rocketchat): invalid token", alertMessage(failed, results))
+}
@@ -0,0 +1,59 @@
+// Package shard splits the incident processing among multiple active daemon instances.
+//
+// Each object is assigned to one of a fixed number of shards by hashing its ID, which is already a hash of its source
+// and tags. Each instance serves its configured shards and ignores events of objects of other shards. Instances serving
+// overlapping shards are not active at the same time, see package ha, guarding against double processing.
+package shard
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/icinga/icinga-notifications/internal/daemon"
+)
+
+// ErrNotOwned indicates an event of an object belonging to a shard not served by this instance.
+var ErrNotOwned = errors.New("object belongs to a shard not served by this instance")
+
+var (
+	// count of shards, being the same for all instances.
+	count = 1
+	// mask has the bits of the shards served by this instance set.
+	mask uint64 = 1
+)
+
+// Configure the shards served by this instance. It must be called before processing any events, otherwise this
+// instance serves all objects.
+func Configure(conf daemon.ShardingConfig) {
+	count = conf.Count
+	mask = conf.Mask()
+}
+
+// Count returns the number of shards.
+func Count() int {
+	return count
+}
+
+// Mask returns a bitmask of the shards served by this instance.
+func Mask() uint64 {
+	return mask
+}
+
+// Primary returns whether this instance serves the first shard, being responsible for jobs unrelated to objects, e.g.,
+// importing schedules, once for all instances.
+func Primary() bool {
+	return mask&1 != 0
+}
+
+// Of returns the shard of the object ID among the given number of shards.
+func Of(objectID []byte, count int) int {
+	if count <= 1 || len(objectID) < 8 {
+		return 0
+	}
+
+	return int(binary.BigEndian.Uint64(objectID) % uint64(count))
+}
+
+// Owns returns whether the object of the given ID belongs to a shard served by this instance.
+func Owns(objectID []byte) bool {
+	return mask&(1<<Of(objectID, count)) != 0
+}
@@ -0,0 +1,31 @@
+package shard
+
+import (
+	"github.com/icinga/icinga-notifications/internal/daemon"
+	"github.com/icinga/icinga-notifications/internal/object"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0, Of([]byte{0, 0, 0, 0, 0, 0, 0, 5}, 1), "single shard")
+	assert.Equal(t, 2, Of([]byte{0, 0, 0, 0, 0, 0, 0, 5}, 3))
+	assert.Equal(t, 1, Of([]byte{0, 0, 0, 0, 0, 0, 1, 0, 0xff}, 3), "only the first eight bytes count")
+	assert.Equal(t, 0, Of(nil, 3), "invalid object ID")
+
+	shards := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		shards[Of(object.ID(1, map[string]string{"host": string(rune('a' + i%26)), "i": string(rune(i))}), 4)]++
+	}
+	assert.Len(t, shards, 4, "objects should be spread across all shards")
+}
+
+func TestShardingConfig_Mask(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, uint64(0b1111), (&daemon.ShardingConfig{Count: 4}).Mask(), "all shards by default")
+	assert.Equal(t, uint64(0b1010), (&daemon.ShardingConfig{Count: 4, Shards: []int{1, 3}}).Mask())
+	assert.Equal(t, uint64(1), (&daemon.ShardingConfig{Count: 1}).Mask())
+}
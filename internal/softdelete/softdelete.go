@@ -0,0 +1,410 @@
+// Package softdelete deletes channels, contacts, rules and schedules in a restorable way.
+//
+// Deleting an object marks it and all rows depending on it as deleted, just like the web UI does. Additionally, all
+// affected rows are recorded, allowing the deletion to be reverted within a grace period. As the RuntimeConfig only
+// loads non-deleted rows, soft-deleted objects are inactive, while open incidents still referencing them stay intact.
+package softdelete
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/schema"
+	"github.com/icinga/icinga-notifications/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned if there is no non-deleted object to be deleted.
+	ErrNotFound = errors.New("object does not exist")
+
+	// ErrInUse is returned if an object is still referenced by other non-deleted objects.
+	ErrInUse = errors.New("object is still in use")
+
+	// ErrNotRestorable is returned if there is no deletion within the grace period to be restored.
+	ErrNotRestorable = errors.New("object cannot be restored")
+)
+
+// ObjectType names the kind of object to be deleted or restored.
+type ObjectType string
+
+const (
+	ObjectChannel  ObjectType = "channel"
+	ObjectContact  ObjectType = "contact"
+	ObjectRule     ObjectType = "rule"
+	ObjectSchedule ObjectType = "schedule"
+)
+
+// Validate returns an error if the ObjectType is unknown.
+func (t ObjectType) Validate() error {
+	if _, ok := objectSpecs[t]; !ok {
+		return fmt.Errorf("unknown object type %q", t)
+	}
+
+	return nil
+}
+
+// dependentRows selects rows of a table depending on the deleted object.
+type dependentRows struct {
+	table string
+	// key lists the columns identifying a single row.
+	key []string
+	// where selects the rows, each "?" is replaced by the object's ID.
+	where string
+}
+
+// objectSpec describes how an ObjectType is deleted.
+type objectSpec struct {
+	// uniqueColumn is NULLed for deletion, as its UNIQUE constraint would otherwise block reusing the value.
+	uniqueColumn string
+	// references lists rows blocking the deletion as long as they are not deleted themselves.
+	references []dependentRows
+	// dependents lists rows being deleted together with the object, deepest dependencies first.
+	dependents []dependentRows
+}
+
+var objectSpecs = map[ObjectType]objectSpec{
+	ObjectChannel: {
+		references: []dependentRows{
+			{table: "contact", where: `"default_channel_id" = ?`},
+			{table: "rule_escalation_recipient", where: `"channel_id" = ?`},
+		},
+		dependents: []dependentRows{
+			{table: "contact_channel_preference", key: []string{"id"}, where: `"channel_id" = ?`},
+		},
+	},
+	ObjectContact: {
+		uniqueColumn: "username",
+		references: []dependentRows{
+			{table: "rule_escalation_recipient", where: `"contact_id" = ?`},
+			{table: "rotation_member", where: `"contact_id" = ?`},
+			{table: "contact", where: `"substitute_contact_id" = ?`},
+		},
+		dependents: []dependentRows{
+			{table: "contact_address", key: []string{"id"}, where: `"contact_id" = ?`},
+			{table: "contact_channel_preference", key: []string{"id"}, where: `"contact_id" = ?`},
+			{table: "contactgroup_member", key: []string{"contactgroup_id", "contact_id"}, where: `"contact_id" = ?`},
+			{
+				table: "schedule_override", key: []string{"id"},
+				where: `"contact_id" = ? OR "replaced_contact_id" = ?`,
+			},
+		},
+	},
+	ObjectRule: {
+		dependents: []dependentRows{
+			{
+				table: "rule_escalation_recipient", key: []string{"id"},
+				where: `"rule_escalation_id" IN (SELECT "id" FROM "rule_escalation" WHERE "rule_id" = ?)`,
+			},
+			{table: "rule_escalation", key: []string{"id"}, where: `"rule_id" = ?`},
+		},
+	},
+	ObjectSchedule: {
+		references: []dependentRows{
+			{table: "rule_escalation_recipient", where: `"schedule_id" = ?`},
+		},
+		dependents: []dependentRows{
+			{
+				table: "timeperiod_entry", key: []string{"id"},
+				where: `"timeperiod_id" IN (SELECT "id" FROM "timeperiod" WHERE "owned_by_rotation_id" IN (
+					SELECT "id" FROM "rotation" WHERE "schedule_id" = ?))`,
+			},
+			{
+				table: "timeperiod", key: []string{"id"},
+				where: `"owned_by_rotation_id" IN (SELECT "id" FROM "rotation" WHERE "schedule_id" = ?)`,
+			},
+			{
+				table: "rotation_member", key: []string{"id"},
+				where: `"rotation_id" IN (SELECT "id" FROM "rotation" WHERE "schedule_id" = ?)`,
+			},
+			{table: "rotation", key: []string{"id"}, where: `"schedule_id" = ?`},
+			{table: "schedule_override", key: []string{"id"}, where: `"schedule_id" = ?`},
+		},
+	},
+}
+
+// Deletion records a soft-deleted object together with all rows deleted alongside it.
+type Deletion struct {
+	ID         int64           `db:"id" json:"id"`
+	ObjectType ObjectType      `db:"object_type" json:"type"`
+	ObjectID   int64           `db:"object_id" json:"object_id"`
+	DeletedAt  types.UnixMilli `db:"deleted_at" json:"deleted_at"`
+	// RestoreData is a JSON encoded list of deletedRow, the object itself being the last one.
+	RestoreData string `db:"restore_data" json:"-"`
+}
+
+// TableName implements the contracts.TableNamer interface.
+func (d *Deletion) TableName() string {
+	return "soft_deletion"
+}
+
+// Restorable checks whether this deletion happened within the grace period before now.
+func (d *Deletion) Restorable(now time.Time, gracePeriod time.Duration) bool {
+	return !d.DeletedAt.Time().Before(now.Add(-gracePeriod))
+}
+
+// deletedRow identifies a single row marked as deleted by its key columns.
+type deletedRow struct {
+	Table string           `json:"table"`
+	Key   map[string]int64 `json:"key"`
+	// Unique holds the value of the NULLed objectSpec.uniqueColumn, if any.
+	Unique types.String `json:"unique,omitempty"`
+}
+
+// Delete marks the object of the given type and ID as deleted, together with all its dependent rows.
+//
+// The deletion is refused with ErrInUse if the object is still referenced, e.g., a contact being an escalation
+// recipient. Deletions older than the grace period are no longer recorded and thus cannot be restored anymore. Both
+// Delete and Restore are refused unless the database schema is supported, see schema.RequireCompatible.
+func Delete(ctx context.Context, db *database.DB, t ObjectType, id int64, gracePeriod time.Duration) (*Deletion, error) {
+	if err := schema.RequireCompatible(); err != nil {
+		return nil, err
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	spec := objectSpecs[t]
+
+	now := time.Now()
+	deletion := &Deletion{ObjectType: t, ObjectID: id, DeletedAt: types.UnixMilli(now)}
+
+	err := utils.RunInTx(ctx, db, func(tx *sqlx.Tx) error {
+		if err := purge(ctx, tx, now.Add(-gracePeriod)); err != nil {
+			return err
+		}
+
+		root := deletedRow{Table: string(t), Key: map[string]int64{"id": id}}
+		columns := `"id"`
+		if spec.uniqueColumn != "" {
+			columns = fmt.Sprintf(`"id", "%s" AS "unique"`, spec.uniqueColumn)
+		}
+
+		var object struct {
+			ID     int64        `db:"id"`
+			Unique types.String `db:"unique"`
+		}
+		stmt := tx.Rebind(fmt.Sprintf(`SELECT %s FROM "%s" WHERE "id" = ? AND "deleted" = 'n'`, columns, t))
+		if err := tx.GetContext(ctx, &object, stmt, id); errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s %d", ErrNotFound, t, id)
+		} else if err != nil {
+			return err
+		}
+		root.Unique = object.Unique
+
+		var inUse []string
+		for _, ref := range spec.references {
+			var count int64
+			stmt := fmt.Sprintf(`SELECT COUNT(*) FROM "%s" WHERE "deleted" = 'n' AND (%s)`, ref.table, ref.where)
+			if err := tx.GetContext(ctx, &count, tx.Rebind(stmt), repeatID(ref.where, id)...); err != nil {
+				return err
+			}
+			if count > 0 {
+				inUse = append(inUse, fmt.Sprintf("%d %s", count, ref.table))
+			}
+		}
+		if len(inUse) > 0 {
+			return fmt.Errorf("%w: %s %d is referenced by %s", ErrInUse, t, id, strings.Join(inUse, ", "))
+		}
+
+		var deleted []deletedRow
+		for _, dep := range spec.dependents {
+			depRows, err := selectKeys(ctx, tx, dep, id)
+			if err != nil {
+				return err
+			}
+			deleted = append(deleted, depRows...)
+		}
+		deleted = append(deleted, root)
+
+		for _, row := range deleted {
+			set := ""
+			if row.Unique.Valid {
+				set = fmt.Sprintf(`, "%s" = NULL`, spec.uniqueColumn)
+			}
+
+			where, args := row.whereKey()
+			stmt := fmt.Sprintf(`UPDATE "%s" SET "deleted" = 'y', "changed_at" = ?%s WHERE %s`, row.Table, set, where)
+			if _, err := tx.ExecContext(ctx, tx.Rebind(stmt), append([]any{deletion.DeletedAt}, args...)...); err != nil {
+				return fmt.Errorf("cannot delete %s row: %w", row.Table, err)
+			}
+		}
+
+		restoreData, err := json.Marshal(deleted)
+		if err != nil {
+			return err
+		}
+		deletion.RestoreData = string(restoreData)
+
+		deletion.ID, err = utils.InsertAndFetchId(ctx, tx, utils.BuildInsertStmtWithout(db, deletion, "id"), deletion)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deletion, nil
+}
+
+// Restore reverts the latest deletion of the object of the given type and ID, if it happened within the grace period.
+//
+// All rows deleted alongside the object are restored as well. Restoring fails if the object has been altered since,
+// e.g., a contact's username being reused by another contact.
+func Restore(ctx context.Context, db *database.DB, t ObjectType, id int64, gracePeriod time.Duration) (*Deletion, error) {
+	if err := schema.RequireCompatible(); err != nil {
+		return nil, err
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	spec := objectSpecs[t]
+
+	now := time.Now()
+	var deletion *Deletion
+
+	err := utils.RunInTx(ctx, db, func(tx *sqlx.Tx) error {
+		if err := purge(ctx, tx, now.Add(-gracePeriod)); err != nil {
+			return err
+		}
+
+		var deletions []*Deletion
+		stmt := tx.Rebind(`SELECT * FROM "soft_deletion" WHERE "object_type" = ? AND "object_id" = ?
+			ORDER BY "deleted_at" DESC`)
+		if err := tx.SelectContext(ctx, &deletions, stmt, t, id); err != nil {
+			return err
+		}
+		if len(deletions) == 0 || !deletions[0].Restorable(now, gracePeriod) {
+			return fmt.Errorf("%w: no deletion of %s %d within the last %s", ErrNotRestorable, t, id, gracePeriod)
+		}
+		deletion = deletions[0]
+
+		var deleted []deletedRow
+		if err := json.Unmarshal([]byte(deletion.RestoreData), &deleted); err != nil {
+			return fmt.Errorf("cannot decode restore data: %w", err)
+		}
+
+		// Restore the object first, followed by its dependents in reversed order of their deletion.
+		for n := len(deleted) - 1; n >= 0; n-- {
+			row := deleted[n]
+
+			set := ""
+			args := []any{types.UnixMilli(now)}
+			if row.Unique.Valid {
+				set = fmt.Sprintf(`, "%s" = ?`, spec.uniqueColumn)
+				args = append(args, row.Unique)
+			}
+
+			where, keyArgs := row.whereKey()
+			stmt := fmt.Sprintf(
+				`UPDATE "%s" SET "deleted" = 'n', "changed_at" = ?%s WHERE "deleted" = 'y' AND %s`, row.Table, set, where)
+			result, err := tx.ExecContext(ctx, tx.Rebind(stmt), append(args, keyArgs...)...)
+			if err != nil {
+				return fmt.Errorf("cannot restore %s row: %w", row.Table, err)
+			}
+
+			if n == len(deleted)-1 {
+				if affected, err := result.RowsAffected(); err != nil {
+					return err
+				} else if affected == 0 {
+					return fmt.Errorf("%w: %s %d is not deleted anymore", ErrNotRestorable, t, id)
+				}
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM "soft_deletion" WHERE "id" = ?`), deletion.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deletion, nil
+}
+
+// List returns all deletions still restorable within the grace period, the most recent ones first.
+func List(ctx context.Context, db *database.DB, gracePeriod time.Duration) ([]*Deletion, error) {
+	var deletions []*Deletion
+	stmt := db.Rebind(`SELECT * FROM "soft_deletion" WHERE "deleted_at" >= ? ORDER BY "deleted_at" DESC`)
+	if err := db.SelectContext(ctx, &deletions, stmt, types.UnixMilli(time.Now().Add(-gracePeriod))); err != nil {
+		return nil, err
+	}
+
+	return deletions, nil
+}
+
+// purge removes all records of deletions older than the given time, making them permanent.
+func purge(ctx context.Context, tx *sqlx.Tx, before time.Time) error {
+	_, err := tx.ExecContext(ctx, tx.Rebind(`DELETE FROM "soft_deletion" WHERE "deleted_at" < ?`), types.UnixMilli(before))
+	return err
+}
+
+// selectKeys returns the keys of all non-deleted rows selected by dep for the object of the given ID.
+func selectKeys(ctx context.Context, tx *sqlx.Tx, dep dependentRows, id int64) ([]deletedRow, error) {
+	columns := make([]string, 0, len(dep.key))
+	for _, column := range dep.key {
+		columns = append(columns, fmt.Sprintf(`"%s"`, column))
+	}
+
+	stmt := fmt.Sprintf(`SELECT %s FROM "%s" WHERE "deleted" = 'n' AND (%s)`,
+		strings.Join(columns, ", "), dep.table, dep.where)
+	rows, err := tx.QueryContext(ctx, tx.Rebind(stmt), repeatID(dep.where, id)...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var deleted []deletedRow
+	for rows.Next() {
+		values := make([]int64, len(dep.key))
+		dest := make([]any, len(dep.key))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := deletedRow{Table: dep.table, Key: make(map[string]int64, len(dep.key))}
+		for i, column := range dep.key {
+			row.Key[column] = values[i]
+		}
+		deleted = append(deleted, row)
+	}
+
+	return deleted, rows.Err()
+}
+
+// whereKey returns a WHERE condition matching this row by its key together with its arguments.
+func (r deletedRow) whereKey() (string, []any) {
+	columns := make([]string, 0, len(r.Key))
+	for column := range r.Key {
+		columns = append(columns, column)
+	}
+	slices.Sort(columns)
+
+	conditions := make([]string, 0, len(columns))
+	args := make([]any, 0, len(columns))
+	for _, column := range columns {
+		conditions = append(conditions, fmt.Sprintf(`"%s" = ?`, column))
+		args = append(args, r.Key[column])
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// repeatID returns the ID as argument for each placeholder in the given condition.
+func repeatID(where string, id int64) []any {
+	args := make([]any, strings.Count(where, "?"))
+	for i := range args {
+		args[i] = id
+	}
+
+	return args
+}
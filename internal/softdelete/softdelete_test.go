@@ -0,0 +1,83 @@
+package softdelete
+
+import (
+	"encoding/json"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestObjectType_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, ot := range []ObjectType{ObjectChannel, ObjectContact, ObjectRule, ObjectSchedule} {
+		assert.NoError(t, ot.Validate(), ot)
+	}
+
+	assert.Error(t, ObjectType("contactgroup").Validate())
+	assert.Error(t, ObjectType("").Validate())
+}
+
+func TestDeletion_Restorable(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	grace := 7 * 24 * time.Hour
+
+	tests := []struct {
+		name       string
+		deletedAt  time.Time
+		restorable bool
+	}{
+		{"Recent", now.Add(-time.Hour), true},
+		{"AtGracePeriodEnd", now.Add(-grace), true},
+		{"Expired", now.Add(-grace - time.Millisecond), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := &Deletion{DeletedAt: types.UnixMilli(tt.deletedAt)}
+			assert.Equal(t, tt.restorable, d.Restorable(now, grace))
+		})
+	}
+}
+
+func TestDeletedRow(t *testing.T) {
+	t.Parallel()
+
+	t.Run("WhereKey", func(t *testing.T) {
+		t.Parallel()
+
+		row := deletedRow{Table: "contactgroup_member", Key: map[string]int64{"contact_id": 2, "contactgroup_id": 1}}
+		where, args := row.whereKey()
+		assert.Equal(t, `"contact_id" = ? AND "contactgroup_id" = ?`, where)
+		assert.Equal(t, []any{int64(2), int64(1)}, args)
+	})
+
+	t.Run("JSONRoundTrip", func(t *testing.T) {
+		t.Parallel()
+
+		rows := []deletedRow{
+			{Table: "contact_address", Key: map[string]int64{"id": 5}},
+			{Table: "contact", Key: map[string]int64{"id": 1}, Unique: types.MakeString("jdoe")},
+		}
+
+		data, err := json.Marshal(rows)
+		require.NoError(t, err)
+
+		var decoded []deletedRow
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, rows, decoded)
+	})
+}
+
+func TestRepeatID(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []any{int64(3), int64(3)}, repeatID(`"contact_id" = ? OR "replaced_contact_id" = ?`, 3))
+	assert.Empty(t, repeatID(`"deleted" = 'n'`, 3))
+}
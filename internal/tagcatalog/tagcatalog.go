@@ -0,0 +1,173 @@
+// Package tagcatalog maintains a rolling catalog of the tag keys and sample values observed on events per source,
+// e.g., to offer autocompletion in rule editors.
+//
+// The catalog is kept in memory only. It is bounded by the number of keys per source and samples per key, and keys not
+// observed for MaxAge are dropped.
+package tagcatalog
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxKeysPerSource limits the keys per source, evicting the least recently observed key.
+	MaxKeysPerSource = 1000
+
+	// MaxSamplesPerKey limits the distinct sample values kept per key, keeping the most recently observed ones.
+	MaxSamplesPerKey = 10
+
+	// MaxSampleLength is the maximum length of a value to be kept as a sample, as long values are rarely useful for
+	// autocompletion.
+	MaxSampleLength = 256
+
+	// MaxAge is the duration after which keys not observed anymore are dropped.
+	MaxAge = 7 * 24 * time.Hour
+)
+
+// Tag summarizes the observations of a tag key of a source.
+type Tag struct {
+	SourceID int64     `json:"source_id"`
+	Key      string    `json:"key"`
+	Extra    bool      `json:"extra"`
+	LastSeen time.Time `json:"last_seen"`
+	Count    int64     `json:"count"`
+
+	// Samples are distinct values, most recently observed first.
+	Samples []string `json:"samples"`
+}
+
+// Catalog of observed tags, safe for concurrent use. The zero value is not usable, see New.
+type Catalog struct {
+	mu      sync.Mutex
+	sources map[int64]map[string]*Tag
+
+	maxKeys    int
+	maxSamples int
+	maxAge     time.Duration
+}
+
+// New creates an empty Catalog with the given bounds.
+func New(maxKeys, maxSamples int, maxAge time.Duration) *Catalog {
+	return &Catalog{
+		sources:    make(map[int64]map[string]*Tag),
+		maxKeys:    maxKeys,
+		maxSamples: maxSamples,
+		maxAge:     maxAge,
+	}
+}
+
+// Observe records the identifying and extra tags of an event of the given source, observed at the given time.
+func (c *Catalog) Observe(sourceID int64, tags, extraTags map[string]string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := c.sources[sourceID]
+	if keys == nil {
+		keys = make(map[string]*Tag)
+		c.sources[sourceID] = keys
+	}
+
+	observe := func(m map[string]string, extra bool) {
+		for key, value := range m {
+			tag := keys[key]
+			if tag == nil {
+				tag = &Tag{SourceID: sourceID, Key: key}
+				keys[key] = tag
+			}
+
+			tag.Extra = extra
+			tag.LastSeen = now
+			tag.Count++
+			c.addSample(tag, value)
+		}
+	}
+	observe(tags, false)
+	observe(extraTags, true)
+
+	c.prune(sourceID, now)
+}
+
+// addSample moves the value to the front of the tag's samples, dropping the oldest sample if there are too many.
+func (c *Catalog) addSample(tag *Tag, value string) {
+	if value == "" || len(value) > MaxSampleLength {
+		return
+	}
+
+	if i := slices.Index(tag.Samples, value); i >= 0 {
+		tag.Samples = slices.Delete(tag.Samples, i, i+1)
+	}
+
+	tag.Samples = slices.Insert(tag.Samples, 0, value)
+	if len(tag.Samples) > c.maxSamples {
+		tag.Samples = tag.Samples[:c.maxSamples]
+	}
+}
+
+// prune drops the aged out keys of a source and evicts the least recently observed keys exceeding the limit.
+func (c *Catalog) prune(sourceID int64, now time.Time) {
+	keys := c.sources[sourceID]
+	for key, tag := range keys {
+		if now.Sub(tag.LastSeen) > c.maxAge {
+			delete(keys, key)
+		}
+	}
+
+	for len(keys) > c.maxKeys {
+		var oldest *Tag
+		for _, tag := range keys {
+			if oldest == nil || tag.LastSeen.Before(oldest.LastSeen) ||
+				tag.LastSeen.Equal(oldest.LastSeen) && tag.Key > oldest.Key {
+				oldest = tag
+			}
+		}
+		delete(keys, oldest.Key)
+	}
+
+	if len(keys) == 0 {
+		delete(c.sources, sourceID)
+	}
+}
+
+// List returns copies of all tags not aged out at the given time, optionally restricted to a single source if
+// sourceID is non-zero, ordered by source and key.
+func (c *Catalog) List(sourceID int64, now time.Time) []*Tag {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tags := []*Tag{}
+	for id := range c.sources {
+		if sourceID != 0 && id != sourceID {
+			continue
+		}
+
+		c.prune(id, now)
+		for _, tag := range c.sources[id] {
+			tagCopy := *tag
+			tagCopy.Samples = slices.Clone(tag.Samples)
+			tags = append(tags, &tagCopy)
+		}
+	}
+
+	slices.SortFunc(tags, func(a, b *Tag) int {
+		return cmp.Or(cmp.Compare(a.SourceID, b.SourceID), cmp.Compare(a.Key, b.Key))
+	})
+
+	return tags
+}
+
+// Keys returns the distinct keys of all sources not aged out at the given time, sorted.
+func (c *Catalog) Keys(now time.Time) []string {
+	var keys []string
+	for _, tag := range c.List(0, now) {
+		keys = append(keys, tag.Key)
+	}
+
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
+
+// Default is the catalog of all events processed by this daemon, see incident.ProcessEvent.
+var Default = New(MaxKeysPerSource, MaxSamplesPerKey, MaxAge)
@@ -0,0 +1,50 @@
+package tagcatalog
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCatalog(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := New(3, 2, time.Hour)
+
+	c.Observe(1, map[string]string{"host": "db-01"}, map[string]string{"hostgroup/linux": ""}, now)
+	c.Observe(1, map[string]string{"host": "db-02", "service": "ssh"}, nil, now.Add(time.Minute))
+	c.Observe(1, map[string]string{"host": "db-03"}, nil, now.Add(2*time.Minute))
+	c.Observe(2, map[string]string{"host": "web-01"}, nil, now.Add(2*time.Minute))
+
+	tags := c.List(1, now.Add(2*time.Minute))
+	require.Len(t, tags, 3)
+	assert.Equal(t, &Tag{
+		SourceID: 1,
+		Key:      "host",
+		LastSeen: now.Add(2 * time.Minute),
+		Count:    3,
+		Samples:  []string{"db-03", "db-02"},
+	}, tags[0], "only the most recent samples should be kept")
+	assert.Equal(t, "hostgroup/linux", tags[1].Key)
+	assert.True(t, tags[1].Extra)
+	assert.Empty(t, tags[1].Samples, "empty values should not be kept as samples")
+
+	c.Observe(1, map[string]string{"zone": "eu", "host": "db-02"}, nil, now.Add(3*time.Minute))
+	assert.Equal(t, []string{"host", "service", "zone"}, keys(c.List(1, now.Add(3*time.Minute))),
+		"least recently observed key should be evicted")
+	assert.Equal(t, []string{"db-02", "db-03"}, c.List(1, now.Add(3*time.Minute))[0].Samples)
+
+	assert.Equal(t, []string{"host", "service", "zone"}, c.Keys(now.Add(3*time.Minute)))
+	assert.Equal(t, []string{"host", "zone"}, c.Keys(now.Add(62*time.Minute)), "keys should age out")
+	assert.Empty(t, c.List(0, now.Add(2*time.Hour)))
+}
+
+func keys(tags []*Tag) []string {
+	var keys []string
+	for _, tag := range tags {
+		keys = append(keys, tag.Key)
+	}
+	return keys
+}
@@ -0,0 +1,199 @@
+package testutils
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"io"
+	"sync"
+)
+
+// FakeResult is the answer of a FakeDB to a single statement.
+//
+// Queries return the Rows of the given Columns, while other statements report LastInsertId and RowsAffected.
+type FakeResult struct {
+	Columns      []string
+	Rows         [][]driver.Value
+	LastInsertId int64
+	RowsAffected int64
+}
+
+// FakeStatement is a statement executed against a FakeDB together with its arguments.
+type FakeStatement struct {
+	Query string
+	Args  []any
+}
+
+// FakeDB records all statements executed via its DB and answers them by its handler, allowing to test the queries
+// built by the daemon without a real database.
+type FakeDB struct {
+	// DB is connected to this FakeDB, using the bind type and quoting of the driver it was created for.
+	DB *sqlx.DB
+
+	handler func(query string, args []any) (FakeResult, error)
+
+	mu         sync.Mutex
+	statements []FakeStatement
+}
+
+// NewFakeDB creates a FakeDB pretending to be a database of the given driver, e.g., database.PostgreSQL.
+//
+// The handler is called for each statement, except for those controlling transactions. If it is nil, every statement
+// succeeds without returning any rows.
+func NewFakeDB(driverName string, handler func(query string, args []any) (FakeResult, error)) *FakeDB {
+	if handler == nil {
+		handler = func(string, []any) (FakeResult, error) { return FakeResult{}, nil }
+	}
+
+	f := &FakeDB{handler: handler}
+	f.DB = sqlx.NewDb(sql.OpenDB(fakeConnector{f}), driverName)
+
+	return f
+}
+
+// Statements returns all statements executed so far in the order of their execution.
+func (f *FakeDB) Statements() []FakeStatement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]FakeStatement(nil), f.statements...)
+}
+
+// Queries returns the queries of all statements executed so far, see Statements.
+func (f *FakeDB) Queries() []string {
+	var queries []string
+	for _, s := range f.Statements() {
+		queries = append(queries, s.Query)
+	}
+
+	return queries
+}
+
+func (f *FakeDB) exec(query string, namedArgs []driver.NamedValue) (FakeResult, error) {
+	args := make([]any, 0, len(namedArgs))
+	for _, arg := range namedArgs {
+		args = append(args, arg.Value)
+	}
+
+	f.mu.Lock()
+	f.statements = append(f.statements, FakeStatement{Query: query, Args: args})
+	f.mu.Unlock()
+
+	return f.handler(query, args)
+}
+
+type fakeConnector struct {
+	db *FakeDB
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(c), nil }
+
+func (c fakeConnector) Driver() driver.Driver { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fake database can only be opened via NewFakeDB")
+}
+
+type fakeConn struct {
+	db *FakeDB
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+
+func (c fakeConn) Close() error { return nil }
+
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := c.db.exec(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return fakeResult{res}, nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res, err := c.db.exec(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fakeRows{res: res}, nil
+}
+
+// CheckNamedValue converts the arguments like the default converter, e.g., an int to an int64, but keeps those not
+// supported by it as they are, so that slices passed to IN clauses by mistake show up in the test.
+func (c fakeConn) CheckNamedValue(arg *driver.NamedValue) error {
+	if v, err := driver.DefaultParameterConverter.ConvertValue(arg.Value); err == nil {
+		arg.Value = v
+	}
+
+	return nil
+}
+
+type fakeStmt struct {
+	db    *FakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error { return nil }
+
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return fakeConn{s.db}.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return fakeConn{s.db}.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	namedArgs := make([]driver.NamedValue, 0, len(args))
+	for i, arg := range args {
+		namedArgs = append(namedArgs, driver.NamedValue{Ordinal: i + 1, Value: arg})
+	}
+
+	return namedArgs
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error { return nil }
+
+func (fakeTx) Rollback() error { return nil }
+
+type fakeResult struct {
+	res FakeResult
+}
+
+func (r fakeResult) LastInsertId() (int64, error) { return r.res.LastInsertId, nil }
+
+func (r fakeResult) RowsAffected() (int64, error) { return r.res.RowsAffected, nil }
+
+type fakeRows struct {
+	res  FakeResult
+	next int
+}
+
+func (r *fakeRows) Columns() []string { return r.res.Columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.res.Rows) {
+		return io.EOF
+	}
+
+	copy(dest, r.res.Rows[r.next])
+	r.next++
+
+	return nil
+}
@@ -0,0 +1,101 @@
+// Package plugintest builds the requests passed to channel plugins in their tests.
+//
+// It is separate from package testutils, as the plugin package depends on packages using testutils in their tests.
+package plugintest
+
+import (
+	"encoding/json"
+	"github.com/icinga/icinga-notifications/pkg/plugin"
+	"time"
+)
+
+// NotificationRequestOption changes a NotificationRequest built by NewNotificationRequest.
+type NotificationRequestOption func(req *plugin.NotificationRequest)
+
+// NewNotificationRequest returns a NotificationRequest notifying Jane Doe of a crit state event of incident #42 of the
+// "web01!http" service, changed by the given options in their order, e.g., to add an address of the tested channel.
+func NewNotificationRequest(opts ...NotificationRequestOption) *plugin.NotificationRequest {
+	req := &plugin.NotificationRequest{
+		Contact: &plugin.Contact{FullName: "Jane Doe"},
+		Object: &plugin.Object{
+			Name: "web01!http",
+			Url:  "https://icinga.example.com/icingadb/service?name=http&host.name=web01",
+			Tags: map[string]string{"host": "web01", "service": "http"},
+		},
+		Incident: &plugin.Incident{
+			Id:       42,
+			Url:      "https://icinga.example.com/notifications/incident?id=42",
+			Severity: "crit",
+		},
+		Event: &plugin.Event{Time: time.Unix(1700000000, 0), Type: "state", Message: "connection refused"},
+	}
+
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	return req
+}
+
+// WithAddress adds an address of the given type to the contact.
+func WithAddress(addressType, address string) NotificationRequestOption {
+	return func(req *plugin.NotificationRequest) {
+		req.Contact.Addresses = append(req.Contact.Addresses, &plugin.Address{Type: addressType, Address: address})
+	}
+}
+
+// WithTag sets a tag of the object.
+func WithTag(key, value string) NotificationRequestOption {
+	return func(req *plugin.NotificationRequest) {
+		req.Object.Tags[key] = value
+	}
+}
+
+// WithRule adds a rule the notification was sent for.
+func WithRule(name, runbookUrl string) NotificationRequestOption {
+	return func(req *plugin.NotificationRequest) {
+		req.Rules = append(req.Rules, &plugin.Rule{Name: name, RunbookUrl: runbookUrl})
+	}
+}
+
+// WithSeverity sets the severity of the incident.
+func WithSeverity(severity string) NotificationRequestOption {
+	return func(req *plugin.NotificationRequest) {
+		req.Incident.Severity = severity
+	}
+}
+
+// WithEventType sets the type of the event.
+func WithEventType(eventType string) NotificationRequestOption {
+	return func(req *plugin.NotificationRequest) {
+		req.Event.Type = eventType
+	}
+}
+
+// WithEventTime sets the time of the event.
+func WithEventTime(t time.Time) NotificationRequestOption {
+	return func(req *plugin.NotificationRequest) {
+		req.Event.Time = t
+	}
+}
+
+// WithMessage sets the message of the event.
+func WithMessage(message string) NotificationRequestOption {
+	return func(req *plugin.NotificationRequest) {
+		req.Event.Message = message
+	}
+}
+
+// WithState sets the state of a stateful channel returned for the previous notification of the incident.
+func WithState(state json.RawMessage) NotificationRequestOption {
+	return func(req *plugin.NotificationRequest) {
+		req.State = state
+	}
+}
+
+// WithAcknowledgeUrl sets the URL for the contact to acknowledge the incident.
+func WithAcknowledgeUrl(url string) NotificationRequestOption {
+	return func(req *plugin.NotificationRequest) {
+		req.AcknowledgeUrl = url
+	}
+}
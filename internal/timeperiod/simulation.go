@@ -0,0 +1,89 @@
+package timeperiod
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxSimulationRange limits the range of time a simulation may cover, as its result grows with each transition.
+const MaxSimulationRange = 366 * 24 * time.Hour
+
+// Span is a range of time from Start to End, exclusively, during which a simulated Value does not change.
+type Span[T any] struct {
+	Start time.Time
+	End   time.Time
+	Value T
+}
+
+// Simulate splits the range from from to to into consecutive spans during which the value returned by at does not
+// change, evaluating it at from and at each transition returned by next, which must be strictly after its argument.
+//
+// All times are returned in the location of from, allowing them to be displayed in a single time zone regardless of
+// the time zones of the evaluated entries.
+func Simulate[T any](from, to time.Time, next func(time.Time) time.Time, at func(time.Time) T, equal func(T, T) bool) []Span[T] {
+	var spans []Span[T]
+	for t := from; t.Before(to); {
+		value := at(t)
+		end := next(t).In(from.Location())
+		if end.After(to) {
+			end = to
+		}
+
+		if n := len(spans); n > 0 && equal(spans[n-1].Value, value) {
+			spans[n-1].End = end
+		} else {
+			spans = append(spans, Span[T]{Start: t, End: end, Value: value})
+		}
+
+		t = end
+	}
+
+	return spans
+}
+
+// Interval is a range of time during which a time period is either active or not, see TimePeriod.Simulate.
+type Interval struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Active bool      `json:"active"`
+}
+
+// Simulate returns the consecutive intervals from from to to during which the time period is either active or not.
+func (p *TimePeriod) Simulate(from, to time.Time) []*Interval {
+	spans := Simulate(from, to, p.NextTransition, p.Contains, func(a, b bool) bool { return a == b })
+
+	intervals := make([]*Interval, 0, len(spans))
+	for _, s := range spans {
+		intervals = append(intervals, &Interval{Start: s.Start, End: s.End, Active: s.Value})
+	}
+
+	return intervals
+}
+
+// ZoneTransition is a change of the UTC offset of a time zone, e.g., when daylight saving time starts or ends.
+type ZoneTransition struct {
+	Time time.Time `json:"time"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+}
+
+// ZoneTransitions returns all changes of the UTC offset of the given location after from and until to.
+//
+// Local times around them are either skipped or occur twice, which is easily overlooked when configuring schedules.
+func ZoneTransitions(loc *time.Location, from, to time.Time) []*ZoneTransition {
+	var transitions []*ZoneTransition
+	for t := from.In(loc); ; {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || end.After(to) {
+			return transitions
+		}
+
+		transitions = append(transitions, &ZoneTransition{Time: end, From: zoneString(t), To: zoneString(end)})
+		t = end
+	}
+}
+
+// zoneString formats the time zone of t, e.g., "CEST (+02:00)".
+func zoneString(t time.Time) string {
+	return fmt.Sprintf("%s (%s)", t.Format("MST"), t.Format("-07:00"))
+}
@@ -0,0 +1,63 @@
+package timeperiod_test
+
+import (
+	"database/sql"
+	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/timeperiod"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTimePeriod_Simulate(t *testing.T) {
+	t.Parallel()
+
+	p := &timeperiod.TimePeriod{
+		Name: "Business Hours",
+		Entries: []*timeperiod.Entry{{
+			StartTime: types.UnixMilli(berlinTime("2023-03-25 09:00:00")),
+			EndTime:   types.UnixMilli(berlinTime("2023-03-25 17:00:00")),
+			Timezone:  berlin,
+			RRule:     sql.NullString{String: "FREQ=DAILY", Valid: true},
+		}},
+	}
+	require.NoError(t, p.Entries[0].Init())
+
+	// Daylight saving time starts on 2023-03-26 at 02:00, which must not shift the local times of the entry.
+	from, to := berlinTime("2023-03-25 00:00:00"), berlinTime("2023-03-27 00:00:00")
+	assert.Equal(t, []*timeperiod.Interval{
+		{Start: from, End: berlinTime("2023-03-25 09:00:00"), Active: false},
+		{Start: berlinTime("2023-03-25 09:00:00"), End: berlinTime("2023-03-25 17:00:00"), Active: true},
+		{Start: berlinTime("2023-03-25 17:00:00"), End: berlinTime("2023-03-26 09:00:00"), Active: false},
+		{Start: berlinTime("2023-03-26 09:00:00"), End: berlinTime("2023-03-26 17:00:00"), Active: true},
+		{Start: berlinTime("2023-03-26 17:00:00"), End: to, Active: false},
+	}, p.Simulate(from, to))
+
+	t.Run("WithoutEntries", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, []*timeperiod.Interval{{Start: from, End: to, Active: false}},
+			(&timeperiod.TimePeriod{}).Simulate(from, to), "days without transitions should be merged")
+	})
+}
+
+func TestZoneTransitions(t *testing.T) {
+	t.Parallel()
+
+	loc, err := time.LoadLocation(berlin)
+	require.NoError(t, err)
+
+	transitions := timeperiod.ZoneTransitions(loc, berlinTime("2023-01-01 00:00:00"), berlinTime("2024-01-01 00:00:00"))
+	require.Len(t, transitions, 2)
+
+	assert.True(t, transitions[0].Time.Equal(time.Date(2023, 3, 26, 1, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "CET (+01:00)", transitions[0].From)
+	assert.Equal(t, "CEST (+02:00)", transitions[0].To)
+
+	assert.True(t, transitions[1].Time.Equal(time.Date(2023, 10, 29, 1, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "CEST (+02:00)", transitions[1].From)
+	assert.Equal(t, "CET (+01:00)", transitions[1].To)
+
+	assert.Empty(t, timeperiod.ZoneTransitions(time.UTC, time.Unix(0, 0), time.Now()))
+}
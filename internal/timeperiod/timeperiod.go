@@ -8,18 +8,24 @@ import (
 	"github.com/pkg/errors"
 	"github.com/teambition/rrule-go"
 	"go.uber.org/zap/zapcore"
+	"slices"
 	"time"
 )
 
 type TimePeriod struct {
 	baseconf.IncrementalPkDbEntry[int64] `db:",inline"`
 
+	// NameRaw is only set for standalone time periods, allowing escalation conditions to refer to them by name.
+	NameRaw types.String `db:"name"`
+
 	Name    string   `db:"-"`
 	Entries []*Entry `db:"-"`
 }
 
 func (p *TimePeriod) IncrementalInitAndValidate() error {
-	if p.Name == "" {
+	if p.NameRaw.Valid && p.NameRaw.String != "" {
+		p.Name = p.NameRaw.String
+	} else if p.Name == "" {
 		p.Name = fmt.Sprintf("Time Period #%d", p.ID)
 	}
 	return nil
@@ -77,7 +83,11 @@ type Entry struct {
 	RotationMemberID sql.NullInt64   `db:"rotation_member_id"`
 
 	initialized bool
-	rrule       *rrule.RRule
+	loc         *time.Location
+	// duration is the wall clock time between StartTime and EndTime, see wallClock.
+	duration time.Duration
+	// rrule yields the wall clock times of the recurrence starts, see wallClock.
+	rrule *rrule.RRule
 }
 
 // IncrementalInitAndValidate implements the config.IncrementalConfigurableInitAndValidatable interface.
@@ -109,6 +119,11 @@ func (e *Entry) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 // Init prepares the Entry for use after being read from the database.
 //
 // This includes loading the timezone information and parsing the recurrence rule if present.
+//
+// Recurrences start and end at the same wall clock times in the entry's time zone as the first one, regardless of
+// daylight saving time. So, e.g., a recurrence from 22:00 to 06:00 is an hour shorter in the night daylight saving
+// time starts and an hour longer in the one it ends. Wall clock times skipped or repeated on a particular day are
+// resolved by resolveWallClock.
 func (e *Entry) Init() error {
 	if e.initialized {
 		return nil
@@ -124,6 +139,8 @@ func (e *Entry) Init() error {
 	// Additionally, set the location so that all times in this entry are consistent with the timezone of the entry.
 	e.StartTime = types.UnixMilli(e.StartTime.Time().Truncate(time.Second).In(loc))
 	e.EndTime = types.UnixMilli(e.EndTime.Time().Truncate(time.Second).In(loc))
+	e.loc = loc
+	e.duration = wallClock(e.EndTime.Time()).Sub(wallClock(e.StartTime.Time()))
 
 	if e.RRule.Valid {
 		option, err := rrule.StrToROptionInLocation(e.RRule.String, loc)
@@ -135,6 +152,13 @@ func (e *Entry) Init() error {
 			option.Dtstart = e.StartTime.Time()
 		}
 
+		// Evaluate the rule on wall clock times, as the rrule package resolves the local times skipped or repeated
+		// due to daylight saving time differently depending on the time zone.
+		option.Dtstart = wallClock(option.Dtstart.In(loc))
+		if !option.Until.IsZero() {
+			option.Until = wallClock(option.Until.In(loc))
+		}
+
 		rule, err := rrule.NewRRule(*option)
 		if err != nil {
 			return err
@@ -167,10 +191,9 @@ func (e *Entry) Contains(t time.Time) bool {
 		return false
 	}
 
-	lastStart := e.rrule.Before(t, true)
-	lastEnd := lastStart.Add(e.EndTime.Time().Sub(e.StartTime.Time()))
+	lastStart, lastEnd := e.lastRecurrence(t)
 	// Whether the date time is between the last recurrence start and the last recurrence end
-	return (t.Equal(lastStart) || t.After(lastStart)) && t.Before(lastEnd)
+	return !lastStart.IsZero() && t.Before(lastEnd)
 }
 
 // NextTransition returns the next recurrence start or end of this entry relative to the given time inclusively.
@@ -196,12 +219,111 @@ func (e *Entry) NextTransition(t time.Time) time.Time {
 		return time.Time{}
 	}
 
-	lastStart := e.rrule.Before(t, true)
-	lastEnd := lastStart.Add(e.EndTime.Time().Sub(e.StartTime.Time()))
-	if (t.Equal(lastStart) || t.After(lastStart)) && t.Before(lastEnd) {
+	if lastStart, lastEnd := e.lastRecurrence(t); !lastStart.IsZero() && t.Before(lastEnd) {
 		// Base time is after the last transition begin but before the last transition end
 		return lastEnd
 	}
 
-	return e.rrule.After(t, false)
+	return e.nextRecurrenceStart(t)
+}
+
+// lastRecurrence returns the start and end of the latest recurrence starting at or before t, or zero values if there
+// is none.
+func (e *Entry) lastRecurrence(t time.Time) (start, end time.Time) {
+	for _, w := range e.recurrencesAround(t) {
+		if s := resolveWallClock(w, e.loc); !s.After(t) && (start.IsZero() || s.After(start)) {
+			start, end = s, resolveWallClock(w.Add(e.duration), e.loc)
+		}
+	}
+
+	return start, end
+}
+
+// nextRecurrenceStart returns the start of the earliest recurrence strictly after t, or the zero value if there is none.
+func (e *Entry) nextRecurrenceStart(t time.Time) time.Time {
+	var next time.Time
+	for _, w := range e.recurrencesAround(t) {
+		if s := resolveWallClock(w, e.loc); s.After(t) && (next.IsZero() || s.Before(next)) {
+			next = s
+		}
+	}
+
+	return next
+}
+
+// recurrencesAround returns the wall clock times of all recurrences whose start might be the closest one before or
+// after t once resolved, i.e. those within the UTC offsets used around t and the first ones beyond.
+func (e *Entry) recurrencesAround(t time.Time) []time.Time {
+	offsets := zoneOffsets(t.In(e.loc))
+	margin := time.Duration(slices.Max(offsets)-slices.Min(offsets)) * time.Second
+	from := wallClock(t.In(e.loc)).Add(-margin)
+	to := wallClock(t.In(e.loc)).Add(margin)
+
+	// Iterate only once, as each call of the rrule package's methods iterates from the first recurrence.
+	var recurrences []time.Time
+	next := e.rrule.Iterator()
+	for w, ok := next(); ok; w, ok = next() {
+		if w.Before(from) {
+			recurrences = append(recurrences[:0], w)
+			continue
+		}
+
+		recurrences = append(recurrences, w)
+		if w.After(to) {
+			break
+		}
+	}
+
+	return recurrences
+}
+
+// wallClock returns the date and time of day shown by clocks at t in its location, represented as the same in UTC.
+//
+// Durations between wall clock times don't change with daylight saving time, e.g., there are always 24 hours from
+// 09:00 to 09:00 on the next day.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// resolveWallClock returns the time at which clocks in loc show the wall clock time w, see wallClock.
+//
+// Like RFC 5545 specifies it for local times, a wall clock time skipped when the UTC offset increases, e.g., when
+// daylight saving time starts, is interpreted using the offset before the gap, i.e. it is shifted forward by the
+// length of the gap. A wall clock time repeated when the offset decreases refers to its first occurrence. In both
+// cases, time.Date makes no guarantees and indeed behaves differently depending on the time zone.
+func resolveWallClock(w time.Time, loc *time.Location) time.Time {
+	offsets := zoneOffsets(time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), w.Nanosecond(), loc))
+
+	var resolved time.Time
+	for _, offset := range offsets {
+		t := w.Add(-time.Duration(offset) * time.Second).In(loc)
+		if _, o := t.Zone(); o == offset && (resolved.IsZero() || t.Before(resolved)) {
+			resolved = t
+		}
+	}
+
+	if resolved.IsZero() {
+		// Skipped wall clock time, the lower offset is the one before the gap.
+		resolved = w.Add(-time.Duration(slices.Min(offsets)) * time.Second).In(loc)
+	}
+
+	return resolved
+}
+
+// zoneOffsets returns the UTC offsets in seconds of the time zone at t, as well as of the zones before and after it.
+func zoneOffsets(t time.Time) []int {
+	_, offset := t.Zone()
+	offsets := []int{offset}
+
+	start, end := t.ZoneBounds()
+	if !start.IsZero() {
+		_, before := start.Add(-time.Nanosecond).Zone()
+		offsets = append(offsets, before)
+	}
+	if !end.IsZero() {
+		_, after := end.Zone()
+		offsets = append(offsets, after)
+	}
+
+	return offsets
 }
@@ -160,6 +160,97 @@ func TestEntry(t *testing.T) {
 	})
 }
 
+func TestEntry_DST(t *testing.T) {
+	t.Parallel()
+
+	newEntry := func(t *testing.T, start, end time.Time, rule string) *timeperiod.Entry {
+		e := &timeperiod.Entry{
+			StartTime: types.UnixMilli(start),
+			EndTime:   types.UnixMilli(end),
+			Timezone:  start.Location().String(),
+			RRule:     sql.NullString{String: rule, Valid: true},
+		}
+		require.NoError(t, e.Init())
+
+		return e
+	}
+
+	t.Run("NightShift", func(t *testing.T) {
+		t.Parallel()
+
+		e := newEntry(t, berlinTime("2023-03-01 22:00:00"), berlinTime("2023-03-02 06:00:00"), "FREQ=DAILY")
+
+		// 02:00 to 03:00 is skipped in the night daylight saving time starts, the shift still ends at 06:00.
+		assert.Equal(t, berlinTime("2023-03-26 06:00:00"), e.NextTransition(berlinTime("2023-03-25 22:00:00")))
+		assert.True(t, e.Contains(berlinTime("2023-03-26 05:59:59")))
+		assert.False(t, e.Contains(berlinTime("2023-03-26 06:00:00")))
+
+		// 02:00 to 03:00 is repeated in the night daylight saving time ends, the shift still ends at 06:00.
+		assert.Equal(t, berlinTime("2023-10-29 06:00:00"), e.NextTransition(berlinTime("2023-10-28 22:00:00")))
+		assert.True(t, e.Contains(berlinTime("2023-10-29 05:59:59")))
+		assert.False(t, e.Contains(berlinTime("2023-10-29 06:00:00")))
+		assert.Equal(t, berlinTime("2023-10-29 22:00:00"), e.NextTransition(berlinTime("2023-10-29 06:00:00")))
+	})
+
+	t.Run("WeeklyHandoff", func(t *testing.T) {
+		t.Parallel()
+
+		// Like rotation members handing over every Monday at 09:00.
+		e := newEntry(t, berlinTime("2023-03-06 09:00:00"), berlinTime("2023-03-13 09:00:00"), "FREQ=WEEKLY;INTERVAL=2")
+
+		assert.Equal(t, berlinTime("2023-03-27 09:00:00"), e.NextTransition(berlinTime("2023-03-20 09:00:00")))
+		assert.True(t, e.Contains(berlinTime("2023-03-27 08:59:59")))
+		assert.False(t, e.Contains(berlinTime("2023-03-27 09:00:00")))
+		assert.Equal(t, berlinTime("2023-04-03 09:00:00"), e.NextTransition(berlinTime("2023-03-27 09:00:00")))
+	})
+
+	// The time package resolves skipped and repeated wall clock times differently in these time zones.
+	for _, tz := range []struct {
+		name         string
+		spring, fall time.Time
+		// repeated is the hour of the day repeated when daylight saving time ends.
+		repeated int
+	}{
+		{berlin, berlinTime("2023-03-26 00:00:00"), berlinTime("2023-10-29 00:00:00"), 2},
+		{"America/New_York", newYorkTime("2023-03-12 00:00:00"), newYorkTime("2023-11-05 00:00:00"), 1},
+	} {
+		t.Run(tz.name, func(t *testing.T) {
+			t.Parallel()
+
+			loc, spring, fall := tz.spring.Location(), tz.spring, tz.fall
+
+			t.Run("SkippedStart", func(t *testing.T) {
+				t.Parallel()
+
+				e := newEntry(t, time.Date(2023, time.March, 1, 2, 30, 0, 0, loc), time.Date(2023, time.March, 1, 4, 0, 0, 0, loc), "FREQ=DAILY")
+
+				// 02:30 does not exist and is shifted forward by the length of the gap.
+				start := spring.Add(2*time.Hour + 30*time.Minute)
+				assert.Equal(t, "03:30:00", start.Format(time.TimeOnly))
+				assert.Equal(t, start, e.NextTransition(spring))
+				assert.False(t, e.Contains(start.Add(-time.Second)))
+				assert.True(t, e.Contains(start))
+				assert.Equal(t, spring.Add(3*time.Hour), e.NextTransition(start), "end should stay at 04:00")
+			})
+
+			t.Run("RepeatedStart", func(t *testing.T) {
+				t.Parallel()
+
+				e := newEntry(t, time.Date(2023, time.March, 1, tz.repeated, 30, 0, 0, loc),
+					time.Date(2023, time.March, 1, tz.repeated+1, 0, 0, 0, loc), "FREQ=DAILY")
+
+				// The start occurs twice, the first occurrence is used.
+				start := fall.Add(time.Duration(tz.repeated)*time.Hour + 30*time.Minute)
+				assert.Equal(t, fmt.Sprintf("%02d:30:00", tz.repeated), start.Format(time.TimeOnly))
+				assert.Equal(t, start, e.NextTransition(fall))
+				assert.True(t, e.Contains(start))
+				assert.True(t, e.Contains(start.Add(time.Hour)), "the repeated start should be covered")
+				assert.Equal(t, start.Add(90*time.Minute), e.NextTransition(start), "end should be after the repeated hour")
+			})
+		})
+	}
+}
+
 func TestTimePeriodTransitions(t *testing.T) {
 	t.Parallel()
 
@@ -236,6 +327,20 @@ func TestTimePeriodTransitions(t *testing.T) {
 
 const berlin = "Europe/Berlin"
 
+func newYorkTime(value string) time.Time {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		panic(err)
+	}
+
+	t, err := time.ParseInLocation(time.DateTime, value, loc)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
 func berlinTime(value string) time.Time {
 	loc, err := time.LoadLocation(berlin)
 	if err != nil {
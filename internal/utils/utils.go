@@ -5,12 +5,15 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"github.com/icinga/icinga-go-library/backoff"
 	"github.com/icinga/icinga-go-library/database"
 	"github.com/icinga/icinga-go-library/types"
+	"github.com/icinga/icinga-notifications/internal/errs"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 	"slices"
 	"strings"
+	"time"
 )
 
 // BuildInsertStmtWithout builds an insert stmt without the provided column.
@@ -31,24 +34,55 @@ func BuildInsertStmtWithout(db *database.DB, into interface{}, withoutColumn str
 	)
 }
 
+// txMaxAttempts limits how often RetryTx attempts to run a transaction.
+const txMaxAttempts = 5
+
+// txBackoff returns the jittered duration to wait before retrying a transaction after the given attempt.
+var txBackoff = backoff.NewExponentialWithJitter(10*time.Millisecond, time.Second)
+
 // RunInTx allows running a function in a database transaction without requiring manual transaction handling.
 //
 // A new transaction is started on db which is then passed to fn. After fn returns, the transaction is
 // committed unless an error was returned. If fn returns an error, that error is returned, otherwise an
 // error is returned if a database operation fails.
+//
+// If the transaction is aborted due to a conflict with a concurrent one, it is retried in a new transaction, see
+// RetryTx. Thus, fn must not modify any state outside the transaction, or reset it when being called again.
 func RunInTx(ctx context.Context, db *database.DB, fn func(tx *sqlx.Tx) error) error {
-	tx, err := db.BeginTxx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = tx.Rollback() }()
+	return RetryTx(ctx, func() error {
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
 
-	err = fn(tx)
-	if err != nil {
-		return err
-	}
+		err = fn(tx)
+		if err != nil {
+			return err
+		}
 
-	return tx.Commit()
+		return tx.Commit()
+	})
+}
+
+// RetryTx calls fn, which is expected to run a whole database transaction, until it succeeds.
+//
+// Only errors caused by the database aborting the transaction due to a conflict with a concurrent one, e.g., a deadlock
+// or serialization failure, are retried, see errs.IsRetryableTx, with a jittered backoff in between. Any other error,
+// or the last one after txMaxAttempts attempts, is returned as it is.
+func RetryTx(ctx context.Context, fn func() error) error {
+	for attempt := uint64(1); ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= txMaxAttempts || !errs.IsRetryableTx(err) {
+			return err
+		}
+
+		select {
+		case <-time.After(txBackoff(attempt)):
+		case <-ctx.Done():
+			return err
+		}
+	}
 }
 
 // InsertAndFetchId executes the given query and fetches the last inserted ID.
@@ -80,6 +114,56 @@ func InsertAndFetchId(ctx context.Context, tx *sqlx.Tx, stmt string, args any) (
 	return lastInsertId, nil
 }
 
+// bulkInsertChunkSize limits the number of rows inserted by a single statement, keeping it well below the databases'
+// limits of placeholders per statement, e.g., 65535 for PostgreSQL.
+const bulkInsertChunkSize = 500
+
+// BulkInsertAndFetchIds inserts the given rows and returns their IDs in the same order.
+//
+// On PostgreSQL, the rows are inserted by multi-row INSERT statements of up to bulkInsertChunkSize rows each, cutting
+// the number of statements compared to InsertAndFetchId, as RETURNING yields the IDs in the order of the rows. MySQL
+// only reports the first ID of a multi-row INSERT, and with the default innodb_autoinc_lock_mode=2, the IDs of its
+// rows are not necessarily consecutive if other transactions insert into the same table concurrently. Thus, each row
+// is inserted on its own by InsertAndFetchId on MySQL.
+func BulkInsertAndFetchIds[Row any](ctx context.Context, tx *sqlx.Tx, stmt string, rows []Row) ([]int64, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int64, 0, len(rows))
+	if tx.DriverName() != database.PostgreSQL {
+		for _, row := range rows {
+			id, err := InsertAndFetchId(ctx, tx, stmt, row)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+
+		return ids, nil
+	}
+
+	for start := 0; start < len(rows); start += bulkInsertChunkSize {
+		chunk := rows[start:min(start+bulkInsertChunkSize, len(rows))]
+
+		query, args, err := tx.BindNamed(stmt+" RETURNING id", chunk)
+		if err != nil {
+			return nil, err
+		}
+
+		var chunkIds []int64
+		if err := tx.SelectContext(ctx, &chunkIds, query, args...); err != nil {
+			return nil, fmt.Errorf("failed to insert entries for type %T: %w", rows, err)
+		}
+		if len(chunkIds) != len(chunk) {
+			return nil, fmt.Errorf("inserted %d entries for type %T, but got %d ids", len(chunk), rows, len(chunkIds))
+		}
+		ids = append(ids, chunkIds...)
+	}
+
+	return ids, nil
+}
+
 // ExecAndApply applies the provided restoreFunc callback for each successfully retrieved row of the specified type.
 // Returns error on any database failure or fails to acquire the table semaphore.
 func ExecAndApply[Row any](ctx context.Context, db *database.DB, stmt string, args []interface{}, restoreFunc func(*Row)) error {
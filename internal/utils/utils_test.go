@@ -1,7 +1,17 @@
 package utils
 
 import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/icinga/icinga-go-library/database"
+	"github.com/icinga/icinga-notifications/internal/testutils"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -53,3 +63,134 @@ func TestIterateOrderedMap(t *testing.T) {
 		})
 	}
 }
+
+func TestRetryTx(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: 1213}
+	serializationFailure := &pq.Error{Code: "40001"}
+	permanent := errors.New("permanent")
+
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		err      error
+	}{
+		{"Success", nil, 1, nil},
+		{"Permanent", []error{permanent}, 1, permanent},
+		{"Deadlock", []error{deadlock}, 2, nil},
+		{"SerializationFailure", []error{serializationFailure, serializationFailure}, 3, nil},
+		{"DeadlockThenPermanent", []error{deadlock, permanent}, 2, permanent},
+		{"Exhausted", []error{deadlock, deadlock, deadlock, deadlock, deadlock, deadlock}, txMaxAttempts, deadlock},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := RetryTx(context.Background(), func() error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+
+				return nil
+			})
+
+			assert.Equal(t, tt.attempts, attempts)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestBulkInsertAndFetchIds(t *testing.T) {
+	type row struct {
+		Name string `db:"name"`
+	}
+
+	const stmt = `INSERT INTO "entry" ("name") VALUES (:name)`
+
+	rows := make([]row, 2*bulkInsertChunkSize+1)
+	for i := range rows {
+		rows[i].Name = strconv.Itoa(i)
+	}
+
+	t.Run("PostgreSQL", func(t *testing.T) {
+		// Returns the IDs in reverse order of the chunks, verifying that they are mapped by their position only.
+		nextId := int64(10_000)
+		db := testutils.NewFakeDB(database.PostgreSQL, func(_ string, args []any) (testutils.FakeResult, error) {
+			res := testutils.FakeResult{Columns: []string{"id"}}
+			for range args {
+				res.Rows = append(res.Rows, []driver.Value{nextId})
+				nextId--
+			}
+
+			return res, nil
+		})
+
+		tx, err := db.DB.Beginx()
+		require.NoError(t, err)
+
+		ids, err := BulkInsertAndFetchIds(context.Background(), tx, stmt, rows)
+		require.NoError(t, err)
+		require.Len(t, ids, len(rows))
+		for i, id := range ids {
+			assert.Equal(t, int64(10_000-i), id, "row %d must get the id returned at its position", i)
+		}
+
+		statements := db.Statements()
+		require.Len(t, statements, 3, "rows must be inserted in chunks of bulkInsertChunkSize")
+		for i, size := range []int{bulkInsertChunkSize, bulkInsertChunkSize, 1} {
+			assert.True(t, strings.HasSuffix(statements[i].Query, " RETURNING id"))
+			assert.Len(t, statements[i].Args, size)
+		}
+		assert.Equal(t, []any{"0"}, statements[0].Args[:1])
+		assert.Equal(t, []any{strconv.Itoa(len(rows) - 1)}, statements[2].Args)
+	})
+
+	t.Run("PostgreSQLMissingIds", func(t *testing.T) {
+		db := testutils.NewFakeDB(database.PostgreSQL, func(string, []any) (testutils.FakeResult, error) {
+			return testutils.FakeResult{Columns: []string{"id"}, Rows: [][]driver.Value{{int64(1)}}}, nil
+		})
+
+		tx, err := db.DB.Beginx()
+		require.NoError(t, err)
+
+		_, err = BulkInsertAndFetchIds(context.Background(), tx, stmt, rows[:2])
+		assert.Error(t, err, "a chunk must not be mapped to fewer ids than rows")
+	})
+
+	t.Run("MySQL", func(t *testing.T) {
+		// Interleaved inserts of concurrent transactions result in IDs not being consecutive.
+		lastInsertIds := []int64{7, 42, 8}
+		db := testutils.NewFakeDB(database.MySQL, func(string, []any) (testutils.FakeResult, error) {
+			id := lastInsertIds[0]
+			lastInsertIds = lastInsertIds[1:]
+
+			return testutils.FakeResult{LastInsertId: id, RowsAffected: 1}, nil
+		})
+
+		tx, err := db.DB.Beginx()
+		require.NoError(t, err)
+
+		ids, err := BulkInsertAndFetchIds(context.Background(), tx, stmt, rows[:3])
+		require.NoError(t, err)
+		assert.Equal(t, []int64{7, 42, 8}, ids)
+
+		statements := db.Statements()
+		require.Len(t, statements, 3, "each row must be inserted on its own")
+		for i, s := range statements {
+			assert.Equal(t, []any{strconv.Itoa(i)}, s.Args)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		db := testutils.NewFakeDB(database.PostgreSQL, nil)
+
+		tx, err := db.DB.Beginx()
+		require.NoError(t, err)
+
+		ids, err := BulkInsertAndFetchIds[row](context.Background(), tx, stmt, nil)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+		assert.Empty(t, db.Statements())
+	})
+}
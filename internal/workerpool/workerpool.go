@@ -0,0 +1,65 @@
+// Package workerpool processes events of distinct objects concurrently, while keeping the order of each object's events.
+package workerpool
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+)
+
+// Pool runs tasks by a fixed number of workers.
+//
+// Tasks of the same key, e.g., the ID of the object an event belongs to, are always run by the same worker, one after
+// another in the order they were submitted, while tasks of different keys may run concurrently.
+type Pool struct {
+	queues []chan func()
+	wg     sync.WaitGroup
+}
+
+// New starts a Pool of the given number of workers, each queueing up to backlog tasks before Submit blocks.
+func New(workers, backlog int) *Pool {
+	p := &Pool{queues: make([]chan func(), max(workers, 1))}
+	for i := range p.queues {
+		p.queues[i] = make(chan func(), backlog)
+
+		p.wg.Add(1)
+		go func(queue <-chan func()) {
+			defer p.wg.Done()
+			for task := range queue {
+				task()
+			}
+		}(p.queues[i])
+	}
+
+	return p
+}
+
+// Submit queues the task to be run by the worker of the given key. It blocks while the worker's queue is full, until
+// the context is done, in which case the task is not run and the context's error is returned.
+func (p *Pool) Submit(ctx context.Context, key []byte, task func()) error {
+	select {
+	case p.queues[p.worker(key)] <- task:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the workers after running all queued tasks. Submit must not be called afterwards.
+func (p *Pool) Close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// worker returns the index of the worker running the tasks of the given key.
+//
+// The key is hashed once more, as the object IDs are already hashes used for sharding, see package shard. Using their
+// bits again would only spread the objects of a shard among some workers.
+func (p *Pool) worker(key []byte) int {
+	h := fnv.New64a()
+	_, _ = h.Write(key)
+
+	return int(h.Sum64() % uint64(len(p.queues)))
+}
@@ -0,0 +1,79 @@
+package workerpool
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("KeepsOrderPerKey", func(t *testing.T) {
+		t.Parallel()
+
+		p := New(4, 10)
+
+		var mu sync.Mutex
+		got := make(map[string][]int)
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("object-%d", i%7)
+			assert.NoError(t, p.Submit(context.Background(), []byte(key), func() {
+				mu.Lock()
+				defer mu.Unlock()
+				got[key] = append(got[key], i)
+			}))
+		}
+		p.Close()
+
+		assert.Len(t, got, 7)
+		for key, order := range got {
+			assert.IsIncreasing(t, order, "tasks of %q should run in order", key)
+		}
+	})
+
+	t.Run("RunsKeysConcurrently", func(t *testing.T) {
+		t.Parallel()
+
+		p := New(2, 0)
+		defer p.Close()
+
+		// Find a key run by the other worker than the blocked one.
+		blocked := []byte("a")
+		other := blocked
+		for i := 0; p.worker(other) == p.worker(blocked); i++ {
+			other = []byte(fmt.Sprintf("b%d", i))
+		}
+
+		release := make(chan struct{})
+		assert.NoError(t, p.Submit(context.Background(), blocked, func() { <-release }))
+		defer close(release)
+
+		done := make(chan struct{})
+		assert.NoError(t, p.Submit(context.Background(), other, func() { close(done) }))
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("task of another key should not wait for the blocked one")
+		}
+	})
+
+	t.Run("SubmitHonorsContext", func(t *testing.T) {
+		t.Parallel()
+
+		p := New(1, 0)
+		defer p.Close()
+
+		release := make(chan struct{})
+		assert.NoError(t, p.Submit(context.Background(), nil, func() { <-release }))
+		defer close(release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, p.Submit(ctx, nil, func() {}), context.DeadlineExceeded)
+	})
+}
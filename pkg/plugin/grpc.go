@@ -0,0 +1,248 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	pluginv1 "github.com/icinga/icinga-notifications/pkg/plugin/proto/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+	"net"
+	"os"
+)
+
+// EnvPluginSocket is the environment variable holding the path of the Unix socket a plugin may serve gRPC on, passed
+// by the daemon to each plugin, see ServeGRPC.
+const EnvPluginSocket = "ICINGA_NOTIFICATIONS_PLUGIN_SOCKET"
+
+// Handshake is written as a single JSON line to stdout by a plugin serving gRPC once it listens on the socket of
+// EnvPluginSocket, before writing anything else. Plugins using the JSON-RPC protocol never write it.
+type Handshake struct {
+	Protocol string `json:"protocol"`
+	Version  string `json:"version"`
+}
+
+// GRPCHandshake announces the protocol of pluginv1.
+var GRPCHandshake = Handshake{Protocol: "grpc", Version: "v1"}
+
+// ProgressNotifier may be implemented by a Plugin served by ServeGRPC to report the progress of a delivery, e.g.,
+// "waiting for rate limit", which the daemon logs.
+//
+// If implemented, it is called instead of Plugin.SendNotification and TestNotifier.SendTestNotification, but not instead
+// of StatefulNotifier.SendStatefulNotification.
+type ProgressNotifier interface {
+	SendNotificationWithProgress(req *NotificationRequest, test bool, progress func(message string)) error
+}
+
+// ServeGRPC serves the plugin's methods over gRPC on the socket passed by the daemon, see pluginv1.ChannelPluginServer.
+//
+// It is an alternative to RunPlugin, allowing plugins to report their progress, see ProgressNotifier. As for RunPlugin,
+// the daemon requests the plugin to stop by closing stdin, which is awaited after finishing all pending requests.
+func ServeGRPC(plugin Plugin) error {
+	socket := os.Getenv(EnvPluginSocket)
+	if socket == "" {
+		return fmt.Errorf("%s is not set, the daemon is too old to serve gRPC", EnvPluginSocket)
+	}
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer()
+	pluginv1.RegisterChannelPluginServer(server, &grpcServer{plugin: plugin})
+
+	if err := json.NewEncoder(os.Stdout).Encode(GRPCHandshake); err != nil {
+		return fmt.Errorf("failed to write handshake: %w", err)
+	}
+
+	go func() {
+		// Besides the request probing the protocol, nothing is sent on stdin, thus just wait for it to be closed.
+		_, _ = io.Copy(io.Discard, os.Stdin)
+		server.GracefulStop()
+	}()
+
+	return server.Serve(listener)
+}
+
+// grpcServer implements pluginv1.ChannelPluginServer by a Plugin.
+type grpcServer struct {
+	pluginv1.UnimplementedChannelPluginServer
+
+	plugin Plugin
+}
+
+func (s *grpcServer) GetInfo(context.Context, *pluginv1.GetInfoRequest) (*pluginv1.GetInfoResponse, error) {
+	return InfoToProto(s.plugin.GetInfo())
+}
+
+func (s *grpcServer) SetConfig(_ context.Context, req *pluginv1.SetConfigRequest) (*pluginv1.SetConfigResponse, error) {
+	if err := s.plugin.SetConfig(json.RawMessage(req.GetConfigJson())); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to set plugin config: %v", err)
+	}
+
+	return &pluginv1.SetConfigResponse{}, nil
+}
+
+func (s *grpcServer) SendNotification(
+	req *pluginv1.SendNotificationRequest,
+	stream pluginv1.ChannelPlugin_SendNotificationServer,
+) error {
+	nr := NotificationRequestFromProto(req.GetRequest())
+
+	var state json.RawMessage
+	var err error
+	if stateful, ok := s.plugin.(StatefulNotifier); ok && !req.GetTest() {
+		state, err = stateful.SendStatefulNotification(nr)
+	} else if notifier, ok := s.plugin.(ProgressNotifier); ok {
+		err = notifier.SendNotificationWithProgress(nr, req.GetTest(), func(message string) {
+			_ = stream.Send(&pluginv1.DeliveryStatus{State: pluginv1.DeliveryStatus_STATE_IN_PROGRESS, Message: message})
+		})
+	} else if tester, ok := s.plugin.(TestNotifier); ok && req.GetTest() {
+		err = tester.SendTestNotification(nr)
+	} else {
+		err = s.plugin.SendNotification(nr)
+	}
+	if err != nil {
+		return status.Error(codes.Unknown, err.Error())
+	}
+
+	return stream.Send(&pluginv1.DeliveryStatus{State: pluginv1.DeliveryStatus_STATE_DELIVERED, StateJson: string(state)})
+}
+
+func (s *grpcServer) HealthCheck(context.Context, *pluginv1.HealthCheckRequest) (*pluginv1.HealthCheckResponse, error) {
+	checker, ok := s.plugin.(HealthChecker)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "health check is not implemented")
+	}
+
+	if err := checker.HealthCheck(); err != nil {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+
+	return &pluginv1.HealthCheckResponse{}, nil
+}
+
+// InfoToProto converts the Info into its pluginv1 representation, omitting its Type.
+func InfoToProto(info *Info) (*pluginv1.GetInfoResponse, error) {
+	attrs, err := json.Marshal(info.ConfigAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config attributes: %w", err)
+	}
+
+	return &pluginv1.GetInfoResponse{
+		Name:            info.Name,
+		Version:         info.Version,
+		Author:          info.Author,
+		ConfigAttrsJson: string(attrs),
+	}, nil
+}
+
+// InfoFromProto converts the pluginv1 representation of an Info back, see InfoToProto.
+func InfoFromProto(res *pluginv1.GetInfoResponse) (*Info, error) {
+	info := &Info{Name: res.GetName(), Version: res.GetVersion(), Author: res.GetAuthor()}
+	if attrs := res.GetConfigAttrsJson(); attrs != "" {
+		if err := json.Unmarshal([]byte(attrs), &info.ConfigAttributes); err != nil {
+			return nil, fmt.Errorf("failed to decode config attributes: %w", err)
+		}
+	}
+
+	return info, nil
+}
+
+// NotificationRequestToProto converts the NotificationRequest into its pluginv1 representation.
+func NotificationRequestToProto(req *NotificationRequest) *pluginv1.NotificationRequest {
+	pb := &pluginv1.NotificationRequest{
+		AcknowledgeUrl: req.AcknowledgeUrl,
+		Subject:        req.Subject,
+		Message:        req.Message,
+		StateJson:      string(req.State),
+	}
+
+	if req.Contact != nil {
+		pb.Contact = &pluginv1.Contact{FullName: req.Contact.FullName}
+		for _, addr := range req.Contact.Addresses {
+			pb.Contact.Addresses = append(pb.Contact.Addresses, &pluginv1.Address{Type: addr.Type, Address: addr.Address})
+		}
+	}
+	if req.Object != nil {
+		pb.Object = &pluginv1.Object{
+			Name:      req.Object.Name,
+			Url:       req.Object.Url,
+			Tags:      req.Object.Tags,
+			ExtraTags: req.Object.ExtraTags,
+		}
+	}
+	if req.Incident != nil {
+		pb.Incident = &pluginv1.Incident{Id: req.Incident.Id, Url: req.Incident.Url, Severity: req.Incident.Severity}
+	}
+	if req.Event != nil {
+		pb.Event = &pluginv1.Event{
+			Time:     timestamppb.New(req.Event.Time),
+			Type:     req.Event.Type,
+			Username: req.Event.Username,
+			Message:  req.Event.Message,
+			TraceId:  req.Event.TraceID,
+		}
+	}
+	for _, r := range req.Reasons {
+		pb.Reasons = append(pb.Reasons, &pluginv1.Reason{
+			Escalation: r.Escalation,
+			Role:       r.Role,
+			Schedule:   r.Schedule,
+			Rotation:   r.Rotation,
+			Group:      r.Group,
+		})
+	}
+
+	return pb
+}
+
+// NotificationRequestFromProto converts the pluginv1 representation of a NotificationRequest back, see
+// NotificationRequestToProto.
+func NotificationRequestFromProto(pb *pluginv1.NotificationRequest) *NotificationRequest {
+	req := &NotificationRequest{
+		AcknowledgeUrl: pb.GetAcknowledgeUrl(),
+		Subject:        pb.GetSubject(),
+		Message:        pb.GetMessage(),
+	}
+	if state := pb.GetStateJson(); state != "" {
+		req.State = json.RawMessage(state)
+	}
+
+	if c := pb.GetContact(); c != nil {
+		req.Contact = &Contact{FullName: c.GetFullName()}
+		for _, addr := range c.GetAddresses() {
+			req.Contact.Addresses = append(req.Contact.Addresses, &Address{Type: addr.GetType(), Address: addr.GetAddress()})
+		}
+	}
+	if o := pb.GetObject(); o != nil {
+		req.Object = &Object{Name: o.GetName(), Url: o.GetUrl(), Tags: o.GetTags(), ExtraTags: o.GetExtraTags()}
+	}
+	if i := pb.GetIncident(); i != nil {
+		req.Incident = &Incident{Id: i.GetId(), Url: i.GetUrl(), Severity: i.GetSeverity()}
+	}
+	if ev := pb.GetEvent(); ev != nil {
+		req.Event = &Event{
+			Time:     ev.GetTime().AsTime(),
+			Type:     ev.GetType(),
+			Username: ev.GetUsername(),
+			Message:  ev.GetMessage(),
+			TraceID:  ev.GetTraceId(),
+		}
+	}
+	for _, r := range pb.GetReasons() {
+		req.Reasons = append(req.Reasons, &Reason{
+			Escalation: r.GetEscalation(),
+			Role:       r.GetRole(),
+			Schedule:   r.GetSchedule(),
+			Rotation:   r.GetRotation(),
+			Group:      r.GetGroup(),
+		})
+	}
+
+	return req
+}
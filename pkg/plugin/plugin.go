@@ -12,6 +12,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -20,8 +21,14 @@ const (
 	MethodGetInfo          = "GetInfo"
 	MethodSetConfig        = "SetConfig"
 	MethodSendNotification = "SendNotification"
+	MethodHealthCheck      = "HealthCheck"
+
+	MethodSendTestNotification = "SendTestNotification"
 )
 
+// TraceIDHeader is the HTTP header carrying the Event.TraceID, e.g., sent by the webhook channel to the receiving system.
+const TraceIDHeader = "X-Icinga-Notifications-Trace-Id"
+
 // ConfigOption describes a config element.
 type ConfigOption struct {
 	// Element name
@@ -107,6 +114,9 @@ func (i *Info) TableName() string {
 
 // Contact to receive notifications for the NotificationRequest.
 type Contact struct {
+	// Id of a Contact as defined in Icinga Notifications, unlike its FullName unique.
+	Id int64 `json:"id"`
+
 	// FullName of a Contact as defined in Icinga Notifications.
 	FullName string `json:"full_name"`
 
@@ -163,6 +173,79 @@ type Event struct {
 
 	// Message of this event, might be a check output when the related Object is an Icinga 2 object.
 	Message string `json:"message"`
+
+	// TraceID correlates this event across the logs of Icinga Notifications, its channel plugins and receiving
+	// systems. It is either generated when the event was received or supplied by its source.
+	TraceID string `json:"trace_id,omitempty"`
+
+	// SeverityChange is either "increased" or "decreased" for state events changing the Incident's severity, e.g.,
+	// "decreased" for a recovery, allowing to distinguish escalating incidents. It is empty for all other events.
+	SeverityChange string `json:"severity_change,omitempty"`
+}
+
+// Rule whose escalations notify the Contact, including its annotations telling what to do about the Incident.
+type Rule struct {
+	// Name of the rule.
+	Name string `json:"name"`
+
+	// Description of the rule, e.g., what is affected by the objects it matches.
+	Description string `json:"description,omitempty"`
+
+	// RunbookUrl points to instructions on how to handle Incidents of this rule.
+	RunbookUrl string `json:"runbook_url,omitempty"`
+
+	// ChatChannel to coordinate the handling of Incidents of this rule, e.g., "#team-dba".
+	ChatChannel string `json:"chat_channel,omitempty"`
+}
+
+// HasAnnotations reports whether any of the Rule's annotations is set.
+func (r *Rule) HasAnnotations() bool {
+	return r.Description != "" || r.RunbookUrl != "" || r.ChatChannel != ""
+}
+
+// Reason explains why the Contact receives a NotificationRequest.
+//
+// A Contact might be notified for multiple reasons at once, e.g., being both on call in a schedule of one escalation and
+// a member of a group of another escalation.
+type Reason struct {
+	// Escalation is the name of the rule escalation listing this recipient. It is empty for incident roles.
+	Escalation string `json:"escalation,omitempty"`
+
+	// Role of the Contact within the Incident, e.g., "subscriber", "manager" or "watcher", if not notified due to an
+	// Escalation.
+	Role string `json:"role,omitempty"`
+
+	// Schedule name, if the Contact is currently on call in this schedule.
+	Schedule string `json:"schedule,omitempty"`
+
+	// Rotation name within the Schedule currently assigning the Contact.
+	Rotation string `json:"rotation,omitempty"`
+
+	// Group name, if the Contact was resolved through a contact group, either directly or as a schedule member.
+	Group string `json:"group,omitempty"`
+}
+
+// String describes the Reason in a human-readable form, e.g., "on call in schedule Team DB (rotation Primary)".
+func (r *Reason) String() string {
+	var parts []string
+	if r.Schedule != "" {
+		schedule := "on call in schedule " + r.Schedule
+		if r.Rotation != "" {
+			schedule += " (rotation " + r.Rotation + ")"
+		}
+		parts = append(parts, schedule)
+	}
+	if r.Group != "" {
+		parts = append(parts, "member of group "+r.Group)
+	}
+	if r.Role != "" {
+		parts = append(parts, r.Role+" of this incident")
+	}
+	if r.Escalation != "" {
+		parts = append(parts, "recipient of escalation "+r.Escalation)
+	}
+
+	return strings.Join(parts, ", ")
 }
 
 // NotificationRequest is being sent to a channel plugin via Plugin.SendNotification to request notification dispatching.
@@ -178,6 +261,33 @@ type NotificationRequest struct {
 
 	// Event being responsible for creating this NotificationRequest, e.g., a firing Icinga 2 Service Check.
 	Event *Event `json:"event"`
+
+	// Reasons why the Contact receives this NotificationRequest.
+	Reasons []*Reason `json:"reasons,omitempty"`
+
+	// Rules whose escalations notify the Contact, ordered by their IDs. It is empty for incident roles and watchers.
+	Rules []*Rule `json:"rules,omitempty"`
+
+	// AcknowledgeUrl is a signed, single-use link allowing the Contact to acknowledge the Incident, becoming its
+	// manager. It is only set for problem notifications if acknowledgement links are configured.
+	AcknowledgeUrl string `json:"acknowledge_url,omitempty"`
+
+	// Subject and Message are the notification's subject and message text as rendered by the daemon from the
+	// channel's templates. Plugins should use them via FormatSubject and FormatMessage, falling back to the fixed
+	// format for daemons not sending them.
+	Subject string `json:"subject,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	// State is the state last returned by this channel for the Incident, see StatefulNotifier. It is omitted for the
+	// first notification of an Incident and for plugins not returning any state.
+	State json.RawMessage `json:"state,omitempty"`
+}
+
+// NotificationResult is the result of MethodSendNotification, being empty for plugins not implementing
+// StatefulNotifier.
+type NotificationResult struct {
+	// State to be passed to this channel with all further notifications of the same incident.
+	State json.RawMessage `json:"state,omitempty"`
 }
 
 // Plugin defines necessary methods for a channel plugin.
@@ -195,6 +305,36 @@ type Plugin interface {
 	SendNotification(req *NotificationRequest) error
 }
 
+// HealthChecker may be implemented by a Plugin to check whether it is able to send notifications with its current
+// config, e.g., by connecting to the configured server. The daemon calls it periodically if configured, allowing
+// broken channels to be detected before an incident happens.
+//
+// Plugins not implementing it are considered healthy as long as they respond to the health check request.
+type HealthChecker interface {
+	// HealthCheck returns an error if the plugin is currently unable to send notifications.
+	HealthCheck() error
+}
+
+// TestNotifier may be implemented by a Plugin to handle test notifications, requested by an administrator to verify the
+// channel's config, differently from regular ones, e.g., by skipping steps depending on a real incident.
+//
+// Plugins not implementing it receive test notifications via Plugin.SendNotification.
+type TestNotifier interface {
+	// SendTestNotification sends the synthetic test notification, returns an error on failure.
+	SendTestNotification(req *NotificationRequest) error
+}
+
+// StatefulNotifier may be implemented by a Plugin to keep state about an incident across its notifications, e.g., the
+// key of a ticket created for the incident in an external system.
+//
+// The returned state is stored by the daemon for the incident and channel, and passed back as
+// NotificationRequest.State with the next notification of that incident. Returning a nil state keeps the current one.
+// If implemented, it is called instead of Plugin.SendNotification, except for test notifications.
+type StatefulNotifier interface {
+	// SendStatefulNotification sends the notification, returns the incident's new state or an error on failure.
+	SendStatefulNotification(req *NotificationRequest) (json.RawMessage, error)
+}
+
 // PopulateDefaults sets the struct fields from Info.ConfigAttributes where ConfigOption.Default is set.
 //
 // It should be called from each channel plugin within its Plugin.SetConfig before doing any further configuration.
@@ -255,12 +395,35 @@ func RunPlugin(plugin Plugin) {
 					response.Error = fmt.Errorf("failed to set plugin config: %w", err).Error()
 				}
 
-			case MethodSendNotification:
+			case MethodSendNotification, MethodSendTestNotification:
+				var state json.RawMessage
+				send := plugin.SendNotification
+				if stateful, ok := plugin.(StatefulNotifier); ok && request.Method == MethodSendNotification {
+					send = func(req *NotificationRequest) (err error) {
+						state, err = stateful.SendStatefulNotification(req)
+						return err
+					}
+				}
+				if tester, ok := plugin.(TestNotifier); ok && request.Method == MethodSendTestNotification {
+					send = tester.SendTestNotification
+				}
+
 				var nr NotificationRequest
 				if err = json.Unmarshal(request.Params, &nr); err != nil {
 					response.Error = fmt.Errorf("failed to json.Unmarshal request: %w", err).Error()
-				} else if err = plugin.SendNotification(&nr); err != nil {
+				} else if err = send(&nr); err != nil {
 					response.Error = err.Error()
+				} else if state != nil {
+					if response.Result, err = json.Marshal(NotificationResult{State: state}); err != nil {
+						response.Error = fmt.Errorf("failed to marshal notification state: %w", err).Error()
+					}
+				}
+
+			case MethodHealthCheck:
+				if checker, ok := plugin.(HealthChecker); ok {
+					if err = checker.HealthCheck(); err != nil {
+						response.Error = err.Error()
+					}
 				}
 
 			default:
@@ -281,8 +444,14 @@ func RunPlugin(plugin Plugin) {
 
 // FormatMessage formats a NotificationRequest message and adds to the given io.Writer.
 //
-// The created message is a multi-line message as one might expect it in an email.
+// The created message is a multi-line message as one might expect it in an email. If the daemon rendered the message
+// from a template, NotificationRequest.Message is written instead.
 func FormatMessage(writer io.Writer, req *NotificationRequest) {
+	if req.Message != "" {
+		_, _ = io.WriteString(writer, req.Message)
+		return
+	}
+
 	if req.Event.Message != "" {
 		msgTitle := "Comment"
 		if req.Event.Type == event.TypeState {
@@ -313,10 +482,43 @@ func FormatMessage(writer io.Writer, req *NotificationRequest) {
 	}
 
 	_, _ = fmt.Fprintf(writer, "\nIncident: %s", req.Incident.Url)
+
+	if req.AcknowledgeUrl != "" {
+		_, _ = fmt.Fprintf(writer, "\nAcknowledge: %s", req.AcknowledgeUrl)
+	}
+
+	for _, rule := range req.Rules {
+		if !rule.HasAnnotations() {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(writer, "\n\nRule %s:", rule.Name)
+		if rule.Description != "" {
+			_, _ = fmt.Fprintf(writer, "\n%s", rule.Description)
+		}
+		if rule.RunbookUrl != "" {
+			_, _ = fmt.Fprintf(writer, "\nRunbook: %s", rule.RunbookUrl)
+		}
+		if rule.ChatChannel != "" {
+			_, _ = fmt.Fprintf(writer, "\nChat: %s", rule.ChatChannel)
+		}
+	}
+
+	if len(req.Reasons) > 0 {
+		_, _ = writer.Write([]byte("\n\nYou are receiving this notification as:\n"))
+		for _, reason := range req.Reasons {
+			_, _ = fmt.Fprintf(writer, "- %s\n", reason)
+		}
+	}
 }
 
-// FormatSubject returns the formatted subject string based on the event type.
+// FormatSubject returns the formatted subject string based on the event type, or NotificationRequest.Subject if the
+// daemon rendered it from a template.
 func FormatSubject(req *NotificationRequest) string {
+	if req.Subject != "" {
+		return req.Subject
+	}
+
 	switch req.Event.Type {
 	case event.TypeState:
 		return fmt.Sprintf("[#%d] %s %s is %s", req.Incident.Id, req.Event.Type, req.Object.Name, req.Incident.Severity)
@@ -0,0 +1,4 @@
+// Package pluginv1 contains the Go code generated from plugin.proto, the gRPC protocol of channel plugins.
+package pluginv1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative plugin.proto
@@ -0,0 +1,259 @@
+// Protocol of channel plugins served over gRPC, being an alternative to the JSON-RPC protocol on stdin and stdout.
+//
+// The daemon passes the path of a Unix socket in the ICINGA_NOTIFICATIONS_PLUGIN_SOCKET environment variable. A plugin
+// serving this protocol listens on it and then writes the handshake line {"protocol":"grpc","version":"v1"} to stdout.
+// The messages mirror the JSON objects of the JSON-RPC protocol, see the Go types of the same name in pkg/plugin.
+//
+// Go code is generated by protoc-gen-go and protoc-gen-go-grpc, see `go generate ./pkg/plugin/proto/v1`.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: plugin.proto
+
+package pluginv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ChannelPlugin_GetInfo_FullMethodName          = "/icinga.notifications.plugin.v1.ChannelPlugin/GetInfo"
+	ChannelPlugin_SetConfig_FullMethodName        = "/icinga.notifications.plugin.v1.ChannelPlugin/SetConfig"
+	ChannelPlugin_SendNotification_FullMethodName = "/icinga.notifications.plugin.v1.ChannelPlugin/SendNotification"
+	ChannelPlugin_HealthCheck_FullMethodName      = "/icinga.notifications.plugin.v1.ChannelPlugin/HealthCheck"
+)
+
+// ChannelPluginClient is the client API for ChannelPlugin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ChannelPluginClient interface {
+	// GetInfo returns the plugin's information, including its config options.
+	GetInfo(ctx context.Context, in *GetInfoRequest, opts ...grpc.CallOption) (*GetInfoResponse, error)
+	// SetConfig configures the plugin, failing with INVALID_ARGUMENT for an invalid config.
+	SetConfig(ctx context.Context, in *SetConfigRequest, opts ...grpc.CallOption) (*SetConfigResponse, error)
+	// SendNotification delivers a notification, optionally reporting its progress by IN_PROGRESS statuses. The stream
+	// ends after a single DELIVERED status or fails with the reason of the failed delivery.
+	SendNotification(ctx context.Context, in *SendNotificationRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DeliveryStatus], error)
+	// HealthCheck fails if the plugin is currently unable to send notifications with its config. Plugins not
+	// implementing it, i.e., responding with UNIMPLEMENTED, are considered healthy.
+	HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error)
+}
+
+type channelPluginClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewChannelPluginClient(cc grpc.ClientConnInterface) ChannelPluginClient {
+	return &channelPluginClient{cc}
+}
+
+func (c *channelPluginClient) GetInfo(ctx context.Context, in *GetInfoRequest, opts ...grpc.CallOption) (*GetInfoResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetInfoResponse)
+	err := c.cc.Invoke(ctx, ChannelPlugin_GetInfo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *channelPluginClient) SetConfig(ctx context.Context, in *SetConfigRequest, opts ...grpc.CallOption) (*SetConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetConfigResponse)
+	err := c.cc.Invoke(ctx, ChannelPlugin_SetConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *channelPluginClient) SendNotification(ctx context.Context, in *SendNotificationRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DeliveryStatus], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ChannelPlugin_ServiceDesc.Streams[0], ChannelPlugin_SendNotification_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SendNotificationRequest, DeliveryStatus]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChannelPlugin_SendNotificationClient = grpc.ServerStreamingClient[DeliveryStatus]
+
+func (c *channelPluginClient) HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HealthCheckResponse)
+	err := c.cc.Invoke(ctx, ChannelPlugin_HealthCheck_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ChannelPluginServer is the server API for ChannelPlugin service.
+// All implementations must embed UnimplementedChannelPluginServer
+// for forward compatibility.
+type ChannelPluginServer interface {
+	// GetInfo returns the plugin's information, including its config options.
+	GetInfo(context.Context, *GetInfoRequest) (*GetInfoResponse, error)
+	// SetConfig configures the plugin, failing with INVALID_ARGUMENT for an invalid config.
+	SetConfig(context.Context, *SetConfigRequest) (*SetConfigResponse, error)
+	// SendNotification delivers a notification, optionally reporting its progress by IN_PROGRESS statuses. The stream
+	// ends after a single DELIVERED status or fails with the reason of the failed delivery.
+	SendNotification(*SendNotificationRequest, grpc.ServerStreamingServer[DeliveryStatus]) error
+	// HealthCheck fails if the plugin is currently unable to send notifications with its config. Plugins not
+	// implementing it, i.e., responding with UNIMPLEMENTED, are considered healthy.
+	HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error)
+	mustEmbedUnimplementedChannelPluginServer()
+}
+
+// UnimplementedChannelPluginServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedChannelPluginServer struct{}
+
+func (UnimplementedChannelPluginServer) GetInfo(context.Context, *GetInfoRequest) (*GetInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInfo not implemented")
+}
+func (UnimplementedChannelPluginServer) SetConfig(context.Context, *SetConfigRequest) (*SetConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetConfig not implemented")
+}
+func (UnimplementedChannelPluginServer) SendNotification(*SendNotificationRequest, grpc.ServerStreamingServer[DeliveryStatus]) error {
+	return status.Errorf(codes.Unimplemented, "method SendNotification not implemented")
+}
+func (UnimplementedChannelPluginServer) HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HealthCheck not implemented")
+}
+func (UnimplementedChannelPluginServer) mustEmbedUnimplementedChannelPluginServer() {}
+func (UnimplementedChannelPluginServer) testEmbeddedByValue()                       {}
+
+// UnsafeChannelPluginServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ChannelPluginServer will
+// result in compilation errors.
+type UnsafeChannelPluginServer interface {
+	mustEmbedUnimplementedChannelPluginServer()
+}
+
+func RegisterChannelPluginServer(s grpc.ServiceRegistrar, srv ChannelPluginServer) {
+	// If the following call pancis, it indicates UnimplementedChannelPluginServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ChannelPlugin_ServiceDesc, srv)
+}
+
+func _ChannelPlugin_GetInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChannelPluginServer).GetInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChannelPlugin_GetInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChannelPluginServer).GetInfo(ctx, req.(*GetInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChannelPlugin_SetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChannelPluginServer).SetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChannelPlugin_SetConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChannelPluginServer).SetConfig(ctx, req.(*SetConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChannelPlugin_SendNotification_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SendNotificationRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChannelPluginServer).SendNotification(m, &grpc.GenericServerStream[SendNotificationRequest, DeliveryStatus]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChannelPlugin_SendNotificationServer = grpc.ServerStreamingServer[DeliveryStatus]
+
+func _ChannelPlugin_HealthCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthCheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChannelPluginServer).HealthCheck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChannelPlugin_HealthCheck_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChannelPluginServer).HealthCheck(ctx, req.(*HealthCheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ChannelPlugin_ServiceDesc is the grpc.ServiceDesc for ChannelPlugin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ChannelPlugin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "icinga.notifications.plugin.v1.ChannelPlugin",
+	HandlerType: (*ChannelPluginServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetInfo",
+			Handler:    _ChannelPlugin_GetInfo_Handler,
+		},
+		{
+			MethodName: "SetConfig",
+			Handler:    _ChannelPlugin_SetConfig_Handler,
+		},
+		{
+			MethodName: "HealthCheck",
+			Handler:    _ChannelPlugin_HealthCheck_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SendNotification",
+			Handler:       _ChannelPlugin_SendNotification_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "plugin.proto",
+}
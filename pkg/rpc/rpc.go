@@ -33,6 +33,17 @@ func (err *Error) Unwrap() error {
 	return err.cause
 }
 
+// ResponseError is returned by Call if the remote side processed the request but responded with an error.
+//
+// In contrast to Error, the communication itself did not fail.
+type ResponseError struct {
+	Message string
+}
+
+func (err *ResponseError) Error() string {
+	return err.Message
+}
+
 type RPC struct {
 	writer    io.Closer // use encoder for writing instead
 	encoder   *json.Encoder
@@ -108,7 +119,7 @@ func (r *RPC) Call(method string, params json.RawMessage) (json.RawMessage, erro
 	select {
 	case response := <-promise:
 		if response.Error != "" {
-			return nil, errors.New(response.Error)
+			return nil, &ResponseError{Message: response.Error}
 		}
 
 		return response.Result, nil
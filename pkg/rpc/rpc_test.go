@@ -35,6 +35,19 @@ func TestRPC(t *testing.T) {
 	wg.Wait()
 }
 
+func TestRPC_ResponseError(t *testing.T) {
+	writer, reader := dummyRemote()
+	rpc := NewRPC(writer, reader, zaptest.NewLogger(t).Sugar())
+
+	_, err := rpc.Call("fail", json.RawMessage(`"invalid credentials"`))
+
+	var respErr *ResponseError
+	if assert.ErrorAs(t, err, &respErr) {
+		assert.Equal(t, `"invalid credentials"`, respErr.Message)
+	}
+	assert.NoError(t, rpc.Err(), "a response error must not break the RPC connection")
+}
+
 func dummyRemote() (io.WriteCloser, io.Reader) {
 	reqReader, reqWriter := io.Pipe()
 	resReader, resWriter := io.Pipe()
@@ -53,7 +66,11 @@ func dummyRemote() (io.WriteCloser, io.Reader) {
 			var res Response
 
 			res.Id = req.Id
-			res.Result = req.Params
+			if req.Method == "fail" {
+				res.Error = string(req.Params)
+			} else {
+				res.Result = req.Params
+			}
 
 			err = enc.Encode(&res)
 			if err != nil {
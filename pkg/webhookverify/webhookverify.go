@@ -0,0 +1,166 @@
+// Package webhookverify verifies the signatures of requests sent by the webhook channel of Icinga Notifications with the
+// HMAC authentication, allowing receivers written in Go to reject forged or replayed requests.
+//
+// The channel signs the decimal Unix timestamp of the request, a dot and the request body with HMAC-SHA256 and sends
+// the timestamp in the TimestampHeader and the hex-encoded signature, prefixed by "sha256=", in the configured signature
+// header, DefaultSignatureHeader unless changed:
+//
+//	X-Icinga-Notifications-Timestamp: 1700000000
+//	X-Icinga-Notifications-Signature: sha256=80c06ec4776489890b4062e2492ef6eb936a6bf5c2be7e1d8652aeda8cb49aa1
+package webhookverify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TimestampHeader carries the Unix timestamp included in the signature.
+	TimestampHeader = "X-Icinga-Notifications-Timestamp"
+
+	// DefaultSignatureHeader carries the signature unless the channel is configured to use another header.
+	DefaultSignatureHeader = "X-Icinga-Notifications-Signature"
+
+	// DefaultTolerance is the maximum difference between the signed timestamp and the current time accepted by default.
+	DefaultTolerance = 5 * time.Minute
+
+	// DefaultMaxBodyBytes limits the size of request bodies read by Verifier.VerifyRequest by default.
+	DefaultMaxBodyBytes = 1 << 20
+
+	// signaturePrefix identifies the hash function of the signature.
+	signaturePrefix = "sha256="
+)
+
+var (
+	// ErrMissingHeader is returned if either the timestamp or the signature header is missing.
+	ErrMissingHeader = errors.New("missing signature header")
+
+	// ErrTimestampOutOfTolerance is returned if the signed timestamp is too far in the past or future.
+	ErrTimestampOutOfTolerance = errors.New("signature timestamp is out of tolerance")
+
+	// ErrInvalidSignature is returned if the signature does not match the timestamp and body.
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Sign returns the signature of the body sent at the timestamp, as sent by the webhook channel.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10) + "."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verifier checks the signatures of requests. Only Secret is required, all other fields have sensible defaults.
+type Verifier struct {
+	// Secret is the HMAC secret configured for the channel.
+	Secret string
+
+	// SignatureHeader defaults to DefaultSignatureHeader.
+	SignatureHeader string
+
+	// Tolerance defaults to DefaultTolerance. A negative value disables the check of the timestamp, leaving the
+	// receiver vulnerable to replayed requests.
+	Tolerance time.Duration
+
+	// MaxBodyBytes defaults to DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// Verify checks the signature and the timestamp found in the header against the request body.
+//
+// The returned error wraps one of ErrMissingHeader, ErrTimestampOutOfTolerance or ErrInvalidSignature.
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	signatureHeader := v.SignatureHeader
+	if signatureHeader == "" {
+		signatureHeader = DefaultSignatureHeader
+	}
+
+	rawTimestamp, signature := header.Get(TimestampHeader), header.Get(signatureHeader)
+	if rawTimestamp == "" {
+		return fmt.Errorf("%w: %s", ErrMissingHeader, TimestampHeader)
+	}
+	if signature == "" {
+		return fmt.Errorf("%w: %s", ErrMissingHeader, signatureHeader)
+	}
+
+	unix, err := strconv.ParseInt(rawTimestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed timestamp %q", ErrInvalidSignature, rawTimestamp)
+	}
+	timestamp := time.Unix(unix, 0)
+
+	// Check the signature first, so that an attacker does not learn anything about the tolerance.
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return fmt.Errorf("%w: expected a %q prefix", ErrInvalidSignature, signaturePrefix)
+	}
+	if !hmac.Equal([]byte(Sign(v.Secret, timestamp, body)), []byte(signature)) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+
+	tolerance := v.Tolerance
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+
+	if age := now().Sub(timestamp); tolerance > 0 && (age > tolerance || age < -tolerance) {
+		return fmt.Errorf("%w: timestamp is off by %v", ErrTimestampOutOfTolerance, age.Round(time.Second))
+	}
+
+	return nil
+}
+
+// VerifyRequest reads the request body and verifies it, see Verify. The body is returned and also restored, so that the
+// request can be processed as usual afterwards.
+func (v *Verifier) VerifyRequest(req *http.Request) ([]byte, error) {
+	maxBodyBytes := v.MaxBodyBytes
+	if maxBodyBytes == 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBodyBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read request body: %w", err)
+	}
+	if int64(len(body)) > maxBodyBytes {
+		return nil, fmt.Errorf("request body exceeds %d bytes", maxBodyBytes)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, v.Verify(req.Header, body)
+}
+
+// Middleware responds with 401 Unauthorized to requests without a valid signature, with 400 Bad Request to requests
+// whose body cannot be read, and passes all others to next.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, err := v.VerifyRequest(req); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrMissingHeader) || errors.Is(err, ErrTimestampOutOfTolerance) || errors.Is(err, ErrInvalidSignature) {
+				status = http.StatusUnauthorized
+			}
+
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,138 @@
+package webhookverify
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The contract of the signatures sent by the webhook channel. Changing any of these breaks all existing receivers.
+const (
+	contractSecret    = "secret"
+	contractTimestamp = "1700000000"
+	contractBody      = `{"id":42}`
+	// echo -n '1700000000.{"id":42}' | openssl dgst -sha256 -hmac secret
+	contractSignature = "sha256=80c06ec4776489890b4062e2492ef6eb936a6bf5c2be7e1d8652aeda8cb49aa1"
+)
+
+func contractHeader() http.Header {
+	header := http.Header{}
+	header.Set("X-Icinga-Notifications-Timestamp", contractTimestamp)
+	header.Set("X-Icinga-Notifications-Signature", contractSignature)
+
+	return header
+}
+
+func newVerifier(at time.Time) *Verifier {
+	return &Verifier{Secret: contractSecret, Now: func() time.Time { return at }}
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, contractSignature, Sign(contractSecret, time.Unix(1700000000, 0), []byte(contractBody)))
+	assert.Equal(t, contractSignature, Sign(contractSecret, time.Unix(1700000000, 999999999), []byte(contractBody)),
+		"only whole seconds should be signed")
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	signedAt := time.Unix(1700000000, 0)
+
+	t.Run("Valid", func(t *testing.T) {
+		assert.NoError(t, newVerifier(signedAt).Verify(contractHeader(), []byte(contractBody)))
+		assert.NoError(t, newVerifier(signedAt.Add(DefaultTolerance)).Verify(contractHeader(), []byte(contractBody)))
+		assert.NoError(t, newVerifier(signedAt.Add(-DefaultTolerance)).Verify(contractHeader(), []byte(contractBody)),
+			"clocks of the sender might be ahead")
+	})
+
+	t.Run("CustomSignatureHeader", func(t *testing.T) {
+		header := contractHeader()
+		header.Set("X-Signature", header.Get("X-Icinga-Notifications-Signature"))
+		header.Del("X-Icinga-Notifications-Signature")
+
+		v := newVerifier(signedAt)
+		assert.ErrorIs(t, v.Verify(header, []byte(contractBody)), ErrMissingHeader)
+
+		v.SignatureHeader = "X-Signature"
+		assert.NoError(t, v.Verify(header, []byte(contractBody)))
+	})
+
+	t.Run("MissingHeader", func(t *testing.T) {
+		for _, name := range []string{"X-Icinga-Notifications-Timestamp", "X-Icinga-Notifications-Signature"} {
+			header := contractHeader()
+			header.Del(name)
+
+			err := newVerifier(signedAt).Verify(header, []byte(contractBody))
+			assert.ErrorIs(t, err, ErrMissingHeader)
+			assert.ErrorContains(t, err, name)
+		}
+	})
+
+	t.Run("InvalidSignature", func(t *testing.T) {
+		v := newVerifier(signedAt)
+		assert.ErrorIs(t, v.Verify(contractHeader(), []byte(`{"id":43}`)), ErrInvalidSignature, "tampered body")
+
+		header := contractHeader()
+		header.Set("X-Icinga-Notifications-Timestamp", "1700000001")
+		assert.ErrorIs(t, v.Verify(header, []byte(contractBody)), ErrInvalidSignature, "tampered timestamp")
+
+		header = contractHeader()
+		header.Set("X-Icinga-Notifications-Timestamp", "yesterday")
+		assert.ErrorIs(t, v.Verify(header, []byte(contractBody)), ErrInvalidSignature, "malformed timestamp")
+
+		header = contractHeader()
+		header.Set("X-Icinga-Notifications-Signature", strings.TrimPrefix(contractSignature, "sha256="))
+		assert.ErrorIs(t, v.Verify(header, []byte(contractBody)), ErrInvalidSignature, "missing prefix")
+
+		v.Secret = "wrong"
+		assert.ErrorIs(t, v.Verify(contractHeader(), []byte(contractBody)), ErrInvalidSignature, "other secret")
+	})
+
+	t.Run("Tolerance", func(t *testing.T) {
+		err := newVerifier(signedAt.Add(DefaultTolerance+time.Second)).Verify(contractHeader(), []byte(contractBody))
+		assert.ErrorIs(t, err, ErrTimestampOutOfTolerance)
+		assert.ErrorContains(t, err, "off by 5m1s")
+
+		err = newVerifier(signedAt.Add(-DefaultTolerance-time.Second)).Verify(contractHeader(), []byte(contractBody))
+		assert.ErrorIs(t, err, ErrTimestampOutOfTolerance)
+
+		v := newVerifier(signedAt.Add(time.Hour))
+		v.Tolerance = 2 * time.Hour
+		assert.NoError(t, v.Verify(contractHeader(), []byte(contractBody)))
+
+		v = newVerifier(signedAt.Add(24 * time.Hour))
+		v.Tolerance = -1
+		assert.NoError(t, v.Verify(contractHeader(), []byte(contractBody)), "negative tolerance should disable the check")
+	})
+}
+
+func TestVerifier_Middleware(t *testing.T) {
+	v := newVerifier(time.Unix(1700000000, 0))
+	v.MaxBodyBytes = 16
+
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		_, _ = w.Write(body)
+	}))
+
+	serve := func(header http.Header, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header = header
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := serve(contractHeader(), contractBody)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, contractBody, w.Body.String(), "body should be restored for the next handler")
+
+	assert.Equal(t, http.StatusUnauthorized, serve(contractHeader(), `{"id":43}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(http.Header{}, contractBody).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(contractHeader(), strings.Repeat("x", 17)).Code)
+}
@@ -11,10 +11,13 @@ CREATE TYPE incident_history_event_type AS ENUM (
     'escalation_triggered',
     'recipient_role_changed',
     'closed',
-    'notified'
+    'notified',
+    'renotified',
+    'acknowledgement_quorum_reached',
+    'contact_channel_degraded'
 );
 CREATE TYPE rotation_type AS ENUM ( '24-7', 'partial', 'multi' );
-CREATE TYPE notification_state_type AS ENUM ( 'suppressed', 'pending', 'sent', 'failed' );
+CREATE TYPE notification_state_type AS ENUM ( 'suppressed', 'pending', 'sent', 'failed', 'throttled' );
 
 -- IPL ORM renders SQL queries with LIKE operators for all suggestions in the search bar,
 -- which fails for numeric and enum types on PostgreSQL. Just like in Icinga DB Web.
@@ -40,8 +43,24 @@ CREATE TABLE available_channel_type (
     CONSTRAINT pk_available_channel_type PRIMARY KEY (type)
 );
 
+-- Sources, rules, contacts, schedules and channels can be scoped to a tenant, e.g., a team or customer sharing the
+-- daemon with others. Events of a tenant's source are only processed by the rules of the same tenant and notify its
+-- contacts via its channels, while objects without a tenant are shared by all tenants.
+CREATE TABLE tenant (
+    id bigserial,
+    name citext NOT NULL,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_tenant PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_tenant_changed_at ON tenant(changed_at);
+
 CREATE TABLE channel (
     id bigserial,
+    tenant_id bigint,
     name citext NOT NULL,
     type varchar(255) NOT NULL, -- 'email', 'sms', ...
     config text, -- JSON with channel-specific attributes
@@ -52,16 +71,23 @@ CREATE TABLE channel (
     deleted boolenum NOT NULL DEFAULT 'n',
 
     CONSTRAINT pk_channel PRIMARY KEY (id),
-    CONSTRAINT fk_channel_available_channel_type FOREIGN KEY (type) REFERENCES available_channel_type(type)
+    CONSTRAINT fk_channel_available_channel_type FOREIGN KEY (type) REFERENCES available_channel_type(type),
+    CONSTRAINT fk_channel_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id)
 );
 
 CREATE INDEX idx_channel_changed_at ON channel(changed_at);
 
 CREATE TABLE contact (
     id bigserial,
+    tenant_id bigint,
     full_name citext NOT NULL,
     username citext, -- reference to web user
     default_channel_id bigint NOT NULL,
+    -- Contacts out of office are not notified, but their substitute instead, if any.
+    out_of_office boolenum NOT NULL DEFAULT 'n',
+    substitute_contact_id bigint,
+    -- During the quiet hours, only notifications of incidents of at least critical severity are delivered.
+    quiet_hours_timeperiod_id bigint,
 
     changed_at bigint NOT NULL,
     deleted boolenum NOT NULL DEFAULT 'n',
@@ -72,7 +98,9 @@ CREATE TABLE contact (
     CONSTRAINT uk_contact_username UNIQUE (username),
 
     CONSTRAINT ck_contact_username_up_to_254_chars CHECK (length(username) <= 254),
-    CONSTRAINT fk_contact_channel FOREIGN KEY (default_channel_id) REFERENCES channel(id)
+    CONSTRAINT fk_contact_channel FOREIGN KEY (default_channel_id) REFERENCES channel(id),
+    CONSTRAINT fk_contact_substitute_contact FOREIGN KEY (substitute_contact_id) REFERENCES contact(id),
+    CONSTRAINT fk_contact_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id)
 );
 
 CREATE INDEX idx_contact_changed_at ON contact(changed_at);
@@ -82,6 +110,8 @@ CREATE TABLE contact_address (
     contact_id bigint NOT NULL,
     type varchar(255) NOT NULL, -- 'phone', 'email', ...
     address text NOT NULL, -- phone number, email address, ...
+    -- Addresses not verified yet, e.g., as the contact didn't confirm them, are avoided when routing notifications.
+    verified boolenum NOT NULL DEFAULT 'y',
 
     changed_at bigint NOT NULL,
     deleted boolenum NOT NULL DEFAULT 'n',
@@ -92,6 +122,47 @@ CREATE TABLE contact_address (
 
 CREATE INDEX idx_contact_address_changed_at ON contact_address(changed_at);
 
+-- Temporary opt-outs of a contact from a channel, or from all channels if channel_id is NULL. Opt-outs must expire and
+-- are never deleted, but ended early by setting ended_at, keeping them as an audit trail.
+CREATE TABLE contact_opt_out (
+    id bigserial,
+    contact_id bigint NOT NULL,
+    channel_id bigint,
+    reason text,
+    created_by citext, -- name of the API token or user who created the opt-out
+    created_at bigint NOT NULL,
+    expires_at bigint NOT NULL,
+    ended_at bigint,
+    ended_by citext,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_contact_opt_out PRIMARY KEY (id),
+    CONSTRAINT fk_contact_opt_out_contact FOREIGN KEY (contact_id) REFERENCES contact(id),
+    CONSTRAINT fk_contact_opt_out_channel FOREIGN KEY (channel_id) REFERENCES channel(id),
+    CONSTRAINT ck_contact_opt_out_expires_after_creation CHECK (expires_at > created_at)
+);
+
+CREATE INDEX idx_contact_opt_out_changed_at ON contact_opt_out(changed_at);
+
+-- Minimum severity of the incidents a contact is notified about via a channel.
+CREATE TABLE contact_channel_preference (
+    id bigserial,
+    contact_id bigint NOT NULL,
+    channel_id bigint NOT NULL,
+    min_severity severity NOT NULL,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_contact_channel_preference PRIMARY KEY (id),
+    CONSTRAINT fk_contact_channel_preference_contact FOREIGN KEY (contact_id) REFERENCES contact(id),
+    CONSTRAINT fk_contact_channel_preference_channel FOREIGN KEY (channel_id) REFERENCES channel(id)
+);
+
+CREATE INDEX idx_contact_channel_preference_changed_at ON contact_channel_preference(changed_at);
+
 CREATE TABLE contactgroup (
     id bigserial,
     name citext NOT NULL,
@@ -120,12 +191,18 @@ CREATE INDEX idx_contactgroup_member_changed_at ON contactgroup_member(changed_a
 
 CREATE TABLE schedule (
     id bigserial,
+    tenant_id bigint,
     name citext NOT NULL,
 
+    -- ics_feed_url optionally references an iCalendar feed, periodically fetched to import additional on-call shifts.
+    -- Each event is assigned to the contact matching either an attendee's email address or the event's summary.
+    ics_feed_url text,
+
     changed_at bigint NOT NULL,
     deleted boolenum NOT NULL DEFAULT 'n',
 
-    CONSTRAINT pk_schedule PRIMARY KEY (id)
+    CONSTRAINT pk_schedule PRIMARY KEY (id),
+    CONSTRAINT fk_schedule_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id)
 );
 
 CREATE INDEX idx_schedule_changed_at ON schedule(changed_at);
@@ -165,9 +242,33 @@ CREATE TABLE rotation (
 
 CREATE INDEX idx_rotation_changed_at ON rotation(changed_at);
 
+-- An override temporarily assigns a contact to a schedule for the time between start_time and end_time, e.g., to cover
+-- for a colleague on vacation or to swap shifts. If replaced_contact_id is set, the contact only takes over the shifts
+-- of this contact. Otherwise, the contact takes over the whole schedule, replacing everyone on call.
+CREATE TABLE schedule_override (
+    id bigserial,
+    schedule_id bigint NOT NULL,
+    contact_id bigint NOT NULL,
+    replaced_contact_id bigint,
+    start_time bigint NOT NULL,
+    end_time bigint NOT NULL,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_schedule_override PRIMARY KEY (id),
+    CONSTRAINT ck_schedule_override_time_range CHECK (start_time < end_time),
+    CONSTRAINT fk_schedule_override_schedule FOREIGN KEY (schedule_id) REFERENCES schedule(id),
+    CONSTRAINT fk_schedule_override_contact FOREIGN KEY (contact_id) REFERENCES contact(id),
+    CONSTRAINT fk_schedule_override_replaced_contact FOREIGN KEY (replaced_contact_id) REFERENCES contact(id)
+);
+
+CREATE INDEX idx_schedule_override_changed_at ON schedule_override(changed_at);
+
 CREATE TABLE timeperiod (
     id bigserial,
     owned_by_rotation_id bigint, -- nullable for future standalone timeperiods
+    name text, -- referred to by within_timeperiod escalation conditions, only set for standalone timeperiods
 
     changed_at bigint NOT NULL,
     deleted boolenum NOT NULL DEFAULT 'n',
@@ -178,6 +279,10 @@ CREATE TABLE timeperiod (
 
 CREATE INDEX idx_timeperiod_changed_at ON timeperiod(changed_at);
 
+-- The contact table is created before the timeperiod table, thus the quiet hours are referenced afterwards.
+ALTER TABLE contact ADD CONSTRAINT fk_contact_quiet_hours_timeperiod
+    FOREIGN KEY (quiet_hours_timeperiod_id) REFERENCES timeperiod(id);
+
 CREATE TABLE rotation_member (
     id bigserial,
     rotation_id bigint NOT NULL,
@@ -231,8 +336,30 @@ CREATE TABLE timeperiod_entry (
 
 CREATE INDEX idx_timeperiod_entry_changed_at ON timeperiod_entry(changed_at);
 
+-- A contactgroup with regions routes notifications in a follow-the-sun manner. Instead of all its members, only the
+-- members of those regional contactgroups are notified whose timeperiod, e.g., local business hours, is active. If no
+-- region is active, the members of all regional contactgroups are notified.
+CREATE TABLE contactgroup_region (
+    id bigserial,
+    contactgroup_id bigint NOT NULL,
+    region_contactgroup_id bigint NOT NULL,
+    timeperiod_id bigint NOT NULL,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_contactgroup_region PRIMARY KEY (id),
+    CONSTRAINT ck_contactgroup_region_not_self CHECK (contactgroup_id != region_contactgroup_id),
+    CONSTRAINT fk_contactgroup_region_contactgroup FOREIGN KEY (contactgroup_id) REFERENCES contactgroup(id),
+    CONSTRAINT fk_contactgroup_region_region_contactgroup FOREIGN KEY (region_contactgroup_id) REFERENCES contactgroup(id),
+    CONSTRAINT fk_contactgroup_region_timeperiod FOREIGN KEY (timeperiod_id) REFERENCES timeperiod(id)
+);
+
+CREATE INDEX idx_contactgroup_region_changed_at ON contactgroup_region(changed_at);
+
 CREATE TABLE source (
     id bigserial,
+    tenant_id bigint,
     -- The type "icinga2" is special and requires (at least some of) the icinga2_ prefixed columns.
     type text NOT NULL,
     name citext NOT NULL,
@@ -243,9 +370,16 @@ CREATE TABLE source (
     -- If type is not "icinga2", listener_password_hash is required to limit API access for incoming connections
     -- to the Listener. The username will be "source-${id}", allowing early verification.
     listener_password_hash text,
+    -- Alternatively, a source can authenticate via mutual TLS if the listener terminates TLS. Its client certificate
+    -- must carry listener_client_name as its Common Name or a DNS Subject Alternative Name and is verified against
+    -- listener_client_ca_pem, a PEM-encoded CA bundle, and/or its hex-encoded SHA-256 listener_client_fingerprint.
+    listener_client_name text,
+    listener_client_ca_pem text,
+    listener_client_fingerprint text,
 
     -- Following columns are for the "icinga2" type.
     -- At least icinga2_base_url, icinga2_auth_user, and icinga2_auth_pass are required - see CHECK below.
+    -- icinga2_base_url may list the API endpoints of an HA zone separated by commas to fail over between them.
     icinga2_base_url text,
     icinga2_auth_user text,
     icinga2_auth_pass text,
@@ -255,6 +389,28 @@ CREATE TABLE source (
     -- differing Common Name - maybe an Icinga 2 Endpoint object name - from the FQDN within icinga2_base_url.
     icinga2_common_name text,
     icinga2_insecure_tls boolenum NOT NULL DEFAULT 'n',
+    -- icinga2_write_back writes acknowledgements and snoozes of incidents back to Icinga 2 via the actions API.
+    icinga2_write_back boolenum NOT NULL DEFAULT 'n',
+    -- icinga2_custom_vars lists custom variables of hosts and services separated by commas, attached to events as
+    -- extra tags, e.g., "os, owner".
+    icinga2_custom_vars text,
+
+    -- transform_template is an optional Go text/template for non-"icinga2" sources. If set, each body submitted to the
+    -- Listener's /process-event endpoint is parsed as arbitrary JSON and passed to this template, whose output must be
+    -- a JSON-encoded event. This allows integrating foreign webhooks without an additional translation service.
+    transform_template text,
+
+    -- auto_close_after optionally overrides the daemon's incident-auto-close-after in milliseconds for this source's
+    -- incidents. Incidents not receiving any event within this duration are closed, a value of 0 disables it.
+    auto_close_after bigint,
+
+    -- severity_mapping optionally maps the severities of this source's events to other ones before they are processed,
+    -- encoded as a JSON object of severity names, e.g., {"warning": "crit"}.
+    severity_mapping text,
+
+    -- url_template optionally replaces the URL of this source's events by a Go template executed with the event, e.g.,
+    -- to deep-link into the source's own UI.
+    url_template text,
 
     changed_at bigint NOT NULL,
     deleted boolenum NOT NULL DEFAULT 'n',
@@ -264,12 +420,26 @@ CREATE TABLE source (
     -- https://icinga.com/docs/icinga-web/latest/doc/20-Advanced-Topics/#manual-user-creation-for-database-authentication-backend
     CONSTRAINT ck_source_bcrypt_listener_password_hash CHECK (listener_password_hash IS NULL OR listener_password_hash LIKE '$2y$%'),
     CONSTRAINT ck_source_icinga2_has_config CHECK (type != 'icinga2' OR (icinga2_base_url IS NOT NULL AND icinga2_auth_user IS NOT NULL AND icinga2_auth_pass IS NOT NULL)),
+    CONSTRAINT ck_source_auto_close_after_not_negative CHECK (auto_close_after >= 0),
 
-    CONSTRAINT pk_source PRIMARY KEY (id)
+    CONSTRAINT pk_source PRIMARY KEY (id),
+    CONSTRAINT fk_source_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id)
 );
 
 CREATE INDEX idx_source_changed_at ON source(changed_at);
 
+-- Time up to which an Icinga 2 source has caught up on all changes, allowing it to only catch up on later ones.
+CREATE TABLE source_checkpoint (
+    source_id bigint NOT NULL,
+    -- Instances serving different shards, see the sharding configuration, each catch up on their own objects.
+    shard_count integer NOT NULL,
+    shard_mask bigint NOT NULL, -- bitmask of the served shards
+    checkpoint bigint NOT NULL,
+
+    CONSTRAINT pk_source_checkpoint PRIMARY KEY (source_id, shard_count, shard_mask),
+    CONSTRAINT fk_source_checkpoint_source FOREIGN KEY (source_id) REFERENCES source(id)
+);
+
 CREATE TABLE object (
     id bytea NOT NULL, -- SHA256 of identifying tags and the source.id
     source_id bigint NOT NULL,
@@ -302,6 +472,25 @@ CREATE TABLE object_extra_tag (
     CONSTRAINT fk_object_extra_tag_object FOREIGN KEY (object_id) REFERENCES object(id)
 );
 
+-- The following indexes support searching objects by their source or tags, including the facet counts.
+CREATE INDEX idx_object_source_id ON object(source_id);
+CREATE INDEX idx_object_id_tag_tag ON object_id_tag(tag);
+CREATE INDEX idx_object_extra_tag_tag ON object_extra_tag(tag);
+
+-- Objects are referenced by their IDs only, as a dependency may be configured before either object was seen in an event.
+CREATE TABLE object_dependency (
+    parent_object_id bytea NOT NULL,
+    child_object_id bytea NOT NULL,
+    source_id bigint NOT NULL,
+    changed_at bigint NOT NULL,
+
+    CONSTRAINT pk_object_dependency PRIMARY KEY (parent_object_id, child_object_id),
+    CONSTRAINT ck_object_dependency_not_self CHECK (parent_object_id != child_object_id),
+    CONSTRAINT fk_object_dependency_source FOREIGN KEY (source_id) REFERENCES source(id)
+);
+
+CREATE INDEX idx_object_dependency_child_object_id ON object_dependency(child_object_id);
+
 CREATE TYPE event_type AS ENUM (
     'acknowledgement-cleared',
     'acknowledgement-set',
@@ -313,6 +502,7 @@ CREATE TYPE event_type AS ENUM (
     'flapping-start',
     'incident-age',
     'mute',
+    'muted-summary',
     'state',
     'unmute'
 );
@@ -328,33 +518,150 @@ CREATE TABLE event (
     username citext,
     mute boolenum,
     mute_reason text,
+    -- Correlates the event with its history and notifications, either generated or supplied by the source.
+    trace_id varchar(64),
 
     CONSTRAINT pk_event PRIMARY KEY (id),
     CONSTRAINT fk_event_object FOREIGN KEY (object_id) REFERENCES object(id)
 );
 
+CREATE INDEX idx_event_trace_id ON event(trace_id);
+CREATE INDEX idx_event_time ON event(time);
+
+-- Named set of escalations referenced by any number of rules, instead of each rule defining the same escalations.
+CREATE TABLE escalation_policy (
+    id bigserial,
+    name citext NOT NULL,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_escalation_policy PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_escalation_policy_changed_at ON escalation_policy(changed_at);
+
 CREATE TABLE rule (
     id bigserial,
+    tenant_id bigint,
     name citext NOT NULL,
     timeperiod_id bigint,
     object_filter text,
+    -- The escalations of this policy are used in addition to the rule's own ones.
+    escalation_policy_id bigint,
+    -- Annotations included in all notifications caused by this rule, telling the recipients what to do.
+    description text,
+    runbook_url text,
+    chat_channel text,
 
     changed_at bigint NOT NULL,
     deleted boolenum NOT NULL DEFAULT 'n',
 
     CONSTRAINT pk_rule PRIMARY KEY (id),
-    CONSTRAINT fk_rule_timeperiod FOREIGN KEY (timeperiod_id) REFERENCES timeperiod(id)
+    CONSTRAINT fk_rule_timeperiod FOREIGN KEY (timeperiod_id) REFERENCES timeperiod(id),
+    CONSTRAINT fk_rule_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id),
+    CONSTRAINT fk_rule_escalation_policy FOREIGN KEY (escalation_policy_id) REFERENCES escalation_policy(id)
 );
 
 CREATE INDEX idx_rule_changed_at ON rule(changed_at);
 
+-- Suppresses notifications for all objects matching the filter while active, independent of the source's downtimes.
+CREATE TABLE maintenance_window (
+    id bigserial,
+    name citext NOT NULL,
+    -- Optional bounds in milliseconds, the window is further restricted to the time period, if set.
+    start_time bigint,
+    end_time bigint,
+    timeperiod_id bigint,
+    object_filter text,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_maintenance_window PRIMARY KEY (id),
+    CONSTRAINT fk_maintenance_window_timeperiod FOREIGN KEY (timeperiod_id) REFERENCES timeperiod(id),
+    CONSTRAINT ck_maintenance_window_end_after_start CHECK (start_time IS NULL OR end_time IS NULL OR end_time > start_time)
+);
+
+CREATE INDEX idx_maintenance_window_changed_at ON maintenance_window(changed_at);
+
+-- Tokens authenticating requests to the HTTP API, scoped by their capabilities. Only their SHA-256 hashes are stored.
+CREATE TABLE api_token (
+    id bigserial,
+    -- Tokens of a tenant only access the incidents of the sources and the contacts visible to this tenant.
+    tenant_id bigint,
+    name citext NOT NULL,
+    token_hash bytea NOT NULL,
+    -- The source on whose behalf the token submits events, required for the ingest_events capability.
+    source_id bigint,
+
+    -- Capabilities granted to the token, each limiting the endpoints it may use.
+    ingest_events boolenum NOT NULL DEFAULT 'n',
+    read_incidents boolenum NOT NULL DEFAULT 'n',
+    manage_incidents boolenum NOT NULL DEFAULT 'n',
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_api_token PRIMARY KEY (id),
+    CONSTRAINT uk_api_token_token_hash UNIQUE (token_hash),
+    CONSTRAINT ck_api_token_token_hash_is_sha256 CHECK (length(token_hash) = 256/8),
+    CONSTRAINT ck_api_token_ingest_events_requires_source CHECK (ingest_events = 'n' OR source_id IS NOT NULL),
+    CONSTRAINT fk_api_token_source FOREIGN KEY (source_id) REFERENCES source(id),
+    CONSTRAINT fk_api_token_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id)
+);
+
+CREATE INDEX idx_api_token_changed_at ON api_token(changed_at);
+
+-- Go templates for the subject and message of notifications sent via a channel, optionally only for a single rule.
+-- Either part may be NULL to keep the built-in default.
+CREATE TABLE notification_template (
+    id bigserial,
+    channel_id bigint NOT NULL,
+    rule_id bigint,
+    subject text,
+    message text,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_notification_template PRIMARY KEY (id),
+    CONSTRAINT fk_notification_template_channel FOREIGN KEY (channel_id) REFERENCES channel(id),
+    CONSTRAINT fk_notification_template_rule FOREIGN KEY (rule_id) REFERENCES rule(id),
+    CONSTRAINT ck_notification_template_subject_or_message CHECK (subject IS NOT NULL OR message IS NOT NULL)
+);
+
+CREATE INDEX idx_notification_template_changed_at ON notification_template(changed_at);
+
+-- Results of the daily test notifications sent via each channel to the self-test contact, see the daemon's self-test.
+CREATE TABLE channel_self_test (
+    id bigserial,
+    channel_id bigint NOT NULL,
+    contact_id bigint NOT NULL,
+    time bigint NOT NULL,
+    success boolenum NOT NULL,
+    error text,
+
+    CONSTRAINT pk_channel_self_test PRIMARY KEY (id),
+    CONSTRAINT fk_channel_self_test_channel FOREIGN KEY (channel_id) REFERENCES channel(id),
+    CONSTRAINT fk_channel_self_test_contact FOREIGN KEY (contact_id) REFERENCES contact(id)
+);
+
+CREATE INDEX idx_channel_self_test_channel_time ON channel_self_test(channel_id, time DESC);
+
 CREATE TABLE rule_escalation (
     id bigserial,
-    rule_id bigint NOT NULL,
+    -- Each escalation belongs to either a rule or an escalation policy.
+    rule_id bigint,
+    escalation_policy_id bigint,
     position integer,
     condition text,
     name citext, -- if not set, recipients are used as a fallback for display purposes
     fallback_for bigint,
+    -- If set, notifications are repeated in this interval in milliseconds until the incident has a manager or recovers.
+    renotify_interval bigint,
+    -- If set, the incident is only considered handled once this many distinct contacts acknowledged it.
+    required_acknowledgements integer,
 
     changed_at bigint NOT NULL,
     deleted boolenum NOT NULL DEFAULT 'n',
@@ -364,15 +671,23 @@ CREATE TABLE rule_escalation (
     -- Each position in an escalation can only be used once.
     -- Column position must be NULLed for deletion via "deleted = 'y'"
     CONSTRAINT uk_rule_escalation_rule_id_position UNIQUE (rule_id, position),
+    CONSTRAINT uk_rule_escalation_escalation_policy_id_position UNIQUE (escalation_policy_id, position),
 
     CONSTRAINT ck_rule_escalation_not_both_condition_and_fallback_for CHECK (NOT (condition IS NOT NULL AND fallback_for IS NOT NULL)),
     CONSTRAINT ck_rule_escalation_non_deleted_needs_position CHECK (deleted = 'y' OR position IS NOT NULL),
+    CONSTRAINT ck_rule_escalation_renotify_interval_positive CHECK (renotify_interval > 0),
+    CONSTRAINT ck_rule_escalation_either_rule_or_escalation_policy CHECK ((rule_id IS NULL) <> (escalation_policy_id IS NULL)),
     CONSTRAINT fk_rule_escalation_rule FOREIGN KEY (rule_id) REFERENCES rule(id),
+    CONSTRAINT fk_rule_escalation_escalation_policy FOREIGN KEY (escalation_policy_id) REFERENCES escalation_policy(id),
     CONSTRAINT fk_rule_escalation_rule_escalation FOREIGN KEY (fallback_for) REFERENCES rule_escalation(id)
 );
 
 CREATE INDEX idx_rule_escalation_changed_at ON rule_escalation(changed_at);
 
+-- Defines how an escalation recipient resolving to multiple contacts, i.e., a group or schedule, is notified:
+-- all contacts, a single contact rotating through all contacts across incidents, or the least recently notified one.
+CREATE TYPE delivery_strategy AS ENUM ('all', 'round-robin', 'least-recently-notified');
+
 CREATE TABLE rule_escalation_recipient (
     id bigserial,
     rule_escalation_id bigint NOT NULL,
@@ -380,6 +695,7 @@ CREATE TABLE rule_escalation_recipient (
     contactgroup_id bigint,
     schedule_id bigint,
     channel_id bigint,
+    delivery delivery_strategy NOT NULL DEFAULT 'all',
 
     changed_at bigint NOT NULL,
     deleted boolenum NOT NULL DEFAULT 'n',
@@ -395,6 +711,34 @@ CREATE TABLE rule_escalation_recipient (
 
 CREATE INDEX idx_rule_escalation_recipient_changed_at ON rule_escalation_recipient(changed_at);
 
+-- Persists when each contact was last selected by an escalation recipient with a single contact delivery strategy.
+CREATE TABLE rule_escalation_recipient_delivery_state (
+    rule_escalation_recipient_id bigint NOT NULL,
+    contact_id bigint NOT NULL,
+    last_selected_at bigint NOT NULL,
+
+    CONSTRAINT pk_rule_escalation_recipient_delivery_state PRIMARY KEY (rule_escalation_recipient_id, contact_id),
+    CONSTRAINT fk_rule_escalation_recipient_delivery_state_recipient FOREIGN KEY (rule_escalation_recipient_id) REFERENCES rule_escalation_recipient(id),
+    CONSTRAINT fk_rule_escalation_recipient_delivery_state_contact FOREIGN KEY (contact_id) REFERENCES contact(id)
+);
+
+-- Records objects deleted via the daemon's API, allowing them to be restored within the configured grace period.
+CREATE TYPE soft_deletion_object_type AS ENUM ('channel', 'contact', 'rule', 'schedule');
+
+CREATE TABLE soft_deletion (
+    id bigserial,
+    object_type soft_deletion_object_type NOT NULL,
+    object_id bigint NOT NULL,
+    deleted_at bigint NOT NULL,
+    -- JSON list of all rows marked as deleted together with the object, including the values of NULLed columns
+    restore_data text NOT NULL,
+
+    CONSTRAINT pk_soft_deletion PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_soft_deletion_object ON soft_deletion(object_type, object_id);
+CREATE INDEX idx_soft_deletion_deleted_at ON soft_deletion(deleted_at);
+
 CREATE TABLE incident (
     id bigserial,
     object_id bytea NOT NULL,
@@ -406,6 +750,8 @@ CREATE TABLE incident (
     CONSTRAINT fk_incident_object FOREIGN KEY (object_id) REFERENCES object(id)
 );
 
+CREATE INDEX idx_incident_recovered_at ON incident(recovered_at);
+
 CREATE TABLE incident_event (
     incident_id bigint NOT NULL,
     event_id bigint NOT NULL,
@@ -415,6 +761,8 @@ CREATE TABLE incident_event (
     CONSTRAINT fk_incident_event_event FOREIGN KEY (event_id) REFERENCES event(id)
 );
 
+CREATE INDEX idx_incident_event_event_id ON incident_event(event_id);
+
 CREATE TYPE incident_contact_role AS ENUM ('recipient', 'subscriber', 'manager');
 
 CREATE TABLE incident_contact (
@@ -436,6 +784,44 @@ CREATE TABLE incident_contact (
     CONSTRAINT fk_incident_contact_schedule FOREIGN KEY (schedule_id) REFERENCES schedule(id)
 );
 
+CREATE TABLE incident_watch (
+    incident_id bigint NOT NULL,
+    contact_id bigint NOT NULL,
+    -- Contacts are only notified about updates reaching at least this severity, or about all updates if NULL.
+    min_severity severity,
+    created_at bigint NOT NULL,
+
+    CONSTRAINT pk_incident_watch PRIMARY KEY (incident_id, contact_id),
+    CONSTRAINT fk_incident_watch_incident FOREIGN KEY (incident_id) REFERENCES incident(id),
+    CONSTRAINT fk_incident_watch_contact FOREIGN KEY (contact_id) REFERENCES contact(id)
+);
+
+-- State kept by channel plugins about an incident, e.g., the key of a ticket created for it, passed back to the plugin
+-- with each notification of the incident.
+CREATE TABLE incident_channel_state (
+    incident_id bigint NOT NULL,
+    channel_id bigint NOT NULL,
+    state text NOT NULL, -- JSON, as returned by the channel plugin
+    changed_at bigint NOT NULL,
+
+    CONSTRAINT pk_incident_channel_state PRIMARY KEY (incident_id, channel_id),
+    CONSTRAINT fk_incident_channel_state_incident FOREIGN KEY (incident_id) REFERENCES incident(id),
+    CONSTRAINT fk_incident_channel_state_channel FOREIGN KEY (channel_id) REFERENCES channel(id)
+);
+
+-- Named values parsed from "name=value" directives within acknowledgement comments, e.g., "ticket=INC1234".
+CREATE TABLE incident_annotation (
+    incident_id bigint NOT NULL,
+    name varchar(255) NOT NULL,
+    value text NOT NULL,
+    event_id bigint, -- acknowledgement event the value was parsed from
+    changed_at bigint NOT NULL,
+
+    CONSTRAINT pk_incident_annotation PRIMARY KEY (incident_id, name),
+    CONSTRAINT fk_incident_annotation_incident FOREIGN KEY (incident_id) REFERENCES incident(id),
+    CONSTRAINT fk_incident_annotation_event FOREIGN KEY (event_id) REFERENCES event(id)
+);
+
 CREATE TABLE incident_rule (
     incident_id bigint NOT NULL,
     rule_id bigint NOT NULL,
@@ -474,6 +860,7 @@ CREATE TABLE incident_history (
     old_recipient_role incident_contact_role,
     notification_state notification_state_type,
     sent_at bigint,
+    trace_id varchar(64),
 
     CONSTRAINT pk_incident_history PRIMARY KEY (id),
     CONSTRAINT fk_incident_history_incident_rule_escalation_state FOREIGN KEY (incident_id, rule_escalation_id) REFERENCES incident_rule_escalation_state(incident_id, rule_escalation_id),
@@ -489,6 +876,45 @@ CREATE TABLE incident_history (
 
 CREATE INDEX idx_incident_history_time_type ON incident_history(time, type);
 COMMENT ON INDEX idx_incident_history_time_type IS 'Incident History ordered by time/type';
+CREATE INDEX idx_incident_history_incident_id_time ON incident_history(incident_id, time);
+COMMENT ON INDEX idx_incident_history_incident_id_time IS 'Incident History of a single incident ordered by time';
+CREATE INDEX idx_incident_history_event_id ON incident_history(event_id);
+
+-- Rule escalations contributing to a notification of the incident history. A contact resolved by multiple overlapping
+-- escalations for the same channel is only notified once, referencing all of them here.
+CREATE TABLE incident_history_rule (
+    incident_history_id bigint NOT NULL,
+    rule_escalation_id bigint NOT NULL,
+    rule_id bigint NOT NULL,
+
+    CONSTRAINT pk_incident_history_rule PRIMARY KEY (incident_history_id, rule_escalation_id),
+    CONSTRAINT fk_incident_history_rule_incident_history FOREIGN KEY (incident_history_id) REFERENCES incident_history(id),
+    CONSTRAINT fk_incident_history_rule_rule_escalation FOREIGN KEY (rule_escalation_id) REFERENCES rule_escalation(id),
+    CONSTRAINT fk_incident_history_rule_rule FOREIGN KEY (rule_id) REFERENCES rule(id)
+);
+
+-- Redeemed acknowledgement links sent in notifications, identified by their nonce to prevent using them twice.
+CREATE TABLE incident_ack_link (
+    nonce varchar(32) NOT NULL,
+    incident_id bigint NOT NULL,
+    contact_id bigint NOT NULL,
+    redeemed_at bigint NOT NULL,
+
+    CONSTRAINT pk_incident_ack_link PRIMARY KEY (nonce),
+    CONSTRAINT fk_incident_ack_link_incident FOREIGN KEY (incident_id) REFERENCES incident(id),
+    CONSTRAINT fk_incident_ack_link_contact FOREIGN KEY (contact_id) REFERENCES contact(id)
+);
+
+-- Volatile in-memory state of open incidents, periodically exported for a fast takeover by another daemon.
+CREATE TABLE incident_state (
+    incident_id bigint NOT NULL,
+    last_notified_at bigint,
+    last_event_at bigint,
+    exported_at bigint NOT NULL,
+
+    CONSTRAINT pk_incident_state PRIMARY KEY (incident_id),
+    CONSTRAINT fk_incident_state_incident FOREIGN KEY (incident_id) REFERENCES incident(id)
+);
 
 CREATE TABLE browser_session (
     php_session_id varchar(256) NOT NULL,
@@ -502,3 +928,118 @@ CREATE TABLE browser_session (
 
 CREATE INDEX idx_browser_session_authenticated_at ON browser_session (authenticated_at DESC);
 CREATE INDEX idx_browser_session_username_agent ON browser_session (username, user_agent);
+
+-- Daemon instances sharing this database, each writing a heartbeat regularly. Only the responsible one processes
+-- events and sends notifications, until its heartbeat times out and another instance takes over. With sharding, one
+-- instance per set of shards is responsible.
+CREATE TABLE daemon_instance (
+    id varchar(32) NOT NULL,
+    hostname text NOT NULL,
+    responsible boolenum NOT NULL DEFAULT 'n',
+    heartbeat bigint NOT NULL,
+    -- Bitmask of the shards served by the instance among shard_count shards.
+    shard_count smallint NOT NULL DEFAULT 1,
+    shards bigint NOT NULL DEFAULT 1,
+
+    CONSTRAINT pk_daemon_instance PRIMARY KEY (id)
+);
+
+-- Events received but not processed yet, see package eventqueue. Each entry is hidden from being claimed again until
+-- visible_at, i.e., while it is being processed.
+CREATE TABLE event_queue (
+    id bigserial,
+    shard smallint NOT NULL,
+    source_id bigint NOT NULL,
+    time bigint NOT NULL,
+    event text NOT NULL,
+    enqueued_at bigint NOT NULL,
+    visible_at bigint NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+
+    CONSTRAINT pk_event_queue PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_event_queue_shard_visible_at ON event_queue(shard, visible_at);
+
+-- Notifies the daemons about configuration changes, e.g., made by Icinga Web, to synchronize them immediately instead
+-- of waiting for the next periodic synchronization. The payload is the name of the changed table.
+CREATE FUNCTION notify_config_change()
+    RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+        BEGIN
+            PERFORM pg_notify('icinga_notifications_config', TG_TABLE_NAME);
+            RETURN NULL;
+        END;
+    $$;
+
+DO $$
+    DECLARE
+        t text;
+    BEGIN
+        FOREACH t IN ARRAY ARRAY[
+            'channel', 'contact', 'contact_address', 'contact_opt_out', 'contact_channel_preference',
+            'contactgroup', 'contactgroup_member', 'contactgroup_region', 'schedule', 'rotation', 'rotation_member',
+            'schedule_override', 'timeperiod', 'timeperiod_entry', 'escalation_policy', 'rule', 'rule_escalation',
+            'rule_escalation_recipient', 'source', 'maintenance_window', 'api_token', 'notification_template'
+        ] LOOP
+            EXECUTE format(
+                'CREATE TRIGGER %I AFTER INSERT OR UPDATE OR DELETE ON %I'
+                    ' FOR EACH STATEMENT EXECUTE FUNCTION notify_config_change()',
+                'trg_' || t || '_notify_config_change', t);
+        END LOOP;
+    END;
+$$;
+
+-- Notifies the daemons about changed recipients and watches of an incident, e.g., a contact subscribing to it via
+-- Icinga Web, to invalidate the incident cached by the responsible daemon. The payload is the ID of the incident.
+CREATE FUNCTION notify_incident_change()
+    RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+        BEGIN
+            IF TG_OP = 'DELETE' THEN
+                PERFORM pg_notify('icinga_notifications_incident', OLD.incident_id::text);
+            ELSE
+                PERFORM pg_notify('icinga_notifications_incident', NEW.incident_id::text);
+            END IF;
+            RETURN NULL;
+        END;
+    $$;
+
+CREATE TRIGGER trg_incident_contact_notify_incident_change AFTER INSERT OR UPDATE OR DELETE ON incident_contact
+    FOR EACH ROW EXECUTE FUNCTION notify_incident_change();
+CREATE TRIGGER trg_incident_watch_notify_incident_change AFTER INSERT OR UPDATE OR DELETE ON incident_watch
+    FOR EACH ROW EXECUTE FUNCTION notify_incident_change();
+
+-- Manual actions taken via the daemon, e.g., acknowledgements, subscriptions or channel tests, see package audit. The
+-- states before and after an action are stored as JSON, if applicable.
+CREATE TABLE audit_log (
+    id bigserial,
+    time bigint NOT NULL,
+    action varchar(255) NOT NULL,
+    actor text NOT NULL,
+    remote_addr varchar(255),
+    target text,
+    old_state text,
+    new_state text,
+
+    CONSTRAINT pk_audit_log PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_audit_log_time ON audit_log(time);
+CREATE INDEX idx_audit_log_action ON audit_log(action);
+
+-- Version of this schema, allowing the daemon and the Icinga Web module to refuse destructive operations against a
+-- schema version they do not know. Each upgrade inserts a new row, keeping the history of applied versions.
+CREATE TABLE icinga_notifications_schema (
+    id bigserial,
+    version smallint NOT NULL,
+    timestamp bigint NOT NULL,
+
+    CONSTRAINT pk_icinga_notifications_schema PRIMARY KEY (id),
+    CONSTRAINT uk_icinga_notifications_schema_version UNIQUE (version)
+);
+
+INSERT INTO icinga_notifications_schema (version, timestamp)
+  VALUES (2, extract(epoch from now()) * 1000);
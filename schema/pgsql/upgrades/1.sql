@@ -0,0 +1,194 @@
+ALTER TYPE incident_history_event_type ADD VALUE 'renotified';
+
+ALTER TABLE schedule ADD COLUMN ics_feed_url text;
+
+-- An override temporarily assigns a contact to a schedule for the time between start_time and end_time, e.g., to cover
+-- for a colleague on vacation or to swap shifts. If replaced_contact_id is set, the contact only takes over the shifts
+-- of this contact. Otherwise, the contact takes over the whole schedule, replacing everyone on call.
+CREATE TABLE schedule_override (
+    id bigserial,
+    schedule_id bigint NOT NULL,
+    contact_id bigint NOT NULL,
+    replaced_contact_id bigint,
+    start_time bigint NOT NULL,
+    end_time bigint NOT NULL,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_schedule_override PRIMARY KEY (id),
+    CONSTRAINT ck_schedule_override_time_range CHECK (start_time < end_time),
+    CONSTRAINT fk_schedule_override_schedule FOREIGN KEY (schedule_id) REFERENCES schedule(id),
+    CONSTRAINT fk_schedule_override_contact FOREIGN KEY (contact_id) REFERENCES contact(id),
+    CONSTRAINT fk_schedule_override_replaced_contact FOREIGN KEY (replaced_contact_id) REFERENCES contact(id)
+);
+
+CREATE INDEX idx_schedule_override_changed_at ON schedule_override(changed_at);
+
+-- A contactgroup with regions routes notifications in a follow-the-sun manner. Instead of all its members, only the
+-- members of those regional contactgroups are notified whose timeperiod, e.g., local business hours, is active. If no
+-- region is active, the members of all regional contactgroups are notified.
+CREATE TABLE contactgroup_region (
+    id bigserial,
+    contactgroup_id bigint NOT NULL,
+    region_contactgroup_id bigint NOT NULL,
+    timeperiod_id bigint NOT NULL,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_contactgroup_region PRIMARY KEY (id),
+    CONSTRAINT ck_contactgroup_region_not_self CHECK (contactgroup_id != region_contactgroup_id),
+    CONSTRAINT fk_contactgroup_region_contactgroup FOREIGN KEY (contactgroup_id) REFERENCES contactgroup(id),
+    CONSTRAINT fk_contactgroup_region_region_contactgroup FOREIGN KEY (region_contactgroup_id) REFERENCES contactgroup(id),
+    CONSTRAINT fk_contactgroup_region_timeperiod FOREIGN KEY (timeperiod_id) REFERENCES timeperiod(id)
+);
+
+CREATE INDEX idx_contactgroup_region_changed_at ON contactgroup_region(changed_at);
+
+ALTER TABLE source
+    ADD COLUMN transform_template text,
+    ADD COLUMN auto_close_after bigint,
+    ADD CONSTRAINT ck_source_auto_close_after_not_negative CHECK (auto_close_after >= 0);
+
+-- The following indexes support searching objects by their source or tags, including the facet counts.
+CREATE INDEX idx_object_source_id ON object(source_id);
+CREATE INDEX idx_object_id_tag_tag ON object_id_tag(tag);
+CREATE INDEX idx_object_extra_tag_tag ON object_extra_tag(tag);
+
+-- Objects are referenced by their IDs only, as a dependency may be configured before either object was seen in an event.
+CREATE TABLE object_dependency (
+    parent_object_id bytea NOT NULL,
+    child_object_id bytea NOT NULL,
+    source_id bigint NOT NULL,
+    changed_at bigint NOT NULL,
+
+    CONSTRAINT pk_object_dependency PRIMARY KEY (parent_object_id, child_object_id),
+    CONSTRAINT ck_object_dependency_not_self CHECK (parent_object_id != child_object_id),
+    CONSTRAINT fk_object_dependency_source FOREIGN KEY (source_id) REFERENCES source(id)
+);
+
+CREATE INDEX idx_object_dependency_child_object_id ON object_dependency(child_object_id);
+
+-- Suppresses notifications for all objects matching the filter while active, independent of the source's downtimes.
+CREATE TABLE maintenance_window (
+    id bigserial,
+    name citext NOT NULL,
+    -- Optional bounds in milliseconds, the window is further restricted to the time period, if set.
+    start_time bigint,
+    end_time bigint,
+    timeperiod_id bigint,
+    object_filter text,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_maintenance_window PRIMARY KEY (id),
+    CONSTRAINT fk_maintenance_window_timeperiod FOREIGN KEY (timeperiod_id) REFERENCES timeperiod(id),
+    CONSTRAINT ck_maintenance_window_end_after_start CHECK (start_time IS NULL OR end_time IS NULL OR end_time > start_time)
+);
+
+CREATE INDEX idx_maintenance_window_changed_at ON maintenance_window(changed_at);
+
+-- Tokens authenticating requests to the versioned HTTP API, only their SHA-256 hashes are stored.
+CREATE TABLE api_token (
+    id bigserial,
+    name citext NOT NULL,
+    token_hash bytea NOT NULL,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_api_token PRIMARY KEY (id),
+    CONSTRAINT uk_api_token_token_hash UNIQUE (token_hash),
+    CONSTRAINT ck_api_token_token_hash_is_sha256 CHECK (length(token_hash) = 256/8)
+);
+
+CREATE INDEX idx_api_token_changed_at ON api_token(changed_at);
+
+ALTER TABLE rule_escalation
+    ADD COLUMN renotify_interval bigint,
+    ADD CONSTRAINT ck_rule_escalation_renotify_interval_positive CHECK (renotify_interval > 0);
+
+-- Defines how an escalation recipient resolving to multiple contacts, i.e., a group or schedule, is notified:
+-- all contacts, a single contact rotating through all contacts across incidents, or the least recently notified one.
+CREATE TYPE delivery_strategy AS ENUM ('all', 'round-robin', 'least-recently-notified');
+
+ALTER TABLE rule_escalation_recipient ADD COLUMN delivery delivery_strategy NOT NULL DEFAULT 'all';
+
+-- Persists when each contact was last selected by an escalation recipient with a single contact delivery strategy.
+CREATE TABLE rule_escalation_recipient_delivery_state (
+    rule_escalation_recipient_id bigint NOT NULL,
+    contact_id bigint NOT NULL,
+    last_selected_at bigint NOT NULL,
+
+    CONSTRAINT pk_rule_escalation_recipient_delivery_state PRIMARY KEY (rule_escalation_recipient_id, contact_id),
+    CONSTRAINT fk_rule_escalation_recipient_delivery_state_recipient FOREIGN KEY (rule_escalation_recipient_id) REFERENCES rule_escalation_recipient(id),
+    CONSTRAINT fk_rule_escalation_recipient_delivery_state_contact FOREIGN KEY (contact_id) REFERENCES contact(id)
+);
+
+CREATE TYPE soft_deletion_object_type AS ENUM ('channel', 'contact', 'rule', 'schedule');
+
+CREATE TABLE soft_deletion (
+    id bigserial,
+    object_type soft_deletion_object_type NOT NULL,
+    object_id bigint NOT NULL,
+    deleted_at bigint NOT NULL,
+    -- JSON list of all rows marked as deleted together with the object, including the values of NULLed columns
+    restore_data text NOT NULL,
+
+    CONSTRAINT pk_soft_deletion PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_soft_deletion_object ON soft_deletion(object_type, object_id);
+CREATE INDEX idx_soft_deletion_deleted_at ON soft_deletion(deleted_at);
+
+CREATE TABLE incident_watch (
+    incident_id bigint NOT NULL,
+    contact_id bigint NOT NULL,
+    -- Contacts are only notified about updates reaching at least this severity, or about all updates if NULL.
+    min_severity severity,
+    created_at bigint NOT NULL,
+
+    CONSTRAINT pk_incident_watch PRIMARY KEY (incident_id, contact_id),
+    CONSTRAINT fk_incident_watch_incident FOREIGN KEY (incident_id) REFERENCES incident(id),
+    CONSTRAINT fk_incident_watch_contact FOREIGN KEY (contact_id) REFERENCES contact(id)
+);
+
+-- Rule escalations contributing to a notification of the incident history. A contact resolved by multiple overlapping
+-- escalations for the same channel is only notified once, referencing all of them here.
+CREATE TABLE incident_history_rule (
+    incident_history_id bigint NOT NULL,
+    rule_escalation_id bigint NOT NULL,
+    rule_id bigint NOT NULL,
+
+    CONSTRAINT pk_incident_history_rule PRIMARY KEY (incident_history_id, rule_escalation_id),
+    CONSTRAINT fk_incident_history_rule_incident_history FOREIGN KEY (incident_history_id) REFERENCES incident_history(id),
+    CONSTRAINT fk_incident_history_rule_rule_escalation FOREIGN KEY (rule_escalation_id) REFERENCES rule_escalation(id),
+    CONSTRAINT fk_incident_history_rule_rule FOREIGN KEY (rule_id) REFERENCES rule(id)
+);
+
+-- Volatile in-memory state of open incidents, periodically exported for a fast takeover by another daemon.
+CREATE TABLE incident_state (
+    incident_id bigint NOT NULL,
+    last_notified_at bigint,
+    last_event_at bigint,
+    exported_at bigint NOT NULL,
+
+    CONSTRAINT pk_incident_state PRIMARY KEY (incident_id),
+    CONSTRAINT fk_incident_state_incident FOREIGN KEY (incident_id) REFERENCES incident(id)
+);
+
+-- Version of this schema, allowing the daemon and the Icinga Web module to refuse destructive operations against a
+-- schema version they do not know. Each upgrade inserts a new row, keeping the history of applied versions.
+CREATE TABLE icinga_notifications_schema (
+    id bigserial,
+    version smallint NOT NULL,
+    timestamp bigint NOT NULL,
+
+    CONSTRAINT pk_icinga_notifications_schema PRIMARY KEY (id),
+    CONSTRAINT uk_icinga_notifications_schema_version UNIQUE (version)
+);
+
+INSERT INTO icinga_notifications_schema (version, timestamp)
+  VALUES (1, extract(epoch from now()) * 1000);
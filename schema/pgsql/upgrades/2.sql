@@ -0,0 +1,340 @@
+ALTER TYPE incident_history_event_type ADD VALUE 'acknowledgement_quorum_reached';
+ALTER TYPE incident_history_event_type ADD VALUE 'contact_channel_degraded';
+ALTER TYPE notification_state_type ADD VALUE 'throttled';
+ALTER TYPE event_type ADD VALUE 'muted-summary' AFTER 'mute';
+
+-- Sources, rules, contacts, schedules and channels can be scoped to a tenant, e.g., a team or customer sharing the
+-- daemon with others. Events of a tenant's source are only processed by the rules of the same tenant and notify its
+-- contacts via its channels, while objects without a tenant are shared by all tenants.
+CREATE TABLE tenant (
+    id bigserial,
+    name citext NOT NULL,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_tenant PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_tenant_changed_at ON tenant(changed_at);
+
+ALTER TABLE channel
+    ADD COLUMN tenant_id bigint,
+    ADD CONSTRAINT fk_channel_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id);
+
+ALTER TABLE contact
+    ADD COLUMN tenant_id bigint,
+    ADD COLUMN out_of_office boolenum NOT NULL DEFAULT 'n',
+    ADD COLUMN substitute_contact_id bigint,
+    ADD COLUMN quiet_hours_timeperiod_id bigint,
+    ADD CONSTRAINT fk_contact_substitute_contact FOREIGN KEY (substitute_contact_id) REFERENCES contact(id),
+    ADD CONSTRAINT fk_contact_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id),
+    ADD CONSTRAINT fk_contact_quiet_hours_timeperiod FOREIGN KEY (quiet_hours_timeperiod_id) REFERENCES timeperiod(id);
+
+ALTER TABLE contact_address ADD COLUMN verified boolenum NOT NULL DEFAULT 'y';
+
+-- Temporary opt-outs of a contact from a channel, or from all channels if channel_id is NULL. Opt-outs must expire and
+-- are never deleted, but ended early by setting ended_at, keeping them as an audit trail.
+CREATE TABLE contact_opt_out (
+    id bigserial,
+    contact_id bigint NOT NULL,
+    channel_id bigint,
+    reason text,
+    created_by citext, -- name of the API token or user who created the opt-out
+    created_at bigint NOT NULL,
+    expires_at bigint NOT NULL,
+    ended_at bigint,
+    ended_by citext,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_contact_opt_out PRIMARY KEY (id),
+    CONSTRAINT fk_contact_opt_out_contact FOREIGN KEY (contact_id) REFERENCES contact(id),
+    CONSTRAINT fk_contact_opt_out_channel FOREIGN KEY (channel_id) REFERENCES channel(id),
+    CONSTRAINT ck_contact_opt_out_expires_after_creation CHECK (expires_at > created_at)
+);
+
+CREATE INDEX idx_contact_opt_out_changed_at ON contact_opt_out(changed_at);
+
+-- Minimum severity of the incidents a contact is notified about via a channel.
+CREATE TABLE contact_channel_preference (
+    id bigserial,
+    contact_id bigint NOT NULL,
+    channel_id bigint NOT NULL,
+    min_severity severity NOT NULL,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_contact_channel_preference PRIMARY KEY (id),
+    CONSTRAINT fk_contact_channel_preference_contact FOREIGN KEY (contact_id) REFERENCES contact(id),
+    CONSTRAINT fk_contact_channel_preference_channel FOREIGN KEY (channel_id) REFERENCES channel(id)
+);
+
+CREATE INDEX idx_contact_channel_preference_changed_at ON contact_channel_preference(changed_at);
+
+ALTER TABLE schedule
+    ADD COLUMN tenant_id bigint,
+    ADD CONSTRAINT fk_schedule_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id);
+
+ALTER TABLE timeperiod ADD COLUMN name text;
+
+ALTER TABLE source
+    ADD COLUMN tenant_id bigint,
+    ADD COLUMN listener_client_name text,
+    ADD COLUMN listener_client_ca_pem text,
+    ADD COLUMN listener_client_fingerprint text,
+    ADD COLUMN icinga2_write_back boolenum NOT NULL DEFAULT 'n',
+    ADD COLUMN icinga2_custom_vars text,
+    ADD COLUMN severity_mapping text,
+    ADD COLUMN url_template text,
+    ADD CONSTRAINT fk_source_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id);
+
+-- Time up to which an Icinga 2 source has caught up on all changes, allowing it to only catch up on later ones.
+CREATE TABLE source_checkpoint (
+    source_id bigint NOT NULL,
+    -- Instances serving different shards, see the sharding configuration, each catch up on their own objects.
+    shard_count integer NOT NULL,
+    shard_mask bigint NOT NULL, -- bitmask of the served shards
+    checkpoint bigint NOT NULL,
+
+    CONSTRAINT pk_source_checkpoint PRIMARY KEY (source_id, shard_count, shard_mask),
+    CONSTRAINT fk_source_checkpoint_source FOREIGN KEY (source_id) REFERENCES source(id)
+);
+
+ALTER TABLE event ADD COLUMN trace_id varchar(64);
+
+CREATE INDEX idx_event_trace_id ON event(trace_id);
+CREATE INDEX idx_event_time ON event(time);
+
+-- Named set of escalations referenced by any number of rules, instead of each rule defining the same escalations.
+CREATE TABLE escalation_policy (
+    id bigserial,
+    name citext NOT NULL,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_escalation_policy PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_escalation_policy_changed_at ON escalation_policy(changed_at);
+
+ALTER TABLE rule
+    ADD COLUMN tenant_id bigint,
+    ADD COLUMN escalation_policy_id bigint,
+    ADD COLUMN description text,
+    ADD COLUMN runbook_url text,
+    ADD COLUMN chat_channel text,
+    ADD CONSTRAINT fk_rule_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id),
+    ADD CONSTRAINT fk_rule_escalation_policy FOREIGN KEY (escalation_policy_id) REFERENCES escalation_policy(id);
+
+ALTER TABLE api_token
+    ADD COLUMN tenant_id bigint,
+    ADD COLUMN source_id bigint,
+    ADD COLUMN ingest_events boolenum NOT NULL DEFAULT 'n',
+    ADD COLUMN read_incidents boolenum NOT NULL DEFAULT 'n',
+    ADD COLUMN manage_incidents boolenum NOT NULL DEFAULT 'n',
+    ADD CONSTRAINT ck_api_token_ingest_events_requires_source CHECK (ingest_events = 'n' OR source_id IS NOT NULL),
+    ADD CONSTRAINT fk_api_token_source FOREIGN KEY (source_id) REFERENCES source(id),
+    ADD CONSTRAINT fk_api_token_tenant FOREIGN KEY (tenant_id) REFERENCES tenant(id);
+
+-- Tokens created before capabilities were introduced had full access to the versioned HTTP API.
+UPDATE api_token SET read_incidents = 'y', manage_incidents = 'y';
+
+-- Go templates for the subject and message of notifications sent via a channel, optionally only for a single rule.
+-- Either part may be NULL to keep the built-in default.
+CREATE TABLE notification_template (
+    id bigserial,
+    channel_id bigint NOT NULL,
+    rule_id bigint,
+    subject text,
+    message text,
+
+    changed_at bigint NOT NULL,
+    deleted boolenum NOT NULL DEFAULT 'n',
+
+    CONSTRAINT pk_notification_template PRIMARY KEY (id),
+    CONSTRAINT fk_notification_template_channel FOREIGN KEY (channel_id) REFERENCES channel(id),
+    CONSTRAINT fk_notification_template_rule FOREIGN KEY (rule_id) REFERENCES rule(id),
+    CONSTRAINT ck_notification_template_subject_or_message CHECK (subject IS NOT NULL OR message IS NOT NULL)
+);
+
+CREATE INDEX idx_notification_template_changed_at ON notification_template(changed_at);
+
+-- Results of the daily test notifications sent via each channel to the self-test contact, see the daemon's self-test.
+CREATE TABLE channel_self_test (
+    id bigserial,
+    channel_id bigint NOT NULL,
+    contact_id bigint NOT NULL,
+    time bigint NOT NULL,
+    success boolenum NOT NULL,
+    error text,
+
+    CONSTRAINT pk_channel_self_test PRIMARY KEY (id),
+    CONSTRAINT fk_channel_self_test_channel FOREIGN KEY (channel_id) REFERENCES channel(id),
+    CONSTRAINT fk_channel_self_test_contact FOREIGN KEY (contact_id) REFERENCES contact(id)
+);
+
+CREATE INDEX idx_channel_self_test_channel_time ON channel_self_test(channel_id, time DESC);
+
+ALTER TABLE rule_escalation
+    ALTER COLUMN rule_id DROP NOT NULL,
+    ADD COLUMN escalation_policy_id bigint,
+    ADD COLUMN required_acknowledgements integer,
+    ADD CONSTRAINT uk_rule_escalation_escalation_policy_id_position UNIQUE (escalation_policy_id, position),
+    ADD CONSTRAINT ck_rule_escalation_either_rule_or_escalation_policy CHECK ((rule_id IS NULL) <> (escalation_policy_id IS NULL)),
+    ADD CONSTRAINT fk_rule_escalation_escalation_policy FOREIGN KEY (escalation_policy_id) REFERENCES escalation_policy(id);
+
+CREATE INDEX idx_incident_recovered_at ON incident(recovered_at);
+
+CREATE INDEX idx_incident_event_event_id ON incident_event(event_id);
+
+-- State kept by channel plugins about an incident, e.g., the key of a ticket created for it, passed back to the plugin
+-- with each notification of the incident.
+CREATE TABLE incident_channel_state (
+    incident_id bigint NOT NULL,
+    channel_id bigint NOT NULL,
+    state text NOT NULL, -- JSON, as returned by the channel plugin
+    changed_at bigint NOT NULL,
+
+    CONSTRAINT pk_incident_channel_state PRIMARY KEY (incident_id, channel_id),
+    CONSTRAINT fk_incident_channel_state_incident FOREIGN KEY (incident_id) REFERENCES incident(id),
+    CONSTRAINT fk_incident_channel_state_channel FOREIGN KEY (channel_id) REFERENCES channel(id)
+);
+
+-- Named values parsed from "name=value" directives within acknowledgement comments, e.g., "ticket=INC1234".
+CREATE TABLE incident_annotation (
+    incident_id bigint NOT NULL,
+    name varchar(255) NOT NULL,
+    value text NOT NULL,
+    event_id bigint, -- acknowledgement event the value was parsed from
+    changed_at bigint NOT NULL,
+
+    CONSTRAINT pk_incident_annotation PRIMARY KEY (incident_id, name),
+    CONSTRAINT fk_incident_annotation_incident FOREIGN KEY (incident_id) REFERENCES incident(id),
+    CONSTRAINT fk_incident_annotation_event FOREIGN KEY (event_id) REFERENCES event(id)
+);
+
+ALTER TABLE incident_history ADD COLUMN trace_id varchar(64);
+
+CREATE INDEX idx_incident_history_incident_id_time ON incident_history(incident_id, time);
+COMMENT ON INDEX idx_incident_history_incident_id_time IS 'Incident History of a single incident ordered by time';
+CREATE INDEX idx_incident_history_event_id ON incident_history(event_id);
+
+-- Redeemed acknowledgement links sent in notifications, identified by their nonce to prevent using them twice.
+CREATE TABLE incident_ack_link (
+    nonce varchar(32) NOT NULL,
+    incident_id bigint NOT NULL,
+    contact_id bigint NOT NULL,
+    redeemed_at bigint NOT NULL,
+
+    CONSTRAINT pk_incident_ack_link PRIMARY KEY (nonce),
+    CONSTRAINT fk_incident_ack_link_incident FOREIGN KEY (incident_id) REFERENCES incident(id),
+    CONSTRAINT fk_incident_ack_link_contact FOREIGN KEY (contact_id) REFERENCES contact(id)
+);
+
+-- Daemon instances sharing this database, each writing a heartbeat regularly. Only the responsible one processes
+-- events and sends notifications, until its heartbeat times out and another instance takes over. With sharding, one
+-- instance per set of shards is responsible.
+CREATE TABLE daemon_instance (
+    id varchar(32) NOT NULL,
+    hostname text NOT NULL,
+    responsible boolenum NOT NULL DEFAULT 'n',
+    heartbeat bigint NOT NULL,
+    -- Bitmask of the shards served by the instance among shard_count shards.
+    shard_count smallint NOT NULL DEFAULT 1,
+    shards bigint NOT NULL DEFAULT 1,
+
+    CONSTRAINT pk_daemon_instance PRIMARY KEY (id)
+);
+
+-- Events received but not processed yet, see package eventqueue. Each entry is hidden from being claimed again until
+-- visible_at, i.e., while it is being processed.
+CREATE TABLE event_queue (
+    id bigserial,
+    shard smallint NOT NULL,
+    source_id bigint NOT NULL,
+    time bigint NOT NULL,
+    event text NOT NULL,
+    enqueued_at bigint NOT NULL,
+    visible_at bigint NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+
+    CONSTRAINT pk_event_queue PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_event_queue_shard_visible_at ON event_queue(shard, visible_at);
+
+-- Notifies the daemons about configuration changes, e.g., made by Icinga Web, to synchronize them immediately instead
+-- of waiting for the next periodic synchronization. The payload is the name of the changed table.
+CREATE FUNCTION notify_config_change()
+    RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+        BEGIN
+            PERFORM pg_notify('icinga_notifications_config', TG_TABLE_NAME);
+            RETURN NULL;
+        END;
+    $$;
+
+DO $$
+    DECLARE
+        t text;
+    BEGIN
+        FOREACH t IN ARRAY ARRAY[
+            'channel', 'contact', 'contact_address', 'contact_opt_out', 'contact_channel_preference',
+            'contactgroup', 'contactgroup_member', 'contactgroup_region', 'schedule', 'rotation', 'rotation_member',
+            'schedule_override', 'timeperiod', 'timeperiod_entry', 'escalation_policy', 'rule', 'rule_escalation',
+            'rule_escalation_recipient', 'source', 'maintenance_window', 'api_token', 'notification_template'
+        ] LOOP
+            EXECUTE format(
+                'CREATE TRIGGER %I AFTER INSERT OR UPDATE OR DELETE ON %I'
+                    ' FOR EACH STATEMENT EXECUTE FUNCTION notify_config_change()',
+                'trg_' || t || '_notify_config_change', t);
+        END LOOP;
+    END;
+$$;
+
+-- Notifies the daemons about changed recipients and watches of an incident, e.g., a contact subscribing to it via
+-- Icinga Web, to invalidate the incident cached by the responsible daemon. The payload is the ID of the incident.
+CREATE FUNCTION notify_incident_change()
+    RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+        BEGIN
+            IF TG_OP = 'DELETE' THEN
+                PERFORM pg_notify('icinga_notifications_incident', OLD.incident_id::text);
+            ELSE
+                PERFORM pg_notify('icinga_notifications_incident', NEW.incident_id::text);
+            END IF;
+            RETURN NULL;
+        END;
+    $$;
+
+CREATE TRIGGER trg_incident_contact_notify_incident_change AFTER INSERT OR UPDATE OR DELETE ON incident_contact
+    FOR EACH ROW EXECUTE FUNCTION notify_incident_change();
+CREATE TRIGGER trg_incident_watch_notify_incident_change AFTER INSERT OR UPDATE OR DELETE ON incident_watch
+    FOR EACH ROW EXECUTE FUNCTION notify_incident_change();
+
+-- Manual actions taken via the daemon, e.g., acknowledgements, subscriptions or channel tests, see package audit. The
+-- states before and after an action are stored as JSON, if applicable.
+CREATE TABLE audit_log (
+    id bigserial,
+    time bigint NOT NULL,
+    action varchar(255) NOT NULL,
+    actor text NOT NULL,
+    remote_addr varchar(255),
+    target text,
+    old_state text,
+    new_state text,
+
+    CONSTRAINT pk_audit_log PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_audit_log_time ON audit_log(time);
+CREATE INDEX idx_audit_log_action ON audit_log(action);
+
+INSERT INTO icinga_notifications_schema (version, timestamp)
+  VALUES (2, extract(epoch from now()) * 1000);